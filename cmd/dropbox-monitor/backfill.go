@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/analysis"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/backfill"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/container"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/core"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// newBackfillCommand creates the backfill subcommand, which imports the
+// revision history of the files in the monitored folders into the database,
+// so reports have history to draw on from the first day:
+//
+//	dropbox-monitor backfill [--revisions 10] [--since 2025-01-01] [--workers 8]
+//
+// Revisions already stored are skipped, so it can be run again after an
+// interruption.
+func newBackfillCommand() *cobra.Command {
+	var (
+		revisions  int
+		sinceValue string
+		workers    int
+	)
+	cmd := &cobra.Command{
+		Use:   "backfill",
+		Short: "Import the revision history of the monitored files into the database",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			since, err := parseExportTime(sinceValue, time.Time{})
+			if err != nil {
+				return fmt.Errorf("invalid --since: %w", err)
+			}
+			return runBackfill(cmd.Context(), backfill.Options{Revisions: revisions, Since: since, Workers: workers})
+		},
+	}
+	flags := cmd.Flags()
+	flags.IntVar(&revisions, "revisions", 0, "Most revisions imported per file (defaults to all Dropbox keeps, up to 100)")
+	flags.StringVar(&sinceValue, "since", "", "Skip revisions made before this date (YYYY-MM-DD or RFC 3339)")
+	flags.IntVar(&workers, "workers", 0, "Number of files whose revisions are listed at a time (defaults to limits.max_concurrent_listings)")
+	return cmd
+}
+
+// runBackfill imports the history of the monitored folders
+func runBackfill(ctx context.Context, opts backfill.Options) error {
+	cfg, logFile, err := loadConfig(loadOptions())
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+	if opts.Workers <= 0 {
+		opts.Workers = cfg.Limits.GetMaxConcurrentListings()
+	}
+
+	state := core.NewStateManager(cfg.State.Path)
+	if err := state.Start(ctx); err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	defer state.Stop(ctx)
+
+	client, err := container.NewDropboxClient(cfg, state)
+	if err != nil {
+		return fmt.Errorf("failed to create dropbox client: %w", err)
+	}
+
+	database, err := db.Open(cfg.Database.GetDriver(), cfg.Database.GetDSN())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	for _, folder := range cfg.Monitoring.GetFolders() {
+		include, exclude := cfg.Monitoring.FolderFilters(folder)
+		opts.Folders = append(opts.Folders, core.FolderOptions{
+			Path:      folder.Path,
+			Recursive: folder.IsRecursive(),
+			Include:   include,
+			Exclude:   exclude,
+		})
+	}
+
+	// Revisions are classified by the rules alone; asking the AI provider
+	// about every revision of every file would cost too much
+	if cfg.Classification.Enabled() {
+		rules, err := analysis.NewRules(cfg.Classification.ToRulesConfig())
+		if err != nil {
+			return fmt.Errorf("failed to create classification rules: %w", err)
+		}
+		opts.Classify = rules.Apply
+	}
+
+	importer, err := backfill.NewImporter(client, database, slog.Default())
+	if err != nil {
+		return err
+	}
+	result, err := importer.Run(ctx, opts)
+	fmt.Printf("Imported %d revisions of %d files\n", result.Revisions, result.Files)
+	return err
+}
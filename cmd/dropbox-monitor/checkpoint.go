@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/core"
+	"github.com/spf13/cobra"
+)
+
+// newCheckpointCommand creates the checkpoint subcommand, which copies the
+// change stream cursors between instances so monitoring continues without a
+// gap:
+//
+//	dropbox-monitor checkpoint export --file checkpoint.json
+//	dropbox-monitor checkpoint import --file checkpoint.json
+//
+// The instance whose state is imported into must be stopped, as a running
+// instance overwrites its state file.
+func newCheckpointCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "checkpoint",
+		Short: "Export or import the change stream cursors",
+	}
+	for _, action := range []string{"export", "import"} {
+		var file string
+		sub := &cobra.Command{
+			Use:   action,
+			Short: strings.ToUpper(action[:1]) + action[1:] + " the change stream cursors",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runCheckpoint(cmd.Context(), action, file)
+			},
+		}
+		sub.Flags().StringVar(&file, "file", "-", "Checkpoint file to write on export or read on import (\"-\" for stdout/stdin)")
+		cmd.AddCommand(sub)
+	}
+	return cmd
+}
+
+// runCheckpoint exports the cursors to file or imports them from it, as
+// action says
+func runCheckpoint(ctx context.Context, action, file string) error {
+	cfg, logFile, err := loadConfig(loadOptions())
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	state := core.NewStateManager(cfg.State.Path)
+	if err := state.Start(ctx); err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	defer state.Stop(ctx)
+
+	if action == "export" {
+		return exportCheckpoint(state, file)
+	}
+	return importCheckpoint(state, file)
+}
+
+// exportCheckpoint writes the cursors in state to path
+func exportCheckpoint(state *core.StateManager, path string) error {
+	if path == "-" {
+		return state.ExportCheckpoint(os.Stdout)
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if err := state.ExportCheckpoint(out); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", path, err)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d cursors to %s\n", len(state.Checkpoint().Cursors), path)
+	return nil
+}
+
+// importCheckpoint restores the cursors in the checkpoint at path into state
+func importCheckpoint(state *core.StateManager, path string) error {
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	restored, err := state.ImportCheckpoint(in)
+	if err != nil {
+		return err
+	}
+	for _, key := range restored {
+		fmt.Fprintf(os.Stderr, "Restored %s\n", key)
+	}
+	fmt.Fprintf(os.Stderr, "Imported %d cursors\n", len(restored))
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/spf13/cobra"
+)
+
+// newCircuitBreakerCommand creates the circuit-breaker subcommand, which shows
+// the Dropbox circuit breaker of a running web server, and closes it after a
+// known outage so polling resumes straight away:
+//
+//	dropbox-monitor circuit-breaker --server http://localhost:8080
+//	dropbox-monitor circuit-breaker reset --server http://localhost:8080
+func newCircuitBreakerCommand() *cobra.Command {
+	var server, apiToken string
+	cmd := &cobra.Command{
+		Use:   "circuit-breaker",
+		Short: "Show the Dropbox circuit breaker of a running web server",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return showCircuit(server)
+		},
+	}
+	cmd.PersistentFlags().StringVar(&server, "server", "http://localhost:8080", "Web server address")
+
+	reset := &cobra.Command{
+		Use:   "reset",
+		Short: "Close the Dropbox circuit breaker of a running web server",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := config.LoadEnvFile(globals.envFile); err != nil {
+				return err
+			}
+			if apiToken == "" {
+				apiToken = os.Getenv("MONITOR_API_TOKEN")
+			}
+			return resetCircuit(server, apiToken)
+		},
+	}
+	reset.Flags().StringVar(&apiToken, "api-token", "", "API token (defaults to $MONITOR_API_TOKEN)")
+	cmd.AddCommand(reset)
+	return cmd
+}
+
+// showCircuit prints the circuit breaker reported by the /api/health
+// endpoint of a running web server
+func showCircuit(server string) error {
+	url := strings.TrimSuffix(server, "/") + "/api/health"
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to get health: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server rejected request: %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	var health struct {
+		Circuit *dropbox.CircuitStatus `json:"circuit"`
+	}
+	if err := json.Unmarshal(respBody, &health); err != nil {
+		return fmt.Errorf("failed to decode health: %w", err)
+	}
+	if health.Circuit == nil {
+		return fmt.Errorf("the Dropbox client has no circuit breaker")
+	}
+	printCircuit(*health.Circuit)
+	return nil
+}
+
+// resetCircuit posts to the /api/circuit-breaker/reset endpoint of a
+// running web server and prints the circuit breaker after the reset
+func resetCircuit(server, token string) error {
+	if token == "" {
+		return fmt.Errorf("an API token is required to reset the circuit breaker")
+	}
+
+	url := strings.TrimSuffix(server, "/") + "/api/circuit-breaker/reset"
+	req, err := http.NewRequest(http.MethodPost, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reset circuit breaker: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server rejected reset: %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	var status dropbox.CircuitStatus
+	if err := json.Unmarshal(respBody, &status); err != nil {
+		return fmt.Errorf("failed to decode circuit breaker: %w", err)
+	}
+	printCircuit(status)
+	return nil
+}
+
+// printCircuit prints the state, failure count and latest failure of a
+// circuit breaker
+func printCircuit(status dropbox.CircuitStatus) {
+	fmt.Printf("State:        %s\n", status.State)
+	fmt.Printf("Failures:     %d\n", status.Failures)
+	if status.LastFailure != nil {
+		fmt.Printf("Last failure: %s\n", status.LastFailure.Local().Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/notify"
+	"github.com/spf13/cobra"
+)
+
+// newEmailTestCommand creates the email-test subcommand, which sends a test
+// email with the configured SMTP settings. It needs only the email settings,
+// so the rest of the configuration is not validated.
+func newEmailTestCommand() *cobra.Command {
+	var (
+		message   string
+		smtpHost  string
+		smtpPort  int
+		smtpUser  string
+		smtpPass  string
+		fromEmail string
+		toEmails  []string
+	)
+	cmd := &cobra.Command{
+		Use:   "email-test",
+		Short: "Send a test email with the configured SMTP settings",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := loadOptions(func(cfg *config.Config) {
+				if cfg.EmailConfig == nil {
+					cfg.EmailConfig = &config.EmailConfig{SMTPPort: config.DefaultSMTPPort}
+				}
+				email := cfg.EmailConfig
+				if smtpHost != "" {
+					email.SMTPHost = smtpHost
+				}
+				if smtpPort != 0 {
+					email.SMTPPort = smtpPort
+				}
+				if smtpUser != "" {
+					email.SMTPUsername = smtpUser
+				}
+				if smtpPass != "" {
+					email.SMTPPassword = smtpPass
+				}
+				if fromEmail != "" {
+					email.FromAddress = fromEmail
+				}
+				if len(toEmails) > 0 {
+					email.ToAddresses = toEmails
+				}
+			})
+			opts.SkipValidation = true
+
+			cfg, logFile, err := loadConfig(opts)
+			if err != nil {
+				return err
+			}
+			defer logFile.Close()
+
+			if err := validateConfig(cfg.EmailConfig); err != nil {
+				return fmt.Errorf("configuration error: %w", err)
+			}
+			if err := notify.NewEmailNotifier(cfg.EmailConfig).SendNotification(cmd.Context(), message); err != nil {
+				return fmt.Errorf("failed to send test email: %w", err)
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), "Test email sent successfully!")
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&message, "message", "Test email from Dropbox Monitor", "Email message to send")
+	flags.StringVar(&smtpHost, "smtp-host", "", "SMTP server host, overriding the config file and $SMTP_SERVER")
+	flags.IntVar(&smtpPort, "smtp-port", 0, "SMTP server port, overriding the config file and $SMTP_PORT")
+	flags.StringVar(&smtpUser, "smtp-user", "", "SMTP username, overriding the config file and $SMTP_USERNAME")
+	flags.StringVar(&smtpPass, "smtp-pass", "", "SMTP password, overriding the config file and $SMTP_PASSWORD")
+	flags.StringVar(&fromEmail, "from", "", "From email address, overriding the config file and $FROM_EMAIL")
+	flags.StringSliceVar(&toEmails, "to", nil, "Comma-separated recipient email addresses, overriding the config file and $TO_EMAILS")
+	return cmd
+}
+
+func validateConfig(cfg *config.EmailConfig) error {
+	if cfg.SMTPHost == "" {
+		return fmt.Errorf("SMTP host is required")
+	}
+	if cfg.SMTPUsername == "" {
+		return fmt.Errorf("SMTP username is required")
+	}
+	if cfg.SMTPPassword == "" {
+		return fmt.Errorf("SMTP password is required")
+	}
+	if cfg.FromAddress == "" {
+		return fmt.Errorf("From email address is required")
+	}
+	if len(cfg.ToAddresses) == 0 || cfg.ToAddresses[0] == "" {
+		return fmt.Errorf("At least one recipient email address is required")
+	}
+	return nil
+}
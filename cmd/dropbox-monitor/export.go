@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/reporting/generators"
+	"github.com/spf13/cobra"
+)
+
+// newExportCommand creates the export subcommand, which dumps the stored
+// change history to CSV or XLSX:
+//
+//	dropbox-monitor export --format xlsx --since 2025-01-01 --output changes.xlsx
+func newExportCommand() *cobra.Command {
+	var format, sinceValue, untilValue, output string
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the stored change history to CSV or XLSX",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExport(format, sinceValue, untilValue, output)
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "csv", "Export format: csv or xlsx")
+	cmd.Flags().StringVar(&sinceValue, "since", "", "Export changes from this date (YYYY-MM-DD or RFC 3339); defaults to all history")
+	cmd.Flags().StringVar(&untilValue, "until", "", "Export changes before this date (YYYY-MM-DD or RFC 3339); defaults to now")
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "File to write (\"-\" for stdout)")
+	return cmd
+}
+
+// runExport writes the changes between sinceValue and untilValue to output
+func runExport(format, sinceValue, untilValue, output string) error {
+	write := generators.WriteCSV
+	switch format {
+	case "csv":
+	case "xlsx":
+		write = generators.WriteXLSX
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+
+	since, err := parseExportTime(sinceValue, time.Time{})
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+	until, err := parseExportTime(untilValue, time.Now())
+	if err != nil {
+		return fmt.Errorf("invalid --until: %w", err)
+	}
+	if !until.After(since) {
+		return fmt.Errorf("--until must be after --since")
+	}
+
+	cfg, logFile, err := loadConfig(loadOptions())
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	database, err := db.Open(cfg.Database.GetDriver(), cfg.Database.GetDSN())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	stored, err := database.GetFileChangesBetween(context.Background(), since, until)
+	if err != nil {
+		return fmt.Errorf("failed to load changes: %w", err)
+	}
+	changes := make([]models.FileChange, 0, len(stored))
+	for _, change := range stored {
+		changes = append(changes, change.ToModel())
+	}
+
+	var out io.Writer = os.Stdout
+	if output != "-" {
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", output, err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if err := write(out, changes); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	if file, ok := out.(*os.File); ok && file != os.Stdout {
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to close %s: %w", output, err)
+		}
+	}
+	return nil
+}
+
+// parseExportTime parses a date or RFC 3339 timestamp, returning fallback for
+// an empty value. Dates are midnight local time.
+func parseExportTime(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/container"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/core"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/spf13/cobra"
+)
+
+// newFoldersCommand creates the folders subcommand, which lists the folders
+// directly under a path with the number of files, total size and last change
+// of everything inside each. The tree is walked in parallel:
+//
+//	dropbox-monitor folders [--workers 8] [/path]
+func newFoldersCommand() *cobra.Command {
+	var workers int
+	cmd := &cobra.Command{
+		Use:   "folders [PATH]",
+		Short: "Summarize the folders under a path",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := ""
+			if len(args) > 0 {
+				root = strings.TrimSuffix(args[0], "/")
+			}
+			return runFolders(cmd.Context(), root, workers)
+		},
+	}
+	cmd.Flags().IntVar(&workers, "workers", 0, "Number of folders listed at a time (defaults to limits.max_concurrent_listings)")
+	return cmd
+}
+
+// runFolders prints the summary of every folder directly under root
+func runFolders(ctx context.Context, root string, workers int) error {
+	cfg, logFile, err := loadConfig(loadOptions())
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+	if workers <= 0 {
+		workers = cfg.Limits.GetMaxConcurrentListings()
+	}
+
+	state := core.NewStateManager(cfg.State.Path)
+	if err := state.Start(ctx); err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	defer state.Stop(ctx)
+
+	client, err := container.NewDropboxClient(cfg, state)
+	if err != nil {
+		return fmt.Errorf("failed to create dropbox client: %w", err)
+	}
+
+	files, err := client.Walk(ctx, root, workers)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FOLDER\tFILES\tBYTES\tLAST CHANGED")
+	for _, summary := range summarizeFolders(root, files) {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", summary.path, summary.files, summary.bytes,
+			summary.lastChanged.Local().Format("2006-01-02 15:04"))
+	}
+	return w.Flush()
+}
+
+// folderSummary totals the files under one folder
+type folderSummary struct {
+	path        string
+	files       int
+	bytes       int64
+	lastChanged time.Time
+}
+
+// summarizeFolders groups files by the folder directly under root that
+// contains them; files directly in root are counted under root itself
+func summarizeFolders(root string, files []*models.FileMetadata) []folderSummary {
+	byFolder := make(map[string]*folderSummary)
+	for _, file := range files {
+		folder := root
+		rel := strings.TrimPrefix(file.Path[min(len(root), len(file.Path)):], "/")
+		if i := strings.Index(rel, "/"); i >= 0 {
+			folder = path.Join("/", root, rel[:i])
+		}
+		if folder == "" {
+			folder = "/"
+		}
+
+		summary, ok := byFolder[folder]
+		if !ok {
+			summary = &folderSummary{path: folder}
+			byFolder[folder] = summary
+		}
+		summary.files++
+		summary.bytes += file.Size
+		if file.Modified.After(summary.lastChanged) {
+			summary.lastChanged = file.Modified
+		}
+	}
+
+	summaries := make([]folderSummary, 0, len(byFolder))
+	for _, summary := range byFolder {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].path < summaries[j].path })
+	return summaries
+}
@@ -0,0 +1,54 @@
+//go:build gui
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/container"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/gui"
+	"github.com/spf13/cobra"
+)
+
+func newGUICommand() *cobra.Command {
+	var flags monitorFlags
+	cmd := &cobra.Command{
+		Use:   "gui",
+		Short: "Monitor the configured folders with the desktop application",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			loadOpts := flags.loadOptions()
+			cfg, logFile, err := loadConfig(loadOpts)
+			if err != nil {
+				return err
+			}
+			defer logFile.Close()
+
+			c, err := container.NewContainer(cfg)
+			if err != nil {
+				return fmt.Errorf("failed to create container: %w", err)
+			}
+			guiApp, err := gui.NewApp(c)
+			if err != nil {
+				return fmt.Errorf("failed to create GUI app: %w", err)
+			}
+
+			return serve(cfg, c, loadOpts, serveOptions{
+				watchConfig: flags.watchConfig,
+				pidFile:     flags.pidFile,
+				start: func(ctx context.Context) error {
+					go func() {
+						if err := guiApp.Start(ctx); err != nil {
+							logger().Error("Error running GUI application", "error", err)
+						}
+					}()
+					return nil
+				},
+				stop: guiApp.Stop,
+			})
+		},
+	}
+	flags.register(cmd)
+	return cmd
+}
@@ -0,0 +1,22 @@
+//go:build !gui
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// newGUICommand stands in for the desktop application, which needs cgo and
+// the graphics libraries and is only built with -tags gui
+func newGUICommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "gui",
+		Short: "Monitor the configured folders with the desktop application",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return fmt.Errorf("this binary was built without the desktop application; rebuild it with -tags gui")
+		},
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// newInjectCommand creates the inject subcommand, which sends change events
+// from a JSON file to a running web server:
+//
+//	dropbox-monitor inject events.json --server http://localhost:8080
+func newInjectCommand() *cobra.Command {
+	var server, apiToken string
+	cmd := &cobra.Command{
+		Use:   "inject FILE",
+		Short: "Inject change events from a JSON file (\"-\" for stdin) into a running web server",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := config.LoadEnvFile(globals.envFile); err != nil {
+				return err
+			}
+			if apiToken == "" {
+				apiToken = os.Getenv("MONITOR_API_TOKEN")
+			}
+			return injectEvents(server, apiToken, args[0])
+		},
+	}
+	cmd.Flags().StringVar(&server, "server", "http://localhost:8080", "Web server address")
+	cmd.Flags().StringVar(&apiToken, "api-token", "", "API token (defaults to $MONITOR_API_TOKEN)")
+	return cmd
+}
+
+// injectEvents posts the change events in path ("-" for stdin) to the
+// /api/events endpoint of a running web server
+func injectEvents(server, token, path string) error {
+	if token == "" {
+		return fmt.Errorf("an API token is required to inject events")
+	}
+
+	var (
+		body []byte
+		err  error
+	)
+	if path == "-" {
+		body, err = io.ReadAll(os.Stdin)
+	} else {
+		body, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read events: %w", err)
+	}
+
+	url := strings.TrimSuffix(server, "/") + "/api/events"
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send events: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("server rejected events: %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	fmt.Println(strings.TrimSpace(string(respBody)))
+	return nil
+}
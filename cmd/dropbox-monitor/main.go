@@ -0,0 +1,235 @@
+// Command dropbox-monitor monitors Dropbox folders and reports their changes.
+// Every entry point is a subcommand of this one binary:
+//
+//	dropbox-monitor run          # monitor and report in the background
+//	dropbox-monitor web          # monitor with the web dashboard and API
+//	dropbox-monitor gui          # monitor with the desktop application
+//	dropbox-monitor check-now    # check every folder once and print a report
+//	dropbox-monitor report       # print the next report without sending it
+//	dropbox-monitor export       # dump the change history to CSV or XLSX
+//
+// The --config, --env, --log-level and --log-format flags apply to every
+// subcommand.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/container"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/daemon"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/spf13/cobra"
+)
+
+// globalFlags are the flags shared by every subcommand
+type globalFlags struct {
+	configPath string
+	envFile    string
+	logLevel   string
+	logFormat  string
+	logFile    string
+}
+
+var globals globalFlags
+
+func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// newRootCommand creates the dropbox-monitor command and its subcommands
+func newRootCommand() *cobra.Command {
+	root := &cobra.Command{
+		Use:          "dropbox-monitor",
+		Short:        "Monitor Dropbox folders and report their changes",
+		SilenceUsage: true,
+	}
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&globals.configPath, "config", "config.yaml", "Path to config file")
+	flags.StringVar(&globals.envFile, "env", ".env", "Path to an optional .env file loaded into the environment")
+	flags.StringVar(&globals.logLevel, "log-level", "", "Log level (debug, info, warn, error), overriding the config file and $LOG_LEVEL")
+	flags.StringVar(&globals.logFormat, "log-format", "", "Log format (text or json), overriding the config file")
+	flags.StringVar(&globals.logFile, "log-file", "", "Also log to this file, rotated as configured under logging, overriding the config file")
+
+	root.AddCommand(
+		newRunCommand(),
+		newWebCommand(),
+		newGUICommand(),
+		newCheckNowCommand(),
+		newReportCommand(),
+		newExportCommand(),
+		newPopulateCommand(),
+		newBackfillCommand(),
+		newEmailTestCommand(),
+		newInjectCommand(),
+		newCircuitBreakerCommand(),
+		newFoldersCommand(),
+		newTeamCommand(),
+		newSearchCommand(),
+		newPruneCommand(),
+		newReportsCommand(),
+		newMigrateCommand(),
+		newCheckpointCommand(),
+		newInstallServiceCommand(),
+	)
+	return root
+}
+
+// loadOptions returns how the configuration is loaded: defaults < config
+// file < environment < flags. overrides apply a subcommand's own flags after
+// the global ones.
+func loadOptions(overrides ...func(*config.Config)) config.LoadOptions {
+	return config.LoadOptions{
+		Path:    globals.configPath,
+		EnvFile: globals.envFile,
+		Overrides: func(cfg *config.Config) {
+			if globals.logLevel != "" {
+				cfg.Logging.Level = globals.logLevel
+			}
+			if globals.logFormat != "" {
+				cfg.Logging.Format = globals.logFormat
+			}
+			if globals.logFile != "" {
+				cfg.Logging.File = globals.logFile
+			}
+			for _, override := range overrides {
+				override(cfg)
+			}
+		},
+	}
+}
+
+// loadConfig loads the configuration with opts and logs at its level and
+// format, to a rotated file as well as stderr when configured, with
+// credentials redacted. The returned closer closes the log file.
+func loadConfig(opts config.LoadOptions) (*config.Config, io.Closer, error) {
+	cfg, err := config.Load(opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	logFile, err := logging.Configure(cfg.Logging.ToOptions(), cfg.Logging.ToLoggerOptions(cfg.SecretValues()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	return cfg, logFile, nil
+}
+
+// serveOptions configures how serve runs the monitor
+type serveOptions struct {
+	// watchConfig reloads the configuration when the config file changes
+	watchConfig bool
+	// pidFile, if set, holds the process ID while the monitor runs
+	pidFile string
+	// start, if set, starts what runs alongside the container, such as the
+	// web server
+	start func(ctx context.Context) error
+	// stop, if set, stops it again before the container stops
+	stop func(ctx context.Context) error
+}
+
+// serve starts c and runs until SIGINT or SIGTERM, reloading the
+// configuration loaded by loadOpts on SIGHUP and, when watching, each time
+// the config file changes. A service manager such as systemd is told when
+// the monitor is ready, reloading and stopping.
+func serve(cfg *config.Config, c *container.Container, loadOpts config.LoadOptions, opts serveOptions) error {
+	if opts.pidFile != "" {
+		pidFile, err := daemon.WritePIDFile(opts.pidFile)
+		if err != nil {
+			return err
+		}
+		defer pidFile.Remove()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if opts.watchConfig {
+		if err := config.WatchFile(ctx, loadOpts.Path, func() { reloadConfig(ctx, c, loadOpts) }); err != nil {
+			logger().Warn("Not watching the config file", "error", err)
+		}
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigChan)
+	go func() {
+		for sig := range sigChan {
+			if sig == syscall.SIGHUP {
+				reloadConfig(ctx, c, loadOpts)
+				continue
+			}
+
+			logger().Info("Received signal, shutting down", "signal", sig)
+			cancel()
+			return
+		}
+	}()
+
+	if err := c.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+	if opts.start != nil {
+		if err := opts.start(ctx); err != nil {
+			c.Stop(context.Background())
+			return err
+		}
+	}
+	notifyServiceManager(daemon.Ready)
+
+	<-ctx.Done()
+	notifyServiceManager(daemon.Stopping)
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer shutdownCancel()
+
+	if opts.stop != nil {
+		if err := opts.stop(shutdownCtx); err != nil {
+			logger().Error("Error during shutdown", "error", err)
+		}
+	}
+	if err := c.Stop(shutdownCtx); err != nil {
+		logger().Error("Error during shutdown", "error", err)
+	}
+	return nil
+}
+
+// reloadConfig reads the configuration file again and applies it to the
+// running container
+func reloadConfig(ctx context.Context, c *container.Container, opts config.LoadOptions) {
+	notifyServiceManager(daemon.Reloading)
+	defer notifyServiceManager(daemon.Ready)
+
+	cfg, err := config.Load(opts)
+	if err != nil {
+		logger().Error("Error reloading config", "error", err)
+		return
+	}
+	if err := c.Reload(ctx, cfg); err != nil {
+		logger().Error("Error reloading config", "error", err)
+		return
+	}
+	logger().Info("Reloaded configuration", "path", opts.Path)
+}
+
+// notifyServiceManager sends state to the service manager that started the
+// monitor, if any
+func notifyServiceManager(state string) {
+	if _, err := daemon.Notify(state); err != nil {
+		logger().Error("Error notifying the service manager", "error", err)
+	}
+}
+
+// logger returns the logger of the command, which follows the default
+// logger configured from the flags
+func logger() *slog.Logger {
+	return logging.Component(nil, "main")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// newMigrateCommand creates the migrate subcommand, which shows or changes
+// the schema version of the database. The monitor applies pending migrations
+// itself when it starts, so this is mostly needed to revert them before
+// downgrading:
+//
+//	dropbox-monitor migrate status
+//	dropbox-monitor migrate up [--to 2]
+//	dropbox-monitor migrate down [--to 1]
+func newMigrateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Show or change the database schema version",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "List the migrations and when they were applied",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMigrate(cmd.Context(), "status", -1)
+		},
+	})
+	for _, action := range []string{"up", "down"} {
+		var to int
+		sub := &cobra.Command{
+			Use:   action,
+			Short: "Migrate the database " + action,
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runMigrate(cmd.Context(), action, to)
+			},
+		}
+		sub.Flags().IntVar(&to, "to", -1, "Schema version to migrate to (defaults to the latest on up and the previous on down)")
+		cmd.AddCommand(sub)
+	}
+	return cmd
+}
+
+// runMigrate applies action to the database, migrating to version to when
+// it is not negative, and lists the migrations
+func runMigrate(ctx context.Context, action string, to int) error {
+	cfg, logFile, err := loadConfig(loadOptions())
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	database, err := db.OpenWithoutMigrations(cfg.Database.GetDriver(), cfg.Database.GetDSN())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	applied, err := database.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "up":
+		if to < 0 {
+			err = database.Migrate(ctx)
+		} else {
+			err = database.MigrateTo(ctx, to)
+		}
+	case "down":
+		target := to
+		if target < 0 {
+			target = 0
+			if len(applied) > 1 {
+				target = applied[len(applied)-2].Version
+			}
+		}
+		err = database.MigrateTo(ctx, target)
+	}
+	if err != nil {
+		return err
+	}
+	return printMigrations(ctx, database)
+}
+
+// printMigrations lists every known migration and when it was applied
+func printMigrations(ctx context.Context, database *db.DB) error {
+	migrations, err := database.Migrations()
+	if err != nil {
+		return err
+	}
+	applied, err := database.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	appliedAt := make(map[int]string)
+	for _, m := range applied {
+		appliedAt[m.Version] = m.AppliedAt.Local().Format("2006-01-02 15:04")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, m := range migrations {
+		at, ok := appliedAt[m.Version]
+		if !ok {
+			at = "pending"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", m.Version, m.Name, at)
+	}
+	return w.Flush()
+}
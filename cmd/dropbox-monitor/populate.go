@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/container"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/core"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// newPopulateCommand creates the populate subcommand, which seeds the file
+// inventory with the first files in the Dropbox root
+func newPopulateCommand() *cobra.Command {
+	var limit int
+	cmd := &cobra.Command{
+		Use:   "populate",
+		Short: "Record the first files in the Dropbox root in the file inventory",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, logFile, err := loadConfig(loadOptions())
+			if err != nil {
+				return err
+			}
+			defer logFile.Close()
+
+			ctx := cmd.Context()
+			state := core.NewStateManager(cfg.State.Path)
+			if err := state.Start(ctx); err != nil {
+				return fmt.Errorf("failed to load state: %w", err)
+			}
+			defer state.Stop(ctx)
+
+			// Create the Dropbox client, preferring the refresh-token flow
+			// when configured
+			client, err := container.NewDropboxClient(cfg, state)
+			if err != nil {
+				return fmt.Errorf("failed to create Dropbox client: %w", err)
+			}
+
+			logger().Info("Listing files from Dropbox", "limit", limit)
+			files, err := client.ListFolder(ctx, "")
+			if err != nil {
+				return fmt.Errorf("failed to list files: %w", err)
+			}
+
+			// Open the database, which brings its schema up to date
+			database, err := db.Open(cfg.Database.GetDriver(), cfg.Database.GetDSN())
+			if err != nil {
+				return fmt.Errorf("failed to open database: %w", err)
+			}
+			defer database.Close()
+
+			now := time.Now()
+			var inventory []db.InventoryFile
+			for _, file := range files {
+				if len(inventory) >= limit {
+					break
+				}
+				inventory = append(inventory, db.NewInventoryFileFromModel(*file, now))
+			}
+			if err := database.SaveInventory(ctx, inventory); err != nil {
+				return fmt.Errorf("failed to save files: %w", err)
+			}
+
+			logger().Info("Populated files from Dropbox", "files", len(inventory))
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&limit, "limit", 10, "Number of files to record")
+	return cmd
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// newPruneCommand creates the prune subcommand, which removes the history
+// past the configured retention right away instead of waiting for the
+// scheduled job. With --dry-run it only counts what would be removed:
+//
+//	dropbox-monitor prune [--dry-run]
+func newPruneCommand() *cobra.Command {
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove the history past the configured retention",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPrune(cmd.Context(), dryRun)
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Count the rows that would be removed without removing them")
+	return cmd
+}
+
+// runPrune prunes the history, or only counts what would go when dryRun
+func runPrune(ctx context.Context, dryRun bool) error {
+	cfg, logFile, err := loadConfig(loadOptions())
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+	policy := cfg.Retention.ToRetentionPolicy()
+	if !policy.Enabled() {
+		return fmt.Errorf("no retention is configured; set the days to keep under retention")
+	}
+
+	database, err := db.Open(cfg.Database.GetDriver(), cfg.Database.GetDSN())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	result, err := database.Prune(ctx, policy, time.Now(), dryRun)
+	if err != nil {
+		return err
+	}
+	return printPruneResult(result, dryRun)
+}
+
+// printPruneResult lists the rows removed from each table
+func printPruneResult(result db.PruneResult, dryRun bool) error {
+	heading := "REMOVED"
+	if dryRun {
+		heading = "WOULD REMOVE"
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "TABLE\t%s\n", heading)
+	fmt.Fprintf(w, "file_changes\t%d\n", result.FileChanges)
+	fmt.Fprintf(w, "file_contents\t%d\n", result.FileContents)
+	fmt.Fprintf(w, "team_events\t%d\n", result.TeamEvents)
+	fmt.Fprintf(w, "folder_usage\t%d\n", result.FolderUsage)
+	return w.Flush()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/notify"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/reporting"
+	"github.com/spf13/cobra"
+)
+
+// newReportsCommand creates the reports subcommand, which lists the archived
+// reports newest first, and re-sends one of them through the configured
+// notification channels:
+//
+//	dropbox-monitor reports [--limit 20]
+//	dropbox-monitor reports resend ID
+func newReportsCommand() *cobra.Command {
+	var limit int
+	cmd := &cobra.Command{
+		Use:   "reports",
+		Short: "List the reports sent, with their delivery status",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runReports(cmd.Context(), limit)
+		},
+	}
+	cmd.Flags().IntVar(&limit, "limit", 20, "Number of reports listed")
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "resend ID",
+		Short: "Send an archived report again",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil || id <= 0 {
+				return fmt.Errorf("invalid report id %q", args[0])
+			}
+			return runResendReport(cmd.Context(), id)
+		},
+	})
+	return cmd
+}
+
+// runReports lists up to limit archived reports
+func runReports(ctx context.Context, limit int) error {
+	if limit <= 0 {
+		return fmt.Errorf("limit must be positive")
+	}
+
+	cfg, logFile, err := loadConfig(loadOptions())
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	database, err := db.Open(cfg.Database.GetDriver(), cfg.Database.GetDSN())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	reports, err := database.ListReports(ctx, limit)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tGENERATED\tTYPE\tCHANGES\tSTATUS\tATTEMPTS\tRECIPIENTS")
+	for _, report := range reports {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%d\t%s\n", report.ID,
+			report.GeneratedAt.Local().Format("2006-01-02 15:04"), report.Type, report.Changes,
+			report.Status, report.Attempts, strings.Join(report.Recipients, ", "))
+	}
+	return w.Flush()
+}
+
+// runResendReport sends the archived report with the given ID again
+func runResendReport(ctx context.Context, id int64) error {
+	cfg, logFile, err := loadConfig(loadOptions())
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	database, err := db.Open(cfg.Database.GetDriver(), cfg.Database.GetDSN())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	// Record email deliveries like the monitor does, so recipients that
+	// keep bouncing stay disabled
+	var bounces *notify.Bounces
+	if cfg.EmailConfig != nil {
+		bounces = notify.NewBounces(database, cfg.EmailConfig.Bounces.MaxBounces)
+	}
+	if err := reporting.Resend(ctx, notify.NewMultiNotifierFromConfig(cfg, bounces), database, id); err != nil {
+		return err
+	}
+	fmt.Printf("Report %d sent\n", id)
+	return nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/container"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/spf13/cobra"
+)
+
+// monitorFlags are the flags of the subcommands that run the monitor
+type monitorFlags struct {
+	pollInterval time.Duration
+	watchConfig  bool
+	pidFile      string
+}
+
+// register adds the flags to cmd
+func (f *monitorFlags) register(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&f.pollInterval, "poll-interval", 0, "Poll interval, overriding the config file and $SCHEDULER_INTERVAL")
+	cmd.Flags().BoolVar(&f.watchConfig, "watch-config", true, "Reload the configuration when the config file changes")
+	cmd.Flags().StringVar(&f.pidFile, "pid-file", "", "Write the process ID to this file while running, refusing to start if another instance holds it")
+}
+
+// loadOptions returns the config load options with the flags applied
+func (f *monitorFlags) loadOptions() config.LoadOptions {
+	return loadOptions(func(cfg *config.Config) {
+		if f.pollInterval > 0 {
+			cfg.PollInterval = f.pollInterval
+		}
+	})
+}
+
+func newRunCommand() *cobra.Command {
+	var flags monitorFlags
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Monitor the configured folders and send reports",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			loadOpts := flags.loadOptions()
+			cfg, logFile, err := loadConfig(loadOpts)
+			if err != nil {
+				return err
+			}
+			defer logFile.Close()
+
+			c, err := container.NewContainer(cfg)
+			if err != nil {
+				return fmt.Errorf("failed to create container: %w", err)
+			}
+			return serve(cfg, c, loadOpts, serveOptions{watchConfig: flags.watchConfig, pidFile: flags.pidFile})
+		},
+	}
+	flags.register(cmd)
+	return cmd
+}
+
+// newCheckNowCommand creates the check-now subcommand, which runs the
+// monitoring pipeline once and prints the report of what changed:
+//
+//	dropbox-monitor check-now --type markdown --output changes.md --send
+func newCheckNowCommand() *cobra.Command {
+	var (
+		reportType string
+		output     string
+		send       bool
+	)
+	cmd := &cobra.Command{
+		Use:   "check-now",
+		Short: "Check every monitored folder for changes once and report them",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, logFile, err := loadConfig(loadOptions())
+			if err != nil {
+				return err
+			}
+			defer logFile.Close()
+
+			c, err := container.NewContainer(cfg)
+			if err != nil {
+				return fmt.Errorf("failed to create container: %w", err)
+			}
+
+			// Without --send only the folder cursors are needed, so no agent
+			// polls and publishes changes while the check runs
+			ctx := cmd.Context()
+			start := c.StartState
+			if send {
+				start = c.StartAgents
+			}
+			if err := start(ctx); err != nil {
+				return fmt.Errorf("failed to start container: %w", err)
+			}
+			report, checkErr := c.CheckNow(ctx, container.CheckOptions{
+				ReportType: models.ReportType(reportType),
+				Send:       send,
+			})
+
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+			defer cancel()
+			if err := c.Stop(shutdownCtx); err != nil {
+				return fmt.Errorf("failed to stop container: %w", err)
+			}
+			if checkErr != nil {
+				return checkErr
+			}
+			return writeReport(cmd.OutOrStdout(), output, report)
+		},
+	}
+	cmd.Flags().StringVar(&reportType, "type", string(models.FileListReport), "Report type (file_list, html, narrative, json, csv, markdown)")
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "File to write the report to (\"-\" for stdout)")
+	cmd.Flags().BoolVar(&send, "send", false, "Send the report, and email the monitored folders' recipients, through the configured notifiers")
+	return cmd
+}
+
+func newReportCommand() *cobra.Command {
+	var reportType string
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Print the next report without sending it",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, logFile, err := loadConfig(loadOptions())
+			if err != nil {
+				return err
+			}
+			defer logFile.Close()
+
+			c, err := container.NewContainer(cfg)
+			if err != nil {
+				return fmt.Errorf("failed to create container: %w", err)
+			}
+			report, err := c.PreviewReport(cmd.Context(), models.ReportType(reportType))
+			if err != nil {
+				return fmt.Errorf("failed to preview report: %w", err)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), report.Metadata["content"])
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&reportType, "type", string(models.FileListReport), "Report type (file_list, html, narrative, json, csv, markdown)")
+	return cmd
+}
+
+// writeReport writes the content of report to path, or to stdout for "-"
+func writeReport(stdout io.Writer, path string, report *models.Report) error {
+	content := report.Metadata["content"]
+	if path == "-" {
+		_, err := fmt.Fprintln(stdout, content)
+		return err
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// newSearchCommand creates the search subcommand, which lists the files
+// whose stored text contains every given word, best match first:
+//
+//	dropbox-monitor search [--limit 20] travel budget
+func newSearchCommand() *cobra.Command {
+	var limit int
+	cmd := &cobra.Command{
+		Use:   "search WORDS...",
+		Short: "List the files whose stored text contains every word",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := strings.Join(args, " ")
+			if strings.TrimSpace(query) == "" {
+				return fmt.Errorf("no words to search for")
+			}
+			return runSearch(cmd.Context(), query, limit)
+		},
+	}
+	cmd.Flags().IntVar(&limit, "limit", db.DefaultSearchResults, "Maximum number of files to list")
+	return cmd
+}
+
+// runSearch prints the files whose stored text matches query
+func runSearch(ctx context.Context, query string, limit int) error {
+	cfg, logFile, err := loadConfig(loadOptions())
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	database, err := db.Open(cfg.Database.GetDriver(), cfg.Database.GetDSN())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	matches, err := database.Search(ctx, query, limit)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		fmt.Printf("No stored text mentions %q\n", query)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tUPDATED\tSNIPPET")
+	for _, match := range matches {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", match.Path, match.UpdatedAt.Local().Format("2006-01-02 15:04"),
+			strings.Join(strings.Fields(match.Snippet), " "))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+// newInstallServiceCommand creates the install-service subcommand, which
+// writes the systemd unit or launchd plist that runs the monitor unattended:
+//
+//	dropbox-monitor install-service --config /etc/dropbox-monitor/config.yaml \
+//	    --user monitor --output /etc/systemd/system/dropbox-monitor.service
+//
+// The service runs the same binary with the same --config, --env and logging
+// flags, made absolute.
+func newInstallServiceCommand() *cobra.Command {
+	var (
+		serviceType string
+		command     string
+		name        string
+		user        string
+		pidFile     string
+		output      string
+	)
+	cmd := &cobra.Command{
+		Use:   "install-service",
+		Short: "Write a systemd unit or launchd plist that runs the monitor as a service",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if command != "run" && command != "web" {
+				return fmt.Errorf("the service can only run the run or web command, not %q", command)
+			}
+
+			executable, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("failed to find the dropbox-monitor binary: %w", err)
+			}
+			if executable, err = filepath.EvalSymlinks(executable); err != nil {
+				return fmt.Errorf("failed to find the dropbox-monitor binary: %w", err)
+			}
+			configPath, err := filepath.Abs(globals.configPath)
+			if err != nil {
+				return err
+			}
+
+			serviceArgs := []string{command, "--config", configPath}
+			if globals.envFile != "" {
+				envFile, err := filepath.Abs(globals.envFile)
+				if err != nil {
+					return err
+				}
+				serviceArgs = append(serviceArgs, "--env", envFile)
+			}
+			if globals.logLevel != "" {
+				serviceArgs = append(serviceArgs, "--log-level", globals.logLevel)
+			}
+			if globals.logFormat != "" {
+				serviceArgs = append(serviceArgs, "--log-format", globals.logFormat)
+			}
+			if globals.logFile != "" {
+				logFile, err := filepath.Abs(globals.logFile)
+				if err != nil {
+					return err
+				}
+				serviceArgs = append(serviceArgs, "--log-file", logFile)
+			}
+			if pidFile != "" {
+				serviceArgs = append(serviceArgs, "--pid-file", pidFile)
+			}
+
+			opts := daemon.ServiceOptions{
+				Name:       name,
+				Executable: executable,
+				Args:       serviceArgs,
+				WorkingDir: filepath.Dir(configPath),
+				User:       user,
+			}
+			if serviceType == daemon.Launchd {
+				opts.Name = "com.github.christiaanpauw." + name
+				opts.LogFile = filepath.Join(filepath.Dir(configPath), name+".log")
+			}
+			service, err := daemon.RenderService(serviceType, opts)
+			if err != nil {
+				return err
+			}
+
+			if output == "-" {
+				_, err := fmt.Fprint(cmd.OutOrStdout(), service)
+				return err
+			}
+			if err := os.WriteFile(output, []byte(service), 0o644); err != nil {
+				return fmt.Errorf("failed to write service file: %w", err)
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Wrote %s\n", output)
+			if serviceType == daemon.Systemd {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Enable it with: systemctl daemon-reload && systemctl enable --now %s\n", filepath.Base(output))
+			} else {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Load it with: launchctl load -w %s\n", output)
+			}
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&serviceType, "type", daemon.DefaultServiceType(), "Service manager: systemd or launchd")
+	flags.StringVar(&command, "command", "run", "Command the service runs: run or web")
+	flags.StringVar(&name, "name", "dropbox-monitor", "Service name")
+	flags.StringVar(&user, "user", "", "User the service runs as")
+	flags.StringVar(&pidFile, "pid-file", "", "PID file the service writes while running")
+	flags.StringVarP(&output, "output", "o", "-", "File to write the service to (\"-\" for stdout)")
+	return cmd
+}
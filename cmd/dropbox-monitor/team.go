@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/container"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/core"
+	"github.com/spf13/cobra"
+)
+
+// newTeamCommand creates the team subcommand, which lists the team folders
+// or namespaces of a Dropbox Business team to help fill in the team section
+// of the configuration:
+//
+//	dropbox-monitor team folders
+//	dropbox-monitor team namespaces
+func newTeamCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "team",
+		Short: "List the team folders or namespaces of a Dropbox Business team",
+	}
+	for _, action := range []string{"folders", "namespaces"} {
+		cmd.AddCommand(&cobra.Command{
+			Use:   action,
+			Short: "List the team " + action,
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runTeam(cmd.Context(), action)
+			},
+		})
+	}
+	return cmd
+}
+
+// runTeam lists the team folders or namespaces, as action says
+func runTeam(ctx context.Context, action string) error {
+	cfg, logFile, err := loadConfig(loadOptions())
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	state := core.NewStateManager(cfg.State.Path)
+	if err := state.Start(ctx); err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	defer state.Stop(ctx)
+
+	client, err := container.NewDropboxClient(cfg, state)
+	if err != nil {
+		return fmt.Errorf("failed to create dropbox client: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if action == "folders" {
+		folders, err := client.ListTeamFolders(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "ID\tNAME\tSTATUS\tTEAM SPACE")
+		for _, folder := range folders {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%t\n", folder.ID, folder.Name, folder.Status, folder.TeamSharedDropbox)
+		}
+		return w.Flush()
+	}
+
+	namespaces, err := client.ListNamespaces(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "ID\tNAME\tTYPE\tMEMBER")
+	for _, namespace := range namespaces {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", namespace.ID, namespace.Name, namespace.Type, namespace.MemberID)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/container"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/web"
+	"github.com/spf13/cobra"
+)
+
+func newWebCommand() *cobra.Command {
+	var flags monitorFlags
+	cmd := &cobra.Command{
+		Use:   "web",
+		Short: "Monitor the configured folders with the web dashboard and API",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			loadOpts := flags.loadOptions()
+			cfg, logFile, err := loadConfig(loadOpts)
+			if err != nil {
+				return err
+			}
+			defer logFile.Close()
+
+			c, err := container.NewContainer(cfg)
+			if err != nil {
+				return fmt.Errorf("failed to create container: %w", err)
+			}
+			server := web.NewServer(c)
+
+			return serve(cfg, c, loadOpts, serveOptions{
+				watchConfig: flags.watchConfig,
+				pidFile:     flags.pidFile,
+				start:       server.Start,
+				stop:        server.Stop,
+			})
+		},
+	}
+	flags.register(cmd)
+	return cmd
+}
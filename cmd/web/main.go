@@ -1,75 +0,0 @@
-package main
-
-import (
-	"context"
-	"flag"
-	"log"
-	"os"
-	"os/signal"
-	"syscall"
-	"time"
-
-	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
-	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/container"
-	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/web"
-)
-
-func main() {
-	// Parse command line flags
-	configFile := flag.String("config", "config.yaml", "Path to configuration file")
-	flag.Parse()
-
-	// Load configuration
-	cfg, err := config.LoadConfig(*configFile)
-	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
-	}
-
-	// Create DI container
-	container, err := container.NewContainer(cfg)
-	if err != nil {
-		log.Fatalf("Failed to create container: %v", err)
-	}
-
-	// Create web server
-	server := web.NewServer(container)
-
-	// Setup graceful shutdown
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Handle shutdown signals
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		sig := <-sigChan
-		log.Printf("Received signal %v, initiating shutdown", sig)
-		cancel()
-	}()
-
-	// Start container
-	if err := container.Start(ctx); err != nil {
-		log.Fatalf("Failed to start container: %v", err)
-	}
-
-	// Start web server
-	if err := server.Start(ctx); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
-	}
-
-	// Wait for shutdown signal
-	<-ctx.Done()
-
-	// Shutdown gracefully
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer shutdownCancel()
-
-	if err := server.Stop(shutdownCtx); err != nil {
-		log.Printf("Error stopping web server: %v", err)
-	}
-
-	if err := container.Stop(shutdownCtx); err != nil {
-		log.Printf("Error stopping container: %v", err)
-	}
-}
\ No newline at end of file
@@ -0,0 +1,307 @@
+// Package actions signs one-click links embedded in alert emails and applies
+// the actions they carry, such as acknowledging an alert or muting a folder.
+package actions
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/i18n"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/interfaces"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// Kind is what a one-click link does
+type Kind string
+
+const (
+	// Acknowledge records that someone has seen a folder's alert; changes
+	// made before then are not reported again
+	Acknowledge Kind = "ack"
+	// MuteFolder silences a folder's alerts for the mute duration
+	MuteFolder Kind = "mute"
+)
+
+const (
+	// DefaultMuteDuration is how long a mute link silences a folder when none is configured
+	DefaultMuteDuration = 24 * time.Hour
+	// DefaultLinkTTL is how long links stay valid when none is configured
+	DefaultLinkTTL = 7 * 24 * time.Hour
+
+	// mutesStateKey is the state key holding when each muted folder is unmuted
+	mutesStateKey = "action_mutes"
+	// acksStateKey is the state key holding when each folder was last acknowledged
+	acksStateKey = "action_acknowledgements"
+
+	// minSecretLength is the shortest accepted signing secret
+	minSecretLength = 16
+)
+
+var (
+	// ErrInvalidToken is returned for tokens that are malformed or carry a bad signature
+	ErrInvalidToken = errors.New("invalid action token")
+	// ErrExpiredToken is returned for correctly signed tokens past their expiry
+	ErrExpiredToken = errors.New("action token has expired")
+)
+
+// Claims are the signed contents of an action token
+type Claims struct {
+	Kind    Kind   `json:"k"`
+	Folder  string `json:"f"`
+	Expires int64  `json:"e"`
+}
+
+// Link is a one-click action link for an email
+type Link struct {
+	Label string
+	URL   string
+}
+
+// Config holds the action link settings
+type Config struct {
+	// Secret signs the links
+	Secret string
+	// BaseURL is the public address of the web server handling the links
+	BaseURL string
+	// MuteDuration is how long a mute link silences a folder
+	MuteDuration time.Duration
+	// LinkTTL is how long links stay valid after they are sent
+	LinkTTL time.Duration
+}
+
+// Service signs action links and keeps the mute and acknowledgement state
+type Service struct {
+	secret       []byte
+	base         *url.URL
+	muteDuration time.Duration
+	linkTTL      time.Duration
+	state        interfaces.StateManager
+	mu           sync.Mutex
+	now          func() time.Time
+}
+
+// NewService creates a service storing its state in state; zero durations
+// fall back to the defaults
+func NewService(cfg Config, state interfaces.StateManager) (*Service, error) {
+	if len(cfg.Secret) < minSecretLength {
+		return nil, fmt.Errorf("action secret must be at least %d characters", minSecretLength)
+	}
+	if state == nil {
+		return nil, fmt.Errorf("state manager cannot be nil")
+	}
+	base, err := url.Parse(cfg.BaseURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("action base URL %q must be an absolute http or https URL", cfg.BaseURL)
+	}
+	if cfg.MuteDuration <= 0 {
+		cfg.MuteDuration = DefaultMuteDuration
+	}
+	if cfg.LinkTTL <= 0 {
+		cfg.LinkTTL = DefaultLinkTTL
+	}
+
+	return &Service{
+		secret:       []byte(cfg.Secret),
+		base:         base,
+		muteDuration: cfg.MuteDuration,
+		linkTTL:      cfg.LinkTTL,
+		state:        state,
+		now:          time.Now,
+	}, nil
+}
+
+// Sign returns a token for the action on folder, valid for the link TTL
+func (s *Service) Sign(kind Kind, folder string) string {
+	payload, _ := json.Marshal(Claims{
+		Kind:    kind,
+		Folder:  folder,
+		Expires: s.now().Add(s.linkTTL).Unix(),
+	})
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.mac(encoded))
+}
+
+// Verify checks the signature and expiry of a token and returns its claims
+func (s *Service) Verify(token string) (Claims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return Claims{}, ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.mac(encoded)) {
+		return Claims{}, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	if claims.Kind != Acknowledge && claims.Kind != MuteFolder {
+		return Claims{}, ErrInvalidToken
+	}
+	if s.now().Unix() >= claims.Expires {
+		return Claims{}, ErrExpiredToken
+	}
+	return claims, nil
+}
+
+// mac returns the HMAC-SHA256 of an encoded payload
+func (s *Service) mac(encoded string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(encoded))
+	return h.Sum(nil)
+}
+
+// Links returns the acknowledge and mute links for an alert about folder
+func (s *Service) Links(folder string) []Link {
+	return []Link{
+		{Label: "Acknowledge", URL: s.url(Acknowledge, folder)},
+		{Label: fmt.Sprintf("Mute %s for %s", folder, formatDuration(s.muteDuration)), URL: s.url(MuteFolder, folder)},
+	}
+}
+
+// Describe describes the action claims carry in the language of catalog,
+// e.g. "Mute alerts for /docs for 24h"; a nil catalog is English
+func (s *Service) Describe(claims Claims, catalog *i18n.Catalog) string {
+	if claims.Kind == MuteFolder {
+		return catalog.T("action.mute", claims.Folder, formatDuration(s.muteDuration))
+	}
+	return catalog.T("action.acknowledge", claims.Folder)
+}
+
+// url returns the link for an action on folder
+func (s *Service) url(kind Kind, folder string) string {
+	u := *s.base
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/actions"
+	u.RawQuery = url.Values{"token": {s.Sign(kind, folder)}}.Encode()
+	return u.String()
+}
+
+// Apply verifies a token and carries out its action, returning its claims
+func (s *Service) Apply(token string) (Claims, error) {
+	claims, err := s.Verify(token)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	switch claims.Kind {
+	case MuteFolder:
+		err = s.record(mutesStateKey, claims.Folder, s.now().Add(s.muteDuration))
+	default:
+		err = s.record(acksStateKey, claims.Folder, s.now())
+	}
+	if err != nil {
+		return Claims{}, err
+	}
+	return claims, nil
+}
+
+// Muted reports whether alerts about folder are muted
+func (s *Service) Muted(folder string) bool {
+	until, ok := s.MutedUntil(folder)
+	return ok && s.now().Before(until)
+}
+
+// MutedUntil returns when the mute on folder ends, if it was ever muted
+func (s *Service) MutedUntil(folder string) (time.Time, bool) {
+	return s.lookup(mutesStateKey, folder)
+}
+
+// AcknowledgedAt returns when the alert about folder was last acknowledged
+func (s *Service) AcknowledgedAt(folder string) (time.Time, bool) {
+	return s.lookup(acksStateKey, folder)
+}
+
+// Unacknowledged returns the changes to folder made after its alert was
+// last acknowledged. Changes without a modification time are kept.
+func (s *Service) Unacknowledged(folder string, changes []models.FileChange) []models.FileChange {
+	acked, ok := s.AcknowledgedAt(folder)
+	if !ok {
+		return changes
+	}
+	var result []models.FileChange
+	for _, change := range changes {
+		modified := change.Modified
+		if modified.IsZero() {
+			modified = change.ModTime
+		}
+		if modified.IsZero() || modified.After(acked) {
+			result = append(result, change)
+		}
+	}
+	return result
+}
+
+// MuteDuration returns how long a mute link silences a folder
+func (s *Service) MuteDuration() time.Duration {
+	return s.muteDuration
+}
+
+// lookup returns the time stored for folder under key
+func (s *Service) lookup(key, folder string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	at, ok := s.load(key)[folderKey(folder)]
+	return at, ok
+}
+
+// record stores a time for folder under key
+func (s *Service) record(key, folder string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	times := s.load(key)
+	times[folderKey(folder)] = at
+	data, err := json.Marshal(times)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", key, err)
+	}
+	if err := s.state.SetString(key, string(data)); err != nil {
+		return fmt.Errorf("failed to save %s: %w", key, err)
+	}
+	return nil
+}
+
+// load decodes the folder times stored under key; s.mu must be held
+func (s *Service) load(key string) map[string]time.Time {
+	times := make(map[string]time.Time)
+	if value := s.state.GetString(key); value != "" {
+		if err := json.Unmarshal([]byte(value), &times); err != nil {
+			return make(map[string]time.Time)
+		}
+	}
+	return times
+}
+
+// folderKey normalises a folder path for lookups
+func folderKey(folder string) string {
+	key := strings.TrimSuffix(strings.ToLower(folder), "/")
+	if key == "" {
+		return "/"
+	}
+	return key
+}
+
+// formatDuration formats whole hours and days compactly, e.g. "24h" or "7d"
+func formatDuration(d time.Duration) string {
+	if d >= 48*time.Hour && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", d/time.Hour)
+	}
+	return d.String()
+}
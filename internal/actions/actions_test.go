@@ -0,0 +1,143 @@
+package actions
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/i18n"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryState is an in-memory StateManager
+type memoryState map[string]string
+
+func (m memoryState) GetString(key string) string {
+	return m[key]
+}
+
+func (m memoryState) SetString(key, value string) error {
+	m[key] = value
+	return nil
+}
+
+func newTestService(t *testing.T, now *time.Time) *Service {
+	service, err := NewService(Config{
+		Secret:  "0123456789abcdef",
+		BaseURL: "https://monitor.example.com/ops/",
+	}, memoryState{})
+	require.NoError(t, err)
+	service.now = func() time.Time { return *now }
+	return service
+}
+
+func TestNewService_Validation(t *testing.T) {
+	_, err := NewService(Config{Secret: "short", BaseURL: "https://example.com"}, memoryState{})
+	assert.Error(t, err)
+
+	_, err = NewService(Config{Secret: "0123456789abcdef", BaseURL: "/relative"}, memoryState{})
+	assert.Error(t, err)
+
+	_, err = NewService(Config{Secret: "0123456789abcdef", BaseURL: "https://example.com"}, nil)
+	assert.Error(t, err)
+
+	service, err := NewService(Config{Secret: "0123456789abcdef", BaseURL: "https://example.com"}, memoryState{})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultMuteDuration, service.MuteDuration())
+}
+
+func TestService_SignAndVerify(t *testing.T) {
+	now := time.Now()
+	service := newTestService(t, &now)
+
+	token := service.Sign(MuteFolder, "/Docs")
+	claims, err := service.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, MuteFolder, claims.Kind)
+	assert.Equal(t, "/Docs", claims.Folder)
+
+	// A token signed with another secret is rejected
+	other, err := NewService(Config{Secret: "fedcba9876543210", BaseURL: "https://example.com"}, memoryState{})
+	require.NoError(t, err)
+	_, err = other.Verify(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// Changing the payload breaks the signature
+	payload, signature, _ := strings.Cut(token, ".")
+	_, err = service.Verify(payload + "x." + signature)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = service.Verify("not-a-token")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	now = now.Add(DefaultLinkTTL)
+	_, err = service.Verify(token)
+	assert.ErrorIs(t, err, ErrExpiredToken)
+}
+
+func TestService_ApplyMuteAndAcknowledge(t *testing.T) {
+	now := time.Now()
+	service := newTestService(t, &now)
+
+	assert.False(t, service.Muted("/docs"))
+	_, err := service.Apply(service.Sign(MuteFolder, "/Docs/"))
+	require.NoError(t, err)
+	assert.True(t, service.Muted("/docs"))
+	assert.False(t, service.Muted("/photos"))
+
+	_, err = service.Apply(service.Sign(Acknowledge, "/photos"))
+	require.NoError(t, err)
+	at, ok := service.AcknowledgedAt("/Photos")
+	require.True(t, ok)
+	assert.True(t, at.Equal(now))
+
+	now = now.Add(DefaultMuteDuration)
+	assert.False(t, service.Muted("/docs"))
+}
+
+func TestService_Unacknowledged(t *testing.T) {
+	now := time.Now()
+	service := newTestService(t, &now)
+
+	changes := []models.FileChange{
+		{Path: "/photos/old.jpg", Modified: now.Add(-time.Hour)},
+		{Path: "/photos/new.jpg", Modified: now.Add(time.Minute)},
+		{Path: "/photos/gone.jpg", IsDeleted: true},
+	}
+	assert.Equal(t, changes, service.Unacknowledged("/photos", changes))
+
+	_, err := service.Apply(service.Sign(Acknowledge, "/photos"))
+	require.NoError(t, err)
+
+	// Changes made before the acknowledgement are not reported again
+	remaining := service.Unacknowledged("/Photos/", changes)
+	require.Len(t, remaining, 2)
+	assert.Equal(t, "/photos/new.jpg", remaining[0].Path)
+	assert.Equal(t, "/photos/gone.jpg", remaining[1].Path)
+	assert.Empty(t, service.Unacknowledged("/photos", changes[:1]))
+	assert.Equal(t, changes, service.Unacknowledged("/docs", changes))
+}
+
+func TestService_Links(t *testing.T) {
+	now := time.Now()
+	service := newTestService(t, &now)
+
+	links := service.Links("/docs")
+	require.Len(t, links, 2)
+	assert.Equal(t, "Acknowledge", links[0].Label)
+	assert.Equal(t, "Mute /docs for 24h", links[1].Label)
+
+	u, err := url.Parse(links[1].URL)
+	require.NoError(t, err)
+	assert.Equal(t, "monitor.example.com", u.Host)
+	assert.Equal(t, "/ops/actions", u.Path)
+
+	claims, err := service.Verify(u.Query().Get("token"))
+	require.NoError(t, err)
+	assert.Equal(t, MuteFolder, claims.Kind)
+	assert.Equal(t, "Mute alerts for /docs for 24h", service.Describe(claims, nil))
+	assert.Equal(t, "Meldingen voor /docs dempen voor 24h", service.Describe(claims, i18n.Lookup("nl")))
+}
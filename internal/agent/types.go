@@ -19,6 +19,7 @@ type FileChangeAgent interface {
 	GetChanges(ctx context.Context) ([]models.FileChange, error)
 	GetFileContent(ctx context.Context, path string) ([]byte, error)
 	SetPollInterval(interval time.Duration)
+	TriggerCheck(ctx context.Context) error
 }
 
 // DatabaseAgent handles database operations
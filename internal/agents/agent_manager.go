@@ -2,53 +2,99 @@ package agents
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
+	"time"
 
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/agent"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/analysis"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/notify"
 )
 
 // AgentManagerDeps holds dependencies for the agent manager
 type AgentManagerDeps struct {
 	FileChangeAgent  agent.FileChangeAgent
+	// FileChangeAgents, if set, are all managed, one per monitored folder;
+	// FileChangeAgent should be the first of them
+	FileChangeAgents []agent.FileChangeAgent
 	ContentAnalyzer  analysis.ContentAnalyzer
 	DatabaseAgent    agent.DatabaseAgent
 	ReportingAgent   agent.ReportingAgent
 	Notifier         notify.Notifier
+	// Logger, if set, is the logger of the agent manager
+	Logger *slog.Logger
 }
 
-// AgentManagerConfig holds configuration for the agent manager
-type AgentManagerConfig struct {
+// RetryPolicy is how the agent manager retries an agent that fails to
+// start. Only agents still in the initialized state are retried, as others
+// cannot be started again.
+type RetryPolicy struct {
+	// MaxAttempts is how many times an agent is started; zero or one
+	// means no retries
+	MaxAttempts int
+	// Delay is how long the manager waits between attempts
+	Delay time.Duration
+}
+
+// Names of the agents in the manager's component graph
+const (
+	databaseAgentName   = "database agent"
+	reportingAgentName  = "reporting agent"
+	fileChangeAgentName = "file change agent"
+)
+
+// AgentManagerOption configures an agent manager
+type AgentManagerOption func(*AgentManagerImpl)
+
+// WithPollInterval sets the poll interval of every file change agent when
+// the manager is initialized; without it each agent keeps its own
+func WithPollInterval(interval time.Duration) AgentManagerOption {
+	return func(am *AgentManagerImpl) {
+		am.pollInterval = interval
+	}
+}
+
+// WithRetryPolicy sets how agents that fail to start are retried; without
+// it each agent is started once
+func WithRetryPolicy(policy RetryPolicy) AgentManagerOption {
+	return func(am *AgentManagerImpl) {
+		am.retry = policy
+	}
 }
 
 // AgentManager defines the interface for agent coordination
 type AgentManager interface {
 	lifecycle.Component
-	Initialize(ctx context.Context) error
+	lifecycle.Initializer
 	GetFileChangeAgent() agent.FileChangeAgent
+	GetFileChangeAgents() []agent.FileChangeAgent
 }
 
 // AgentManagerImpl implements the AgentManager interface
 type AgentManagerImpl struct {
 	*lifecycle.BaseComponent
-	deps   AgentManagerDeps
-	config AgentManagerConfig
-	stopCh chan struct{}
-	mu     sync.RWMutex
+	deps         AgentManagerDeps
+	pollInterval time.Duration
+	retry        RetryPolicy
+	mu           sync.RWMutex
+	// graph orders the agents, built when the manager is initialized
+	graph *lifecycle.Graph
 }
 
-// NewAgentManager creates a new agent manager
-func NewAgentManager(deps AgentManagerDeps) AgentManager {
+// NewAgentManager creates a new agent manager, which must be initialized
+// before it is started
+func NewAgentManager(deps AgentManagerDeps, opts ...AgentManagerOption) AgentManager {
 	am := &AgentManagerImpl{
 		BaseComponent: lifecycle.NewBaseComponent("AgentManager"),
-		deps:         deps,
-		stopCh:       make(chan struct{}),
+		deps:          deps,
+	}
+	for _, opt := range opts {
+		opt(am)
 	}
-	am.SetState(lifecycle.StateInitialized)
 	return am
 }
 
@@ -58,11 +104,13 @@ func (am *AgentManagerImpl) Start(ctx context.Context) error {
 		return err
 	}
 
-	log.Printf("🚀 Starting AgentManager...")
+	am.logger().Info("Starting agents")
 
 	// Check that all agents are initialized
-	if am.deps.FileChangeAgent.State() != lifecycle.StateInitialized {
-		return fmt.Errorf("file change agent not initialized")
+	for _, fca := range am.fileChangeAgents() {
+		if fca.State() != lifecycle.StateInitialized {
+			return fmt.Errorf("file change agent not initialized")
+		}
 	}
 	if am.deps.DatabaseAgent.State() != lifecycle.StateInitialized {
 		return fmt.Errorf("database agent not initialized")
@@ -71,36 +119,24 @@ func (am *AgentManagerImpl) Start(ctx context.Context) error {
 		return fmt.Errorf("reporting agent not initialized")
 	}
 
-	// Start file change monitoring
-	if err := am.deps.FileChangeAgent.Start(ctx); err != nil {
-		am.SetState(lifecycle.StateFailed)
-		return fmt.Errorf("failed to start file change agent: %w", err)
-	}
-
-	// Start database agent
-	if err := am.deps.DatabaseAgent.Start(ctx); err != nil {
-		am.SetState(lifecycle.StateFailed)
-		return fmt.Errorf("failed to start database agent: %w", err)
-	}
-
-	// Start reporting agent
-	if err := am.deps.ReportingAgent.Start(ctx); err != nil {
+	// Start the agents after the agents they depend on, stopping those
+	// already started if one fails
+	if err := am.graph.Start(ctx); err != nil {
 		am.SetState(lifecycle.StateFailed)
-		return fmt.Errorf("failed to start reporting agent: %w", err)
+		return err
 	}
 
 	// Check that all agents are running
-	if am.deps.FileChangeAgent.State() != lifecycle.StateRunning {
-		am.SetState(lifecycle.StateFailed)
-		return fmt.Errorf("file change agent failed to start")
+	for _, fca := range am.fileChangeAgents() {
+		if fca.State() != lifecycle.StateRunning {
+			return am.rollback(ctx, fmt.Errorf("file change agent failed to start"))
+		}
 	}
 	if am.deps.DatabaseAgent.State() != lifecycle.StateRunning {
-		am.SetState(lifecycle.StateFailed)
-		return fmt.Errorf("database agent failed to start")
+		return am.rollback(ctx, fmt.Errorf("database agent failed to start"))
 	}
 	if am.deps.ReportingAgent.State() != lifecycle.StateRunning {
-		am.SetState(lifecycle.StateFailed)
-		return fmt.Errorf("reporting agent failed to start")
+		return am.rollback(ctx, fmt.Errorf("reporting agent failed to start"))
 	}
 
 	// Set state to running after all agents are started and verified
@@ -108,32 +144,28 @@ func (am *AgentManagerImpl) Start(ctx context.Context) error {
 	return nil
 }
 
-// Stop stops all agents
+// Stop stops all agents in the reverse of the order they were started.
+// Stopping a stopped manager has no effect, and agents that are no longer
+// running are skipped.
 func (am *AgentManagerImpl) Stop(ctx context.Context) error {
-	log.Printf("🛑 Stopping AgentManager...")
+	am.mu.Lock()
+	defer am.mu.Unlock()
 
-	if err := am.DefaultStop(ctx); err != nil {
-		return err
+	if am.State() == lifecycle.StateStopped {
+		return nil
 	}
 
-	am.SetState(lifecycle.StateStopping)
+	am.logger().Info("Stopping agents")
 
-	// Stop file change monitoring
-	if err := am.deps.FileChangeAgent.Stop(ctx); err != nil {
-		am.SetState(lifecycle.StateFailed)
-		return fmt.Errorf("failed to stop file change agent: %w", err)
+	if err := am.DefaultStop(ctx); err != nil {
+		return err
 	}
 
-	// Stop database agent
-	if err := am.deps.DatabaseAgent.Stop(ctx); err != nil {
-		am.SetState(lifecycle.StateFailed)
-		return fmt.Errorf("failed to stop database agent: %w", err)
-	}
+	am.SetState(lifecycle.StateStopping)
 
-	// Stop reporting agent
-	if err := am.deps.ReportingAgent.Stop(ctx); err != nil {
+	if err := am.graph.Stop(ctx); err != nil {
 		am.SetState(lifecycle.StateFailed)
-		return fmt.Errorf("failed to stop reporting agent: %w", err)
+		return err
 	}
 
 	am.SetState(lifecycle.StateStopped)
@@ -147,8 +179,10 @@ func (am *AgentManagerImpl) Health(ctx context.Context) error {
 	}
 
 	// Check that all agents are running
-	if am.deps.FileChangeAgent.State() != lifecycle.StateRunning {
-		return fmt.Errorf("file change agent not running")
+	for _, fca := range am.fileChangeAgents() {
+		if fca.State() != lifecycle.StateRunning {
+			return fmt.Errorf("file change agent not running")
+		}
 	}
 	if am.deps.DatabaseAgent.State() != lifecycle.StateRunning {
 		return fmt.Errorf("database agent not running")
@@ -158,8 +192,10 @@ func (am *AgentManagerImpl) Health(ctx context.Context) error {
 	}
 
 	// Check file change agent health
-	if err := am.deps.FileChangeAgent.Health(ctx); err != nil {
-		return fmt.Errorf("file change agent unhealthy: %w", err)
+	for _, fca := range am.fileChangeAgents() {
+		if err := fca.Health(ctx); err != nil {
+			return fmt.Errorf("file change agent unhealthy: %w", err)
+		}
 	}
 
 	// Check database agent health
@@ -175,7 +211,16 @@ func (am *AgentManagerImpl) Health(ctx context.Context) error {
 	return nil
 }
 
-// Initialize performs any necessary initialization before starting the agents
+// logger returns the logger of the agent manager
+func (am *AgentManagerImpl) logger() *slog.Logger {
+	if am.deps.Logger != nil {
+		return am.deps.Logger
+	}
+	return logging.Component(nil, "agents")
+}
+
+// Initialize validates the dependencies and applies the options to the
+// agents; it must be called before Start
 func (am *AgentManagerImpl) Initialize(ctx context.Context) error {
 	am.mu.Lock()
 	defer am.mu.Unlock()
@@ -191,6 +236,29 @@ func (am *AgentManagerImpl) Initialize(ctx context.Context) error {
 		return fmt.Errorf("ReportingAgent is required")
 	}
 
+	// The database must be healthy before the agents that store changes in
+	// it start, and changes are only detected once they can be reported
+	graph := lifecycle.NewGraph()
+	if err := graph.Add(databaseAgentName, am.retrying(am.deps.DatabaseAgent)); err != nil {
+		return err
+	}
+	if err := graph.Add(reportingAgentName, am.retrying(am.deps.ReportingAgent), databaseAgentName); err != nil {
+		return err
+	}
+	for i, fca := range am.fileChangeAgents() {
+		name := fmt.Sprintf("%s %d", fileChangeAgentName, i+1)
+		if err := graph.Add(name, am.retrying(fca), databaseAgentName, reportingAgentName); err != nil {
+			return err
+		}
+	}
+	am.graph = graph
+
+	if am.pollInterval > 0 {
+		for _, fca := range am.fileChangeAgents() {
+			fca.SetPollInterval(am.pollInterval)
+		}
+	}
+
 	// Set state to initialized after validation
 	am.SetState(lifecycle.StateInitialized)
 	return nil
@@ -202,3 +270,62 @@ func (am *AgentManagerImpl) GetFileChangeAgent() agent.FileChangeAgent {
 	defer am.mu.RUnlock()
 	return am.deps.FileChangeAgent
 }
+
+// GetFileChangeAgents returns the file change agents for every monitored folder
+func (am *AgentManagerImpl) GetFileChangeAgents() []agent.FileChangeAgent {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+	return am.fileChangeAgents()
+}
+
+// fileChangeAgents returns every managed file change agent
+func (am *AgentManagerImpl) fileChangeAgents() []agent.FileChangeAgent {
+	if len(am.deps.FileChangeAgents) > 0 {
+		return am.deps.FileChangeAgents
+	}
+	return []agent.FileChangeAgent{am.deps.FileChangeAgent}
+}
+
+// rollback stops the agents already started after the manager failed to
+// start, returning err
+func (am *AgentManagerImpl) rollback(ctx context.Context, err error) error {
+	am.SetState(lifecycle.StateFailed)
+	if stopErr := am.graph.Stop(ctx); stopErr != nil {
+		return errors.Join(err, fmt.Errorf("rollback failed: %w", stopErr))
+	}
+	return err
+}
+
+// retryingComponent starts its component under the manager's retry policy
+type retryingComponent struct {
+	lifecycle.Component
+	am *AgentManagerImpl
+}
+
+// Start implements lifecycle.Component
+func (c retryingComponent) Start(ctx context.Context) error {
+	return c.am.start(ctx, c.Component)
+}
+
+// retrying wraps component to be started under the manager's retry policy
+func (am *AgentManagerImpl) retrying(component lifecycle.Component) lifecycle.Component {
+	return retryingComponent{Component: component, am: am}
+}
+
+// start starts component, retrying under the manager's retry policy while
+// the component can still be started
+func (am *AgentManagerImpl) start(ctx context.Context, component lifecycle.Component) error {
+	for attempt := 1; ; attempt++ {
+		err := component.Start(ctx)
+		if err == nil || attempt >= am.retry.MaxAttempts || component.State() != lifecycle.StateInitialized {
+			return err
+		}
+
+		am.logger().Warn("Agent failed to start, retrying", "attempt", attempt, "error", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(am.retry.Delay):
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/agent"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
 	"github.com/stretchr/testify/assert"
@@ -45,6 +46,11 @@ func (m *mockFileChangeAgent) SetPollInterval(interval time.Duration) {
 	m.Called(interval)
 }
 
+func (m *mockFileChangeAgent) TriggerCheck(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
 func (m *mockFileChangeAgent) GetFileContent(ctx context.Context, path string) ([]byte, error) {
 	args := m.Called(ctx, path)
 	return args.Get(0).([]byte), args.Error(1)
@@ -123,6 +129,19 @@ func (m *mockReportingAgent) NotifyChanges(ctx context.Context, changes []models
 	return args.Error(0)
 }
 
+func (m *mockReportingAgent) PreviewReport(ctx context.Context, changes []models.FileChange, reportType models.ReportType) (*models.Report, error) {
+	args := m.Called(ctx, changes, reportType)
+	if report, ok := args.Get(0).(*models.Report); ok {
+		return report, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockReportingAgent) GenerateCustomReport(ctx context.Context, title string, changes []models.FileChange, reportType models.ReportType) error {
+	args := m.Called(ctx, title, changes, reportType)
+	return args.Error(0)
+}
+
 func TestAgentManager_Start(t *testing.T) {
 	// Create mocks
 	fileChangeAgent := new(mockFileChangeAgent)
@@ -151,6 +170,8 @@ func TestAgentManager_Start(t *testing.T) {
 	fileChangeAgent.On("Start", mock.Anything).Return(nil).Times(1)
 	databaseAgent.On("Start", mock.Anything).Return(nil).Times(1)
 	reportingAgent.On("Start", mock.Anything).Return(nil).Times(1)
+	databaseAgent.On("Health", mock.Anything).Return(nil).Once()
+	reportingAgent.On("Health", mock.Anything).Return(nil).Once()
 
 	fileChangeAgent.On("State").Return(lifecycle.StateRunning).Times(1)
 	databaseAgent.On("State").Return(lifecycle.StateRunning).Times(1)
@@ -166,6 +187,42 @@ func TestAgentManager_Start(t *testing.T) {
 	reportingAgent.AssertExpectations(t)
 }
 
+func TestAgentManager_StartMultipleFolders(t *testing.T) {
+	// One file change agent per monitored folder
+	folderAgents := []*mockFileChangeAgent{new(mockFileChangeAgent), new(mockFileChangeAgent)}
+	databaseAgent := new(mockDatabaseAgent)
+	reportingAgent := new(mockReportingAgent)
+
+	for _, fca := range folderAgents {
+		fca.On("State").Return(lifecycle.StateInitialized).Once()
+		fca.On("Start", mock.Anything).Return(nil).Once()
+		fca.On("State").Return(lifecycle.StateRunning).Once()
+	}
+	databaseAgent.On("State").Return(lifecycle.StateInitialized).Once()
+	databaseAgent.On("Start", mock.Anything).Return(nil).Once()
+	databaseAgent.On("State").Return(lifecycle.StateRunning).Once()
+	reportingAgent.On("State").Return(lifecycle.StateInitialized).Once()
+	reportingAgent.On("Start", mock.Anything).Return(nil).Once()
+	databaseAgent.On("Health", mock.Anything).Return(nil).Once()
+	reportingAgent.On("Health", mock.Anything).Return(nil).Once()
+	reportingAgent.On("State").Return(lifecycle.StateRunning).Once()
+
+	am := NewAgentManager(AgentManagerDeps{
+		FileChangeAgent:  folderAgents[0],
+		FileChangeAgents: []agent.FileChangeAgent{folderAgents[0], folderAgents[1]},
+		DatabaseAgent:    databaseAgent,
+		ReportingAgent:   reportingAgent,
+	})
+
+	assert.NoError(t, am.Initialize(context.Background()))
+	assert.NoError(t, am.Start(context.Background()))
+	assert.Len(t, am.GetFileChangeAgents(), 2)
+
+	for _, fca := range folderAgents {
+		fca.AssertExpectations(t)
+	}
+}
+
 func TestAgentManager_Stop(t *testing.T) {
 	// Create mocks
 	fileChangeAgent := new(mockFileChangeAgent)
@@ -194,6 +251,8 @@ func TestAgentManager_Stop(t *testing.T) {
 	fileChangeAgent.On("Start", mock.Anything).Return(nil).Once()
 	databaseAgent.On("Start", mock.Anything).Return(nil).Once()
 	reportingAgent.On("Start", mock.Anything).Return(nil).Once()
+	databaseAgent.On("Health", mock.Anything).Return(nil).Once()
+	reportingAgent.On("Health", mock.Anything).Return(nil).Once()
 
 	fileChangeAgent.On("State").Return(lifecycle.StateRunning).Once()
 	databaseAgent.On("State").Return(lifecycle.StateRunning).Once()
@@ -216,6 +275,11 @@ func TestAgentManager_Stop(t *testing.T) {
 	err = am.Stop(context.Background())
 	assert.NoError(t, err)
 
+	// Stopping again does not stop the agents a second time
+	err = am.Stop(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, lifecycle.StateStopped, am.State())
+
 	// Assert expectations
 	fileChangeAgent.AssertExpectations(t)
 	databaseAgent.AssertExpectations(t)
@@ -250,6 +314,8 @@ func TestAgentManager_Health(t *testing.T) {
 	fileChangeAgent.On("Start", mock.Anything).Return(nil).Once()
 	databaseAgent.On("Start", mock.Anything).Return(nil).Once()
 	reportingAgent.On("Start", mock.Anything).Return(nil).Once()
+	databaseAgent.On("Health", mock.Anything).Return(nil).Once()
+	reportingAgent.On("Health", mock.Anything).Return(nil).Once()
 
 	fileChangeAgent.On("State").Return(lifecycle.StateRunning).Once()
 	databaseAgent.On("State").Return(lifecycle.StateRunning).Once()
@@ -277,3 +343,77 @@ func TestAgentManager_Health(t *testing.T) {
 	databaseAgent.AssertExpectations(t)
 	reportingAgent.AssertExpectations(t)
 }
+
+func TestAgentManager_Options(t *testing.T) {
+	fileChangeAgent := new(mockFileChangeAgent)
+	databaseAgent := new(mockDatabaseAgent)
+	reportingAgent := new(mockReportingAgent)
+
+	am := NewAgentManager(AgentManagerDeps{
+		FileChangeAgent: fileChangeAgent,
+		DatabaseAgent:   databaseAgent,
+		ReportingAgent:  reportingAgent,
+	}, WithPollInterval(time.Minute), WithRetryPolicy(RetryPolicy{MaxAttempts: 3, Delay: time.Millisecond}))
+
+	// The manager cannot start before it is initialized
+	assert.Error(t, am.Start(context.Background()))
+
+	// Initializing applies the poll interval to the file change agents
+	fileChangeAgent.On("SetPollInterval", time.Minute).Once()
+	assert.NoError(t, am.Initialize(context.Background()))
+
+	// An agent that fails to start is retried while it can still be started
+	fileChangeAgent.On("State").Return(lifecycle.StateInitialized).Times(2)
+	fileChangeAgent.On("Start", mock.Anything).Return(assert.AnError).Once()
+	fileChangeAgent.On("Start", mock.Anything).Return(nil).Once()
+	fileChangeAgent.On("State").Return(lifecycle.StateRunning).Once()
+	databaseAgent.On("State").Return(lifecycle.StateInitialized).Once()
+	databaseAgent.On("Start", mock.Anything).Return(nil).Once()
+	databaseAgent.On("State").Return(lifecycle.StateRunning).Once()
+	reportingAgent.On("State").Return(lifecycle.StateInitialized).Once()
+	reportingAgent.On("Start", mock.Anything).Return(nil).Once()
+	databaseAgent.On("Health", mock.Anything).Return(nil).Once()
+	reportingAgent.On("Health", mock.Anything).Return(nil).Once()
+	reportingAgent.On("State").Return(lifecycle.StateRunning).Once()
+
+	assert.NoError(t, am.Start(context.Background()))
+	assert.Equal(t, lifecycle.StateRunning, am.State())
+
+	fileChangeAgent.AssertExpectations(t)
+	databaseAgent.AssertExpectations(t)
+	reportingAgent.AssertExpectations(t)
+}
+
+func TestAgentManager_RollsBackFailedStart(t *testing.T) {
+	fileChangeAgent := new(mockFileChangeAgent)
+	databaseAgent := new(mockDatabaseAgent)
+	reportingAgent := new(mockReportingAgent)
+
+	am := NewAgentManager(AgentManagerDeps{
+		FileChangeAgent: fileChangeAgent,
+		DatabaseAgent:   databaseAgent,
+		ReportingAgent:  reportingAgent,
+	}, WithRetryPolicy(RetryPolicy{MaxAttempts: 2, Delay: time.Millisecond}))
+	assert.NoError(t, am.Initialize(context.Background()))
+
+	// Retries stop at the maximum attempts
+	fileChangeAgent.On("State").Return(lifecycle.StateInitialized)
+	fileChangeAgent.On("Start", mock.Anything).Return(assert.AnError).Times(2)
+	for _, a := range []*mock.Mock{&databaseAgent.Mock, &reportingAgent.Mock} {
+		a.On("State").Return(lifecycle.StateInitialized).Once()
+		a.On("Health", mock.Anything).Return(nil).Once()
+		a.On("Start", mock.Anything).Return(nil).Once()
+	}
+
+	// The agents started before the failure are stopped again
+	databaseAgent.On("State").Return(lifecycle.StateRunning).Once()
+	databaseAgent.On("Stop", mock.Anything).Return(nil).Once()
+	reportingAgent.On("State").Return(lifecycle.StateRunning).Once()
+	reportingAgent.On("Stop", mock.Anything).Return(nil).Once()
+
+	assert.ErrorIs(t, am.Start(context.Background()), assert.AnError)
+	assert.Equal(t, lifecycle.StateFailed, am.State())
+	fileChangeAgent.AssertExpectations(t)
+	databaseAgent.AssertExpectations(t)
+	reportingAgent.AssertExpectations(t)
+}
@@ -2,65 +2,79 @@ package agents
 
 import (
 	"context"
-	"os"
-	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func setupTestDB(t *testing.T) (DatabaseAgent, string, func()) {
-	// Create a temporary directory for the test database
-	tempDir, err := os.MkdirTemp("", "dropbox_monitor_test")
-	assert.NoError(t, err)
-
-	// Set environment variable for database path
-	dbPath := filepath.Join(tempDir, "test.db")
-	oldDBPath := os.Getenv("DROPBOX_MONITOR_DB")
-	os.Setenv("DROPBOX_MONITOR_DB", dbPath)
+func setupTestDB(t *testing.T) (DatabaseAgent, func()) {
+	// Keep the test database in memory
+	database, err := db.NewDB(db.MemoryPath)
+	require.NoError(t, err)
 
-	// Initialize database agent
-	agent, err := NewDatabaseAgent()
-	assert.NoError(t, err)
+	agent, err := NewDatabaseAgent(database)
+	require.NoError(t, err)
 
 	// Return cleanup function
 	cleanup := func() {
 		agent.Close()
-		os.RemoveAll(tempDir)
-		if oldDBPath != "" {
-			os.Setenv("DROPBOX_MONITOR_DB", oldDBPath)
-		} else {
-			os.Unsetenv("DROPBOX_MONITOR_DB")
-		}
+		database.Close()
 	}
 
-	return agent, tempDir, cleanup
+	return agent, cleanup
+}
+
+func TestNewDatabaseAgent_RequiresDatabase(t *testing.T) {
+	_, err := NewDatabaseAgent(nil)
+	assert.Error(t, err)
 }
 
 func TestDatabaseAgent_StoreFileContent(t *testing.T) {
-	agent, _, cleanup := setupTestDB(t)
-	defer cleanup()
+	database, err := db.NewDB(db.MemoryPath)
+	require.NoError(t, err)
+	defer database.Close()
+	database.SetContentPolicy(db.ContentPolicy{Mode: db.ContentFull})
+	agent, err := NewDatabaseAgent(database)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// Content needs a stored change to belong to
+	err = agent.StoreFileContent(ctx, &models.FileContent{Path: "/test.txt", ContentType: "text/plain", Text: "hello"})
+	assert.Error(t, err)
 
-	// Test storing file content
-	content := &models.FileContent{
-		ContentType: "text/plain",
+	modTime := time.Now().Add(-time.Hour)
+	for i, hash := range []string{"v1", "v2"} {
+		require.NoError(t, agent.StoreChange(ctx, models.FileMetadata{
+			Path:        "/test.txt",
+			Size:        5,
+			ModTime:     modTime.Add(time.Duration(i) * time.Minute),
+			ContentHash: hash,
+		}))
 	}
 
-	err := agent.StoreFileContent(context.Background(), content)
-	assert.NoError(t, err)
-
-	// Test storing another file content
-	content = &models.FileContent{
-		ContentType: "application/json",
-	}
-	err = agent.StoreFileContent(context.Background(), content)
-	assert.NoError(t, err)
+	// Each version keeps its own content
+	require.NoError(t, agent.StoreFileContent(ctx, &models.FileContent{
+		Path: "/test.txt", ContentType: "text/plain", ContentHash: "v1", Text: "hello",
+	}))
+	require.NoError(t, agent.StoreFileContent(ctx, &models.FileContent{
+		Path: "/test.txt", ContentType: "text/plain", ContentHash: "v2", Text: "hello world",
+	}))
+
+	revisions, err := database.GetContentRevisions(ctx, "/test.txt", 10)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, "hello world", revisions[0].Content)
+	assert.Equal(t, "v2", revisions[0].ContentHash)
+	assert.Equal(t, "hello", revisions[1].Content)
+	assert.Equal(t, "v1", revisions[1].ContentHash)
 }
 
 func TestDatabaseAgent_GetRecentChanges(t *testing.T) {
-	agent, _, cleanup := setupTestDB(t)
+	agent, cleanup := setupTestDB(t)
 	defer cleanup()
 
 	// Store some test data
@@ -91,7 +105,7 @@ func TestDatabaseAgent_GetRecentChanges(t *testing.T) {
 }
 
 func TestDatabaseAgent_GetChanges(t *testing.T) {
-	agent, _, cleanup := setupTestDB(t)
+	agent, cleanup := setupTestDB(t)
 	defer cleanup()
 
 	startTime := time.Now().Add(-time.Hour).Format(time.RFC3339)
@@ -114,15 +128,21 @@ func TestDatabaseAgent_GetChanges(t *testing.T) {
 }
 
 func TestDatabaseAgent_Health(t *testing.T) {
-	agent, _, cleanup := setupTestDB(t)
-	defer cleanup()
+	database, err := db.NewDB(db.MemoryPath)
+	require.NoError(t, err)
+	agent, err := NewDatabaseAgent(database)
+	require.NoError(t, err)
 
 	// Test health check
-	err := agent.Health(context.Background())
+	err = agent.Health(context.Background())
 	assert.NoError(t, err)
 
+	// Closing the agent leaves the database to its owner
+	require.NoError(t, agent.Close())
+	assert.NoError(t, agent.Health(context.Background()))
+
 	// Test health check after closing connection
-	agent.Close()
+	database.Close()
 	err = agent.Health(context.Background())
 	assert.Error(t, err)
 }
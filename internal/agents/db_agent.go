@@ -3,8 +3,6 @@ package agents
 import (
 	"context"
 	"fmt"
-	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
@@ -28,21 +26,11 @@ type databaseAgent struct {
 	database *db.DB
 }
 
-// NewDatabaseAgent creates a new database agent
-func NewDatabaseAgent() (DatabaseAgent, error) {
-	// Get database path from environment variable or use default
-	dbPath := os.Getenv("DROPBOX_MONITOR_DB")
-	if dbPath == "" {
-		// Create data directory if it doesn't exist
-		if err := os.MkdirAll("data", 0755); err != nil {
-			return nil, fmt.Errorf("create data directory: %w", err)
-		}
-		dbPath = filepath.Join("data", "dropbox_monitor.db")
-	}
-
-	database, err := db.NewDB(dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("create database: %w", err)
+// NewDatabaseAgent creates a new database agent storing changes in
+// database, which stays owned by the caller
+func NewDatabaseAgent(database *db.DB) (DatabaseAgent, error) {
+	if database == nil {
+		return nil, fmt.Errorf("database cannot be nil")
 	}
 
 	agent := &databaseAgent{
@@ -66,10 +54,7 @@ func (a *databaseAgent) Start(ctx context.Context) error {
 
 // Stop implements lifecycle.Component
 func (a *databaseAgent) Stop(ctx context.Context) error {
-	if err := a.DefaultStop(ctx); err != nil {
-		return err
-	}
-	return a.Close()
+	return a.DefaultStop(ctx)
 }
 
 // Health implements lifecycle.Component
@@ -88,6 +73,7 @@ func (a *databaseAgent) StoreChange(ctx context.Context, change models.FileMetad
 	dbChange := &db.FileChange{
 		FilePath:       change.Path,
 		ModifiedAt:     change.ModTime,
+		ContentHash:    change.ContentHash,
 		IsDownloadable: true,
 		CreatedAt:      time.Now(),
 		Size:          change.Size,
@@ -155,12 +141,24 @@ func (a *databaseAgent) GetChanges(ctx context.Context, startTime, endTime strin
 	return changes, nil
 }
 
-// StoreFileContent stores file content in the database
+// StoreFileContent stores the text of an analyzed file with the stored
+// change of the same version, as much of it as the database's content
+// policy keeps. Storing the content of each version keeps a revision
+// history per path.
 func (a *databaseAgent) StoreFileContent(ctx context.Context, content *models.FileContent) error {
+	changeID, ok, err := a.database.FileChangeIDForContent(ctx, content.Path, content.ContentHash)
+	if err != nil {
+		return fmt.Errorf("store file content: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("store file content: no stored change of %s", content.Path)
+	}
+
 	dbContent := &db.FileContent{
-		Content:     "",  // We don't store the actual content
-		ContentType: content.ContentType,
-		CreatedAt:   time.Now(),
+		FileChangeID: changeID,
+		Content:      content.Text,
+		ContentType:  content.ContentType,
+		CreatedAt:    time.Now(),
 	}
 
 	if err := a.database.SaveFileContent(ctx, dbContent); err != nil {
@@ -170,10 +168,8 @@ func (a *databaseAgent) StoreFileContent(ctx context.Context, content *models.Fi
 	return nil
 }
 
-// Close closes the database connection
+// Close implements DatabaseAgent. The database is left open for its owner
+// to close.
 func (a *databaseAgent) Close() error {
-	if err := a.database.Close(); err != nil {
-		return fmt.Errorf("close database: %w", err)
-	}
 	return nil
 }
@@ -2,12 +2,13 @@ package agents
 
 import (
 	"context"
-	"log"
+	"fmt"
 	"time"
 
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/agent"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/core"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/interfaces"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
 )
 
@@ -24,9 +25,15 @@ func NewFileChangeAgent(client interfaces.DropboxClient, stateManager interfaces
 	}
 }
 
+// NewFileChangeAgentWithOptions creates a file change agent for one monitored folder
+func NewFileChangeAgentWithOptions(client interfaces.DropboxClient, stateManager interfaces.StateManager, opts core.FolderOptions) agent.FileChangeAgent {
+	return &fileChangeAgentImpl{
+		FileChangeAgent: core.NewFileChangeAgentWithOptions(client, stateManager, opts),
+	}
+}
+
 // Start starts the file change monitoring
 func (a *fileChangeAgentImpl) Start(ctx context.Context) error {
-	log.Printf(" Starting FileChangeAgent...")
 	return a.FileChangeAgent.Start(ctx)
 }
 
@@ -35,6 +42,22 @@ func (a *fileChangeAgentImpl) Stop(ctx context.Context) error {
 	return a.FileChangeAgent.Stop(ctx)
 }
 
+// Live reports whether the file change monitoring is still running
+func (a *fileChangeAgentImpl) Live(ctx context.Context) error {
+	if checker, ok := a.FileChangeAgent.(lifecycle.LivenessChecker); ok {
+		return checker.Live(ctx)
+	}
+	return a.FileChangeAgent.Health(ctx)
+}
+
+// Restart starts the file change monitoring again after it failed
+func (a *fileChangeAgentImpl) Restart(ctx context.Context) error {
+	if restarter, ok := a.FileChangeAgent.(lifecycle.Restarter); ok {
+		return restarter.Restart(ctx)
+	}
+	return fmt.Errorf("file change agent cannot be restarted")
+}
+
 // Health checks the health of the file change agent
 func (a *fileChangeAgentImpl) Health(ctx context.Context) error {
 	return a.FileChangeAgent.Health(ctx)
@@ -45,12 +68,42 @@ func (a *fileChangeAgentImpl) GetChanges(ctx context.Context) ([]models.FileChan
 	return a.FileChangeAgent.GetChanges(ctx)
 }
 
+// PeekChanges returns the changes the next check would report without
+// consuming them
+func (a *fileChangeAgentImpl) PeekChanges(ctx context.Context) ([]models.FileChange, error) {
+	if impl, ok := a.FileChangeAgent.(*core.FileChangeAgentImpl); ok {
+		return impl.PeekChanges(ctx)
+	}
+	return nil, fmt.Errorf("file change agent cannot peek at changes")
+}
+
+// PollStatus returns when the folder was last checked successfully and the
+// latest error
+func (a *fileChangeAgentImpl) PollStatus() core.PollStatus {
+	if poller, ok := a.FileChangeAgent.(interface{ PollStatus() core.PollStatus }); ok {
+		return poller.PollStatus()
+	}
+	return core.PollStatus{}
+}
+
 // GetFileContent returns the content of a file
 func (a *fileChangeAgentImpl) GetFileContent(ctx context.Context, path string) ([]byte, error) {
 	return a.FileChangeAgent.GetFileContent(ctx, path)
 }
 
+// TriggerCheck checks for changes immediately
+func (a *fileChangeAgentImpl) TriggerCheck(ctx context.Context) error {
+	return a.FileChangeAgent.TriggerCheck(ctx)
+}
+
 // SetPollInterval sets the polling interval
 func (a *fileChangeAgentImpl) SetPollInterval(interval time.Duration) {
 	a.FileChangeAgent.SetPollInterval(interval)
 }
+
+// SetFilters replaces the include and exclude globs of the folder
+func (a *fileChangeAgentImpl) SetFilters(include, exclude []string) {
+	if impl, ok := a.FileChangeAgent.(*core.FileChangeAgentImpl); ok {
+		impl.SetFilters(include, exclude)
+	}
+}
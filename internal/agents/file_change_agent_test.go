@@ -2,114 +2,97 @@ package agents
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/core"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/events"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/mocks"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
-// mockDropboxClient is a mock implementation of the Dropbox client
-type mockDropboxClient struct {
-	mock.Mock
-	dropbox.Client
-}
-
-func (m *mockDropboxClient) ListFolder(ctx context.Context, path string) ([]*models.FileMetadata, error) {
-	args := m.Called(ctx, path)
-	return args.Get(0).([]*models.FileMetadata), args.Error(1)
-}
-
-func (m *mockDropboxClient) GetFileContent(ctx context.Context, path string) ([]byte, error) {
-	args := m.Called(ctx, path)
-	return args.Get(0).([]byte), args.Error(1)
-}
-
-func (m *mockDropboxClient) GetChangesLast24Hours(ctx context.Context) ([]*models.FileMetadata, error) {
-	args := m.Called(ctx)
-	return args.Get(0).([]*models.FileMetadata), args.Error(1)
-}
-
-func (m *mockDropboxClient) GetChangesLast10Minutes(ctx context.Context) ([]*models.FileMetadata, error) {
-	args := m.Called(ctx)
-	return args.Get(0).([]*models.FileMetadata), args.Error(1)
-}
-
-func (m *mockDropboxClient) GetChanges(ctx context.Context) ([]*models.FileMetadata, error) {
-	args := m.Called(ctx)
-	return args.Get(0).([]*models.FileMetadata), args.Error(1)
-}
-
-// mockStateManager is a mock implementation of the StateManager
-type mockStateManager struct {
-	mock.Mock
-	core.StateManager
-}
-
-func (m *mockStateManager) GetString(key string) string {
-	args := m.Called(key)
-	return args.String(0)
-}
-
-func (m *mockStateManager) SetString(key, value string) error {
-	args := m.Called(key, value)
-	return args.Error(0)
-}
-
 func TestFileChangeAgent_GetChanges(t *testing.T) {
 	// Create test files
 	now := time.Now()
 	testFiles := []*models.FileMetadata{
 		models.NewFileMetadata("/test1.txt", 1024, now, false),
-		models.NewFileMetadata("/test2.txt", 2048, now, false),
+		models.NewFileMetadata("/test2.txt", 2048, now, true),
 	}
 
 	// Create expected changes
 	expectedChanges := models.BatchConvertMetadataToChanges(testFiles)
 
 	tests := []struct {
-		name      string
-		files     []*models.FileMetadata
-		wantErr   bool
-		expected  []models.FileChange
-		err       error
+		name     string
+		cursor   string
+		setup    func(c *mocks.DropboxClient, s *mocks.StateManager)
+		wantErr  bool
+		expected []models.FileChange
 	}{
 		{
-			name:      "Recent changes found",
-			files:     testFiles,
-			wantErr:   false,
-			expected:  expectedChanges,
-			err:       nil,
+			name:   "Baseline on first check",
+			cursor: "",
+			setup: func(c *mocks.DropboxClient, s *mocks.StateManager) {
+				c.On("GetLatestCursor", mock.Anything, "").Return("cursor-1", nil).Once()
+				s.On("SetString", "cursor", "cursor-1").Return(nil).Once()
+			},
+			expected: nil,
+		},
+		{
+			name:   "Delta since cursor",
+			cursor: "cursor-1",
+			setup: func(c *mocks.DropboxClient, s *mocks.StateManager) {
+				c.On("ListFolderContinue", mock.Anything, "cursor-1").Return(testFiles, "cursor-2", nil).Once()
+				s.On("SetString", "cursor", "cursor-2").Return(nil).Once()
+			},
+			expected: expectedChanges,
 		},
 		{
-			name:      "Dropbox error",
-			files:     nil,
-			wantErr:   true,
-			expected:  nil,
-			err:       assert.AnError,
+			name:   "No changes keeps cursor",
+			cursor: "cursor-2",
+			setup: func(c *mocks.DropboxClient, s *mocks.StateManager) {
+				c.On("ListFolderContinue", mock.Anything, "cursor-2").Return([]*models.FileMetadata{}, "cursor-2", nil).Once()
+			},
+			expected: []models.FileChange{},
+		},
+		{
+			name:   "Reset cursor is cleared",
+			cursor: "stale",
+			setup: func(c *mocks.DropboxClient, s *mocks.StateManager) {
+				c.On("ListFolderContinue", mock.Anything, "stale").Return(nil, "", dropbox.NewConflictError("reset", nil)).Once()
+				s.On("SetString", "cursor", "").Return(nil).Once()
+			},
+			wantErr: true,
+		},
+		{
+			name:   "Dropbox error",
+			cursor: "cursor-1",
+			setup: func(c *mocks.DropboxClient, s *mocks.StateManager) {
+				c.On("ListFolderContinue", mock.Anything, "cursor-1").Return(nil, "", assert.AnError).Once()
+			},
+			wantErr: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Create mocks
-			mockClient := &mockDropboxClient{}
-			mockState := &mockStateManager{}
-
-			// Set up mock expectations for the embedded FileChangeAgent
-			mockClient.On("ListFolder", mock.Anything, "").Return(tt.files, tt.err).Once()
-			mockState.On("GetString", "cursor").Return("").Once()
-			if !tt.wantErr {
-				mockState.On("SetString", "cursor", mock.Anything).Return(nil).Once()
-			}
+			mockClient := &mocks.DropboxClient{}
+			mockState := &mocks.StateManager{}
+
+			mockState.On("GetString", "cursor").Return(tt.cursor).Once()
+			tt.setup(mockClient, mockState)
 
 			// Create agent with mocks
-			agent := NewFileChangeAgent(mockClient, mockState, "")
+			agent := NewFileChangeAgent(mockClient, mockState, "/")
 
 			// Initialize the embedded FileChangeAgent
 			agent.(*fileChangeAgentImpl).FileChangeAgent.(*core.FileChangeAgentImpl).SetState(lifecycle.StateInitialized)
@@ -138,8 +121,8 @@ func TestFileChangeAgent_GetChanges(t *testing.T) {
 
 func TestFileChangeAgent_Lifecycle(t *testing.T) {
 	// Create mocks
-	mockClient := &mockDropboxClient{}
-	mockState := &mockStateManager{}
+	mockClient := &mocks.DropboxClient{}
+	mockState := &mocks.StateManager{}
 
 	// Set up mock expectations
 	mockClient.On("ListFolder", mock.Anything, "").Return([]*models.FileMetadata{}, nil).Times(2)
@@ -163,6 +146,226 @@ func TestFileChangeAgent_Lifecycle(t *testing.T) {
 	// Run shutdown sequence
 	err = agent.Stop(context.Background())
 	assert.NoError(t, err)
+
+	// Stopping again, as the container and a signal handler may both do, has no effect
+	err = agent.Stop(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, lifecycle.StateStopped, agent.State())
+}
+
+// listingDropboxClient adds recursive listing to the mock client
+type listingDropboxClient struct {
+	*mocks.DropboxClient
+}
+
+func (m listingDropboxClient) ListFolderRecursive(ctx context.Context, path string) ([]*models.FileMetadata, string, error) {
+	args := m.Called(ctx, path)
+	files, _ := args.Get(0).([]*models.FileMetadata)
+	return files, args.String(1), args.Error(2)
+}
+
+func TestFileChangeAgent_FirstRun(t *testing.T) {
+	now := time.Now()
+	existing := []*models.FileMetadata{
+		models.NewFileMetadata("/docs/a.txt", 1024, now, false),
+		models.NewFileMetadata("/docs/b.tmp", 2048, now, false),
+	}
+
+	newAgent := func(opts core.FolderOptions) (*mocks.DropboxClient, *mocks.StateManager, *core.FileChangeAgentImpl) {
+		mockClient := &mocks.DropboxClient{}
+		mockState := &mocks.StateManager{}
+		mockState.On("GetString", "cursor:/docs").Return("").Once()
+		opts.Path, opts.Recursive, opts.Exclude = "/docs", true, []string{"*.tmp"}
+		agent := core.NewFileChangeAgentWithOptions(listingDropboxClient{mockClient}, mockState, opts)
+		return mockClient, mockState, agent.(*core.FileChangeAgentImpl)
+	}
+
+	t.Run("Baseline by default", func(t *testing.T) {
+		mockClient, mockState, agent := newAgent(core.FolderOptions{})
+		mockClient.On("GetLatestCursor", mock.Anything, "/docs").Return("cursor-1", nil).Once()
+		mockState.On("SetString", "cursor:/docs", "cursor-1").Return(nil).Once()
+
+		changes, err := agent.GetChanges(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+		mockClient.AssertExpectations(t)
+		mockState.AssertExpectations(t)
+	})
+
+	t.Run("Full report", func(t *testing.T) {
+		mockClient, mockState, agent := newAgent(core.FolderOptions{FirstRun: core.FirstRunFull})
+		mockClient.On("ListFolderRecursive", mock.Anything, "/docs").Return(existing, "cursor-1", nil).Once()
+		mockState.On("SetString", "cursor:/docs", "cursor-1").Return(nil).Once()
+
+		changes, err := agent.GetChanges(context.Background())
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		assert.Equal(t, "/docs/a.txt", changes[0].Path)
+		mockState.AssertExpectations(t)
+	})
+
+	t.Run("Summary only", func(t *testing.T) {
+		var summarized []models.FileChange
+		mockClient, mockState, agent := newAgent(core.FolderOptions{
+			FirstRun: core.FirstRunSummary,
+			OnFirstRun: func(ctx context.Context, changes []models.FileChange) error {
+				summarized = changes
+				return nil
+			},
+		})
+		mockClient.On("ListFolderRecursive", mock.Anything, "/docs").Return(existing, "cursor-1", nil).Once()
+		mockState.On("SetString", "cursor:/docs", "cursor-1").Return(nil).Once()
+
+		changes, err := agent.GetChanges(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+		require.Len(t, summarized, 1)
+		assert.Equal(t, "/docs/a.txt", summarized[0].Path)
+		mockState.AssertExpectations(t)
+	})
+
+	t.Run("Failed summary is retried", func(t *testing.T) {
+		mockClient, mockState, agent := newAgent(core.FolderOptions{
+			FirstRun: core.FirstRunSummary,
+			OnFirstRun: func(ctx context.Context, changes []models.FileChange) error {
+				return assert.AnError
+			},
+		})
+		mockClient.On("ListFolderRecursive", mock.Anything, "/docs").Return(existing, "cursor-1", nil).Once()
+
+		_, err := agent.GetChanges(context.Background())
+		assert.Error(t, err)
+		mockState.AssertNotCalled(t, "SetString", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Baseline stores the listed files", func(t *testing.T) {
+		store := &fileStore{}
+		mockClient, mockState, agent := newAgent(core.FolderOptions{Files: store})
+		mockClient.On("ListFolderRecursive", mock.Anything, "/docs").Return(existing, "cursor-1", nil).Once()
+		mockState.On("SetString", "cursor:/docs", "cursor-1").Return(nil).Once()
+
+		changes, err := agent.GetChanges(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+		// Filtered files are stored too
+		assert.Equal(t, existing, store.files)
+		mockClient.AssertNotCalled(t, "GetLatestCursor", mock.Anything, mock.Anything)
+		mockState.AssertExpectations(t)
+	})
+}
+
+// resumableDropboxClient lists pages of files, failing after the pages
+// allowed, and resumes from the progress it is given
+type resumableDropboxClient struct {
+	*mocks.DropboxClient
+	pages   [][]*models.FileMetadata
+	allowed int
+	resumed dropbox.SyncProgress
+}
+
+func (m *resumableDropboxClient) ListFolderResumable(ctx context.Context, path string, progress dropbox.SyncProgress, onPage dropbox.PageHandler) (string, error) {
+	m.resumed = progress
+	for progress.Pages < len(m.pages) {
+		if progress.Pages >= m.allowed {
+			return "", assert.AnError
+		}
+		page := m.pages[progress.Pages]
+		progress.Pages++
+		progress.Files += len(page)
+		progress.Cursor = fmt.Sprintf("page-%d", progress.Pages)
+		if err := onPage(page, progress); err != nil {
+			return "", err
+		}
+	}
+	return "cursor-1", nil
+}
+
+func TestFileChangeAgent_ResumesSync(t *testing.T) {
+	now := time.Now()
+	pages := [][]*models.FileMetadata{
+		{models.NewFileMetadata("/docs/a.txt", 1024, now, false)},
+		{models.NewFileMetadata("/docs/b.txt", 2048, now, false)},
+	}
+	client := &resumableDropboxClient{DropboxClient: &mocks.DropboxClient{}, pages: pages, allowed: 1}
+	store := &fileStore{}
+
+	// The first sync fails after saving the progress of its first page
+	var saved string
+	mockState := &mocks.StateManager{}
+	mockState.On("GetString", "cursor:/docs").Return("")
+	mockState.On("GetString", "sync:/docs").Return("").Once()
+	mockState.On("SetString", "sync:/docs", mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.String(1)
+	}).Return(nil).Once()
+	agent := core.NewFileChangeAgentWithOptions(client, mockState, core.FolderOptions{Path: "/docs", Recursive: true, Files: store})
+
+	_, err := agent.GetChanges(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, saved, `"cursor":"page-1"`)
+
+	// The next sync resumes from the second page, saves its progress, and
+	// forgets it once done
+	client.allowed = len(pages)
+	mockState.On("GetString", "sync:/docs").Return(saved).Once()
+	mockState.On("SetString", "sync:/docs", mock.MatchedBy(func(progress string) bool {
+		return strings.Contains(progress, `"cursor":"page-2"`)
+	})).Return(nil).Once()
+	mockState.On("SetString", "cursor:/docs", "cursor-1").Return(nil).Once()
+	mockState.On("SetString", "sync:/docs", "").Return(nil).Once()
+
+	changes, err := agent.GetChanges(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+	assert.Equal(t, 1, client.resumed.Pages)
+	assert.Equal(t, "page-1", client.resumed.Cursor)
+	assert.Equal(t, []*models.FileMetadata{pages[0][0], pages[1][0]}, store.files)
+	mockState.AssertExpectations(t)
+}
+
+// fileStore records the files stored by a sync
+type fileStore struct {
+	files []*models.FileMetadata
+	err   error
+}
+
+func (s *fileStore) StoreFiles(ctx context.Context, files []*models.FileMetadata) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.files = append(s.files, files...)
+	return nil
+}
+
+func TestFileChangeAgent_StoresChangedFiles(t *testing.T) {
+	changed := []*models.FileMetadata{
+		models.NewFileMetadata("/docs/a.txt", 1024, time.Now(), false),
+		models.NewFileMetadata("/docs/gone.txt", 0, time.Now(), true),
+	}
+
+	t.Run("Stored before the cursor moves", func(t *testing.T) {
+		store := &fileStore{}
+		mockClient, mockState := &mocks.DropboxClient{}, &mocks.StateManager{}
+		mockState.On("GetString", "cursor:/docs").Return("cursor-1").Once()
+		mockClient.On("ListFolderContinue", mock.Anything, "cursor-1").Return(changed, "cursor-2", nil).Once()
+		mockState.On("SetString", "cursor:/docs", "cursor-2").Return(nil).Once()
+		agent := core.NewFileChangeAgentWithOptions(mockClient, mockState, core.FolderOptions{Path: "/docs", Recursive: true, Files: store})
+
+		_, err := agent.GetChanges(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, changed, store.files)
+		mockState.AssertExpectations(t)
+	})
+
+	t.Run("Failure keeps the cursor", func(t *testing.T) {
+		mockClient, mockState := &mocks.DropboxClient{}, &mocks.StateManager{}
+		mockState.On("GetString", "cursor:/docs").Return("cursor-1").Once()
+		mockClient.On("ListFolderContinue", mock.Anything, "cursor-1").Return(changed, "cursor-2", nil).Once()
+		agent := core.NewFileChangeAgentWithOptions(mockClient, mockState, core.FolderOptions{Path: "/docs", Recursive: true, Files: &fileStore{err: assert.AnError}})
+
+		_, err := agent.GetChanges(context.Background())
+		assert.Error(t, err)
+		mockState.AssertNotCalled(t, "SetString", mock.Anything, mock.Anything)
+	})
 }
 
 // DropboxError is a mock error type for testing
@@ -173,3 +376,244 @@ type DropboxError struct {
 func (e *DropboxError) Error() string {
 	return e.Message
 }
+
+func TestFileChangeAgent_ReloadedSettings(t *testing.T) {
+	now := time.Now()
+	files := []*models.FileMetadata{
+		models.NewFileMetadata("/notes.txt", 10, now, false),
+		models.NewFileMetadata("/photo.jpg", 20, now, false),
+	}
+
+	mockClient := &mocks.DropboxClient{}
+	mockState := &mocks.StateManager{}
+	mockState.On("GetString", "cursor").Return("cursor-1")
+	mockClient.On("ListFolderContinue", mock.Anything, "cursor-1").Return(files, "cursor-1", nil)
+
+	checked := make(chan []models.FileChange, 10)
+	agent := NewFileChangeAgentWithOptions(mockClient, mockState, core.FolderOptions{
+		Path:         "/",
+		PollInterval: time.Hour,
+		Recursive:    true,
+		OnChanges: func(ctx context.Context, changes []models.FileChange) error {
+			checked <- changes
+			return nil
+		},
+	})
+	require.NoError(t, agent.Start(context.Background()))
+	defer agent.Stop(context.Background())
+
+	// The new filters apply from the next check, and the running agent
+	// polls at the new interval without a restart
+	agent.(*fileChangeAgentImpl).SetFilters([]string{"*.txt"}, nil)
+	agent.SetPollInterval(10 * time.Millisecond)
+
+	select {
+	case changes := <-checked:
+		require.Len(t, changes, 1)
+		assert.Equal(t, "/notes.txt", changes[0].Path)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the new poll interval was not applied")
+	}
+}
+
+func TestFileChangeAgent_PublishesChanges(t *testing.T) {
+	files := []*models.FileMetadata{models.NewFileMetadata("/docs/a.txt", 10, time.Now(), false)}
+	mockClient := &mocks.DropboxClient{}
+	mockState := &mocks.StateManager{}
+	mockState.On("GetString", "cursor:/docs").Return("cursor-1")
+	mockClient.On("ListFolderContinue", mock.Anything, "cursor-1").Return(files, "cursor-1", nil)
+
+	// Changes are published before the folder's own handler sees them
+	bus := events.NewBus(nil)
+	published := make(chan events.FileChanged, 10)
+	var handled atomic.Int32
+	bus.Subscribe("test", func(ctx context.Context, event events.FileChanged) error {
+		if handled.Load() == 0 {
+			published <- event
+		}
+		return nil
+	})
+	agent := NewFileChangeAgentWithOptions(mockClient, mockState, core.FolderOptions{
+		Path:         "/docs",
+		PollInterval: 10 * time.Millisecond,
+		Recursive:    true,
+		Events:       bus,
+		OnChanges: func(ctx context.Context, changes []models.FileChange) error {
+			handled.Add(1)
+			return nil
+		},
+	})
+	require.NoError(t, agent.Start(context.Background()))
+	defer agent.Stop(context.Background())
+
+	select {
+	case event := <-published:
+		assert.Equal(t, "/docs", event.Folder)
+		require.Len(t, event.Changes, 1)
+		assert.Equal(t, "/docs/a.txt", event.Changes[0].Path)
+		assert.False(t, event.DetectedAt.IsZero())
+	case <-time.After(5 * time.Second):
+		t.Fatal("no changes were published")
+	}
+}
+
+func TestFileChangeAgent_KeepsCursorUntilPublished(t *testing.T) {
+	files := []*models.FileMetadata{models.NewFileMetadata("/docs/a.txt", 10, time.Now(), false)}
+	mockClient := &mocks.DropboxClient{}
+	mockState := &mocks.StateManager{}
+	mockState.On("GetString", "cursor:/docs").Return("cursor-1")
+	mockClient.On("ListFolderContinue", mock.Anything, "cursor-1").Return(files, "cursor-2", nil)
+
+	bus := events.NewBus(nil)
+	var failing atomic.Bool
+	failing.Store(true)
+	bus.Subscribe("database", func(ctx context.Context, event events.FileChanged) error {
+		if failing.Load() {
+			return assert.AnError
+		}
+		return nil
+	})
+	var handled atomic.Int32
+	agent := NewFileChangeAgentWithOptions(mockClient, mockState, core.FolderOptions{
+		Path:         "/docs",
+		PollInterval: time.Hour,
+		Recursive:    true,
+		Events:       bus,
+		OnChanges: func(ctx context.Context, changes []models.FileChange) error {
+			handled.Add(1)
+			return nil
+		},
+	})
+	ctx := context.Background()
+	require.NoError(t, agent.Start(ctx))
+	defer agent.Stop(ctx)
+
+	// Changes a subscriber failed to record are listed again by the next check
+	assert.ErrorIs(t, agent.TriggerCheck(ctx), assert.AnError)
+	mockState.AssertNotCalled(t, "SetString", mock.Anything, mock.Anything)
+	assert.Zero(t, handled.Load())
+
+	failing.Store(false)
+	mockState.On("SetString", "cursor:/docs", "cursor-2").Return(nil).Once()
+	require.NoError(t, agent.TriggerCheck(ctx))
+	mockState.AssertExpectations(t)
+	assert.Equal(t, int32(1), handled.Load())
+}
+
+func TestFileChangeAgent_PeekChanges(t *testing.T) {
+	files := []*models.FileMetadata{models.NewFileMetadata("/docs/a.txt", 10, time.Now(), false)}
+	mockClient := &mocks.DropboxClient{}
+	mockState := &mocks.StateManager{}
+	mockState.On("GetString", "cursor:/docs").Return("cursor-1").Once()
+	mockClient.On("ListFolderContinue", mock.Anything, "cursor-1").Return(files, "cursor-2", nil).Once()
+
+	bus := events.NewBus(nil)
+	bus.Subscribe("database", func(ctx context.Context, event events.FileChanged) error {
+		t.Error("peeked changes were published")
+		return nil
+	})
+	agent := NewFileChangeAgentWithOptions(mockClient, mockState, core.FolderOptions{Path: "/docs", Recursive: true, Events: bus})
+
+	// Peeked changes are neither published nor consumed
+	changes, err := agent.(*fileChangeAgentImpl).PeekChanges(context.Background())
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "/docs/a.txt", changes[0].Path)
+	mockState.AssertNotCalled(t, "SetString", mock.Anything, mock.Anything)
+
+	// A folder without a cursor leaves its first run to the first check
+	mockState.On("GetString", "cursor:/docs").Return("").Once()
+	changes, err = agent.(*fileChangeAgentImpl).PeekChanges(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+	mockClient.AssertExpectations(t)
+}
+
+func TestFileChangeAgent_Account(t *testing.T) {
+	files := []*models.FileMetadata{models.NewFileMetadata("/docs/a.txt", 10, time.Now(), false)}
+	mockClient := &mocks.DropboxClient{}
+	mockState := &mocks.StateManager{}
+	mockState.On("GetString", "cursor#home:/docs").Return("cursor-1").Once()
+	mockState.On("SetString", "cursor#home:/docs", "cursor-2").Return(nil).Once()
+	mockClient.On("ListFolderContinue", mock.Anything, "cursor-1").Return(files, "cursor-2", nil).Once()
+	store := &fileStore{}
+
+	// The changes and files of a configured account carry its ID
+	agent := NewFileChangeAgentWithOptions(mockClient, mockState, core.FolderOptions{Path: "/docs", Recursive: true, Files: store, AccountID: "home"})
+	changes, err := agent.GetChanges(context.Background())
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "home", changes[0].AccountID)
+	require.Len(t, store.files, 1)
+	assert.Equal(t, "home", store.files[0].AccountID)
+	mockState.AssertExpectations(t)
+}
+
+// longpollingDropboxClient reports changes on its first longpoll and waits
+// on later ones until they are cancelled
+type longpollingDropboxClient struct {
+	*mocks.DropboxClient
+	longpolls atomic.Int32
+}
+
+func (m *longpollingDropboxClient) ListFolderLongpoll(ctx context.Context, cursor string, timeout time.Duration) (bool, time.Duration, error) {
+	if m.longpolls.Add(1) == 1 {
+		return true, 0, nil
+	}
+	<-ctx.Done()
+	return false, 0, ctx.Err()
+}
+
+func TestFileChangeAgent_Longpolls(t *testing.T) {
+	files := []*models.FileMetadata{models.NewFileMetadata("/docs/a.txt", 10, time.Now(), false)}
+	client := &longpollingDropboxClient{DropboxClient: &mocks.DropboxClient{}}
+	mockState := &mocks.StateManager{}
+	mockState.On("GetString", "cursor:/docs").Return("cursor-1")
+	client.On("ListFolderContinue", mock.Anything, "cursor-1").Return(files, "cursor-1", nil)
+
+	// The folder is checked as soon as the longpoll reports changes, long
+	// before the next poll is due
+	checked := make(chan []models.FileChange, 10)
+	agent := NewFileChangeAgentWithOptions(client, mockState, core.FolderOptions{
+		Path:         "/docs",
+		PollInterval: time.Hour,
+		Adaptive:     &core.AdaptivePolling{Longpoll: true},
+		Recursive:    true,
+		OnChanges: func(ctx context.Context, changes []models.FileChange) error {
+			checked <- changes
+			return nil
+		},
+	})
+	require.NoError(t, agent.Start(context.Background()))
+	defer agent.Stop(context.Background())
+
+	select {
+	case changes := <-checked:
+		require.Len(t, changes, 1)
+		assert.Equal(t, "/docs/a.txt", changes[0].Path)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the longpoll did not trigger a check")
+	}
+	client.AssertNumberOfCalls(t, "ListFolderContinue", 1)
+}
+
+func TestFileChangeAgent_Restart(t *testing.T) {
+	agent := NewFileChangeAgent(&mocks.DropboxClient{}, &mocks.StateManager{}, "/docs")
+
+	// The poll loop exits with the context it was started with, leaving the
+	// agent running but idle
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, agent.Start(ctx))
+	require.NoError(t, agent.(lifecycle.LivenessChecker).Live(context.Background()))
+	cancel()
+	assert.Eventually(t, func() bool {
+		return agent.(lifecycle.LivenessChecker).Live(context.Background()) != nil
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, lifecycle.StateRunning, agent.State())
+
+	// Restarting resumes monitoring
+	require.NoError(t, agent.(lifecycle.Restarter).Restart(context.Background()))
+	assert.NoError(t, agent.(lifecycle.LivenessChecker).Live(context.Background()))
+	assert.Equal(t, lifecycle.StateRunning, agent.State())
+	require.NoError(t, agent.Stop(context.Background()))
+}
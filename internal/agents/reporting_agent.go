@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/limits"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/notify"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/reporting"
@@ -16,6 +17,8 @@ type ReportingAgent interface {
 	Initialize(ctx context.Context) error
 	GenerateReport(ctx context.Context, changes []models.FileChange) error
 	NotifyChanges(ctx context.Context, changes []models.FileChange) error
+	PreviewReport(ctx context.Context, changes []models.FileChange, reportType models.ReportType) (*models.Report, error)
+	GenerateCustomReport(ctx context.Context, title string, changes []models.FileChange, reportType models.ReportType) error
 }
 
 // reportingAgent implements the ReportingAgent interface
@@ -27,11 +30,23 @@ type reportingAgent struct {
 
 // NewReportingAgent creates a new reporting agent
 func NewReportingAgent(notifier notify.Notifier) (ReportingAgent, error) {
+	return NewReportingAgentWithLimits(notifier, nil)
+}
+
+// NewReportingAgentWithLimits creates a new reporting agent that enforces the
+// guard's report size limit
+func NewReportingAgentWithLimits(notifier notify.Notifier, guard *limits.Guard) (ReportingAgent, error) {
+	return NewReportingAgentWithConfig(notifier, reporting.ReporterConfig{Limits: guard})
+}
+
+// NewReportingAgentWithConfig creates a new reporting agent whose reporter
+// uses the given settings
+func NewReportingAgentWithConfig(notifier notify.Notifier, cfg reporting.ReporterConfig) (ReportingAgent, error) {
 	if notifier == nil {
 		return nil, fmt.Errorf("notifier cannot be nil")
 	}
 
-	reporter, err := reporting.NewReporter(notifier)
+	reporter, err := reporting.NewReporterWithConfig(notifier, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create reporter: %w", err)
 	}
@@ -86,6 +101,48 @@ func (a *reportingAgent) NotifyChanges(ctx context.Context, changes []models.Fil
 	return a.GenerateReport(ctx, changes)
 }
 
+// GenerateCustomReport generates and sends a single titled report, such as the
+// output of a saved query
+func (a *reportingAgent) GenerateCustomReport(ctx context.Context, title string, changes []models.FileChange, reportType models.ReportType) error {
+	if a.State() != lifecycle.StateRunning {
+		return fmt.Errorf("reporting agent is not running")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context cancelled: %w", err)
+	}
+
+	if len(changes) == 0 {
+		return nil // No changes to report
+	}
+
+	report, err := a.reporter.GenerateReport(ctx, changes, reportType)
+	if err != nil {
+		return fmt.Errorf("failed to generate %s report: %w", reportType, err)
+	}
+	report.Title = title
+
+	if err := a.reporter.SendReport(ctx, report); err != nil {
+		return fmt.Errorf("failed to send %s report: %w", reportType, err)
+	}
+
+	return nil
+}
+
+// PreviewReport renders a report for file changes without sending it
+func (a *reportingAgent) PreviewReport(ctx context.Context, changes []models.FileChange, reportType models.ReportType) (*models.Report, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("context cancelled: %w", err)
+	}
+
+	report, err := a.reporter.GenerateReport(ctx, changes, reportType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s report: %w", reportType, err)
+	}
+
+	return report, nil
+}
+
 // Initialize implements lifecycle.Component
 func (a *reportingAgent) Initialize(ctx context.Context) error {
 	currentState := a.State()
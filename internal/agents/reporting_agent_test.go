@@ -140,3 +140,49 @@ func TestReportingAgent_GenerateReport(t *testing.T) {
 		})
 	}
 }
+
+func TestReportingAgent_PreviewReport(t *testing.T) {
+	notifier := &mockNotifier{}
+	agent, err := NewReportingAgent(notifier)
+	require.NoError(t, err)
+
+	changes := []models.FileChange{
+		{
+			Path:      "/test/file1.txt",
+			Extension: ".txt",
+			Directory: "/test",
+			ModTime:   time.Now(),
+			Size:      1024,
+		},
+	}
+
+	report, err := agent.PreviewReport(context.Background(), changes, models.FileListReport)
+	require.NoError(t, err)
+	assert.Contains(t, report.Metadata["content"], "/test/file1.txt")
+	assert.Equal(t, 0, notifier.sentMessages) // Previews are never sent
+
+	_, err = agent.PreviewReport(context.Background(), changes, models.ReportType("unknown"))
+	assert.Error(t, err)
+}
+
+func TestReportingAgent_GenerateCustomReport(t *testing.T) {
+	notifier := &mockNotifier{}
+	agent, err := NewReportingAgent(notifier)
+	require.NoError(t, err)
+	require.NoError(t, agent.Start(context.Background()))
+
+	changes := []models.FileChange{
+		{Path: "/DB/schema.sql", Extension: ".sql", Directory: "/DB", ModTime: time.Now(), Size: 512},
+	}
+
+	err = agent.GenerateCustomReport(context.Background(), "Weekly SQL changes", changes, models.FileListReport)
+	require.NoError(t, err)
+	assert.Equal(t, 1, notifier.sentMessages)
+	assert.Contains(t, notifier.lastMessage, "Weekly SQL changes")
+	assert.Contains(t, notifier.lastMessage, "/DB/schema.sql")
+
+	// Empty results are not sent
+	err = agent.GenerateCustomReport(context.Background(), "Weekly SQL changes", nil, models.FileListReport)
+	require.NoError(t, err)
+	assert.Equal(t, 1, notifier.sentMessages)
+}
@@ -7,12 +7,6 @@ import (
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
 )
 
-// StateManager defines the interface for state management
-type StateManager interface {
-	GetString(key string) string
-	SetString(key, value string) error
-}
-
 // FileChangeHandler is a function that handles file changes
 type FileChangeHandler func(context.Context, []models.FileChange) error
 
@@ -1,50 +1,223 @@
 package analysis
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"io"
+	"log/slog"
 	"mime"
 	"net/http"
 	"path/filepath"
+	"strings"
 
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/extract"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
 )
 
 // ContentAnalyzer analyzes file content
 type ContentAnalyzer interface {
 	AnalyzeContent(ctx context.Context, path string, content []byte) (*models.FileContent, error)
+	AnalyzeStream(ctx context.Context, path string, r io.Reader) (*models.FileContent, error)
 }
 
-// contentAnalyzer implements the ContentAnalyzer interface
-type contentAnalyzer struct{}
+// ContentStreamer opens file content as a stream
+type ContentStreamer interface {
+	GetFileContentStream(ctx context.Context, path string) (io.ReadCloser, error)
+}
+
+// sniffLen is how much content http.DetectContentType considers
+const sniffLen = 512
+
+// maxExtractBytes is the largest file whose text is extracted; larger files
+// are analyzed without buffering them
+const maxExtractBytes = 32 << 20
+
+// DefaultPromptChars caps the document text sent to the AI provider when
+// no limit is configured
+const DefaultPromptChars = 12000
+
+// AnalyzerConfig holds the optional settings of a content analyzer
+type AnalyzerConfig struct {
+	// Provider summarizes extracted text and picks its keywords and topics;
+	// nil skips AI analysis
+	Provider Provider
+	// MaxChars truncates the text sent to the provider
+	MaxChars int
+	// Fetcher downloads the files analyzed by AnalyzeChange
+	Fetcher ContentFetcher
+	// MaxFileBytes skips files larger than this in AnalyzeChange
+	MaxFileBytes int64
+	// CacheSize is how many analyses AnalyzeChange keeps to avoid
+	// downloading a revision twice
+	CacheSize int
+}
+
+// contentAnalyzer implements the ContentAnalyzer and ChangeAnalyzer interfaces
+type contentAnalyzer struct {
+	config AnalyzerConfig
+	cache  *analysisCache
+	logger *slog.Logger
+}
 
 // NewContentAnalyzer creates a new content analyzer
 func NewContentAnalyzer() ContentAnalyzer {
-	return &contentAnalyzer{}
+	return NewContentAnalyzerWithConfig(AnalyzerConfig{})
+}
+
+// NewContentAnalyzerWithConfig creates a content analyzer with the given settings
+func NewContentAnalyzerWithConfig(cfg AnalyzerConfig) ContentAnalyzer {
+	if cfg.MaxChars <= 0 {
+		cfg.MaxChars = DefaultPromptChars
+	}
+	if cfg.MaxFileBytes <= 0 {
+		cfg.MaxFileBytes = DefaultAnalyzeMaxFileBytes
+	}
+	if cfg.CacheSize <= 0 {
+		cfg.CacheSize = DefaultAnalysisCacheSize
+	}
+	return &contentAnalyzer{config: cfg, cache: newAnalysisCache(cfg.CacheSize), logger: logging.Component(nil, "analysis")}
 }
 
 // AnalyzeContent analyzes the content of a file and returns metadata about it
 func (a *contentAnalyzer) AnalyzeContent(ctx context.Context, path string, content []byte) (*models.FileContent, error) {
+	return a.AnalyzeStream(ctx, path, bytes.NewReader(content))
+}
+
+// AnalyzeStream analyzes content read from r, holding only one chunk of it
+// in memory at a time. Documents with a text extractor are buffered, up to
+// maxExtractBytes, so their text can be extracted.
+func (a *contentAnalyzer) AnalyzeStream(ctx context.Context, path string, r io.Reader) (*models.FileContent, error) {
+	h := sha256.New()
+	head := make([]byte, 0, sniffLen)
+	buf := make([]byte, 32*1024)
+	var size int64
+	binary := false
+	var content []byte
+	extractable := extract.Default.Supports(path)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("context cancelled: %w", err)
+		}
+		n, err := r.Read(buf)
+		chunk := buf[:n]
+		h.Write(chunk)
+		size += int64(n)
+		if len(head) < sniffLen {
+			head = append(head, chunk[:min(n, sniffLen-len(head))]...)
+		}
+		if !binary && !isTextFile(chunk) {
+			binary = true
+		}
+		if extractable {
+			if size > maxExtractBytes {
+				extractable, content = false, nil
+			} else {
+				content = append(content, chunk...)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read content of %s: %w", path, err)
+		}
+	}
+
 	// Get file extension and MIME type
 	ext := filepath.Ext(path)
 	mimeType := mime.TypeByExtension(ext)
 	if mimeType == "" {
-		mimeType = http.DetectContentType(content)
+		mimeType = http.DetectContentType(head)
 	}
 
 	// Create file content analysis
 	analysis := &models.FileContent{
 		Path:         path,
 		ContentType:  mimeType,
-		Size:         int64(len(content)),
-		IsBinary:     !isTextFile(content),
-		ContentHash:  calculateHash(content),
+		Size:         size,
+		IsBinary:     binary,
+		ContentHash:  fmt.Sprintf("%x", h.Sum(nil)),
+	}
+
+	if extractable {
+		// Unreadable documents are still analyzed, just without their text
+		text, err := extract.Default.Extract(ctx, path, content)
+		if err != nil {
+			a.logger.Warn("Skipping text extraction", "path", path, "error", err)
+		}
+		analysis.Text = text
+	}
+
+	if a.config.Provider != nil && strings.TrimSpace(analysis.Text) != "" {
+		// Like extraction failures, provider failures leave the analysis without AI fields
+		if err := a.summarize(ctx, analysis); err != nil {
+			a.logger.Warn("Skipping AI analysis", "path", path, "error", err)
+		}
 	}
 
 	return analysis, nil
 }
 
+// summaryPrompt asks for the summary, keywords and topics of a document as JSON
+const summaryPrompt = `Summarize the document below from a monitored Dropbox folder.
+Reply with only a JSON object of the form
+{"summary": "one or two sentences", "keywords": ["up to 8 keywords"], "topics": ["up to 3 topics"]}
+
+File: %s
+
+%s`
+
+// summarize fills in the summary, keywords and topics of analysis from the
+// provider's reading of its text
+func (a *contentAnalyzer) summarize(ctx context.Context, analysis *models.FileContent) error {
+	text := analysis.Text
+	if runes := []rune(text); len(runes) > a.config.MaxChars {
+		text = string(runes[:a.config.MaxChars])
+	}
+
+	completion, err := a.config.Provider.Complete(ctx, fmt.Sprintf(summaryPrompt, analysis.Path, text))
+	if err != nil {
+		return err
+	}
+
+	// Models often wrap JSON in prose or code fences
+	reply := completion.Text
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return fmt.Errorf("%s replied without JSON", a.config.Provider.Name())
+	}
+	var result struct {
+		Summary  string   `json:"summary"`
+		Keywords []string `json:"keywords"`
+		Topics   []string `json:"topics"`
+	}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &result); err != nil {
+		return fmt.Errorf("failed to parse %s reply: %w", a.config.Provider.Name(), err)
+	}
+
+	analysis.Summary = strings.TrimSpace(result.Summary)
+	analysis.Keywords = result.Keywords
+	analysis.Topics = result.Topics
+	return nil
+}
+
+// AnalyzeFile streams the file at path from streamer into analyzer, so
+// files of any size can be analyzed without buffering them
+func AnalyzeFile(ctx context.Context, analyzer ContentAnalyzer, streamer ContentStreamer, path string) (*models.FileContent, error) {
+	stream, err := streamer.GetFileContentStream(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", path, err)
+	}
+	defer stream.Close()
+
+	return analyzer.AnalyzeStream(ctx, path, stream)
+}
+
 // isTextFile checks if the content appears to be text
 func isTextFile(content []byte) bool {
 	if len(content) == 0 {
@@ -60,10 +233,3 @@ func isTextFile(content []byte) bool {
 
 	return true
 }
-
-// calculateHash generates a hash of the content
-func calculateHash(content []byte) string {
-	h := sha256.New()
-	h.Write(content)
-	return fmt.Sprintf("%x", h.Sum(nil))
-}
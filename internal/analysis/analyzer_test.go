@@ -1,10 +1,18 @@
 package analysis
 
 import (
+	"bytes"
 	"context"
+	"io"
+	"strings"
 	"testing"
 
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/mocks"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestContentAnalyzer_AnalyzeContent(t *testing.T) {
@@ -62,3 +70,111 @@ func TestContentAnalyzer_AnalyzeContent(t *testing.T) {
 		})
 	}
 }
+
+func TestContentAnalyzer_AnalyzeStream(t *testing.T) {
+	analyzer := NewContentAnalyzer()
+
+	// A null byte far past the first chunk still marks the content as binary
+	content := append([]byte(strings.Repeat("a", 100*1024)), 0)
+	result, err := analyzer.AnalyzeStream(context.Background(), "data", bytes.NewReader(content))
+	require.NoError(t, err)
+	assert.True(t, result.IsBinary)
+	assert.Equal(t, int64(len(content)), result.Size)
+	assert.Equal(t, "text/plain; charset=utf-8", result.ContentType, "the type is sniffed from the first bytes")
+	assert.Equal(t, dropbox.SHA256(content), result.ContentHash)
+	assert.Empty(t, result.Text, "files without an extractor have no text")
+}
+
+func TestContentAnalyzer_ExtractsText(t *testing.T) {
+	analyzer := NewContentAnalyzer()
+
+	result, err := analyzer.AnalyzeContent(context.Background(), "/notes.md", []byte("# Notes\r\n\r\n\r\nDone  today\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "# Notes\n\nDone today", result.Text)
+
+	// Unreadable documents are analyzed without text
+	result, err = analyzer.AnalyzeContent(context.Background(), "/broken.docx", []byte("not a zip"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), result.Size)
+	assert.Empty(t, result.Text)
+}
+
+func TestAnalyzeFile(t *testing.T) {
+	client := &mocks.DropboxClient{}
+	client.On("GetFileContentStream", mock.Anything, "/notes.txt").
+		Return(io.NopCloser(strings.NewReader("Hello, World!")), nil)
+
+	result, err := AnalyzeFile(context.Background(), NewContentAnalyzer(), client, "/notes.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "/notes.txt", result.Path)
+	assert.Equal(t, int64(13), result.Size)
+	assert.False(t, result.IsBinary)
+	client.AssertExpectations(t)
+}
+
+func TestContentAnalyzer_Summarizes(t *testing.T) {
+	provider := &fakeProvider{reply: "```json\n{\"summary\": \" Travel budget. \", \"keywords\": [\"travel\", \"budget\"], \"topics\": [\"finance\"]}\n```"}
+	analyzer := NewContentAnalyzerWithConfig(AnalyzerConfig{Provider: provider, MaxChars: 10})
+	ctx := context.Background()
+
+	result, err := analyzer.AnalyzeContent(ctx, "/docs/budget.txt", []byte("Travel budget for the field trip"))
+	require.NoError(t, err)
+	assert.Equal(t, "Travel budget.", result.Summary)
+	assert.Equal(t, []string{"travel", "budget"}, result.Keywords)
+	assert.Equal(t, []string{"finance"}, result.Topics)
+	require.Len(t, provider.prompts, 1)
+	assert.Contains(t, provider.prompts[0], "File: /docs/budget.txt")
+	assert.Contains(t, provider.prompts[0], "Travel bud")
+	assert.NotContains(t, provider.prompts[0], "field trip", "text is truncated")
+
+	// Provider failures and files without text leave the AI fields empty
+	provider.reply = "I cannot help with that"
+	result, err = analyzer.AnalyzeContent(ctx, "/docs/notes.txt", []byte("Notes"))
+	require.NoError(t, err)
+	assert.Empty(t, result.Summary)
+	assert.Equal(t, "Notes", result.Text)
+
+	_, err = analyzer.AnalyzeContent(ctx, "/docs/photo.jpg", []byte{0xff, 0xd8, 0x00})
+	require.NoError(t, err)
+	assert.Len(t, provider.prompts, 2)
+}
+
+func TestContentAnalyzer_AnalyzeChange(t *testing.T) {
+	fetcher := &contentFetcher{files: map[string]string{
+		"/notes.txt": "Hello, World!",
+		"/big.txt":   strings.Repeat("x", 64),
+	}}
+	analyzer := NewContentAnalyzerWithConfig(AnalyzerConfig{Fetcher: fetcher, MaxFileBytes: 32, CacheSize: 1}).(ChangeAnalyzer)
+	ctx := context.Background()
+	notes := models.FileChange{Path: "/notes.txt", Size: 13, ContentHash: "rev1"}
+
+	result, err := analyzer.AnalyzeChange(ctx, notes)
+	require.NoError(t, err)
+	assert.Equal(t, int64(13), result.Size)
+	assert.Equal(t, "Hello, World!", result.Text)
+
+	result.Text = "changed by the caller"
+	result, err = analyzer.AnalyzeChange(ctx, notes)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, World!", result.Text, "cached analyses are copied")
+	assert.Equal(t, []string{"/notes.txt"}, fetcher.downloaded, "a revision is downloaded once")
+
+	notes.ContentHash = "rev2"
+	_, err = analyzer.AnalyzeChange(ctx, notes)
+	require.NoError(t, err)
+	assert.Len(t, fetcher.downloaded, 2, "a new revision is downloaded again")
+
+	_, err = analyzer.AnalyzeChange(ctx, models.FileChange{Path: "/big.txt", Size: 64})
+	assert.ErrorIs(t, err, ErrFileTooLarge)
+	_, err = analyzer.AnalyzeChange(ctx, models.FileChange{Path: "/big.txt", Size: 10})
+	assert.ErrorIs(t, err, ErrFileTooLarge, "the downloaded size is checked too")
+	_, err = analyzer.AnalyzeChange(ctx, models.FileChange{Path: "/gone.txt", IsDeleted: true})
+	assert.Error(t, err)
+	assert.Len(t, fetcher.downloaded, 3)
+
+	// With room for one entry, the second revision evicted the first
+	notes.ContentHash = "rev1"
+	_, err = analyzer.AnalyzeChange(ctx, notes)
+	require.NoError(t, err)
+	assert.Len(t, fetcher.downloaded, 4)
+}
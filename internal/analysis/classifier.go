@@ -0,0 +1,276 @@
+package analysis
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// ClassifierConfig holds the thresholds used to recognise change events
+type ClassifierConfig struct {
+	// ClusterGap is the longest pause between changes in the same burst
+	ClusterGap time.Duration
+	// BatchMinFiles is the number of files added in one burst that makes an upload batch
+	BatchMinFiles int
+	// BatchMinBytes is the number of bytes added in one burst that makes an upload batch
+	BatchMinBytes int64
+	// ReorgMinMoves is the number of moved files in one burst that makes a reorganization
+	ReorgMinMoves int
+	// RevisionMinChanges is the number of saves of one document that makes a revision cycle
+	RevisionMinChanges int
+}
+
+// DefaultClassifierConfig returns the default classifier thresholds
+func DefaultClassifierConfig() ClassifierConfig {
+	return ClassifierConfig{
+		ClusterGap:         10 * time.Minute,
+		BatchMinFiles:      20,
+		BatchMinBytes:      100 * 1024 * 1024,
+		ReorgMinMoves:      3,
+		RevisionMinChanges: 3,
+	}
+}
+
+// documentExtensions are the file types considered for revision cycles
+var documentExtensions = map[string]bool{
+	".doc": true, ".docx": true, ".odt": true, ".rtf": true, ".pages": true,
+	".xls": true, ".xlsx": true, ".ods": true, ".csv": true, ".numbers": true,
+	".ppt": true, ".pptx": true, ".odp": true, ".key": true,
+	".pdf": true, ".txt": true, ".md": true, ".tex": true,
+}
+
+// revisionSuffix matches version markers such as "_v2", " final" or " (1)"
+var revisionSuffix = regexp.MustCompile(`[\s_.-]*(v\d+|rev\d+|version\s*\d+|final|draft|copy|\(\d+\))$`)
+
+// Classifier groups raw file changes into higher-level events
+type Classifier struct {
+	config ClassifierConfig
+}
+
+// NewClassifier creates a classifier; zero thresholds use the defaults
+func NewClassifier(cfg ClassifierConfig) *Classifier {
+	defaults := DefaultClassifierConfig()
+	if cfg.ClusterGap <= 0 {
+		cfg.ClusterGap = defaults.ClusterGap
+	}
+	if cfg.BatchMinFiles <= 0 {
+		cfg.BatchMinFiles = defaults.BatchMinFiles
+	}
+	if cfg.BatchMinBytes <= 0 {
+		cfg.BatchMinBytes = defaults.BatchMinBytes
+	}
+	if cfg.ReorgMinMoves <= 0 {
+		cfg.ReorgMinMoves = defaults.ReorgMinMoves
+	}
+	if cfg.RevisionMinChanges <= 0 {
+		cfg.RevisionMinChanges = defaults.RevisionMinChanges
+	}
+	return &Classifier{config: cfg}
+}
+
+// Classify returns the events recognised in the changes, ordered by start
+// time. Changes that fit no pattern are left out.
+func (c *Classifier) Classify(changes []models.FileChange) []models.ChangeEvent {
+	sorted := append([]models.FileChange(nil), changes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return changeTime(sorted[i]).Before(changeTime(sorted[j]))
+	})
+
+	var events []models.ChangeEvent
+	for _, burst := range c.clusters(sorted) {
+		moved := make(map[int]bool)
+		if event, ok := c.reorganization(burst, moved); ok {
+			events = append(events, event)
+		}
+		if event, ok := c.uploadBatch(burst, moved); ok {
+			events = append(events, event)
+		}
+	}
+	events = append(events, c.revisionCycles(sorted)...)
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Start.Before(events[j].Start)
+	})
+	return events
+}
+
+// clusters splits time-ordered changes wherever the gap exceeds ClusterGap
+func (c *Classifier) clusters(changes []models.FileChange) [][]models.FileChange {
+	var clusters [][]models.FileChange
+	start := 0
+	for i := 1; i <= len(changes); i++ {
+		if i == len(changes) || changeTime(changes[i]).Sub(changeTime(changes[i-1])) > c.config.ClusterGap {
+			clusters = append(clusters, changes[start:i])
+			start = i
+		}
+	}
+	return clusters
+}
+
+// reorganization pairs deletions with additions of the same file name in a
+// different folder, recording the paired changes in moved
+func (c *Classifier) reorganization(burst []models.FileChange, moved map[int]bool) (models.ChangeEvent, bool) {
+	deleted := make(map[string][]int)
+	for i, change := range burst {
+		if change.IsDeleted {
+			name := strings.ToLower(path.Base(change.Path))
+			deleted[name] = append(deleted[name], i)
+		}
+	}
+
+	var pairs [][2]int
+	for i, change := range burst {
+		if change.IsDeleted {
+			continue
+		}
+		name := strings.ToLower(path.Base(change.Path))
+		for k, j := range deleted[name] {
+			if !strings.EqualFold(path.Dir(burst[j].Path), path.Dir(change.Path)) {
+				pairs = append(pairs, [2]int{j, i})
+				deleted[name] = append(deleted[name][:k], deleted[name][k+1:]...)
+				break
+			}
+		}
+	}
+	if len(pairs) < c.config.ReorgMinMoves {
+		return models.ChangeEvent{}, false
+	}
+
+	from := make(map[string]int)
+	to := make(map[string]int)
+	event := models.ChangeEvent{Kind: models.FolderReorganizationEvent}
+	for _, pair := range pairs {
+		moved[pair[0]], moved[pair[1]] = true, true
+		from[path.Dir(burst[pair[0]].Path)]++
+		to[path.Dir(burst[pair[1]].Path)]++
+		event.Paths = append(event.Paths, burst[pair[1]].Path)
+		event.Bytes += burst[pair[1]].Size
+		extend(&event, burst[pair[0]])
+		extend(&event, burst[pair[1]])
+	}
+
+	event.Summary = fmt.Sprintf("Folder reorganization: %d files moved from %s to %s",
+		len(pairs), describeFolders(from), describeFolders(to))
+	return event, true
+}
+
+// uploadBatch reports a burst of additions that are not part of a move
+func (c *Classifier) uploadBatch(burst []models.FileChange, moved map[int]bool) (models.ChangeEvent, bool) {
+	event := models.ChangeEvent{Kind: models.UploadBatchEvent}
+	folders := make(map[string]int)
+	for i, change := range burst {
+		if change.IsDeleted || moved[i] {
+			continue
+		}
+		event.Paths = append(event.Paths, change.Path)
+		event.Bytes += change.Size
+		folders[path.Dir(change.Path)]++
+		extend(&event, change)
+	}
+
+	count := len(event.Paths)
+	if count < c.config.BatchMinFiles && (count < 2 || event.Bytes < c.config.BatchMinBytes) {
+		return models.ChangeEvent{}, false
+	}
+
+	event.Summary = fmt.Sprintf("Large upload batch: %d files (%.1f MB) added to %s between %s and %s",
+		count, float64(event.Bytes)/(1024*1024), describeFolders(folders),
+		event.Start.Format("15:04"), event.End.Format("15:04"))
+	return event, true
+}
+
+// revisionCycles finds documents saved repeatedly, either under the same
+// name or as numbered or "final" variants in the same folder
+func (c *Classifier) revisionCycles(changes []models.FileChange) []models.ChangeEvent {
+	groups := make(map[string][]models.FileChange)
+	var keys []string
+	for _, change := range changes {
+		ext := strings.ToLower(path.Ext(change.Path))
+		if change.IsDeleted || !documentExtensions[ext] {
+			continue
+		}
+		key := strings.ToLower(path.Dir(change.Path)) + "/" + documentStem(change.Path) + ext
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], change)
+	}
+
+	var events []models.ChangeEvent
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < c.config.RevisionMinChanges {
+			continue
+		}
+
+		event := models.ChangeEvent{Kind: models.RevisionCycleEvent}
+		seen := make(map[string]bool)
+		for _, change := range group {
+			if !seen[change.Path] {
+				seen[change.Path] = true
+				event.Paths = append(event.Paths, change.Path)
+			}
+			event.Bytes += change.Size
+			extend(&event, change)
+		}
+
+		latest := group[len(group)-1].Path
+		event.Summary = fmt.Sprintf("Document revision cycle: %s in %s revised %d times between %s and %s",
+			path.Base(latest), path.Dir(latest), len(group),
+			event.Start.Format("15:04"), event.End.Format("15:04"))
+		events = append(events, event)
+	}
+	return events
+}
+
+// extend widens the event's time range to include the change
+func extend(event *models.ChangeEvent, change models.FileChange) {
+	t := changeTime(change)
+	if event.Start.IsZero() || t.Before(event.Start) {
+		event.Start = t
+	}
+	if t.After(event.End) {
+		event.End = t
+	}
+}
+
+// changeTime returns when the change happened
+func changeTime(change models.FileChange) time.Time {
+	if change.Modified.IsZero() {
+		return change.ModTime
+	}
+	return change.Modified
+}
+
+// documentStem returns the lowercased file name without its extension and
+// version markers, so "Report_v2.docx" and "report final.docx" share a stem
+func documentStem(filePath string) string {
+	base := strings.ToLower(path.Base(filePath))
+	stem := strings.TrimSuffix(base, path.Ext(base))
+	for {
+		trimmed := revisionSuffix.ReplaceAllString(stem, "")
+		if trimmed == stem || trimmed == "" {
+			return stem
+		}
+		stem = trimmed
+	}
+}
+
+// describeFolders names the busiest folder, noting how many others were involved
+func describeFolders(counts map[string]int) string {
+	busiest := ""
+	for folder, count := range counts {
+		if busiest == "" || count > counts[busiest] || (count == counts[busiest] && folder < busiest) {
+			busiest = folder
+		}
+	}
+	if len(counts) > 1 {
+		return fmt.Sprintf("%s and %d other folders", busiest, len(counts)-1)
+	}
+	return busiest
+}
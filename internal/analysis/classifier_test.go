@@ -0,0 +1,86 @@
+package analysis
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var classifierBase = time.Date(2025, 2, 12, 9, 0, 0, 0, time.UTC)
+
+func change(path string, minutes int, size int64, deleted bool) models.FileChange {
+	return models.FileChange{
+		Path:      path,
+		Modified:  classifierBase.Add(time.Duration(minutes) * time.Minute),
+		Size:      size,
+		IsDeleted: deleted,
+	}
+}
+
+func TestClassifier_UploadBatch(t *testing.T) {
+	var changes []models.FileChange
+	for i := 0; i < 25; i++ {
+		changes = append(changes, change(fmt.Sprintf("/Photos/img%02d.jpg", i), i/5, 1024*1024, false))
+	}
+	// A lone edit hours later is not part of the batch
+	changes = append(changes, change("/Notes/todo.txt", 240, 10, false))
+
+	events := NewClassifier(DefaultClassifierConfig()).Classify(changes)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.UploadBatchEvent, events[0].Kind)
+	assert.Len(t, events[0].Paths, 25)
+	assert.Equal(t, "Large upload batch: 25 files (25.0 MB) added to /Photos between 09:00 and 09:04", events[0].Summary)
+}
+
+func TestClassifier_FolderReorganization(t *testing.T) {
+	changes := []models.FileChange{
+		change("/Old/a.txt", 0, 1, true),
+		change("/Old/b.txt", 0, 1, true),
+		change("/Old/c.txt", 1, 1, true),
+		change("/New/A.txt", 1, 1, false),
+		change("/New/b.txt", 1, 1, false),
+		change("/New/c.txt", 2, 1, false),
+		change("/Old/untouched.txt", 2, 1, true),
+	}
+
+	events := NewClassifier(DefaultClassifierConfig()).Classify(changes)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.FolderReorganizationEvent, events[0].Kind)
+	assert.Equal(t, "Folder reorganization: 3 files moved from /Old to /New", events[0].Summary)
+}
+
+func TestClassifier_RevisionCycle(t *testing.T) {
+	changes := []models.FileChange{
+		change("/Work/Proposal.docx", 0, 100, false),
+		change("/Work/Proposal_v2.docx", 60, 100, false),
+		change("/Work/proposal final.docx", 180, 100, false),
+		change("/Work/budget.xlsx", 30, 100, false),
+		change("/Other/Proposal.docx", 90, 100, false),
+	}
+
+	events := NewClassifier(DefaultClassifierConfig()).Classify(changes)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.RevisionCycleEvent, events[0].Kind)
+	assert.Len(t, events[0].Paths, 3)
+	assert.Equal(t, "Document revision cycle: proposal final.docx in /Work revised 3 times between 09:00 and 12:00", events[0].Summary)
+}
+
+func TestClassifier_NoEvents(t *testing.T) {
+	changes := []models.FileChange{
+		change("/a.txt", 0, 1, false),
+		change("/b.txt", 30, 1, true),
+	}
+	assert.Empty(t, NewClassifier(ClassifierConfig{}).Classify(changes))
+	assert.Empty(t, NewClassifier(ClassifierConfig{}).Classify(nil))
+}
+
+func TestDocumentStem(t *testing.T) {
+	for _, name := range []string{"/Report.docx", "/report_v2.docx", "/Report - Final.docx", "/report (1).docx", "/report v3 draft.docx"} {
+		assert.Equal(t, "report", documentStem(name), name)
+	}
+	assert.Equal(t, "final", documentStem("/final.docx"))
+}
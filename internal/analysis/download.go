@@ -0,0 +1,132 @@
+package analysis
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+const (
+	// DefaultAnalyzeMaxFileBytes skips files larger than this when no limit
+	// is configured
+	DefaultAnalyzeMaxFileBytes = maxExtractBytes
+	// DefaultAnalysisCacheSize is how many analyses are kept when no cache
+	// size is configured
+	DefaultAnalysisCacheSize = 256
+)
+
+// ErrFileTooLarge is returned for files beyond the analyzer's size limit
+var ErrFileTooLarge = errors.New("file too large to analyze")
+
+// ChangeAnalyzer analyzes the current revision of changed files
+type ChangeAnalyzer interface {
+	AnalyzeChange(ctx context.Context, change models.FileChange) (*models.FileContent, error)
+}
+
+// AnalyzeChange downloads the changed file through the configured fetcher
+// and analyzes it. Each revision is downloaded only once: later calls for the
+// same path and content hash return the cached analysis.
+func (a *contentAnalyzer) AnalyzeChange(ctx context.Context, change models.FileChange) (*models.FileContent, error) {
+	if a.config.Fetcher == nil {
+		return nil, fmt.Errorf("no content fetcher configured")
+	}
+	if change.IsDeleted {
+		return nil, fmt.Errorf("%s has been deleted", change.Path)
+	}
+	if change.Size > a.config.MaxFileBytes {
+		return nil, fmt.Errorf("%s is %d bytes: %w", change.Path, change.Size, ErrFileTooLarge)
+	}
+
+	key := revisionKey(change)
+	if analysis, ok := a.cache.get(key); ok {
+		return analysis, nil
+	}
+
+	content, err := a.config.Fetcher.GetFileContent(ctx, change.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", change.Path, err)
+	}
+	// The size in the change may be stale by the time the file is downloaded
+	if int64(len(content)) > a.config.MaxFileBytes {
+		return nil, fmt.Errorf("%s is %d bytes: %w", change.Path, len(content), ErrFileTooLarge)
+	}
+
+	analysis, err := a.AnalyzeContent(ctx, change.Path, content)
+	if err != nil {
+		return nil, err
+	}
+	a.cache.put(key, analysis)
+	return cloneContent(analysis), nil
+}
+
+// revisionKey identifies the revision of a change by its path and Dropbox
+// content hash, falling back to its size and modification time
+func revisionKey(change models.FileChange) string {
+	if change.ContentHash != "" {
+		return change.Path + "\x00" + change.ContentHash
+	}
+	return change.Path + "\x00" + strconv.FormatInt(change.Size, 10) + "\x00" + change.Modified.UTC().String()
+}
+
+// analysisCache keeps the most recently used analyses
+type analysisCache struct {
+	size int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// cacheEntry is an analysis in the cache with its key
+type cacheEntry struct {
+	key      string
+	analysis *models.FileContent
+}
+
+// newAnalysisCache creates a cache holding up to size analyses
+func newAnalysisCache(size int) *analysisCache {
+	return &analysisCache{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// get returns a copy of the analysis cached under key
+func (c *analysisCache) get(key string) (*models.FileContent, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return cloneContent(element.Value.(*cacheEntry).analysis), true
+}
+
+// put caches analysis under key, evicting the least recently used entry
+// when the cache is full
+func (c *analysisCache) put(key string, analysis *models.FileContent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*cacheEntry).analysis = analysis
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, analysis: analysis})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// cloneContent copies an analysis so callers cannot change a cached one
+func cloneContent(analysis *models.FileContent) *models.FileContent {
+	clone := *analysis
+	clone.Keywords = append([]string(nil), analysis.Keywords...)
+	clone.Topics = append([]string(nil), analysis.Topics...)
+	return &clone
+}
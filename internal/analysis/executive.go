@@ -0,0 +1,172 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/i18n"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// executiveTopItems is the number of extensions, directories, authors and
+// topics described to the provider
+const executiveTopItems = 5
+
+// executiveTopKeywords is the number of keywords described to the provider
+const executiveTopKeywords = 10
+
+// executiveMaxDiffs caps the document changes described to the provider
+const executiveMaxDiffs = 10
+
+// executivePrompt asks for an executive summary of the change statistics
+const executivePrompt = `Write an executive summary of 3 to 5 sentences for the report on the changes
+below, made to a monitored Dropbox account. Say what kind of work the changes point to and
+call out anything that needs attention, such as many deletions or security events. Use only
+the statistics given, in plain prose without headings, lists or Markdown. Write in %s.
+
+%s`
+
+// ExecutiveSummarizer asks an AI provider for an executive summary of the
+// changes in a report
+type ExecutiveSummarizer struct {
+	provider   Provider
+	classifier *Classifier
+}
+
+// NewExecutiveSummarizer creates a summarizer asking provider, which describes
+// the change events recognised by the default classifier
+func NewExecutiveSummarizer(provider Provider) (*ExecutiveSummarizer, error) {
+	if provider == nil {
+		return nil, fmt.Errorf("provider cannot be nil")
+	}
+	return &ExecutiveSummarizer{provider: provider, classifier: NewClassifier(DefaultClassifierConfig())}, nil
+}
+
+// executiveStats are the change statistics sent to the provider
+type executiveStats struct {
+	TotalChanges   int            `json:"total_changes"`
+	Modified       int            `json:"modified"`
+	Renamed        int            `json:"renamed"`
+	Deleted        int            `json:"deleted"`
+	TotalMB        float64        `json:"total_mb"`
+	TopExtensions  map[string]int `json:"top_extensions,omitempty"`
+	TopDirectories map[string]int `json:"top_directories,omitempty"`
+	TopAuthors     map[string]int `json:"top_authors,omitempty"`
+	FileTypes      map[string]int `json:"file_types,omitempty"`
+	TopKeywords    map[string]int `json:"top_keywords,omitempty"`
+	TopTopics      map[string]int `json:"top_topics,omitempty"`
+	Categories     map[string]int `json:"categories,omitempty"`
+	Highlights     []string       `json:"highlights,omitempty"`
+	DocumentDiffs  []string       `json:"document_changes,omitempty"`
+	SecurityEvents map[string]int `json:"security_events,omitempty"`
+	MonitoringGaps int            `json:"monitoring_gaps,omitempty"`
+}
+
+// Summarize returns an executive summary of the changes in report, written
+// in the report's language
+func (s *ExecutiveSummarizer) Summarize(ctx context.Context, report *models.Report) (string, error) {
+	if report == nil || len(report.Changes) == 0 {
+		return "", fmt.Errorf("report has no changes to summarize")
+	}
+
+	stats, err := json.MarshalIndent(s.stats(report), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal change statistics: %w", err)
+	}
+	language := i18n.Lookup(report.Language).T("language.name")
+	completion, err := s.provider.Complete(ctx, fmt.Sprintf(executivePrompt, language, stats))
+	if err != nil {
+		return "", err
+	}
+
+	// Models sometimes break the prose over lines; the report wraps it itself
+	summary := strings.Join(strings.Fields(completion.Text), " ")
+	if summary == "" {
+		return "", fmt.Errorf("%s replied with an empty summary", s.provider.Name())
+	}
+	return summary, nil
+}
+
+// stats collects the change statistics of report
+func (s *ExecutiveSummarizer) stats(report *models.Report) executiveStats {
+	stats := executiveStats{
+		TopExtensions:  topCounts(report.ExtensionCount, report.GetTopExtensions(executiveTopItems)),
+		TopDirectories: topCounts(report.DirectoryCount, report.GetTopDirectories(executiveTopItems)),
+		FileTypes:      report.FileTypeCount,
+		TopKeywords:    topCounts(report.KeywordCount, report.GetTopKeywords(executiveTopKeywords)),
+		TopTopics:      topCounts(report.TopicCount, report.GetTopTopics(executiveTopItems)),
+		Categories:     report.CategoryCount,
+		MonitoringGaps: len(report.Gaps),
+	}
+
+	var size int64
+	authors := make(map[string]int)
+	for _, change := range report.Changes {
+		stats.TotalChanges++
+		size += change.Size
+		switch change.Type() {
+		case models.ChangeDeleted:
+			stats.Deleted++
+		case models.ChangeRenamed:
+			stats.Renamed++
+		default:
+			stats.Modified++
+		}
+		if change.ModifiedBy != "" {
+			authors[change.ModifiedBy]++
+		}
+	}
+	stats.TotalMB = math.Round(float64(size)/(1<<20)*100) / 100
+	stats.TopAuthors = topCounts(authors, topKeys(authors, executiveTopItems))
+
+	for _, event := range s.classifier.Classify(report.Changes) {
+		stats.Highlights = append(stats.Highlights, event.Summary)
+	}
+	for i, diff := range report.Diffs {
+		if i == executiveMaxDiffs {
+			break
+		}
+		stats.DocumentDiffs = append(stats.DocumentDiffs, diff.Path+": "+diff.String())
+	}
+	if len(report.SecurityEvents) > 0 {
+		stats.SecurityEvents = make(map[string]int)
+		for _, event := range report.SecurityEvents {
+			stats.SecurityEvents[event.Type]++
+		}
+	}
+	return stats
+}
+
+// topCounts returns the counts of the given keys
+func topCounts(counts map[string]int, keys []string) map[string]int {
+	if len(keys) == 0 {
+		return nil
+	}
+	top := make(map[string]int, len(keys))
+	for _, key := range keys {
+		top[key] = counts[key]
+	}
+	return top
+}
+
+// topKeys returns the n keys with the highest counts, ties in name order
+func topKeys(counts map[string]int, n int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
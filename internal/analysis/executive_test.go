@@ -0,0 +1,66 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutiveSummarizer_Summarize(t *testing.T) {
+	provider := &fakeProvider{reply: "Work focused on the budget.\n\nTwo files were removed."}
+	summarizer, err := NewExecutiveSummarizer(provider)
+	require.NoError(t, err)
+
+	report := models.NewReport(models.NarrativeReport)
+	report.Language = "nl"
+	report.AddChange(models.FileChange{Path: "/finance/budget.xlsx", Directory: "/finance", Extension: ".xlsx", Size: 3 << 20, ModifiedBy: "Ann"})
+	report.AddChange(models.FileChange{Path: "/finance/old.txt", Directory: "/finance", Extension: ".txt", IsDeleted: true})
+	report.AddChange(models.FileChange{Path: "/notes/a.txt", Directory: "/notes", Extension: ".txt", ModifiedBy: "Ann"})
+	report.SecurityEvents = []models.TeamEvent{{Type: "login_fail"}, {Type: "login_fail"}}
+
+	summary, err := summarizer.Summarize(context.Background(), report)
+	require.NoError(t, err)
+	assert.Equal(t, "Work focused on the budget. Two files were removed.", summary)
+
+	require.Len(t, provider.prompts, 1)
+	prompt := provider.prompts[0]
+	assert.Contains(t, prompt, "Write in Nederlands.")
+
+	var stats executiveStats
+	require.NoError(t, json.Unmarshal([]byte(prompt[strings.Index(prompt, "{"):]), &stats))
+	assert.Equal(t, 3, stats.TotalChanges)
+	assert.Equal(t, 2, stats.Modified)
+	assert.Equal(t, 1, stats.Deleted)
+	assert.Equal(t, 3.0, stats.TotalMB)
+	assert.Equal(t, map[string]int{"/finance": 2, "/notes": 1}, stats.TopDirectories)
+	assert.Equal(t, map[string]int{"Ann": 2}, stats.TopAuthors)
+	assert.Equal(t, map[string]int{"login_fail": 2}, stats.SecurityEvents)
+}
+
+func TestExecutiveSummarizer_Failures(t *testing.T) {
+	_, err := NewExecutiveSummarizer(nil)
+	assert.Error(t, err)
+
+	provider := &fakeProvider{reply: "  \n"}
+	summarizer, err := NewExecutiveSummarizer(provider)
+	require.NoError(t, err)
+
+	report := models.NewReport(models.NarrativeReport)
+	_, err = summarizer.Summarize(context.Background(), report)
+	assert.Error(t, err, "reports without changes are not summarized")
+	assert.Empty(t, provider.prompts)
+
+	report.AddChange(models.FileChange{Path: "/a.txt"})
+	_, err = summarizer.Summarize(context.Background(), report)
+	assert.Error(t, err, "empty replies are rejected")
+
+	provider.err = errors.New("quota exceeded")
+	_, err = summarizer.Summarize(context.Background(), report)
+	assert.ErrorIs(t, err, provider.err)
+}
@@ -0,0 +1,197 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AI providers
+const (
+	ProviderGemini    = "gemini"
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderOllama    = "ollama"
+)
+
+// DefaultMaxOutputTokens caps the length of each reply when none is configured
+const DefaultMaxOutputTokens = 512
+
+// defaultRequestsPerMinute keeps each hosted provider within the rate limits
+// of its entry-level tier; local models are not limited
+var defaultRequestsPerMinute = map[string]int{
+	ProviderGemini:    15,
+	ProviderOpenAI:    60,
+	ProviderAnthropic: 50,
+}
+
+// ErrTokenBudgetExceeded is returned when a request would go beyond the
+// daily token budget of a provider
+var ErrTokenBudgetExceeded = errors.New("daily token budget exceeded")
+
+// Completion is a model's reply to a prompt with the tokens it used
+type Completion struct {
+	Text         string
+	InputTokens  int
+	OutputTokens int
+}
+
+// Provider generates text with a language model
+type Provider interface {
+	Complete(ctx context.Context, prompt string) (Completion, error)
+	// Name names the provider and model
+	Name() string
+}
+
+// ProviderConfig selects the AI provider and limits its use
+type ProviderConfig struct {
+	// Provider is gemini, openai, anthropic or ollama
+	Provider string
+	// Model overrides the provider's default model
+	Model string
+	// URL overrides the provider's API address
+	URL string
+	// APIKey authenticates with hosted providers
+	APIKey string
+	// MaxOutputTokens caps the length of each reply
+	MaxOutputTokens int
+	// RequestsPerMinute spaces out requests; zero uses the provider's
+	// default and a negative value disables the limit
+	RequestsPerMinute int
+	// DailyTokenBudget caps the tokens used per day; zero is unlimited
+	DailyTokenBudget int
+}
+
+// NewProvider creates the configured provider, limited to its request rate
+// and token budget
+func NewProvider(cfg ProviderConfig) (Provider, error) {
+	if cfg.MaxOutputTokens <= 0 {
+		cfg.MaxOutputTokens = DefaultMaxOutputTokens
+	}
+
+	var provider Provider
+	var err error
+	switch cfg.Provider {
+	case ProviderGemini:
+		provider, err = NewGemini(cfg)
+	case ProviderOpenAI:
+		provider, err = NewOpenAI(cfg)
+	case ProviderAnthropic:
+		provider, err = NewAnthropic(cfg)
+	case ProviderOllama:
+		provider = NewOllama(cfg)
+	default:
+		return nil, fmt.Errorf("unknown AI provider %q", cfg.Provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rpm := cfg.RequestsPerMinute
+	if rpm == 0 {
+		rpm = defaultRequestsPerMinute[cfg.Provider]
+	}
+	return NewLimitedProvider(provider, rpm, cfg.DailyTokenBudget), nil
+}
+
+// LimitedProvider spaces out the requests to a provider and stops sending
+// them once its daily token budget is used up
+type LimitedProvider struct {
+	Provider
+	interval time.Duration
+	budget   int
+	now      func() time.Time
+
+	mu   sync.Mutex
+	next time.Time
+	day  time.Time
+	used int
+}
+
+// NewLimitedProvider limits provider to requestsPerMinute and budget tokens
+// per day; zero or negative values disable either limit
+func NewLimitedProvider(provider Provider, requestsPerMinute, budget int) *LimitedProvider {
+	l := &LimitedProvider{Provider: provider, budget: budget, now: time.Now}
+	if requestsPerMinute > 0 {
+		l.interval = time.Minute / time.Duration(requestsPerMinute)
+	}
+	return l
+}
+
+// Complete waits for the next request slot and sends the prompt if the
+// day's budget has room for it
+func (l *LimitedProvider) Complete(ctx context.Context, prompt string) (Completion, error) {
+	wait, err := l.reserve(estimateTokens(prompt))
+	if err != nil {
+		return Completion{}, err
+	}
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return Completion{}, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	completion, err := l.Provider.Complete(ctx, prompt)
+	if err != nil {
+		return completion, err
+	}
+
+	// Providers that do not report usage are charged an estimate
+	used := completion.InputTokens + completion.OutputTokens
+	if used == 0 {
+		used = estimateTokens(prompt) + estimateTokens(completion.Text)
+	}
+	l.mu.Lock()
+	l.used += used
+	l.mu.Unlock()
+	return completion, nil
+}
+
+// TokensUsed returns the tokens used so far today
+func (l *LimitedProvider) TokensUsed() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.resetDay()
+	return l.used
+}
+
+// reserve checks the budget for a prompt of the given tokens and claims the
+// next request slot, returning how long to wait for it
+func (l *LimitedProvider) reserve(tokens int) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.resetDay()
+	if l.budget > 0 && l.used+tokens > l.budget {
+		return 0, fmt.Errorf("%s: %w (%d of %d tokens used)", l.Name(), ErrTokenBudgetExceeded, l.used, l.budget)
+	}
+
+	now := l.now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	return wait, nil
+}
+
+// resetDay starts a new budget at midnight. The caller holds l.mu.
+func (l *LimitedProvider) resetDay() {
+	now := l.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if !today.Equal(l.day) {
+		l.day = today
+		l.used = 0
+	}
+}
+
+// estimateTokens approximates the tokens of text at four characters each
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
@@ -0,0 +1,149 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProvider struct {
+	reply   string
+	err     error
+	prompts []string
+}
+
+func (p *fakeProvider) Complete(ctx context.Context, prompt string) (Completion, error) {
+	p.prompts = append(p.prompts, prompt)
+	return Completion{Text: p.reply, InputTokens: 100, OutputTokens: 20}, p.err
+}
+
+func (p *fakeProvider) Name() string {
+	return "fake/model"
+}
+
+func TestNewProvider(t *testing.T) {
+	provider, err := NewProvider(ProviderConfig{Provider: ProviderOllama})
+	require.NoError(t, err)
+	assert.Equal(t, "ollama/"+DefaultOllamaModel, provider.Name())
+	assert.Zero(t, provider.(*LimitedProvider).interval, "local models are not rate limited")
+
+	provider, err = NewProvider(ProviderConfig{Provider: ProviderGemini, APIKey: "key"})
+	require.NoError(t, err)
+	assert.Equal(t, 4*time.Second, provider.(*LimitedProvider).interval)
+
+	for _, name := range []string{ProviderGemini, ProviderOpenAI, ProviderAnthropic} {
+		_, err = NewProvider(ProviderConfig{Provider: name})
+		assert.Error(t, err, "%s requires an API key", name)
+	}
+	_, err = NewProvider(ProviderConfig{Provider: "oracle"})
+	assert.Error(t, err)
+}
+
+func TestHTTPProviders(t *testing.T) {
+	var request map[string]interface{}
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		switch r.URL.Path {
+		case "/models/" + DefaultGeminiModel + ":generateContent":
+			w.Write([]byte(`{"candidates": [{"content": {"parts": [{"text": "gem"}, {"text": "ini"}]}}],
+				"usageMetadata": {"promptTokenCount": 3, "candidatesTokenCount": 1}}`))
+		case "/v1/chat/completions":
+			w.Write([]byte(`{"choices": [{"message": {"content": "openai"}}], "usage": {"prompt_tokens": 4, "completion_tokens": 2}}`))
+		case "/v1/messages":
+			w.Write([]byte(`{"content": [{"type": "text", "text": "anthropic"}], "usage": {"input_tokens": 5, "output_tokens": 3}}`))
+		case "/api/generate":
+			w.Write([]byte(`{"response": "ollama", "prompt_eval_count": 6, "eval_count": 4}`))
+		default:
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	gemini, err := NewGemini(ProviderConfig{URL: server.URL, APIKey: "g-key"})
+	require.NoError(t, err)
+	completion, err := gemini.Complete(ctx, "hello")
+	require.NoError(t, err)
+	assert.Equal(t, Completion{Text: "gemini", InputTokens: 3, OutputTokens: 1}, completion)
+	assert.Equal(t, "g-key", header.Get("X-Goog-Api-Key"))
+
+	openai, err := NewOpenAI(ProviderConfig{URL: server.URL + "/v1/chat/completions", APIKey: "sk-test"})
+	require.NoError(t, err)
+	completion, err = openai.Complete(ctx, "hello")
+	require.NoError(t, err)
+	assert.Equal(t, Completion{Text: "openai", InputTokens: 4, OutputTokens: 2}, completion)
+	assert.Equal(t, "Bearer sk-test", header.Get("Authorization"))
+	assert.Equal(t, DefaultOpenAIModel, request["model"])
+
+	anthropic, err := NewAnthropic(ProviderConfig{URL: server.URL + "/v1/messages", APIKey: "a-key", MaxOutputTokens: 64})
+	require.NoError(t, err)
+	completion, err = anthropic.Complete(ctx, "hello")
+	require.NoError(t, err)
+	assert.Equal(t, Completion{Text: "anthropic", InputTokens: 5, OutputTokens: 3}, completion)
+	assert.Equal(t, "a-key", header.Get("X-Api-Key"))
+	assert.Equal(t, anthropicVersion, header.Get("Anthropic-Version"))
+	assert.Equal(t, float64(64), request["max_tokens"])
+
+	completion, err = NewOllama(ProviderConfig{URL: server.URL}).Complete(ctx, "hello")
+	require.NoError(t, err)
+	assert.Equal(t, Completion{Text: "ollama", InputTokens: 6, OutputTokens: 4}, completion)
+	assert.Equal(t, "hello", request["prompt"])
+
+	_, err = NewOllama(ProviderConfig{URL: server.URL + "/missing"}).Complete(ctx, "hello")
+	assert.ErrorContains(t, err, "status 503: overloaded")
+}
+
+func TestLimitedProvider(t *testing.T) {
+	fake := &fakeProvider{reply: "ok"}
+	limited := NewLimitedProvider(fake, 0, 240)
+	now := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	limited.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// Each request uses 120 tokens, so the budget has no room for a third
+	for i := 0; i < 2; i++ {
+		_, err := limited.Complete(ctx, "hello")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 240, limited.TokensUsed())
+	_, err := limited.Complete(ctx, "hello")
+	assert.ErrorIs(t, err, ErrTokenBudgetExceeded)
+	assert.Len(t, fake.prompts, 2)
+
+	// The budget starts over the next day
+	now = now.Add(2 * time.Hour)
+	assert.Zero(t, limited.TokensUsed())
+	_, err = limited.Complete(ctx, "hello")
+	assert.NoError(t, err)
+
+	// Failed requests are not charged
+	fake.err = errors.New("unavailable")
+	_, err = limited.Complete(ctx, "hello")
+	assert.Error(t, err)
+	assert.Equal(t, 120, limited.TokensUsed())
+}
+
+func TestLimitedProvider_RequestsPerMinute(t *testing.T) {
+	limited := NewLimitedProvider(&fakeProvider{}, 600, 0)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := limited.Complete(context.Background(), "hello")
+		require.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond, "requests are spaced 100ms apart")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limited.Complete(context.Background(), "hello")
+	_, err := limited.Complete(ctx, "hello")
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,249 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/jsonhttp"
+)
+
+// Default provider settings
+const (
+	DefaultGeminiModel    = "gemini-1.5-flash"
+	DefaultGeminiURL      = "https://generativelanguage.googleapis.com/v1beta"
+	DefaultOpenAIModel    = "gpt-4o-mini"
+	DefaultOpenAIURL      = "https://api.openai.com/v1/chat/completions"
+	DefaultAnthropicModel = "claude-3-5-haiku-latest"
+	DefaultAnthropicURL   = "https://api.anthropic.com/v1/messages"
+	DefaultOllamaModel    = "llama3.2"
+	DefaultOllamaURL      = "http://localhost:11434"
+	anthropicVersion      = "2023-06-01"
+	aiRequestTimeout      = 2 * time.Minute
+)
+
+// httpProvider holds what the HTTP providers share
+type httpProvider struct {
+	client    *http.Client
+	url       string
+	apiKey    string
+	model     string
+	maxTokens int
+	name      string
+}
+
+// newHTTPProvider applies the defaults for a provider to cfg
+func newHTTPProvider(cfg ProviderConfig, name, defaultURL, defaultModel string) httpProvider {
+	p := httpProvider{
+		client:    &http.Client{Timeout: aiRequestTimeout},
+		url:       strings.TrimRight(cfg.URL, "/"),
+		apiKey:    cfg.APIKey,
+		model:     cfg.Model,
+		maxTokens: cfg.MaxOutputTokens,
+		name:      name,
+	}
+	if p.url == "" {
+		p.url = defaultURL
+	}
+	if p.model == "" {
+		p.model = defaultModel
+	}
+	if p.maxTokens <= 0 {
+		p.maxTokens = DefaultMaxOutputTokens
+	}
+	return p
+}
+
+// Name returns the provider and model
+func (p httpProvider) Name() string {
+	return p.name + "/" + p.model
+}
+
+// post sends a JSON request and decodes the JSON response into out
+func (p httpProvider) post(ctx context.Context, endpoint string, header http.Header, body, out interface{}) error {
+	return jsonhttp.Post(ctx, p.client, p.name, endpoint, header, body, out)
+}
+
+// Gemini generates text with the Google AI Studio Gemini API
+type Gemini struct {
+	httpProvider
+}
+
+// NewGemini creates a Gemini provider; it requires an API key
+func NewGemini(cfg ProviderConfig) (*Gemini, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("gemini requires an API key")
+	}
+	return &Gemini{newHTTPProvider(cfg, ProviderGemini, DefaultGeminiURL, DefaultGeminiModel)}, nil
+}
+
+// Complete returns the model's reply to prompt
+func (p *Gemini) Complete(ctx context.Context, prompt string) (Completion, error) {
+	var resp struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+		} `json:"candidates"`
+		UsageMetadata struct {
+			PromptTokenCount     int `json:"promptTokenCount"`
+			CandidatesTokenCount int `json:"candidatesTokenCount"`
+		} `json:"usageMetadata"`
+	}
+	body := map[string]interface{}{
+		"contents":         []map[string]interface{}{{"parts": []map[string]string{{"text": prompt}}}},
+		"generationConfig": map[string]interface{}{"maxOutputTokens": p.maxTokens},
+	}
+	endpoint := fmt.Sprintf("%s/models/%s:generateContent", p.url, url.PathEscape(p.model))
+	header := http.Header{"X-Goog-Api-Key": {p.apiKey}}
+	if err := p.post(ctx, endpoint, header, body, &resp); err != nil {
+		return Completion{}, err
+	}
+	if len(resp.Candidates) == 0 {
+		return Completion{}, fmt.Errorf("gemini response has no candidates")
+	}
+
+	var text strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+	}
+	return Completion{
+		Text:         text.String(),
+		InputTokens:  resp.UsageMetadata.PromptTokenCount,
+		OutputTokens: resp.UsageMetadata.CandidatesTokenCount,
+	}, nil
+}
+
+// OpenAI generates text with the OpenAI chat completions API
+type OpenAI struct {
+	httpProvider
+}
+
+// NewOpenAI creates an OpenAI provider; it requires an API key
+func NewOpenAI(cfg ProviderConfig) (*OpenAI, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("openai requires an API key")
+	}
+	return &OpenAI{newHTTPProvider(cfg, ProviderOpenAI, DefaultOpenAIURL, DefaultOpenAIModel)}, nil
+}
+
+// Complete returns the model's reply to prompt
+func (p *OpenAI) Complete(ctx context.Context, prompt string) (Completion, error) {
+	var resp struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	body := map[string]interface{}{
+		"model":      p.model,
+		"messages":   []map[string]string{{"role": "user", "content": prompt}},
+		"max_tokens": p.maxTokens,
+	}
+	header := http.Header{"Authorization": {"Bearer " + p.apiKey}}
+	if err := p.post(ctx, p.url, header, body, &resp); err != nil {
+		return Completion{}, err
+	}
+	if len(resp.Choices) == 0 {
+		return Completion{}, fmt.Errorf("openai response has no choices")
+	}
+	return Completion{
+		Text:         resp.Choices[0].Message.Content,
+		InputTokens:  resp.Usage.PromptTokens,
+		OutputTokens: resp.Usage.CompletionTokens,
+	}, nil
+}
+
+// Anthropic generates text with the Anthropic messages API
+type Anthropic struct {
+	httpProvider
+}
+
+// NewAnthropic creates an Anthropic provider; it requires an API key
+func NewAnthropic(cfg ProviderConfig) (*Anthropic, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("anthropic requires an API key")
+	}
+	return &Anthropic{newHTTPProvider(cfg, ProviderAnthropic, DefaultAnthropicURL, DefaultAnthropicModel)}, nil
+}
+
+// Complete returns the model's reply to prompt
+func (p *Anthropic) Complete(ctx context.Context, prompt string) (Completion, error) {
+	var resp struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	body := map[string]interface{}{
+		"model":      p.model,
+		"max_tokens": p.maxTokens,
+		"messages":   []map[string]string{{"role": "user", "content": prompt}},
+	}
+	header := http.Header{
+		"X-Api-Key":         {p.apiKey},
+		"Anthropic-Version": {anthropicVersion},
+	}
+	if err := p.post(ctx, p.url, header, body, &resp); err != nil {
+		return Completion{}, err
+	}
+
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return Completion{
+		Text:         text.String(),
+		InputTokens:  resp.Usage.InputTokens,
+		OutputTokens: resp.Usage.OutputTokens,
+	}, nil
+}
+
+// Ollama generates text with a model served locally by Ollama
+type Ollama struct {
+	httpProvider
+}
+
+// NewOllama creates a provider for a local Ollama server
+func NewOllama(cfg ProviderConfig) *Ollama {
+	return &Ollama{newHTTPProvider(cfg, ProviderOllama, DefaultOllamaURL, DefaultOllamaModel)}
+}
+
+// Complete returns the model's reply to prompt
+func (p *Ollama) Complete(ctx context.Context, prompt string) (Completion, error) {
+	var resp struct {
+		Response        string `json:"response"`
+		PromptEvalCount int    `json:"prompt_eval_count"`
+		EvalCount       int    `json:"eval_count"`
+	}
+	body := map[string]interface{}{
+		"model":   p.model,
+		"prompt":  prompt,
+		"stream":  false,
+		"options": map[string]interface{}{"num_predict": p.maxTokens},
+	}
+	if err := p.post(ctx, p.url+"/api/generate", nil, body, &resp); err != nil {
+		return Completion{}, err
+	}
+	return Completion{
+		Text:         resp.Response,
+		InputTokens:  resp.PromptEvalCount,
+		OutputTokens: resp.EvalCount,
+	}, nil
+}
@@ -0,0 +1,269 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// DefaultMaxFallbacks caps the fallback requests for each set of changes
+// when no limit is configured
+const DefaultMaxFallbacks = 20
+
+// PathRule assigns a portfolio and project to the paths matching a regular
+// expression. Portfolio and Project may refer to submatches, as in "$1" or
+// "${client}".
+type PathRule struct {
+	Match     string
+	Portfolio string
+	Project   string
+}
+
+// TypeRule assigns a document type to files with one of the extensions
+type TypeRule struct {
+	Extensions   []string
+	DocumentType string
+}
+
+// RulesConfig holds the classification rules
+type RulesConfig struct {
+	// Paths are tried in order; the first match sets the portfolio and project
+	Paths []PathRule
+	Types []TypeRule
+	// Fallback, when set, is asked to classify changes the rules leave
+	// incomplete
+	Fallback Provider
+	// MaxFallbacks caps the fallback requests for each set of changes
+	MaxFallbacks int
+}
+
+// compiledPathRule is a path rule with its expression compiled
+type compiledPathRule struct {
+	PathRule
+	re *regexp.Regexp
+}
+
+// Rules sets the portfolio, project and document type of changes from path
+// and extension rules, asking a language model about the changes they leave
+// incomplete when a fallback is configured
+type Rules struct {
+	paths        []compiledPathRule
+	types        map[string]string
+	fallback     Provider
+	maxFallbacks int
+	logger       *slog.Logger
+
+	mu        sync.Mutex
+	fallbacks map[string]classification
+}
+
+// classification is the portfolio, project and document type of a file
+type classification struct {
+	Portfolio    string `json:"portfolio"`
+	Project      string `json:"project"`
+	DocumentType string `json:"document_type"`
+}
+
+// NewRules compiles the classification rules. Path expressions match
+// case-insensitively.
+func NewRules(cfg RulesConfig) (*Rules, error) {
+	r := &Rules{
+		types:        make(map[string]string),
+		fallback:     cfg.Fallback,
+		maxFallbacks: cfg.MaxFallbacks,
+		logger:       logging.Component(nil, "analysis"),
+		fallbacks:    make(map[string]classification),
+	}
+	if r.maxFallbacks <= 0 {
+		r.maxFallbacks = DefaultMaxFallbacks
+	}
+
+	for _, rule := range cfg.Paths {
+		if rule.Portfolio == "" && rule.Project == "" {
+			return nil, fmt.Errorf("path rule %q sets neither portfolio nor project", rule.Match)
+		}
+		re, err := regexp.Compile("(?i)" + rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path rule %q: %w", rule.Match, err)
+		}
+		r.paths = append(r.paths, compiledPathRule{PathRule: rule, re: re})
+	}
+
+	for _, rule := range cfg.Types {
+		if rule.DocumentType == "" {
+			return nil, fmt.Errorf("type rule for %v has no document type", rule.Extensions)
+		}
+		for _, ext := range rule.Extensions {
+			ext = normalizeExtension(ext)
+			if ext == "." {
+				return nil, fmt.Errorf("type rule %q has an empty extension", rule.DocumentType)
+			}
+			if _, ok := r.types[ext]; !ok {
+				r.types[ext] = rule.DocumentType
+			}
+		}
+	}
+	return r, nil
+}
+
+// Apply classifies each change in place. Fields the rules leave empty are
+// requested from the fallback, at most once per folder and extension.
+// Deleted files are classified by the rules only.
+func (r *Rules) Apply(ctx context.Context, changes []models.FileChange) {
+	if r == nil {
+		return
+	}
+
+	requests := 0
+	for i := range changes {
+		change := &changes[i]
+		r.applyRules(change)
+		if r.fallback == nil || change.IsDeleted || complete(change) {
+			continue
+		}
+
+		key := path.Dir(change.Path) + "\x00" + normalizeExtension(path.Ext(change.Path))
+		r.mu.Lock()
+		result, ok := r.fallbacks[key]
+		r.mu.Unlock()
+		if !ok {
+			if requests >= r.maxFallbacks || ctx.Err() != nil {
+				continue
+			}
+			requests++
+
+			var err error
+			if result, err = r.ask(ctx, change.Path); err != nil {
+				r.logger.Warn("Skipping classification fallback", "path", change.Path, "error", err)
+				continue
+			}
+			r.mu.Lock()
+			r.fallbacks[key] = result
+			r.mu.Unlock()
+		}
+		fill(change, result)
+	}
+}
+
+// applyRules sets the fields the path and type rules determine
+func (r *Rules) applyRules(change *models.FileChange) {
+	for _, rule := range r.paths {
+		match := rule.re.FindStringSubmatchIndex(change.Path)
+		if match == nil {
+			continue
+		}
+		if change.Portfolio == "" {
+			change.Portfolio = string(rule.re.ExpandString(nil, rule.Portfolio, change.Path, match))
+		}
+		if change.Project == "" {
+			change.Project = string(rule.re.ExpandString(nil, rule.Project, change.Path, match))
+		}
+		break
+	}
+
+	if change.DocumentType == "" {
+		change.DocumentType = r.types[normalizeExtension(path.Ext(change.Path))]
+	}
+}
+
+// classifyPrompt asks for the classification of a path as JSON
+const classifyPrompt = `Classify the file below from a monitored Dropbox folder.
+Reply with only a JSON object of the form
+{"portfolio": "", "project": "", "document_type": ""}
+Prefer these known values and leave a field empty if you cannot tell.
+Portfolios: %s
+Projects: %s
+Document types: %s
+
+File: %s`
+
+// ask requests the classification of a path from the fallback
+func (r *Rules) ask(ctx context.Context, filePath string) (classification, error) {
+	var portfolios, projects, types []string
+	for _, rule := range r.paths {
+		portfolios = append(portfolios, literal(rule.Portfolio))
+		projects = append(projects, literal(rule.Project))
+	}
+	for _, documentType := range r.types {
+		types = append(types, documentType)
+	}
+
+	prompt := fmt.Sprintf(classifyPrompt, knownValues(portfolios), knownValues(projects), knownValues(types), filePath)
+	completion, err := r.fallback.Complete(ctx, prompt)
+	if err != nil {
+		return classification{}, err
+	}
+
+	// Models often wrap JSON in prose or code fences
+	reply := completion.Text
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return classification{}, fmt.Errorf("%s replied without JSON", r.fallback.Name())
+	}
+	var result classification
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &result); err != nil {
+		return classification{}, fmt.Errorf("failed to parse %s reply: %w", r.fallback.Name(), err)
+	}
+	result.Portfolio = strings.TrimSpace(result.Portfolio)
+	result.Project = strings.TrimSpace(result.Project)
+	result.DocumentType = strings.TrimSpace(result.DocumentType)
+	return result, nil
+}
+
+// complete reports whether every classification field of change is set
+func complete(change *models.FileChange) bool {
+	return change.Portfolio != "" && change.Project != "" && change.DocumentType != ""
+}
+
+// fill sets the empty classification fields of change from result
+func fill(change *models.FileChange, result classification) {
+	if change.Portfolio == "" {
+		change.Portfolio = result.Portfolio
+	}
+	if change.Project == "" {
+		change.Project = result.Project
+	}
+	if change.DocumentType == "" {
+		change.DocumentType = result.DocumentType
+	}
+}
+
+// literal returns a rule value without submatch references, which cannot be
+// offered as known values
+func literal(value string) string {
+	if strings.Contains(value, "$") {
+		return ""
+	}
+	return value
+}
+
+// knownValues lists the distinct non-empty values for the fallback prompt
+func knownValues(values []string) string {
+	seen := make(map[string]bool)
+	var distinct []string
+	for _, value := range values {
+		if value != "" && !seen[value] {
+			seen[value] = true
+			distinct = append(distinct, value)
+		}
+	}
+	if len(distinct) == 0 {
+		return "(none)"
+	}
+	sort.Strings(distinct)
+	return strings.Join(distinct, ", ")
+}
+
+// normalizeExtension lower-cases an extension and gives it a leading dot
+func normalizeExtension(ext string) string {
+	return "." + strings.TrimPrefix(strings.ToLower(strings.TrimSpace(ext)), ".")
+}
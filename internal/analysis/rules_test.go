@@ -0,0 +1,95 @@
+package analysis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRules_Apply(t *testing.T) {
+	rules, err := NewRules(RulesConfig{
+		Paths: []PathRule{
+			{Match: `^/clients/(?P<client>[^/]+)/([^/]+)/`, Portfolio: "Clients", Project: "${client} $2"},
+			{Match: `^/finance/`, Portfolio: "Finance"},
+		},
+		Types: []TypeRule{
+			{Extensions: []string{"docx", ".PDF"}, DocumentType: "Document"},
+			{Extensions: []string{".xlsx"}, DocumentType: "Spreadsheet"},
+		},
+	})
+	require.NoError(t, err)
+
+	changes := []models.FileChange{
+		{Path: "/Clients/Acme/Audit/report.docx"},
+		{Path: "/finance/budget.XLSX"},
+		{Path: "/misc/photo.jpg", Project: "Kept"},
+	}
+	rules.Apply(context.Background(), changes)
+
+	assert.Equal(t, "Clients", changes[0].Portfolio)
+	assert.Equal(t, "Acme Audit", changes[0].Project)
+	assert.Equal(t, "Document", changes[0].DocumentType)
+
+	assert.Equal(t, "Finance", changes[1].Portfolio)
+	assert.Empty(t, changes[1].Project)
+	assert.Equal(t, "Spreadsheet", changes[1].DocumentType)
+
+	assert.Empty(t, changes[2].Portfolio)
+	assert.Equal(t, "Kept", changes[2].Project, "fields already set are kept")
+	assert.Empty(t, changes[2].DocumentType)
+
+	var none *Rules
+	none.Apply(context.Background(), changes)
+}
+
+func TestRules_Fallback(t *testing.T) {
+	provider := &fakeProvider{reply: `Sure: {"portfolio": "Research", "project": " Grants ", "document_type": "Image"}`}
+	rules, err := NewRules(RulesConfig{
+		Paths:        []PathRule{{Match: `^/finance/`, Portfolio: "Finance", Project: "$0"}},
+		Types:        []TypeRule{{Extensions: []string{".pdf"}, DocumentType: "Document"}},
+		Fallback:     provider,
+		MaxFallbacks: 2,
+	})
+	require.NoError(t, err)
+
+	changes := []models.FileChange{
+		{Path: "/lab/a.png"},
+		{Path: "/lab/b.png"},
+		{Path: "/lab/c.pdf"},
+		{Path: "/other/d.png"},
+		{Path: "/gone/e.png", IsDeleted: true},
+	}
+	rules.Apply(context.Background(), changes)
+
+	assert.Equal(t, "Research", changes[0].Portfolio)
+	assert.Equal(t, "Grants", changes[0].Project)
+	assert.Equal(t, "Image", changes[1].DocumentType, "one request covers a folder and extension")
+	assert.Equal(t, "Document", changes[2].DocumentType, "rules take precedence")
+	assert.Empty(t, changes[3].Portfolio, "the fallback is limited per set of changes")
+	assert.Empty(t, changes[4].Portfolio, "deleted files are not sent to the fallback")
+
+	require.Len(t, provider.prompts, 2)
+	assert.Contains(t, provider.prompts[0], "Portfolios: Finance")
+	assert.Contains(t, provider.prompts[0], "Projects: (none)")
+	assert.Contains(t, provider.prompts[0], "File: /lab/a.png")
+
+	// Failed requests leave the fields empty
+	provider.reply = "no idea"
+	changes = []models.FileChange{{Path: "/new/f.png"}}
+	rules.Apply(context.Background(), changes)
+	assert.Empty(t, changes[0].Portfolio)
+}
+
+func TestNewRules_Invalid(t *testing.T) {
+	_, err := NewRules(RulesConfig{Paths: []PathRule{{Match: `(`, Portfolio: "X"}}})
+	assert.Error(t, err)
+	_, err = NewRules(RulesConfig{Paths: []PathRule{{Match: `^/x/`}}})
+	assert.Error(t, err)
+	_, err = NewRules(RulesConfig{Types: []TypeRule{{Extensions: []string{".pdf"}}}})
+	assert.Error(t, err)
+	_, err = NewRules(RulesConfig{Types: []TypeRule{{Extensions: []string{""}, DocumentType: "X"}}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,74 @@
+package analysis
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+const (
+	// DefaultSniffMaxFileBytes skips files larger than this when none is configured
+	DefaultSniffMaxFileBytes = 10 << 20
+	// DefaultSniffMaxFiles caps how many files are downloaded per report when none is configured
+	DefaultSniffMaxFiles = 20
+)
+
+// ContentFetcher downloads file content
+type ContentFetcher interface {
+	GetFileContent(ctx context.Context, path string) ([]byte, error)
+}
+
+// SnifferConfig holds the limits on content downloaded for sniffing
+type SnifferConfig struct {
+	// MaxFileBytes skips files larger than this
+	MaxFileBytes int64
+	// MaxFiles caps how many files are downloaded for each call
+	MaxFiles int
+}
+
+// TypeSniffer determines the MIME type of changed files whose type cannot be
+// told from their extension by sniffing their content
+type TypeSniffer struct {
+	fetcher ContentFetcher
+	config  SnifferConfig
+	logger  *slog.Logger
+}
+
+// NewTypeSniffer creates a sniffer that downloads content through fetcher
+func NewTypeSniffer(fetcher ContentFetcher, cfg SnifferConfig) *TypeSniffer {
+	if cfg.MaxFileBytes <= 0 {
+		cfg.MaxFileBytes = DefaultSniffMaxFileBytes
+	}
+	if cfg.MaxFiles <= 0 {
+		cfg.MaxFiles = DefaultSniffMaxFiles
+	}
+	return &TypeSniffer{fetcher: fetcher, config: cfg, logger: logging.Component(nil, "analysis")}
+}
+
+// SniffTypes sets ContentType, and SHA256 from the downloaded content, on the
+// changes whose type is unknown. Deleted and oversize files are skipped, and
+// download failures are logged and leave the change unknown.
+func (s *TypeSniffer) SniffTypes(ctx context.Context, changes []models.FileChange) {
+	downloaded := 0
+	for i := range changes {
+		change := &changes[i]
+		if change.IsDeleted || change.Size > s.config.MaxFileBytes || change.FileType() != models.UnknownFileType {
+			continue
+		}
+		if downloaded >= s.config.MaxFiles || ctx.Err() != nil {
+			return
+		}
+
+		downloaded++
+		content, err := s.fetcher.GetFileContent(ctx, change.Path)
+		if err != nil {
+			s.logger.Warn("Failed to download file for type detection", "path", change.Path, "error", err)
+			continue
+		}
+		change.ContentType = models.SniffContentType(content)
+		change.SHA256 = dropbox.SHA256(content)
+	}
+}
@@ -0,0 +1,60 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// contentFetcher serves canned file content and records downloads
+type contentFetcher struct {
+	files      map[string]string
+	downloaded []string
+}
+
+func (f *contentFetcher) GetFileContent(ctx context.Context, path string) ([]byte, error) {
+	f.downloaded = append(f.downloaded, path)
+	content, ok := f.files[path]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return []byte(content), nil
+}
+
+func TestTypeSniffer_SniffTypes(t *testing.T) {
+	fetcher := &contentFetcher{files: map[string]string{
+		"/scan":   "%PDF-1.7\n",
+		"/README": "plain notes\n",
+	}}
+	changes := []models.FileChange{
+		{Path: "/scan", Size: 9},
+		{Path: "/README", Size: 12},
+		{Path: "/report.pdf", Extension: ".pdf", Size: 9},
+		{Path: "/removed", IsDeleted: true},
+		{Path: "/huge", Size: 1 << 30},
+		{Path: "/missing", Size: 1},
+	}
+
+	NewTypeSniffer(fetcher, SnifferConfig{}).SniffTypes(context.Background(), changes)
+
+	assert.Equal(t, []string{"/scan", "/README", "/missing"}, fetcher.downloaded)
+	assert.Equal(t, "application/pdf", changes[0].ContentType)
+	assert.Equal(t, "text/plain", changes[1].ContentType)
+	assert.Empty(t, changes[2].ContentType)
+	assert.Empty(t, changes[5].ContentType)
+	assert.Equal(t, dropbox.SHA256([]byte("%PDF-1.7\n")), changes[0].SHA256)
+	assert.Empty(t, changes[2].SHA256)
+}
+
+func TestTypeSniffer_MaxFiles(t *testing.T) {
+	fetcher := &contentFetcher{files: map[string]string{}}
+	changes := []models.FileChange{{Path: "/a"}, {Path: "/b"}, {Path: "/c"}}
+
+	NewTypeSniffer(fetcher, SnifferConfig{MaxFiles: 2}).SniffTypes(context.Background(), changes)
+
+	assert.Equal(t, []string{"/a", "/b"}, fetcher.downloaded)
+}
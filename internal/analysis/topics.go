@@ -0,0 +1,75 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/extract"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// DefaultTopicMaxFiles caps how many documents are analyzed per report when
+// none is configured
+const DefaultTopicMaxFiles = 20
+
+// TopicCounter counts the keywords and topics that content analysis finds in
+// changed documents
+type TopicCounter struct {
+	analyzer ChangeAnalyzer
+	maxFiles int
+	logger   *slog.Logger
+}
+
+// NewTopicCounter creates a counter that analyzes up to maxFiles documents
+// per report through analyzer
+func NewTopicCounter(analyzer ChangeAnalyzer, maxFiles int) *TopicCounter {
+	if maxFiles <= 0 {
+		maxFiles = DefaultTopicMaxFiles
+	}
+	return &TopicCounter{analyzer: analyzer, maxFiles: maxFiles, logger: logging.Component(nil, "analysis")}
+}
+
+// CountTopics returns how many of the changed documents mention each keyword
+// and topic, compared without case. Only files with a text extractor are
+// analyzed; deleted and oversize files are skipped, and analysis failures are
+// logged and leave the document out.
+func (c *TopicCounter) CountTopics(ctx context.Context, changes []models.FileChange) (keywords, topics map[string]int) {
+	keywords, topics = make(map[string]int), make(map[string]int)
+	analyzed := make(map[string]bool)
+	for _, change := range changes {
+		if change.IsDeleted || change.Type() == models.ChangeSharedLink || change.Type() == models.ChangePaper ||
+			analyzed[change.Path] || !extract.Default.Supports(change.Path) {
+			continue
+		}
+		if len(analyzed) >= c.maxFiles || ctx.Err() != nil {
+			break
+		}
+
+		analyzed[change.Path] = true
+		content, err := c.analyzer.AnalyzeChange(ctx, change)
+		if err != nil {
+			if !errors.Is(err, ErrFileTooLarge) {
+				c.logger.Warn("Failed to analyze file for report topics", "path", change.Path, "error", err)
+			}
+			continue
+		}
+		countDistinct(keywords, content.Keywords)
+		countDistinct(topics, content.Topics)
+	}
+	return keywords, topics
+}
+
+// countDistinct counts each distinct value once, lower-cased
+func countDistinct(counts map[string]int, values []string) {
+	seen := make(map[string]bool)
+	for _, value := range values {
+		value = strings.ToLower(strings.Join(strings.Fields(value), " "))
+		if value != "" && !seen[value] {
+			seen[value] = true
+			counts[value]++
+		}
+	}
+}
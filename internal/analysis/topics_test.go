@@ -0,0 +1,54 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubChangeAnalyzer returns the analysis stored for each path
+type stubChangeAnalyzer struct {
+	analyses map[string]*models.FileContent
+	analyzed []string
+}
+
+func (s *stubChangeAnalyzer) AnalyzeChange(ctx context.Context, change models.FileChange) (*models.FileContent, error) {
+	s.analyzed = append(s.analyzed, change.Path)
+	analysis, ok := s.analyses[change.Path]
+	if !ok {
+		return nil, fmt.Errorf("download failed")
+	}
+	return analysis, nil
+}
+
+func TestTopicCounter_CountTopics(t *testing.T) {
+	analyzer := &stubChangeAnalyzer{analyses: map[string]*models.FileContent{
+		"/docs/a.md":  {Keywords: []string{"Budget", "budget", "Q3  forecast"}, Topics: []string{"Finance"}},
+		"/docs/b.txt": {Keywords: []string{"budget"}, Topics: []string{"finance", "Hiring"}},
+	}}
+	counter := NewTopicCounter(analyzer, 0)
+
+	keywords, topics := counter.CountTopics(context.Background(), []models.FileChange{
+		{Path: "/docs/a.md"},
+		{Path: "/docs/a.md"},
+		{Path: "/docs/b.txt"},
+		{Path: "/docs/c.txt"},
+		{Path: "/photos/d.jpg"},
+		{Path: "/docs/e.txt", IsDeleted: true},
+	})
+	assert.Equal(t, map[string]int{"budget": 2, "q3 forecast": 1}, keywords)
+	assert.Equal(t, map[string]int{"finance": 2, "hiring": 1}, topics)
+	assert.Equal(t, []string{"/docs/a.md", "/docs/b.txt", "/docs/c.txt"}, analyzer.analyzed,
+		"documents are analyzed once and other files not at all")
+}
+
+func TestTopicCounter_MaxFiles(t *testing.T) {
+	analyzer := &stubChangeAnalyzer{}
+	counter := NewTopicCounter(analyzer, 2)
+
+	counter.CountTopics(context.Background(), []models.FileChange{{Path: "/a.txt"}, {Path: "/b.txt"}, {Path: "/c.txt"}})
+	assert.Equal(t, []string{"/a.txt", "/b.txt"}, analyzer.analyzed)
+}
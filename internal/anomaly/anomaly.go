@@ -0,0 +1,451 @@
+// Package anomaly watches the published changes for the patterns of a
+// destructive incident, such as ransomware encrypting a Dropbox folder, and
+// raises a high-priority alert as soon as one appears rather than waiting
+// for the scheduled report.
+package anomaly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/events"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/i18n"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/safego"
+)
+
+const (
+	// DefaultWindow is how far back changes are counted when none is configured
+	DefaultWindow = 10 * time.Minute
+	// DefaultEntropyThreshold is the entropy, in bits per byte, above which
+	// content looks encrypted when no threshold is configured
+	DefaultEntropyThreshold = 7.5
+	// DefaultMaxFileBytes skips larger files when measuring entropy
+	DefaultMaxFileBytes = 10 << 20
+	// DefaultMaxFiles caps how many files of each set of changes are
+	// downloaded to measure their entropy
+	DefaultMaxFiles = 20
+)
+
+// minEntropySample is the least content whose entropy is measured; shorter
+// content cannot reach the threshold
+const minEntropySample = 512
+
+// maxListed caps the changes listed in an alert
+const maxListed = 20
+
+// maxPendingSamples caps the sets of changes waiting for their entropy to be
+// measured; further sets are skipped until the backlog clears
+const maxPendingSamples = 16
+
+// sampleTimeout bounds the downloads of one set of changes
+const sampleTimeout = 5 * time.Minute
+
+// Kind names a suspicious pattern
+type Kind string
+
+// The patterns the detector watches for
+const (
+	// MassDeletion is many files deleted within the window
+	MassDeletion Kind = "mass_deletion"
+	// MassRename is many files renamed to unknown extensions within the window
+	MassRename Kind = "mass_rename"
+	// HighEntropy is many files rewritten with content that looks encrypted
+	// within the window
+	HighEntropy Kind = "high_entropy"
+)
+
+// kinds lists the patterns in the order they are checked
+var kinds = []Kind{MassDeletion, MassRename, HighEntropy}
+
+// Config sets the thresholds of the detector; a threshold of zero disables
+// its pattern
+type Config struct {
+	// Window is how far back changes are counted; zero uses DefaultWindow
+	Window time.Duration
+	// Cooldown is how long after an alert the same pattern is not alerted
+	// again; zero uses the window
+	Cooldown time.Duration
+	// Deletions is how many deletions within the window raise an alert
+	Deletions int
+	// Renames is how many renames to unknown extensions within the window
+	// raise an alert
+	Renames int
+	// KnownExtensions are added to the common extensions renames to which
+	// are not counted
+	KnownExtensions []string
+	// EntropyFiles is how many modified files with encrypted-looking
+	// content within the window raise an alert
+	EntropyFiles int
+	// EntropyThreshold is the entropy, in bits per byte, above which content
+	// looks encrypted; zero uses DefaultEntropyThreshold
+	EntropyThreshold float64
+	// MaxFileBytes skips larger files when measuring entropy; zero uses
+	// DefaultMaxFileBytes
+	MaxFileBytes int64
+	// MaxFiles caps the files of each set of changes downloaded to measure
+	// their entropy; zero uses DefaultMaxFiles
+	MaxFiles int
+	// SubjectPrefix starts the subject of the alerts
+	SubjectPrefix string
+	// Language is the tag of the language the alerts are written in; empty
+	// is English
+	Language string
+}
+
+// Validate reports whether the thresholds are usable
+func (c Config) Validate() error {
+	if c.Window < 0 || c.Cooldown < 0 {
+		return fmt.Errorf("window and cooldown cannot be negative")
+	}
+	if c.Deletions < 0 || c.Renames < 0 || c.EntropyFiles < 0 || c.MaxFileBytes < 0 || c.MaxFiles < 0 {
+		return fmt.Errorf("thresholds cannot be negative")
+	}
+	if c.EntropyThreshold < 0 || c.EntropyThreshold > 8 {
+		return fmt.Errorf("entropy threshold must be between 0 and 8 bits per byte")
+	}
+	return nil
+}
+
+// Enabled reports whether any pattern is watched for
+func (c Config) Enabled() bool {
+	return c.Deletions > 0 || c.Renames > 0 || c.EntropyFiles > 0
+}
+
+// threshold returns how many changes of a kind raise an alert
+func (c Config) threshold(kind Kind) int {
+	switch kind {
+	case MassDeletion:
+		return c.Deletions
+	case MassRename:
+		return c.Renames
+	case HighEntropy:
+		return c.EntropyFiles
+	}
+	return 0
+}
+
+// ContentFetcher downloads file content
+type ContentFetcher interface {
+	GetFileContent(ctx context.Context, path string) ([]byte, error)
+}
+
+// Sender delivers an alert
+type Sender interface {
+	SendReport(ctx context.Context, report *models.Report, message string) error
+}
+
+// Anomaly is a suspicious pattern seen in the changes
+type Anomaly struct {
+	Kind Kind
+	// Changes are the changes counted towards the pattern
+	Changes []models.FileChange
+	// Since and Until bound when the changes were detected
+	Since, Until time.Time
+}
+
+// sample is a set of changes waiting for their entropy to be measured
+type sample struct {
+	at      time.Time
+	changes []models.FileChange
+}
+
+// sighting is a change counted towards a pattern
+type sighting struct {
+	at     time.Time
+	change models.FileChange
+}
+
+// Detector counts the published changes of each suspicious pattern over a
+// sliding window, alerting when a count reaches its threshold
+type Detector struct {
+	config  Config
+	known   map[string]bool
+	fetcher ContentFetcher
+	sender  Sender
+	logger  *slog.Logger
+	now     func() time.Time
+
+	mu        sync.Mutex
+	sightings map[Kind][]sighting
+	alerted   map[Kind]time.Time
+
+	// samples queues the sets of changes for the entropy worker, which runs
+	// while sampling is set; pending counts the sets not yet measured
+	samples  chan sample
+	sampling bool
+	pending  sync.WaitGroup
+}
+
+// NewDetector creates a detector downloading content through fetcher to
+// measure its entropy and alerting through sender
+func NewDetector(cfg Config, fetcher ContentFetcher, sender Sender, logger *slog.Logger) (*Detector, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.EntropyFiles > 0 && fetcher == nil {
+		return nil, fmt.Errorf("entropy detection needs a content fetcher")
+	}
+	if cfg.Window == 0 {
+		cfg.Window = DefaultWindow
+	}
+	if cfg.Cooldown == 0 {
+		cfg.Cooldown = cfg.Window
+	}
+	if cfg.EntropyThreshold == 0 {
+		cfg.EntropyThreshold = DefaultEntropyThreshold
+	}
+	if cfg.MaxFileBytes == 0 {
+		cfg.MaxFileBytes = DefaultMaxFileBytes
+	}
+	if cfg.MaxFiles == 0 {
+		cfg.MaxFiles = DefaultMaxFiles
+	}
+
+	known := make(map[string]bool)
+	for _, ext := range append(defaultKnownExtensions, cfg.KnownExtensions...) {
+		known["."+strings.TrimPrefix(strings.ToLower(strings.TrimSpace(ext)), ".")] = true
+	}
+	return &Detector{
+		config:    cfg,
+		known:     known,
+		fetcher:   fetcher,
+		sender:    sender,
+		logger:    logging.Component(logger, "anomaly"),
+		now:       time.Now,
+		sightings: make(map[Kind][]sighting),
+		alerted:   make(map[Kind]time.Time),
+		samples:   make(chan sample, maxPendingSamples),
+	}, nil
+}
+
+// Handle counts a set of published changes towards each pattern and alerts
+// the patterns whose count reaches its threshold; it is meant to be
+// subscribed to the event bus. Measuring entropy downloads content, so it is
+// left to a background worker and does not hold up publishing.
+func (d *Detector) Handle(ctx context.Context, event events.FileChanged) error {
+	at := event.DetectedAt
+	if at.IsZero() {
+		at = d.now()
+	}
+
+	observed := make(map[Kind][]models.FileChange)
+	for _, change := range event.Changes {
+		switch {
+		case change.Type() == models.ChangeDeleted:
+			observed[MassDeletion] = append(observed[MassDeletion], change)
+		case change.IsRenamed() && d.unknownRename(change):
+			observed[MassRename] = append(observed[MassRename], change)
+		}
+	}
+	if d.config.EntropyFiles > 0 {
+		d.sample(at, event.Changes)
+	}
+
+	var errs []error
+	for _, kind := range kinds {
+		if kind == HighEntropy {
+			continue
+		}
+		if err := d.check(ctx, kind, at, observed[kind]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// check counts the changes of a kind and alerts the anomaly they complete
+func (d *Detector) check(ctx context.Context, kind Kind, at time.Time, changes []models.FileChange) error {
+	anomaly := d.observe(kind, at, changes)
+	if anomaly == nil {
+		return nil
+	}
+	return d.alert(ctx, *anomaly)
+}
+
+// sample queues the modified files of a set of changes for the entropy
+// worker, starting it when it is not running. When the queue is full the
+// set is skipped and logged.
+func (d *Detector) sample(at time.Time, changes []models.FileChange) {
+	var candidates []models.FileChange
+	for _, change := range changes {
+		if d.sampled(change) {
+			candidates = append(candidates, change)
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+
+	d.pending.Add(1)
+	select {
+	case d.samples <- sample{at: at, changes: candidates}:
+	default:
+		d.pending.Done()
+		d.logger.Warn("Entropy measurements are behind, skipping changes", "changes", len(candidates))
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.sampling {
+		d.sampling = true
+		safego.Go(d.logger, "anomaly entropy", d.measure)
+	}
+}
+
+// measure measures the queued sets of changes in order, alerting when
+// enough files look encrypted, until the queue is empty
+func (d *Detector) measure() {
+	for {
+		d.mu.Lock()
+		if len(d.samples) == 0 {
+			d.sampling = false
+			d.mu.Unlock()
+			return
+		}
+		d.mu.Unlock()
+
+		s := <-d.samples
+		err := safego.Call(d.logger, "anomaly entropy", func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), sampleTimeout)
+			defer cancel()
+			return d.check(ctx, HighEntropy, s.at, d.encrypted(ctx, s.changes))
+		})
+		if err != nil {
+			d.logger.Error("Failed to alert encrypted-looking changes", "error", err)
+		}
+		d.pending.Done()
+	}
+}
+
+// wait blocks until every queued set of changes has been measured
+func (d *Detector) wait() {
+	d.pending.Wait()
+}
+
+// sampled reports whether a change is a modified file whose entropy is
+// measured: compressed formats and oversize files are skipped
+func (d *Detector) sampled(change models.FileChange) bool {
+	return change.Type() == models.ChangeModified && change.Size <= d.config.MaxFileBytes &&
+		!compressedExtensions[strings.ToLower(path.Ext(change.Path))]
+}
+
+// unknownRename reports whether a file was renamed to an extension other
+// than its own that is not known
+func (d *Detector) unknownRename(change models.FileChange) bool {
+	ext := strings.ToLower(path.Ext(change.Path))
+	return ext != "" && ext != strings.ToLower(path.Ext(change.PreviousPath)) && !d.known[ext]
+}
+
+// encrypted returns the sampled files whose content looks encrypted, at
+// most MaxFiles of them downloaded. Download failures are logged and leave
+// the file uncounted.
+func (d *Detector) encrypted(ctx context.Context, changes []models.FileChange) []models.FileChange {
+	var matched []models.FileChange
+	for i, change := range changes {
+		if i >= d.config.MaxFiles || ctx.Err() != nil {
+			break
+		}
+
+		content, err := d.fetcher.GetFileContent(ctx, change.Path)
+		if err != nil {
+			d.logger.Warn("Failed to download file to measure its entropy", "path", change.Path, "error", err)
+			continue
+		}
+		if len(content) >= minEntropySample && Entropy(content) >= d.config.EntropyThreshold {
+			matched = append(matched, change)
+		}
+	}
+	return matched
+}
+
+// observe adds the changes of a kind seen at a time to its window and
+// returns the anomaly when the window reaches the threshold outside the
+// cooldown; the window is emptied once it is alerted
+func (d *Detector) observe(kind Kind, at time.Time, changes []models.FileChange) *Anomaly {
+	threshold := d.config.threshold(kind)
+	if threshold == 0 {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cutoff := at.Add(-d.config.Window)
+	sightings := d.sightings[kind][:0]
+	for _, s := range d.sightings[kind] {
+		if s.at.After(cutoff) {
+			sightings = append(sightings, s)
+		}
+	}
+	for _, change := range changes {
+		sightings = append(sightings, sighting{at: at, change: change})
+	}
+	d.sightings[kind] = sightings
+
+	if len(sightings) < threshold {
+		return nil
+	}
+	if last, ok := d.alerted[kind]; ok && at.Sub(last) < d.config.Cooldown {
+		return nil
+	}
+
+	anomaly := &Anomaly{Kind: kind, Since: sightings[0].at, Until: at}
+	for _, s := range sightings {
+		anomaly.Changes = append(anomaly.Changes, s.change)
+	}
+	d.alerted[kind] = at
+	d.sightings[kind] = nil
+	return anomaly
+}
+
+// alert sends the alert for an anomaly
+func (d *Detector) alert(ctx context.Context, anomaly Anomaly) error {
+	report, message := d.render(anomaly)
+	d.logger.Warn("Sending anomaly alert", "anomaly", anomaly.Kind, "changes", len(anomaly.Changes))
+	if err := d.sender.SendReport(ctx, report, message); err != nil {
+		return fmt.Errorf("failed to send %s alert: %w", anomaly.Kind, err)
+	}
+	return nil
+}
+
+// render renders the alert for an anomaly as a report and its text
+func (d *Detector) render(anomaly Anomaly) (*models.Report, string) {
+	catalog := i18n.Lookup(d.config.Language)
+	title := catalog.T("anomaly."+string(anomaly.Kind), len(anomaly.Changes), d.config.Window)
+	if d.config.SubjectPrefix != "" {
+		title = d.config.SubjectPrefix + " " + title
+	}
+
+	report := models.NewReport(models.FileListReport)
+	report.Title = title
+	report.Since, report.Until = anomaly.Since, anomaly.Until
+	for _, change := range anomaly.Changes {
+		report.AddChange(change)
+	}
+	report.Metadata["anomaly"] = string(anomaly.Kind)
+	report.Metadata["priority"] = "high"
+
+	var b strings.Builder
+	b.WriteString(title + "\n\n")
+	for i, change := range anomaly.Changes {
+		if i == maxListed {
+			b.WriteString(catalog.T("report.and_more", len(anomaly.Changes)-maxListed) + "\n")
+			break
+		}
+		if change.IsRenamed() {
+			fmt.Fprintf(&b, "%s %s -> %s\n", change.Type(), change.PreviousPath, change.Path)
+			continue
+		}
+		fmt.Fprintf(&b, "%s %s\n", change.Type(), change.Path)
+	}
+	return report, b.String()
+}
@@ -0,0 +1,212 @@
+package anomaly
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/events"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSender records the alerts it is asked to send
+type recordingSender struct {
+	reports  []*models.Report
+	messages []string
+}
+
+func (s *recordingSender) SendReport(ctx context.Context, report *models.Report, message string) error {
+	s.reports = append(s.reports, report)
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+// contentFetcher serves content by path
+type contentFetcher map[string][]byte
+
+func (f contentFetcher) GetFileContent(ctx context.Context, path string) ([]byte, error) {
+	content, ok := f[path]
+	if !ok {
+		return nil, fmt.Errorf("%s not found", path)
+	}
+	return content, nil
+}
+
+// deletions returns n deleted files under dir
+func deletions(dir string, n int) []models.FileChange {
+	changes := make([]models.FileChange, n)
+	for i := range changes {
+		changes[i] = models.FileChange{Path: fmt.Sprintf("%s/file%d.docx", dir, i), IsDeleted: true}
+	}
+	return changes
+}
+
+func TestEntropy(t *testing.T) {
+	random := make([]byte, 64<<10)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	assert.Zero(t, Entropy(nil))
+	assert.Zero(t, Entropy(bytes.Repeat([]byte("a"), 100)))
+	assert.InDelta(t, 1, Entropy([]byte("abababab")), 0.001)
+	assert.Less(t, Entropy(bytes.Repeat([]byte("The quick brown fox jumps over the lazy dog. "), 100)), 5.0)
+	assert.Greater(t, Entropy(random), 7.9)
+}
+
+func TestDetector_MassDeletion(t *testing.T) {
+	sender := &recordingSender{}
+	detector, err := NewDetector(Config{Window: 10 * time.Minute, Deletions: 100, SubjectPrefix: "[SECURITY]"}, nil, sender, nil)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	publish := func(offset time.Duration, changes []models.FileChange) {
+		t.Helper()
+		require.NoError(t, detector.Handle(ctx, events.FileChanged{Folder: "/docs", Changes: changes, DetectedAt: start.Add(offset)}))
+	}
+
+	// Deletions spread over longer than the window are not counted together
+	publish(0, deletions("/docs", 60))
+	publish(11*time.Minute, deletions("/docs", 60))
+	assert.Empty(t, sender.reports)
+
+	publish(15*time.Minute, deletions("/docs", 50))
+	require.Len(t, sender.reports, 1)
+	assert.Equal(t, "[SECURITY] Possible ransomware: 110 files deleted within 10m0s", sender.reports[0].Title)
+	assert.Equal(t, 110, sender.reports[0].TotalChanges)
+	assert.Equal(t, "mass_deletion", sender.reports[0].Metadata["anomaly"])
+	assert.Equal(t, "high", sender.reports[0].Metadata["priority"])
+	assert.Equal(t, start.Add(11*time.Minute), sender.reports[0].Since)
+	assert.Contains(t, sender.messages[0], "deleted /docs/file0.docx")
+	assert.Contains(t, sender.messages[0], "…and 90 more")
+
+	// The pattern is not alerted again within the cooldown
+	publish(16*time.Minute, deletions("/docs", 200))
+	assert.Len(t, sender.reports, 1)
+	publish(26*time.Minute, deletions("/docs", 100))
+	assert.Len(t, sender.reports, 2)
+}
+
+func TestDetector_MassRename(t *testing.T) {
+	sender := &recordingSender{}
+	detector, err := NewDetector(Config{Renames: 3, KnownExtensions: []string{"draft"}}, nil, sender, nil)
+	require.NoError(t, err)
+
+	renamed := func(from, to string) models.FileChange {
+		return models.FileChange{Path: to, PreviousPath: from, ChangeType: models.ChangeRenamed}
+	}
+	require.NoError(t, detector.Handle(context.Background(), events.FileChanged{Changes: []models.FileChange{
+		renamed("/a.docx", "/b.docx"),
+		renamed("/a.docx", "/a.pdf"),
+		renamed("/a.txt", "/a.draft"),
+		renamed("/a.docx", "/a"),
+		renamed("/a.docx", "/a.docx.locked"),
+		renamed("/b.xlsx", "/b.xlsx.locked"),
+	}}))
+	assert.Empty(t, sender.reports)
+
+	require.NoError(t, detector.Handle(context.Background(), events.FileChanged{Changes: []models.FileChange{
+		renamed("/c.pdf", "/c.pdf.ENC"),
+	}}))
+	require.Len(t, sender.reports, 1)
+	assert.Equal(t, "Possible ransomware: 3 files renamed to unknown extensions within 10m0s", sender.reports[0].Title)
+	assert.Contains(t, sender.messages[0], "renamed /a.docx -> /a.docx.locked")
+}
+
+func TestDetector_HighEntropy(t *testing.T) {
+	random := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(random)
+	text := bytes.Repeat([]byte("quarterly figures "), 100)
+	fetcher := contentFetcher{
+		"/a.txt":   random,
+		"/b.csv":   random,
+		"/c.txt":   text,
+		"/d.zip":   random,
+		"/e.docx":  random,
+		"/short":   random[:100],
+		"/big.txt": random,
+	}
+
+	sender := &recordingSender{}
+	detector, err := NewDetector(Config{EntropyFiles: 2, MaxFileBytes: 1 << 20}, fetcher, sender, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, detector.Handle(context.Background(), events.FileChanged{Changes: []models.FileChange{
+		{Path: "/a.txt"},
+		{Path: "/c.txt"},
+		{Path: "/d.zip"},
+		{Path: "/e.docx"},
+		{Path: "/short"},
+		{Path: "/big.txt", Size: 2 << 20},
+		{Path: "/missing.txt"},
+		{Path: "/b.csv", IsDeleted: true},
+	}}))
+	detector.wait()
+	assert.Empty(t, sender.reports)
+
+	require.NoError(t, detector.Handle(context.Background(), events.FileChanged{Changes: []models.FileChange{{Path: "/b.csv"}}}))
+	detector.wait()
+	require.Len(t, sender.reports, 1)
+	assert.Equal(t, "high_entropy", sender.reports[0].Metadata["anomaly"])
+	assert.Equal(t, 2, sender.reports[0].TotalChanges)
+}
+
+// blockingFetcher serves content once released
+type blockingFetcher struct {
+	release chan struct{}
+	content []byte
+}
+
+func (f *blockingFetcher) GetFileContent(ctx context.Context, path string) ([]byte, error) {
+	<-f.release
+	return f.content, nil
+}
+
+func TestDetector_EntropyOffPublishPath(t *testing.T) {
+	random := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(random)
+	fetcher := &blockingFetcher{release: make(chan struct{}), content: random}
+
+	sender := &recordingSender{}
+	detector, err := NewDetector(Config{EntropyFiles: 1, Deletions: 1, Language: "af"}, fetcher, sender, nil)
+	require.NoError(t, err)
+
+	// Handle returns while the download is still waiting, and the other
+	// patterns are alerted at once
+	done := make(chan error, 1)
+	go func() {
+		done <- detector.Handle(context.Background(), events.FileChanged{Changes: []models.FileChange{
+			{Path: "/a.txt"},
+			{Path: "/b.txt", IsDeleted: true},
+		}})
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Handle waited for the entropy download")
+	}
+	require.Len(t, sender.reports, 1)
+	assert.Equal(t, "Moontlike losprysware: 1 lêers binne 10m0s geskrap", sender.reports[0].Title)
+
+	close(fetcher.release)
+	detector.wait()
+	require.Len(t, sender.reports, 2)
+	assert.Equal(t, "high_entropy", sender.reports[1].Metadata["anomaly"])
+}
+
+func TestNewDetector_Invalid(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"negative window":    {Window: -time.Minute, Deletions: 1},
+		"negative threshold": {Deletions: -1},
+		"entropy above 8":    {EntropyFiles: 1, EntropyThreshold: 9},
+		"no fetcher":         {EntropyFiles: 1},
+	} {
+		_, err := NewDetector(cfg, nil, &recordingSender{}, nil)
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,49 @@
+package anomaly
+
+import "math"
+
+// Entropy returns the Shannon entropy of data in bits per byte, from 0 for
+// a single repeated byte to 8 for uniformly random bytes. Encrypted content
+// is close to 8; text and most documents are well below 6.
+func Entropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+	entropy := 0.0
+	total := float64(len(data))
+	for _, count := range counts {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / total
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// compressedExtensions are formats whose content is compressed, and so has
+// high entropy, without being encrypted
+var compressedExtensions = map[string]bool{
+	".7z": true, ".bz2": true, ".gz": true, ".rar": true, ".tgz": true, ".xz": true, ".zip": true, ".zst": true,
+	".docx": true, ".xlsx": true, ".pptx": true, ".odt": true, ".ods": true, ".odp": true, ".epub": true, ".pdf": true,
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".heic": true,
+	".mp3": true, ".m4a": true, ".aac": true, ".ogg": true, ".flac": true,
+	".mp4": true, ".m4v": true, ".mov": true, ".mkv": true, ".webm": true, ".avi": true,
+	".apk": true, ".dmg": true, ".iso": true, ".jar": true,
+}
+
+// defaultKnownExtensions are the extensions files are commonly renamed to;
+// a rename to any other extension counts towards a mass rename
+var defaultKnownExtensions = []string{
+	".txt", ".md", ".csv", ".tsv", ".json", ".xml", ".yaml", ".yml", ".html", ".htm", ".css", ".js", ".ts",
+	".go", ".py", ".r", ".rmd", ".sql", ".sh", ".ipynb", ".log", ".ini", ".cfg", ".conf",
+	".doc", ".docx", ".xls", ".xlsx", ".ppt", ".pptx", ".odt", ".ods", ".odp", ".rtf", ".pdf", ".epub", ".pages",
+	".numbers", ".key", ".paper", ".bak", ".old", ".tmp",
+	".jpg", ".jpeg", ".png", ".gif", ".bmp", ".tif", ".tiff", ".svg", ".webp", ".heic", ".psd", ".ai", ".eps",
+	".mp3", ".m4a", ".wav", ".aac", ".ogg", ".flac", ".mp4", ".m4v", ".mov", ".avi", ".mkv", ".webm",
+	".zip", ".7z", ".rar", ".gz", ".tgz", ".tar", ".bz2", ".xz",
+}
@@ -0,0 +1,261 @@
+// Package backfill imports the revision history of the files in the
+// monitored folders, so a monitor deployed on an existing account starts
+// with the changes made before it ran rather than an empty database.
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/core"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// DefaultWorkers is how many files have their revisions listed at a time
+// when Options.Workers is not set
+const DefaultWorkers = 4
+
+// Source lists the files under a folder and the revisions of each file
+type Source interface {
+	ListFolderRecursive(ctx context.Context, path string) ([]*models.FileMetadata, string, error)
+	ListRevisions(ctx context.Context, path string, limit int) ([]*models.FileMetadata, error)
+}
+
+// AccountResolver is implemented by sources that can name the accounts that
+// made revisions in shared folders
+type AccountResolver interface {
+	AccountNames(ctx context.Context, ids []string) (map[string]string, error)
+}
+
+// Store persists file changes, skipping versions it has stored already
+type Store interface {
+	SaveFileChanges(ctx context.Context, changes []db.FileChange) error
+}
+
+// Options select the history that is imported
+type Options struct {
+	// Folders are the monitored folders whose files are imported, with
+	// their filters
+	Folders []core.FolderOptions
+	// Revisions is the most revisions imported per file; zero imports as
+	// many as Dropbox keeps, up to dropbox.MaxRevisions
+	Revisions int
+	// Since skips revisions made before it when set
+	Since time.Time
+	// Workers is how many files have their revisions listed at a time
+	Workers int
+	// Classify, if set, classifies the revisions before they are stored
+	Classify func(ctx context.Context, changes []models.FileChange)
+}
+
+// Result counts what a backfill imported
+type Result struct {
+	// Files is how many files had their revisions listed
+	Files int `json:"files"`
+	// Revisions is how many revisions were passed to the store, including
+	// those stored by an earlier run
+	Revisions int `json:"revisions"`
+}
+
+// Importer copies the revision history of the monitored files into the store
+type Importer struct {
+	source Source
+	store  Store
+	logger *slog.Logger
+}
+
+// NewImporter creates an importer reading from source and writing to store
+func NewImporter(source Source, store Store, logger *slog.Logger) (*Importer, error) {
+	if source == nil {
+		return nil, fmt.Errorf("backfill source cannot be nil")
+	}
+	if store == nil {
+		return nil, fmt.Errorf("file change store cannot be nil")
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Importer{source: source, store: store, logger: logging.Component(logger, "backfill")}, nil
+}
+
+// fileRevisions are the revisions listed for one file
+type fileRevisions struct {
+	path      string
+	revisions []*models.FileMetadata
+	err       error
+}
+
+// Run imports the revisions of every file in the folders. Revisions are
+// stored oldest first, a file at a time, and versions already stored are
+// skipped, so an interrupted backfill can simply be run again.
+func (i *Importer) Run(ctx context.Context, opts Options) (Result, error) {
+	var result Result
+	paths, err := i.listFiles(ctx, opts.Folders)
+	if err != nil {
+		return result, err
+	}
+	i.logger.Info("Importing file history", "files", len(paths), "folders", len(opts.Folders))
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan string)
+	listed := make(chan fileRevisions)
+	var wg sync.WaitGroup
+	for n := 0; n < workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				revisions, err := i.source.ListRevisions(ctx, path, opts.Revisions)
+				select {
+				case listed <- fileRevisions{path: path, revisions: revisions, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, path := range paths {
+			select {
+			case jobs <- path:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(listed)
+	}()
+
+	names := newAccountNames(i.source, i.logger)
+	for file := range listed {
+		if file.err != nil {
+			// A file deleted since the folders were listed has no history to import
+			var dbErr *dropbox.Error
+			if errors.As(file.err, &dbErr) && dbErr.Type == dropbox.ErrorTypeConflict {
+				i.logger.Warn("Skipping file that no longer exists", "path", file.path)
+				continue
+			}
+			return result, fmt.Errorf("failed to list revisions of %s: %w", file.path, file.err)
+		}
+
+		changes := i.toChanges(ctx, file.revisions, opts, names)
+		if err := i.store.SaveFileChanges(ctx, changes); err != nil {
+			return result, fmt.Errorf("failed to store revisions of %s: %w", file.path, err)
+		}
+		result.Files++
+		result.Revisions += len(changes)
+		if result.Files%100 == 0 {
+			i.logger.Info("Imported file history", "files", result.Files, "of", len(paths), "revisions", result.Revisions)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// listFiles returns the paths of the files matching the folders' filters,
+// each once even when folders overlap
+func (i *Importer) listFiles(ctx context.Context, folders []core.FolderOptions) ([]string, error) {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, folder := range folders {
+		files, _, err := i.source.ListFolderRecursive(ctx, folder.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %q: %w", folder.Path, err)
+		}
+		for _, change := range folder.Filter(models.BatchConvertMetadataToChanges(files)) {
+			if !seen[change.Path] {
+				seen[change.Path] = true
+				paths = append(paths, change.Path)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// toChanges converts the revisions of a file into their stored form,
+// dropping those made before opts.Since
+func (i *Importer) toChanges(ctx context.Context, revisions []*models.FileMetadata, opts Options, names *accountNames) []db.FileChange {
+	kept := make([]*models.FileMetadata, 0, len(revisions))
+	for _, revision := range revisions {
+		if opts.Since.IsZero() || !revision.Modified.Before(opts.Since) {
+			kept = append(kept, revision)
+		}
+	}
+
+	modifiers := names.lookup(ctx, kept)
+	changes := make([]models.FileChange, len(kept))
+	for n, revision := range kept {
+		changes[n] = revision.ToFileChange()
+		changes[n].ModifiedBy = modifiers[revision.ModifiedByID]
+		changes[n].Normalize()
+	}
+	if opts.Classify != nil {
+		opts.Classify(ctx, changes)
+	}
+
+	stored := make([]db.FileChange, len(kept))
+	for n, revision := range kept {
+		fc := db.NewFileChangeFromModel(changes[n])
+		fc.DropboxRev = revision.Rev
+		fc.ModifiedByID = revision.ModifiedByID
+		stored[n] = *fc
+	}
+	return stored
+}
+
+// accountNames caches the names of the accounts that made revisions. Names
+// are looked up only when the source can resolve them, and a failed lookup
+// leaves revisions with just the account ID.
+type accountNames struct {
+	resolver AccountResolver
+	logger   *slog.Logger
+	names    map[string]string
+}
+
+// newAccountNames creates the cache over source
+func newAccountNames(source Source, logger *slog.Logger) *accountNames {
+	resolver, _ := source.(AccountResolver)
+	return &accountNames{resolver: resolver, logger: logger, names: make(map[string]string)}
+}
+
+// lookup returns the names of the accounts that made revisions
+func (a *accountNames) lookup(ctx context.Context, revisions []*models.FileMetadata) map[string]string {
+	var unknown []string
+	for _, revision := range revisions {
+		id := revision.ModifiedByID
+		if _, ok := a.names[id]; id != "" && !ok {
+			a.names[id] = ""
+			unknown = append(unknown, id)
+		}
+	}
+	if len(unknown) > 0 && a.resolver != nil {
+		names, err := a.resolver.AccountNames(ctx, unknown)
+		if err != nil {
+			a.logger.Warn("Failed to look up account names", "error", err)
+		}
+		for id, name := range names {
+			a.names[id] = name
+		}
+	}
+	return a.names
+}
@@ -0,0 +1,124 @@
+package backfill
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/core"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// historySource serves canned files and revisions, keyed by path
+type historySource struct {
+	files     map[string][]*models.FileMetadata
+	revisions map[string][]*models.FileMetadata
+	names     map[string]string
+
+	mu      sync.Mutex
+	listed  []string
+	lookups [][]string
+}
+
+func (s *historySource) ListFolderRecursive(ctx context.Context, path string) ([]*models.FileMetadata, string, error) {
+	return s.files[path], "cursor", nil
+}
+
+func (s *historySource) ListRevisions(ctx context.Context, path string, limit int) ([]*models.FileMetadata, error) {
+	s.mu.Lock()
+	s.listed = append(s.listed, path)
+	s.mu.Unlock()
+	revisions, ok := s.revisions[path]
+	if !ok {
+		return nil, dropbox.NewConflictError("path/not_found", nil)
+	}
+	return revisions, nil
+}
+
+func (s *historySource) AccountNames(ctx context.Context, ids []string) (map[string]string, error) {
+	s.lookups = append(s.lookups, ids)
+	return s.names, nil
+}
+
+// revision creates a revision of path made by an account
+func revision(path, rev, hash, account string, modified time.Time) *models.FileMetadata {
+	file := models.NewFileMetadata(path, 100, modified, false)
+	file.Rev = rev
+	file.ContentHash = hash
+	file.ModifiedByID = account
+	return file
+}
+
+func TestImporter_Run(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	source := &historySource{
+		files: map[string][]*models.FileMetadata{
+			"/docs": {
+				models.NewFileMetadata("/docs/plan.txt", 100, day, false),
+				models.NewFileMetadata("/docs/notes.tmp", 100, day, false),
+				models.NewFileMetadata("/docs/gone.txt", 100, day, false),
+			},
+			// Overlapping folders list a file once
+			"/docs/sub": {models.NewFileMetadata("/docs/plan.txt", 100, day, false)},
+		},
+		revisions: map[string][]*models.FileMetadata{
+			"/docs/plan.txt": {
+				revision("/docs/plan.txt", "1", "h1", "dbid:1", day),
+				revision("/docs/plan.txt", "2", "h2", "dbid:2", day.AddDate(0, 1, 0)),
+				revision("/docs/plan.txt", "3", "h3", "dbid:1", day.AddDate(0, 2, 0)),
+			},
+		},
+		names: map[string]string{"dbid:1": "Jane Doe", "dbid:2": "John Roe"},
+	}
+
+	database, err := db.NewDB(db.MemoryPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	importer, err := NewImporter(source, database, nil)
+	require.NoError(t, err)
+	opts := Options{
+		Folders: []core.FolderOptions{
+			{Path: "/docs", Recursive: true, Exclude: []string{"*.tmp"}},
+			{Path: "/docs/sub", Recursive: true},
+		},
+		Since: day.Add(time.Hour),
+		Classify: func(ctx context.Context, changes []models.FileChange) {
+			for i := range changes {
+				changes[i].Project = "Planning"
+			}
+		},
+	}
+
+	ctx := context.Background()
+	result, err := importer.Run(ctx, opts)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Files: 1, Revisions: 2}, result)
+	sort.Strings(source.listed)
+	assert.Equal(t, []string{"/docs/gone.txt", "/docs/plan.txt"}, source.listed)
+	assert.Equal(t, [][]string{{"dbid:2", "dbid:1"}}, source.lookups)
+
+	stored, err := database.GetRecentFileChanges(ctx, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	sort.Slice(stored, func(i, j int) bool { return stored[i].ModifiedAt.Before(stored[j].ModifiedAt) })
+	assert.Equal(t, "2", stored[0].DropboxRev)
+	assert.Equal(t, "John Roe", stored[0].ModifiedByName)
+	assert.Equal(t, "dbid:2", stored[0].ModifiedByID)
+	assert.Equal(t, "Planning", stored[0].Project)
+	assert.Equal(t, "3", stored[1].DropboxRev)
+	assert.Equal(t, "Jane Doe", stored[1].ModifiedByName)
+
+	// Running again stores nothing new
+	_, err = importer.Run(ctx, opts)
+	require.NoError(t, err)
+	stored, err = database.GetRecentFileChanges(ctx, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, stored, 2)
+}
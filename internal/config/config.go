@@ -2,17 +2,40 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
-	"gopkg.in/yaml.v3"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/actions"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/analysis"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/anomaly"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/core"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/diff"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/embeddings"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/enrich"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/features"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/filter"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/i18n"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/limits"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/preview"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/severity"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/sharing"
 )
 
 // Config holds all configuration settings
 type Config struct {
 	DropboxToken    string        `yaml:"dropbox_token"`
+	DropboxAppKey   string        `yaml:"dropbox_app_key"`
+	DropboxAppSecret string       `yaml:"dropbox_app_secret"`
+	DropboxRefreshToken string    `yaml:"dropbox_refresh_token"`
 	PollInterval    time.Duration `yaml:"poll_interval"`
 	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
 	EmailConfig     *EmailConfig  `yaml:"email_config"`
@@ -23,6 +46,813 @@ type Config struct {
 	State          StateConfig    `yaml:"state"`
 	Web            WebConfig      `yaml:"web"`
 	Monitoring     MonitoringConfig `yaml:"monitoring"`
+	Accounts       []AccountConfig  `yaml:"accounts"`
+	Webhook        WebhookConfig    `yaml:"webhook"`
+	SavedQueries   []SavedQueryConfig `yaml:"saved_queries"`
+	Limits         LimitsConfig       `yaml:"limits"`
+	Quotas         QuotasConfig       `yaml:"quotas"`
+	TeamLog        TeamLogConfig      `yaml:"team_log"`
+	Features       map[string]bool    `yaml:"features"`
+	Logging        LoggingConfig      `yaml:"logging"`
+	Diffs          DiffsConfig        `yaml:"diffs"`
+	Digest         DigestConfig       `yaml:"digest"`
+	FileTypes      FileTypesConfig    `yaml:"file_types"`
+	Actions        ActionsConfig      `yaml:"actions"`
+	SLO            SLOConfig          `yaml:"slo"`
+	Embeddings     EmbeddingsConfig   `yaml:"embeddings"`
+	AI             AIConfig           `yaml:"ai"`
+	Classification ClassificationConfig `yaml:"classification"`
+	Enrichment     EnrichmentConfig     `yaml:"enrichment"`
+	Fixtures       FixturesConfig       `yaml:"fixtures"`
+	Team           TeamConfig           `yaml:"team"`
+	Sharing        SharingConfig        `yaml:"sharing"`
+	Retention      RetentionConfig      `yaml:"retention"`
+	Severity       SeverityConfig       `yaml:"severity"`
+	Anomaly        AnomalyConfig        `yaml:"anomaly"`
+	Report         ReportConfig         `yaml:"report"`
+}
+
+// DefaultTeamLogPollInterval is how often the team log is read when not configured
+const DefaultTeamLogPollInterval = 15 * time.Minute
+
+// TeamLogConfig controls ingestion of the Dropbox Business team events log.
+// Categories limits which event categories are stored; empty stores sharing,
+// login and device events.
+type TeamLogConfig struct {
+	Enabled          bool          `yaml:"enabled"`
+	PollInterval     time.Duration `yaml:"poll_interval"`
+	Categories       []string      `yaml:"categories"`
+	IncludeInReports bool          `yaml:"include_in_reports"`
+}
+
+// GetPollInterval returns the poll interval, falling back to the default
+func (t TeamLogConfig) GetPollInterval() time.Duration {
+	if t.PollInterval <= 0 {
+		return DefaultTeamLogPollInterval
+	}
+	return t.PollInterval
+}
+
+// DefaultQuotaScanInterval is how often folder sizes are scanned when not configured
+const DefaultQuotaScanInterval = 6 * time.Hour
+
+// QuotasConfig holds per-folder storage budgets and how often they are checked
+type QuotasConfig struct {
+	ScanInterval time.Duration       `yaml:"scan_interval"`
+	Folders      []FolderQuotaConfig `yaml:"folders"`
+}
+
+// FolderQuotaConfig is the size budget for one folder. An alert is raised when
+// the folder exceeds MaxSize or, if ProjectionDays is set, when its recent
+// growth would exceed MaxSize within that many days.
+type FolderQuotaConfig struct {
+	Path           string   `yaml:"path"`
+	MaxSize        ByteSize `yaml:"max_size"`
+	ProjectionDays int      `yaml:"projection_days"`
+}
+
+// GetScanInterval returns the scan interval, falling back to the default
+func (q QuotasConfig) GetScanInterval() time.Duration {
+	if q.ScanInterval <= 0 {
+		return DefaultQuotaScanInterval
+	}
+	return q.ScanInterval
+}
+
+// LimitsConfig holds resource limits shared by all agents; zero values use the defaults
+type LimitsConfig struct {
+	MaxConcurrentDownloads int   `yaml:"max_concurrent_downloads"`
+	MaxContentBytes        int64 `yaml:"max_content_bytes"`
+	MaxReportBytes         int   `yaml:"max_report_bytes"`
+	// MinFreeDisk pauses content downloads while free space on DiskPath is
+	// below it; zero disables the check
+	MinFreeDisk       ByteSize      `yaml:"min_free_disk"`
+	DiskPath          string        `yaml:"disk_path"`
+	DiskCheckInterval time.Duration `yaml:"disk_check_interval"`
+	// APIRequestsPerSecond and APIBurst size the token bucket shared by all
+	// Dropbox requests; a negative rate disables it
+	APIRequestsPerSecond float64 `yaml:"api_requests_per_second"`
+	APIBurst             int     `yaml:"api_burst"`
+	// RateLimitPauseAfter is how long Dropbox must keep rate limiting
+	// requests before scanning is paused; RateLimitPause is how long it is
+	// paused when Dropbox gives no Retry-After hint
+	RateLimitPauseAfter time.Duration `yaml:"rate_limit_pause_after"`
+	RateLimitPause      time.Duration `yaml:"rate_limit_pause"`
+	// MaxConcurrentListings is how many folders are listed at a time during
+	// the initial sync and folder listings; 1 lists the tree sequentially
+	MaxConcurrentListings int `yaml:"max_concurrent_listings"`
+}
+
+// DefaultDiskCheckInterval is how often free disk space is checked when not configured
+const DefaultDiskCheckInterval = time.Minute
+
+// ToLimits converts the configuration to limits.Config
+func (l LimitsConfig) ToLimits() limits.Config {
+	return limits.Config{
+		MaxConcurrentDownloads: l.MaxConcurrentDownloads,
+		MaxContentBytes:        l.MaxContentBytes,
+		MaxReportBytes:         l.MaxReportBytes,
+		MinFreeDiskBytes:       int64(l.MinFreeDisk),
+		DiskPath:               l.DiskPath,
+	}
+}
+
+// ToRateLimitConfig converts the request rate settings to
+// dropbox.RateLimitConfig
+func (l LimitsConfig) ToRateLimitConfig() dropbox.RateLimitConfig {
+	return dropbox.RateLimitConfig{RequestsPerSecond: l.APIRequestsPerSecond, Burst: l.APIBurst}
+}
+
+// GetMaxConcurrentListings returns how many folders are listed at a time,
+// defaulting to dropbox.DefaultTraversalWorkers
+func (l LimitsConfig) GetMaxConcurrentListings() int {
+	if l.MaxConcurrentListings <= 0 {
+		return dropbox.DefaultTraversalWorkers
+	}
+	return l.MaxConcurrentListings
+}
+
+// ToRateLimitPauseConfig converts the rate limit pause settings to
+// dropbox.RateLimitPauseConfig
+func (l LimitsConfig) ToRateLimitPauseConfig() dropbox.RateLimitPauseConfig {
+	return dropbox.RateLimitPauseConfig{After: l.RateLimitPauseAfter, Pause: l.RateLimitPause}
+}
+
+// GetDiskCheckInterval returns the disk check interval, falling back to the default
+func (l LimitsConfig) GetDiskCheckInterval() time.Duration {
+	if l.DiskCheckInterval <= 0 {
+		return DefaultDiskCheckInterval
+	}
+	return l.DiskCheckInterval
+}
+
+// DefaultPruneInterval is how often old history is pruned when not configured
+const DefaultPruneInterval = 24 * time.Hour
+
+// RetentionConfig sets how many days of history each table keeps; zero
+// keeps it forever. File changes keep the latest version of every existing
+// file however old.
+type RetentionConfig struct {
+	FileChangesDays  int           `yaml:"file_changes_days"`
+	FileContentsDays int           `yaml:"file_contents_days"`
+	TeamEventsDays   int           `yaml:"team_events_days"`
+	FolderUsageDays  int           `yaml:"folder_usage_days"`
+	PruneInterval    time.Duration `yaml:"prune_interval"`
+}
+
+// GetPruneInterval returns the prune interval, falling back to the default
+func (r RetentionConfig) GetPruneInterval() time.Duration {
+	if r.PruneInterval <= 0 {
+		return DefaultPruneInterval
+	}
+	return r.PruneInterval
+}
+
+// ToRetentionPolicy converts the retention settings to db.RetentionPolicy
+func (r RetentionConfig) ToRetentionPolicy() db.RetentionPolicy {
+	days := func(n int) time.Duration { return time.Duration(n) * 24 * time.Hour }
+	return db.RetentionPolicy{
+		FileChanges:  days(r.FileChangesDays),
+		FileContents: days(r.FileContentsDays),
+		TeamEvents:   days(r.TeamEventsDays),
+		FolderUsage:  days(r.FolderUsageDays),
+	}
+}
+
+// DefaultSLOCheckInterval is how often the freshness SLO is checked when not configured
+const DefaultSLOCheckInterval = time.Minute
+
+// SLOConfig holds the freshness SLO: how long a detected change may wait to
+// be reported before an alert is sent. A zero MaxUnreportedAge only exposes
+// the age as a metric.
+type SLOConfig struct {
+	MaxUnreportedAge time.Duration `yaml:"max_unreported_age"`
+	CheckInterval    time.Duration `yaml:"check_interval"`
+}
+
+// GetCheckInterval returns the check interval, falling back to the default
+func (s SLOConfig) GetCheckInterval() time.Duration {
+	if s.CheckInterval <= 0 {
+		return DefaultSLOCheckInterval
+	}
+	return s.CheckInterval
+}
+
+// DiffsConfig controls diff summaries of changed text documents in reports
+type DiffsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxFileSize skips documents larger than this
+	MaxFileSize ByteSize `yaml:"max_file_size"`
+	// MaxFiles caps how many documents are downloaded for each report
+	MaxFiles int `yaml:"max_files"`
+	// Extensions lists the file types treated as text
+	Extensions []string `yaml:"extensions"`
+}
+
+// ToDiffConfig converts the configuration to diff.Config
+func (d DiffsConfig) ToDiffConfig() diff.Config {
+	return diff.Config{
+		MaxFileBytes: int64(d.MaxFileSize),
+		MaxFiles:     d.MaxFiles,
+		Extensions:   d.Extensions,
+	}
+}
+
+// EmbeddingsConfig controls embedding of changed documents for semantic
+// search. Provider is hashing (built in, the default), openai, google or
+// ollama; the API key of hosted providers falls back to the OPENAI_API_KEY
+// or GOOGLE_API_KEY environment variable.
+type EmbeddingsConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Provider string `yaml:"provider"`
+	Model    string `yaml:"model"`
+	URL      string `yaml:"url"`
+	APIKey   string `yaml:"api_key"`
+	// Dimensions sizes the vectors of the hashing provider
+	Dimensions int `yaml:"dimensions"`
+	// MaxFileSize skips documents larger than this
+	MaxFileSize ByteSize `yaml:"max_file_size"`
+	// MaxFiles caps how many documents are embedded for each set of changes
+	MaxFiles int `yaml:"max_files"`
+	// MaxChars truncates the text embedded for each document
+	MaxChars int `yaml:"max_chars"`
+}
+
+// GetAPIKey returns the API key, falling back to the provider's environment variable
+func (e EmbeddingsConfig) GetAPIKey() string {
+	if e.APIKey != "" {
+		return e.APIKey
+	}
+	switch e.Provider {
+	case embeddings.ProviderOpenAI:
+		return os.Getenv("OPENAI_API_KEY")
+	case embeddings.ProviderGoogle:
+		return os.Getenv("GOOGLE_API_KEY")
+	}
+	return ""
+}
+
+// ToEmbeddingsConfig converts the configuration to embeddings.Config
+func (e EmbeddingsConfig) ToEmbeddingsConfig() embeddings.Config {
+	return embeddings.Config{
+		Provider:     e.Provider,
+		Model:        e.Model,
+		URL:          e.URL,
+		APIKey:       e.GetAPIKey(),
+		Dimensions:   e.Dimensions,
+		MaxFileBytes: int64(e.MaxFileSize),
+		MaxFiles:     e.MaxFiles,
+		MaxChars:     e.MaxChars,
+	}
+}
+
+// AIConfig selects the AI provider that summarizes analyzed documents and
+// picks their keywords and topics. Provider is gemini, openai, anthropic or
+// ollama; the API key of hosted providers falls back to the GEMINI_API_KEY
+// (or GOOGLE_API_KEY), OPENAI_API_KEY or ANTHROPIC_API_KEY environment
+// variable.
+type AIConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Provider string `yaml:"provider"`
+	Model    string `yaml:"model"`
+	URL      string `yaml:"url"`
+	APIKey   string `yaml:"api_key"`
+	// MaxOutputTokens caps the length of each reply
+	MaxOutputTokens int `yaml:"max_output_tokens"`
+	// RequestsPerMinute overrides the provider's default rate limit; -1 disables it
+	RequestsPerMinute int `yaml:"requests_per_minute"`
+	// DailyTokenBudget caps the tokens used per day; 0 is unlimited
+	DailyTokenBudget int `yaml:"daily_token_budget"`
+	// MaxChars truncates the document text sent to the provider
+	MaxChars int `yaml:"max_chars"`
+	// MaxFileSize skips files larger than this; defaults to 32 MB
+	MaxFileSize ByteSize `yaml:"max_file_size"`
+	// CacheSize is how many analyses are kept so that a revision is not
+	// downloaded twice; defaults to 256
+	CacheSize int `yaml:"cache_size"`
+}
+
+// GetAPIKey returns the API key, falling back to the provider's environment variable
+func (a AIConfig) GetAPIKey() string {
+	if a.APIKey != "" {
+		return a.APIKey
+	}
+	switch a.Provider {
+	case analysis.ProviderGemini:
+		if key := os.Getenv("GEMINI_API_KEY"); key != "" {
+			return key
+		}
+		return os.Getenv("GOOGLE_API_KEY")
+	case analysis.ProviderOpenAI:
+		return os.Getenv("OPENAI_API_KEY")
+	case analysis.ProviderAnthropic:
+		return os.Getenv("ANTHROPIC_API_KEY")
+	}
+	return ""
+}
+
+// ToProviderConfig converts the configuration to analysis.ProviderConfig
+func (a AIConfig) ToProviderConfig() analysis.ProviderConfig {
+	return analysis.ProviderConfig{
+		Provider:          a.Provider,
+		Model:             a.Model,
+		URL:               a.URL,
+		APIKey:            a.GetAPIKey(),
+		MaxOutputTokens:   a.MaxOutputTokens,
+		RequestsPerMinute: a.RequestsPerMinute,
+		DailyTokenBudget:  a.DailyTokenBudget,
+	}
+}
+
+// ToAnalyzerConfig converts the configuration to analysis.AnalyzerConfig,
+// without the provider and fetcher
+func (a AIConfig) ToAnalyzerConfig() analysis.AnalyzerConfig {
+	return analysis.AnalyzerConfig{
+		MaxChars:     a.MaxChars,
+		MaxFileBytes: int64(a.MaxFileSize),
+		CacheSize:    a.CacheSize,
+	}
+}
+
+// ClassificationConfig holds the rules that set the portfolio, project and
+// document type of changes before they are stored
+type ClassificationConfig struct {
+	// Paths are tried in order; the first whose regular expression matches
+	// the path sets the portfolio and project
+	Paths []ClassificationPathRule `yaml:"paths"`
+	// Types map file extensions to document types
+	Types []ClassificationTypeRule `yaml:"types"`
+	// LLMFallback asks the AI provider about changes the rules leave
+	// incomplete; it requires ai to be enabled
+	LLMFallback bool `yaml:"llm_fallback"`
+	// MaxFallbacks caps the fallback requests for each set of changes
+	MaxFallbacks int `yaml:"max_fallbacks"`
+}
+
+// ClassificationPathRule assigns a portfolio and project to matching paths;
+// both may refer to submatches such as $1
+type ClassificationPathRule struct {
+	Match     string `yaml:"match"`
+	Portfolio string `yaml:"portfolio"`
+	Project   string `yaml:"project"`
+}
+
+// ClassificationTypeRule assigns a document type to extensions
+type ClassificationTypeRule struct {
+	Extensions   []string `yaml:"extensions"`
+	DocumentType string   `yaml:"document_type"`
+}
+
+// Enabled reports whether any classification is configured
+func (c ClassificationConfig) Enabled() bool {
+	return len(c.Paths) > 0 || len(c.Types) > 0 || c.LLMFallback
+}
+
+// ToRulesConfig converts the configuration to analysis.RulesConfig, without
+// the fallback provider
+func (c ClassificationConfig) ToRulesConfig() analysis.RulesConfig {
+	rules := analysis.RulesConfig{MaxFallbacks: c.MaxFallbacks}
+	for _, rule := range c.Paths {
+		rules.Paths = append(rules.Paths, analysis.PathRule{Match: rule.Match, Portfolio: rule.Portfolio, Project: rule.Project})
+	}
+	for _, rule := range c.Types {
+		rules.Types = append(rules.Types, analysis.TypeRule{Extensions: rule.Extensions, DocumentType: rule.DocumentType})
+	}
+	return rules
+}
+
+// SeverityConfig grades each set of detected changes by the rules, and
+// alerts each grade through its own tier of channels
+type SeverityConfig struct {
+	// Rules are matched against every set of changes; the first rule of the
+	// highest level that applies grades it
+	Rules []SeverityRuleConfig `yaml:"rules"`
+	// Tiers are keyed by level: info, warning or critical
+	Tiers map[string]SeverityTierConfig `yaml:"tiers"`
+}
+
+// SeverityRuleConfig grades the changes meeting all of its conditions
+type SeverityRuleConfig struct {
+	Name       string   `yaml:"name"`
+	Level      string   `yaml:"level"`
+	Paths      []string `yaml:"paths"`
+	Extensions []string `yaml:"extensions"`
+	// Operations are change types: modified, deleted, renamed, shared_link
+	// or paper
+	Operations []string `yaml:"operations"`
+	MinSize    ByteSize `yaml:"min_size"`
+	// MinFiles is how many changes detected together must match
+	MinFiles int `yaml:"min_files"`
+}
+
+// SeverityTierConfig sets the channels alerted for one level and the
+// prefix of their subjects
+type SeverityTierConfig struct {
+	Channels      []string `yaml:"channels"`
+	SubjectPrefix string   `yaml:"subject_prefix"`
+}
+
+// Enabled reports whether any severity rules are configured
+func (s SeverityConfig) Enabled() bool {
+	return len(s.Rules) > 0
+}
+
+// ToRules converts the configuration to severity rules
+func (s SeverityConfig) ToRules() []severity.Rule {
+	rules := make([]severity.Rule, 0, len(s.Rules))
+	for _, rule := range s.Rules {
+		operations := make([]models.ChangeType, 0, len(rule.Operations))
+		for _, operation := range rule.Operations {
+			operations = append(operations, models.ChangeType(strings.ToLower(operation)))
+		}
+		rules = append(rules, severity.Rule{
+			Name:       rule.Name,
+			Level:      severity.Level(strings.ToLower(rule.Level)),
+			Paths:      rule.Paths,
+			Extensions: rule.Extensions,
+			Operations: operations,
+			MinSize:    int64(rule.MinSize),
+			MinFiles:   rule.MinFiles,
+		})
+	}
+	return rules
+}
+
+// ToTiers converts the configuration to severity tiers
+func (s SeverityConfig) ToTiers() map[severity.Level]severity.Tier {
+	tiers := make(map[severity.Level]severity.Tier, len(s.Tiers))
+	for level, tier := range s.Tiers {
+		tiers[severity.Level(strings.ToLower(level))] = severity.Tier{Channels: tier.Channels, SubjectPrefix: tier.SubjectPrefix}
+	}
+	return tiers
+}
+
+// AnomalyConfig watches the detected changes for the patterns of a
+// destructive incident, such as ransomware, and alerts them through their
+// own channels; a threshold of zero disables its pattern
+type AnomalyConfig struct {
+	// Window is how far back changes are counted
+	Window time.Duration `yaml:"window"`
+	// Cooldown is how long after an alert the same pattern is not alerted again
+	Cooldown time.Duration `yaml:"cooldown"`
+	// Deletions is how many deletions within the window raise an alert
+	Deletions int `yaml:"deletions"`
+	// Renames is how many renames to unknown extensions within the window
+	// raise an alert
+	Renames int `yaml:"renames"`
+	// KnownExtensions are extensions renames to which are expected, in
+	// addition to the common ones
+	KnownExtensions []string             `yaml:"known_extensions"`
+	Entropy         AnomalyEntropyConfig `yaml:"entropy"`
+	Alerts          AnomalyAlertsConfig  `yaml:"alerts"`
+}
+
+// AnomalyEntropyConfig detects files rewritten with content that looks
+// encrypted, downloading modified files to measure their entropy
+type AnomalyEntropyConfig struct {
+	// Files is how many such files within the window raise an alert
+	Files int `yaml:"files"`
+	// Threshold is the entropy in bits per byte, up to 8, above which
+	// content looks encrypted
+	Threshold   float64  `yaml:"threshold"`
+	MaxFileSize ByteSize `yaml:"max_file_size"`
+	// MaxFiles caps the files downloaded for each set of changes
+	MaxFiles int `yaml:"max_files"`
+}
+
+// AnomalyAlertsConfig is the channel anomaly alerts are sent through, kept
+// apart from the regular notifications so they reach whoever responds to
+// incidents
+type AnomalyAlertsConfig struct {
+	SubjectPrefix string `yaml:"subject_prefix"`
+	// Email lists the addresses alerted through email_config
+	Email           []string          `yaml:"email"`
+	SlackWebhookURL string            `yaml:"slack_webhook_url"`
+	WebhookURL      string            `yaml:"webhook_url"`
+	Headers         map[string]string `yaml:"headers"`
+	// Secret signs each webhook request body with HMAC-SHA256
+	Secret string `yaml:"secret"`
+}
+
+// Enabled reports whether any anomaly is watched for
+func (a AnomalyConfig) Enabled() bool {
+	return a.ToDetectorConfig().Enabled()
+}
+
+// ToDetectorConfig converts the configuration to anomaly detector settings
+func (a AnomalyConfig) ToDetectorConfig() anomaly.Config {
+	return anomaly.Config{
+		Window:           a.Window,
+		Cooldown:         a.Cooldown,
+		Deletions:        a.Deletions,
+		Renames:          a.Renames,
+		KnownExtensions:  a.KnownExtensions,
+		EntropyFiles:     a.Entropy.Files,
+		EntropyThreshold: a.Entropy.Threshold,
+		MaxFileBytes:     int64(a.Entropy.MaxFileSize),
+		MaxFiles:         a.Entropy.MaxFiles,
+		SubjectPrefix:    a.Alerts.SubjectPrefix,
+	}
+}
+
+// EnrichmentConfig holds the pattern rules that attach custom fields to
+// changes before they are stored
+type EnrichmentConfig struct {
+	// Patterns are tried in order; the first match for a field sets it
+	Patterns []EnrichmentPattern `yaml:"patterns"`
+}
+
+// EnrichmentPattern sets a field from a regular expression matched against
+// the path or, with source "name", the file name; the value may refer to
+// submatches such as $1
+type EnrichmentPattern struct {
+	Field  string `yaml:"field"`
+	Match  string `yaml:"match"`
+	Value  string `yaml:"value"`
+	Source string `yaml:"source"`
+}
+
+// ToPatternRules converts the configuration to enrich.PatternRule values
+func (e EnrichmentConfig) ToPatternRules() []enrich.PatternRule {
+	rules := make([]enrich.PatternRule, 0, len(e.Patterns))
+	for _, pattern := range e.Patterns {
+		rules = append(rules, enrich.PatternRule{Field: pattern.Field, Match: pattern.Match, Value: pattern.Value, Source: pattern.Source})
+	}
+	return rules
+}
+
+// DefaultFixturesDir is where API fixtures are kept when no directory is
+// configured
+const DefaultFixturesDir = "testdata/fixtures"
+
+// FixturesConfig records Dropbox API responses to fixture files, or replays
+// them so reporting and analysis can be developed offline. Replay needs no
+// Dropbox token.
+type FixturesConfig struct {
+	// Mode is "record" or "replay"; empty talks to Dropbox as usual
+	Mode string `yaml:"mode"`
+	Dir  string `yaml:"dir"`
+}
+
+// Replaying reports whether responses are served from fixtures
+func (f FixturesConfig) Replaying() bool {
+	return f.Mode == string(dropbox.FixtureReplay)
+}
+
+// ToFixtureConfig converts the configuration to dropbox.FixtureConfig
+func (f FixturesConfig) ToFixtureConfig() dropbox.FixtureConfig {
+	dir := f.Dir
+	if dir == "" {
+		dir = DefaultFixturesDir
+	}
+	return dropbox.FixtureConfig{Mode: dropbox.FixtureMode(f.Mode), Dir: dir}
+}
+
+// TeamConfig scopes a Dropbox Business team token to one member and,
+// optionally, a namespace such as the team space or a team folder, so
+// monitored paths are resolved within it
+type TeamConfig struct {
+	// MemberID is the team member ID, e.g. "dbmid:AAH..."; team tokens need
+	// one to read files
+	MemberID string `yaml:"member_id"`
+	// NamespaceID roots monitored paths at a namespace instead of the
+	// member's home folder
+	NamespaceID string `yaml:"namespace_id"`
+}
+
+// ToTeamConfig converts the configuration to dropbox.TeamConfig
+func (t TeamConfig) ToTeamConfig() dropbox.TeamConfig {
+	return dropbox.TeamConfig{MemberID: t.MemberID, NamespaceID: t.NamespaceID}
+}
+
+// DefaultSharingPollInterval is how often shared links and Paper docs are
+// polled when not configured
+const DefaultSharingPollInterval = 15 * time.Minute
+
+// SharingConfig reports new shared links and edited Paper docs as changes
+type SharingConfig struct {
+	SharedLinks  bool          `yaml:"shared_links"`
+	Paper        bool          `yaml:"paper"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+}
+
+// Enabled reports whether anything is watched
+func (s SharingConfig) Enabled() bool {
+	return s.SharedLinks || s.Paper
+}
+
+// GetPollInterval returns the poll interval, falling back to the default
+func (s SharingConfig) GetPollInterval() time.Duration {
+	if s.PollInterval <= 0 {
+		return DefaultSharingPollInterval
+	}
+	return s.PollInterval
+}
+
+// ToWatcherConfig converts the configuration to sharing.Config
+func (s SharingConfig) ToWatcherConfig() sharing.Config {
+	return sharing.Config{SharedLinks: s.SharedLinks, Paper: s.Paper}
+}
+
+// FileTypesConfig controls content sniffing for the file type statistics in
+// reports. Files whose type cannot be told from their extension are
+// downloaded when Sniff is set.
+type FileTypesConfig struct {
+	Sniff bool `yaml:"sniff"`
+	// MaxFileSize skips files larger than this
+	MaxFileSize ByteSize `yaml:"max_file_size"`
+	// MaxFiles caps how many files are downloaded for each report
+	MaxFiles int `yaml:"max_files"`
+}
+
+// ToSnifferConfig converts the configuration to analysis.SnifferConfig
+func (f FileTypesConfig) ToSnifferConfig() analysis.SnifferConfig {
+	return analysis.SnifferConfig{
+		MaxFileBytes: int64(f.MaxFileSize),
+		MaxFiles:     f.MaxFiles,
+	}
+}
+
+// ActionsConfig controls the signed acknowledge and mute links in folder alert
+// emails. Links are only added when a secret is set.
+type ActionsConfig struct {
+	// Secret signs the links; it must be at least 16 characters
+	Secret string `yaml:"secret"`
+	// BaseURL is the public address of the web server, e.g. https://monitor.example.com
+	BaseURL string `yaml:"base_url"`
+	// MuteDuration is how long a mute link silences a folder; it defaults to 24h
+	MuteDuration time.Duration `yaml:"mute_duration"`
+	// LinkTTL is how long links stay valid; it defaults to 7 days
+	LinkTTL time.Duration `yaml:"link_ttl"`
+}
+
+// Enabled reports whether action links are configured
+func (a ActionsConfig) Enabled() bool {
+	return a.Secret != ""
+}
+
+// ToServiceConfig converts the configuration to actions.Config
+func (a ActionsConfig) ToServiceConfig() actions.Config {
+	return actions.Config{
+		Secret:       a.Secret,
+		BaseURL:      a.BaseURL,
+		MuteDuration: a.MuteDuration,
+		LinkTTL:      a.LinkTTL,
+	}
+}
+
+// DigestConfig controls the daily and weekly digest reports built from the database
+type DigestConfig struct {
+	Daily  bool `yaml:"daily"`
+	Weekly bool `yaml:"weekly"`
+	// ReportType is narrative (plain text, the default), html or markdown
+	ReportType string `yaml:"report_type"`
+}
+
+// GetReportType returns the digest report type, defaulting to plain text
+func (d DigestConfig) GetReportType() models.ReportType {
+	if d.ReportType == "" {
+		return models.NarrativeReport
+	}
+	return models.ReportType(d.ReportType)
+}
+
+// ReportConfig controls how reports, digests, email subjects and the web
+// dashboard are written
+type ReportConfig struct {
+	// Language is en (English, the default), af (Afrikaans) or nl (Dutch);
+	// a region such as nl-BE is ignored
+	Language string `yaml:"language"`
+	// Links links the changed files in HTML and Markdown reports
+	Links ReportLinksConfig `yaml:"links"`
+	// ExecutiveSummary leads narrative, HTML and Markdown reports with a
+	// summary written by the AI provider; it requires ai to be enabled
+	ExecutiveSummary bool `yaml:"executive_summary"`
+	// Topics adds the top keywords and topics of changed documents to
+	// reports; it requires ai to be enabled
+	Topics ReportTopicsConfig `yaml:"topics"`
+}
+
+// ReportTopicsConfig controls the keyword and topic tables of reports, which
+// count the keywords and topics the AI provider finds in changed documents
+type ReportTopicsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxFiles caps how many documents are analyzed for each report
+	MaxFiles int `yaml:"max_files"`
+}
+
+// ReportLinksConfig controls the links to changed files in reports. Enabled
+// links files on the Dropbox website, which opens them for a signed-in
+// owner; the other options imply it.
+type ReportLinksConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// SharedLinks links files through their existing shared link
+	SharedLinks bool `yaml:"shared_links"`
+	// TemporaryLinks links files without a shared link through a download
+	// link that expires after four hours
+	TemporaryLinks bool `yaml:"temporary_links"`
+	// Thumbnails previews changed images in HTML reports
+	Thumbnails bool `yaml:"thumbnails"`
+	// ThumbnailSize is w32h32 to w2048h1536; w128h128 by default
+	ThumbnailSize string `yaml:"thumbnail_size"`
+	// MaxFiles caps the files linked in each report; 50 by default
+	MaxFiles int `yaml:"max_files"`
+}
+
+// IsEnabled reports whether changed files are linked in reports
+func (l ReportLinksConfig) IsEnabled() bool {
+	return l.Enabled || l.SharedLinks || l.TemporaryLinks || l.Thumbnails
+}
+
+// ToPreviewConfig converts the configuration to preview.Config
+func (l ReportLinksConfig) ToPreviewConfig() preview.Config {
+	return preview.Config{
+		SharedLinks:    l.SharedLinks,
+		TemporaryLinks: l.TemporaryLinks,
+		Thumbnails:     l.Thumbnails,
+		ThumbnailSize:  l.ThumbnailSize,
+		MaxFiles:       l.MaxFiles,
+	}
+}
+
+// LoggingConfig controls the log level and format, and logging to a rotated
+// file in addition to stderr
+type LoggingConfig struct {
+	// Level is debug, info (the default), warn or error
+	Level string `yaml:"level"`
+	// Format is text (the default) or json
+	Format string `yaml:"format"`
+	// File enables file logging when set
+	File string `yaml:"file"`
+	// MaxSizeMB rotates the file when it reaches this size
+	MaxSizeMB int `yaml:"max_size_mb"`
+	// MaxAge rotates the file once it is this old, e.g. 24h
+	MaxAge time.Duration `yaml:"max_age"`
+	// MaxBackups is how many rotated files are kept
+	MaxBackups int `yaml:"max_backups"`
+	// Compress gzips rotated files
+	Compress bool `yaml:"compress"`
+}
+
+// ToOptions converts the configuration to logging.Options
+func (l LoggingConfig) ToOptions() logging.Options {
+	return logging.Options{
+		Path:       l.File,
+		MaxSize:    int64(l.MaxSizeMB) * 1024 * 1024,
+		MaxAge:     l.MaxAge,
+		MaxBackups: l.MaxBackups,
+		Compress:   l.Compress,
+	}
+}
+
+// ToLoggerOptions converts the configuration to logging.LoggerOptions that
+// redact secrets. The level is assumed valid, as checked by Validate.
+func (l LoggingConfig) ToLoggerOptions(secrets []string) logging.LoggerOptions {
+	level, _ := logging.ParseLevel(l.Level)
+	return logging.LoggerOptions{Level: level, Format: l.Format, Secrets: secrets}
+}
+
+// AccountConfig holds configuration for one Dropbox account in multi-account
+// mode. The monitored folders are checked in every configured account with
+// the account's own token, and their changes carry the account's ID; the
+// top-level token is still used for the monitor's other Dropbox requests.
+type AccountConfig struct {
+	ID           string `yaml:"id"`
+	Name         string `yaml:"name"`
+	DropboxToken string `yaml:"dropbox_token"`
+}
+
+// SavedQueryConfig defines a named filter over file changes and the schedule
+// on which a custom report of its matches is sent
+type SavedQueryConfig struct {
+	Name       string        `yaml:"name"`
+	PathPrefix string        `yaml:"path_prefix"`
+	Extensions []string      `yaml:"extensions"`
+	Authors    []string      `yaml:"authors"`
+	Schedule   time.Duration `yaml:"schedule"`
+	ReportType string        `yaml:"report_type"`
+}
+
+// WebhookConfig holds Dropbox webhook configuration
+type WebhookConfig struct {
+	AppSecret    string        `yaml:"app_secret"`
+	AppSecrets   []string      `yaml:"app_secrets"`
+	ReplayWindow time.Duration `yaml:"replay_window"`
+}
+
+// Secrets returns every configured app secret; during rotation both the old
+// and new secret are accepted
+func (w WebhookConfig) Secrets() []string {
+	secrets := make([]string, 0, len(w.AppSecrets)+1)
+	if w.AppSecret != "" {
+		secrets = append(secrets, w.AppSecret)
+	}
+	for _, secret := range w.AppSecrets {
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
 }
 
 // DropboxConfig holds Dropbox-specific configuration
@@ -31,20 +861,169 @@ type DropboxConfig struct {
 	PollInterval time.Duration `yaml:"poll_interval"`
 }
 
-// DatabaseConfig holds database configuration
+// DatabaseConfig holds database configuration. Driver is sqlite (the
+// default), which stores everything in the file at Path, or postgres, which
+// connects to URL and needs the pgvector extension.
 type DatabaseConfig struct {
-	Path string `yaml:"path"`
+	Driver string `yaml:"driver"`
+	Path   string `yaml:"path"`
+	URL    string `yaml:"url"`
+	// BatchSize is how many file changes are written per transaction
+	BatchSize int `yaml:"batch_size"`
+	// Contents sets how much of each analyzed file's text is stored
+	Contents ContentsConfig `yaml:"contents"`
+}
+
+// ContentsConfig sets what is stored of the text of analyzed files: mode is
+// none, preview (the default), which keeps the first preview_bytes, or full
+type ContentsConfig struct {
+	Mode         string `yaml:"mode"`
+	PreviewBytes int    `yaml:"preview_bytes"`
+}
+
+// ToContentPolicy converts the configuration to a db.ContentPolicy
+func (c ContentsConfig) ToContentPolicy() db.ContentPolicy {
+	mode := db.ContentMode(c.Mode)
+	if mode == "" {
+		mode = db.ContentPreview
+	}
+	return db.ContentPolicy{Mode: mode, PreviewBytes: c.PreviewBytes}
+}
+
+// Database drivers
+const (
+	DatabaseDriverSQLite   = "sqlite"
+	DatabaseDriverPostgres = "postgres"
+)
+
+// GetDriver returns the database driver, defaulting to sqlite
+func (d DatabaseConfig) GetDriver() string {
+	if d.Driver == "" {
+		return DatabaseDriverSQLite
+	}
+	return d.Driver
+}
+
+// GetBatchSize returns how many file changes are written per transaction,
+// defaulting to db.DefaultWriteBatchSize
+func (d DatabaseConfig) GetBatchSize() int {
+	if d.BatchSize <= 0 {
+		return db.DefaultWriteBatchSize
+	}
+	return d.BatchSize
+}
+
+// GetURL returns the PostgreSQL connection URL, falling back to the
+// DATABASE_URL environment variable
+func (d DatabaseConfig) GetURL() string {
+	if d.URL != "" {
+		return d.URL
+	}
+	return os.Getenv("DATABASE_URL")
+}
+
+// GetDSN returns what the driver connects to: the URL for postgres and the
+// file path for sqlite
+func (d DatabaseConfig) GetDSN() string {
+	if d.GetDriver() == DatabaseDriverPostgres {
+		return d.GetURL()
+	}
+	return d.Path
 }
 
 // WebConfig holds web server configuration
 type WebConfig struct {
-	Address string `yaml:"address"`
+	Address  string `yaml:"address"`
+	APIToken string `yaml:"api_token"`
 }
 
-// MonitoringConfig holds monitoring configuration
+// MonitoringConfig holds monitoring configuration. Include and Exclude are
+// glob filters applied to every folder: a folder's own include list replaces
+// the global one, while exclude lists are combined.
 type MonitoringConfig struct {
-	Enabled bool   `yaml:"enabled"`
-	Path    string `yaml:"path"`
+	Enabled bool                    `yaml:"enabled"`
+	Path    string                  `yaml:"path"`
+	Folders []MonitoredFolderConfig `yaml:"folders"`
+	Include []string                `yaml:"include"`
+	Exclude []string                `yaml:"exclude"`
+	// FirstRun decides what the first check of a folder reports: baseline
+	// (the default) records the current state silently, summary sends one
+	// notification describing the existing files and full reports every
+	// existing file as a change
+	FirstRun string `yaml:"first_run"`
+	// Adaptive adjusts each folder's poll interval to its activity
+	Adaptive AdaptivePollingConfig `yaml:"adaptive"`
+}
+
+// AdaptivePollingConfig adjusts how often folders are checked to their
+// activity: a check that finds changes brings a folder's interval down to
+// MinInterval, and each check that finds none doubles it up to MaxInterval.
+// With Longpoll, folders are also checked as soon as Dropbox reports a change.
+type AdaptivePollingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MinInterval defaults to the folder's poll interval
+	MinInterval time.Duration `yaml:"min_interval"`
+	// MaxInterval defaults to core.DefaultMaxPollInterval
+	MaxInterval     time.Duration `yaml:"max_interval"`
+	Longpoll        bool          `yaml:"longpoll"`
+	LongpollTimeout time.Duration `yaml:"longpoll_timeout"`
+}
+
+// ToAdaptivePolling converts the configuration to core.AdaptivePolling, or
+// nil when adaptive polling is disabled
+func (a AdaptivePollingConfig) ToAdaptivePolling() *core.AdaptivePolling {
+	if !a.Enabled {
+		return nil
+	}
+	return &core.AdaptivePolling{
+		MinInterval:     a.MinInterval,
+		MaxInterval:     a.MaxInterval,
+		Longpoll:        a.Longpoll,
+		LongpollTimeout: a.LongpollTimeout,
+	}
+}
+
+// MonitoredFolderConfig holds the settings for one monitored folder
+type MonitoredFolderConfig struct {
+	Path         string        `yaml:"path"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// Recursive includes subfolders; it defaults to true
+	Recursive  *bool    `yaml:"recursive"`
+	Include    []string `yaml:"include"`
+	Exclude    []string `yaml:"exclude"`
+	Recipients []string `yaml:"recipients"`
+}
+
+// IsRecursive reports whether subfolders are monitored
+func (f MonitoredFolderConfig) IsRecursive() bool {
+	return f.Recursive == nil || *f.Recursive
+}
+
+// GetFolders returns the monitored folders, falling back to a single
+// recursive folder at Path when none are listed
+func (m MonitoringConfig) GetFolders() []MonitoredFolderConfig {
+	if len(m.Folders) > 0 {
+		return m.Folders
+	}
+	return []MonitoredFolderConfig{{Path: m.Path}}
+}
+
+// GetFirstRun returns the first run policy, defaulting to a silent baseline
+func (m MonitoringConfig) GetFirstRun() core.FirstRunPolicy {
+	if m.FirstRun == "" {
+		return core.FirstRunBaseline
+	}
+	return core.FirstRunPolicy(m.FirstRun)
+}
+
+// FolderFilters returns the include and exclude globs in effect for a folder
+func (m MonitoringConfig) FolderFilters(folder MonitoredFolderConfig) (include, exclude []string) {
+	include = folder.Include
+	if len(include) == 0 {
+		include = m.Include
+	}
+	exclude = append(append([]string{}, m.Exclude...), folder.Exclude...)
+	return include, exclude
 }
 
 // StateConfig holds state management configuration
@@ -60,16 +1039,189 @@ type RetryConfig struct {
 
 // NotifyConfig holds notification configuration
 type NotifyConfig struct {
-	Enabled   bool     `yaml:"enabled"`
-	SMTPHost  string   `yaml:"smtp_host"`
-	SMTPPort  int      `yaml:"smtp_port"`
-	FromEmail string   `yaml:"from_email"`
-	ToEmails  []string `yaml:"to_emails"`
+	Enabled   bool                 `yaml:"enabled"`
+	SMTPHost  string               `yaml:"smtp_host"`
+	SMTPPort  int                  `yaml:"smtp_port"`
+	FromEmail string               `yaml:"from_email"`
+	ToEmails  []string             `yaml:"to_emails"`
+	Channels  NotifyChannelsConfig `yaml:"channels"`
+	// Recipients sends each kind of notification to its own email list
+	Recipients NotifyRecipientsConfig `yaml:"recipients"`
+	// RetryInterval is how often queued notifications are retried
+	RetryInterval time.Duration `yaml:"retry_interval"`
+	// RetryBackoff is how long a failed notification waits before its
+	// first retry, doubling after each further failure up to MaxRetryBackoff
+	RetryBackoff    time.Duration `yaml:"retry_backoff"`
+	MaxRetryBackoff time.Duration `yaml:"max_retry_backoff"`
+	// MaxAttempts is how many times a notification is tried before it is
+	// dead-lettered and no longer retried
+	MaxAttempts int `yaml:"max_attempts"`
+}
+
+// Defaults of the notification retry queue
+const (
+	// DefaultNotifyRetryInterval is how often failed notifications are retried when not configured
+	DefaultNotifyRetryInterval = 5 * time.Minute
+	// DefaultNotifyRetryBackoff is the wait before the first retry when not configured
+	DefaultNotifyRetryBackoff = time.Minute
+	// DefaultNotifyMaxRetryBackoff caps the wait between retries when not configured
+	DefaultNotifyMaxRetryBackoff = time.Hour
+	// DefaultNotifyMaxAttempts is how many times a notification is tried
+	// when not configured
+	DefaultNotifyMaxAttempts = 10
+)
+
+// GetRetryInterval returns the retry interval, falling back to the default
+func (n NotifyConfig) GetRetryInterval() time.Duration {
+	if n.RetryInterval <= 0 {
+		return DefaultNotifyRetryInterval
+	}
+	return n.RetryInterval
+}
+
+// GetRetryBackoff returns the wait before the first retry, falling back to the default
+func (n NotifyConfig) GetRetryBackoff() time.Duration {
+	if n.RetryBackoff <= 0 {
+		return DefaultNotifyRetryBackoff
+	}
+	return n.RetryBackoff
+}
+
+// GetMaxRetryBackoff returns the longest wait between retries, falling back
+// to the default
+func (n NotifyConfig) GetMaxRetryBackoff() time.Duration {
+	if n.MaxRetryBackoff <= 0 {
+		return DefaultNotifyMaxRetryBackoff
+	}
+	return n.MaxRetryBackoff
+}
+
+// GetMaxAttempts returns how many times a notification is tried, falling
+// back to the default
+func (n NotifyConfig) GetMaxAttempts() int {
+	if n.MaxAttempts <= 0 {
+		return DefaultNotifyMaxAttempts
+	}
+	return n.MaxAttempts
+}
+
+// Audience is a kind of notification that may go to its own recipients
+type Audience string
+
+const (
+	// AudienceRealtime receives change reports and alerts as they happen
+	AudienceRealtime Audience = "realtime"
+	// AudienceDaily receives the daily digest
+	AudienceDaily Audience = "daily"
+	// AudienceWeekly receives the weekly digest
+	AudienceWeekly Audience = "weekly"
+)
+
+// NotifyRecipientsConfig holds an email distribution list for each
+// audience. Empty lists fall back to email_config.to_addresses.
+type NotifyRecipientsConfig struct {
+	Realtime []string `yaml:"realtime"`
+	Daily    []string `yaml:"daily"`
+	Weekly   []string `yaml:"weekly"`
+}
+
+// For returns the recipients configured for an audience
+func (r NotifyRecipientsConfig) For(audience Audience) []string {
+	switch audience {
+	case AudienceRealtime:
+		return r.Realtime
+	case AudienceDaily:
+		return r.Daily
+	case AudienceWeekly:
+		return r.Weekly
+	}
+	return nil
 }
 
-// HealthCheckConfig holds health check configuration
+// EmailRecipients returns the addresses an audience is emailed at
+func (c *Config) EmailRecipients(audience Audience) []string {
+	if recipients := c.Notify.Recipients.For(audience); len(recipients) > 0 {
+		return recipients
+	}
+	if c.EmailConfig == nil {
+		return nil
+	}
+	return c.EmailConfig.ToAddresses
+}
+
+// NotifyChannelsConfig selects the channels notifications are sent through
+type NotifyChannelsConfig struct {
+	Email   EmailChannelConfig   `yaml:"email"`
+	Slack   SlackChannelConfig   `yaml:"slack"`
+	Webhook WebhookChannelConfig `yaml:"webhook"`
+}
+
+// EmailChannelConfig controls delivery through email_config
+type EmailChannelConfig struct {
+	// Enabled defaults to true so existing email-only setups keep working
+	Enabled *bool `yaml:"enabled"`
+}
+
+// IsEnabled reports whether email delivery is enabled
+func (e EmailChannelConfig) IsEnabled() bool {
+	return e.Enabled == nil || *e.Enabled
+}
+
+// SlackChannelConfig configures delivery to a Slack incoming webhook
+type SlackChannelConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	WebhookURL string `yaml:"webhook_url"`
+}
+
+// WebhookChannelConfig configures delivery as JSON to HTTP endpoints
+type WebhookChannelConfig struct {
+	Enabled bool              `yaml:"enabled"`
+	URL     string            `yaml:"url"`
+	URLs    []string          `yaml:"urls"`
+	Headers map[string]string `yaml:"headers"`
+	// Secret signs each request body with HMAC-SHA256
+	Secret string `yaml:"secret"`
+	// MaxRetries is how often a failed delivery is retried; negative disables retries
+	MaxRetries int `yaml:"max_retries"`
+	// RetryBackoff is the delay before the first retry; it doubles each attempt
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+}
+
+// Endpoints returns url followed by urls, without duplicates
+func (w WebhookChannelConfig) Endpoints() []string {
+	var endpoints []string
+	seen := make(map[string]bool)
+	for _, url := range append([]string{w.URL}, w.URLs...) {
+		if url != "" && !seen[url] {
+			seen[url] = true
+			endpoints = append(endpoints, url)
+		}
+	}
+	return endpoints
+}
+
+// HealthCheckConfig holds health check configuration. Components failing
+// their health check are restarted, and a notification is sent once one has
+// failed AlertAfter checks in a row.
 type HealthCheckConfig struct {
 	Interval time.Duration `yaml:"interval"`
+	// RestartBackoff is how long a failed component waits before it is
+	// restarted again, doubling after each further failure up to
+	// MaxRestartBackoff
+	RestartBackoff    time.Duration `yaml:"restart_backoff"`
+	MaxRestartBackoff time.Duration `yaml:"max_restart_backoff"`
+	AlertAfter        int           `yaml:"alert_after"`
+}
+
+// ToSupervisorConfig converts the configuration to lifecycle.SupervisorConfig;
+// unset values use the supervisor's defaults
+func (h HealthCheckConfig) ToSupervisorConfig() lifecycle.SupervisorConfig {
+	return lifecycle.SupervisorConfig{
+		Interval:   h.Interval,
+		Backoff:    h.RestartBackoff,
+		MaxBackoff: h.MaxRestartBackoff,
+		AlertAfter: h.AlertAfter,
+	}
 }
 
 // EmailConfig represents email notification configuration
@@ -80,18 +1232,429 @@ type EmailConfig struct {
 	SMTPPassword string   `yaml:"smtp_password"`
 	FromAddress  string   `yaml:"from_address"`
 	ToAddresses  []string `yaml:"to_addresses"`
+	// AttachCSV attaches the changes of each report as a CSV file
+	AttachCSV bool `yaml:"attach_csv"`
+	// TLSMode is tls (implicit TLS), starttls (required upgrade) or none.
+	// When empty, STARTTLS is used if the server offers it.
+	TLSMode string `yaml:"tls_mode"`
+	// CAFile is a PEM bundle used instead of the system roots to verify the server
+	CAFile string `yaml:"ca_file"`
+	// AuthMethod is plain (the default), login or cram-md5
+	AuthMethod string `yaml:"auth_method"`
+	// Timeout bounds connecting and each exchange with the server
+	Timeout time.Duration `yaml:"timeout"`
+	// Bounces configures how bouncing recipients are detected and disabled
+	Bounces BounceConfig `yaml:"bounces"`
+}
+
+// BounceConfig configures bounce handling
+type BounceConfig struct {
+	// MaxBounces is how many hard bounces in a row disable a recipient;
+	// zero uses the default of 3
+	MaxBounces int `yaml:"max_bounces"`
+	// Mailbox, when its host is set, is read over IMAP for bounce messages
+	Mailbox BounceMailboxConfig `yaml:"mailbox"`
+}
+
+// BounceMailboxConfig is the IMAP mailbox that receives bounce messages.
+// Every unread message in the folder is read and marked as read, so it
+// should be a mailbox used for nothing else.
+type BounceMailboxConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// Folder defaults to INBOX
+	Folder string `yaml:"folder"`
+	// TLSMode is tls (the default) or none
+	TLSMode string `yaml:"tls_mode"`
+	// CheckInterval is how often the mailbox is read; defaults to 15 minutes
+	CheckInterval time.Duration `yaml:"check_interval"`
+}
+
+// Bounce mailbox defaults
+const (
+	DefaultBounceFolder        = "INBOX"
+	DefaultBounceCheckInterval = 15 * time.Minute
+)
+
+// Enabled reports whether a bounce mailbox is configured
+func (m BounceMailboxConfig) Enabled() bool {
+	return m.Host != ""
+}
+
+// GetPort returns the IMAP port, defaulting to 993 with TLS and 143 without
+func (m BounceMailboxConfig) GetPort() int {
+	if m.Port > 0 {
+		return m.Port
+	}
+	if m.TLSMode == SMTPTLSNone {
+		return 143
+	}
+	return 993
+}
+
+// GetFolder returns the folder to read, defaulting to INBOX
+func (m BounceMailboxConfig) GetFolder() string {
+	if m.Folder == "" {
+		return DefaultBounceFolder
+	}
+	return m.Folder
+}
+
+// GetCheckInterval returns how often to read the mailbox
+func (m BounceMailboxConfig) GetCheckInterval() time.Duration {
+	if m.CheckInterval <= 0 {
+		return DefaultBounceCheckInterval
+	}
+	return m.CheckInterval
+}
+
+// SMTP TLS modes
+const (
+	SMTPTLSImplicit = "tls"
+	SMTPTLSStartTLS = "starttls"
+	SMTPTLSNone     = "none"
+)
+
+// SMTP authentication methods
+const (
+	SMTPAuthPlain   = "plain"
+	SMTPAuthLogin   = "login"
+	SMTPAuthCRAMMD5 = "cram-md5"
+)
+
+// DefaultSMTPTimeout bounds SMTP exchanges when no timeout is configured
+const DefaultSMTPTimeout = 30 * time.Second
+
+// GetTimeout returns the SMTP timeout, falling back to the default
+func (e EmailConfig) GetTimeout() time.Duration {
+	if e.Timeout <= 0 {
+		return DefaultSMTPTimeout
+	}
+	return e.Timeout
 }
 
 // Validate validates the configuration
 func (c *Config) Validate() error {
 	// Validate Dropbox configuration
-	if c.DropboxToken == "" {
-		return fmt.Errorf("dropbox configuration error: access token is required")
+	if c.DropboxToken == "" && c.DropboxRefreshToken == "" && !c.Fixtures.Replaying() {
+		return fmt.Errorf("dropbox configuration error: access token or refresh token is required")
+	}
+	if c.DropboxRefreshToken != "" && c.DropboxAppKey == "" {
+		return fmt.Errorf("dropbox configuration error: app key is required when using a refresh token")
 	}
 	if c.PollInterval <= 0 {
 		return fmt.Errorf("dropbox configuration error: poll interval must be positive")
 	}
 
+	// Validate account configuration
+	seen := make(map[string]bool)
+	for i, account := range c.Accounts {
+		if account.ID == "" {
+			return fmt.Errorf("account configuration error: account %d has no id", i)
+		}
+		if seen[account.ID] {
+			return fmt.Errorf("account configuration error: duplicate account id %q", account.ID)
+		}
+		seen[account.ID] = true
+		if account.DropboxToken == "" {
+			return fmt.Errorf("account configuration error: access token is required for account %q", account.ID)
+		}
+	}
+
+	// Validate webhook configuration
+	if c.Webhook.ReplayWindow < 0 {
+		return fmt.Errorf("webhook configuration error: replay window cannot be negative")
+	}
+
+	// Validate limits configuration
+	if c.Limits.MaxConcurrentDownloads < 0 || c.Limits.MaxContentBytes < 0 || c.Limits.MaxReportBytes < 0 ||
+		c.Limits.MinFreeDisk < 0 || c.Limits.DiskCheckInterval < 0 ||
+		c.Limits.RateLimitPauseAfter < 0 || c.Limits.RateLimitPause < 0 || c.Limits.APIBurst < 0 ||
+		c.Limits.MaxConcurrentListings < 0 {
+		return fmt.Errorf("limits configuration error: limits cannot be negative")
+	}
+
+	// Validate logging configuration
+	if c.Logging.MaxSizeMB < 0 || c.Logging.MaxAge < 0 || c.Logging.MaxBackups < 0 {
+		return fmt.Errorf("logging configuration error: rotation limits cannot be negative")
+	}
+	if _, err := logging.ParseLevel(c.Logging.Level); err != nil {
+		return fmt.Errorf("logging configuration error: %w", err)
+	}
+	switch c.Logging.Format {
+	case "", logging.FormatText, logging.FormatJSON:
+	default:
+		return fmt.Errorf("logging configuration error: unknown format %q", c.Logging.Format)
+	}
+
+	// Validate monitored folders
+	switch c.Monitoring.GetFirstRun() {
+	case core.FirstRunBaseline, core.FirstRunSummary, core.FirstRunFull:
+	default:
+		return fmt.Errorf("monitoring configuration error: first_run must be baseline, summary or full")
+	}
+	if err := filter.Validate(append(append([]string{}, c.Monitoring.Include...), c.Monitoring.Exclude...)); err != nil {
+		return fmt.Errorf("monitoring configuration error: invalid filter: %w", err)
+	}
+	if adaptive := c.Monitoring.Adaptive; adaptive.Enabled {
+		if adaptive.MinInterval < 0 || adaptive.MaxInterval < 0 {
+			return fmt.Errorf("monitoring configuration error: adaptive poll intervals cannot be negative")
+		}
+		if adaptive.MinInterval > 0 && adaptive.MaxInterval > 0 && adaptive.MinInterval > adaptive.MaxInterval {
+			return fmt.Errorf("monitoring configuration error: adaptive min_interval cannot exceed max_interval")
+		}
+		if adaptive.LongpollTimeout != 0 &&
+			(adaptive.LongpollTimeout < dropbox.MinLongpollTimeout || adaptive.LongpollTimeout > dropbox.MaxLongpollTimeout) {
+			return fmt.Errorf("monitoring configuration error: longpoll_timeout must be between %s and %s",
+				dropbox.MinLongpollTimeout, dropbox.MaxLongpollTimeout)
+		}
+	}
+	folderPaths := make(map[string]bool)
+	for i, folder := range c.Monitoring.Folders {
+		key := strings.ToLower(strings.TrimSuffix(folder.Path, "/"))
+		if folderPaths[key] {
+			return fmt.Errorf("monitoring configuration error: duplicate folder %q", folder.Path)
+		}
+		folderPaths[key] = true
+		if folder.Path != "" && !strings.HasPrefix(folder.Path, "/") {
+			return fmt.Errorf("monitoring configuration error: folder %d must have an absolute path", i)
+		}
+		if folder.PollInterval < 0 {
+			return fmt.Errorf("monitoring configuration error: poll interval for %q cannot be negative", folder.Path)
+		}
+		if err := filter.Validate(append(append([]string{}, folder.Include...), folder.Exclude...)); err != nil {
+			return fmt.Errorf("monitoring configuration error: invalid filter for %q: %w", folder.Path, err)
+		}
+		if len(folder.Recipients) > 0 && c.EmailConfig == nil {
+			return fmt.Errorf("monitoring configuration error: recipients for %q require email configuration", folder.Path)
+		}
+	}
+
+	// Validate notification recipients
+	for _, audience := range []Audience{AudienceRealtime, AudienceDaily, AudienceWeekly} {
+		if len(c.Notify.Recipients.For(audience)) > 0 && c.EmailConfig == nil {
+			return fmt.Errorf("notify configuration error: %s recipients require email configuration", audience)
+		}
+	}
+
+	// Validate diff configuration
+	if c.Diffs.MaxFileSize < 0 || c.Diffs.MaxFiles < 0 {
+		return fmt.Errorf("diff configuration error: limits cannot be negative")
+	}
+
+	// Validate embeddings configuration
+	if c.Embeddings.Dimensions < 0 || c.Embeddings.MaxFileSize < 0 || c.Embeddings.MaxFiles < 0 || c.Embeddings.MaxChars < 0 {
+		return fmt.Errorf("embeddings configuration error: limits cannot be negative")
+	}
+	if c.Embeddings.URL != "" && !isHTTPURL(c.Embeddings.URL) {
+		return fmt.Errorf("embeddings configuration error: url must be an http(s) URL")
+	}
+	if c.Embeddings.Enabled {
+		if _, err := embeddings.New(c.Embeddings.ToEmbeddingsConfig()); err != nil {
+			return fmt.Errorf("embeddings configuration error: %w", err)
+		}
+	}
+
+	// Validate AI configuration
+	if c.AI.MaxOutputTokens < 0 || c.AI.RequestsPerMinute < -1 || c.AI.DailyTokenBudget < 0 || c.AI.MaxChars < 0 ||
+		c.AI.MaxFileSize < 0 || c.AI.CacheSize < 0 {
+		return fmt.Errorf("ai configuration error: limits cannot be negative")
+	}
+	if c.AI.URL != "" && !isHTTPURL(c.AI.URL) {
+		return fmt.Errorf("ai configuration error: url must be an http(s) URL")
+	}
+	if c.AI.Enabled {
+		if _, err := analysis.NewProvider(c.AI.ToProviderConfig()); err != nil {
+			return fmt.Errorf("ai configuration error: %w", err)
+		}
+	}
+
+	// Validate classification rules
+	if _, err := analysis.NewRules(c.Classification.ToRulesConfig()); err != nil {
+		return fmt.Errorf("classification configuration error: %w", err)
+	}
+	if _, err := enrich.NewPatterns(c.Enrichment.ToPatternRules()); err != nil {
+		return fmt.Errorf("enrichment configuration error: %w", err)
+	}
+	if c.Classification.MaxFallbacks < 0 {
+		return fmt.Errorf("classification configuration error: max fallbacks cannot be negative")
+	}
+	if c.Classification.LLMFallback && !c.AI.Enabled {
+		return fmt.Errorf("classification configuration error: llm fallback requires ai to be enabled")
+	}
+
+	// Validate severity rules and tiers
+	if _, err := severity.NewClassifier(c.Severity.ToRules()); err != nil {
+		return fmt.Errorf("severity configuration error: %w", err)
+	}
+	for _, rule := range c.Severity.Rules {
+		for _, operation := range rule.Operations {
+			switch models.ChangeType(strings.ToLower(operation)) {
+			case models.ChangeModified, models.ChangeDeleted, models.ChangeRenamed, models.ChangeSharedLink, models.ChangePaper:
+			default:
+				return fmt.Errorf("severity configuration error: rule %q has unknown operation %q", rule.Name, operation)
+			}
+		}
+	}
+	for level, tier := range c.Severity.Tiers {
+		if _, err := severity.ParseLevel(level); err != nil {
+			return fmt.Errorf("severity configuration error: %w", err)
+		}
+		for _, channel := range tier.Channels {
+			switch channel {
+			case "email", "slack", "webhook":
+			default:
+				return fmt.Errorf("severity configuration error: %s tier has unknown channel %q", level, channel)
+			}
+		}
+	}
+
+	// Validate anomaly detection and its alert channel
+	if err := c.Anomaly.ToDetectorConfig().Validate(); err != nil {
+		return fmt.Errorf("anomaly configuration error: %w", err)
+	}
+	if c.Anomaly.Enabled() {
+		alerts := c.Anomaly.Alerts
+		if len(alerts.Email) == 0 && alerts.SlackWebhookURL == "" && alerts.WebhookURL == "" {
+			return fmt.Errorf("anomaly configuration error: alerts need an email address, slack webhook url or webhook url")
+		}
+		if len(alerts.Email) > 0 && c.EmailConfig == nil {
+			return fmt.Errorf("anomaly configuration error: email alerts require email_config")
+		}
+		for _, url := range []string{alerts.SlackWebhookURL, alerts.WebhookURL} {
+			if url != "" && !isHTTPURL(url) {
+				return fmt.Errorf("anomaly configuration error: alert url %q is not an http(s) URL", url)
+			}
+		}
+	}
+
+	// Validate file type configuration
+	if c.FileTypes.MaxFileSize < 0 || c.FileTypes.MaxFiles < 0 {
+		return fmt.Errorf("file type configuration error: limits cannot be negative")
+	}
+
+	// Validate quota configuration
+	if c.Quotas.ScanInterval < 0 {
+		return fmt.Errorf("quota configuration error: scan interval cannot be negative")
+	}
+	quotaPaths := make(map[string]bool)
+	for i, folder := range c.Quotas.Folders {
+		if !strings.HasPrefix(folder.Path, "/") {
+			return fmt.Errorf("quota configuration error: folder %d must have an absolute path", i)
+		}
+		if quotaPaths[folder.Path] {
+			return fmt.Errorf("quota configuration error: duplicate folder %q", folder.Path)
+		}
+		quotaPaths[folder.Path] = true
+		if folder.MaxSize <= 0 {
+			return fmt.Errorf("quota configuration error: max size for %q must be positive", folder.Path)
+		}
+		if folder.ProjectionDays < 0 {
+			return fmt.Errorf("quota configuration error: projection days for %q cannot be negative", folder.Path)
+		}
+	}
+
+	// Validate fixture configuration
+	switch dropbox.FixtureMode(c.Fixtures.Mode) {
+	case dropbox.FixtureOff, dropbox.FixtureRecord, dropbox.FixtureReplay:
+	default:
+		return fmt.Errorf("fixtures configuration error: mode must be record or replay")
+	}
+
+	// Validate team configuration
+	if c.Team.MemberID != "" && !strings.HasPrefix(c.Team.MemberID, "dbmid:") {
+		return fmt.Errorf("team configuration error: member_id must start with dbmid:")
+	}
+	if c.Team.NamespaceID != "" && strings.Trim(c.Team.NamespaceID, "0123456789") != "" {
+		return fmt.Errorf("team configuration error: namespace_id must be numeric")
+	}
+
+	// Validate sharing configuration
+	if c.Sharing.PollInterval < 0 {
+		return fmt.Errorf("sharing configuration error: poll interval cannot be negative")
+	}
+
+	// Validate team log configuration
+	if c.TeamLog.PollInterval < 0 {
+		return fmt.Errorf("team log configuration error: poll interval cannot be negative")
+	}
+	if c.TeamLog.IncludeInReports && !c.TeamLog.Enabled {
+		return fmt.Errorf("team log configuration error: include_in_reports requires the team log to be enabled")
+	}
+
+	// Validate feature flags
+	flags := make([]string, 0, len(c.Features))
+	for name := range c.Features {
+		flags = append(flags, name)
+	}
+	if err := features.Validate(flags); err != nil {
+		return fmt.Errorf("features configuration error: %w", err)
+	}
+
+	// Validate saved queries
+	queryNames := make(map[string]bool)
+	for i, query := range c.SavedQueries {
+		if query.Name == "" {
+			return fmt.Errorf("saved query configuration error: query %d has no name", i)
+		}
+		if queryNames[query.Name] {
+			return fmt.Errorf("saved query configuration error: duplicate query name %q", query.Name)
+		}
+		queryNames[query.Name] = true
+		if query.Schedule <= 0 {
+			return fmt.Errorf("saved query configuration error: schedule for %q must be positive", query.Name)
+		}
+		switch models.ReportType(query.ReportType) {
+		case "", models.FileListReport, models.HTMLReport, models.NarrativeReport, models.JSONReport, models.CSVReport, models.MarkdownReport:
+		default:
+			return fmt.Errorf("saved query configuration error: unknown report type %q for %q", query.ReportType, query.Name)
+		}
+	}
+
+	// Validate digest configuration
+	switch models.ReportType(c.Digest.ReportType) {
+	case "", models.FileListReport, models.HTMLReport, models.NarrativeReport, models.MarkdownReport:
+	default:
+		return fmt.Errorf("digest configuration error: unknown report type %q", c.Digest.ReportType)
+	}
+
+	// Validate report configuration
+	if _, err := i18n.ParseLanguage(c.Report.Language); err != nil {
+		return fmt.Errorf("report configuration error: %w", err)
+	}
+	if err := c.Report.Links.ToPreviewConfig().Validate(); err != nil {
+		return fmt.Errorf("report links configuration error: %w", err)
+	}
+	if c.Report.ExecutiveSummary && !c.AI.Enabled {
+		return fmt.Errorf("report configuration error: executive summary requires ai to be enabled")
+	}
+	if c.Report.Topics.Enabled && !c.AI.Enabled {
+		return fmt.Errorf("report configuration error: topics require ai to be enabled")
+	}
+	if c.Report.Topics.MaxFiles < 0 {
+		return fmt.Errorf("report configuration error: topics max files cannot be negative")
+	}
+
+	// Validate action link configuration
+	if c.Actions.Enabled() {
+		if len(c.Actions.Secret) < 16 {
+			return fmt.Errorf("actions configuration error: secret must be at least 16 characters")
+		}
+		if !isHTTPURL(c.Actions.BaseURL) {
+			return fmt.Errorf("actions configuration error: base_url must be an http(s) URL")
+		}
+	}
+	if c.Actions.MuteDuration < 0 || c.Actions.LinkTTL < 0 {
+		return fmt.Errorf("actions configuration error: durations cannot be negative")
+	}
+
+	// Validate freshness SLO configuration
+	if c.SLO.MaxUnreportedAge < 0 || c.SLO.CheckInterval < 0 {
+		return fmt.Errorf("slo configuration error: durations cannot be negative")
+	}
+
 	// Validate retry configuration
 	if c.Retry.MaxAttempts <= 0 {
 		return fmt.Errorf("retry configuration error: max attempts must be positive")
@@ -104,6 +1667,9 @@ func (c *Config) Validate() error {
 	if c.HealthCheck.Interval <= 0 {
 		return fmt.Errorf("health check configuration error: interval must be positive")
 	}
+	if c.HealthCheck.RestartBackoff < 0 || c.HealthCheck.MaxRestartBackoff < 0 || c.HealthCheck.AlertAfter < 0 {
+		return fmt.Errorf("health check configuration error: restart settings cannot be negative")
+	}
 
 	// Validate notification configuration
 	if c.Notify.Enabled {
@@ -114,6 +1680,31 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("notification configuration error: invalid SMTP port")
 		}
 	}
+	if c.Notify.RetryInterval < 0 {
+		return fmt.Errorf("notification configuration error: retry interval cannot be negative")
+	}
+	if c.Notify.RetryBackoff < 0 || c.Notify.MaxRetryBackoff < 0 {
+		return fmt.Errorf("notification configuration error: retry backoff cannot be negative")
+	}
+	if c.Notify.MaxAttempts < 0 {
+		return fmt.Errorf("notification configuration error: max attempts cannot be negative")
+	}
+	if slack := c.Notify.Channels.Slack; slack.Enabled && !isHTTPURL(slack.WebhookURL) {
+		return fmt.Errorf("notification configuration error: slack channel requires an http(s) webhook_url")
+	}
+	if webhook := c.Notify.Channels.Webhook; webhook.Enabled {
+		if len(webhook.Endpoints()) == 0 {
+			return fmt.Errorf("notification configuration error: webhook channel requires an http(s) url")
+		}
+		for _, url := range webhook.Endpoints() {
+			if !isHTTPURL(url) {
+				return fmt.Errorf("notification configuration error: webhook url %q is not an http(s) URL", url)
+			}
+		}
+		if webhook.RetryBackoff < 0 {
+			return fmt.Errorf("notification configuration error: webhook retry backoff cannot be negative")
+		}
+	}
 
 	// Validate state configuration
 	if c.State.Path == "" {
@@ -127,8 +1718,34 @@ func (c *Config) Validate() error {
 	}
 
 	// Validate database configuration
-	if c.Database.Path == "" {
-		c.Database.Path = filepath.Join(os.TempDir(), "dropbox_monitor.db")
+	switch c.Database.GetDriver() {
+	case DatabaseDriverSQLite:
+		if c.Database.Path == "" {
+			c.Database.Path = filepath.Join(os.TempDir(), "dropbox_monitor.db")
+		}
+	case DatabaseDriverPostgres:
+		if c.Database.GetURL() == "" {
+			return fmt.Errorf("database configuration error: url is required for the postgres driver")
+		}
+	default:
+		return fmt.Errorf("database configuration error: unknown driver %q", c.Database.Driver)
+	}
+	if c.Database.BatchSize < 0 {
+		return fmt.Errorf("database configuration error: batch size cannot be negative")
+	}
+	switch db.ContentMode(c.Database.Contents.Mode) {
+	case "", db.ContentNone, db.ContentPreview, db.ContentFull:
+	default:
+		return fmt.Errorf("database configuration error: unknown contents mode %q", c.Database.Contents.Mode)
+	}
+	if c.Database.Contents.PreviewBytes < 0 {
+		return fmt.Errorf("database configuration error: contents preview bytes cannot be negative")
+	}
+
+	// Validate retention configuration
+	if c.Retention.FileChangesDays < 0 || c.Retention.FileContentsDays < 0 ||
+		c.Retention.TeamEventsDays < 0 || c.Retention.FolderUsageDays < 0 {
+		return fmt.Errorf("retention configuration error: days cannot be negative")
 	}
 
 	// Validate email configuration
@@ -139,28 +1756,117 @@ func (c *Config) Validate() error {
 		if c.EmailConfig.SMTPPort <= 0 || c.EmailConfig.SMTPPort > 65535 {
 			return fmt.Errorf("email configuration error: invalid SMTP port")
 		}
+		switch c.EmailConfig.TLSMode {
+		case "", SMTPTLSImplicit, SMTPTLSStartTLS, SMTPTLSNone:
+		default:
+			return fmt.Errorf("email configuration error: unknown TLS mode %q", c.EmailConfig.TLSMode)
+		}
+		if c.EmailConfig.TLSMode == SMTPTLSNone && c.EmailConfig.CAFile != "" {
+			return fmt.Errorf("email configuration error: CA file requires TLS")
+		}
+		switch c.EmailConfig.AuthMethod {
+		case "", SMTPAuthPlain, SMTPAuthLogin, SMTPAuthCRAMMD5:
+		default:
+			return fmt.Errorf("email configuration error: unknown auth method %q", c.EmailConfig.AuthMethod)
+		}
+		if c.EmailConfig.Timeout < 0 {
+			return fmt.Errorf("email configuration error: timeout cannot be negative")
+		}
+		if c.EmailConfig.Bounces.MaxBounces < 0 {
+			return fmt.Errorf("email configuration error: max bounces cannot be negative")
+		}
+		if mailbox := c.EmailConfig.Bounces.Mailbox; mailbox.Enabled() {
+			switch mailbox.TLSMode {
+			case "", SMTPTLSImplicit, SMTPTLSNone:
+			default:
+				return fmt.Errorf("email configuration error: unknown bounce mailbox TLS mode %q", mailbox.TLSMode)
+			}
+			if mailbox.Username == "" {
+				return fmt.Errorf("email configuration error: bounce mailbox username is required")
+			}
+			if mailbox.Port < 0 || mailbox.Port > 65535 {
+				return fmt.Errorf("email configuration error: invalid bounce mailbox port")
+			}
+		}
 	}
 
 	return nil
 }
 
-// LoadConfig loads configuration from a file
+// isHTTPURL reports whether s is an http or https URL
+func isHTTPURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+// LoadConfig loads configuration from a file, with the defaults and the
+// environment applied as by Load
 func LoadConfig(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+	return Load(LoadOptions{Path: path})
+}
+
+// SecretValues returns the configured credentials, such as access tokens,
+// passwords and API keys, for the logger to redact
+func (c *Config) SecretValues() []string {
+	secrets := []string{c.DropboxToken, c.DropboxAppSecret, c.DropboxRefreshToken, c.Web.APIToken,
+		c.Embeddings.APIKey, c.AI.APIKey, c.Notify.Channels.Slack.WebhookURL, c.Notify.Channels.Webhook.Secret,
+		c.Anomaly.Alerts.SlackWebhookURL, c.Anomaly.Alerts.Secret}
+	secrets = append(secrets, c.Webhook.Secrets()...)
+	for _, account := range c.Accounts {
+		secrets = append(secrets, account.DropboxToken)
+	}
+	if c.EmailConfig != nil {
+		secrets = append(secrets, c.EmailConfig.SMTPPassword, c.EmailConfig.Bounces.Mailbox.Password)
+	}
+	if u, err := url.Parse(c.Database.GetURL()); err == nil && u.User != nil {
+		password, _ := u.User.Password()
+		secrets = append(secrets, password)
 	}
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	values := secrets[:0]
+	for _, secret := range secrets {
+		if secret != "" {
+			values = append(values, secret)
+		}
 	}
+	return values
+}
+
+// UsesRefreshToken reports whether the OAuth2 refresh-token flow is configured
+func (c *Config) UsesRefreshToken() bool {
+	return c.DropboxRefreshToken != ""
+}
 
-	if err := config.Validate(); err != nil {
-		return nil, err
+// IsMultiAccount reports whether more than one Dropbox account is configured
+func (c *Config) IsMultiAccount() bool {
+	return len(c.Accounts) > 1
+}
+
+// GetAccount returns the account with the given ID
+func (c *Config) GetAccount(id string) (AccountConfig, bool) {
+	for _, account := range c.Accounts {
+		if account.ID == id {
+			return account, true
+		}
 	}
+	return AccountConfig{}, false
+}
+
+// ChangeQuery returns the filter defined by the saved query
+func (q SavedQueryConfig) ChangeQuery() models.ChangeQuery {
+	return models.ChangeQuery{
+		Name:       q.Name,
+		PathPrefix: q.PathPrefix,
+		Extensions: q.Extensions,
+		Authors:    q.Authors,
+	}
+}
 
-	return &config, nil
+// GetReportType returns the report type for the saved query, defaulting to a file list
+func (q SavedQueryConfig) GetReportType() models.ReportType {
+	if q.ReportType == "" {
+		return models.FileListReport
+	}
+	return models.ReportType(q.ReportType)
 }
 
 // GetEnvOrDefault gets an environment variable value or returns a default
@@ -203,17 +1909,7 @@ func GetDurationOrDefault(key string, defaultValue time.Duration) time.Duration
 
 // NewConfig creates a new configuration with default values
 func NewConfig() *Config {
-	return &Config{
-		PollInterval: 5 * time.Minute,
-		Retry: RetryConfig{
-			MaxAttempts: 3,
-			Delay:      time.Second * 5,
-		},
-		HealthCheck: HealthCheckConfig{
-			Interval: time.Minute,
-		},
-		EmailConfig: &EmailConfig{
-			SMTPPort: 587,
-		},
-	}
+	config := defaultConfig()
+	config.EmailConfig = &EmailConfig{SMTPPort: DefaultSMTPPort}
+	return &config
 }
@@ -1,12 +1,21 @@
 package config
 
 import (
+	"log/slog"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/core"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/severity"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -502,6 +511,524 @@ func TestConfig_ValidateNew(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "multiple accounts",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				Accounts: []AccountConfig{
+					{ID: "work", Name: "Work", DropboxToken: "work-token"},
+					{ID: "home", Name: "Home", DropboxToken: "home-token"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "multiple accounts without top-level token",
+			config: Config{
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				Accounts: []AccountConfig{
+					{ID: "work", Name: "Work", DropboxToken: "work-token"},
+					{ID: "home", Name: "Home", DropboxToken: "home-token"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate account ids",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				Accounts: []AccountConfig{
+					{ID: "work", DropboxToken: "token-a"},
+					{ID: "work", DropboxToken: "token-b"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "account missing token",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				Accounts: []AccountConfig{
+					{ID: "work"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "refresh token instead of access token",
+			config: Config{
+				DropboxAppKey:       "app-key",
+				DropboxAppSecret:    "app-secret",
+				DropboxRefreshToken: "refresh-token",
+				PollInterval:        5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "refresh token without app key",
+			config: Config{
+				DropboxRefreshToken: "refresh-token",
+				PollInterval:        5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid saved query",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				SavedQueries: []SavedQueryConfig{
+					{Name: "SQL changes", PathPrefix: "/DB", Extensions: []string{".sql"}, Schedule: 7 * 24 * time.Hour},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "saved query without schedule",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				SavedQueries: []SavedQueryConfig{
+					{Name: "SQL changes", Extensions: []string{".sql"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "saved query with unknown report type",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				SavedQueries: []SavedQueryConfig{
+					{Name: "SQL changes", Schedule: time.Hour, ReportType: "pdf"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative webhook replay window",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				Webhook: WebhookConfig{ReplayWindow: -time.Minute},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative limits",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				Limits: LimitsConfig{MaxConcurrentDownloads: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative file type limits",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				FileTypes: FileTypesConfig{Sniff: true, MaxFiles: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate monitored folders",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				Monitoring: MonitoringConfig{
+					Folders: []MonitoredFolderConfig{{Path: "/Work"}, {Path: "/work/"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid first run policy",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				Monitoring: MonitoringConfig{FirstRun: "everything"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "adaptive min interval above max",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				Monitoring: MonitoringConfig{Adaptive: AdaptivePollingConfig{
+					Enabled: true, MinInterval: time.Hour, MaxInterval: time.Minute,
+				}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "longpoll timeout out of range",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				Monitoring: MonitoringConfig{Adaptive: AdaptivePollingConfig{
+					Enabled: true, Longpoll: true, LongpollTimeout: time.Hour,
+				}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "adaptive polling",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				Monitoring: MonitoringConfig{Adaptive: AdaptivePollingConfig{
+					Enabled: true, MinInterval: time.Minute, MaxInterval: time.Hour, Longpoll: true, LongpollTimeout: 2 * time.Minute,
+				}},
+			},
+		},
+		{
+			name: "monitored folder with invalid pattern",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				Monitoring: MonitoringConfig{
+					Folders: []MonitoredFolderConfig{{Path: "/Work", Include: []string{"[bad"}}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid folder quota",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				Quotas: QuotasConfig{
+					Folders: []FolderQuotaConfig{{Path: "/Raw-Video", MaxSize: 2 << 40, ProjectionDays: 30}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "folder quota without size",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				Quotas: QuotasConfig{
+					Folders: []FolderQuotaConfig{{Path: "/Raw-Video"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "slack channel without webhook url",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				Notify: NotifyConfig{
+					Channels: NotifyChannelsConfig{Slack: SlackChannelConfig{Enabled: true}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "webhook channel with invalid extra url",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				Notify: NotifyConfig{
+					Channels: NotifyChannelsConfig{Webhook: WebhookChannelConfig{
+						Enabled: true,
+						URLs:    []string{"https://example.com/hook", "ftp://example.com"},
+					}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown feature flag",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				Features: map[string]bool{"teleport": true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "team log in reports without ingestion",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				TeamLog: TeamLogConfig{IncludeInReports: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown SMTP TLS mode",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				EmailConfig: &EmailConfig{SMTPHost: "smtp.test.com", SMTPPort: 465, TLSMode: "ssl"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown SMTP auth method",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				EmailConfig: &EmailConfig{SMTPHost: "smtp.test.com", SMTPPort: 587, AuthMethod: "xoauth2"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "SMTP CA file without TLS",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				EmailConfig: &EmailConfig{SMTPHost: "smtp.test.com", SMTPPort: 25, TLSMode: SMTPTLSNone, CAFile: "ca.pem"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "bounce mailbox without username",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				EmailConfig: &EmailConfig{SMTPHost: "smtp.test.com", SMTPPort: 587,
+					Bounces: BounceConfig{Mailbox: BounceMailboxConfig{Host: "imap.test.com"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "bounce mailbox with STARTTLS",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				EmailConfig: &EmailConfig{SMTPHost: "smtp.test.com", SMTPPort: 587,
+					Bounces: BounceConfig{Mailbox: BounceMailboxConfig{Host: "imap.test.com", Username: "bounces", TLSMode: SMTPTLSStartTLS}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid bounce mailbox",
+			config: Config{
+				DropboxToken: "test-token",
+				PollInterval: 5 * time.Minute,
+				Retry: RetryConfig{
+					MaxAttempts: 3,
+					Delay:      30 * time.Second,
+				},
+				HealthCheck: HealthCheckConfig{
+					Interval: time.Minute,
+				},
+				EmailConfig: &EmailConfig{SMTPHost: "smtp.test.com", SMTPPort: 587,
+					Bounces: BounceConfig{MaxBounces: 5, Mailbox: BounceMailboxConfig{Host: "imap.test.com", Username: "bounces"}}},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -539,3 +1066,694 @@ func TestGetDurationOrDefaultNew(t *testing.T) {
 	assert.Equal(t, time.Hour, GetDurationOrDefault("TEST_DURATION", time.Minute))
 	assert.Equal(t, time.Minute, GetDurationOrDefault("NON_EXISTENT_DURATION", time.Minute))
 }
+
+func TestWebhookConfig_Secrets(t *testing.T) {
+	cfg := WebhookConfig{AppSecret: "current", AppSecrets: []string{"", "previous"}}
+	assert.Equal(t, []string{"current", "previous"}, cfg.Secrets())
+	assert.Empty(t, WebhookConfig{}.Secrets())
+}
+
+func TestParseByteSize(t *testing.T) {
+	testCases := map[string]ByteSize{
+		"1024":  1024,
+		"2TB":   2 << 40,
+		"500mb": 500 << 20,
+		"1.5GB": 3 << 29,
+		"10 KB": 10 << 10,
+	}
+	for input, want := range testCases {
+		got, err := ParseByteSize(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+
+	for _, input := range []string{"", "lots", "-1GB"} {
+		_, err := ParseByteSize(input)
+		assert.Error(t, err, input)
+	}
+
+	assert.Equal(t, "512 B", ByteSize(512).String())
+	assert.Equal(t, "1.5 GB", ByteSize(3<<29).String())
+	assert.Equal(t, "2.0 TB", ByteSize(2<<40).String())
+}
+
+func TestLimitsConfig_DropboxRateLimits(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+limits:
+  api_requests_per_second: 2.5
+  api_burst: 5
+  rate_limit_pause_after: 10m
+`), &cfg))
+
+	assert.Equal(t, dropbox.RateLimitConfig{RequestsPerSecond: 2.5, Burst: 5}, cfg.Limits.ToRateLimitConfig())
+	assert.Equal(t, dropbox.RateLimitPauseConfig{After: 10 * time.Minute}, cfg.Limits.ToRateLimitPauseConfig())
+
+	cfg.DropboxToken = "test-token"
+	cfg.PollInterval = 5 * time.Minute
+	cfg.Retry = RetryConfig{MaxAttempts: 3, Delay: 30 * time.Second}
+	cfg.HealthCheck = HealthCheckConfig{Interval: time.Minute}
+	assert.NoError(t, cfg.Validate())
+	cfg.Limits.APIBurst = -1
+	assert.Error(t, cfg.Validate())
+}
+
+func TestLimitsConfig_MaxConcurrentListings(t *testing.T) {
+	var cfg Config
+	assert.Equal(t, dropbox.DefaultTraversalWorkers, cfg.Limits.GetMaxConcurrentListings())
+
+	require.NoError(t, yaml.Unmarshal([]byte(`
+limits:
+  max_concurrent_listings: 8
+`), &cfg))
+	assert.Equal(t, 8, cfg.Limits.GetMaxConcurrentListings())
+
+	cfg.DropboxToken = "test-token"
+	cfg.PollInterval = 5 * time.Minute
+	cfg.Retry = RetryConfig{MaxAttempts: 3, Delay: 30 * time.Second}
+	cfg.HealthCheck = HealthCheckConfig{Interval: time.Minute}
+	assert.NoError(t, cfg.Validate())
+	cfg.Limits.MaxConcurrentListings = -1
+	assert.Error(t, cfg.Validate())
+}
+
+func TestLoggingAndDiskConfig_YAML(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+limits:
+  min_free_disk: 5GB
+  disk_path: /var/lib/monitor
+logging:
+  file: /var/log/monitor.log
+  max_size_mb: 50
+  max_age: 24h
+  max_backups: 3
+  compress: true
+`), &cfg))
+
+	lim := cfg.Limits.ToLimits()
+	assert.Equal(t, int64(5<<30), lim.MinFreeDiskBytes)
+	assert.Equal(t, "/var/lib/monitor", lim.DiskPath)
+	assert.Equal(t, DefaultDiskCheckInterval, cfg.Limits.GetDiskCheckInterval())
+
+	opts := cfg.Logging.ToOptions()
+	assert.Equal(t, "/var/log/monitor.log", opts.Path)
+	assert.Equal(t, int64(50<<20), opts.MaxSize)
+	assert.Equal(t, 24*time.Hour, opts.MaxAge)
+	assert.Equal(t, 3, opts.MaxBackups)
+	assert.True(t, opts.Compress)
+}
+
+func TestQuotasConfig_YAML(t *testing.T) {
+	var cfg Config
+	err := yaml.Unmarshal([]byte(`
+quotas:
+  folders:
+    - path: /Raw-Video
+      max_size: 2TB
+      projection_days: 14
+`), &cfg)
+	require.NoError(t, err)
+	require.Len(t, cfg.Quotas.Folders, 1)
+	assert.Equal(t, ByteSize(2<<40), cfg.Quotas.Folders[0].MaxSize)
+	assert.Equal(t, DefaultQuotaScanInterval, cfg.Quotas.GetScanInterval())
+}
+
+func TestMonitoringConfig_GetFolders(t *testing.T) {
+	legacy := MonitoringConfig{Path: "/Work"}
+	folders := legacy.GetFolders()
+	if assert.Len(t, folders, 1) {
+		assert.Equal(t, "/Work", folders[0].Path)
+		assert.True(t, folders[0].IsRecursive())
+	}
+
+	var cfg Config
+	err := yaml.Unmarshal([]byte(`
+monitoring:
+  folders:
+    - path: /Work
+      recursive: false
+      include: ["*.docx"]
+    - path: /Photos
+      poll_interval: 1h
+`), &cfg)
+	require.NoError(t, err)
+	folders = cfg.Monitoring.GetFolders()
+	require.Len(t, folders, 2)
+	assert.False(t, folders[0].IsRecursive())
+	assert.Equal(t, []string{"*.docx"}, folders[0].Include)
+	assert.True(t, folders[1].IsRecursive())
+	assert.Equal(t, time.Hour, folders[1].PollInterval)
+	assert.Equal(t, core.FirstRunBaseline, cfg.Monitoring.GetFirstRun())
+
+	cfg.Monitoring.FirstRun = "summary"
+	assert.Equal(t, core.FirstRunSummary, cfg.Monitoring.GetFirstRun())
+
+	// Adaptive polling is off unless enabled
+	assert.Nil(t, cfg.Monitoring.Adaptive.ToAdaptivePolling())
+	cfg.Monitoring.Adaptive = AdaptivePollingConfig{Enabled: true, MaxInterval: time.Hour, Longpoll: true}
+	assert.Equal(t, &core.AdaptivePolling{MaxInterval: time.Hour, Longpoll: true}, cfg.Monitoring.Adaptive.ToAdaptivePolling())
+}
+
+func TestMonitoringConfig_FolderFilters(t *testing.T) {
+	var cfg Config
+	err := yaml.Unmarshal([]byte(`
+monitoring:
+  include: ["*.docx", "Reports/**"]
+  exclude: ["*.tmp", ".~lock*"]
+  folders:
+    - path: /Work
+    - path: /Photos
+      include: ["*.jpg"]
+      exclude: ["thumbs/**"]
+`), &cfg)
+	require.NoError(t, err)
+
+	folders := cfg.Monitoring.GetFolders()
+	include, exclude := cfg.Monitoring.FolderFilters(folders[0])
+	assert.Equal(t, []string{"*.docx", "Reports/**"}, include)
+	assert.Equal(t, []string{"*.tmp", ".~lock*"}, exclude)
+
+	include, exclude = cfg.Monitoring.FolderFilters(folders[1])
+	assert.Equal(t, []string{"*.jpg"}, include)
+	assert.Equal(t, []string{"*.tmp", ".~lock*", "thumbs/**"}, exclude)
+
+	cfg.Monitoring.Exclude = []string{"[bad"}
+	cfg.DropboxToken = "test-token"
+	cfg.PollInterval = time.Minute
+	assert.ErrorContains(t, cfg.Validate(), "invalid filter")
+}
+
+func TestNotifyChannelsConfig_YAML(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+notify:
+  channels:
+    slack:
+      enabled: true
+      webhook_url: https://hooks.slack.com/services/x
+`), &cfg))
+	assert.True(t, cfg.Notify.Channels.Email.IsEnabled(), "email stays on unless disabled")
+	assert.True(t, cfg.Notify.Channels.Slack.Enabled)
+
+	require.NoError(t, yaml.Unmarshal([]byte(`
+notify:
+  channels:
+    email:
+      enabled: false
+`), &cfg))
+	assert.False(t, cfg.Notify.Channels.Email.IsEnabled())
+}
+
+func TestNotifyConfig_Retries(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+notify:
+  retry_backoff: 2m
+  max_attempts: 5
+`), &cfg))
+	assert.Equal(t, 2*time.Minute, cfg.Notify.GetRetryBackoff())
+	assert.Equal(t, DefaultNotifyMaxRetryBackoff, cfg.Notify.GetMaxRetryBackoff())
+	assert.Equal(t, 5, cfg.Notify.GetMaxAttempts())
+	assert.Equal(t, DefaultNotifyMaxAttempts, NotifyConfig{}.GetMaxAttempts())
+
+	cfg.DropboxToken = "test-token"
+	cfg.PollInterval = 5 * time.Minute
+	cfg.Retry = RetryConfig{MaxAttempts: 3, Delay: 30 * time.Second}
+	cfg.HealthCheck = HealthCheckConfig{Interval: time.Minute}
+	assert.NoError(t, cfg.Validate())
+	cfg.Notify.MaxAttempts = -1
+	assert.Error(t, cfg.Validate())
+}
+
+func TestHealthCheckConfig_Supervisor(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+health_check:
+  interval: 30s
+  restart_backoff: 5s
+  alert_after: 2
+`), &cfg))
+	supervisor := cfg.HealthCheck.ToSupervisorConfig()
+	assert.Equal(t, 30*time.Second, supervisor.Interval)
+	assert.Equal(t, 5*time.Second, supervisor.Backoff)
+	assert.Zero(t, supervisor.MaxBackoff, "unset values use the supervisor's defaults")
+	assert.Equal(t, 2, supervisor.AlertAfter)
+
+	cfg.DropboxToken = "test-token"
+	cfg.PollInterval = 5 * time.Minute
+	cfg.Retry = RetryConfig{MaxAttempts: 3, Delay: 30 * time.Second}
+	assert.NoError(t, cfg.Validate())
+	cfg.HealthCheck.AlertAfter = -1
+	assert.Error(t, cfg.Validate())
+}
+
+func TestConfig_EmailRecipients(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+email_config:
+  to_addresses: [team@example.com]
+notify:
+  recipients:
+    weekly: [leadership@example.com]
+`), &cfg))
+	assert.Equal(t, []string{"leadership@example.com"}, cfg.EmailRecipients(AudienceWeekly))
+	assert.Equal(t, []string{"team@example.com"}, cfg.EmailRecipients(AudienceDaily), "empty lists fall back")
+	assert.Equal(t, []string{"team@example.com"}, cfg.EmailRecipients(AudienceRealtime))
+}
+
+func TestWebhookChannelConfig_Endpoints(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+notify:
+  channels:
+    webhook:
+      enabled: true
+      url: https://a.example.com/hook
+      urls: [https://b.example.com/hook, https://a.example.com/hook]
+      secret: s3cret
+      max_retries: 5
+      retry_backoff: 2s
+`), &cfg))
+	webhook := cfg.Notify.Channels.Webhook
+	assert.Equal(t, []string{"https://a.example.com/hook", "https://b.example.com/hook"}, webhook.Endpoints())
+	assert.Equal(t, "s3cret", webhook.Secret)
+	assert.Equal(t, 5, webhook.MaxRetries)
+	assert.Equal(t, 2*time.Second, webhook.RetryBackoff)
+}
+
+func TestActionsConfig_Validate(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+actions:
+  secret: 0123456789abcdef
+  base_url: https://monitor.example.com
+  mute_duration: 12h
+`), &cfg))
+	assert.True(t, cfg.Actions.Enabled())
+	assert.Equal(t, 12*time.Hour, cfg.Actions.ToServiceConfig().MuteDuration)
+
+	valid := Config{
+		DropboxToken: "test-token",
+		PollInterval: 5 * time.Minute,
+		Retry:        RetryConfig{MaxAttempts: 3, Delay: 30 * time.Second},
+		HealthCheck:  HealthCheckConfig{Interval: time.Minute},
+		Actions:      cfg.Actions,
+	}
+	assert.NoError(t, valid.Validate())
+
+	valid.Actions.BaseURL = "monitor.example.com"
+	assert.Error(t, valid.Validate())
+
+	valid.Actions = ActionsConfig{Secret: "short", BaseURL: "https://monitor.example.com"}
+	assert.Error(t, valid.Validate())
+}
+
+func TestEmbeddingsConfig_Validate(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+embeddings:
+  enabled: true
+  provider: openai
+  max_file_size: 2MB
+`), &cfg))
+	t.Setenv("OPENAI_API_KEY", "sk-env")
+	assert.Equal(t, "sk-env", cfg.Embeddings.GetAPIKey())
+	assert.Equal(t, int64(2*1024*1024), cfg.Embeddings.ToEmbeddingsConfig().MaxFileBytes)
+
+	valid := Config{
+		DropboxToken: "test-token",
+		PollInterval: 5 * time.Minute,
+		Retry:        RetryConfig{MaxAttempts: 3, Delay: 30 * time.Second},
+		HealthCheck:  HealthCheckConfig{Interval: time.Minute},
+		Embeddings:   cfg.Embeddings,
+	}
+	assert.NoError(t, valid.Validate())
+
+	t.Setenv("OPENAI_API_KEY", "")
+	assert.Error(t, valid.Validate(), "openai requires an API key")
+
+	valid.Embeddings = EmbeddingsConfig{Enabled: true, Provider: "telepathy"}
+	assert.Error(t, valid.Validate())
+
+	valid.Embeddings = EmbeddingsConfig{Enabled: true, Provider: "ollama", URL: "localhost:11434"}
+	assert.Error(t, valid.Validate())
+}
+
+func TestAIConfig_Validate(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+ai:
+  enabled: true
+  provider: anthropic
+  requests_per_minute: 10
+  daily_token_budget: 100000
+`), &cfg))
+	t.Setenv("ANTHROPIC_API_KEY", "a-env")
+	assert.Equal(t, "a-env", cfg.AI.GetAPIKey())
+	assert.Equal(t, 100000, cfg.AI.ToProviderConfig().DailyTokenBudget)
+
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Setenv("GOOGLE_API_KEY", "g-env")
+	assert.Equal(t, "g-env", AIConfig{Provider: "gemini"}.GetAPIKey())
+
+	valid := Config{
+		DropboxToken: "test-token",
+		PollInterval: 5 * time.Minute,
+		Retry:        RetryConfig{MaxAttempts: 3, Delay: 30 * time.Second},
+		HealthCheck:  HealthCheckConfig{Interval: time.Minute},
+		AI:           cfg.AI,
+	}
+	assert.NoError(t, valid.Validate())
+
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	assert.Error(t, valid.Validate(), "anthropic requires an API key")
+
+	valid.AI = AIConfig{Enabled: true, Provider: "ollama", RequestsPerMinute: -2}
+	assert.Error(t, valid.Validate())
+
+	valid.AI = AIConfig{Enabled: true, Provider: "ollama", RequestsPerMinute: -1}
+	assert.NoError(t, valid.Validate())
+
+	valid.AI = AIConfig{Enabled: true, Provider: "oracle"}
+	assert.Error(t, valid.Validate())
+}
+
+func TestClassificationConfig_Validate(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+classification:
+  paths:
+    - match: ^/clients/([^/]+)/
+      portfolio: Clients
+      project: $1
+  types:
+    - extensions: [.docx, .pdf]
+      document_type: Document
+`), &cfg))
+	rules := cfg.Classification.ToRulesConfig()
+	require.Len(t, rules.Paths, 1)
+	assert.Equal(t, "$1", rules.Paths[0].Project)
+	assert.Equal(t, []string{".docx", ".pdf"}, rules.Types[0].Extensions)
+	assert.True(t, cfg.Classification.Enabled())
+
+	valid := Config{
+		DropboxToken:   "test-token",
+		PollInterval:   5 * time.Minute,
+		Retry:          RetryConfig{MaxAttempts: 3, Delay: 30 * time.Second},
+		HealthCheck:    HealthCheckConfig{Interval: time.Minute},
+		Classification: cfg.Classification,
+	}
+	assert.NoError(t, valid.Validate())
+
+	valid.Classification.Paths[0].Match = "(["
+	assert.Error(t, valid.Validate())
+
+	valid.Classification = ClassificationConfig{LLMFallback: true}
+	assert.Error(t, valid.Validate(), "the fallback requires ai")
+	valid.AI = AIConfig{Enabled: true, Provider: "ollama"}
+	assert.NoError(t, valid.Validate())
+}
+
+func TestEnrichmentConfig_Validate(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+enrichment:
+  patterns:
+    - field: ticket
+      match: ([A-Z]+-[0-9]+)
+      value: $1
+      source: name
+`), &cfg))
+	rules := cfg.Enrichment.ToPatternRules()
+	require.Len(t, rules, 1)
+	assert.Equal(t, "ticket", rules[0].Field)
+	assert.Equal(t, "name", rules[0].Source)
+
+	valid := Config{
+		DropboxToken: "test-token",
+		PollInterval: 5 * time.Minute,
+		Retry:        RetryConfig{MaxAttempts: 3, Delay: 30 * time.Second},
+		HealthCheck:  HealthCheckConfig{Interval: time.Minute},
+		Enrichment:   cfg.Enrichment,
+	}
+	assert.NoError(t, valid.Validate())
+
+	valid.Enrichment.Patterns[0].Source = "content"
+	assert.Error(t, valid.Validate())
+}
+
+func TestSeverityConfig_Validate(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+severity:
+  rules:
+    - name: mass deletion
+      level: critical
+      operations: [deleted]
+      min_files: 100
+    - name: large uploads
+      level: Warning
+      min_size: 1GB
+  tiers:
+    critical:
+      channels: [email, slack]
+      subject_prefix: "[CRITICAL]"
+`), &cfg))
+	rules := cfg.Severity.ToRules()
+	require.Len(t, rules, 2)
+	assert.Equal(t, severity.Critical, rules[0].Level)
+	assert.Equal(t, []models.ChangeType{models.ChangeDeleted}, rules[0].Operations)
+	assert.Equal(t, severity.Warning, rules[1].Level)
+	assert.Equal(t, int64(1<<30), rules[1].MinSize)
+	assert.Equal(t, map[severity.Level]severity.Tier{
+		severity.Critical: {Channels: []string{"email", "slack"}, SubjectPrefix: "[CRITICAL]"},
+	}, cfg.Severity.ToTiers())
+	assert.True(t, cfg.Severity.Enabled())
+
+	valid := Config{
+		DropboxToken: "test-token",
+		PollInterval: 5 * time.Minute,
+		Retry:        RetryConfig{MaxAttempts: 3, Delay: 30 * time.Second},
+		HealthCheck:  HealthCheckConfig{Interval: time.Minute},
+		Severity:     cfg.Severity,
+	}
+	assert.NoError(t, valid.Validate())
+
+	valid.Severity.Rules[0].Operations = []string{"created"}
+	assert.Error(t, valid.Validate())
+	valid.Severity.Rules[0].Operations = nil
+	valid.Severity.Rules[0].Level = "urgent"
+	assert.Error(t, valid.Validate())
+	valid.Severity.Rules[0].Level = "critical"
+	valid.Severity.Tiers["critical"] = SeverityTierConfig{Channels: []string{"pager"}}
+	assert.Error(t, valid.Validate())
+}
+
+func TestAnomalyConfig_Validate(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+anomaly:
+  window: 5m
+  deletions: 200
+  renames: 50
+  known_extensions: [draft]
+  entropy:
+    files: 20
+    max_file_size: 5MB
+  alerts:
+    subject_prefix: "[SECURITY]"
+    slack_webhook_url: https://hooks.slack.com/services/security
+`), &cfg))
+	detector := cfg.Anomaly.ToDetectorConfig()
+	assert.Equal(t, 5*time.Minute, detector.Window)
+	assert.Equal(t, 200, detector.Deletions)
+	assert.Equal(t, 20, detector.EntropyFiles)
+	assert.Equal(t, int64(5<<20), detector.MaxFileBytes)
+	assert.Equal(t, "[SECURITY]", detector.SubjectPrefix)
+	assert.True(t, cfg.Anomaly.Enabled())
+	assert.False(t, AnomalyConfig{}.Enabled())
+
+	valid := Config{
+		DropboxToken: "test-token",
+		PollInterval: 5 * time.Minute,
+		Retry:        RetryConfig{MaxAttempts: 3, Delay: 30 * time.Second},
+		HealthCheck:  HealthCheckConfig{Interval: time.Minute},
+		Anomaly:      cfg.Anomaly,
+	}
+	assert.NoError(t, valid.Validate())
+	assert.Contains(t, valid.SecretValues(), "https://hooks.slack.com/services/security")
+
+	valid.Anomaly.Entropy.Threshold = 9
+	assert.Error(t, valid.Validate())
+	valid.Anomaly.Entropy.Threshold = 0
+	valid.Anomaly.Alerts.Email = []string{"security@example.com"}
+	assert.Error(t, valid.Validate(), "email alerts need email_config")
+	valid.Anomaly.Alerts = AnomalyAlertsConfig{}
+	assert.Error(t, valid.Validate(), "alerts need a channel")
+	valid.Anomaly.Alerts.WebhookURL = "ftp://example.com"
+	assert.Error(t, valid.Validate())
+}
+
+func TestFixturesConfig_Validate(t *testing.T) {
+	cfg := Config{
+		PollInterval: 5 * time.Minute,
+		Retry:        RetryConfig{MaxAttempts: 3, Delay: 30 * time.Second},
+		HealthCheck:  HealthCheckConfig{Interval: time.Minute},
+		Fixtures:     FixturesConfig{Mode: "replay"},
+	}
+	assert.NoError(t, cfg.Validate(), "replay needs no token")
+	assert.Equal(t, DefaultFixturesDir, cfg.Fixtures.ToFixtureConfig().Dir)
+
+	cfg.Fixtures.Mode = "record"
+	assert.Error(t, cfg.Validate(), "recording talks to Dropbox")
+
+	cfg.DropboxToken = "test-token"
+	cfg.Fixtures = FixturesConfig{Mode: "rewind", Dir: "fixtures"}
+	assert.Error(t, cfg.Validate())
+}
+
+func TestTeamConfig_Validate(t *testing.T) {
+	cfg := Config{
+		DropboxToken: "test-token",
+		PollInterval: 5 * time.Minute,
+		Retry:        RetryConfig{MaxAttempts: 3, Delay: 30 * time.Second},
+		HealthCheck:  HealthCheckConfig{Interval: time.Minute},
+		Team:         TeamConfig{MemberID: "dbmid:abc", NamespaceID: "12345"},
+	}
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, "dbmid:abc", cfg.Team.ToTeamConfig().MemberID)
+
+	cfg.Team.MemberID = "abc"
+	assert.Error(t, cfg.Validate(), "member IDs start with dbmid:")
+
+	cfg.Team = TeamConfig{NamespaceID: "ns:1"}
+	assert.Error(t, cfg.Validate(), "namespace IDs are numeric")
+}
+
+func TestDatabaseConfig_Validate(t *testing.T) {
+	var cfg Config
+	assert.Equal(t, DatabaseDriverSQLite, cfg.Database.GetDriver())
+
+	require.NoError(t, yaml.Unmarshal([]byte(`
+database:
+  driver: postgres
+  url: postgres://monitor@localhost/dropbox?sslmode=disable
+`), &cfg))
+	assert.Equal(t, DatabaseDriverPostgres, cfg.Database.GetDriver())
+	assert.Equal(t, "postgres://monitor@localhost/dropbox?sslmode=disable", cfg.Database.GetDSN())
+
+	cfg.DropboxToken = "test-token"
+	cfg.PollInterval = 5 * time.Minute
+	cfg.Retry = RetryConfig{MaxAttempts: 3, Delay: 30 * time.Second}
+	cfg.HealthCheck = HealthCheckConfig{Interval: time.Minute}
+	assert.NoError(t, cfg.Validate())
+	assert.Empty(t, cfg.Database.Path, "postgres needs no database file")
+
+	t.Setenv("DATABASE_URL", "")
+	cfg.Database.URL = ""
+	assert.Error(t, cfg.Validate(), "postgres requires a url")
+	t.Setenv("DATABASE_URL", "postgres://env@localhost/dropbox")
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, "postgres://env@localhost/dropbox", cfg.Database.GetDSN())
+
+	cfg.Database = DatabaseConfig{Driver: "mysql"}
+	assert.Error(t, cfg.Validate())
+
+	cfg.Database = DatabaseConfig{}
+	assert.Equal(t, db.DefaultWriteBatchSize, cfg.Database.GetBatchSize())
+	cfg.Database.BatchSize = 100
+	assert.Equal(t, 100, cfg.Database.GetBatchSize())
+	cfg.Database.BatchSize = -1
+	assert.Error(t, cfg.Validate())
+
+	cfg.Database = DatabaseConfig{}
+	assert.Equal(t, db.ContentPolicy{Mode: db.ContentPreview}, cfg.Database.Contents.ToContentPolicy())
+	cfg.Database.Contents = ContentsConfig{Mode: "full"}
+	assert.NoError(t, cfg.Validate())
+	cfg.Database.Contents = ContentsConfig{Mode: "everything"}
+	assert.Error(t, cfg.Validate())
+	cfg.Database.Contents = ContentsConfig{Mode: "preview", PreviewBytes: -1}
+	assert.Error(t, cfg.Validate())
+}
+
+func TestRetentionConfig(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+retention:
+  file_changes_days: 90
+  file_contents_days: 30
+`), &cfg))
+	policy := cfg.Retention.ToRetentionPolicy()
+	assert.True(t, policy.Enabled())
+	assert.Equal(t, 90*24*time.Hour, policy.FileChanges)
+	assert.Equal(t, 30*24*time.Hour, policy.FileContents)
+	assert.Zero(t, policy.TeamEvents, "unset tables are kept forever")
+	assert.Equal(t, DefaultPruneInterval, cfg.Retention.GetPruneInterval())
+	assert.False(t, RetentionConfig{}.ToRetentionPolicy().Enabled())
+
+	cfg.DropboxToken = "test-token"
+	cfg.PollInterval = 5 * time.Minute
+	cfg.Retry = RetryConfig{MaxAttempts: 3, Delay: 30 * time.Second}
+	cfg.HealthCheck = HealthCheckConfig{Interval: time.Minute}
+	assert.NoError(t, cfg.Validate())
+	cfg.Retention.TeamEventsDays = -1
+	assert.Error(t, cfg.Validate())
+}
+
+func TestLoggingConfig_LevelAndFormat(t *testing.T) {
+	cfg := Config{
+		DropboxToken: "sl.test-token",
+		PollInterval: 5 * time.Minute,
+		Retry:        RetryConfig{MaxAttempts: 3, Delay: 30 * time.Second},
+		HealthCheck:  HealthCheckConfig{Interval: time.Minute},
+		Logging:      LoggingConfig{Level: "debug", Format: "json"},
+		Web:          WebConfig{APIToken: "web-api-token"},
+	}
+	require.NoError(t, cfg.Validate())
+
+	opts := cfg.Logging.ToLoggerOptions(cfg.SecretValues())
+	assert.Equal(t, slog.LevelDebug, opts.Level)
+	assert.Equal(t, logging.FormatJSON, opts.Format)
+	assert.ElementsMatch(t, []string{"sl.test-token", "web-api-token"}, opts.Secrets)
+
+	cfg.Logging.Level = "verbose"
+	assert.Error(t, cfg.Validate())
+
+	cfg.Logging = LoggingConfig{Format: "xml"}
+	assert.Error(t, cfg.Validate())
+}
+
+func TestReportConfig_Validate(t *testing.T) {
+	cfg := Config{
+		DropboxToken: "test-token",
+		PollInterval: 5 * time.Minute,
+		Retry:        RetryConfig{MaxAttempts: 3, Delay: 30 * time.Second},
+		HealthCheck:  HealthCheckConfig{Interval: time.Minute},
+	}
+	for _, language := range []string{"", "en", "af", "nl-BE"} {
+		cfg.Report.Language = language
+		assert.NoError(t, cfg.Validate(), language)
+	}
+	cfg.Report.Language = "fr"
+	assert.Error(t, cfg.Validate())
+
+	cfg.Report.Language = ""
+	cfg.Report.Links = ReportLinksConfig{Thumbnails: true, ThumbnailSize: "w256h256"}
+	assert.NoError(t, cfg.Validate())
+	assert.True(t, cfg.Report.Links.IsEnabled())
+	cfg.Report.Links.ThumbnailSize = "256"
+	assert.Error(t, cfg.Validate())
+
+	cfg.Report.Links = ReportLinksConfig{}
+	cfg.Report.ExecutiveSummary = true
+	assert.Error(t, cfg.Validate(), "executive summaries need an AI provider")
+
+	cfg.Report.ExecutiveSummary = false
+	cfg.Report.Topics = ReportTopicsConfig{Enabled: true}
+	assert.Error(t, cfg.Validate(), "topics need an AI provider")
+	cfg.AI = AIConfig{Enabled: true, Provider: "ollama"}
+	assert.NoError(t, cfg.Validate())
+	cfg.Report.Topics.MaxFiles = -1
+	assert.Error(t, cfg.Validate())
+}
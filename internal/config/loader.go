@@ -0,0 +1,190 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults used when neither the config file nor the environment set them
+const (
+	DefaultPollInterval        = 5 * time.Minute
+	DefaultShutdownTimeout     = 30 * time.Second
+	DefaultRetryAttempts       = 3
+	DefaultRetryDelay          = 5 * time.Second
+	DefaultHealthCheckInterval = time.Minute
+	DefaultSMTPPort            = 587
+)
+
+// LoadOptions says where Load reads the configuration from
+type LoadOptions struct {
+	// Path is the YAML config file; when empty, only the defaults and the
+	// environment are used
+	Path string
+	// EnvFile is a .env file loaded into the environment first. A missing
+	// file is not an error, and variables already set are kept.
+	EnvFile string
+	// Overrides applies command-line flags, overriding every other source
+	Overrides func(*Config)
+	// SkipValidation returns the configuration without validating it, for
+	// commands that use only part of it, such as sending a test email
+	SkipValidation bool
+}
+
+// Load builds the configuration from, in increasing precedence: the
+// defaults, the YAML file, the environment (including the .env file) and
+// command-line flags. The result is validated unless SkipValidation is set.
+func Load(opts LoadOptions) (*Config, error) {
+	if err := LoadEnvFile(opts.EnvFile); err != nil {
+		return nil, err
+	}
+
+	config := defaultConfig()
+	if opts.Path != "" {
+		if err := decodeFile(opts.Path, &config); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyEnv(&config); err != nil {
+		return nil, err
+	}
+	if opts.Overrides != nil {
+		opts.Overrides(&config)
+	}
+
+	if opts.SkipValidation {
+		return &config, nil
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// LoadEnvFile loads variables from path into the environment. A missing
+// file is not an error; variables already set take precedence.
+func LoadEnvFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := godotenv.Load(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to load %s: %w", path, err)
+	}
+	return nil
+}
+
+// defaultConfig returns the settings every configuration starts from
+func defaultConfig() Config {
+	return Config{
+		PollInterval:    DefaultPollInterval,
+		ShutdownTimeout: DefaultShutdownTimeout,
+		Retry: RetryConfig{
+			MaxAttempts: DefaultRetryAttempts,
+			Delay:       DefaultRetryDelay,
+		},
+		HealthCheck: HealthCheckConfig{
+			Interval: DefaultHealthCheckInterval,
+		},
+	}
+}
+
+// decodeFile reads the YAML file at path over config, resolving the secret
+// references in its values
+func decodeFile(path string, config *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if err := resolveSecrets(&node, filepath.Dir(path)); err != nil {
+		return err
+	}
+	if err := node.Decode(config); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return nil
+}
+
+// envSetting is a setting that an environment variable overrides
+type envSetting struct {
+	name  string
+	apply func(c *Config, value string) error
+}
+
+// envSettings are the environment variables Load reads, as documented for
+// the .env file
+var envSettings = []envSetting{
+	{"DROPBOX_ACCESS_TOKEN", func(c *Config, v string) error { c.DropboxToken = v; return nil }},
+	{"DROPBOX_APP_KEY", func(c *Config, v string) error { c.DropboxAppKey = v; return nil }},
+	{"DROPBOX_APP_SECRET", func(c *Config, v string) error { c.DropboxAppSecret = v; return nil }},
+	{"DROPBOX_REFRESH_TOKEN", func(c *Config, v string) error { c.DropboxRefreshToken = v; return nil }},
+	{"SCHEDULER_INTERVAL", func(c *Config, v string) error {
+		interval, err := parseMinutes(v)
+		c.PollInterval = interval
+		return err
+	}},
+	{"SMTP_SERVER", func(c *Config, v string) error { c.email().SMTPHost = v; return nil }},
+	{"SMTP_PORT", func(c *Config, v string) error {
+		port, err := strconv.Atoi(v)
+		c.email().SMTPPort = port
+		return err
+	}},
+	{"SMTP_USERNAME", func(c *Config, v string) error { c.email().SMTPUsername = v; return nil }},
+	{"SMTP_PASSWORD", func(c *Config, v string) error { c.email().SMTPPassword = v; return nil }},
+	{"FROM_EMAIL", func(c *Config, v string) error { c.email().FromAddress = v; return nil }},
+	{"TO_EMAILS", func(c *Config, v string) error { c.email().ToAddresses = splitList(v); return nil }},
+	{"MONITOR_API_TOKEN", func(c *Config, v string) error { c.Web.APIToken = v; return nil }},
+	{"LOG_LEVEL", func(c *Config, v string) error { c.Logging.Level = strings.ToLower(v); return nil }},
+}
+
+// applyEnv overrides config with the environment variables that are set
+func applyEnv(config *Config) error {
+	for _, setting := range envSettings {
+		value, ok := os.LookupEnv(setting.name)
+		if !ok || value == "" {
+			continue
+		}
+		if err := setting.apply(config, value); err != nil {
+			return fmt.Errorf("environment configuration error: invalid %s: %w", setting.name, err)
+		}
+	}
+	return nil
+}
+
+// email returns the email configuration, creating it with the default port
+// when the config file has none
+func (c *Config) email() *EmailConfig {
+	if c.EmailConfig == nil {
+		c.EmailConfig = &EmailConfig{SMTPPort: DefaultSMTPPort}
+	}
+	return c.EmailConfig
+}
+
+// parseMinutes parses a duration, where a bare number counts minutes
+func parseMinutes(s string) (time.Duration, error) {
+	if minutes, err := strconv.Atoi(s); err == nil {
+		return time.Duration(minutes) * time.Minute, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
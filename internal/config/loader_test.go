@@ -0,0 +1,63 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Precedence(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+dropbox_token: yaml-token
+poll_interval: 10m
+logging:
+  level: warn
+email_config:
+  smtp_host: smtp.yaml.com
+  smtp_port: 587
+  smtp_password: yaml-password
+`), 0600))
+	envFile := filepath.Join(dir, ".env")
+	require.NoError(t, os.WriteFile(envFile, []byte("SMTP_PASSWORD=dotenv-password\nTO_EMAILS=a@example.com, b@example.com\n"), 0600))
+	t.Setenv("SMTP_PASSWORD", "")
+	os.Unsetenv("SMTP_PASSWORD")
+	t.Setenv("TO_EMAILS", "")
+	os.Unsetenv("TO_EMAILS")
+	t.Setenv("DROPBOX_ACCESS_TOKEN", "env-token")
+	t.Setenv("SCHEDULER_INTERVAL", "15")
+
+	cfg, err := Load(LoadOptions{Path: path, EnvFile: envFile, Overrides: func(cfg *Config) {
+		cfg.Logging.Level = "debug"
+	}})
+	require.NoError(t, err)
+
+	assert.Equal(t, DefaultRetryAttempts, cfg.Retry.MaxAttempts, "defaults fill unset settings")
+	assert.Equal(t, DefaultShutdownTimeout, cfg.ShutdownTimeout)
+	assert.Equal(t, "smtp.yaml.com", cfg.EmailConfig.SMTPHost, "the config file overrides defaults")
+	assert.Equal(t, "env-token", cfg.DropboxToken, "the environment overrides the config file")
+	assert.Equal(t, 15*time.Minute, cfg.PollInterval)
+	assert.Equal(t, "dotenv-password", cfg.EmailConfig.SMTPPassword, "the .env file feeds the environment")
+	assert.Equal(t, []string{"a@example.com", "b@example.com"}, cfg.EmailConfig.ToAddresses)
+	assert.Equal(t, "debug", cfg.Logging.Level, "flags override everything")
+}
+
+func TestLoad_EnvironmentOnly(t *testing.T) {
+	t.Setenv("DROPBOX_ACCESS_TOKEN", "env-token")
+	t.Setenv("SMTP_SERVER", "smtp.example.com")
+
+	cfg, err := Load(LoadOptions{EnvFile: filepath.Join(t.TempDir(), "missing.env")})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultPollInterval, cfg.PollInterval)
+	assert.Equal(t, "smtp.example.com", cfg.EmailConfig.SMTPHost)
+	assert.Equal(t, DefaultSMTPPort, cfg.EmailConfig.SMTPPort)
+
+	t.Setenv("SMTP_PORT", "not-a-port")
+	_, err = Load(LoadOptions{})
+	assert.ErrorContains(t, err, "invalid SMTP_PORT")
+}
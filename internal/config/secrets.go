@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Secret reference prefixes. A value that is a reference as a whole is
+// replaced by the secret it points at.
+const (
+	filePrefix    = "file://"
+	keyringPrefix = "keyring://"
+)
+
+// envReference matches ${ENV_VAR} references, which may appear anywhere in
+// a value
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// keyringLookup reads the secret stored for service and account in the OS
+// keyring; tests replace it
+var keyringLookup = lookupKeyring
+
+// resolveSecrets replaces the secret references in the values of a parsed
+// config file: ${ENV_VAR} with the variable, file://path with the contents
+// of the file, relative to dir, and keyring://service/account with the OS
+// keyring entry. Every reference that cannot be resolved is reported with
+// the key and line it is on.
+func resolveSecrets(node *yaml.Node, dir string) error {
+	var unresolved []string
+	walkScalars(node, "", func(path string, n *yaml.Node) {
+		value, err := resolveSecret(n.Value, dir)
+		if err != nil {
+			unresolved = append(unresolved, fmt.Sprintf("%s (line %d): %v", path, n.Line, err))
+			return
+		}
+		if value == n.Value {
+			return
+		}
+		if n.Style == 0 {
+			// Let a plain reference resolve to a number or boolean
+			n.Tag = ""
+		}
+		n.Value = value
+	})
+	if len(unresolved) > 0 {
+		return fmt.Errorf("secrets configuration error: unresolved %s", strings.Join(unresolved, "; "))
+	}
+	return nil
+}
+
+// walkScalars calls fn with every scalar value under node and its dotted
+// key path
+func walkScalars(node *yaml.Node, path string, fn func(path string, n *yaml.Node)) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			walkScalars(child, path, fn)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if path != "" {
+				key = path + "." + key
+			}
+			walkScalars(node.Content[i+1], key, fn)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			walkScalars(child, fmt.Sprintf("%s[%d]", path, i), fn)
+		}
+	case yaml.ScalarNode:
+		fn(path, node)
+	}
+}
+
+// resolveSecret resolves the secret references in value
+func resolveSecret(value, dir string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, filePrefix):
+		path := strings.TrimPrefix(value, filePrefix)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("secret file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case strings.HasPrefix(value, keyringPrefix):
+		service, account, ok := strings.Cut(strings.TrimPrefix(value, keyringPrefix), "/")
+		if !ok || service == "" || account == "" {
+			return "", fmt.Errorf("keyring reference %q is not keyring://service/account", value)
+		}
+		secret, err := keyringLookup(service, account)
+		if err != nil {
+			return "", fmt.Errorf("keyring entry %s/%s: %w", service, account, err)
+		}
+		return secret, nil
+	}
+
+	var missing []string
+	resolved := envReference.ReplaceAllStringFunc(value, func(ref string) string {
+		name := envReference.FindStringSubmatch(ref)[1]
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return env
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return resolved, nil
+}
+
+// lookupKeyring reads a generic password from the macOS keychain, or from
+// the Secret Service keyring (through secret-tool) elsewhere
+func lookupKeyring(service, account string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "windows":
+		return "", fmt.Errorf("the OS keyring is not supported on windows")
+	default:
+		cmd = exec.Command("secret-tool", "lookup", "service", service, "account", account)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimRight(string(out), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("not found")
+	}
+	return secret, nil
+}
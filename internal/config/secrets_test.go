@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig_SecretReferences(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "smtp_password"), []byte("file-password\n"), 0600))
+	t.Setenv("TEST_DROPBOX_TOKEN", "env-token")
+	t.Setenv("TEST_SMTP_PORT", "2525")
+
+	keyringLookup = func(service, account string) (string, error) {
+		if service == "dropbox-monitor" && account == "api" {
+			return "keyring-token", nil
+		}
+		return "", fmt.Errorf("not found")
+	}
+	defer func() { keyringLookup = lookupKeyring }()
+
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+dropbox_token: ${TEST_DROPBOX_TOKEN}
+poll_interval: 5m
+retry:
+  max_attempts: 3
+  delay: 5s
+health_check:
+  interval: 1m
+email_config:
+  smtp_host: smtp.${TEST_SMTP_SUBDOMAIN}example.com
+  smtp_port: ${TEST_SMTP_PORT}
+  smtp_password: file://smtp_password
+web:
+  api_token: keyring://dropbox-monitor/api
+`), 0600))
+
+	_, err := LoadConfig(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "email_config.smtp_host (line 10): environment variable TEST_SMTP_SUBDOMAIN is not set")
+
+	t.Setenv("TEST_SMTP_SUBDOMAIN", "mail.")
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "env-token", cfg.DropboxToken)
+	assert.Equal(t, "smtp.mail.example.com", cfg.EmailConfig.SMTPHost)
+	assert.Equal(t, 2525, cfg.EmailConfig.SMTPPort)
+	assert.Equal(t, "file-password", cfg.EmailConfig.SMTPPassword)
+	assert.Equal(t, "keyring-token", cfg.Web.APIToken)
+}
+
+func TestLoadConfig_UnresolvedSecrets(t *testing.T) {
+	keyringLookup = func(service, account string) (string, error) {
+		return "", fmt.Errorf("not found")
+	}
+	defer func() { keyringLookup = lookupKeyring }()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`dropbox_token: file://missing
+email_config:
+  smtp_password: keyring://dropbox-monitor/smtp
+accounts:
+  - id: second
+    dropbox_token: keyring://no-account
+`), 0600))
+
+	_, err := LoadConfig(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "secrets configuration error")
+	assert.Contains(t, err.Error(), "dropbox_token (line 1): secret file")
+	assert.Contains(t, err.Error(), "email_config.smtp_password (line 3): keyring entry dropbox-monitor/smtp: not found")
+	assert.Contains(t, err.Error(), "accounts[0].dropbox_token (line 6)")
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ByteSize is a size in bytes that can be written in YAML as a plain number
+// or with a binary unit suffix such as "500MB" or "2TB"
+type ByteSize int64
+
+// byteUnits maps unit suffixes to multipliers, longest suffixes first
+var byteUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseByteSize parses a size such as "2TB", "1.5GB" or "1024"
+func ParseByteSize(s string) (ByteSize, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	if value == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	multiplier := int64(1)
+	for _, unit := range byteUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			multiplier = unit.multiplier
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return ByteSize(n * float64(multiplier)), nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (b *ByteSize) UnmarshalYAML(node *yaml.Node) error {
+	size, err := ParseByteSize(node.Value)
+	if err != nil {
+		return err
+	}
+	*b = size
+	return nil
+}
+
+// String renders the size with the largest binary unit that keeps it at
+// least 1, e.g. "1.5 GB"
+func (b ByteSize) String() string {
+	for _, unit := range byteUnits {
+		if int64(b) >= unit.multiplier && unit.multiplier > 1 {
+			return fmt.Sprintf("%.1f %s", float64(b)/float64(unit.multiplier), unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%d B", int64(b))
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/safego"
+)
+
+// watchDebounce is how long the config file must be left alone before a
+// change is reported, so an editor's several writes reload once
+const watchDebounce = 250 * time.Millisecond
+
+// WatchFile calls onChange each time the file at path is written or
+// replaced, until ctx is done. The directory is watched rather than the file
+// so that editors saving through a rename are noticed too.
+func WatchFile(ctx context.Context, path string, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config watcher: %w", err)
+	}
+	path = filepath.Clean(path)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", path, err)
+	}
+
+	safego.Go(nil, "config watcher", func() {
+		defer watcher.Close()
+
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == path && event.Has(fsnotify.Write|fsnotify.Create) {
+					debounce = time.After(watchDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Warn("Config watcher error", "component", "config", "error", err)
+			case <-debounce:
+				debounce = nil
+				onChange()
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("poll_interval: 5m\n"), 0600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan struct{}, 10)
+	require.NoError(t, WatchFile(ctx, path, func() { changed <- struct{}{} }))
+
+	// Other files in the directory are ignored
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.yaml"), []byte("x"), 0600))
+	select {
+	case <-changed:
+		t.Fatal("change reported for another file")
+	case <-time.After(2 * watchDebounce):
+	}
+
+	// An editor saving through a temporary file and a rename
+	tmp := filepath.Join(dir, ".config.yaml.swp")
+	require.NoError(t, os.WriteFile(tmp, []byte("poll_interval: 1m\n"), 0600))
+	require.NoError(t, os.Rename(tmp, path))
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("change not reported")
+	}
+}
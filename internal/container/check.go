@@ -0,0 +1,122 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// CheckOptions configures a one-off check
+type CheckOptions struct {
+	// ReportType is the report generated from the detected changes
+	ReportType models.ReportType
+	// Send sends the report through the configured notifiers, and lets the
+	// monitored folders email their own recipients, when there are changes
+	Send bool
+}
+
+// changePeeker is implemented by file change agents that can list the
+// changes of their next check without consuming them
+type changePeeker interface {
+	PeekChanges(ctx context.Context) ([]models.FileChange, error)
+}
+
+// CheckNow runs the monitoring pipeline once: every monitored folder is
+// checked for changes, which are classified, enriched and stored like
+// polled changes, and a report of them is generated. Changes sent are left
+// out of the next scheduled report. Without Send, the changes are only
+// listed and classified: they are not published, so nothing is stored,
+// alerted or queued for a report, and the next check detects them again.
+// The container must be running, or started with StartAgents; StartState
+// is enough without Send.
+func (c *Container) CheckNow(ctx context.Context, opts CheckOptions) (*models.Report, error) {
+	if opts.ReportType == "" {
+		opts.ReportType = models.FileListReport
+	}
+	if c.reportingAgent == nil {
+		return nil, fmt.Errorf("reporting agent is not configured")
+	}
+
+	check := c.checkChanges
+	if !opts.Send {
+		check = c.peekChanges
+	}
+	changes, err := check(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for changes: %w", err)
+	}
+
+	report, err := c.reportingAgent.PreviewReport(ctx, changes, opts.ReportType)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Send && len(changes) > 0 {
+		if err := c.reportingAgent.GenerateCustomReport(ctx, report.Title, changes, opts.ReportType); err != nil {
+			return nil, fmt.Errorf("failed to send report: %w", err)
+		}
+		c.freshness.Reported(changes)
+		if c.scheduler != nil {
+			if err := c.scheduler.Reported(ctx, changes); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return report, nil
+}
+
+// checkChanges checks every monitored folder and returns the changes they
+// published
+func (c *Container) checkChanges(ctx context.Context) ([]models.FileChange, error) {
+	var (
+		mu      sync.Mutex
+		changes []models.FileChange
+	)
+	unsubscribe := c.Subscribe(func(ctx context.Context, detected []models.FileChange) error {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, detected...)
+		return nil
+	})
+	defer unsubscribe()
+	if err := c.TriggerCheck(ctx); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// peekChanges lists the changes of the next check of every monitored folder
+// and classifies them, without publishing them
+func (c *Container) peekChanges(ctx context.Context) ([]models.FileChange, error) {
+	fileChangeAgents, err := c.changeAgents()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		changes []models.FileChange
+		errs    []error
+	)
+	for _, fca := range fileChangeAgents {
+		peeker, ok := fca.(changePeeker)
+		if !ok {
+			errs = append(errs, fmt.Errorf("file change agent cannot list changes without consuming them"))
+			continue
+		}
+		peeked, err := peeker.PeekChanges(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		changes = append(changes, peeked...)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	c.rules.Apply(ctx, changes)
+	c.enrichers.Apply(ctx, changes)
+	return changes, nil
+}
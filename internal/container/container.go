@@ -2,19 +2,42 @@ package container
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"sync"
+	"time"
 
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/actions"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/agent"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/agents"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/analysis"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/anomaly"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/core"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/diff"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/digest"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/embeddings"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/enrich"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/events"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/features"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/freshness"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/interfaces"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/limits"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/notify"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/preview"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/quota"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/reporting"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/safego"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/scheduler"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/severity"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/sharing"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/teamlog"
 )
 
 // Container represents the application container
@@ -26,6 +49,37 @@ type Container struct {
 	reportingAgent agents.ReportingAgent
 	scheduler     *scheduler.Scheduler
 	agentManager  agents.AgentManager
+	fileChangeAgent agent.FileChangeAgent
+	fileChangeAgents []agent.FileChangeAgent
+	// folders holds the reloadable settings of each file change agent
+	folders       []*monitoredFolder
+	stateManager  *core.StateManager
+	database      *db.DB
+	limits        *limits.Guard
+	events        *events.Bus
+	features      *features.Registry
+	account       *dropbox.AccountCache
+	actions       *actions.Service
+	freshness     *freshness.Tracker
+	embeddings    *embeddings.Index
+	bounces       *notify.Bounces
+	rules         *analysis.Rules
+	enrichers     *enrich.Pipeline
+	severity      *severity.Alerter
+	anomalies     *anomaly.Detector
+	// digestNotifiers send digests to their audience; they are not queued
+	// since the queue is shared per channel with the realtime notifier
+	digestNotifiers map[config.Audience]*notify.MultiNotifier
+	logger        *slog.Logger
+	configMu      sync.RWMutex
+	// reloadMu serializes reloads from SIGHUP and the config file watcher
+	reloadMu      sync.Mutex
+	// components orders the startup and shutdown of the components, built
+	// when the container starts
+	components *lifecycle.Graph
+	// supervisor restarts the folders and the scheduler when they fail;
+	// nil when the container is built from mocks
+	supervisor *lifecycle.Supervisor
 }
 
 // NewContainer creates a new container
@@ -34,13 +88,62 @@ func NewContainer(cfg *config.Config) (*Container, error) {
 		return nil, fmt.Errorf("config cannot be nil")
 	}
 
+	// Create state manager, shared with the token source so refreshed tokens persist
+	stateManager := core.NewStateManager(cfg.State.Path)
+
+	guard, err := limits.NewGuard(cfg.Limits.ToLimits())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create limits: %w", err)
+	}
+
 	// Create dropbox client
-	dropboxClient, err := dropbox.NewDropboxClient(cfg.DropboxToken)
+	dropboxClient, err := newDropboxClient(cfg, stateManager, guard)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create dropbox client: %w", err)
 	}
 
-	return NewContainerWithClient(cfg, dropboxClient)
+	return newContainer(cfg, dropboxClient, stateManager, guard)
+}
+
+// NewDropboxClient creates the Dropbox client configured in cfg, for
+// commands that call Dropbox without building a container
+func NewDropboxClient(cfg *config.Config, stateManager *core.StateManager) (*dropbox.DropboxClient, error) {
+	guard, err := limits.NewGuard(cfg.Limits.ToLimits())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create limits: %w", err)
+	}
+	return newDropboxClient(cfg, stateManager, guard)
+}
+
+// newDropboxClient creates a Dropbox client using the refresh-token flow when
+// configured, falling back to the static access token
+func newDropboxClient(cfg *config.Config, stateManager *core.StateManager, guard *limits.Guard) (*dropbox.DropboxClient, error) {
+	clientConfig := dropbox.DefaultClientConfig()
+	clientConfig.Limits = guard
+	clientConfig.Fixtures = cfg.Fixtures.ToFixtureConfig()
+	clientConfig.Team = cfg.Team.ToTeamConfig()
+	clientConfig.RateLimit = cfg.Limits.ToRateLimitConfig()
+	clientConfig.RateLimitPause = cfg.Limits.ToRateLimitPauseConfig()
+	clientConfig.TraversalWorkers = cfg.Limits.GetMaxConcurrentListings()
+
+	if cfg.Fixtures.Replaying() {
+		// Replayed responses do not depend on the token
+		return dropbox.NewDropboxClientWithTokenSource(dropbox.StaticTokenSource("replay"), clientConfig)
+	}
+	if !cfg.UsesRefreshToken() {
+		return dropbox.NewDropboxClientWithConfig(cfg.DropboxToken, clientConfig)
+	}
+
+	tokens, err := dropbox.NewRefreshTokenSource(dropbox.OAuthConfig{
+		AppKey:       cfg.DropboxAppKey,
+		AppSecret:    cfg.DropboxAppSecret,
+		RefreshToken: cfg.DropboxRefreshToken,
+	}, stateManager)
+	if err != nil {
+		return nil, err
+	}
+
+	return dropbox.NewDropboxClientWithTokenSource(tokens, clientConfig)
 }
 
 // NewContainerWithClient creates a new container with a provided Dropbox client
@@ -49,17 +152,107 @@ func NewContainerWithClient(cfg *config.Config, dropboxClient interfaces.Dropbox
 		return nil, fmt.Errorf("config cannot be nil")
 	}
 
-	// Create notifier
-	notifier := notify.NewEmailNotifier(cfg.EmailConfig)
+	guard, err := limits.NewGuard(cfg.Limits.ToLimits())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create limits: %w", err)
+	}
 
-	// Create content analyzer
-	contentAnalyzer := analysis.NewContentAnalyzer()
+	return newContainer(cfg, dropboxClient, core.NewStateManager(cfg.State.Path), guard)
+}
+
+// newContainer wires all components around the given client, state manager and limits
+func newContainer(cfg *config.Config, dropboxClient interfaces.DropboxClient, stateManager *core.StateManager, guard *limits.Guard) (*Container, error) {
+	// Components log through the default logger, each with its own
+	// component field
+	logger := slog.Default()
+
+	// Create feature flags
+	flags, err := features.NewRegistry(cfg.Features)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create feature flags: %w", err)
+	}
 
 	// Create database connection
-	dbConn, err := db.NewDB(cfg.Database.Path)
+	dbConn, err := db.Open(cfg.Database.GetDriver(), cfg.Database.GetDSN())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create database connection: %w", err)
 	}
+	dbConn.SetWriteBatchSize(cfg.Database.GetBatchSize())
+	dbConn.SetContentPolicy(cfg.Database.Contents.ToContentPolicy())
+
+	// Track email deliveries, disabling recipients that keep bouncing
+	var bounces *notify.Bounces
+	if cfg.EmailConfig != nil {
+		bounces = notify.NewBounces(dbConn, cfg.EmailConfig.Bounces.MaxBounces)
+		if mailbox := cfg.EmailConfig.Bounces.Mailbox; mailbox.Enabled() {
+			bounces.WithMailbox(notify.NewBounceMailbox(mailbox, cfg.EmailConfig.GetTimeout()))
+		}
+	}
+
+	// Create notifier dispatching to every configured channel, queueing
+	// failed deliveries in the database for retry
+	notifier := notify.NewMultiNotifierFromConfig(cfg, bounces).WithQueue(dbConn).WithArchive(dbConn)
+	if bounces != nil {
+		bounces.WithAlerts(notifier)
+	}
+	reportPanics(notifier, logger)
+
+	// Create content analyzer, downloading changed files through the
+	// Dropbox client
+	analyzerConfig := cfg.AI.ToAnalyzerConfig()
+	analyzerConfig.Fetcher = dropboxClient
+	if cfg.AI.Enabled {
+		analyzerConfig.Provider, err = analysis.NewProvider(cfg.AI.ToProviderConfig())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create AI provider: %w", err)
+		}
+	}
+	contentAnalyzer := analysis.NewContentAnalyzerWithConfig(analyzerConfig)
+
+	// Classify changes by portfolio, project and document type before they
+	// are stored, asking the AI provider when the rules fall short
+	var rules *analysis.Rules
+	if cfg.Classification.Enabled() {
+		rulesConfig := cfg.Classification.ToRulesConfig()
+		if cfg.Classification.LLMFallback {
+			rulesConfig.Fallback = analyzerConfig.Provider
+		}
+		rules, err = analysis.NewRules(rulesConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create classification rules: %w", err)
+		}
+	}
+
+	// Attach custom fields to changes before they are stored; code embedding
+	// the monitor can add its own enrichers through Enrichers
+	patterns, err := enrich.NewPatterns(cfg.Enrichment.ToPatternRules())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create enrichment patterns: %w", err)
+	}
+	enrichers := enrich.NewPipeline(patterns)
+
+	// Grade changes by the severity rules, alerting each grade through the
+	// channels of its tier
+	var alerter *severity.Alerter
+	if cfg.Severity.Enabled() {
+		classifier, err := severity.NewClassifier(cfg.Severity.ToRules())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create severity rules: %w", err)
+		}
+		alerter = severity.NewAlerter(classifier, cfg.Severity.ToTiers(), notifier, logger)
+	}
+
+	// Watch for mass deletions, renames and encryption, alerting through
+	// the dedicated anomaly channel
+	var detector *anomaly.Detector
+	if cfg.Anomaly.Enabled() {
+		detectorConfig := cfg.Anomaly.ToDetectorConfig()
+		detectorConfig.Language = cfg.Report.Language
+		detector, err = anomaly.NewDetector(detectorConfig, dropboxClient, notify.NewAnomalyNotifier(cfg, bounces), logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create anomaly detector: %w", err)
+		}
+	}
 
 	// Create database agent
 	dbAgent, err := db.NewDatabaseAgent(dbConn)
@@ -67,32 +260,180 @@ func NewContainerWithClient(cfg *config.Config, dropboxClient interfaces.Dropbox
 		return nil, fmt.Errorf("failed to create database agent: %w", err)
 	}
 
-	// Create state manager
-	stateManager := core.NewStateManager(cfg.State.Path)
+	// Create reporting agent, including recent team events when configured
+	reporterConfig := reporting.ReporterConfig{
+		Limits:     guard,
+		Language:   cfg.Report.Language,
+		Archive:    dbConn,
+		Recipients: reportRecipients(cfg, config.AudienceRealtime),
+	}
+	if cfg.TeamLog.IncludeInReports {
+		reporterConfig.SecurityEvents = dbConn
+		reporterConfig.SecurityWindow = cfg.PollInterval
+	}
 
-	// Create reporting agent
-	reportingAgent, err := agents.NewReportingAgent(notifier)
+	// Cache the account details for report headers and the dashboard when
+	// the client can fetch them
+	var account *dropbox.AccountCache
+	if source, ok := dropboxClient.(dropbox.AccountSource); ok {
+		account = dropbox.NewAccountCache(source, dropbox.DefaultAccountCacheTTL)
+		reporterConfig.Account = account
+	}
+	// Report the periods scanning was paused by the API rate limit
+	if gaps, ok := dropboxClient.(reporting.GapSource); ok {
+		reporterConfig.Gaps = gaps
+	}
+	if cfg.Diffs.Enabled {
+		reporterConfig.Diffs = diff.NewTracker(dropboxClient, dbConn, cfg.Diffs.ToDiffConfig())
+	}
+	if cfg.FileTypes.Sniff {
+		reporterConfig.Types = analysis.NewTypeSniffer(dropboxClient, cfg.FileTypes.ToSnifferConfig())
+	}
+	// Count the keywords and topics the AI provider finds in changed documents
+	if analyzer, ok := contentAnalyzer.(analysis.ChangeAnalyzer); ok && cfg.Report.Topics.Enabled {
+		reporterConfig.Topics = analysis.NewTopicCounter(analyzer, cfg.Report.Topics.MaxFiles)
+	}
+	if cfg.Report.ExecutiveSummary {
+		reporterConfig.Summary, err = analysis.NewExecutiveSummarizer(analyzerConfig.Provider)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create executive summarizer: %w", err)
+		}
+	}
+	// Link changed files in reports when the client can resolve links
+	if source, ok := dropboxClient.(preview.Source); ok && cfg.Report.Links.IsEnabled() {
+		resolver, err := preview.NewResolver(source, cfg.Report.Links.ToPreviewConfig(), logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create link resolver: %w", err)
+		}
+		reporterConfig.Links = resolver
+	}
+	reportingAgent, err := agents.NewReportingAgentWithConfig(notifier, reporterConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create reporting agent: %w", err)
 	}
 
+	// Track how long detected changes wait to be reported
+	tracker := freshness.NewTracker(cfg.SLO.MaxUnreportedAge)
+	reportingAgent = &trackedReportingAgent{ReportingAgent: reportingAgent, tracker: tracker}
+
 	// Create scheduler
-	scheduler, err := scheduler.NewScheduler(dropboxClient, reportingAgent, cfg.PollInterval)
+	scheduler, err := scheduler.NewScheduler(reportingAgent, cfg.PollInterval)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create scheduler: %w", err)
 	}
+	scheduler.SetLogger(logging.Component(logger, "scheduler"))
+	// Unreported changes are kept in the database, so they survive a restart
+	// and the report command can preview them
+	scheduler.SetChangeQueue(dbConn)
+
+	// Schedule custom reports for saved queries
+	for _, query := range cfg.SavedQueries {
+		if err := scheduler.AddSavedQuery(query.ChangeQuery(), query.Schedule, query.GetReportType()); err != nil {
+			return nil, fmt.Errorf("failed to schedule saved query %s: %w", query.Name, err)
+		}
+	}
+
+	// Schedule storage quota scans
+	if err := scheduleQuotas(cfg, dropboxClient, dbConn, notifier, scheduler); err != nil {
+		return nil, err
+	}
+
+	// Schedule retries of queued notifications
+	if err := scheduler.RegisterTask("notification_retry", cfg.Notify.GetRetryInterval(), notifier.Retry); err != nil {
+		return nil, fmt.Errorf("failed to schedule notification retries: %w", err)
+	}
+
+	// Schedule team log ingestion
+	if err := scheduleTeamLog(cfg, dropboxClient, dbConn, stateManager, scheduler); err != nil {
+		return nil, err
+	}
+
+	// Schedule daily and weekly digests, each emailed to its own audience
+	digestNotifiers := map[config.Audience]*notify.MultiNotifier{
+		config.AudienceDaily:  notify.NewMultiNotifierForAudience(cfg, config.AudienceDaily, bounces),
+		config.AudienceWeekly: notify.NewMultiNotifierForAudience(cfg, config.AudienceWeekly, bounces),
+	}
+	if err := scheduleDigests(cfg, dbConn, digestNotifiers, guard, dbConn, scheduler); err != nil {
+		return nil, err
+	}
+
+	// Schedule free disk space checks
+	if err := scheduleDiskCheck(cfg, guard, notifier, scheduler); err != nil {
+		return nil, err
+	}
+
+	// Schedule freshness SLO checks
+	if err := scheduleFreshness(cfg, tracker, notifier, scheduler); err != nil {
+		return nil, err
+	}
+
+	// Schedule reads of the bounce mailbox
+	if err := scheduleBounces(cfg, bounces, scheduler); err != nil {
+		return nil, err
+	}
+
+	// Schedule pruning of history past its retention
+	if err := scheduleRetention(cfg, dbConn, logging.Component(logger, "retention"), scheduler); err != nil {
+		return nil, err
+	}
+
+	// Sign acknowledge and mute links for folder alerts when configured
+	var actionLinks *actions.Service
+	if cfg.Actions.Enabled() {
+		actionLinks, err = actions.NewService(cfg.Actions.ToServiceConfig(), stateManager)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create action links: %w", err)
+		}
+	}
+
+	// Keep an inventory of the synced files through the database agent
+	inventory, _ := dbAgent.(core.FileStore)
+
+	// Create one file change agent per monitored folder and account,
+	// publishing its changes on the event bus
+	bus := events.NewBus(logger)
+	accounts, err := newFolderAccounts(cfg, dropboxClient, stateManager, guard)
+	if err != nil {
+		return nil, err
+	}
+	folders, err := newFileChangeAgents(cfg, accounts, stateManager, guard, bus, tracker, reporterConfig.Account, actionLinks, bounces, dbConn, notifier, dbConn, inventory, logger)
+	if err != nil {
+		return nil, err
+	}
+	fileChangeAgents := make([]agent.FileChangeAgent, 0, len(folders))
+	for _, folder := range folders {
+		fileChangeAgents = append(fileChangeAgents, folder.agent)
+	}
+	fileChangeAgent := fileChangeAgents[0]
+
+	// Embed changed documents for semantic search when configured
+	var index *embeddings.Index
+	if cfg.Embeddings.Enabled {
+		embedder, err := embeddings.New(cfg.Embeddings.ToEmbeddingsConfig())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create embedder: %w", err)
+		}
+		index = embeddings.NewIndex(embedder, dropboxClient, dbConn, cfg.Embeddings.ToEmbeddingsConfig())
+	}
 
 	// Create agent manager dependencies
 	agentDeps := agents.AgentManagerDeps{
-		FileChangeAgent:  agents.NewFileChangeAgent(dropboxClient, stateManager, cfg.Monitoring.Path),
+		FileChangeAgent:  fileChangeAgent,
+		FileChangeAgents: fileChangeAgents,
 		ContentAnalyzer:  contentAnalyzer,
 		DatabaseAgent:    dbAgent,
 		ReportingAgent:   reportingAgent,
 		Notifier:        notifier,
+		Logger:           logging.Component(logger, "agents"),
 	}
 
 	// Create agent manager
-	agentManager := agents.NewAgentManager(agentDeps)
+	agentManager := agents.NewAgentManager(agentDeps, agents.WithRetryPolicy(agentRetryPolicy(cfg)))
+
+	supervisor, err := newSupervisor(cfg, folders, scheduler, notifier, logger)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create container
 	container := &Container{
@@ -100,32 +441,348 @@ func NewContainerWithClient(cfg *config.Config, dropboxClient interfaces.Dropbox
 		config:        cfg,
 		dropboxClient: dropboxClient,
 		notifier:      notifier,
+		supervisor:    supervisor,
 		reportingAgent: reportingAgent,
 		scheduler:     scheduler,
 		agentManager:  agentManager,
+		fileChangeAgent: fileChangeAgent,
+		fileChangeAgents: fileChangeAgents,
+		folders:       folders,
+		stateManager:  stateManager,
+		database:      dbConn,
+		limits:        guard,
+		events:        bus,
+		logger:        logger,
+		features:      flags,
+		account:       account,
+		actions:       actionLinks,
+		freshness:     tracker,
+		embeddings:    index,
+		bounces:       bounces,
+		rules:         rules,
+		enrichers:     enrichers,
+		severity:      alerter,
+		anomalies:     detector,
+		digestNotifiers: digestNotifiers,
+	}
+	container.subscribe()
+
+	// Schedule polls for shared links and Paper docs, merged into the change
+	// stream like ingested changes
+	if err := scheduleSharing(cfg, dropboxClient, stateManager, container.IngestChanges, scheduler); err != nil {
+		return nil, err
 	}
 
 	container.SetState(lifecycle.StateInitialized)
 	return container, nil
 }
 
+// scheduleQuotas registers a periodic scan of the configured folder budgets
+func scheduleQuotas(cfg *config.Config, dropboxClient interfaces.DropboxClient, store quota.UsageStore, notifier notify.Notifier, s *scheduler.Scheduler) error {
+	if len(cfg.Quotas.Folders) == 0 {
+		return nil
+	}
+
+	sizer, ok := dropboxClient.(quota.Sizer)
+	if !ok {
+		return fmt.Errorf("storage quotas require a dropbox client that can compute folder sizes")
+	}
+
+	budgets := make([]quota.Budget, 0, len(cfg.Quotas.Folders))
+	for _, folder := range cfg.Quotas.Folders {
+		budgets = append(budgets, quota.Budget{
+			Path:           folder.Path,
+			MaxBytes:       int64(folder.MaxSize),
+			ProjectionDays: folder.ProjectionDays,
+		})
+	}
+
+	monitor, err := quota.NewMonitor(budgets, sizer, store, quota.NewNotifierAlerter(notifier))
+	if err != nil {
+		return fmt.Errorf("failed to create quota monitor: %w", err)
+	}
+
+	if err := s.RegisterTask("quotas", cfg.Quotas.GetScanInterval(), monitor.Check); err != nil {
+		return fmt.Errorf("failed to schedule quota scans: %w", err)
+	}
+	return nil
+}
+
+// reportRecipients returns the email addresses of audience and the names of
+// the other channels reports are sent through, as recorded in the archive
+func reportRecipients(cfg *config.Config, audience config.Audience) []string {
+	channels := cfg.Notify.Channels
+	var recipients []string
+	if channels.Email.IsEnabled() {
+		recipients = append(recipients, cfg.EmailRecipients(audience)...)
+	}
+	if channels.Slack.Enabled {
+		recipients = append(recipients, "slack")
+	}
+	if channels.Webhook.Enabled {
+		recipients = append(recipients, "webhook")
+	}
+	return recipients
+}
+
+// scheduleDiskCheck registers periodic free disk space checks that pause
+// content downloads while space is low and alert when that changes
+func scheduleDiskCheck(cfg *config.Config, guard *limits.Guard, notifier notify.Notifier, s *scheduler.Scheduler) error {
+	if cfg.Limits.MinFreeDisk == 0 {
+		return nil
+	}
+
+	check := func(ctx context.Context) error {
+		status, err := guard.CheckDisk()
+		if err != nil || !status.Changed {
+			return err
+		}
+
+		message := fmt.Sprintf("Free disk space on %s has recovered to %s. Content downloads have resumed.",
+			status.Path, config.ByteSize(status.FreeBytes))
+		if status.Low {
+			message = fmt.Sprintf("Free disk space on %s is %s, below the %s threshold. Content downloads are paused; change monitoring continues.",
+				status.Path, config.ByteSize(status.FreeBytes), config.ByteSize(status.MinBytes))
+		}
+		if err := notifier.SendNotification(ctx, message); err != nil {
+			return fmt.Errorf("failed to send disk space alert: %w", err)
+		}
+		return nil
+	}
+
+	if err := s.RegisterTask("disk_space", cfg.Limits.GetDiskCheckInterval(), check); err != nil {
+		return fmt.Errorf("failed to schedule disk space checks: %w", err)
+	}
+	return nil
+}
+
+// scheduleFreshness registers periodic freshness SLO checks, which keep the
+// age metrics current and alert when the threshold is breached or recovers
+func scheduleFreshness(cfg *config.Config, tracker *freshness.Tracker, notifier notify.Notifier, s *scheduler.Scheduler) error {
+	check := func(ctx context.Context) error {
+		status := tracker.Check()
+		if !status.Changed {
+			return nil
+		}
+
+		message := fmt.Sprintf("Change reporting has caught up: the oldest unreported change is %s old, within the %s freshness SLO.",
+			status.Age.Round(time.Second), status.Threshold)
+		if status.Breached {
+			message = fmt.Sprintf("Change reporting is falling behind: %d changes are unreported and the oldest was detected %s ago, beyond the %s freshness SLO.",
+				status.Pending, status.Age.Round(time.Second), status.Threshold)
+		}
+		if err := notifier.SendNotification(ctx, message); err != nil {
+			return fmt.Errorf("failed to send freshness alert: %w", err)
+		}
+		return nil
+	}
+
+	if err := s.RegisterTask("freshness", cfg.SLO.GetCheckInterval(), check); err != nil {
+		return fmt.Errorf("failed to schedule freshness checks: %w", err)
+	}
+	return nil
+}
+
+// scheduleBounces registers periodic reads of the bounce mailbox when one
+// is configured
+func scheduleBounces(cfg *config.Config, bounces *notify.Bounces, s *scheduler.Scheduler) error {
+	if bounces == nil || !cfg.EmailConfig.Bounces.Mailbox.Enabled() {
+		return nil
+	}
+	if err := s.RegisterTask("email_bounces", cfg.EmailConfig.Bounces.Mailbox.GetCheckInterval(), bounces.CheckMailbox); err != nil {
+		return fmt.Errorf("failed to schedule bounce mailbox checks: %w", err)
+	}
+	return nil
+}
+
+// trackedReportingAgent marks changes as reported in the freshness tracker
+// once a report of them has been sent
+type trackedReportingAgent struct {
+	agents.ReportingAgent
+	tracker *freshness.Tracker
+}
+
+// GenerateReport generates and sends a report, then marks its changes as reported
+func (a *trackedReportingAgent) GenerateReport(ctx context.Context, changes []models.FileChange) error {
+	if err := a.ReportingAgent.GenerateReport(ctx, changes); err != nil {
+		return err
+	}
+	a.tracker.Reported(changes)
+	return nil
+}
+
+// scheduleDigests registers the daily and weekly digest reports, sent as
+// separate reports through the notifier of their audience and kept in archive
+func scheduleDigests(cfg *config.Config, store digest.Store, notifiers map[config.Audience]*notify.MultiNotifier, guard *limits.Guard, archive reporting.ReportArchive, s *scheduler.Scheduler) error {
+	periods := []struct {
+		period   digest.Period
+		audience config.Audience
+		enabled  bool
+	}{
+		{digest.Daily, config.AudienceDaily, cfg.Digest.Daily},
+		{digest.Weekly, config.AudienceWeekly, cfg.Digest.Weekly},
+	}
+
+	for _, p := range periods {
+		if !p.enabled {
+			continue
+		}
+		reporter, err := reporting.NewReporterWithConfig(notifiers[p.audience], reporting.ReporterConfig{
+			Limits:     guard,
+			Archive:    archive,
+			Recipients: reportRecipients(cfg, p.audience),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create %s digest reporter: %w", p.period, err)
+		}
+		digester, err := digest.NewDigester(store, reporter, cfg.Digest.GetReportType())
+		if err != nil {
+			return fmt.Errorf("failed to create %s digester: %w", p.period, err)
+		}
+		digester.WithLanguage(cfg.Report.Language)
+		if err := s.RegisterTask("digest:"+string(p.period), p.period.Interval(), digester.Task(p.period)); err != nil {
+			return fmt.Errorf("failed to schedule %s digest: %w", p.period, err)
+		}
+	}
+	return nil
+}
+
+// scheduleRetention registers periodic pruning of the history that the
+// retention policy no longer keeps
+func scheduleRetention(cfg *config.Config, database *db.DB, logger *slog.Logger, s *scheduler.Scheduler) error {
+	policy := cfg.Retention.ToRetentionPolicy()
+	if !policy.Enabled() {
+		return nil
+	}
+
+	prune := func(ctx context.Context) error {
+		result, err := database.Prune(ctx, policy, time.Now(), false)
+		if err != nil {
+			return err
+		}
+		if result.Total() > 0 {
+			logger.Info("Pruned history past retention", "file_changes", result.FileChanges,
+				"file_contents", result.FileContents, "team_events", result.TeamEvents, "folder_usage", result.FolderUsage)
+		}
+		return nil
+	}
+
+	if err := s.RegisterTask("retention", cfg.Retention.GetPruneInterval(), prune); err != nil {
+		return fmt.Errorf("failed to schedule pruning: %w", err)
+	}
+	return nil
+}
+
+// newSupervisor creates the supervisor restarting the file change agent of
+// each folder and the scheduler when they fail, and notifying through
+// notifier once one keeps failing
+func newSupervisor(cfg *config.Config, folders []*monitoredFolder, scheduler *scheduler.Scheduler, notifier notify.Notifier, logger *slog.Logger) (*lifecycle.Supervisor, error) {
+	supervisorConfig := cfg.HealthCheck.ToSupervisorConfig()
+	supervisorConfig.Logger = logging.Component(logger, "supervisor")
+	supervisorConfig.OnFailure = func(ctx context.Context, name string, failures int, err error) {
+		message := fmt.Sprintf("The %s has failed %d health checks in a row and is being restarted: %v", name, failures, err)
+		if err := notifier.SendNotification(ctx, message); err != nil {
+			supervisorConfig.Logger.Error("Failed to send supervisor alert", "component", name, "error", err)
+		}
+	}
+	supervisor := lifecycle.NewSupervisor(supervisorConfig)
+
+	for _, folder := range folders {
+		if err := supervisor.Register(fmt.Sprintf("file change agent of %s", folder.name()), folder.agent); err != nil {
+			return nil, fmt.Errorf("failed to supervise folder %q: %w", folder.name(), err)
+		}
+	}
+	if err := supervisor.Register("scheduler", scheduler); err != nil {
+		return nil, fmt.Errorf("failed to supervise scheduler: %w", err)
+	}
+	return supervisor, nil
+}
+
+// reportPanics sends a notification through notifier for every panic
+// recovered in a background goroutine
+func reportPanics(notifier notify.Notifier, logger *slog.Logger) {
+	logger = logging.Component(logger, "safego")
+	safego.SetHandler(func(p *safego.Panic) {
+		message := fmt.Sprintf("The background goroutine %s panicked and was recovered: %v", p.Name, p.Value)
+		if err := notifier.SendNotification(context.Background(), message); err != nil {
+			logger.Error("Failed to send panic alert", "goroutine", p.Name, "error", err)
+		}
+	})
+}
+
+// agentRetryPolicy returns how agents that fail to start are retried
+func agentRetryPolicy(cfg *config.Config) agents.RetryPolicy {
+	return agents.RetryPolicy{MaxAttempts: cfg.Retry.MaxAttempts, Delay: cfg.Retry.Delay}
+}
+
+// scheduleTeamLog registers periodic ingestion of the team events log
+func scheduleTeamLog(cfg *config.Config, dropboxClient interfaces.DropboxClient, store teamlog.Store, stateManager *core.StateManager, s *scheduler.Scheduler) error {
+	if !cfg.TeamLog.Enabled {
+		return nil
+	}
+
+	source, ok := dropboxClient.(teamlog.Source)
+	if !ok {
+		return fmt.Errorf("team log ingestion requires a dropbox client that can read team events")
+	}
+
+	ingester, err := teamlog.NewIngester(source, store, stateManager, cfg.TeamLog.Categories)
+	if err != nil {
+		return fmt.Errorf("failed to create team log ingester: %w", err)
+	}
+
+	if err := s.RegisterTask("team_log", cfg.TeamLog.GetPollInterval(), ingester.Ingest); err != nil {
+		return fmt.Errorf("failed to schedule team log ingestion: %w", err)
+	}
+	return nil
+}
+
+// scheduleSharing registers periodic polls for new shared links and edited
+// Paper docs, passing them to sink
+func scheduleSharing(cfg *config.Config, dropboxClient interfaces.DropboxClient, stateManager *core.StateManager, sink sharing.Sink, s *scheduler.Scheduler) error {
+	if !cfg.Sharing.Enabled() {
+		return nil
+	}
+
+	source, ok := dropboxClient.(sharing.Source)
+	if !ok {
+		return fmt.Errorf("sharing monitoring requires a dropbox client that can list shared links and Paper docs")
+	}
+
+	watcher, err := sharing.NewWatcher(source, stateManager, sink, cfg.Sharing.ToWatcherConfig())
+	if err != nil {
+		return fmt.Errorf("failed to create sharing watcher: %w", err)
+	}
+
+	if err := s.RegisterTask("sharing", cfg.Sharing.GetPollInterval(), watcher.Poll); err != nil {
+		return fmt.Errorf("failed to schedule sharing polls: %w", err)
+	}
+	return nil
+}
+
 // NewContainerWithMocks creates a new container with provided mock dependencies
 func NewContainerWithMocks(cfg *config.Config, dropboxClient interfaces.DropboxClient, reportingAgent agents.ReportingAgent, fileChangeAgent agent.FileChangeAgent, databaseAgent agents.DatabaseAgent, scheduler *scheduler.Scheduler) (*Container, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("config cannot be nil")
 	}
 
+	// Create feature flags
+	flags, err := features.NewRegistry(cfg.Features)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create feature flags: %w", err)
+	}
+
 	// Create agent manager dependencies
 	agentDeps := agents.AgentManagerDeps{
 		FileChangeAgent: fileChangeAgent,
 		ContentAnalyzer: analysis.NewContentAnalyzer(),
 		DatabaseAgent:   databaseAgent,
 		ReportingAgent:  reportingAgent,
-		Notifier:       notify.NewEmailNotifier(cfg.EmailConfig),
+		Notifier:       notify.NewMultiNotifierFromConfig(cfg, nil),
 	}
 
 	// Create agent manager
-	agentManager := agents.NewAgentManager(agentDeps)
+	agentManager := agents.NewAgentManager(agentDeps, agents.WithRetryPolicy(agentRetryPolicy(cfg)))
 
 	// Create container
 	container := &Container{
@@ -135,7 +792,12 @@ func NewContainerWithMocks(cfg *config.Config, dropboxClient interfaces.DropboxC
 		reportingAgent: reportingAgent,
 		scheduler:     scheduler,
 		agentManager:  agentManager,
+		fileChangeAgent: fileChangeAgent,
+		events:        events.NewBus(nil),
+		logger:        slog.Default(),
+		features:      flags,
 	}
+	container.subscribe()
 
 	container.SetState(lifecycle.StateInitialized)
 	return container, nil
@@ -151,13 +813,275 @@ func (c *Container) GetHealthChecker() *lifecycle.BaseComponent {
 	return c.BaseComponent
 }
 
+// Logger returns the logger the container's components log through
+func (c *Container) Logger() *slog.Logger {
+	return c.logger
+}
+
+// GetConfig returns the configuration the container was built with, or the
+// one last applied by Reload
+func (c *Container) GetConfig() *config.Config {
+	c.configMu.RLock()
+	defer c.configMu.RUnlock()
+	return c.config
+}
+
+// Reload validates cfg and passes it to every component implementing
+// lifecycle.Reloader, such as the scheduler and the notifier, so they apply
+// new settings without a restart. The monitored folders take their new poll
+// intervals, filters and recipients. Settings only read while the container
+// is built, such as the Dropbox token, the database path and the list of
+// monitored folders, take effect after a restart. Components that fail to
+// reload keep their previous settings.
+func (c *Container) Reload(ctx context.Context, cfg *config.Config) error {
+	if cfg == nil {
+		return fmt.Errorf("config cannot be nil")
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	components := []interface{}{c.notifier, c.reportingAgent, c.agentManager}
+	for _, notifier := range c.digestNotifiers {
+		components = append(components, notifier)
+	}
+	if c.scheduler != nil {
+		components = append(components, c.scheduler)
+	}
+	for _, fileChangeAgent := range c.fileChangeAgents {
+		components = append(components, fileChangeAgent)
+	}
+
+	var errs []error
+	for _, component := range components {
+		reloader, ok := component.(lifecycle.Reloader)
+		if !ok {
+			continue
+		}
+		if err := reloader.Reload(ctx, cfg); err != nil {
+			errs = append(errs, fmt.Errorf("failed to reload %T: %w", component, err))
+		}
+	}
+	if err := reloadFolders(cfg, c.folders, c.Logger()); err != nil {
+		errs = append(errs, err)
+	}
+
+	c.configMu.Lock()
+	c.config = cfg
+	c.configMu.Unlock()
+	return errors.Join(errs...)
+}
+
+// GetDB returns the database connection, or nil when the container was built with mocks
+func (c *Container) GetDB() *db.DB {
+	return c.database
+}
+
+// LimitStats returns how often the resource limits have throttled work
+func (c *Container) LimitStats() limits.Stats {
+	return c.limits.Stats()
+}
+
+// Features returns the feature flag registry
+func (c *Container) Features() *features.Registry {
+	return c.features
+}
+
+// AccountInfo returns the cached details of the monitored account, or nil
+// when the Dropbox client cannot provide them
+func (c *Container) AccountInfo(ctx context.Context) (*models.AccountInfo, error) {
+	if c.account == nil {
+		return nil, nil
+	}
+	return c.account.GetAccountInfo(ctx)
+}
+
+// Actions returns the action link service, or nil when action links are not configured
+func (c *Container) Actions() *actions.Service {
+	return c.actions
+}
+
+// Embeddings returns the semantic search index, or nil when embeddings are
+// not enabled
+func (c *Container) Embeddings() *embeddings.Index {
+	return c.embeddings
+}
+
+// Bounces returns the email delivery tracker, or nil when email is not
+// configured
+func (c *Container) Bounces() *notify.Bounces {
+	return c.bounces
+}
+
+// CircuitState returns the Dropbox client's circuit breaker state, or an empty
+// string when the client does not report one
+func (c *Container) CircuitState() string {
+	if breaker, ok := c.dropboxClient.(interface{ CircuitState() string }); ok {
+		return breaker.CircuitState()
+	}
+	return ""
+}
+
+// circuitBreaker is a Dropbox client whose circuit breaker can be inspected
+// and reset
+type circuitBreaker interface {
+	CircuitStatus() dropbox.CircuitStatus
+	ResetCircuit()
+}
+
+// CircuitStatus returns the Dropbox client's circuit breaker status, and
+// false when the client has no circuit breaker
+func (c *Container) CircuitStatus() (dropbox.CircuitStatus, bool) {
+	if breaker, ok := c.dropboxClient.(circuitBreaker); ok {
+		return breaker.CircuitStatus(), true
+	}
+	return dropbox.CircuitStatus{}, false
+}
+
+// ResetCircuit closes the Dropbox client's circuit breaker after a known
+// outage, and reports false when the client has no circuit breaker
+func (c *Container) ResetCircuit() bool {
+	breaker, ok := c.dropboxClient.(circuitBreaker)
+	if ok {
+		breaker.ResetCircuit()
+	}
+	return ok
+}
+
+// Enrichers returns the enrichment pipeline run over changes before they are
+// stored; enrichers added to it apply to changes detected from then on
+func (c *Container) Enrichers() *enrich.Pipeline {
+	return c.enrichers
+}
+
+// QuotaPause reports whether Dropbox requests are paused by the API rate limit
+// and when they resume
+func (c *Container) QuotaPause() (time.Time, bool) {
+	if pauser, ok := c.dropboxClient.(interface{ QuotaPause() (time.Time, bool) }); ok {
+		return pauser.QuotaPause()
+	}
+	return time.Time{}, false
+}
+
 // GetNotifier returns the notifier instance
 func (c *Container) GetNotifier() notify.Notifier {
 	return c.notifier
 }
 
+// ResendReport sends the archived report with the given ID again through the
+// notification channels
+func (c *Container) ResendReport(ctx context.Context, id int64) error {
+	if c.database == nil {
+		return fmt.Errorf("database is not configured")
+	}
+	return reporting.Resend(ctx, c.notifier, c.database, id)
+}
+
+// PreviewReport renders the report the scheduler would send next, without sending it
+func (c *Container) PreviewReport(ctx context.Context, reportType models.ReportType) (*models.Report, error) {
+	if c.scheduler == nil {
+		return nil, fmt.Errorf("scheduler is not configured")
+	}
+	return c.scheduler.Preview(ctx, reportType)
+}
+
+// TriggerCheck asks every file change agent to check for changes immediately
+func (c *Container) TriggerCheck(ctx context.Context) error {
+	fileChangeAgents, err := c.changeAgents()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, fca := range fileChangeAgents {
+		if err := fca.TriggerCheck(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// changeAgents returns the file change agent of every monitored folder
+func (c *Container) changeAgents() ([]agent.FileChangeAgent, error) {
+	fileChangeAgents := c.fileChangeAgents
+	if len(fileChangeAgents) == 0 && c.fileChangeAgent != nil {
+		fileChangeAgents = []agent.FileChangeAgent{c.fileChangeAgent}
+	}
+	if len(fileChangeAgents) == 0 {
+		return nil, fmt.Errorf("file change agent is not configured")
+	}
+	return fileChangeAgents, nil
+}
+
+// Subscribe registers a handler for every set of changes the monitored
+// folders report, and for ingested changes, after they are classified. It
+// returns a function that removes the handler. The handler is optional: its
+// failures are logged but do not fail the check.
+func (c *Container) Subscribe(handler core.ChangeHandler) func() {
+	return c.events.SubscribeOptional("subscriber", events.Changes(handler))
+}
+
+// Events returns the bus the changes are published on
+func (c *Container) Events() *events.Bus {
+	return c.events
+}
+
+// IngestChanges publishes externally sourced changes like those of the
+// monitored folders, so they are classified, stored and included in the next
+// scheduled report
+func (c *Container) IngestChanges(ctx context.Context, changes []models.FileChange) error {
+	for i := range changes {
+		changes[i].Normalize()
+		if err := changes[i].Validate(); err != nil {
+			return fmt.Errorf("invalid change %d: %w", i, err)
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if err := c.events.Publish(ctx, events.FileChanged{Changes: changes}); err != nil {
+		return fmt.Errorf("failed to handle changes: %w", err)
+	}
+	return nil
+}
+
+// startLevel is how many of the container's components start
+type startLevel int
+
+const (
+	// startState starts the persisted state only
+	startState startLevel = iota
+	// startAgents starts the state and the agents
+	startAgents
+	// startAll starts the scheduler and the supervisor as well
+	startAll
+)
+
 // Start starts all components in the container
 func (c *Container) Start(ctx context.Context) error {
+	return c.start(ctx, startAll)
+}
+
+// StartAgents starts only the components a one-off check needs: the
+// persisted state and the agents, without the scheduler and the supervisor.
+// Stop stops them again.
+func (c *Container) StartAgents(ctx context.Context) error {
+	return c.start(ctx, startAgents)
+}
+
+// StartState loads only the persisted state, such as the folder cursors,
+// which is all a one-off check without sending needs: no agent polls or
+// publishes changes. Stop saves the state again.
+func (c *Container) StartState(ctx context.Context) error {
+	return c.start(ctx, startState)
+}
+
+// start starts the container's components up to level
+func (c *Container) start(ctx context.Context, level startLevel) error {
 	if err := c.DefaultStart(ctx); err != nil {
 		return err
 	}
@@ -166,19 +1090,61 @@ func (c *Container) Start(ctx context.Context) error {
 		return fmt.Errorf("context cancelled: %w", err)
 	}
 
-	if err := c.agentManager.Start(ctx); err != nil {
-		return fmt.Errorf("failed to start agent manager: %w", err)
+	if level >= startAgents {
+		if err := c.agentManager.Initialize(ctx); err != nil {
+			return fmt.Errorf("failed to initialize agent manager: %w", err)
+		}
 	}
 
-	if err := c.scheduler.Start(ctx); err != nil {
-		return fmt.Errorf("failed to start scheduler: %w", err)
+	components, err := c.componentGraph(level)
+	if err != nil {
+		return err
 	}
+	c.components = components
+	return components.Start(ctx)
+}
 
-	return nil
+// componentGraph declares the order the container's components start in.
+// Persisted state is loaded before any agent reads it; the scheduler starts
+// without waiting for the agents to be healthy, so an unreachable Dropbox
+// does not prevent startup. The supervisor is added last, so it starts after
+// the components it watches and stops before them. Components above level
+// are left out.
+func (c *Container) componentGraph(level startLevel) (*lifecycle.Graph, error) {
+	graph := lifecycle.NewGraph()
+	var agentDeps []string
+	if c.stateManager != nil {
+		if err := graph.Add("state manager", c.stateManager); err != nil {
+			return nil, err
+		}
+		agentDeps = append(agentDeps, "state manager")
+	}
+	if level < startAgents {
+		return graph, nil
+	}
+	if err := graph.Add("agent manager", c.agentManager, agentDeps...); err != nil {
+		return nil, err
+	}
+	if level < startAll {
+		return graph, nil
+	}
+	if err := graph.Add("scheduler", c.scheduler); err != nil {
+		return nil, err
+	}
+	if c.supervisor != nil {
+		if err := graph.Add("supervisor", c.supervisor); err != nil {
+			return nil, err
+		}
+	}
+	return graph, nil
 }
 
-// Stop stops all components in the container
+// Stop stops all components in the container. Stopping it again, as the
+// signal handler and the web server may both do, has no effect.
 func (c *Container) Stop(ctx context.Context) error {
+	if c.State() == lifecycle.StateStopped {
+		return nil
+	}
 	if err := c.DefaultStop(ctx); err != nil {
 		return err
 	}
@@ -187,15 +1153,12 @@ func (c *Container) Stop(ctx context.Context) error {
 		return fmt.Errorf("context cancelled: %w", err)
 	}
 
-	if err := c.scheduler.Stop(ctx); err != nil {
-		return fmt.Errorf("failed to stop scheduler: %w", err)
+	// Components stop in the reverse of their start order, so state is
+	// saved after the agents have stopped changing it
+	if c.components == nil {
+		return nil
 	}
-
-	if err := c.agentManager.Stop(ctx); err != nil {
-		return fmt.Errorf("failed to stop agent manager: %w", err)
-	}
-
-	return nil
+	return c.components.Stop(ctx)
 }
 
 // Health checks the health of all components in the container
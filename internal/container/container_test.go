@@ -2,16 +2,29 @@ package container
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/analysis"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
-	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/core"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/events"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/freshness"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/health"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/mocks"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/notify"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/scheduler"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockReportingAgent mocks the ReportingAgent interface
@@ -65,6 +78,19 @@ func (m *MockReportingAgent) NotifyChanges(ctx context.Context, changes []models
 	return args.Error(0)
 }
 
+func (m *MockReportingAgent) PreviewReport(ctx context.Context, changes []models.FileChange, reportType models.ReportType) (*models.Report, error) {
+	args := m.Called(ctx, changes, reportType)
+	if report, ok := args.Get(0).(*models.Report); ok {
+		return report, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockReportingAgent) GenerateCustomReport(ctx context.Context, title string, changes []models.FileChange, reportType models.ReportType) error {
+	args := m.Called(ctx, title, changes, reportType)
+	return args.Error(0)
+}
+
 // MockFileChangeAgent mocks the FileChangeAgent interface
 type MockFileChangeAgent struct {
 	mock.Mock
@@ -111,6 +137,11 @@ func (m *MockFileChangeAgent) GetChanges(ctx context.Context) ([]models.FileChan
 	return args.Get(0).([]models.FileChange), args.Error(1)
 }
 
+func (m *MockFileChangeAgent) PeekChanges(ctx context.Context) ([]models.FileChange, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.FileChange), args.Error(1)
+}
+
 func (m *MockFileChangeAgent) GetFileContent(ctx context.Context, path string) ([]byte, error) {
 	args := m.Called(ctx, path)
 	return args.Get(0).([]byte), args.Error(1)
@@ -120,6 +151,11 @@ func (m *MockFileChangeAgent) SetPollInterval(interval time.Duration) {
 	m.Called(interval)
 }
 
+func (m *MockFileChangeAgent) TriggerCheck(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
 // MockDatabaseAgent mocks the DatabaseAgent interface
 type MockDatabaseAgent struct {
 	mock.Mock
@@ -201,6 +237,7 @@ func TestNewContainer(t *testing.T) {
 			name: "valid config",
 			cfg: &config.Config{
 				DropboxToken: "test-token",
+				Database:     config.DatabaseConfig{Path: db.MemoryPath},
 				PollInterval: 5 * time.Minute,
 				Monitoring: config.MonitoringConfig{
 					Path:    "/test/monitor",
@@ -232,10 +269,203 @@ func TestNewContainer(t *testing.T) {
 	}
 }
 
+func TestNewContainer_MonitoredFolders(t *testing.T) {
+	cfg := &config.Config{
+		DropboxToken: "test-token",
+		Database:     config.DatabaseConfig{Path: db.MemoryPath},
+		PollInterval: 5 * time.Minute,
+		EmailConfig:  &config.EmailConfig{SMTPHost: "localhost", SMTPPort: 25},
+		Monitoring: config.MonitoringConfig{
+			Folders: []config.MonitoredFolderConfig{
+				{Path: "/Work", Include: []string{"*.docx"}, Recipients: []string{"work@example.com"}},
+				{Path: "/Photos", PollInterval: time.Hour},
+			},
+		},
+	}
+
+	container, err := NewContainerWithClient(cfg, &mocks.DropboxClient{})
+	require.NoError(t, err)
+	assert.Len(t, container.GetAgentManager().GetFileChangeAgents(), 2)
+
+	// Every folder is checked in every configured account
+	cfg.Accounts = []config.AccountConfig{
+		{ID: "work", DropboxToken: "work-token"},
+		{ID: "home", DropboxToken: "home-token"},
+	}
+	container, err = NewContainerWithClient(cfg, &mocks.DropboxClient{})
+	require.NoError(t, err)
+	assert.Len(t, container.GetAgentManager().GetFileChangeAgents(), 4)
+	cfg.Accounts = nil
+
+	// Recipients need an email configuration to send through
+	cfg.EmailConfig = nil
+	_, err = NewContainerWithClient(cfg, &mocks.DropboxClient{})
+	assert.Error(t, err)
+}
+
+func TestNewContainer_NotificationChannels(t *testing.T) {
+	disabled := false
+	cfg := &config.Config{
+		DropboxToken: "test-token",
+		Database:     config.DatabaseConfig{Path: db.MemoryPath},
+		PollInterval: 5 * time.Minute,
+	}
+	cfg.Notify.Channels.Email.Enabled = &disabled
+	cfg.Notify.Channels.Slack = config.SlackChannelConfig{Enabled: true, WebhookURL: "https://hooks.slack.com/services/x"}
+
+	container, err := NewContainerWithClient(cfg, &mocks.DropboxClient{})
+	require.NoError(t, err)
+
+	multi, ok := container.GetNotifier().(*notify.MultiNotifier)
+	require.True(t, ok)
+
+	enabled := make(map[string]bool)
+	for _, channel := range multi.Channels() {
+		enabled[channel.Name] = channel.Enabled
+	}
+	assert.Equal(t, map[string]bool{"email": false, "slack": true, "webhook": false}, enabled)
+}
+
+func TestNewContainer_SeverityAlerts(t *testing.T) {
+	var alerts []string
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		alerts = append(alerts, body["text"])
+	}))
+	defer slack.Close()
+
+	disabled := false
+	cfg := &config.Config{
+		DropboxToken: "test-token",
+		Database:     config.DatabaseConfig{Path: db.MemoryPath},
+		PollInterval: 5 * time.Minute,
+		Severity: config.SeverityConfig{
+			Rules: []config.SeverityRuleConfig{{Name: "mass deletion", Level: "critical", Operations: []string{"deleted"}, MinFiles: 2}},
+			Tiers: map[string]config.SeverityTierConfig{"critical": {Channels: []string{"slack"}, SubjectPrefix: "[CRITICAL]"}},
+		},
+	}
+	cfg.Notify.Channels.Email.Enabled = &disabled
+	cfg.Notify.Channels.Slack = config.SlackChannelConfig{Enabled: true, WebhookURL: slack.URL}
+
+	container, err := NewContainerWithClient(cfg, &mocks.DropboxClient{})
+	require.NoError(t, err)
+	defer container.database.Close()
+
+	// Only the set of changes meeting the rule raises an alert
+	ctx := context.Background()
+	deleted := []models.FileChange{{Path: "/docs/a.txt", IsDeleted: true}, {Path: "/docs/b.txt", IsDeleted: true}}
+	require.NoError(t, container.events.Publish(ctx, events.FileChanged{Folder: "/docs", Changes: deleted[:1]}))
+	assert.Empty(t, alerts)
+	require.NoError(t, container.events.Publish(ctx, events.FileChanged{Folder: "/docs", Changes: deleted}))
+	require.Len(t, alerts, 1)
+	assert.True(t, strings.HasPrefix(alerts[0], "[CRITICAL] CRITICAL: 2 changes matched"), alerts[0])
+}
+
+func TestNewContainer_AnomalyAlerts(t *testing.T) {
+	var alerts, regular []string
+	security := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		alerts = append(alerts, body["text"])
+	}))
+	defer security.Close()
+	team := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		regular = append(regular, r.URL.Path)
+	}))
+	defer team.Close()
+
+	disabled := false
+	cfg := &config.Config{
+		DropboxToken: "test-token",
+		Database:     config.DatabaseConfig{Path: db.MemoryPath},
+		PollInterval: 5 * time.Minute,
+		Anomaly: config.AnomalyConfig{
+			Deletions: 3,
+			Alerts:    config.AnomalyAlertsConfig{SubjectPrefix: "[SECURITY]", SlackWebhookURL: security.URL},
+		},
+	}
+	cfg.Notify.Channels.Email.Enabled = &disabled
+	cfg.Notify.Channels.Slack = config.SlackChannelConfig{Enabled: true, WebhookURL: team.URL}
+
+	container, err := NewContainerWithClient(cfg, &mocks.DropboxClient{})
+	require.NoError(t, err)
+	defer container.database.Close()
+
+	// Deletions published separately are counted together
+	ctx := context.Background()
+	deleted := []models.FileChange{{Path: "/docs/a.txt", IsDeleted: true}, {Path: "/docs/b.txt", IsDeleted: true}, {Path: "/docs/c.txt", IsDeleted: true}}
+	require.NoError(t, container.events.Publish(ctx, events.FileChanged{Folder: "/docs", Changes: deleted[:2]}))
+	assert.Empty(t, alerts)
+	require.NoError(t, container.events.Publish(ctx, events.FileChanged{Folder: "/docs", Changes: deleted[2:]}))
+	require.Len(t, alerts, 1)
+	assert.True(t, strings.HasPrefix(alerts[0], "[SECURITY] Possible ransomware: 3 files deleted"), alerts[0])
+	assert.Empty(t, regular, "anomaly alerts bypass the regular channels")
+}
+
+func TestContainer_Reload(t *testing.T) {
+	newConfig := func(interval time.Duration) *config.Config {
+		disabled := false
+		cfg := &config.Config{
+			DropboxToken: "test-token",
+			Database:     config.DatabaseConfig{Path: db.MemoryPath},
+			PollInterval: interval,
+			Retry:        config.RetryConfig{MaxAttempts: 3, Delay: time.Second},
+			HealthCheck:  config.HealthCheckConfig{Interval: time.Second},
+		}
+		cfg.Notify.Channels.Email.Enabled = &disabled
+		return cfg
+	}
+
+	container, err := NewContainerWithClient(newConfig(5*time.Minute), &mocks.DropboxClient{})
+	require.NoError(t, err)
+
+	reloaded := newConfig(time.Minute)
+	reloaded.Notify.Channels.Slack = config.SlackChannelConfig{Enabled: true, WebhookURL: "https://hooks.slack.com/services/x"}
+	require.NoError(t, container.Reload(context.Background(), reloaded))
+	assert.Same(t, reloaded, container.GetConfig())
+	assert.Equal(t, time.Minute, container.scheduler.Interval())
+
+	multi := container.GetNotifier().(*notify.MultiNotifier)
+	for _, channel := range multi.Channels() {
+		assert.Equal(t, channel.Name == "slack", channel.Enabled, channel.Name)
+	}
+
+	// Folders take their new recipients without a restart
+	require.Len(t, container.folders, 1)
+	assert.Nil(t, container.folders[0].notifier())
+	withRecipients := newConfig(time.Minute)
+	withRecipients.EmailConfig = &config.EmailConfig{SMTPHost: "smtp.test.com", SMTPPort: 587, FromAddress: "from@test.com"}
+	withRecipients.Monitoring.Folders = []config.MonitoredFolderConfig{{Path: "", Recipients: []string{"team@test.com"}}}
+	require.NoError(t, container.Reload(context.Background(), withRecipients))
+	assert.NotNil(t, container.folders[0].notifier())
+	require.NoError(t, container.Reload(context.Background(), reloaded))
+	assert.Nil(t, container.folders[0].notifier())
+
+	// Invalid configurations are rejected before any component sees them
+	assert.Error(t, container.Reload(context.Background(), newConfig(0)))
+	assert.Same(t, reloaded, container.GetConfig())
+	assert.Equal(t, time.Minute, container.scheduler.Interval())
+	assert.Error(t, container.Reload(context.Background(), nil))
+}
+
+func TestNewContainer_TeamLogRequiresCapableClient(t *testing.T) {
+	cfg := &config.Config{
+		DropboxToken: "test-token",
+		Database:     config.DatabaseConfig{Path: db.MemoryPath},
+		PollInterval: 5 * time.Minute,
+		TeamLog:      config.TeamLogConfig{Enabled: true},
+	}
+
+	_, err := NewContainerWithClient(cfg, &mocks.DropboxClient{})
+	assert.Error(t, err)
+}
+
 func TestContainer_Lifecycle(t *testing.T) {
 	// Create test config
 	cfg := &config.Config{
 		DropboxToken: "test-token",
+		Database:     config.DatabaseConfig{Path: db.MemoryPath},
 		PollInterval: 5 * time.Minute,
 		Monitoring: config.MonitoringConfig{
 			Path:    "/test/monitor",
@@ -251,7 +481,7 @@ func TestContainer_Lifecycle(t *testing.T) {
 	}
 
 	// Create mock agents
-	mockClient := &dropbox.MockDropboxClient{}
+	mockClient := &mocks.DropboxClient{}
 
 	mockReportingAgent := NewMockReportingAgent()
 	mockReportingAgent.On("Initialize", mock.Anything).Return(nil).Once()
@@ -275,7 +505,7 @@ func TestContainer_Lifecycle(t *testing.T) {
 	mockDatabaseAgent.On("State").Return(lifecycle.StateInitialized).Maybe()
 
 	// Create scheduler
-	scheduler, err := scheduler.NewScheduler(mockClient, mockReportingAgent, cfg.PollInterval)
+	scheduler, err := scheduler.NewScheduler(mockReportingAgent, cfg.PollInterval)
 	assert.NoError(t, err)
 
 	// Create container with mocks
@@ -307,14 +537,247 @@ func TestContainer_Lifecycle(t *testing.T) {
 	err = container.Health(ctx)
 	assert.NoError(t, err)
 
+	// Every component reports healthy while running
+	reports := container.ComponentHealth(ctx)
+	assert.True(t, health.Ready(reports), "%+v", reports)
+	var names []string
+	for _, report := range reports {
+		names = append(names, report.Name)
+	}
+	assert.Equal(t, []string{"container", "scheduler", "agents", "folder", "dropbox"}, names)
+
 	// Test Stop
 	err = container.Stop(ctx)
 	assert.NoError(t, err)
 	assert.Equal(t, lifecycle.StateStopped, container.State())
 
+	// Stopping again has no effect
+	err = container.Stop(ctx)
+	assert.NoError(t, err)
+
 	// Verify all expectations were met
 	mockClient.AssertExpectations(t)
 	mockReportingAgent.AssertExpectations(t)
 	mockFileChangeAgent.AssertExpectations(t)
 	mockDatabaseAgent.AssertExpectations(t)
 }
+
+func TestContainer_IngestChanges(t *testing.T) {
+	cfg := &config.Config{
+		DropboxToken: "test-token",
+		Database:     config.DatabaseConfig{Path: db.MemoryPath},
+		PollInterval: 5 * time.Minute,
+	}
+
+	mockClient := &mocks.DropboxClient{}
+	mockReportingAgent := NewMockReportingAgent()
+	scheduler, err := scheduler.NewScheduler(mockReportingAgent, cfg.PollInterval)
+	assert.NoError(t, err)
+
+	container, err := NewContainerWithMocks(cfg, mockClient, mockReportingAgent, NewMockFileChangeAgent(), NewMockDatabaseAgent(), scheduler)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	modified := time.Now()
+
+	// Invalid changes are rejected before anything is published
+	var published []models.FileChange
+	container.Subscribe(func(ctx context.Context, changes []models.FileChange) error {
+		published = append([]models.FileChange(nil), changes...)
+		return nil
+	})
+	err = container.IngestChanges(ctx, []models.FileChange{{Path: "relative.txt", Modified: modified}})
+	assert.Error(t, err)
+	assert.Empty(t, published)
+
+	expected := []models.FileChange{{
+		Path:      "/external/data.csv",
+		Extension: ".csv",
+		Directory: "/external",
+		ModTime:   modified,
+		Modified:  modified,
+		Size:      10,
+	}}
+
+	err = container.IngestChanges(ctx, []models.FileChange{{Path: "/external/data.csv", Modified: modified, Size: 10}})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, published)
+
+	// Ingested changes are included in the next scheduled report
+	report := models.NewReport(models.FileListReport)
+	mockReportingAgent.On("PreviewReport", mock.Anything, expected, models.FileListReport).Return(report, nil).Once()
+	got, err := container.PreviewReport(ctx, models.FileListReport)
+	require.NoError(t, err)
+	assert.Same(t, report, got)
+	mockReportingAgent.AssertNotCalled(t, "GenerateReport", mock.Anything, mock.Anything)
+
+	// Classification rules apply before the changes are handed on
+	container.rules, err = analysis.NewRules(analysis.RulesConfig{
+		Paths: []analysis.PathRule{{Match: `^/external/`, Portfolio: "External"}},
+		Types: []analysis.TypeRule{{Extensions: []string{".csv"}, DocumentType: "Data"}},
+	})
+	require.NoError(t, err)
+	expected[0].Portfolio, expected[0].DocumentType = "External", "Data"
+
+	err = container.IngestChanges(ctx, []models.FileChange{{Path: "/external/data.csv", Modified: modified, Size: 10}})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, published)
+	mockReportingAgent.AssertExpectations(t)
+}
+
+func TestContainer_CheckNow(t *testing.T) {
+	cfg := &config.Config{
+		DropboxToken: "test-token",
+		Database:     config.DatabaseConfig{Path: db.MemoryPath},
+		PollInterval: 5 * time.Minute,
+	}
+
+	mockClient := &mocks.DropboxClient{}
+	mockReportingAgent := NewMockReportingAgent()
+	mockFileChangeAgent := NewMockFileChangeAgent()
+	scheduler, err := scheduler.NewScheduler(mockReportingAgent, cfg.PollInterval)
+	require.NoError(t, err)
+
+	container, err := NewContainerWithMocks(cfg, mockClient, mockReportingAgent, mockFileChangeAgent, NewMockDatabaseAgent(), scheduler)
+	require.NoError(t, err)
+
+	// A dry run needs only the state; no agent is started
+	ctx := context.Background()
+	require.NoError(t, container.StartState(ctx))
+	defer container.Stop(ctx)
+
+	var published int
+	container.events.Subscribe("database", func(ctx context.Context, event events.FileChanged) error {
+		published++
+		return nil
+	})
+
+	changes := []models.FileChange{{Path: "/docs/a.txt"}}
+	mockFileChangeAgent.On("PeekChanges", mock.Anything).Return(changes, nil).Once()
+	mockFileChangeAgent.On("TriggerCheck", mock.Anything).Run(func(mock.Arguments) {
+		container.events.Publish(ctx, events.FileChanged{Folder: "/docs", Changes: changes})
+	}).Return(nil)
+	report := &models.Report{Title: "Changes", Metadata: map[string]string{"content": "/docs/a.txt"}}
+	mockReportingAgent.On("PreviewReport", mock.Anything, changes, models.MarkdownReport).Return(report, nil)
+
+	// Without sending, the report is only rendered
+	got, err := container.CheckNow(ctx, CheckOptions{ReportType: models.MarkdownReport})
+	require.NoError(t, err)
+	assert.Equal(t, report, got)
+	mockReportingAgent.AssertNotCalled(t, "GenerateCustomReport", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	// and the changes are neither consumed nor published
+	mockFileChangeAgent.AssertNotCalled(t, "TriggerCheck", mock.Anything)
+	assert.Zero(t, published)
+
+	mockReportingAgent.On("GenerateCustomReport", mock.Anything, "Changes", changes, models.MarkdownReport).Return(nil).Once()
+	_, err = container.CheckNow(ctx, CheckOptions{ReportType: models.MarkdownReport, Send: true})
+	require.NoError(t, err)
+	mockReportingAgent.AssertExpectations(t)
+	assert.Equal(t, 1, published)
+
+	// A failed check generates no report
+	failing := NewMockFileChangeAgent()
+	failing.On("TriggerCheck", mock.Anything).Return(errors.New("dropbox down"))
+	container.fileChangeAgent = failing
+	_, err = container.CheckNow(ctx, CheckOptions{Send: true})
+	assert.Error(t, err)
+}
+
+func TestTrackedReportingAgent(t *testing.T) {
+	mockReportingAgent := NewMockReportingAgent()
+	tracker := freshness.NewTracker(time.Hour)
+	reportingAgent := &trackedReportingAgent{ReportingAgent: mockReportingAgent, tracker: tracker}
+
+	ctx := context.Background()
+	changes := []models.FileChange{{Path: "/docs/a.txt"}}
+	tracker.Detected(changes)
+
+	// A failed report leaves the changes pending
+	mockReportingAgent.On("GenerateReport", mock.Anything, changes).Return(errors.New("smtp down")).Once()
+	assert.Error(t, reportingAgent.GenerateReport(ctx, changes))
+	assert.Equal(t, 1, tracker.Check().Pending)
+
+	mockReportingAgent.On("GenerateReport", mock.Anything, changes).Return(nil).Once()
+	assert.NoError(t, reportingAgent.GenerateReport(ctx, changes))
+	assert.Equal(t, 0, tracker.Check().Pending)
+	mockReportingAgent.AssertExpectations(t)
+}
+
+type fakeChangeStore struct {
+	saved   []db.FileChange
+	batches int
+}
+
+func (s *fakeChangeStore) SaveFileChanges(ctx context.Context, changes []db.FileChange) error {
+	for _, fc := range changes {
+		if fc.FilePath == "/locked.txt" {
+			return errors.New("database is locked")
+		}
+	}
+	s.saved = append(s.saved, changes...)
+	s.batches++
+	return nil
+}
+
+func TestRecordChanges(t *testing.T) {
+	store := &fakeChangeStore{}
+	handler := recordChanges(store)
+
+	require.NoError(t, handler(context.Background(), []models.FileChange{
+		{Path: "/docs/a.txt", Size: 10},
+		{Path: "/docs/b.txt", IsDeleted: true},
+	}))
+	assert.Equal(t, 1, store.batches, "a set of changes is stored together")
+	require.Len(t, store.saved, 2)
+	assert.Equal(t, "/docs/a.txt", store.saved[0].FilePath)
+	assert.True(t, store.saved[1].IsDeleted)
+
+	// A failed write stores none of the set
+	store.saved = nil
+	err := handler(context.Background(), []models.FileChange{
+		{Path: "/docs/c.txt"},
+		{Path: "/locked.txt"},
+	})
+	assert.ErrorContains(t, err, "database is locked")
+	assert.Empty(t, store.saved)
+
+	// Renames delete the previous path so the history stays consistent
+	require.NoError(t, handler(context.Background(), []models.FileChange{
+		{Path: "/archive/a.txt", Size: 10, ChangeType: models.ChangeRenamed, PreviousPath: "/docs/a.txt"},
+	}))
+	require.Len(t, store.saved, 2)
+	assert.Equal(t, "/docs/a.txt", store.saved[0].FilePath)
+	assert.True(t, store.saved[0].IsDeleted)
+	assert.Equal(t, "/archive/a.txt", store.saved[1].FilePath)
+	assert.False(t, store.saved[1].IsDeleted)
+}
+
+func TestSetPollStatus(t *testing.T) {
+	earlier := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Minute)
+
+	tests := []struct {
+		name string
+		poll core.PollStatus
+		want health.Status
+	}{
+		{"not checked yet", core.PollStatus{}, health.StatusHealthy},
+		{"checked", core.PollStatus{LastSuccess: later}, health.StatusHealthy},
+		{"recovered", core.PollStatus{LastSuccess: later, LastError: "timeout", LastErrorAt: earlier}, health.StatusHealthy},
+		{"failing", core.PollStatus{LastSuccess: earlier, LastError: "timeout", LastErrorAt: later}, health.StatusUnhealthy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := health.Report{Name: "folder:/docs", Status: health.StatusHealthy}
+			setPollStatus(&report, tt.poll)
+			assert.Equal(t, tt.want, report.Status)
+			assert.Equal(t, tt.poll.LastError, report.LastError)
+			if tt.poll.LastSuccess.IsZero() {
+				assert.Nil(t, report.LastSuccess)
+			} else {
+				require.NotNil(t, report.LastSuccess)
+				assert.True(t, tt.poll.LastSuccess.Equal(*report.LastSuccess))
+			}
+		})
+	}
+}
@@ -0,0 +1,328 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/actions"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/agent"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/agents"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/core"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/events"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/freshness"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/i18n"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/interfaces"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/limits"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/notify"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/reporting"
+)
+
+// folderAccount is a Dropbox account the monitored folders are checked in
+type folderAccount struct {
+	// id is the configured account's ID, empty for the top-level account
+	id     string
+	client interfaces.DropboxClient
+}
+
+// newFolderAccounts returns the accounts the monitored folders are checked
+// in: every configured account, through a client using its own token, or
+// the top-level account through client when none are configured
+func newFolderAccounts(cfg *config.Config, client interfaces.DropboxClient, stateManager *core.StateManager, guard *limits.Guard) ([]folderAccount, error) {
+	if len(cfg.Accounts) == 0 {
+		return []folderAccount{{client: client}}, nil
+	}
+
+	accounts := make([]folderAccount, 0, len(cfg.Accounts))
+	for _, account := range cfg.Accounts {
+		accountCfg := *cfg
+		accountCfg.DropboxToken = account.DropboxToken
+		accountCfg.DropboxRefreshToken = ""
+		accountCfg.Team = config.TeamConfig{}
+		accountClient, err := newDropboxClient(&accountCfg, stateManager, guard)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dropbox client for account %q: %w", account.ID, err)
+		}
+		accounts = append(accounts, folderAccount{id: account.ID, client: accountClient})
+	}
+	return accounts, nil
+}
+
+// newFileChangeAgents creates a file change agent for each monitored folder
+// in each account whose changes are published to bus; the result always
+// holds at least one folder. Once published, the changes are emailed to the folder's own
+// recipients, and recorded in tracker as reported when that succeeds.
+// Folder reports carry the account header from account and action links
+// from links when set, their deliveries are recorded in bounces when set,
+// and they are kept in archive when set. Under the summary first run policy, notifier receives a description
+// of each folder's existing files. Renames are recognised from the content
+// hashes in hashes, and the metadata of the synced files is recorded in
+// files when set.
+func newFileChangeAgents(cfg *config.Config, accounts []folderAccount, stateManager interfaces.StateManager, guard *limits.Guard, bus *events.Bus, tracker *freshness.Tracker, account reporting.AccountSource, links *actions.Service, bounces *notify.Bounces, archive reporting.ReportArchive, notifier notify.Notifier, hashes core.HashLookup, files core.FileStore, logger *slog.Logger) ([]*monitoredFolder, error) {
+	folders := cfg.Monitoring.GetFolders()
+	monitored := make([]*monitoredFolder, 0, len(folders)*len(accounts))
+
+	for _, folder := range folders {
+		for _, dropboxAccount := range accounts {
+			mf, err := newMonitoredFolder(cfg, folder, dropboxAccount, stateManager, guard, bus, tracker, account, links, bounces, archive, notifier, hashes, files, logger)
+			if err != nil {
+				return nil, err
+			}
+			monitored = append(monitored, mf)
+		}
+	}
+
+	return monitored, nil
+}
+
+// newMonitoredFolder creates the file change agent of folder in
+// dropboxAccount, as described by newFileChangeAgents
+func newMonitoredFolder(cfg *config.Config, folder config.MonitoredFolderConfig, dropboxAccount folderAccount, stateManager interfaces.StateManager, guard *limits.Guard, bus *events.Bus, tracker *freshness.Tracker, account reporting.AccountSource, links *actions.Service, bounces *notify.Bounces, archive reporting.ReportArchive, notifier notify.Notifier, hashes core.HashLookup, files core.FileStore, logger *slog.Logger) (*monitoredFolder, error) {
+	name := folder.Path
+	if name == "" {
+		name = "/"
+	}
+	folderLogger := logging.Component(logger, "folder").With("folder", name)
+	scope := cfg.Team.ToTeamConfig().Scope()
+	if dropboxAccount.id != "" {
+		// Configured accounts are read with their own tokens, outside the
+		// team member and namespace of the top-level account
+		folderLogger = folderLogger.With("account", dropboxAccount.id)
+		scope = ""
+	}
+
+	mf := &monitoredFolder{
+		path:    folder.Path,
+		account: dropboxAccount.id,
+		newNotifier: func(cfg *config.Config, folder config.MonitoredFolderConfig) (core.ChangeHandler, error) {
+			return newFolderNotifier(cfg, folder, guard, account, links, bounces, archive, folderLogger)
+		},
+	}
+	if err := mf.setRecipients(cfg, folder); err != nil {
+		return nil, err
+	}
+
+	include, exclude := cfg.Monitoring.FolderFilters(folder)
+	opts := core.FolderOptions{
+		Path:         folder.Path,
+		PollInterval: folderPollInterval(cfg, folder),
+		Adaptive:     cfg.Monitoring.Adaptive.ToAdaptivePolling(),
+		Recursive:    folder.IsRecursive(),
+		Include:      include,
+		Exclude:      exclude,
+		Events:       bus,
+		OnChanges: func(ctx context.Context, changes []models.FileChange) error {
+			handler := mf.notifier()
+			if handler == nil {
+				return nil
+			}
+			if err := handler(ctx, changes); err != nil {
+				return err
+			}
+			tracker.Reported(changes)
+			return nil
+		},
+		FirstRun:   cfg.Monitoring.GetFirstRun(),
+		OnFirstRun: firstRunSummary(folder.Path, notifier),
+		Hashes:     hashes,
+		Files:      files,
+		Logger:     folderLogger,
+		Scope:      scope,
+		AccountID:  dropboxAccount.id,
+	}
+	mf.agent = agents.NewFileChangeAgentWithOptions(dropboxAccount.client, stateManager, opts)
+	return mf, nil
+}
+
+// filterSetter is implemented by file change agents whose filters can be
+// replaced while they run
+type filterSetter interface {
+	SetFilters(include, exclude []string)
+}
+
+// monitoredFolder is the file change agent of one monitored folder along
+// with the settings that can be reloaded while it runs
+type monitoredFolder struct {
+	path string
+	// account is the configured account the folder is read in, if any
+	account string
+	agent   agent.FileChangeAgent
+	// newNotifier creates the handler that emails the folder's own recipients
+	newNotifier func(cfg *config.Config, folder config.MonitoredFolderConfig) (core.ChangeHandler, error)
+	mu          sync.RWMutex
+	// handler is nil while the folder has no recipients of its own
+	handler core.ChangeHandler
+}
+
+// name returns the folder's path, with its account when it is read in a
+// configured one
+func (f *monitoredFolder) name() string {
+	name := f.path
+	if name == "" {
+		name = "/"
+	}
+	if f.account != "" {
+		name += " in account " + f.account
+	}
+	return name
+}
+
+// notifier returns the handler that emails the folder's recipients, or nil
+// when it has none
+func (f *monitoredFolder) notifier() core.ChangeHandler {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.handler
+}
+
+// setRecipients replaces the handler that emails the folder's recipients
+func (f *monitoredFolder) setRecipients(cfg *config.Config, folder config.MonitoredFolderConfig) error {
+	var handler core.ChangeHandler
+	if len(folder.Recipients) > 0 {
+		var err error
+		if handler, err = f.newNotifier(cfg, folder); err != nil {
+			return err
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handler = handler
+	return nil
+}
+
+// reload applies the poll interval, filters and recipients of folder, as
+// configured in cfg, to the running agent
+func (f *monitoredFolder) reload(cfg *config.Config, folder config.MonitoredFolderConfig) error {
+	f.agent.SetPollInterval(folderPollInterval(cfg, folder))
+	if filtered, ok := f.agent.(filterSetter); ok {
+		filtered.SetFilters(cfg.Monitoring.FolderFilters(folder))
+	}
+	return f.setRecipients(cfg, folder)
+}
+
+// reloadFolders applies cfg to the monitored folders. Folders are matched by
+// path; adding or removing folders, or changing whether they are recursive,
+// takes a restart.
+func reloadFolders(cfg *config.Config, folders []*monitoredFolder, logger *slog.Logger) error {
+	if len(folders) == 0 {
+		return nil
+	}
+	configured := make(map[string]config.MonitoredFolderConfig)
+	for _, folder := range cfg.Monitoring.GetFolders() {
+		configured[folder.Path] = folder
+	}
+
+	// A folder has an agent in every account, so several share a path
+	var errs []error
+	reloaded := make(map[string]bool)
+	for _, mf := range folders {
+		folder, ok := configured[mf.path]
+		if !ok {
+			logger.Warn("Monitored folder removed from the configuration; restart to stop monitoring it", "folder", mf.path)
+			continue
+		}
+		reloaded[mf.path] = true
+		if err := mf.reload(cfg, folder); err != nil {
+			errs = append(errs, fmt.Errorf("failed to reload folder %q: %w", mf.path, err))
+		}
+	}
+	for path := range configured {
+		if !reloaded[path] {
+			logger.Warn("Monitored folder added to the configuration; restart to start monitoring it", "folder", path)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// folderPollInterval returns how often folder is checked, falling back to
+// the global poll interval
+func folderPollInterval(cfg *config.Config, folder config.MonitoredFolderConfig) time.Duration {
+	if folder.PollInterval > 0 {
+		return folder.PollInterval
+	}
+	return cfg.PollInterval
+}
+
+// firstRunSummary returns a handler that sends one notification describing
+// the files found on a folder's first check
+func firstRunSummary(folder string, notifier notify.Notifier) core.ChangeHandler {
+	if folder == "" {
+		folder = "/"
+	}
+	return func(ctx context.Context, changes []models.FileChange) error {
+		var total int64
+		for _, change := range changes {
+			total += change.Size
+		}
+		message := fmt.Sprintf("Started monitoring %s, which holds %d files totalling %s. Changes made from now on will be reported.",
+			folder, len(changes), config.ByteSize(total))
+		return notifier.SendNotification(ctx, message)
+	}
+}
+
+// newFolderNotifier returns a handler that emails a file list report of a
+// folder's changes to the folder's own recipients. With links set, reports
+// end with acknowledge and mute links, muted folders are not reported and
+// changes made before the folder's last acknowledgement are left out.
+// Sent reports are kept in archive when set.
+func newFolderNotifier(cfg *config.Config, folder config.MonitoredFolderConfig, guard *limits.Guard, account reporting.AccountSource, links *actions.Service, bounces *notify.Bounces, archive reporting.ReportArchive, logger *slog.Logger) (core.ChangeHandler, error) {
+	if cfg.EmailConfig == nil {
+		return nil, fmt.Errorf("recipients for folder %q require email configuration", folder.Path)
+	}
+
+	emailConfig := *cfg.EmailConfig
+	emailConfig.ToAddresses = folder.Recipients
+
+	reporter, err := reporting.NewReporterWithConfig(notify.NewEmailNotifierWithBounces(&emailConfig, bounces),
+		reporting.ReporterConfig{
+			Limits:     guard,
+			Account:    account,
+			Language:   cfg.Report.Language,
+			Archive:    archive,
+			Recipients: folder.Recipients,
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reporter for folder %q: %w", folder.Path, err)
+	}
+
+	title := i18n.Lookup(cfg.Report.Language).T("report.folder_title", folder.Path)
+	return func(ctx context.Context, changes []models.FileChange) error {
+		if links != nil && links.Muted(folder.Path) {
+			logger.Info("Skipping report for muted folder")
+			return nil
+		}
+		if links != nil {
+			if changes = links.Unacknowledged(folder.Path, changes); len(changes) == 0 {
+				logger.Info("Skipping report for acknowledged folder")
+				return nil
+			}
+		}
+
+		report, err := reporter.GenerateReport(ctx, changes, models.FileListReport)
+		if err != nil {
+			return fmt.Errorf("failed to generate report for folder %q: %w", folder.Path, err)
+		}
+		report.Title = title
+		if links != nil {
+			report.Metadata["content"] += actionFooter(links.Links(folder.Path))
+		}
+		return reporter.SendReport(ctx, report)
+	}, nil
+}
+
+// actionFooter lists the action links at the end of a plain text report
+func actionFooter(links []actions.Link) string {
+	var b strings.Builder
+	b.WriteString("\n\nActions:\n")
+	for _, link := range links {
+		fmt.Fprintf(&b, "- %s: %s\n", link.Label, link.URL)
+	}
+	return b.String()
+}
@@ -0,0 +1,111 @@
+package container
+
+import (
+	"context"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/core"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/health"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
+)
+
+// pollStatuser is implemented by file change agents that record their checks
+type pollStatuser interface {
+	PollStatus() core.PollStatus
+}
+
+// metricsSnapshotter is implemented by Dropbox clients that record their
+// latest error
+type metricsSnapshotter interface {
+	MetricsSnapshot() dropbox.MetricsSnapshot
+}
+
+// ComponentHealth reports the health of the container, the scheduler, the
+// agents, each monitored folder, the database and the Dropbox connection.
+// It makes no Dropbox requests: folders are judged by their latest check and
+// Dropbox by the client's circuit breaker, so it is cheap enough for load
+// balancer probes.
+func (c *Container) ComponentHealth(ctx context.Context) []health.Report {
+	reports := []health.Report{
+		health.ComponentReport("container", c, nil),
+		health.ComponentReport("scheduler", c.scheduler, c.scheduler.Health(ctx)),
+		health.ComponentReport("agents", c.agentManager, nil),
+	}
+
+	var lastPoll time.Time
+	for _, fca := range c.folderAgents() {
+		report := health.ComponentReport("folder", fca, nil)
+		if poller, ok := fca.(pollStatuser); ok {
+			poll := poller.PollStatus()
+			report.Name = "folder:" + poll.Folder
+			if poll.Account != "" {
+				report.Name += "#" + poll.Account
+			}
+			setPollStatus(&report, poll)
+			if poll.LastSuccess.After(lastPoll) {
+				lastPoll = poll.LastSuccess
+			}
+		}
+		reports = append(reports, report)
+	}
+
+	if c.database != nil {
+		report := health.Report{Name: "database", Status: health.StatusHealthy}
+		if err := c.database.DB.PingContext(ctx); err != nil {
+			report.Status, report.LastError = health.StatusUnhealthy, err.Error()
+		}
+		reports = append(reports, report)
+	}
+
+	return append(reports, c.dropboxReport(lastPoll))
+}
+
+// folderAgents returns the file change agent of every monitored folder
+func (c *Container) folderAgents() []lifecycle.Component {
+	var components []lifecycle.Component
+	if len(c.fileChangeAgents) == 0 && c.fileChangeAgent != nil {
+		return append(components, c.fileChangeAgent)
+	}
+	for _, fca := range c.fileChangeAgents {
+		components = append(components, fca)
+	}
+	return components
+}
+
+// dropboxReport describes the Dropbox connection: it is unhealthy while the
+// circuit breaker is open, and lastPoll is the latest successful folder check
+func (c *Container) dropboxReport(lastPoll time.Time) health.Report {
+	report := health.Report{Name: "dropbox", Status: health.StatusHealthy, State: c.CircuitState()}
+	if !lastPoll.IsZero() {
+		report.LastSuccess = &lastPoll
+	}
+	if metered, ok := c.dropboxClient.(metricsSnapshotter); ok {
+		if snapshot := metered.MetricsSnapshot(); snapshot.LastError != "" {
+			report.LastError = snapshot.LastError
+			report.LastErrorAt = &snapshot.LastErrorTime
+		}
+	}
+	if report.State == "open" {
+		report.Status = health.StatusUnhealthy
+		if report.LastError == "" {
+			report.LastError = "circuit breaker is open"
+		}
+	}
+	return report
+}
+
+// setPollStatus adds a folder's latest checks to its report. A folder whose
+// latest check failed is unhealthy.
+func setPollStatus(report *health.Report, poll core.PollStatus) {
+	if !poll.LastSuccess.IsZero() {
+		report.LastSuccess = &poll.LastSuccess
+	}
+	if poll.LastError == "" {
+		return
+	}
+	report.LastError, report.LastErrorAt = poll.LastError, &poll.LastErrorAt
+	if poll.LastErrorAt.After(poll.LastSuccess) && report.Status == health.StatusHealthy {
+		report.Status = health.StatusUnhealthy
+	}
+}
@@ -0,0 +1,74 @@
+package container
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/core"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/events"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// classify classifies and enriches published changes, recording them as
+// detected; it is subscribed ahead of every other consumer so they all see
+// the classified changes
+func (c *Container) classify(ctx context.Context, event events.FileChanged) error {
+	c.rules.Apply(ctx, event.Changes)
+	c.enrichers.Apply(ctx, event.Changes)
+	c.freshness.Detected(event.Changes)
+	return nil
+}
+
+// subscribe registers the consumers of published changes in the order they
+// handle them: classification, anomaly detection, severity alerts, the
+// change history, the search index and the scheduled report. Alerts and the
+// search index are optional: their failures do not hold back the folder
+// cursors, which move on once the changes are stored and queued for the
+// report.
+func (c *Container) subscribe() {
+	c.events.Subscribe("classification", c.classify)
+	if c.anomalies != nil {
+		c.events.SubscribeOptional("anomaly", c.anomalies.Handle)
+	}
+	if c.severity != nil {
+		c.events.SubscribeOptional("severity", c.severity.Handle)
+	}
+	if c.database != nil {
+		c.events.Subscribe("database", events.Changes(recordChanges(c.database)))
+	}
+	if c.embeddings != nil {
+		c.events.SubscribeOptional("embeddings", events.Changes(c.embeddings.Update))
+	}
+	if c.scheduler != nil {
+		c.events.Subscribe("reports", c.scheduler.Collect)
+	}
+}
+
+// changeStore saves file changes
+type changeStore interface {
+	SaveFileChanges(ctx context.Context, changes []db.FileChange) error
+}
+
+// recordChanges returns a handler that stores every published change, so the
+// change history covers polled folders as well as ingested changes. Each set
+// of changes is written in batches, and changes stored already are skipped
+// by the store. A renamed file is stored as the deletion of its previous path
+// followed by the file at its new path.
+func recordChanges(store changeStore) core.ChangeHandler {
+	return func(ctx context.Context, changes []models.FileChange) error {
+		stored := make([]db.FileChange, 0, len(changes))
+		for _, change := range changes {
+			if change.IsRenamed() {
+				previous := models.FileChange{Path: change.PreviousPath, Modified: change.Modified, IsDeleted: true, AccountID: change.AccountID}
+				previous.Normalize()
+				stored = append(stored, *db.NewFileChangeFromModel(previous))
+			}
+			stored = append(stored, *db.NewFileChangeFromModel(change))
+		}
+		if err := store.SaveFileChanges(ctx, stored); err != nil {
+			return fmt.Errorf("failed to store %d changes: %w", len(stored), err)
+		}
+		return nil
+	}
+}
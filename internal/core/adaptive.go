@@ -0,0 +1,124 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultMaxPollInterval is the longest adaptive poll interval when
+// AdaptivePolling.MaxInterval is not set
+const DefaultMaxPollInterval = time.Hour
+
+// AdaptivePolling adjusts how often a folder is checked to its activity. A
+// check that finds changes brings the interval down to MinInterval, and each
+// check that finds none doubles it, up to MaxInterval.
+type AdaptivePolling struct {
+	// MinInterval is the interval after a check that found changes; zero
+	// uses the folder's poll interval
+	MinInterval time.Duration
+	// MaxInterval bounds the interval while the folder is idle; zero uses
+	// DefaultMaxPollInterval
+	MaxInterval time.Duration
+	// Longpoll waits on the Dropbox longpoll endpoint between checks, so a
+	// change is checked as soon as it is made rather than at the next poll
+	Longpoll bool
+	// LongpollTimeout is how long each longpoll waits for changes; zero
+	// uses the shortest timeout Dropbox accepts
+	LongpollTimeout time.Duration
+}
+
+// Longpoller is implemented by Dropbox clients that can wait for changes
+// under a cursor
+type Longpoller interface {
+	ListFolderLongpoll(ctx context.Context, cursor string, timeout time.Duration) (bool, time.Duration, error)
+}
+
+// bounds returns the adaptive interval range for a folder polled every
+// pollInterval by default
+func (p AdaptivePolling) bounds(pollInterval time.Duration) (time.Duration, time.Duration) {
+	low := p.MinInterval
+	if low <= 0 {
+		low = pollInterval
+	}
+	high := p.MaxInterval
+	if high <= 0 {
+		high = DefaultMaxPollInterval
+	}
+	return low, max(low, high)
+}
+
+// backoff returns the interval after idle consecutive checks without changes
+func (p AdaptivePolling) backoff(pollInterval time.Duration, idle int) time.Duration {
+	low, high := p.bounds(pollInterval)
+	interval := low
+	for i := 0; i < idle && interval < high; i++ {
+		interval *= 2
+	}
+	return min(interval, high)
+}
+
+// adapt records whether a check found changes, which sets the next
+// adaptive poll interval
+func (a *FileChangeAgentImpl) adapt(changed bool) {
+	if a.options.Adaptive == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if changed {
+		a.idleChecks = 0
+	} else {
+		a.idleChecks++
+	}
+}
+
+// watch waits on the Dropbox longpoll endpoint for changes under the
+// folder's cursor, handing each wake-up to the poll loop and waiting for
+// its check to finish before waiting again. Until the first check stores a
+// cursor, and after a failed longpoll or check, it waits a poll interval.
+func (a *FileChangeAgentImpl) watch(ctx context.Context, poller Longpoller, wake chan<- chan struct{}) {
+	for {
+		var wait time.Duration
+		cursor := a.stateManager.GetString(a.cursorKey)
+		if cursor == "" {
+			wait = a.interval()
+		} else {
+			changed, backoff, err := poller.ListFolderLongpoll(ctx, cursor, a.options.Adaptive.LongpollTimeout)
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err != nil:
+				a.logger.Warn("Waiting for changes failed", "error", err)
+				wait = a.interval()
+			case changed:
+				done := make(chan struct{})
+				select {
+				case wake <- done:
+				case <-ctx.Done():
+					return
+				}
+				select {
+				case <-done:
+				case <-ctx.Done():
+					return
+				}
+				if a.stateManager.GetString(a.cursorKey) == cursor {
+					// The check failed or was skipped, so the longpoll would
+					// return at once
+					wait = a.interval()
+				}
+			}
+			wait = max(wait, backoff)
+		}
+
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptivePolling_Backoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		adaptive AdaptivePolling
+		idle     int
+		want     time.Duration
+	}{
+		{"after changes", AdaptivePolling{MinInterval: time.Minute, MaxInterval: time.Hour}, 0, time.Minute},
+		{"doubles while idle", AdaptivePolling{MinInterval: time.Minute, MaxInterval: time.Hour}, 3, 8 * time.Minute},
+		{"bounded by the maximum", AdaptivePolling{MinInterval: time.Minute, MaxInterval: time.Hour}, 10, time.Hour},
+		{"minimum defaults to the poll interval", AdaptivePolling{MaxInterval: time.Hour}, 1, 10 * time.Minute},
+		{"maximum defaults", AdaptivePolling{MinInterval: time.Minute}, 100, DefaultMaxPollInterval},
+		{"maximum below the minimum", AdaptivePolling{MinInterval: time.Hour, MaxInterval: time.Minute}, 2, time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.adaptive.backoff(5*time.Minute, tt.idle); got != tt.want {
+				t.Errorf("backoff(%d) = %v, want %v", tt.idle, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CheckpointVersion is the version of the checkpoint format written by
+// ExportCheckpoint. Checkpoints with another version are rejected on import.
+const CheckpointVersion = 1
+
+// Checkpoint holds the change stream cursors of an instance so another
+// instance can continue monitoring from the same point
+type Checkpoint struct {
+	Version   int               `json:"version"`
+	CreatedAt time.Time         `json:"created_at"`
+	Cursors   map[string]string `json:"cursors"`
+}
+
+// isCursorKey reports whether a state key holds a change stream cursor: the
+// per-folder cursors and cursors of other feeds such as the team log.
+// Credentials and other state stay with the instance.
+func isCursorKey(key string) bool {
+	return key == "cursor" || strings.HasPrefix(key, "cursor:") || strings.HasPrefix(key, "cursor@") ||
+		strings.HasPrefix(key, "cursor#") || strings.HasSuffix(key, "_cursor")
+}
+
+// Checkpoint returns the current change stream cursors
+func (sm *StateManager) Checkpoint() Checkpoint {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	cp := Checkpoint{
+		Version:   CheckpointVersion,
+		CreatedAt: time.Now().UTC(),
+		Cursors:   make(map[string]string),
+	}
+	for key, val := range sm.state {
+		if str, ok := val.(string); ok && str != "" && isCursorKey(key) {
+			cp.Cursors[key] = str
+		}
+	}
+	return cp
+}
+
+// RestoreCheckpoint replaces the change stream cursors with those in cp and
+// returns the keys restored. Cursors missing from cp are cleared so their
+// folders start from a fresh baseline; other state is left untouched.
+func (sm *StateManager) RestoreCheckpoint(cp Checkpoint) ([]string, error) {
+	if cp.Version != CheckpointVersion {
+		return nil, fmt.Errorf("unsupported checkpoint version %d, expected %d", cp.Version, CheckpointVersion)
+	}
+	for key := range cp.Cursors {
+		if !isCursorKey(key) {
+			return nil, fmt.Errorf("checkpoint key %q is not a cursor", key)
+		}
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for key := range sm.state {
+		if isCursorKey(key) {
+			delete(sm.state, key)
+		}
+	}
+	restored := make([]string, 0, len(cp.Cursors))
+	for key, cursor := range cp.Cursors {
+		sm.state[key] = cursor
+		restored = append(restored, key)
+	}
+	sort.Strings(restored)
+
+	if err := sm.saveState(); err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// ExportCheckpoint writes the current change stream cursors to w as JSON
+func (sm *StateManager) ExportCheckpoint(w io.Writer) error {
+	data, err := json.MarshalIndent(sm.Checkpoint(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// ImportCheckpoint reads a checkpoint written by ExportCheckpoint from r and
+// restores its cursors, returning the keys restored
+func (sm *StateManager) ImportCheckpoint(r io.Reader) ([]string, error) {
+	var cp Checkpoint
+	if err := json.NewDecoder(r).Decode(&cp); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint: %w", err)
+	}
+	return sm.RestoreCheckpoint(cp)
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startedStateManager(t *testing.T) *StateManager {
+	t.Helper()
+	sm := NewStateManager(filepath.Join(t.TempDir(), "state.json"))
+	require.NoError(t, sm.Start(context.Background()))
+	return sm
+}
+
+func TestStateManager_CheckpointRoundTrip(t *testing.T) {
+	source := startedStateManager(t)
+	require.NoError(t, source.SetString("cursor", "root-cursor"))
+	require.NoError(t, source.SetString("cursor:/work", "work-cursor"))
+	require.NoError(t, source.SetString("team_log_cursor", "team-cursor"))
+	require.NoError(t, source.SetString("dropbox_access_token", "secret"))
+
+	var buf bytes.Buffer
+	require.NoError(t, source.ExportCheckpoint(&buf))
+	assert.NotContains(t, buf.String(), "secret")
+
+	target := startedStateManager(t)
+	require.NoError(t, target.SetString("cursor:/old", "stale-cursor"))
+	require.NoError(t, target.SetString("dropbox_access_token", "target-token"))
+
+	restored, err := target.ImportCheckpoint(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cursor", "cursor:/work", "team_log_cursor"}, restored)
+
+	assert.Equal(t, "root-cursor", target.GetString("cursor"))
+	assert.Equal(t, "work-cursor", target.GetString("cursor:/work"))
+	assert.Equal(t, "team-cursor", target.GetString("team_log_cursor"))
+	assert.Empty(t, target.GetString("cursor:/old"))
+	assert.Equal(t, "target-token", target.GetString("dropbox_access_token"))
+
+	// The restored cursors are persisted
+	reloaded := NewStateManager(target.statePath)
+	require.NoError(t, reloaded.Start(context.Background()))
+	assert.Equal(t, "work-cursor", reloaded.GetString("cursor:/work"))
+}
+
+func TestStateManager_ImportCheckpointRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"malformed", `{"version":`},
+		{"unknown version", `{"version": 99, "cursors": {"cursor": "c"}}`},
+		{"non-cursor key", `{"version": 1, "cursors": {"dropbox_access_token": "t"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := startedStateManager(t)
+			require.NoError(t, sm.SetString("cursor", "kept"))
+
+			_, err := sm.ImportCheckpoint(strings.NewReader(tt.input))
+			assert.Error(t, err)
+			assert.Equal(t, "kept", sm.GetString("cursor"))
+		})
+	}
+}
@@ -2,17 +2,28 @@ package core
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/agent"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/events"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/interfaces"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/metrics"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/safego"
 )
 
+// changesPerPoll records how many changes each successful check reported
+var changesPerPoll = metrics.Default.Histogram("dropbox_changes_per_poll",
+	"Changes reported per folder check, after filtering.", []float64{0, 1, 5, 10, 50, 100, 500, 1000})
+
 // FileChangeAgentImpl monitors Dropbox for file changes
 type FileChangeAgentImpl struct {
 	*lifecycle.BaseComponent
@@ -20,19 +31,69 @@ type FileChangeAgentImpl struct {
 	stateManager  interfaces.StateManager
 	pollInterval  time.Duration
 	monitorPath   string
-	stopCh        chan struct{}
+	options       FolderOptions
+	cursorKey     string
+	syncKey       string
+	// runMu guards the channels of the current run: stop ends the poll
+	// loop, which closes done when it exits
+	runMu sync.Mutex
+	stop  func()
+	done  chan struct{}
+	// reloadCh wakes the poll loop when the poll interval changes
+	reloadCh chan struct{}
 	mu           sync.RWMutex
+	checkMu       sync.Mutex
+	// poll describes the latest checks, guarded by mu
+	poll   PollStatus
+	// idleChecks counts the checks in a row without changes, guarded by mu
+	idleChecks int
+	logger *slog.Logger
+}
+
+// PollStatus describes the latest checks of a monitored folder
+type PollStatus struct {
+	Folder string
+	// Account is the configured account the folder is read in, if any
+	Account string
+	// LastSuccess is when a check last completed without error
+	LastSuccess time.Time
+	// LastError is the error of the latest failed check and LastErrorAt when
+	// it failed; a later LastSuccess means the folder has recovered
+	LastError   string
+	LastErrorAt time.Time
 }
 
-// NewFileChangeAgent creates a new file change agent
+// NewFileChangeAgent creates a new file change agent that reports every change under monitorPath
 func NewFileChangeAgent(client interfaces.DropboxClient, stateManager interfaces.StateManager, monitorPath string) agent.FileChangeAgent {
+	return NewFileChangeAgentWithOptions(client, stateManager, FolderOptions{Path: monitorPath, Recursive: true})
+}
+
+// NewFileChangeAgentWithOptions creates a new file change agent for one monitored folder
+func NewFileChangeAgentWithOptions(client interfaces.DropboxClient, stateManager interfaces.StateManager, opts FolderOptions) agent.FileChangeAgent {
+	pollInterval := opts.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = 5 * time.Minute // Default poll interval
+	}
+
 	agent := &FileChangeAgentImpl{
 		BaseComponent: lifecycle.NewBaseComponent("FileChangeAgent"),
 		dropboxClient: client,
 		stateManager:  stateManager,
-		pollInterval:  5 * time.Minute, // Default poll interval
-		monitorPath:   monitorPath,
-		stopCh:        make(chan struct{}),
+		pollInterval:  pollInterval,
+		monitorPath:   opts.Path,
+		options:       opts,
+		cursorKey:     opts.cursorKey(),
+		syncKey:       opts.syncKey(),
+		reloadCh:      make(chan struct{}, 1),
+	}
+	agent.poll.Folder = opts.Path
+	if agent.poll.Folder == "" {
+		agent.poll.Folder = "/"
+	}
+	agent.poll.Account = opts.AccountID
+	agent.logger = opts.Logger
+	if agent.logger == nil {
+		agent.logger = logging.Component(nil, "folder").With("folder", agent.poll.Folder)
 	}
 	agent.SetState(lifecycle.StateInitialized)
 	return agent
@@ -44,29 +105,62 @@ func (a *FileChangeAgentImpl) Start(ctx context.Context) error {
 		return err
 	}
 
-	log.Printf("🔍 Starting FileChangeAgent...")
+	a.logger.Info("Starting file change agent")
 
 	// Start monitoring in a goroutine
-	go a.monitorChanges(ctx)
+	stopCh, done := make(chan struct{}), make(chan struct{})
+	a.runMu.Lock()
+	a.stop, a.done = sync.OnceFunc(func() { close(stopCh) }), done
+	a.runMu.Unlock()
+	safego.Go(a.logger, "file change agent", func() {
+		defer close(done)
+		a.monitorChanges(ctx, stopCh)
+	})
 
 	return nil
 }
 
-// Stop stops the file change monitoring
+// Restart stops the agent if it is still running and starts it again, so
+// monitoring resumes after its poll loop exited
+func (a *FileChangeAgentImpl) Restart(ctx context.Context) error {
+	if a.State() == lifecycle.StateRunning {
+		if err := a.Stop(ctx); err != nil {
+			return err
+		}
+	}
+	a.stopLoop()
+	a.SetState(lifecycle.StateInitialized)
+	return a.Start(ctx)
+}
+
+// stopLoop ends the poll loop of the current run, if any
+func (a *FileChangeAgentImpl) stopLoop() {
+	a.runMu.Lock()
+	stop := a.stop
+	a.runMu.Unlock()
+	if stop != nil {
+		stop()
+	}
+}
+
+// Stop stops the file change monitoring; stopping a stopped agent has no effect
 func (a *FileChangeAgentImpl) Stop(ctx context.Context) error {
+	if a.State() == lifecycle.StateStopped {
+		return nil
+	}
 	if err := a.DefaultStop(ctx); err != nil {
 		return err
 	}
 
-	log.Printf("🛑 Stopping FileChangeAgent...")
-	close(a.stopCh)
+	a.logger.Info("Stopping file change agent")
+	a.stopLoop()
 
 	return nil
 }
 
 // Health checks the health of the file change agent
 func (a *FileChangeAgentImpl) Health(ctx context.Context) error {
-	if err := a.DefaultHealth(ctx); err != nil {
+	if err := a.Live(ctx); err != nil {
 		return err
 	}
 
@@ -79,28 +173,330 @@ func (a *FileChangeAgentImpl) Health(ctx context.Context) error {
 	return nil
 }
 
-// GetChanges returns a list of file changes
+// Live reports whether the agent is running and its poll loop has not
+// exited, without making Dropbox requests
+func (a *FileChangeAgentImpl) Live(ctx context.Context) error {
+	if err := a.DefaultHealth(ctx); err != nil {
+		return err
+	}
+
+	a.runMu.Lock()
+	done := a.done
+	a.runMu.Unlock()
+	select {
+	case <-done:
+		return fmt.Errorf("poll loop has exited")
+	default:
+		return nil
+	}
+}
+
+// FolderLister is implemented by Dropbox clients that can list every file
+// under a folder along with a cursor for later changes
+type FolderLister interface {
+	ListFolderRecursive(ctx context.Context, path string) ([]*models.FileMetadata, string, error)
+}
+
+// ResumableLister is implemented by Dropbox clients that list every file
+// under a folder a page at a time, resuming from the progress of an
+// interrupted listing
+type ResumableLister interface {
+	ListFolderResumable(ctx context.Context, path string, progress dropbox.SyncProgress, onPage dropbox.PageHandler) (string, error)
+}
+
+// syncCheckpointInterval is how often the progress of an initial sync is
+// saved; pages handled since the last save are listed again on resume
+var syncCheckpointInterval = 5 * time.Second
+
+// GetChanges returns the files added, modified or deleted since the last
+// call. What the first call returns depends on the folder's FirstRun policy:
+// by default it records a baseline cursor and returns no changes.
 func (a *FileChangeAgentImpl) GetChanges(ctx context.Context) ([]models.FileChange, error) {
-	// Get the current cursor from state
-	cursor := a.stateManager.GetString("cursor")
+	changes, commit, err := a.changes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := commit(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// changes returns the changes since the last check along with the function
+// that moves the cursor past them, so a check can keep the cursor until the
+// changes are handled
+func (a *FileChangeAgentImpl) changes(ctx context.Context) ([]models.FileChange, func() error, error) {
+	cursor := a.stateManager.GetString(a.cursorKey)
+	if cursor == "" {
+		return a.firstRun(ctx)
+	}
 
-	// List files from Dropbox
-	files, err := a.dropboxClient.ListFolder(ctx, "")
+	changes, next, err := a.listChanges(ctx, cursor)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list folder: %w", err)
+		return nil, nil, err
 	}
+	changesPerPoll.Observe(float64(len(changes)))
+	return changes, func() error {
+		if next == cursor {
+			return nil
+		}
+		return a.saveCursor(next)
+	}, nil
+}
 
-	// Compare with previous state
-	changes := a.detectChanges(files, cursor)
+// listChanges lists the changes since cursor and returns them along with
+// the cursor after them
+func (a *FileChangeAgentImpl) listChanges(ctx context.Context, cursor string) ([]models.FileChange, string, error) {
+	files, next, err := a.dropboxClient.ListFolderContinue(ctx, cursor)
+	if err != nil {
+		var dbErr *dropbox.Error
+		if errors.As(err, &dbErr) && dbErr.Type == dropbox.ErrorTypeConflict {
+			// The cursor was reset by Dropbox; start over from a fresh baseline
+			if clearErr := a.stateManager.SetString(a.cursorKey, ""); clearErr != nil {
+				return nil, "", fmt.Errorf("failed to clear cursor: %w", clearErr)
+			}
+			return nil, "", fmt.Errorf("cursor reset, re-baselining on next check: %w", err)
+		}
+		return nil, "", fmt.Errorf("failed to list changes: %w", err)
+	}
+	a.stamp(files)
 
-	// Update state with new cursor
-	if len(changes) > 0 {
-		if err := a.stateManager.SetString("cursor", cursor); err != nil {
-			return nil, fmt.Errorf("failed to update cursor: %w", err)
+	// Files not stored are listed again on the next check as the cursor is
+	// left unchanged
+	if err := a.storeFiles(ctx, files); err != nil {
+		return nil, "", err
+	}
+
+	changes := a.filters().Filter(models.BatchConvertMetadataToChanges(files))
+	changes = DetectRenames(ctx, changes, a.options.Hashes)
+	return changes, next, nil
+}
+
+// PeekChanges returns the changes the next check would report without
+// moving the cursor past them or publishing them. A folder without a cursor
+// has no changes to peek at, as its first run policy is left to the first
+// check.
+func (a *FileChangeAgentImpl) PeekChanges(ctx context.Context) ([]models.FileChange, error) {
+	a.checkMu.Lock()
+	defer a.checkMu.Unlock()
+
+	cursor := a.stateManager.GetString(a.cursorKey)
+	if cursor == "" {
+		return nil, nil
+	}
+	changes, _, err := a.listChanges(ctx, cursor)
+	return changes, err
+}
+
+// committed is the commit of changes whose cursor is saved already
+func committed() error { return nil }
+
+// firstRun applies the FirstRun policy to a folder without a cursor. The
+// summary and full policies, and any policy when the files are stored, list
+// the existing files, falling back to a baseline when the client cannot list
+// them. The existing files reported by the full policy come with the
+// function that saves the cursor.
+func (a *FileChangeAgentImpl) firstRun(ctx context.Context) ([]models.FileChange, func() error, error) {
+	policy := a.options.FirstRun
+	report := policy == FirstRunSummary || policy == FirstRunFull
+	if !report && a.options.Files == nil {
+		return nil, committed, a.baseline(ctx)
+	}
+
+	var (
+		files  []*models.FileMetadata
+		cursor string
+		err    error
+	)
+	resumable, synced := a.dropboxClient.(ResumableLister)
+	if synced {
+		files, cursor, err = a.sync(ctx, resumable, report)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		lister, ok := a.dropboxClient.(FolderLister)
+		if !ok {
+			a.logger.Warn("Dropbox client cannot list the folder, recording a baseline instead")
+			return nil, committed, a.baseline(ctx)
+		}
+		files, cursor, err = lister.ListFolderRecursive(ctx, a.rootPath())
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list existing files: %w", err)
+		}
+		a.stamp(files)
+		if err := a.storeFiles(ctx, files); err != nil {
+			return nil, nil, err
 		}
 	}
+	if !report {
+		return nil, committed, a.finishSync(cursor, synced)
+	}
+	existing := a.filters().Filter(models.BatchConvertMetadataToChanges(files))
 
-	return changes, nil
+	// A failed summary leaves the cursor unset, and the sync to start over,
+	// so the next check lists the files again and retries it
+	if policy == FirstRunSummary {
+		if len(existing) > 0 && a.options.OnFirstRun != nil {
+			if err := a.options.OnFirstRun(ctx, existing); err != nil {
+				if synced {
+					if clearErr := a.stateManager.SetString(a.syncKey, ""); clearErr != nil {
+						a.logger.Error("Failed to clear sync progress", "error", clearErr)
+					}
+				}
+				return nil, nil, fmt.Errorf("failed to report existing files: %w", err)
+			}
+		}
+		if err := a.finishSync(cursor, synced); err != nil {
+			return nil, nil, err
+		}
+		changesPerPoll.Observe(0)
+		return nil, committed, nil
+	}
+
+	// The listing is complete, so its progress is forgotten: until the
+	// cursor is saved, the next check lists every file again
+	if synced {
+		if err := a.stateManager.SetString(a.syncKey, ""); err != nil {
+			return nil, nil, fmt.Errorf("failed to clear sync progress: %w", err)
+		}
+	}
+	changesPerPoll.Observe(float64(len(existing)))
+	return existing, func() error { return a.saveCursor(cursor) }, nil
+}
+
+// sync lists the existing files through a resumable listing, storing each
+// page and saving the progress as it goes, so a sync interrupted by a crash
+// or restart resumes where it stopped. The files are returned only when keep
+// is set; after a resume they are the files listed since.
+func (a *FileChangeAgentImpl) sync(ctx context.Context, lister ResumableLister, keep bool) ([]*models.FileMetadata, string, error) {
+	progress, err := a.syncProgress()
+	if err != nil {
+		a.logger.Warn("Discarding unreadable sync progress", "error", err)
+	}
+	if progress.Pages > 0 {
+		a.logger.Info("Resuming interrupted sync", "pages", progress.Pages, "files", progress.Files,
+			"started_at", progress.StartedAt.Format(time.RFC3339))
+	}
+
+	var (
+		files []*models.FileMetadata
+		saved time.Time
+	)
+	cursor, err := lister.ListFolderResumable(ctx, a.rootPath(), progress, func(page []*models.FileMetadata, progress dropbox.SyncProgress) error {
+		a.stamp(page)
+		if err := a.storeFiles(ctx, page); err != nil {
+			return err
+		}
+		if keep {
+			files = append(files, page...)
+		}
+		if time.Since(saved) < syncCheckpointInterval {
+			return nil
+		}
+		saved = time.Now()
+		return a.saveSyncProgress(progress)
+	})
+	if err != nil {
+		var dbErr *dropbox.Error
+		if errors.As(err, &dbErr) && dbErr.Type == dropbox.ErrorTypeConflict {
+			// The saved listing can no longer be continued; start over
+			if clearErr := a.stateManager.SetString(a.syncKey, ""); clearErr != nil {
+				return nil, "", fmt.Errorf("failed to clear sync progress: %w", clearErr)
+			}
+		}
+		return nil, "", fmt.Errorf("failed to list existing files: %w", err)
+	}
+	return files, cursor, nil
+}
+
+// syncProgress returns the saved progress of an interrupted sync, or the
+// zero progress to start a new one
+func (a *FileChangeAgentImpl) syncProgress() (dropbox.SyncProgress, error) {
+	var progress dropbox.SyncProgress
+	saved := a.stateManager.GetString(a.syncKey)
+	if saved == "" {
+		return progress, nil
+	}
+	if err := json.Unmarshal([]byte(saved), &progress); err != nil {
+		return dropbox.SyncProgress{}, err
+	}
+	return progress, nil
+}
+
+// saveSyncProgress saves the progress of the sync
+func (a *FileChangeAgentImpl) saveSyncProgress(progress dropbox.SyncProgress) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return fmt.Errorf("failed to encode sync progress: %w", err)
+	}
+	if err := a.stateManager.SetString(a.syncKey, string(data)); err != nil {
+		return fmt.Errorf("failed to save sync progress: %w", err)
+	}
+	return nil
+}
+
+// finishSync stores the cursor a sync ended with and, after a resumable
+// sync, forgets its progress
+func (a *FileChangeAgentImpl) finishSync(cursor string, synced bool) error {
+	if err := a.saveCursor(cursor); err != nil {
+		return err
+	}
+	if synced {
+		if err := a.stateManager.SetString(a.syncKey, ""); err != nil {
+			return fmt.Errorf("failed to clear sync progress: %w", err)
+		}
+	}
+	return nil
+}
+
+// stamp records the folder's account on listed files, and so on the changes
+// made from them
+func (a *FileChangeAgentImpl) stamp(files []*models.FileMetadata) {
+	if a.options.AccountID == "" {
+		return
+	}
+	for _, file := range files {
+		file.AccountID = a.options.AccountID
+	}
+}
+
+// storeFiles records the metadata of the files seen by a sync when a file
+// store is set
+func (a *FileChangeAgentImpl) storeFiles(ctx context.Context, files []*models.FileMetadata) error {
+	if a.options.Files == nil || len(files) == 0 {
+		return nil
+	}
+	if err := a.options.Files.StoreFiles(ctx, files); err != nil {
+		return fmt.Errorf("failed to store file metadata: %w", err)
+	}
+	return nil
+}
+
+// baseline stores a cursor for the current state of the monitored path
+func (a *FileChangeAgentImpl) baseline(ctx context.Context) error {
+	cursor, err := a.dropboxClient.GetLatestCursor(ctx, a.rootPath())
+	if err != nil {
+		return fmt.Errorf("failed to get latest cursor: %w", err)
+	}
+	return a.saveCursor(cursor)
+}
+
+// saveCursor saves the cursor the next check continues from
+func (a *FileChangeAgentImpl) saveCursor(cursor string) error {
+	if err := a.stateManager.SetString(a.cursorKey, cursor); err != nil {
+		return fmt.Errorf("failed to update cursor: %w", err)
+	}
+	return nil
+}
+
+// rootPath returns the monitored path with the root as "", as the list
+// endpoints expect
+func (a *FileChangeAgentImpl) rootPath() string {
+	if a.monitorPath == "/" {
+		return ""
+	}
+	return a.monitorPath
 }
 
 // GetFileContent returns the content of a file
@@ -108,57 +504,184 @@ func (a *FileChangeAgentImpl) GetFileContent(ctx context.Context, path string) (
 	return a.dropboxClient.GetFileContent(ctx, path)
 }
 
-// SetPollInterval sets the polling interval
+// SetPollInterval sets the polling interval; a running agent polls at the
+// new interval from now on
 func (a *FileChangeAgentImpl) SetPollInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
 	a.mu.Lock()
-	defer a.mu.Unlock()
+	changed := a.pollInterval != interval
 	a.pollInterval = interval
+	a.mu.Unlock()
+
+	if changed {
+		select {
+		case a.reloadCh <- struct{}{}:
+		default: // A reload is already pending and will read the new interval
+		}
+	}
 }
 
-// monitorChanges polls Dropbox for changes
-func (a *FileChangeAgentImpl) monitorChanges(ctx context.Context) {
-	ticker := time.NewTicker(a.pollInterval)
-	defer ticker.Stop()
+// SetFilters replaces the include and exclude globs applied from the next
+// check on
+func (a *FileChangeAgentImpl) SetFilters(include, exclude []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.options.Include = include
+	a.options.Exclude = exclude
+}
+
+// filters returns the folder options with the current filters
+func (a *FileChangeAgentImpl) filters() FolderOptions {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.options
+}
+
+// interval returns the current poll interval, adapted to the folder's
+// activity when adaptive polling is on
+func (a *FileChangeAgentImpl) interval() time.Duration {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.options.Adaptive != nil {
+		return a.options.Adaptive.backoff(a.pollInterval, a.idleChecks)
+	}
+	return a.pollInterval
+}
+
+// TriggerCheck checks for changes immediately instead of waiting for the next poll
+func (a *FileChangeAgentImpl) TriggerCheck(ctx context.Context) error {
+	if a.State() != lifecycle.StateRunning {
+		return fmt.Errorf("file change agent is not running")
+	}
+	return a.checkForChanges(ctx)
+}
+
+// monitorChanges polls Dropbox for changes until ctx is done or stopCh is
+// closed. While Dropbox requests are paused by the API rate limit, polls are
+// suspended and a check is scheduled for when they resume. With longpolling
+// on, changes are also checked as soon as Dropbox reports them.
+func (a *FileChangeAgentImpl) monitorChanges(ctx context.Context, stopCh <-chan struct{}) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	timer := time.NewTimer(a.interval())
+	defer timer.Stop()
+
+	var wake chan chan struct{}
+	if adaptive := a.options.Adaptive; adaptive != nil && adaptive.Longpoll {
+		if poller, ok := a.dropboxClient.(Longpoller); ok {
+			wake = make(chan chan struct{})
+			safego.Go(a.logger, "longpoll", func() { a.watch(ctx, poller, wake) })
+		} else {
+			a.logger.Warn("Dropbox client cannot longpoll, polling only")
+		}
+	}
+
+	var resume <-chan time.Time
+	check := func() {
+		err := a.checkForChanges(ctx)
+		if err == nil {
+			return
+		}
+		var dbErr *dropbox.Error
+		if errors.As(err, &dbErr) && dbErr.Type == dropbox.ErrorTypeQuotaPaused {
+			a.logger.Warn("Scanning paused by the Dropbox rate limit", "resume_at", dbErr.ResumeAt.Format(time.RFC3339))
+			resume = time.After(time.Until(dbErr.ResumeAt))
+			return
+		}
+		a.logger.Error("Checking for changes failed", "error", err)
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-a.stopCh:
+		case <-stopCh:
 			return
-		case <-ticker.C:
-			if err := a.checkForChanges(ctx); err != nil {
-				log.Printf("Error checking for changes: %v", err)
+		case <-a.reloadCh:
+			timer.Reset(a.interval())
+		case <-resume:
+			resume = nil
+			check()
+			timer.Reset(a.interval())
+		case done := <-wake:
+			if resume == nil {
+				check()
+			}
+			close(done)
+			timer.Reset(a.interval())
+		case <-timer.C:
+			if resume == nil {
+				check()
 			}
+			timer.Reset(a.interval())
 		}
 	}
 }
 
-// checkForChanges checks for changes in Dropbox
+// PollStatus returns when the folder was last checked successfully and the
+// latest error
+func (a *FileChangeAgentImpl) PollStatus() PollStatus {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.poll
+}
+
+// checkForChanges checks for changes in Dropbox, recording the outcome in
+// the poll status
 func (a *FileChangeAgentImpl) checkForChanges(ctx context.Context) error {
-	changes, err := a.GetChanges(ctx)
+	// Serialize polls and webhook-triggered checks so the cursor is not raced
+	a.checkMu.Lock()
+	defer a.checkMu.Unlock()
+
+	err := a.check(ctx)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		a.poll.LastError, a.poll.LastErrorAt = err.Error(), time.Now()
+	} else {
+		a.poll.LastSuccess = time.Now()
+	}
+	return err
+}
+
+// check reports the changes since the previous check. The cursor moves past
+// the changes only once they are published, so changes a subscriber failed
+// to record are listed again by the next check.
+func (a *FileChangeAgentImpl) check(ctx context.Context) error {
+	changes, commit, err := a.changes(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get changes: %w", err)
 	}
+	a.adapt(len(changes) > 0)
 
 	if len(changes) > 0 {
-		if err := a.processChanges(ctx, changes); err != nil {
-			return fmt.Errorf("failed to process changes: %w", err)
+		if err := a.publishChanges(ctx, changes); err != nil {
+			return fmt.Errorf("failed to publish changes: %w", err)
 		}
 	}
+	if err := commit(); err != nil {
+		return err
+	}
 
+	if len(changes) > 0 && a.options.OnChanges != nil {
+		if err := a.options.OnChanges(ctx, changes); err != nil {
+			return fmt.Errorf("failed to process changes: %w", err)
+		}
+	}
 	return nil
 }
 
-// detectChanges compares current files with previous state
-func (a *FileChangeAgentImpl) detectChanges(files []*models.FileMetadata, cursor string) []models.FileChange {
-	return models.BatchConvertMetadataToChanges(files)
-}
-
-// processChanges handles detected changes
-func (a *FileChangeAgentImpl) processChanges(ctx context.Context, changes []models.FileChange) error {
+// publishChanges publishes detected changes to the subscribers of the bus
+func (a *FileChangeAgentImpl) publishChanges(ctx context.Context, changes []models.FileChange) error {
 	for _, change := range changes {
-		log.Printf("Processing change: %+v", change)
+		a.logger.Debug("Processing change", "path", change.Path, "deleted", change.IsDeleted, "size", change.Size)
 	}
-	return nil
+	if a.options.Events == nil {
+		return nil
+	}
+	return a.options.Events.Publish(ctx, events.FileChanged{Folder: a.monitorPath, Changes: changes})
 }
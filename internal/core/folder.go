@@ -0,0 +1,166 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/events"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/filter"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// ChangeHandler is called with the changes found by each check
+type ChangeHandler func(ctx context.Context, changes []models.FileChange) error
+
+// FirstRunPolicy decides what the first check of a folder without a cursor
+// reports
+type FirstRunPolicy string
+
+const (
+	// FirstRunBaseline records the current state of the folder silently
+	FirstRunBaseline FirstRunPolicy = "baseline"
+	// FirstRunSummary passes the existing files to OnFirstRun instead of
+	// reporting them as changes
+	FirstRunSummary FirstRunPolicy = "summary"
+	// FirstRunFull reports every existing file as a change
+	FirstRunFull FirstRunPolicy = "full"
+)
+
+// FolderOptions configures which changes a FileChangeAgent reports for its folder
+type FolderOptions struct {
+	// Path is the monitored folder; "" or "/" is the Dropbox root
+	Path string
+	// PollInterval is how often the folder is checked; zero uses the default
+	PollInterval time.Duration
+	// Adaptive, if set, adjusts the poll interval to the folder's activity
+	Adaptive *AdaptivePolling
+	// Recursive includes changes in subfolders
+	Recursive bool
+	// Include limits changes to files matching any of these globs; empty matches all
+	Include []string
+	// Exclude drops changes to files matching any of these globs
+	Exclude []string
+	// Events, if set, receives a FileChanged event for every non-empty set
+	// of changes, published before OnChanges is called; the cursor moves
+	// past the changes only once they are published without error
+	Events *events.Bus
+	// OnChanges, if set, receives every non-empty set of changes
+	OnChanges ChangeHandler
+	// FirstRun decides what the first check reports; empty records a baseline
+	FirstRun FirstRunPolicy
+	// OnFirstRun, if set, receives the existing files on the first check
+	// under FirstRunSummary
+	OnFirstRun ChangeHandler
+	// Hashes, if set, supplies the content hashes of deleted files so
+	// renames are reported as such rather than as a deletion and an addition
+	Hashes HashLookup
+	// Files, if set, records the metadata of every file listed by the
+	// initial sync and every file changed since, whatever the filters and
+	// FirstRun policy
+	Files FileStore
+	// Logger, if set, is the logger of the folder's agent
+	Logger *slog.Logger
+	// Scope, if set, names the team member and namespace the folder is read
+	// in, so switching either starts from fresh cursors rather than resuming
+	// those of another root
+	Scope string
+	// AccountID, if set, names the configured account the folder is read
+	// in. Its files and changes carry the ID, and its cursors are kept apart
+	// from those of the same folder in other accounts.
+	AccountID string
+}
+
+// FileStore records the metadata of the files seen by a sync, removing
+// deleted files
+type FileStore interface {
+	StoreFiles(ctx context.Context, files []*models.FileMetadata) error
+}
+
+// cursorKey returns the state key for the folder's cursor; the root keeps
+// the original "cursor" key when there is no scope
+func (o FolderOptions) cursorKey() string {
+	return o.stateKey("cursor")
+}
+
+// syncKey returns the state key for the progress of the folder's initial
+// sync
+func (o FolderOptions) syncKey() string {
+	return o.stateKey("sync")
+}
+
+// stateKey returns the state key named name for the folder, such as
+// "cursor:/work", "cursor@dbmid:AAH:/work" within a scope or
+// "cursor#home:/work" in the account "home"
+func (o FolderOptions) stateKey(name string) string {
+	if o.AccountID != "" {
+		name += "#" + o.AccountID
+	}
+	if o.Scope != "" {
+		name += "@" + o.Scope
+	}
+	if folder := o.folder(); folder != "" {
+		return name + ":" + folder
+	}
+	return name
+}
+
+// folder returns the lowercased folder path without a trailing slash
+func (o FolderOptions) folder() string {
+	return strings.TrimSuffix(strings.ToLower(o.Path), "/")
+}
+
+// Matches reports whether a change to filePath should be reported. Globs are
+// matched case-insensitively as described by filter.Filter, relative to the
+// folder; exclusions take precedence over inclusions.
+func (o FolderOptions) Matches(filePath string) bool {
+	return o.matches(o.compile(), filePath)
+}
+
+// Filter returns the changes that match the options
+func (o FolderOptions) Filter(changes []models.FileChange) []models.FileChange {
+	compiled := o.compile()
+	if o.Recursive && compiled.IsEmpty() {
+		return changes
+	}
+
+	filtered := make([]models.FileChange, 0, len(changes))
+	for _, change := range changes {
+		if o.matches(compiled, change.Path) {
+			filtered = append(filtered, change)
+		}
+	}
+	return filtered
+}
+
+// matches applies the folder scope and the compiled globs to filePath
+func (o FolderOptions) matches(compiled *filter.Filter, filePath string) bool {
+	lower := strings.ToLower(filePath)
+	folder := o.folder()
+	if folder != "" && !strings.HasPrefix(lower, folder+"/") {
+		return false
+	}
+
+	rel := strings.TrimPrefix(lower, folder+"/")
+	if !o.Recursive && strings.Contains(rel, "/") {
+		return false
+	}
+	return compiled.Match(rel)
+}
+
+// compile builds the glob filter; invalid patterns are rejected when the
+// configuration is validated, so here they only disable filtering
+func (o FolderOptions) compile() *filter.Filter {
+	compiled, err := filter.New(o.Include, o.Exclude)
+	if err != nil {
+		logger := o.Logger
+		if logger == nil {
+			logger = logging.Component(nil, "folder").With("folder", o.Path)
+		}
+		logger.Warn("Ignoring invalid filters", "error", err)
+		return nil
+	}
+	return compiled
+}
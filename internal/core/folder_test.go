@@ -0,0 +1,84 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+func TestFolderOptions_Matches(t *testing.T) {
+	tests := []struct {
+		name string
+		opts FolderOptions
+		path string
+		want bool
+	}{
+		{"root recursive", FolderOptions{Recursive: true}, "/a/b/c.txt", true},
+		{"outside folder", FolderOptions{Path: "/Work", Recursive: true}, "/Home/c.txt", false},
+		{"folder prefix only", FolderOptions{Path: "/Work", Recursive: true}, "/Workshop/c.txt", false},
+		{"case insensitive folder", FolderOptions{Path: "/Work", Recursive: true}, "/work/c.txt", true},
+		{"non-recursive direct child", FolderOptions{Path: "/Work/"}, "/Work/c.txt", true},
+		{"non-recursive subfolder", FolderOptions{Path: "/Work"}, "/Work/sub/c.txt", false},
+		{"include by name", FolderOptions{Path: "/Work", Recursive: true, Include: []string{"*.SQL"}}, "/Work/db/q.sql", true},
+		{"include miss", FolderOptions{Path: "/Work", Recursive: true, Include: []string{"*.sql"}}, "/Work/db/q.txt", false},
+		{"include by relative path", FolderOptions{Path: "/Work", Recursive: true, Include: []string{"db/*"}}, "/Work/db/q.txt", true},
+		{"include double star", FolderOptions{Path: "/Work", Recursive: true, Include: []string{"Reports/**"}}, "/Work/reports/2025/q1.pdf", true},
+		{"exclude wins", FolderOptions{Path: "/Work", Recursive: true, Include: []string{"*.sql"}, Exclude: []string{"tmp_*"}}, "/Work/tmp_q.sql", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.Matches(tt.path); got != tt.want {
+				t.Errorf("Matches(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFolderOptions_Filter(t *testing.T) {
+	changes := []models.FileChange{{Path: "/Work/a.sql"}, {Path: "/Work/b.txt"}}
+
+	all := FolderOptions{Path: "/Work", Recursive: true}.Filter(changes)
+	if len(all) != 2 {
+		t.Errorf("Expected all changes without filters, got %v", all)
+	}
+
+	sql := FolderOptions{Path: "/Work", Recursive: true, Include: []string{"*.sql"}}.Filter(changes)
+	if len(sql) != 1 || sql[0].Path != "/Work/a.sql" {
+		t.Errorf("Expected only the sql change, got %v", sql)
+	}
+}
+
+func TestFolderOptions_CursorKey(t *testing.T) {
+	if key := (FolderOptions{Path: "/"}).cursorKey(); key != "cursor" {
+		t.Errorf("Expected root to use the original cursor key, got %q", key)
+	}
+	if key := (FolderOptions{Path: "/Work/"}).cursorKey(); key != "cursor:/work" {
+		t.Errorf("Unexpected cursor key %q", key)
+	}
+
+	// Each team member and namespace keeps its own cursors
+	scoped := FolderOptions{Path: "/Work/", Scope: "dbmid:AAH/ns:123"}
+	if key := scoped.cursorKey(); key != "cursor@dbmid:AAH/ns:123:/work" {
+		t.Errorf("Unexpected scoped cursor key %q", key)
+	}
+	if key := scoped.syncKey(); key != "sync@dbmid:AAH/ns:123:/work" {
+		t.Errorf("Unexpected scoped sync key %q", key)
+	}
+	scoped.Path = "/"
+	if key := scoped.cursorKey(); key != "cursor@dbmid:AAH/ns:123" {
+		t.Errorf("Unexpected scoped root cursor key %q", key)
+	}
+	if !isCursorKey(scoped.cursorKey()) {
+		t.Errorf("Scoped cursor key %q is not exported in checkpoints", scoped.cursorKey())
+	}
+
+	// Each configured account keeps its own cursors
+	account := FolderOptions{Path: "/Work/", AccountID: "home"}
+	if key := account.cursorKey(); key != "cursor#home:/work" {
+		t.Errorf("Unexpected account cursor key %q", key)
+	}
+	if !isCursorKey(account.cursorKey()) {
+		t.Errorf("Account cursor key %q is not exported in checkpoints", account.cursorKey())
+	}
+}
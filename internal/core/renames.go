@@ -0,0 +1,82 @@
+package core
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// HashLookup returns the last known Dropbox content hash of a file, or ""
+// when none is known. Dropbox reports deletions without a content hash, so
+// renames are recognised from the hashes recorded for earlier changes.
+type HashLookup interface {
+	LastContentHash(ctx context.Context, path string) (string, error)
+}
+
+// DetectRenames pairs each deleted file with a file of the same content
+// added in the same set of changes, replacing the pair with one renamed
+// change. When several added files match, one with the same name is
+// preferred. Changes without a counterpart are returned unchanged.
+func DetectRenames(ctx context.Context, changes []models.FileChange, hashes HashLookup) []models.FileChange {
+	if hashes == nil {
+		return changes
+	}
+
+	added := make(map[string][]int)
+	for i, change := range changes {
+		if !change.IsDeleted && change.ContentHash != "" {
+			added[change.ContentHash] = append(added[change.ContentHash], i)
+		}
+	}
+	if len(added) == 0 {
+		return changes
+	}
+
+	previous := make(map[int]string)
+	renamed := make(map[int]bool)
+	for i, change := range changes {
+		if !change.IsDeleted {
+			continue
+		}
+		hash, err := hashes.LastContentHash(ctx, change.Path)
+		if err != nil {
+			logging.Component(nil, "renames").Warn("Skipping rename detection", "path", change.Path, "error", err)
+			continue
+		}
+		candidates := added[hash]
+		if hash == "" || len(candidates) == 0 {
+			continue
+		}
+
+		match := 0
+		for k, j := range candidates {
+			if strings.EqualFold(path.Base(changes[j].Path), path.Base(change.Path)) {
+				match = k
+				break
+			}
+		}
+		j := candidates[match]
+		added[hash] = append(candidates[:match:match], candidates[match+1:]...)
+		previous[j] = change.Path
+		renamed[i] = true
+	}
+	if len(renamed) == 0 {
+		return changes
+	}
+
+	result := make([]models.FileChange, 0, len(changes)-len(renamed))
+	for i, change := range changes {
+		if renamed[i] {
+			continue
+		}
+		if from, ok := previous[i]; ok {
+			change.ChangeType = models.ChangeRenamed
+			change.PreviousPath = from
+		}
+		result = append(result, change)
+	}
+	return result
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hashMap is a HashLookup backed by a map; the path "/broken" fails
+type hashMap map[string]string
+
+func (h hashMap) LastContentHash(ctx context.Context, path string) (string, error) {
+	if path == "/broken" {
+		return "", errors.New("lookup failed")
+	}
+	return h[path], nil
+}
+
+func TestDetectRenames(t *testing.T) {
+	hashes := hashMap{"/docs/a.txt": "hash-a", "/docs/b.txt": "hash-b", "/docs/c.txt": "hash-c"}
+	changes := []models.FileChange{
+		{Path: "/docs/a.txt", IsDeleted: true},
+		{Path: "/docs/b.txt", IsDeleted: true},
+		{Path: "/docs/c.txt", IsDeleted: true},
+		{Path: "/broken", IsDeleted: true},
+		{Path: "/archive/copy.txt", ContentHash: "hash-a"},
+		{Path: "/archive/a.txt", ContentHash: "hash-a"},
+		{Path: "/docs/renamed.txt", ContentHash: "hash-b"},
+		{Path: "/docs/new.txt", ContentHash: "hash-new"},
+	}
+
+	result := DetectRenames(context.Background(), changes, hashes)
+	require.Len(t, result, 6)
+
+	assert.Equal(t, models.ChangeDeleted, result[0].Type(), "no file has the content of c.txt")
+	assert.Equal(t, "/docs/c.txt", result[0].Path)
+	assert.Equal(t, "/broken", result[1].Path)
+	assert.Equal(t, models.ChangeModified, result[2].Type(), "a file with the same name is preferred")
+	assert.Equal(t, "/archive/copy.txt", result[2].Path)
+	assert.True(t, result[3].IsRenamed())
+	assert.Equal(t, "/docs/a.txt", result[3].PreviousPath)
+	assert.True(t, result[4].IsRenamed())
+	assert.Equal(t, "/docs/b.txt", result[4].PreviousPath)
+	assert.Equal(t, models.ChangeModified, result[5].Type())
+
+	assert.Equal(t, changes, DetectRenames(context.Background(), changes, nil))
+}
@@ -6,12 +6,13 @@ import (
 
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/interfaces"
 )
 
 // Monitor represents the main application monitor
 type Monitor struct {
 	DB            *db.DB
-	DropboxClient *dropbox.DropboxClient
+	DropboxClient interfaces.DropboxClient
 }
 
 // NewMonitor creates a new monitor with the given database connection string and Dropbox access token
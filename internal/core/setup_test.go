@@ -7,7 +7,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
-	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/mocks"
 )
 
 type mockDB struct {
@@ -19,27 +19,13 @@ func (m *mockDB) Close() error {
 }
 
 func newMockDB() *mockDB {
-	db, err := db.NewDB(":memory:")
+	db, err := db.NewDB(db.MemoryPath)
 	if err != nil {
 		panic(err)
 	}
 	return &mockDB{DB: db}
 }
 
-type mockDropboxClient struct {
-	*dropbox.DropboxClient
-}
-
-func (m *mockDropboxClient) Close() error {
-	return nil
-}
-
-func newMockDropboxClient() *mockDropboxClient {
-	return &mockDropboxClient{
-		DropboxClient: &dropbox.DropboxClient{},
-	}
-}
-
 func TestNewMonitor(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -49,13 +35,13 @@ func TestNewMonitor(t *testing.T) {
 	}{
 		{
 			name:         "valid configuration",
-			dbConnStr:    "test.db",
+			dbConnStr:    db.MemoryPath,
 			dropboxToken: "test-token",
 			wantErr:      false,
 		},
 		{
 			name:         "missing dropbox token",
-			dbConnStr:    "test.db",
+			dbConnStr:    db.MemoryPath,
 			dropboxToken: "",
 			wantErr:      true,
 		},
@@ -79,11 +65,9 @@ func TestNewMonitor(t *testing.T) {
 
 func TestMonitor_Close(t *testing.T) {
 	mockDb := newMockDB()
-	mockClient := newMockDropboxClient()
-
 	monitor := &Monitor{
 		DB:            mockDb.DB,
-		DropboxClient: mockClient.DropboxClient,
+		DropboxClient: &mocks.DropboxClient{},
 	}
 
 	err := monitor.Close()
@@ -4,11 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sync"
 
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
 )
 
 // StateManager manages application state persistence
@@ -17,6 +19,7 @@ type StateManager struct {
 	mu        sync.RWMutex
 	statePath string
 	state     map[string]interface{}
+	logger    *slog.Logger
 }
 
 // NewStateManager creates a new state manager
@@ -25,6 +28,7 @@ func NewStateManager(statePath string) *StateManager {
 		BaseComponent: lifecycle.NewBaseComponent("StateManager"),
 		statePath:     statePath,
 		state:         make(map[string]interface{}),
+		logger:        logging.Component(nil, "state"),
 	}
 	sm.SetState(lifecycle.StateInitialized)
 	return sm
@@ -90,25 +94,48 @@ func (sm *StateManager) SetString(key, value string) error {
 	return sm.saveState()
 }
 
-// loadState loads state from disk
+// loadState loads state from disk, falling back to the backup when the state
+// file is missing or truncated
 func (sm *StateManager) loadState() error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	data, err := os.ReadFile(sm.statePath)
+	state, err := readStateFile(sm.statePath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			sm.state = make(map[string]interface{})
-			return nil
+		backup, backupErr := readStateFile(backupPath(sm.statePath))
+		if backupErr != nil {
+			if os.IsNotExist(err) && os.IsNotExist(backupErr) {
+				sm.state = make(map[string]interface{})
+				return nil
+			}
+			return err
 		}
-		return fmt.Errorf("failed to read state file: %w", err)
+		sm.logger.Warn("Recovered state from backup", "path", backupPath(sm.statePath), "error", err)
+		state = backup
 	}
 
-	if err := json.Unmarshal(data, &sm.state); err != nil {
-		return fmt.Errorf("failed to unmarshal state: %w", err)
+	sm.state = state
+	return nil
+}
+
+// readStateFile reads and decodes a state file
+func readStateFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read state file: %w", err)
 	}
 
-	return nil
+	var state map[string]interface{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal state: %w", err)
+	}
+	if state == nil {
+		state = make(map[string]interface{})
+	}
+	return state, nil
 }
 
 // saveState saves state to disk
@@ -118,7 +145,7 @@ func (sm *StateManager) saveState() error {
 		return fmt.Errorf("failed to marshal state: %w", err)
 	}
 
-	if err := os.WriteFile(sm.statePath, data, 0644); err != nil {
+	if err := writeFileAtomic(sm.statePath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write state file: %w", err)
 	}
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -156,3 +157,62 @@ func TestStateManagerConcurrency(t *testing.T) {
 		}
 	})
 }
+
+func TestStateManagerRecovery(t *testing.T) {
+	ctx := context.Background()
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	sm := NewStateManager(statePath)
+	if err := sm.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := sm.SetString("cursor", "first"); err != nil {
+		t.Fatalf("SetString() error = %v", err)
+	}
+	if err := sm.SetString("cursor", "second"); err != nil {
+		t.Fatalf("SetString() error = %v", err)
+	}
+
+	// The previous version is kept as a backup and no temporary files remain
+	backup, err := os.ReadFile(backupPath(statePath))
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if !strings.Contains(string(backup), "first") {
+		t.Errorf("backup = %s, want previous state", backup)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(statePath))
+	if len(entries) != 2 {
+		t.Errorf("state directory has %d entries, want state file and backup", len(entries))
+	}
+
+	// A truncated state file falls back to the backup
+	if err := os.WriteFile(statePath, []byte(`{"cursor": "sec`), 0644); err != nil {
+		t.Fatalf("Failed to truncate state: %v", err)
+	}
+	recovered := NewStateManager(statePath)
+	if err := recovered.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if got := recovered.GetString("cursor"); got != "first" {
+		t.Errorf("GetString() = %v, want first", got)
+	}
+
+	// Without a usable backup, corruption is reported rather than discarded
+	os.WriteFile(backupPath(statePath), []byte(""), 0644)
+	os.WriteFile(statePath, []byte(`{"cursor"`), 0644)
+	if err := NewStateManager(statePath).Start(ctx); err == nil {
+		t.Error("Start() expected error for corrupt state without backup")
+	}
+
+	// A missing state file with a backup, as after a crash mid-replace
+	os.Remove(statePath)
+	os.WriteFile(backupPath(statePath), []byte(`{"cursor": "saved"}`), 0644)
+	restored := NewStateManager(statePath)
+	if err := restored.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if got := restored.GetString("cursor"); got != "saved" {
+		t.Errorf("GetString() = %v, want saved", got)
+	}
+}
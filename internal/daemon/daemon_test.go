@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", "monitor.pid")
+
+	pidFile, err := WritePIDFile(path)
+	require.NoError(t, err)
+	pid, err := ReadPIDFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.Getpid(), pid)
+
+	// Writing it again from the same process is allowed
+	_, err = WritePIDFile(path)
+	require.NoError(t, err)
+
+	require.NoError(t, pidFile.Remove())
+	assert.NoFileExists(t, path)
+}
+
+func TestPIDFile_Running(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "monitor.pid")
+
+	// The parent of the test process is still running
+	require.NoError(t, os.WriteFile(path, []byte(strconv.Itoa(os.Getppid())), 0o644))
+	_, err := WritePIDFile(path)
+	assert.ErrorContains(t, err, "already running")
+}
+
+func TestPIDFile_Stale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "monitor.pid")
+
+	// No process has the largest pid Linux hands out
+	require.NoError(t, os.WriteFile(path, []byte("4194304\n"), 0o644))
+	pidFile, err := WritePIDFile(path)
+	require.NoError(t, err)
+
+	// Another process replacing the file keeps it from being removed
+	require.NoError(t, os.WriteFile(path, []byte("4194304\n"), 0o644))
+	require.NoError(t, pidFile.Remove())
+	assert.FileExists(t, path)
+}
+
+func TestNotify(t *testing.T) {
+	t.Setenv(notifySocketEnv, "")
+	sent, err := Notify(Ready)
+	require.NoError(t, err)
+	assert.False(t, sent)
+
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	t.Setenv(notifySocketEnv, socket)
+	sent, err = Notify(Ready)
+	require.NoError(t, err)
+	assert.True(t, sent)
+
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, Ready, string(buf[:n]))
+}
+
+func TestRenderService(t *testing.T) {
+	opts := ServiceOptions{
+		Name:       "dropbox-monitor",
+		Executable: "/usr/local/bin/dropbox-monitor",
+		Args:       []string{"run", "--config", "/etc/dropbox monitor/config.yaml"},
+		WorkingDir: "/var/lib/dropbox-monitor",
+		User:       "monitor",
+	}
+
+	unit, err := RenderService(Systemd, opts)
+	require.NoError(t, err)
+	assert.Contains(t, unit, "Type=notify\n")
+	assert.Contains(t, unit, `ExecStart=/usr/local/bin/dropbox-monitor run --config "/etc/dropbox monitor/config.yaml"`+"\n")
+	assert.Contains(t, unit, "User=monitor\n")
+	assert.Contains(t, unit, "WorkingDirectory=/var/lib/dropbox-monitor\n")
+
+	opts.Args = append(opts.Args, "--log-level", "<debug>")
+	plist, err := RenderService(Launchd, opts)
+	require.NoError(t, err)
+	assert.Contains(t, plist, "<string>dropbox-monitor</string>")
+	assert.Contains(t, plist, "<string>/etc/dropbox monitor/config.yaml</string>")
+	assert.Contains(t, plist, "<string>&lt;debug&gt;</string>")
+	assert.Contains(t, plist, "<key>UserName</key>")
+
+	_, err = RenderService("upstart", opts)
+	assert.Error(t, err)
+}
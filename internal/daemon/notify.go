@@ -0,0 +1,48 @@
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// Service manager states sent by Notify
+const (
+	// Ready tells the service manager that startup has finished
+	Ready = "READY=1"
+	// Reloading tells it that the configuration is being reloaded; Ready
+	// follows once it has been applied
+	Reloading = "RELOADING=1"
+	// Stopping tells it that shutdown has begun
+	Stopping = "STOPPING=1"
+)
+
+// notifySocketEnv names the socket systemd listens on for Type=notify
+// services
+const notifySocketEnv = "NOTIFY_SOCKET"
+
+// Notify sends state to the service manager, as sd_notify does. It reports
+// false without an error when the monitor was not started by a service
+// manager that listens for notifications.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv(notifySocketEnv)
+	if socket == "" {
+		return false, nil
+	}
+	// A leading @ names a socket in the abstract namespace
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("failed to notify the service manager: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,86 @@
+// Package daemon runs the monitor unattended as a system service: it manages
+// the PID file, signals readiness to systemd, and renders the systemd unit or
+// launchd plist that starts the monitor.
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// PIDFile is a file holding the process ID of the running monitor, so only
+// one instance runs at a time and service managers can find it
+type PIDFile struct {
+	path string
+	pid  int
+}
+
+// WritePIDFile writes the current process ID to path. It fails when the file
+// names another process that is still running; a file left behind by a
+// process that has exited is replaced.
+func WritePIDFile(path string) (*PIDFile, error) {
+	if pid, err := ReadPIDFile(path); err == nil && pid != os.Getpid() && processRunning(pid) {
+		return nil, fmt.Errorf("already running with pid %d (%s)", pid, path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create pid file directory: %w", err)
+	}
+
+	// Write to a temporary file and rename it, so readers never see a
+	// partial pid
+	pid := os.Getpid()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.Itoa(pid)+"\n"), 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write pid file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("failed to write pid file: %w", err)
+	}
+	return &PIDFile{path: path, pid: pid}, nil
+}
+
+// ReadPIDFile returns the process ID stored in the file at path
+func ReadPIDFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid pid file %s", path)
+	}
+	return pid, nil
+}
+
+// Path returns where the PID file is
+func (p *PIDFile) Path() string {
+	return p.path
+}
+
+// Remove deletes the PID file, unless another process has since replaced it
+func (p *PIDFile) Remove() error {
+	if pid, err := ReadPIDFile(p.path); err != nil || pid != p.pid {
+		return nil
+	}
+	if err := os.Remove(p.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove pid file: %w", err)
+	}
+	return nil
+}
+
+// processRunning reports whether a process with the given ID exists
+func processRunning(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, os.ErrPermission)
+}
@@ -0,0 +1,156 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"runtime"
+	"strings"
+	"text/template"
+)
+
+// Service manager types
+const (
+	Systemd = "systemd"
+	Launchd = "launchd"
+)
+
+// ServiceOptions describe the service that runs the monitor
+type ServiceOptions struct {
+	// Name is the unit name, or the launchd label
+	Name string
+	// Description is shown by the service manager
+	Description string
+	// Executable is the absolute path of the dropbox-monitor binary
+	Executable string
+	// Args are the arguments it runs with, e.g. run --config /etc/...
+	Args []string
+	// WorkingDir is the directory it runs in
+	WorkingDir string
+	// User runs the service as this user when set
+	User string
+	// LogFile receives stdout and stderr under launchd, which has no journal
+	LogFile string
+}
+
+// DefaultServiceType returns the service manager of the current platform
+func DefaultServiceType() string {
+	if runtime.GOOS == "darwin" {
+		return Launchd
+	}
+	return Systemd
+}
+
+// RenderService renders the systemd unit or launchd plist for opts
+func RenderService(serviceType string, opts ServiceOptions) (string, error) {
+	if opts.Name == "" || opts.Executable == "" {
+		return "", fmt.Errorf("a service needs a name and an executable")
+	}
+	if opts.Description == "" {
+		opts.Description = "Dropbox Monitor"
+	}
+
+	var tmpl *template.Template
+	switch serviceType {
+	case Systemd:
+		tmpl = systemdUnit
+	case Launchd:
+		tmpl = launchdPlist
+	default:
+		return "", fmt.Errorf("unknown service type %q (want %s or %s)", serviceType, Systemd, Launchd)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, opts); err != nil {
+		return "", fmt.Errorf("failed to render %s service: %w", serviceType, err)
+	}
+	return buf.String(), nil
+}
+
+// systemdUnit runs the monitor as a Type=notify service, so it counts as
+// started once it has signalled Ready, and reloads it with SIGHUP
+var systemdUnit = template.Must(template.New("systemd").Funcs(template.FuncMap{
+	"command": systemdCommand,
+}).Parse(`[Unit]
+Description={{.Description}}
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=notify
+ExecStart={{command .Executable .Args}}
+ExecReload=/bin/kill -HUP $MAINPID
+{{- if .WorkingDir}}
+WorkingDirectory={{.WorkingDir}}
+{{- end}}
+{{- if .User}}
+User={{.User}}
+{{- end}}
+Restart=on-failure
+RestartSec=10
+TimeoutStopSec=60
+
+[Install]
+WantedBy=multi-user.target
+`))
+
+// launchdPlist keeps the monitor running under launchd
+var launchdPlist = template.Must(template.New("launchd").Funcs(template.FuncMap{
+	"xml": xmlEscape,
+}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{xml .Name}}</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>{{xml .Executable}}</string>
+{{- range .Args}}
+		<string>{{xml .}}</string>
+{{- end}}
+	</array>
+{{- if .WorkingDir}}
+	<key>WorkingDirectory</key>
+	<string>{{xml .WorkingDir}}</string>
+{{- end}}
+{{- if .User}}
+	<key>UserName</key>
+	<string>{{xml .User}}</string>
+{{- end}}
+{{- if .LogFile}}
+	<key>StandardOutPath</key>
+	<string>{{xml .LogFile}}</string>
+	<key>StandardErrorPath</key>
+	<string>{{xml .LogFile}}</string>
+{{- end}}
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+</dict>
+</plist>
+`))
+
+// systemdCommand joins a command line for ExecStart, quoting the words that
+// need it
+func systemdCommand(executable string, args []string) string {
+	words := make([]string, 0, len(args)+1)
+	for _, word := range append([]string{executable}, args...) {
+		if word == "" || strings.ContainsAny(word, " \t\"'\\$%;") {
+			word = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `$$`, `%`, `%%`).Replace(word) + `"`
+		}
+		words = append(words, word)
+	}
+	return strings.Join(words, " ")
+}
+
+// xmlEscape escapes s for an XML text node
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// unknownAuthor is used for changes without a recorded author
+const unknownAuthor = "unknown"
+
+// AuthorActivity is the number of changes an author made on a given day
+type AuthorActivity struct {
+	Author string    `json:"author"`
+	Day    time.Time `json:"day"`
+	Count  int       `json:"count"`
+}
+
+// GetAuthorActivity aggregates changes since the given time by author and day
+// for a single account, or for all accounts when accountID is empty. Results
+// are ordered by author and then by day.
+func (db *DB) GetAuthorActivity(ctx context.Context, accountID string, since time.Time) ([]AuthorActivity, error) {
+	query := `
+		SELECT COALESCE(modified_by_name, ''), COALESCE(author, ''), modified_at
+		FROM file_changes
+		WHERE modified_at > ?`
+	args := []interface{}{since}
+	if accountID != "" {
+		query += ` AND account_id = ?`
+		args = append(args, accountID)
+	}
+
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying author activity: %v", err)
+	}
+	defer rows.Close()
+
+	type key struct {
+		author string
+		day    time.Time
+	}
+	counts := make(map[key]int)
+	for rows.Next() {
+		var modifiedBy, author string
+		var modifiedAt time.Time
+		if err := rows.Scan(&modifiedBy, &author, &modifiedAt); err != nil {
+			return nil, fmt.Errorf("error scanning author activity: %v", err)
+		}
+
+		name := modifiedBy
+		if name == "" {
+			name = author
+		}
+		if name == "" {
+			name = unknownAuthor
+		}
+
+		local := modifiedAt.Local()
+		day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.Local)
+		counts[key{author: name, day: day}]++
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	activity := make([]AuthorActivity, 0, len(counts))
+	for k, count := range counts {
+		activity = append(activity, AuthorActivity{Author: k.author, Day: k.day, Count: count})
+	}
+	sort.Slice(activity, func(i, j int) bool {
+		if activity[i].Author != activity[j].Author {
+			return activity[i].Author < activity[j].Author
+		}
+		return activity[i].Day.Before(activity[j].Day)
+	})
+
+	return activity, nil
+}
+
+// FolderActivity is the number of changes within a folder on a given day
+type FolderActivity struct {
+	Folder string    `json:"folder"`
+	Day    time.Time `json:"day"`
+	Count  int       `json:"count"`
+}
+
+// GetFolderActivity aggregates changes since the given time by folder and day
+// for a single account, or for all accounts when accountID is empty. Each
+// change counts towards the longest of folders containing it, or towards its
+// top-level folder when none does. Results are ordered by folder and then by
+// day.
+func (db *DB) GetFolderActivity(ctx context.Context, accountID string, since time.Time, folders []string) ([]FolderActivity, error) {
+	query := `
+		SELECT file_path, modified_at
+		FROM file_changes
+		WHERE modified_at > ?`
+	args := []interface{}{since}
+	if accountID != "" {
+		query += ` AND account_id = ?`
+		args = append(args, accountID)
+	}
+
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying folder activity: %v", err)
+	}
+	defer rows.Close()
+
+	type key struct {
+		folder string
+		day    time.Time
+	}
+	counts := make(map[key]int)
+	for rows.Next() {
+		var filePath string
+		var modifiedAt time.Time
+		if err := rows.Scan(&filePath, &modifiedAt); err != nil {
+			return nil, fmt.Errorf("error scanning folder activity: %v", err)
+		}
+
+		local := modifiedAt.Local()
+		day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.Local)
+		counts[key{folder: folderOf(filePath, folders), day: day}]++
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	activity := make([]FolderActivity, 0, len(counts))
+	for k, count := range counts {
+		activity = append(activity, FolderActivity{Folder: k.folder, Day: k.day, Count: count})
+	}
+	sort.Slice(activity, func(i, j int) bool {
+		if activity[i].Folder != activity[j].Folder {
+			return activity[i].Folder < activity[j].Folder
+		}
+		return activity[i].Day.Before(activity[j].Day)
+	})
+
+	return activity, nil
+}
+
+// folderOf returns the longest of folders containing filePath, compared
+// case-insensitively as Dropbox paths are, falling back to the top-level
+// folder of filePath
+func folderOf(filePath string, folders []string) string {
+	lower := strings.ToLower(filePath)
+	best := ""
+	for _, folder := range folders {
+		prefix := strings.ToLower(strings.TrimSuffix(folder, "/"))
+		if prefix == "" || len(folder) <= len(best) {
+			continue
+		}
+		if strings.HasPrefix(lower, prefix+"/") {
+			best = folder
+		}
+	}
+	if best != "" {
+		return best
+	}
+
+	top, _, found := strings.Cut(strings.TrimPrefix(filePath, "/"), "/")
+	if !found {
+		return "/"
+	}
+	return "/" + top
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// ContentMode selects how much of a file's text SaveFileContent keeps
+type ContentMode string
+
+// Content modes
+const (
+	// ContentNone stores no text, only the content type and size
+	ContentNone ContentMode = "none"
+	// ContentPreview stores the start of the text, up to the preview size
+	ContentPreview ContentMode = "preview"
+	// ContentFull stores the whole text
+	ContentFull ContentMode = "full"
+)
+
+// DefaultContentPreviewBytes is the preview size used when the policy
+// leaves PreviewBytes unset
+const DefaultContentPreviewBytes = 2048
+
+// ContentPolicy decides what SaveFileContent stores of each file's text. The
+// zero value stores previews of the default size.
+type ContentPolicy struct {
+	Mode         ContentMode
+	PreviewBytes int
+}
+
+// Apply returns the part of text the policy stores and whether it was cut
+// short. Previews end on a whole character.
+func (p ContentPolicy) Apply(text string) (string, bool) {
+	switch p.Mode {
+	case ContentNone:
+		return "", text != ""
+	case ContentFull:
+		return text, false
+	}
+
+	limit := p.PreviewBytes
+	if limit <= 0 {
+		limit = DefaultContentPreviewBytes
+	}
+	if len(text) <= limit {
+		return text, false
+	}
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	return text[:limit], true
+}
+
+// SetContentPolicy sets what SaveFileContent stores of each file's text
+func (db *DB) SetContentPolicy(policy ContentPolicy) {
+	db.contents = policy
+}
+
+// FileChangeIDForContent returns the ID of the stored change that content
+// of path belongs to: the latest version with contentHash, or the latest
+// version of path when contentHash is empty. It reports false when no such
+// change is stored.
+func (db *DB) FileChangeIDForContent(ctx context.Context, path, contentHash string) (int64, bool, error) {
+	query := `
+		SELECT id FROM file_changes
+		WHERE file_path = ? AND NOT COALESCE(is_deleted, FALSE)
+		ORDER BY modified_at DESC, id DESC
+		LIMIT 1`
+	args := []interface{}{path}
+	if contentHash != "" {
+		query = `
+			SELECT id FROM file_changes
+			WHERE file_path = ? AND content_hash = ?
+			ORDER BY modified_at DESC, id DESC
+			LIMIT 1`
+		args = append(args, contentHash)
+	}
+
+	var id int64
+	err := db.DB.QueryRowContext(ctx, query, args...).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("error finding change for content: %v", err)
+	}
+	return id, true, nil
+}
+
+// ContentRevision is the stored content of one version of a file
+type ContentRevision struct {
+	FileContent
+	ContentHash string
+	Rev         string
+	ModifiedAt  time.Time
+}
+
+// GetContentRevisions returns up to limit stored contents of path, newest
+// version first
+func (db *DB) GetContentRevisions(ctx context.Context, path string, limit int) ([]ContentRevision, error) {
+	rows, err := db.DB.QueryContext(ctx, `
+		SELECT c.id, c.file_change_id, COALESCE(c.content, ''), COALESCE(c.content_type, ''),
+			COALESCE(c.size, 0), COALESCE(c.truncated, FALSE), c.created_at,
+			COALESCE(fc.content_hash, ''), COALESCE(fc.dropbox_rev, ''), fc.modified_at
+		FROM file_contents c
+		JOIN file_changes fc ON fc.id = c.file_change_id
+		WHERE fc.file_path = ?
+		ORDER BY fc.modified_at DESC, fc.id DESC
+		LIMIT ?`, path, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying content revisions: %v", err)
+	}
+	defer rows.Close()
+
+	var revisions []ContentRevision
+	for rows.Next() {
+		var r ContentRevision
+		if err := rows.Scan(&r.ID, &r.FileChangeID, &r.Content, &r.ContentType, &r.Size, &r.Truncated,
+			&r.CreatedAt, &r.ContentHash, &r.Rev, &r.ModifiedAt); err != nil {
+			return nil, fmt.Errorf("error scanning content revision: %v", err)
+		}
+		revisions = append(revisions, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating content revisions: %v", err)
+	}
+	return revisions, nil
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// NewFileChangeFromModel converts a processed change into its stored form.
+// The Dropbox content hash is stored when the change carries one, so saving
+// unchanged content again is skipped; otherwise a hash is derived from the
+// path, modification time and size to keep distinct versions apart.
+// Deletions always get a derived hash so they are never mistaken for the
+// version they delete.
+func NewFileChangeFromModel(change models.FileChange) *FileChange {
+	contentHash := change.ContentHash
+	if change.IsDeleted {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("deleted|%s|%d", change.Path, change.Modified.UnixNano())))
+		contentHash = hex.EncodeToString(sum[:])
+	} else if contentHash == "" {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d", change.Path, change.Modified.UnixNano(), change.Size)))
+		contentHash = hex.EncodeToString(sum[:])
+	}
+
+	return &FileChange{
+		FilePath:       change.Path,
+		ModifiedAt:     change.Modified,
+		FileType:       change.Extension,
+		ContentHash:    contentHash,
+		SHA256:         change.SHA256,
+		ServerModified: change.Modified,
+		Size:           change.Size,
+		ModifiedByName: change.ModifiedBy,
+		AccountID:      change.AccountID,
+		IsDeleted:      change.IsDeleted,
+		Portfolio:      change.Portfolio,
+		Project:        change.Project,
+		DocumentType:   change.DocumentType,
+		Details:        change.Details,
+	}
+}
+
+// ToModel converts a stored change into the form used by reports and filters
+func (fc FileChange) ToModel() models.FileChange {
+	author := fc.ModifiedByName
+	if author == "" {
+		author = fc.Author
+	}
+
+	change := models.FileChange{
+		Path:         fc.FilePath,
+		Modified:     fc.ModifiedAt,
+		Size:         fc.Size,
+		AccountID:    fc.AccountID,
+		ModifiedBy:   author,
+		SHA256:       fc.SHA256,
+		IsDeleted:    fc.IsDeleted,
+		Portfolio:    fc.Portfolio,
+		Project:      fc.Project,
+		DocumentType: fc.DocumentType,
+		Details:      fc.Details,
+	}
+	change.Normalize()
+	return change
+}
+
+// NewInventoryFileFromModel converts file metadata seen by a sync into its
+// inventory form
+func NewInventoryFileFromModel(file models.FileMetadata, syncedAt time.Time) InventoryFile {
+	modified := file.ServerModified
+	if modified.IsZero() {
+		modified = file.Modified
+	}
+	return InventoryFile{
+		Path:           file.Path,
+		Size:           file.Size,
+		Rev:            file.Rev,
+		ContentHash:    file.ContentHash,
+		ModifiedByID:   file.ModifiedByID,
+		ServerModified: modified,
+		AccountID:      file.AccountID,
+		SyncedAt:       syncedAt,
+		Deleted:        file.IsDeleted,
+	}
+}
+
+// ToModel converts an inventory entry into file metadata
+func (f InventoryFile) ToModel() models.FileMetadata {
+	file := models.NewFileMetadata(f.Path, f.Size, f.ServerModified, false)
+	file.ServerModified = f.ServerModified
+	file.Rev = f.Rev
+	file.ContentHash = f.ContentHash
+	file.ModifiedByID = f.ModifiedByID
+	file.AccountID = f.AccountID
+	return *file
+}
@@ -3,6 +3,8 @@ package db
 import (
 	"context"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/agent"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
@@ -43,20 +45,72 @@ func (a *DatabaseAgentImpl) Health(ctx context.Context) error {
 	return a.DefaultHealth(ctx)
 }
 
-// StoreChange stores a file change in the database
+// StoreChange records the metadata of one file in the inventory
 func (a *DatabaseAgentImpl) StoreChange(ctx context.Context, change models.FileMetadata) error {
-	// TODO: Implement database storage
-	return nil
+	return a.StoreFiles(ctx, []*models.FileMetadata{&change})
 }
 
-// GetLatestChanges retrieves the latest changes from the database
+// StoreFiles records the metadata of the files seen by a sync in the
+// inventory, removing deleted files
+func (a *DatabaseAgentImpl) StoreFiles(ctx context.Context, files []*models.FileMetadata) error {
+	now := time.Now()
+	entries := make([]InventoryFile, 0, len(files))
+	for _, file := range files {
+		if file != nil {
+			entries = append(entries, NewInventoryFileFromModel(*file, now))
+		}
+	}
+	return a.db.SaveInventory(ctx, entries)
+}
+
+// GetLatestChanges returns the limit most recently modified files of the
+// inventory
 func (a *DatabaseAgentImpl) GetLatestChanges(ctx context.Context, limit int) ([]models.FileMetadata, error) {
-	// TODO: Implement database retrieval
-	return nil, nil
+	files, err := a.inventory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if limit >= 0 && len(files) > limit {
+		files = files[:limit]
+	}
+	return files, nil
 }
 
-// GetChanges retrieves changes within a time range
+// GetChanges returns the files of the inventory last modified between the
+// RFC 3339 times startTime and endTime, most recent first
 func (a *DatabaseAgentImpl) GetChanges(ctx context.Context, startTime, endTime string) ([]models.FileMetadata, error) {
-	// TODO: Implement database retrieval
-	return nil, nil
+	start, err := time.Parse(time.RFC3339, startTime)
+	if err != nil {
+		return nil, fmt.Errorf("parse start time: %w", err)
+	}
+	end, err := time.Parse(time.RFC3339, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("parse end time: %w", err)
+	}
+
+	files, err := a.inventory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var changes []models.FileMetadata
+	for _, file := range files {
+		if !file.Modified.Before(start) && file.Modified.Before(end) {
+			changes = append(changes, file)
+		}
+	}
+	return changes, nil
+}
+
+// inventory returns every file of the inventory, most recently modified first
+func (a *DatabaseAgentImpl) inventory(ctx context.Context) ([]models.FileMetadata, error) {
+	entries, err := a.db.GetInventory(ctx, "", "")
+	if err != nil {
+		return nil, err
+	}
+	files := make([]models.FileMetadata, 0, len(entries))
+	for _, entry := range entries {
+		files = append(files, entry.ToModel())
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Modified.After(files[j].Modified) })
+	return files, nil
 }
@@ -8,6 +8,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -17,8 +18,22 @@ type DBType int
 
 const (
 	SQLite DBType = iota
+	Postgres
 )
 
+// Driver names accepted by Open
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// MemoryPath is the SQLite path of a database held in memory, which is
+// discarded when it is closed. Tests use it to avoid files on disk.
+const MemoryPath = ":memory:"
+
+// memoryDatabases numbers the in-memory databases so each is separate
+var memoryDatabases atomic.Int64
+
 // Store defines the interface for database operations
 type Store interface {
 	SaveFileChange(ctx context.Context, fc *FileChange) error
@@ -30,6 +45,9 @@ type Store interface {
 type DB struct {
 	DB     *sql.DB // Expose the underlying connection
 	DBType DBType
+
+	batchSize int
+	contents  ContentPolicy
 }
 
 type Vector []float32
@@ -54,10 +72,61 @@ func (v *Vector) Scan(value interface{}) error {
 
 func NewDB(connStr string) (*DB, error) {
 	log.Println("Starting database initialization...")
-	return initSQLiteDB(connStr)
+	return initSQLiteDB(connStr, true)
+}
+
+// Open opens the database with the given driver, sqlite or postgres, and
+// brings its schema up to date. An empty driver means sqlite. For SQLite
+// dsn is the path of the database file; for PostgreSQL it is a connection
+// string or URL, and the pgvector extension must be available.
+func Open(driver, dsn string) (*DB, error) {
+	return open(driver, dsn, true)
 }
 
-func initSQLiteDB(connStr string) (*DB, error) {
+// OpenWithoutMigrations opens the database leaving its schema as it is, for
+// tools that manage the migrations themselves
+func OpenWithoutMigrations(driver, dsn string) (*DB, error) {
+	return open(driver, dsn, false)
+}
+
+// open opens the database with driver, applying pending migrations when
+// migrateSchema is set
+func open(driver, dsn string, migrateSchema bool) (*DB, error) {
+	switch driver {
+	case "", DriverSQLite:
+		return initSQLiteDB(dsn, migrateSchema)
+	case DriverPostgres:
+		return initPostgresDB(dsn, migrateSchema)
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", driver)
+	}
+}
+
+func initPostgresDB(dsn string, migrateSchema bool) (*DB, error) {
+	log.Println("Initializing PostgreSQL database...")
+
+	conn, err := openPostgres(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error connecting to PostgreSQL database: %v", err)
+	}
+
+	db := &DB{DB: conn, DBType: Postgres}
+	if migrateSchema {
+		if err := db.Migrate(context.Background()); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("error initializing PostgreSQL schema: %v", err)
+		}
+	}
+
+	log.Println("Successfully initialized PostgreSQL database")
+	return db, nil
+}
+
+func initSQLiteDB(connStr string, migrateSchema bool) (*DB, error) {
 	log.Println("Initializing SQLite database...")
 	
 	// Extract database path from connection string
@@ -66,19 +135,25 @@ func initSQLiteDB(connStr string) (*DB, error) {
 		dbPath = dbPath[5:]
 	}
 
-	// Create parent directory if it doesn't exist
-	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
-		return nil, fmt.Errorf("error creating data directory: %v", err)
-	}
+	if connStr == MemoryPath {
+		// The pool's connections share the database through a cache named
+		// for it alone, which lives until the last of them is closed
+		connStr = fmt.Sprintf("file:memdb%d?mode=memory&cache=shared", memoryDatabases.Add(1))
+	} else {
+		// Create parent directory if it doesn't exist
+		if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+			return nil, fmt.Errorf("error creating data directory: %v", err)
+		}
 
-	// Try to remove any existing WAL files that might be corrupted
-	walPath := dbPath + "-wal"
-	shmPath := dbPath + "-shm"
-	os.Remove(walPath)
-	os.Remove(shmPath)
+		// Try to remove any existing WAL files that might be corrupted
+		walPath := dbPath + "-wal"
+		shmPath := dbPath + "-shm"
+		os.Remove(walPath)
+		os.Remove(shmPath)
 
-	// Open database with WAL journal mode and normal synchronous mode for better performance
-	connStr = fmt.Sprintf("%s?_journal_mode=WAL&_synchronous=NORMAL", connStr)
+		// Open database with WAL journal mode and normal synchronous mode for better performance
+		connStr = fmt.Sprintf("%s?_journal_mode=WAL&_synchronous=NORMAL", connStr)
+	}
 	conn, err := sql.Open("sqlite", connStr)
 	if err != nil {
 		return nil, fmt.Errorf("error opening SQLite database: %v", err)
@@ -90,159 +165,223 @@ func initSQLiteDB(connStr string) (*DB, error) {
 		return nil, fmt.Errorf("error connecting to SQLite database: %v", err)
 	}
 
-	// Initialize schema
-	if err := initSQLiteSchema(conn); err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("error initializing SQLite schema: %v", err)
+	// Bring the schema up to date
+	db := &DB{DB: conn, DBType: SQLite}
+	if migrateSchema {
+		if err := db.Migrate(context.Background()); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("error initializing SQLite schema: %v", err)
+		}
 	}
 
 	log.Printf("Successfully initialized SQLite database at: %s", dbPath)
-	return &DB{DB: conn, DBType: SQLite}, nil
+	return db, nil
 }
 
-func initSQLiteSchema(conn *sql.DB) error {
-	// Start a transaction for table creation
-	tx, err := conn.Begin()
+// ensureColumn adds a column to an existing table if it is missing
+func ensureColumn(conn *sql.DB, table, column, columnType string) error {
+	rows, err := conn.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
 	if err != nil {
-		return fmt.Errorf("error starting transaction: %v", err)
-	}
-	defer tx.Rollback() // Rollback if we return with error
-
-	// First create tables
-	tables := []string{
-		`CREATE TABLE IF NOT EXISTS file_changes (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			file_path TEXT NOT NULL,
-			modified_at DATETIME NOT NULL,
-			file_type TEXT,
-			portfolio TEXT,
-			project TEXT,
-			document_type TEXT,
-			author TEXT,
-			content_hash TEXT,
-			embedding TEXT,
-			dropbox_id TEXT,
-			dropbox_rev TEXT,
-			client_modified DATETIME,
-			server_modified DATETIME,
-			size INTEGER,
-			is_downloadable BOOLEAN,
-			modified_by_id TEXT,
-			modified_by_name TEXT,
-			shared_folder_id TEXT,
-			lock_holder_name TEXT,
-			lock_holder_id TEXT,
-			lock_created_at DATETIME,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS file_contents (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			file_change_id INTEGER NOT NULL,
-			content TEXT,
-			content_type TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (file_change_id) REFERENCES file_changes(id)
-		)`,
-		`CREATE TABLE IF NOT EXISTS daily_summaries (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			summary_date DATE NOT NULL,
-			total_files INTEGER NOT NULL,
-			summary TEXT,
-			portfolio_stats TEXT,
-			project_stats TEXT,
-			author_stats TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS sync_state (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			cursor TEXT NOT NULL,
-			last_sync DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-	}
-
-	// Execute table creation queries
-	for _, query := range tables {
-		if _, err := tx.Exec(query); err != nil {
-			return fmt.Errorf("error executing query %q: %v", query, err)
+		return fmt.Errorf("error reading table info for %s: %v", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return fmt.Errorf("error scanning table info for %s: %v", table, err)
+		}
+		if name == column {
+			return nil
 		}
 	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating table info for %s: %v", table, err)
+	}
 
-	// Commit the transaction to ensure tables are created
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("error committing transaction: %v", err)
+	if _, err := conn.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, columnType)); err != nil {
+		return fmt.Errorf("error adding column %s.%s: %v", table, column, err)
+	}
+	return nil
+}
+
+// SaveFileChange stores a file change unless the same version is stored
+// already, setting its ID either way
+func (db *DB) SaveFileChange(ctx context.Context, fc *FileChange) error {
+	changes := []FileChange{*fc}
+	if err := db.SaveFileChanges(ctx, changes); err != nil {
+		return err
 	}
+	*fc = changes[0]
+	return nil
+}
 
-	// Verify that the tables exist before creating indexes
-	var exists int
-	err = conn.QueryRow("SELECT 1 FROM sqlite_master WHERE type='table' AND name='file_changes'").Scan(&exists)
-	if err != nil {
-		return fmt.Errorf("error checking if table exists: %v", err)
+// DefaultWriteBatchSize is how many file changes SaveFileChanges stores in
+// each transaction unless SetWriteBatchSize says otherwise
+const DefaultWriteBatchSize = 500
+
+// SetWriteBatchSize sets how many file changes SaveFileChanges stores in
+// each transaction; values below 1 restore the default
+func (db *DB) SetWriteBatchSize(size int) {
+	db.batchSize = size
+}
+
+// writeBatchSize returns the number of file changes stored per transaction
+func (db *DB) writeBatchSize() int {
+	if db.batchSize < 1 {
+		return DefaultWriteBatchSize
 	}
+	return db.batchSize
+}
 
-	if exists != 1 {
-		return fmt.Errorf("file_changes table was not created successfully")
+// SaveFileChanges stores file changes in order, skipping those whose version
+// is stored already, and sets their IDs. Changes are written in transactions
+// of the write batch size with prepared statements, which is much faster
+// than saving them one at a time. When a batch fails it is rolled back and
+// the error returned, but earlier batches stay committed.
+func (db *DB) SaveFileChanges(ctx context.Context, changes []FileChange) error {
+	size := db.writeBatchSize()
+	for start := 0; start < len(changes); start += size {
+		end := start + size
+		if end > len(changes) {
+			end = len(changes)
+		}
+		if err := db.saveFileChangeBatch(ctx, changes[start:end]); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	// Start a new transaction for indexes
-	tx, err = conn.Begin()
+// saveFileChangeBatch stores changes in one transaction
+func (db *DB) saveFileChangeBatch(ctx context.Context, changes []FileChange) error {
+	tx, err := db.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("error starting transaction for indexes: %v", err)
+		return fmt.Errorf("error starting file change transaction: %v", err)
 	}
 	defer tx.Rollback()
 
-	// Create indexes in a separate transaction
-	indexes := []string{
-		`CREATE INDEX IF NOT EXISTS idx_file_changes_file_path ON file_changes(file_path)`,
-		`CREATE INDEX IF NOT EXISTS idx_file_changes_modified_at ON file_changes(modified_at)`,
-		`CREATE INDEX IF NOT EXISTS idx_file_changes_content_hash ON file_changes(content_hash)`,
-		`CREATE INDEX IF NOT EXISTS idx_file_changes_dropbox_id ON file_changes(dropbox_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_daily_summaries_date ON daily_summaries(summary_date)`,
+	w, err := prepareFileChangeWriter(ctx, tx)
+	if err != nil {
+		return err
 	}
+	defer w.Close()
 
-	// Execute index creation queries
-	for _, query := range indexes {
-		if _, err := tx.Exec(query); err != nil {
-			return fmt.Errorf("error executing query %q: %v", query, err)
+	for i := range changes {
+		if err := w.save(ctx, &changes[i]); err != nil {
+			return fmt.Errorf("error saving file change %s: %v", changes[i].FilePath, err)
 		}
 	}
 
-	// Commit the index transaction
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("error committing index transaction: %v", err)
+		return fmt.Errorf("error committing file changes: %v", err)
 	}
-
 	return nil
 }
 
-func (db *DB) SaveFileChange(ctx context.Context, fc *FileChange) error {
-	// Check if file with same path and content hash already exists
-	existing, err := db.GetExistingFileChange(ctx, fc.FilePath, fc.ContentHash)
+// fileChangeWriter stores file changes within a transaction using
+// statements prepared once for the whole batch
+type fileChangeWriter struct {
+	existing *sql.Stmt
+	deleted  *sql.Stmt
+	insert   *sql.Stmt
+}
+
+// prepareFileChangeWriter prepares the statements used to store changes in tx
+func prepareFileChangeWriter(ctx context.Context, tx *sql.Tx) (*fileChangeWriter, error) {
+	w := &fileChangeWriter{}
+	var err error
+
+	// The latest stored version of a path with the same content
+	w.existing, err = tx.PrepareContext(ctx, `
+		SELECT id, modified_at FROM file_changes
+		WHERE file_path = ? AND content_hash = ?
+		ORDER BY modified_at DESC
+		LIMIT 1`)
 	if err != nil {
-		return fmt.Errorf("error checking for existing file: %v", err)
-	}
-	if existing != nil {
-		// File already exists with same content hash, no need to save
-		fc.ID = existing.ID // Set the ID so it can be used for file content
-		return nil
+		return nil, fmt.Errorf("error preparing file change lookup: %v", err)
 	}
 
-	// Convert embedding to JSON for SQLite storage
-	embeddingJSON, err := json.Marshal(fc.Embedding)
+	// Whether a deletion of a path was stored after a given time
+	w.deleted, err = tx.PrepareContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM file_changes
+			WHERE file_path = ? AND is_deleted AND modified_at > ?
+		)`)
 	if err != nil {
-		return fmt.Errorf("error marshaling embedding: %v", err)
+		w.Close()
+		return nil, fmt.Errorf("error preparing deletion lookup: %v", err)
 	}
 
-	query := `
+	w.insert, err = tx.PrepareContext(ctx, `
 		INSERT INTO file_changes (
 			file_path, modified_at, file_type, portfolio, project, document_type, 
 			author, content_hash, embedding, dropbox_id, dropbox_rev, client_modified, 
 			server_modified, size, is_downloadable, modified_by_id, modified_by_name, 
-			shared_folder_id, lock_holder_name, lock_holder_id, lock_created_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		RETURNING id, created_at`
+			shared_folder_id, lock_holder_name, lock_holder_id, lock_created_at,
+			account_id, sha256, is_deleted, details
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id, created_at`)
+	if err != nil {
+		w.Close()
+		return nil, fmt.Errorf("error preparing file change insert: %v", err)
+	}
+	return w, nil
+}
 
-	err = db.DB.QueryRowContext(ctx, query,
+// Close releases the prepared statements
+func (w *fileChangeWriter) Close() {
+	for _, stmt := range []*sql.Stmt{w.existing, w.deleted, w.insert} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+}
+
+// save stores fc unless the same version is stored already
+func (w *fileChangeWriter) save(ctx context.Context, fc *FileChange) error {
+	// Check if file with same path and content hash already exists
+	var existingID int64
+	var existingModified time.Time
+	err := w.existing.QueryRowContext(ctx, fc.FilePath, fc.ContentHash).Scan(&existingID, &existingModified)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("error checking for existing file: %v", err)
+	}
+	if err == nil {
+		// A file restored after a deletion is a new version even with the same content
+		var deleted bool
+		if err := w.deleted.QueryRowContext(ctx, fc.FilePath, existingModified).Scan(&deleted); err != nil {
+			return fmt.Errorf("error checking for deletion: %v", err)
+		}
+		if !deleted {
+			// File already exists with same content hash, no need to save
+			fc.ID = existingID // Set the ID so it can be used for file content
+			return nil
+		}
+	}
+
+	// Convert embedding to JSON for SQLite storage
+	embeddingJSON, err := json.Marshal(fc.Embedding)
+	if err != nil {
+		return fmt.Errorf("error marshaling embedding: %v", err)
+	}
+	var details sql.NullString
+	if len(fc.Details) > 0 {
+		detailsJSON, err := json.Marshal(fc.Details)
+		if err != nil {
+			return fmt.Errorf("error marshaling details: %v", err)
+		}
+		details = sql.NullString{String: string(detailsJSON), Valid: true}
+	}
+
+	return w.insert.QueryRowContext(ctx,
 		fc.FilePath,
 		fc.ModifiedAt,
 		fc.FileType,
@@ -264,13 +403,29 @@ func (db *DB) SaveFileChange(ctx context.Context, fc *FileChange) error {
 		fc.LockHolderName,
 		fc.LockHolderID,
 		fc.LockCreatedAt,
+		fc.AccountID,
+		fc.SHA256,
+		fc.IsDeleted,
+		details,
 	).Scan(&fc.ID, &fc.CreatedAt)
+}
 
+// LastContentHash returns the content hash stored with the latest version of
+// path that is not a deletion, or "" when none is stored
+func (db *DB) LastContentHash(ctx context.Context, path string) (string, error) {
+	var hash sql.NullString
+	err := db.DB.QueryRowContext(ctx, `
+		SELECT content_hash FROM file_changes
+		WHERE file_path = ? AND NOT COALESCE(is_deleted, FALSE)
+		ORDER BY modified_at DESC, id DESC
+		LIMIT 1`, path).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
 	if err != nil {
-		return fmt.Errorf("error saving file change: %v", err)
+		return "", fmt.Errorf("error querying content hash: %v", err)
 	}
-
-	return nil
+	return hash.String, nil
 }
 
 func (db *DB) GetExistingFileChange(ctx context.Context, filePath string, contentHash string) (*FileChange, error) {
@@ -281,14 +436,15 @@ func (db *DB) GetExistingFileChange(ctx context.Context, filePath string, conten
 			dropbox_rev, client_modified, server_modified, size, 
 			is_downloadable, modified_by_id, modified_by_name, 
 			shared_folder_id, lock_holder_name, lock_holder_id, 
-			lock_created_at, created_at
+			lock_created_at, created_at, COALESCE(account_id, ''),
+			COALESCE(sha256, ''), COALESCE(is_deleted, FALSE), COALESCE(details, '')
 		FROM file_changes
 		WHERE file_path = ? AND content_hash = ?
 		ORDER BY modified_at DESC
 		LIMIT 1`
 
 	var fc FileChange
-	var embeddingJSON string
+	var embeddingJSON, detailsJSON string
 	var clientModified, serverModified, lockCreatedAt sql.NullTime
 	err := db.DB.QueryRowContext(ctx, query, filePath, contentHash).Scan(
 		&fc.ID,
@@ -314,6 +470,10 @@ func (db *DB) GetExistingFileChange(ctx context.Context, filePath string, conten
 		&fc.LockHolderID,
 		&lockCreatedAt,
 		&fc.CreatedAt,
+		&fc.AccountID,
+		&fc.SHA256,
+		&fc.IsDeleted,
+		&detailsJSON,
 	)
 
 	if err == sql.ErrNoRows {
@@ -329,6 +489,11 @@ func (db *DB) GetExistingFileChange(ctx context.Context, filePath string, conten
 			return nil, fmt.Errorf("error unmarshaling embedding: %v", err)
 		}
 	}
+	if detailsJSON != "" {
+		if err := json.Unmarshal([]byte(detailsJSON), &fc.Details); err != nil {
+			return nil, fmt.Errorf("error unmarshaling details: %v", err)
+		}
+	}
 
 	if clientModified.Valid {
 		fc.ClientModified = clientModified.Time
@@ -343,6 +508,10 @@ func (db *DB) GetExistingFileChange(ctx context.Context, filePath string, conten
 	return &fc, nil
 }
 
+// SaveFileContent stores the text of the file change fc belongs to, as much
+// of it as the content policy keeps, and sets Content, Size and Truncated to
+// what was stored. Each change keeps its first stored content, so the
+// changes of a path hold the content of each of its revisions.
 func (db *DB) SaveFileContent(ctx context.Context, fc *FileContent) error {
 	// Check if content already exists for this file change
 	var exists bool
@@ -361,15 +530,24 @@ func (db *DB) SaveFileContent(ctx context.Context, fc *FileContent) error {
 		return nil
 	}
 
+	if fc.Size == 0 {
+		fc.Size = int64(len(fc.Content))
+	}
+	var truncated bool
+	fc.Content, truncated = db.contents.Apply(fc.Content)
+	fc.Truncated = fc.Truncated || truncated
+
 	query := `
-		INSERT INTO file_contents (file_change_id, content, content_type)
-		VALUES (?, ?, ?)
+		INSERT INTO file_contents (file_change_id, content, content_type, size, truncated)
+		VALUES (?, ?, ?, ?, ?)
 		RETURNING id, created_at`
 
 	err = db.DB.QueryRowContext(ctx, query,
 		fc.FileChangeID,
 		fc.Content,
 		fc.ContentType,
+		fc.Size,
+		fc.Truncated,
 	).Scan(&fc.ID, &fc.CreatedAt)
 
 	if err != nil {
@@ -418,20 +596,76 @@ func (db *DB) SaveDailySummary(ctx context.Context, ds *DailySummary) error {
 	return nil
 }
 
-func (db *DB) GetRecentFileChanges(ctx context.Context, since time.Time) ([]FileChange, error) {
-	query := `
+// fileChangeColumns selects every file_changes column in the order scanned by queryFileChanges
+const fileChangeColumns = `
 		SELECT 
 			id, file_path, modified_at, file_type, portfolio, project, 
 			document_type, author, content_hash, embedding, dropbox_id, 
 			dropbox_rev, client_modified, server_modified, size, 
 			is_downloadable, modified_by_id, modified_by_name, 
 			shared_folder_id, lock_holder_name, lock_holder_id, 
-			lock_created_at, created_at
-		FROM file_changes
-		WHERE modified_at > ?
-		ORDER BY modified_at DESC`
+			lock_created_at, created_at, COALESCE(account_id, ''),
+			COALESCE(sha256, ''), COALESCE(is_deleted, FALSE), COALESCE(details, '')
+		FROM file_changes`
 
-	rows, err := db.DB.QueryContext(ctx, query, since)
+func (db *DB) GetRecentFileChanges(ctx context.Context, since time.Time) ([]FileChange, error) {
+	return db.GetRecentFileChangesForAccount(ctx, "", since)
+}
+
+// GetRecentFileChangesForAccount returns changes since the given time for a
+// single account, or for all accounts when accountID is empty
+func (db *DB) GetRecentFileChangesForAccount(ctx context.Context, accountID string, since time.Time) ([]FileChange, error) {
+	query := fileChangeColumns + `
+		WHERE modified_at > ?`
+	args := []interface{}{since}
+	if accountID != "" {
+		query += ` AND account_id = ?`
+		args = append(args, accountID)
+	}
+	query += ` ORDER BY modified_at DESC`
+
+	return db.queryFileChanges(ctx, query, args...)
+}
+
+// GetFileChangesAfter returns up to limit changes stored after the change
+// with ID afterID, in the order they were stored, for a single account or
+// for all accounts when accountID is empty. Change IDs only grow, so the ID
+// of the last change returned serves as the cursor for the next call.
+func (db *DB) GetFileChangesAfter(ctx context.Context, accountID string, afterID int64, limit int) ([]FileChange, error) {
+	query := fileChangeColumns + `
+		WHERE id > ?`
+	args := []interface{}{afterID}
+	if accountID != "" {
+		query += ` AND account_id = ?`
+		args = append(args, accountID)
+	}
+	query += ` ORDER BY id ASC LIMIT ?`
+	args = append(args, limit)
+
+	return db.queryFileChanges(ctx, query, args...)
+}
+
+// LatestFileChangeID returns the ID of the most recently stored change, or 0
+// when none are stored
+func (db *DB) LatestFileChangeID(ctx context.Context) (int64, error) {
+	var id int64
+	if err := db.DB.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM file_changes`).Scan(&id); err != nil {
+		return 0, fmt.Errorf("error querying latest file change: %v", err)
+	}
+	return id, nil
+}
+
+// GetFileChangesBetween returns changes modified in [since, until), oldest first
+func (db *DB) GetFileChangesBetween(ctx context.Context, since, until time.Time) ([]FileChange, error) {
+	query := fileChangeColumns + `
+		WHERE modified_at >= ? AND modified_at < ?
+		ORDER BY modified_at ASC`
+	return db.queryFileChanges(ctx, query, since, until)
+}
+
+// queryFileChanges runs a query selecting all file_changes columns
+func (db *DB) queryFileChanges(ctx context.Context, query string, args ...interface{}) ([]FileChange, error) {
+	rows, err := db.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error querying file changes: %v", err)
 	}
@@ -440,7 +674,7 @@ func (db *DB) GetRecentFileChanges(ctx context.Context, since time.Time) ([]File
 	var files []FileChange
 	for rows.Next() {
 		var fc FileChange
-		var embeddingJSON string
+		var embeddingJSON, detailsJSON string
 		var clientModified, serverModified, lockCreatedAt sql.NullTime
 		err := rows.Scan(
 			&fc.ID,
@@ -466,6 +700,10 @@ func (db *DB) GetRecentFileChanges(ctx context.Context, since time.Time) ([]File
 			&fc.LockHolderID,
 			&lockCreatedAt,
 			&fc.CreatedAt,
+			&fc.AccountID,
+			&fc.SHA256,
+			&fc.IsDeleted,
+			&detailsJSON,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning file change: %v", err)
@@ -477,6 +715,11 @@ func (db *DB) GetRecentFileChanges(ctx context.Context, since time.Time) ([]File
 				return nil, fmt.Errorf("error unmarshaling embedding: %v", err)
 			}
 		}
+		if detailsJSON != "" {
+			if err := json.Unmarshal([]byte(detailsJSON), &fc.Details); err != nil {
+				return nil, fmt.Errorf("error unmarshaling details: %v", err)
+			}
+		}
 
 		if clientModified.Valid {
 			fc.ClientModified = clientModified.Time
@@ -526,6 +769,11 @@ type FileChange struct {
 	LockHolderID    string    `json:"lock_holder_id"`
 	LockCreatedAt   time.Time `json:"lock_created_at"`
 	CreatedAt       time.Time `json:"created_at"`
+	AccountID       string    `json:"account_id"`
+	SHA256          string    `json:"sha256,omitempty"`
+	IsDeleted       bool      `json:"is_deleted"`
+	// Details holds the custom fields attached by enrichers, stored as JSON
+	Details map[string]string `json:"details,omitempty"`
 }
 
 type FileContent struct {
@@ -533,7 +781,11 @@ type FileContent struct {
 	FileChangeID int64
 	Content      string
 	ContentType  string
-	CreatedAt    time.Time
+	// Size is the length in bytes of the full text, of which Content may
+	// hold only a preview
+	Size      int64
+	Truncated bool
+	CreatedAt time.Time
 }
 
 type DailySummary struct {
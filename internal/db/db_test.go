@@ -2,10 +2,17 @@ package db
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
 )
 
 func TestFileContentStorage(t *testing.T) {
@@ -89,3 +96,1407 @@ func TestFileContentStorage(t *testing.T) {
 		t.Errorf("Content mismatch. Expected 'This is a test document', got '%s'", savedContent)
 	}
 }
+
+func TestGetAuthorActivity(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+	yesterday := now.Add(-24 * time.Hour)
+
+	changes := []*FileChange{
+		{FilePath: "/a.txt", ModifiedAt: now, ContentHash: "1", ModifiedByName: "Alice", AccountID: "work"},
+		{FilePath: "/b.txt", ModifiedAt: now, ContentHash: "2", ModifiedByName: "Alice", AccountID: "work"},
+		{FilePath: "/c.txt", ModifiedAt: yesterday, ContentHash: "3", ModifiedByName: "Alice", AccountID: "work"},
+		{FilePath: "/d.txt", ModifiedAt: now, ContentHash: "4", Author: "Bob", AccountID: "home"},
+		{FilePath: "/e.txt", ModifiedAt: now.Add(-30 * 24 * time.Hour), ContentHash: "5", ModifiedByName: "Carol"},
+	}
+	for _, fc := range changes {
+		if err := db.SaveFileChange(ctx, fc); err != nil {
+			t.Fatalf("Failed to save file change: %v", err)
+		}
+	}
+
+	activity, err := db.GetAuthorActivity(ctx, "", now.Add(-7*24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to get author activity: %v", err)
+	}
+
+	totals := make(map[string]int)
+	for _, a := range activity {
+		totals[a.Author] += a.Count
+	}
+	if totals["Alice"] != 3 || totals["Bob"] != 1 {
+		t.Errorf("Unexpected author totals: %v", totals)
+	}
+	if _, ok := totals["Carol"]; ok {
+		t.Errorf("Changes outside the window should be excluded: %v", totals)
+	}
+
+	activity, err = db.GetAuthorActivity(ctx, "home", now.Add(-7*24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to get author activity: %v", err)
+	}
+	if len(activity) != 1 || activity[0].Author != "Bob" {
+		t.Errorf("Expected only Bob's activity for the home account, got %v", activity)
+	}
+}
+
+func TestGetStats(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	day := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC) // a Monday
+	changes := []*FileChange{
+		{FilePath: "/Work/a.docx", ModifiedAt: day.Add(time.Hour), ContentHash: "1", Size: 100, ModifiedByName: "Alice", AccountID: "work"},
+		{FilePath: "/Work/a.docx", ModifiedAt: day.Add(2 * time.Hour), ContentHash: "2", Size: 200, ModifiedByName: "Alice", AccountID: "work"},
+		{FilePath: "/Work/B.PDF", ModifiedAt: day.Add(26 * time.Hour), ContentHash: "3", IsDeleted: true, Author: "Bob", AccountID: "work"},
+		{FilePath: "/Photos/README", ModifiedAt: day.Add(50 * time.Hour), ContentHash: "4", Size: 50, AccountID: "home"},
+		{FilePath: "/Work/old.docx", ModifiedAt: day.AddDate(0, 0, -30), ContentHash: "5", AccountID: "work"},
+	}
+	for _, fc := range changes {
+		if err := db.SaveFileChange(ctx, fc); err != nil {
+			t.Fatalf("Failed to save file change: %v", err)
+		}
+	}
+
+	query := StatsQuery{Since: day, Until: day.AddDate(0, 0, 4), Bucket: StatsDay}
+	timeline, err := db.GetStats(ctx, query)
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if timeline.ChangeStats != (ChangeStats{Changes: 4, Files: 3, Deleted: 1, Bytes: 350}) {
+		t.Errorf("Unexpected totals %+v", timeline.ChangeStats)
+	}
+	if len(timeline.Buckets) != 4 {
+		t.Fatalf("Expected a bucket for each of 4 days, got %d", len(timeline.Buckets))
+	}
+	want := []ChangeStats{{Changes: 2, Files: 1, Bytes: 300}, {Changes: 1, Files: 1, Deleted: 1}, {Changes: 1, Files: 1, Bytes: 50}, {}}
+	for i, bucket := range timeline.Buckets {
+		if !bucket.Start.Equal(day.AddDate(0, 0, i)) || bucket.ChangeStats != want[i] {
+			t.Errorf("Bucket %d = %+v, want %v starting %v", i, bucket, want[i], day.AddDate(0, 0, i))
+		}
+	}
+
+	query.Bucket = StatsWeek
+	weekly, err := db.GetStats(ctx, query)
+	if err != nil {
+		t.Fatalf("Failed to get weekly stats: %v", err)
+	}
+	if len(weekly.Buckets) != 1 || weekly.Buckets[0].Changes != 4 || weekly.Buckets[0].Files != 3 {
+		t.Errorf("Unexpected weekly stats: %+v", weekly)
+	}
+
+	query.Bucket = StatsDay
+	byExtension, err := db.GetStatsBy(ctx, query, StatsByExtension)
+	if err != nil {
+		t.Fatalf("Failed to get stats by extension: %v", err)
+	}
+	names := make([]string, 0, len(byExtension))
+	for _, group := range byExtension {
+		names = append(names, fmt.Sprintf("%s:%d", group.Name, group.Changes))
+	}
+	if got := strings.Join(names, ","); got != ".docx:2,(none):1,.pdf:1" {
+		t.Errorf("Unexpected extension groups %s", got)
+	}
+	if len(byExtension[0].Buckets) != 4 || byExtension[0].Buckets[0].Changes != 2 {
+		t.Errorf("Expected the docx changes in the first of 4 buckets, got %+v", byExtension[0].Buckets)
+	}
+
+	query.AccountID = "work"
+	query.Limit = 1
+	byAuthor, err := db.GetStatsBy(ctx, query, StatsByAuthor)
+	if err != nil {
+		t.Fatalf("Failed to get stats by author: %v", err)
+	}
+	if len(byAuthor) != 1 || byAuthor[0].Name != "Alice" || byAuthor[0].Changes != 2 {
+		t.Errorf("Expected only Alice's changes, got %+v", byAuthor)
+	}
+
+	query = StatsQuery{Since: day, Until: day.AddDate(0, 0, 4), Folders: []string{"/Work"}}
+	byFolder, err := db.GetStatsBy(ctx, query, StatsByFolder)
+	if err != nil {
+		t.Fatalf("Failed to get stats by folder: %v", err)
+	}
+	if len(byFolder) != 2 || byFolder[0].Name != "/Work" || byFolder[0].Changes != 3 || byFolder[1].Name != "/Photos" {
+		t.Errorf("Unexpected folder stats: %+v", byFolder)
+	}
+
+	if _, err := db.GetStats(ctx, StatsQuery{Since: day.AddDate(-1, 0, 0), Until: day, Bucket: StatsHour}); err == nil {
+		t.Error("Expected an error for a window of too many buckets")
+	}
+	if _, err := db.GetStats(ctx, StatsQuery{Since: day, Bucket: "fortnight"}); err == nil {
+		t.Error("Expected an error for an unknown bucket")
+	}
+}
+
+func TestGetFolderActivity(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	changes := []*FileChange{
+		{FilePath: "/Work/Reports/a.docx", ModifiedAt: now, ContentHash: "1", AccountID: "work"},
+		{FilePath: "/work/reports/b.docx", ModifiedAt: now, ContentHash: "2", AccountID: "work"},
+		{FilePath: "/Work/c.docx", ModifiedAt: now, ContentHash: "3", AccountID: "work"},
+		{FilePath: "/Photos/d.jpg", ModifiedAt: now, ContentHash: "4", AccountID: "home"},
+		{FilePath: "/e.txt", ModifiedAt: now, ContentHash: "5", AccountID: "home"},
+		{FilePath: "/Work/f.docx", ModifiedAt: now.Add(-30 * 24 * time.Hour), ContentHash: "6", AccountID: "work"},
+	}
+	for _, fc := range changes {
+		if err := db.SaveFileChange(ctx, fc); err != nil {
+			t.Fatalf("Failed to save file change: %v", err)
+		}
+	}
+
+	activity, err := db.GetFolderActivity(ctx, "", now.Add(-7*24*time.Hour), []string{"/Work", "/Work/Reports"})
+	if err != nil {
+		t.Fatalf("Failed to get folder activity: %v", err)
+	}
+
+	totals := make(map[string]int)
+	for _, a := range activity {
+		totals[a.Folder] += a.Count
+	}
+	expected := map[string]int{"/Work/Reports": 2, "/Work": 1, "/Photos": 1, "/": 1}
+	if !reflect.DeepEqual(totals, expected) {
+		t.Errorf("Expected folder totals %v, got %v", expected, totals)
+	}
+
+	activity, err = db.GetFolderActivity(ctx, "home", now.Add(-7*24*time.Hour), nil)
+	if err != nil {
+		t.Fatalf("Failed to get folder activity: %v", err)
+	}
+	if len(activity) != 2 || activity[0].Folder != "/" || activity[1].Folder != "/Photos" {
+		t.Errorf("Expected the home account's folders, got %v", activity)
+	}
+}
+
+func TestFileChangeModelRoundTrip(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	modified := time.Now().Add(-time.Hour)
+	change := models.FileChange{Path: "/DB/schema.sql", Modified: modified, Size: 42, ModifiedBy: "Alice", AccountID: "work",
+		Details: map[string]string{"ticket": "DB-7"}}
+
+	if err := db.SaveFileChange(ctx, NewFileChangeFromModel(change)); err != nil {
+		t.Fatalf("Failed to save file change: %v", err)
+	}
+
+	// A new version of the same file is stored separately
+	change.Modified = modified.Add(time.Minute)
+	if err := db.SaveFileChange(ctx, NewFileChangeFromModel(change)); err != nil {
+		t.Fatalf("Failed to save file change: %v", err)
+	}
+
+	stored, err := db.GetRecentFileChanges(ctx, modified.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to get recent changes: %v", err)
+	}
+	if len(stored) != 2 {
+		t.Fatalf("Expected 2 stored changes, got %d", len(stored))
+	}
+
+	got := stored[0].ToModel()
+	if got.Path != "/DB/schema.sql" || got.Extension != ".sql" || got.Directory != "/DB" ||
+		got.Size != 42 || got.ModifiedBy != "Alice" || got.AccountID != "work" {
+		t.Errorf("Unexpected round-tripped change: %+v", got)
+	}
+	if got.Details["ticket"] != "DB-7" {
+		t.Errorf("Expected details to round-trip, got %v", got.Details)
+	}
+}
+
+func TestFolderUsage(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	samples := []FolderUsage{
+		{Path: "/Raw-Video", Bytes: 100, ScannedAt: now.Add(-60 * 24 * time.Hour)},
+		{Path: "/Raw-Video", Bytes: 300, ScannedAt: now},
+		{Path: "/Raw-Video", Bytes: 200, ScannedAt: now.Add(-24 * time.Hour)},
+		{Path: "/Docs", Bytes: 50, ScannedAt: now},
+	}
+	for _, u := range samples {
+		if err := db.SaveFolderUsage(ctx, u); err != nil {
+			t.Fatalf("Failed to save folder usage: %v", err)
+		}
+	}
+
+	usage, err := db.GetFolderUsage(ctx, "/Raw-Video", now.Add(-30*24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to get folder usage: %v", err)
+	}
+	if len(usage) != 2 || usage[0].Bytes != 200 || usage[1].Bytes != 300 {
+		t.Errorf("Expected the two recent scans oldest first, got %v", usage)
+	}
+}
+
+func TestTeamEvents(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	events := []models.TeamEvent{
+		{Timestamp: now.Add(-2 * time.Hour), Category: "logins", Type: "login_success", Actor: "Alice", IPAddress: "10.0.0.1"},
+		{Timestamp: now.Add(-48 * time.Hour), Category: "sharing", Type: "shared_link_create", Actor: "Bob"},
+	}
+	if err := db.SaveTeamEvents(ctx, events); err != nil {
+		t.Fatalf("Failed to save team events: %v", err)
+	}
+
+	recent, err := db.GetTeamEventsSince(ctx, now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to get team events: %v", err)
+	}
+	if len(recent) != 1 || recent[0].Type != "login_success" || recent[0].IPAddress != "10.0.0.1" {
+		t.Errorf("Expected only the recent login event, got %v", recent)
+	}
+}
+
+func TestNotificationQueue(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, n := range []models.QueuedNotification{
+		{Channel: "slack", Message: "second", CreatedAt: now},
+		{Channel: "email", Message: "other", CreatedAt: now},
+		{Channel: "slack", Message: "first", CreatedAt: now.Add(-time.Hour), Report: &models.Report{Type: models.HTMLReport, Title: "Weekly"}, ArchiveID: 3},
+	} {
+		if err := db.EnqueueNotification(ctx, n); err != nil {
+			t.Fatalf("Failed to queue notification: %v", err)
+		}
+	}
+
+	pending, err := db.PendingNotifications(ctx, "slack")
+	if err != nil {
+		t.Fatalf("Failed to get pending notifications: %v", err)
+	}
+	if len(pending) != 2 || pending[0].Message != "first" || pending[1].Message != "second" {
+		t.Fatalf("Expected slack notifications oldest first, got %v", pending)
+	}
+	if pending[0].Report == nil || pending[0].Report.Title != "Weekly" || pending[0].ArchiveID != 3 ||
+		pending[1].Report != nil || pending[1].ArchiveID != 0 {
+		t.Errorf("Expected the queued report to be kept, got %+v", pending)
+	}
+
+	next := now.Add(time.Minute).UTC().Truncate(time.Second)
+	if err := db.MarkNotificationFailed(ctx, pending[0].ID, "timeout", next); err != nil {
+		t.Fatalf("Failed to mark notification: %v", err)
+	}
+	if err := db.DeleteNotification(ctx, pending[1].ID); err != nil {
+		t.Fatalf("Failed to delete notification: %v", err)
+	}
+
+	all, err := db.PendingNotifications(ctx, "")
+	if err != nil {
+		t.Fatalf("Failed to get pending notifications: %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("Expected 2 pending notifications, got %v", all)
+	}
+	if all[1].Attempts != 1 || all[1].LastError != "timeout" || !all[1].NextAttemptAt.Equal(next) {
+		t.Errorf("Expected the failed retry to be recorded, got %+v", all[1])
+	}
+
+	if err := db.DeadLetterNotification(ctx, all[1].ID, "gave up"); err != nil {
+		t.Fatalf("Failed to dead-letter notification: %v", err)
+	}
+	if pending, err := db.PendingNotifications(ctx, "slack"); err != nil || len(pending) != 0 {
+		t.Fatalf("Expected dead letters to leave the queue, got %v, %v", pending, err)
+	}
+	dead, err := db.DeadLetteredNotifications(ctx, "")
+	if err != nil {
+		t.Fatalf("Failed to get dead letters: %v", err)
+	}
+	if len(dead) != 1 || !dead[0].DeadLettered || dead[0].Attempts != 2 || dead[0].LastError != "gave up" {
+		t.Fatalf("Expected the dead letter, got %+v", dead)
+	}
+
+	if ok, err := db.RequeueNotification(ctx, all[0].ID); err != nil || ok {
+		t.Errorf("Expected only dead letters to be requeued, got %v, %v", ok, err)
+	}
+	if ok, err := db.RequeueNotification(ctx, dead[0].ID); err != nil || !ok {
+		t.Fatalf("Failed to requeue notification: %v, %v", ok, err)
+	}
+	pending, err = db.PendingNotifications(ctx, "slack")
+	if err != nil {
+		t.Fatalf("Failed to get pending notifications: %v", err)
+	}
+	if len(pending) != 1 || pending[0].Attempts != 0 || !pending[0].NextAttemptAt.IsZero() {
+		t.Errorf("Expected the requeued notification to be due at once, got %+v", pending)
+	}
+}
+
+func TestPendingChanges(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	modified := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	changes := []models.FileChange{
+		{Path: "/docs/a.txt", Modified: modified, Size: 10},
+		{Path: "/docs/new.txt", Modified: modified, ChangeType: models.ChangeRenamed, PreviousPath: "/docs/old.txt"},
+	}
+	if err := db.AddPendingChanges(ctx, "", changes); err != nil {
+		t.Fatalf("Failed to add pending changes: %v", err)
+	}
+	// Changes detected again are stored once per queue
+	if err := db.AddPendingChanges(ctx, "", changes[:1]); err != nil {
+		t.Fatalf("Failed to add pending changes: %v", err)
+	}
+	if err := db.AddPendingChanges(ctx, "contracts", changes[:1]); err != nil {
+		t.Fatalf("Failed to add pending changes: %v", err)
+	}
+
+	pending, err := db.PendingChanges(ctx, "")
+	if err != nil {
+		t.Fatalf("Failed to get pending changes: %v", err)
+	}
+	if len(pending) != 2 || pending[0].Path != "/docs/a.txt" || pending[1].PreviousPath != "/docs/old.txt" || !pending[0].Modified.Equal(modified) {
+		t.Fatalf("Expected the changes in the order added, got %+v", pending)
+	}
+
+	if err := db.RemovePendingChanges(ctx, "", pending[:1]); err != nil {
+		t.Fatalf("Failed to remove pending changes: %v", err)
+	}
+	if pending, err := db.PendingChanges(ctx, ""); err != nil || len(pending) != 1 || pending[0].Path != "/docs/new.txt" {
+		t.Errorf("Expected the unreported change to remain, got %+v, %v", pending, err)
+	}
+	if pending, err := db.PendingChanges(ctx, "contracts"); err != nil || len(pending) != 1 {
+		t.Errorf("Expected other queues to be untouched, got %+v, %v", pending, err)
+	}
+}
+
+func TestReportArchive(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	report := models.NewReport(models.HTMLReport)
+	report.Title = "Weekly"
+	report.GeneratedAt = now.Add(-time.Hour)
+	report.Metadata["content"] = "<p>older</p>"
+	older := models.NewArchivedReport(report, []string{"a@example.com", "slack"})
+	older.Status = models.ReportFailed
+	older.LastError = "smtp down"
+	older.LastAttemptAt = report.GeneratedAt
+	olderID, err := db.ArchiveReport(ctx, older)
+	if err != nil {
+		t.Fatalf("Failed to archive report: %v", err)
+	}
+
+	report.GeneratedAt = now
+	report.Metadata["content"] = "<p>newer</p>"
+	newer := models.NewArchivedReport(report, nil)
+	newer.Status = models.ReportSent
+	if _, err := db.ArchiveReport(ctx, newer); err != nil {
+		t.Fatalf("Failed to archive report: %v", err)
+	}
+
+	reports, err := db.ListReports(ctx, 10)
+	if err != nil {
+		t.Fatalf("Failed to list reports: %v", err)
+	}
+	if len(reports) != 2 || reports[0].Content != "" || reports[1].ID != olderID {
+		t.Fatalf("Expected two reports newest first without content, got %+v", reports)
+	}
+	if got := reports[1]; got.Title != "Weekly" || got.Type != models.HTMLReport || got.Status != models.ReportFailed ||
+		got.LastError != "smtp down" || len(got.Recipients) != 2 || got.Recipients[1] != "slack" {
+		t.Errorf("Unexpected archived report %+v", got)
+	}
+
+	if err := db.UpdateReportDelivery(ctx, olderID, models.ReportSent, "", now); err != nil {
+		t.Fatalf("Failed to update report delivery: %v", err)
+	}
+	got, err := db.GetReport(ctx, olderID)
+	if err != nil {
+		t.Fatalf("Failed to get report: %v", err)
+	}
+	if got == nil || got.Content != "<p>older</p>" || got.Status != models.ReportSent || got.LastError != "" ||
+		got.Attempts != 2 || !got.LastAttemptAt.Equal(now) {
+		t.Errorf("Unexpected report after re-send %+v", got)
+	}
+
+	if err := db.SetReportStatus(ctx, olderID, models.ReportQueued, "timeout"); err != nil {
+		t.Fatalf("Failed to set report status: %v", err)
+	}
+	got, err = db.GetReport(ctx, olderID)
+	if err != nil {
+		t.Fatalf("Failed to get report: %v", err)
+	}
+	if got.Status != models.ReportQueued || got.LastError != "timeout" || got.Attempts != 2 {
+		t.Errorf("Expected the queued status without another attempt, got %+v", got)
+	}
+
+	missing, err := db.GetReport(ctx, olderID+100)
+	if err != nil || missing != nil {
+		t.Errorf("Expected no report for unknown ID, got %+v, %v", missing, err)
+	}
+}
+
+func TestDocumentTexts(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if _, found, err := db.GetDocumentText(ctx, "/notes.md"); err != nil || found {
+		t.Fatalf("Expected no stored text, got found=%v err=%v", found, err)
+	}
+
+	for _, text := range []string{"first", "second"} {
+		if err := db.SaveDocumentText(ctx, "/notes.md", text); err != nil {
+			t.Fatalf("Failed to save document text: %v", err)
+		}
+	}
+
+	text, found, err := db.GetDocumentText(ctx, "/notes.md")
+	if err != nil {
+		t.Fatalf("Failed to get document text: %v", err)
+	}
+	if !found || text != "second" {
+		t.Errorf("Expected the latest text, got %q (found=%v)", text, found)
+	}
+}
+
+func TestDocumentHashes(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if err := db.SaveDocumentText(ctx, "/notes.md", "first"); err != nil {
+		t.Fatalf("Failed to save document text: %v", err)
+	}
+	if _, found, err := db.GetDocumentHash(ctx, "/notes.md"); err != nil || found {
+		t.Fatalf("Expected no stored hash, got found=%v err=%v", found, err)
+	}
+
+	if err := db.SaveDocumentHash(ctx, "/notes.md", "abc"); err != nil {
+		t.Fatalf("Failed to save document hash: %v", err)
+	}
+	if hash, found, err := db.GetDocumentHash(ctx, "/notes.md"); err != nil || !found || hash != "abc" {
+		t.Errorf("Expected stored hash abc, got %q (found=%v, err=%v)", hash, found, err)
+	}
+
+	// Replacing the text clears the hash of the previous version
+	if err := db.SaveDocumentText(ctx, "/notes.md", "second"); err != nil {
+		t.Fatalf("Failed to save document text: %v", err)
+	}
+	if _, found, _ := db.GetDocumentHash(ctx, "/notes.md"); found {
+		t.Error("Expected the hash to be cleared with the text")
+	}
+}
+
+func TestSaveFileChanges(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	db.SetWriteBatchSize(2)
+
+	ctx := context.Background()
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	var changes []FileChange
+	for i, path := range []string{"/a.txt", "/b.txt", "/c.txt", "/a.txt", "/a.txt", "/a.txt"} {
+		change := models.FileChange{Path: path, Modified: day.Add(time.Duration(i) * time.Hour), ContentHash: "hash-" + path}
+		// The second /a.txt repeats the first, then it is deleted and restored
+		if i == 4 {
+			change.IsDeleted = true
+			change.ContentHash = ""
+		}
+		changes = append(changes, *NewFileChangeFromModel(change))
+	}
+	if err := db.SaveFileChanges(ctx, changes); err != nil {
+		t.Fatalf("Failed to save file changes: %v", err)
+	}
+
+	for i, fc := range changes {
+		if fc.ID == 0 {
+			t.Errorf("Expected change %d to get an ID", i)
+		}
+	}
+	if changes[3].ID != changes[0].ID {
+		t.Errorf("Expected the repeated version to get the stored ID %d, got %d", changes[0].ID, changes[3].ID)
+	}
+	if changes[5].ID == changes[0].ID {
+		t.Error("Expected the restored version to be stored again")
+	}
+
+	stored, err := db.GetFileChangesBetween(ctx, day, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to get file changes: %v", err)
+	}
+	if len(stored) != 5 {
+		t.Errorf("Expected 5 stored changes across the batches, got %d", len(stored))
+	}
+
+	if err := db.SaveFileChanges(ctx, nil); err != nil {
+		t.Errorf("Expected saving no changes to succeed, got %v", err)
+	}
+}
+
+func TestSaveFileChange_ContentHashes(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	change := models.FileChange{Path: "/docs/a.txt", Modified: day, ContentHash: "dropbox-hash", SHA256: "local-hash"}
+	for i := 0; i < 2; i++ {
+		// Saving the same content again, even with a new modification time, is skipped
+		change.Modified = day.Add(time.Duration(i) * time.Hour)
+		if err := db.SaveFileChange(ctx, NewFileChangeFromModel(change)); err != nil {
+			t.Fatalf("Failed to save file change: %v", err)
+		}
+	}
+
+	stored, err := db.GetFileChangesBetween(ctx, day, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to get file changes: %v", err)
+	}
+	if len(stored) != 1 {
+		t.Fatalf("Expected 1 stored change, got %d", len(stored))
+	}
+	if stored[0].ContentHash != "dropbox-hash" || stored[0].SHA256 != "local-hash" {
+		t.Errorf("Expected both hashes to be stored, got %q and %q", stored[0].ContentHash, stored[0].SHA256)
+	}
+	if stored[0].ToModel().SHA256 != "local-hash" {
+		t.Error("Expected the local hash to be carried into the model")
+	}
+
+	// The hash of the last version outlives a deletion, for rename detection
+	deleted := models.FileChange{Path: "/docs/a.txt", Modified: day.Add(2 * time.Hour), IsDeleted: true}
+	if err := db.SaveFileChange(ctx, NewFileChangeFromModel(deleted)); err != nil {
+		t.Fatalf("Failed to save deletion: %v", err)
+	}
+	hash, err := db.LastContentHash(ctx, "/docs/a.txt")
+	if err != nil {
+		t.Fatalf("Failed to get content hash: %v", err)
+	}
+	if hash != "dropbox-hash" {
+		t.Errorf("Expected the last content hash, got %q", hash)
+	}
+	if hash, err := db.LastContentHash(ctx, "/docs/unknown.txt"); err != nil || hash != "" {
+		t.Errorf("Expected no hash for an unknown path, got %q, %v", hash, err)
+	}
+}
+
+func TestGetFileChangesBetween(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i, offset := range []time.Duration{-time.Hour, 2 * time.Hour, time.Hour, 24 * time.Hour} {
+		change := models.FileChange{Path: fmt.Sprintf("/docs/file%d.txt", i), Modified: day.Add(offset)}
+		if err := db.SaveFileChange(ctx, NewFileChangeFromModel(change)); err != nil {
+			t.Fatalf("Failed to save file change: %v", err)
+		}
+	}
+
+	changes, err := db.GetFileChangesBetween(ctx, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("Failed to get file changes: %v", err)
+	}
+	if len(changes) != 2 || changes[0].FilePath != "/docs/file2.txt" || changes[1].FilePath != "/docs/file1.txt" {
+		t.Errorf("Expected the two changes of the day oldest first, got %v", changes)
+	}
+}
+
+func TestEmbeddings(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	change := models.FileChange{Path: "/docs/budget.txt", Modified: time.Now(), ContentHash: "v1"}
+	if err := db.SaveFileChange(ctx, NewFileChangeFromModel(change)); err != nil {
+		t.Fatalf("Failed to save file change: %v", err)
+	}
+
+	for path, vector := range map[string]Vector{
+		"/docs/budget.txt": {1, 0, 0},
+		"/docs/plan.txt":   {0.8, 0.6, 0},
+		"/docs/photo.txt":  {0, 0, 1},
+		"/docs/other.txt":  {1, 0},
+	} {
+		if err := db.SaveEmbedding(ctx, path, "v1", "test", vector); err != nil {
+			t.Fatalf("Failed to save embedding: %v", err)
+		}
+	}
+
+	results, err := db.SearchSimilar(ctx, Vector{2, 0, 0}, 2)
+	if err != nil {
+		t.Fatalf("Failed to search embeddings: %v", err)
+	}
+	if len(results) != 2 || results[0].Path != "/docs/budget.txt" || results[1].Path != "/docs/plan.txt" {
+		t.Fatalf("Expected budget then plan, got %v", results)
+	}
+	if results[0].Score < 0.999 || results[1].Score < 0.799 || results[1].Score > 0.801 {
+		t.Errorf("Expected cosine scores of 1 and 0.8, got %v and %v", results[0].Score, results[1].Score)
+	}
+
+	// The stored change of the same version carries the embedding
+	stored, err := db.GetExistingFileChange(ctx, "/docs/budget.txt", "v1")
+	if err != nil || stored == nil {
+		t.Fatalf("Failed to load file change: %v", err)
+	}
+	if !reflect.DeepEqual(stored.Embedding, Vector{1, 0, 0}) {
+		t.Errorf("Expected the change's embedding column to be set, got %v", stored.Embedding)
+	}
+
+	if hash, model, found, err := db.GetEmbeddingHash(ctx, "/docs/plan.txt"); err != nil || !found || hash != "v1" || model != "test" {
+		t.Errorf("Expected hash v1 of model test, got %q %q (found=%v, err=%v)", hash, model, found, err)
+	}
+	if err := db.DeleteEmbedding(ctx, "/docs/plan.txt"); err != nil {
+		t.Fatalf("Failed to delete embedding: %v", err)
+	}
+	if _, _, found, _ := db.GetEmbeddingHash(ctx, "/docs/plan.txt"); found {
+		t.Error("Expected the embedding to be deleted")
+	}
+}
+
+func TestGetTreeAsOf(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	day := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	changes := []models.FileChange{
+		{Path: "/docs/a.txt", Modified: day, Size: 10, ContentHash: "v1"},
+		{Path: "/docs/a.txt", Modified: day.AddDate(0, 0, 1), Size: 20, ContentHash: "v2"},
+		{Path: "/docs/a.txt", Modified: day.AddDate(0, 0, 2), IsDeleted: true},
+		// Restoring the deleted content is a new version
+		{Path: "/docs/a.txt", Modified: day.AddDate(0, 0, 3), Size: 20, ContentHash: "v2"},
+		{Path: "/docs_old/b.txt", Modified: day, Size: 5},
+		{Path: "/docsXold/c.txt", Modified: day, Size: 5},
+	}
+	for _, change := range changes {
+		if err := db.SaveFileChange(ctx, NewFileChangeFromModel(change)); err != nil {
+			t.Fatalf("Failed to save file change: %v", err)
+		}
+	}
+
+	tests := []struct {
+		days    int
+		size    int64
+		deleted bool
+	}{
+		{0, 10, false},
+		{1, 20, false},
+		{2, 0, true},
+		{3, 20, false},
+	}
+	for _, tt := range tests {
+		tree, err := db.GetTreeAsOf(ctx, "", "/docs/", day.AddDate(0, 0, tt.days).Add(time.Hour))
+		if err != nil {
+			t.Fatalf("Failed to get tree: %v", err)
+		}
+		if len(tree) != 1 || tree[0].Path != "/docs/a.txt" {
+			t.Fatalf("Expected only /docs/a.txt after %d days, got %+v", tt.days, tree)
+		}
+		if tree[0].Size != tt.size || tree[0].Deleted != tt.deleted {
+			t.Errorf("After %d days expected size %d and deleted %v, got %+v", tt.days, tt.size, tt.deleted, tree[0])
+		}
+	}
+
+	tree, err := db.GetTreeAsOf(ctx, "", "/docs_old", day.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to get tree: %v", err)
+	}
+	if len(tree) != 1 || tree[0].Path != "/docs_old/b.txt" {
+		t.Errorf("Expected the root to be matched literally, got %+v", tree)
+	}
+
+	tree, err = db.GetTreeAsOf(ctx, "", "/", day.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to get tree: %v", err)
+	}
+	if len(tree) != 0 {
+		t.Errorf("Expected no files before the first change, got %+v", tree)
+	}
+}
+
+func TestInventory(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	day := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	a := models.NewFileMetadata("/Docs/a.txt", 10, day, false)
+	a.Rev, a.ContentHash, a.ModifiedByID = "rev1", "hash1", "dbid:alice"
+	b := models.NewFileMetadata("/Docs/b.txt", 20, day, false)
+	other := models.NewFileMetadata("/Other/c.txt", 30, day, false)
+	for _, file := range []*models.FileMetadata{a, b, other} {
+		if err := db.SaveInventory(ctx, []InventoryFile{NewInventoryFileFromModel(*file, day)}); err != nil {
+			t.Fatalf("Failed to save inventory: %v", err)
+		}
+	}
+
+	// A later sync updates a.txt under a new case and deletes b.txt
+	updated := models.NewFileMetadata("/docs/A.txt", 15, day.Add(time.Hour), false)
+	updated.Rev = "rev2"
+	deleted := models.NewFileMetadata("/Docs/b.txt", 0, day.Add(time.Hour), true)
+	if err := db.SaveInventory(ctx, []InventoryFile{
+		NewInventoryFileFromModel(*updated, day.Add(time.Hour)),
+		NewInventoryFileFromModel(*deleted, day.Add(time.Hour)),
+	}); err != nil {
+		t.Fatalf("Failed to save inventory: %v", err)
+	}
+
+	files, err := db.GetInventory(ctx, "", "/DOCS/")
+	if err != nil {
+		t.Fatalf("Failed to get inventory: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Expected one file under /docs, got %+v", files)
+	}
+	if files[0].Path != "/docs/A.txt" || files[0].Size != 15 || files[0].Rev != "rev2" {
+		t.Errorf("Expected the updated file, got %+v", files[0])
+	}
+	if !files[0].ServerModified.Equal(day.Add(time.Hour)) {
+		t.Errorf("Expected server modified %v, got %v", day.Add(time.Hour), files[0].ServerModified)
+	}
+
+	files, err = db.GetInventory(ctx, "", "/")
+	if err != nil {
+		t.Fatalf("Failed to get inventory: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("Expected two files in total, got %+v", files)
+	}
+}
+
+func TestGetFileChangesAfter(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if latest, err := db.LatestFileChangeID(ctx); err != nil || latest != 0 {
+		t.Fatalf("Expected no latest change, got %d, %v", latest, err)
+	}
+
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i, account := range []string{"work", "home", "work", "work"} {
+		// Older modification times stored later still come after the cursor
+		change := models.FileChange{Path: fmt.Sprintf("/docs/file%d.txt", i), Modified: day.Add(-time.Duration(i) * time.Hour), AccountID: account}
+		if err := db.SaveFileChange(ctx, NewFileChangeFromModel(change)); err != nil {
+			t.Fatalf("Failed to save file change: %v", err)
+		}
+	}
+
+	page, err := db.GetFileChangesAfter(ctx, "work", 0, 2)
+	if err != nil {
+		t.Fatalf("Failed to get file changes: %v", err)
+	}
+	if len(page) != 2 || page[0].FilePath != "/docs/file0.txt" || page[1].FilePath != "/docs/file2.txt" {
+		t.Fatalf("Expected the first two work changes in stored order, got %v", page)
+	}
+
+	page, err = db.GetFileChangesAfter(ctx, "work", page[1].ID, 2)
+	if err != nil {
+		t.Fatalf("Failed to get file changes: %v", err)
+	}
+	if len(page) != 1 || page[0].FilePath != "/docs/file3.txt" {
+		t.Fatalf("Expected the last work change, got %v", page)
+	}
+
+	latest, err := db.LatestFileChangeID(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get latest change: %v", err)
+	}
+	if latest != page[0].ID {
+		t.Errorf("Expected latest change %d, got %d", page[0].ID, latest)
+	}
+}
+
+func TestEmailRecipients(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	if err := db.RecordEmailDelivery(ctx, "Ops@Example.com", now); err != nil {
+		t.Fatalf("Failed to record delivery: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := db.RecordEmailFailure(ctx, "ops@example.com", true, "550 no such user", now); err != nil {
+			t.Fatalf("Failed to record failure: %v", err)
+		}
+	}
+	recipient, err := db.RecordEmailFailure(ctx, "ops@example.com", false, "451 try later", now)
+	if err != nil {
+		t.Fatalf("Failed to record failure: %v", err)
+	}
+	if recipient.Delivered != 1 || recipient.Failed != 3 || recipient.HardBounces != 2 || recipient.ConsecutiveBounces != 2 {
+		t.Errorf("Unexpected counts %+v", recipient)
+	}
+	if recipient.LastError != "451 try later" || recipient.LastDeliveredAt == nil || !recipient.LastDeliveredAt.Equal(now) {
+		t.Errorf("Unexpected last delivery details %+v", recipient)
+	}
+
+	if err := db.SetEmailRecipientDisabled(ctx, "ops@example.com", true, now); err != nil {
+		t.Fatalf("Failed to disable recipient: %v", err)
+	}
+	recipients, err := db.EmailRecipients(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list recipients: %v", err)
+	}
+	if len(recipients) != 1 || !recipients[0].Disabled() {
+		t.Fatalf("Expected one disabled recipient, got %+v", recipients)
+	}
+
+	// Enabling starts a new run and a delivery clears it
+	if err := db.SetEmailRecipientDisabled(ctx, "ops@example.com", false, now); err != nil {
+		t.Fatalf("Failed to enable recipient: %v", err)
+	}
+	recipient, found, err := db.GetEmailRecipient(ctx, "ops@example.com")
+	if err != nil || !found {
+		t.Fatalf("Failed to get recipient: %v", err)
+	}
+	if recipient.Disabled() || recipient.ConsecutiveBounces != 0 || recipient.HardBounces != 2 {
+		t.Errorf("Expected an enabled recipient keeping its history, got %+v", recipient)
+	}
+
+	if _, found, _ := db.GetEmailRecipient(ctx, "new@example.com"); found {
+		t.Error("Expected no record for an unknown address")
+	}
+}
+
+// tableExists reports whether the database has table
+func tableExists(t *testing.T, db *DB, table string) bool {
+	var count int
+	err := db.DB.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to check table %s: %v", table, err)
+	}
+	return count == 1
+}
+
+func TestMigrations(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	migrations, err := db.Migrations()
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	applied, err := db.SchemaVersion(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get schema version: %v", err)
+	}
+	if len(applied) != len(migrations) || applied[len(applied)-1].Version != migrations[len(migrations)-1].Version {
+		t.Fatalf("Expected all %d migrations applied, got %+v", len(migrations), applied)
+	}
+
+	if err := db.MigrateTo(ctx, 1); err != nil {
+		t.Fatalf("Failed to revert to version 1: %v", err)
+	}
+	if tableExists(t, db, "file_inventory") || !tableExists(t, db, "file_changes") {
+		t.Error("Expected only the inventory to be dropped at version 1")
+	}
+
+	if err := db.MigrateTo(ctx, 0); err != nil {
+		t.Fatalf("Failed to revert all migrations: %v", err)
+	}
+	if tableExists(t, db, "file_changes") {
+		t.Error("Expected file_changes to be dropped at version 0")
+	}
+	if applied, _ := db.SchemaVersion(ctx); len(applied) != 0 {
+		t.Errorf("Expected no applied migrations, got %+v", applied)
+	}
+
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if !tableExists(t, db, "file_inventory") {
+		t.Error("Expected the inventory after migrating up again")
+	}
+	if err := db.MigrateTo(ctx, 99); err == nil {
+		t.Error("Expected an error for an unknown version")
+	}
+}
+
+func TestMigrations_AdoptLegacySchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	legacy, err := OpenWithoutMigrations(DriverSQLite, "file:"+dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	// A database created before multi-account support and content hashing
+	_, err = legacy.DB.Exec(`CREATE TABLE file_changes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		file_path TEXT NOT NULL,
+		modified_at DATETIME NOT NULL,
+		file_type TEXT,
+		portfolio TEXT,
+		project TEXT,
+		document_type TEXT,
+		author TEXT,
+		content_hash TEXT,
+		embedding TEXT,
+		dropbox_id TEXT,
+		dropbox_rev TEXT,
+		client_modified DATETIME,
+		server_modified DATETIME,
+		size INTEGER,
+		is_downloadable BOOLEAN,
+		modified_by_id TEXT,
+		modified_by_name TEXT,
+		shared_folder_id TEXT,
+		lock_holder_name TEXT,
+		lock_holder_id TEXT,
+		lock_created_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		t.Fatalf("Failed to create legacy table: %v", err)
+	}
+	legacy.Close()
+
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to migrate legacy database: %v", err)
+	}
+	defer db.Close()
+
+	change := models.FileChange{Path: "/a.txt", Modified: time.Now(), AccountID: "work", Details: map[string]string{"client": "ACME"}}
+	if err := db.SaveFileChange(context.Background(), NewFileChangeFromModel(change)); err != nil {
+		t.Fatalf("Failed to save change after migrating: %v", err)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations(fstest.MapFS{
+		"m/0002_second.up.sql":  {Data: []byte("CREATE TABLE b (id INTEGER);")},
+		"m/0001_first.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER);")},
+		"m/0001_first.down.sql": {Data: []byte("DROP TABLE a;")},
+		"m/README.md":           {Data: []byte("ignored")},
+	}, "m")
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Name != "first" || migrations[0].Down == "" || migrations[1].Down != "" {
+		t.Errorf("Unexpected migrations %+v", migrations)
+	}
+
+	for name, files := range map[string]fstest.MapFS{
+		"unnumbered": {"m/first.up.sql": {Data: []byte("")}},
+		"no up":      {"m/0001_first.down.sql": {Data: []byte("DROP TABLE a;")}},
+		"renamed": {
+			"m/0001_first.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER);")},
+			"m/0001_other.down.sql": {Data: []byte("DROP TABLE a;")},
+		},
+	} {
+		if _, err := loadMigrations(files, "m"); err == nil {
+			t.Errorf("Expected an error for %s migrations", name)
+		}
+	}
+}
+
+func TestMigrations_DriversMatch(t *testing.T) {
+	sqlite, err := loadMigrations(migrationFiles, "migrations/"+DriverSQLite)
+	if err != nil {
+		t.Fatalf("Failed to load SQLite migrations: %v", err)
+	}
+	postgres, err := loadMigrations(migrationFiles, "migrations/"+DriverPostgres)
+	if err != nil {
+		t.Fatalf("Failed to load PostgreSQL migrations: %v", err)
+	}
+	if len(sqlite) != len(postgres) {
+		t.Fatalf("Expected %d PostgreSQL migrations, got %d", len(sqlite), len(postgres))
+	}
+	for i := range sqlite {
+		if sqlite[i].Version != postgres[i].Version || sqlite[i].Name != postgres[i].Name {
+			t.Errorf("Expected PostgreSQL migration %d_%s, got %d_%s",
+				sqlite[i].Version, sqlite[i].Name, postgres[i].Version, postgres[i].Name)
+		}
+		if (sqlite[i].Down == "") != (postgres[i].Down == "") {
+			t.Errorf("Expected migration %d_%s to be reversible on both drivers", sqlite[i].Version, sqlite[i].Name)
+		}
+	}
+}
+
+func TestOpen_UnknownDriver(t *testing.T) {
+	if _, err := Open("mysql", "user@/dropbox"); err == nil {
+		t.Error("Expected an error for an unknown driver")
+	}
+}
+
+func TestRebind(t *testing.T) {
+	for query, want := range map[string]string{
+		`SELECT 1`: `SELECT 1`,
+		`SELECT * FROM t WHERE a = ? AND b IN (?, ?)`:     `SELECT * FROM t WHERE a = $1 AND b IN ($2, $3)`,
+		`SELECT '?' AS q, "odd?" FROM t WHERE a = ?`:      `SELECT '?' AS q, "odd?" FROM t WHERE a = $1`,
+		"SELECT a -- why?\nFROM t WHERE a = ?":            "SELECT a -- why?\nFROM t WHERE a = $1",
+		`SELECT 1 - (embedding <=> ?::vector) LIMIT ?`:    `SELECT 1 - (embedding <=> $1::vector) LIMIT $2`,
+		`SELECT a FROM t WHERE b LIKE ? ESCAPE '\' AND ?`: `SELECT a FROM t WHERE b LIKE $1 ESCAPE '\' AND $2`,
+	} {
+		if got := rebind(query); got != want {
+			t.Errorf("rebind(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	days := func(n int) time.Time { return now.AddDate(0, 0, -n) }
+	changes := []FileChange{
+		// An old version superseded by an old but current one
+		*NewFileChangeFromModel(models.FileChange{Path: "/a.txt", Modified: days(100), ContentHash: "a1"}),
+		*NewFileChangeFromModel(models.FileChange{Path: "/a.txt", Modified: days(95), ContentHash: "a2"}),
+		// A file deleted long ago
+		*NewFileChangeFromModel(models.FileChange{Path: "/b.txt", Modified: days(100), ContentHash: "b1"}),
+		*NewFileChangeFromModel(models.FileChange{Path: "/b.txt", Modified: days(99), IsDeleted: true}),
+		// Recent versions
+		*NewFileChangeFromModel(models.FileChange{Path: "/c.txt", Modified: days(10), ContentHash: "c1"}),
+		*NewFileChangeFromModel(models.FileChange{Path: "/c.txt", Modified: days(5), ContentHash: "c2"}),
+	}
+	if err := db.SaveFileChanges(ctx, changes); err != nil {
+		t.Fatalf("Failed to save file changes: %v", err)
+	}
+	for _, id := range []int64{changes[0].ID, changes[4].ID} {
+		if err := db.SaveFileContent(ctx, &FileContent{FileChangeID: id, Content: "text", ContentType: "text/plain"}); err != nil {
+			t.Fatalf("Failed to save file content: %v", err)
+		}
+	}
+	for _, scanned := range []time.Time{days(40), days(1)} {
+		if err := db.SaveFolderUsage(ctx, FolderUsage{Path: "/", Bytes: 100, ScannedAt: scanned}); err != nil {
+			t.Fatalf("Failed to save folder usage: %v", err)
+		}
+	}
+	if err := db.SaveTeamEvents(ctx, []models.TeamEvent{
+		{Timestamp: days(200), Category: "logins", Type: "login_success"},
+		{Timestamp: days(2), Category: "logins", Type: "login_success"},
+	}); err != nil {
+		t.Fatalf("Failed to save team events: %v", err)
+	}
+
+	policy := RetentionPolicy{FileChanges: 90 * 24 * time.Hour, TeamEvents: 180 * 24 * time.Hour, FolderUsage: 30 * 24 * time.Hour}
+	want := PruneResult{FileChanges: 3, FileContents: 1, TeamEvents: 1, FolderUsage: 1}
+	for _, dryRun := range []bool{true, false} {
+		result, err := db.Prune(ctx, policy, now, dryRun)
+		if err != nil {
+			t.Fatalf("Failed to prune (dry run %v): %v", dryRun, err)
+		}
+		if result != want {
+			t.Errorf("Expected to prune %+v (dry run %v), got %+v", want, dryRun, result)
+		}
+	}
+
+	stored, err := db.GetFileChangesBetween(ctx, days(365), now)
+	if err != nil {
+		t.Fatalf("Failed to get file changes: %v", err)
+	}
+	var paths []string
+	for _, fc := range stored {
+		paths = append(paths, fc.FilePath+"@"+fc.ContentHash)
+	}
+	if strings.Join(paths, ",") != "/a.txt@a2,/c.txt@c1,/c.txt@c2" {
+		t.Errorf("Expected the current and recent versions to remain, got %v", paths)
+	}
+	if usage, _ := db.GetFolderUsage(ctx, "/", days(365)); len(usage) != 1 {
+		t.Errorf("Expected 1 folder usage scan to remain, got %d", len(usage))
+	}
+
+	// Contents are pruned by their own age
+	result, err := db.Prune(ctx, RetentionPolicy{FileContents: 30 * 24 * time.Hour}, time.Now().AddDate(0, 0, 31), false)
+	if err != nil {
+		t.Fatalf("Failed to prune contents: %v", err)
+	}
+	if result.FileContents != 1 || result.Total() != 1 {
+		t.Errorf("Expected the remaining content to be pruned, got %+v", result)
+	}
+
+	if result, err := db.Prune(ctx, RetentionPolicy{}, now, false); err != nil || result.Total() != 0 {
+		t.Errorf("Expected an empty policy to prune nothing, got %+v, %v", result, err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	changes := []FileChange{
+		*NewFileChangeFromModel(models.FileChange{Path: "/docs/budget.txt", Modified: day, ContentHash: "v1"}),
+		*NewFileChangeFromModel(models.FileChange{Path: "/docs/budget.txt", Modified: day.Add(time.Hour), ContentHash: "v2"}),
+		*NewFileChangeFromModel(models.FileChange{Path: "/docs/menu.txt", Modified: day, ContentHash: "m1"}),
+	}
+	if err := db.SaveFileChanges(ctx, changes); err != nil {
+		t.Fatalf("Failed to save file changes: %v", err)
+	}
+	for i, text := range []string{
+		"The travel budget for March is approved",
+		"The travel budget for April is pending",
+		"Lunch menu: soup and bread",
+	} {
+		if err := db.SaveFileContent(ctx, &FileContent{FileChangeID: changes[i].ID, Content: text, ContentType: "text/plain"}); err != nil {
+			t.Fatalf("Failed to save file content: %v", err)
+		}
+	}
+	if err := db.SaveDocumentText(ctx, "/notes/trip.md", "Notes on the travel plans and the budget"); err != nil {
+		t.Fatalf("Failed to save document text: %v", err)
+	}
+
+	matches, err := db.Search(ctx, "travel budget", 10)
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	var paths []string
+	for _, match := range matches {
+		paths = append(paths, match.Path)
+	}
+	sort.Strings(paths)
+	if strings.Join(paths, ",") != "/docs/budget.txt,/notes/trip.md" {
+		t.Errorf("Expected each matching file once, got %v", paths)
+	}
+	for _, match := range matches {
+		if !strings.Contains(match.Snippet, "[budget]") {
+			t.Errorf("Expected the snippet of %s to mark the match, got %q", match.Path, match.Snippet)
+		}
+	}
+
+	// Words are matched wherever they are, and punctuation is not query syntax
+	if matches, err := db.Search(ctx, `soup "menu:`, 10); err != nil || len(matches) != 1 || matches[0].Path != "/docs/menu.txt" {
+		t.Errorf("Expected the menu to match, got %+v, %v", matches, err)
+	}
+	if matches, err := db.Search(ctx, "budget", 1); err != nil || len(matches) != 1 {
+		t.Errorf("Expected the limit to apply, got %+v, %v", matches, err)
+	}
+
+	// Updated and pruned texts leave the index
+	if err := db.SaveDocumentText(ctx, "/notes/trip.md", "Nothing planned"); err != nil {
+		t.Fatalf("Failed to update document text: %v", err)
+	}
+	if _, err := db.Prune(ctx, RetentionPolicy{FileContents: time.Hour}, time.Now().Add(2*time.Hour), false); err != nil {
+		t.Fatalf("Failed to prune contents: %v", err)
+	}
+	if matches, err := db.Search(ctx, "budget", 10); err != nil || len(matches) != 0 {
+		t.Errorf("Expected no matches left, got %+v, %v", matches, err)
+	}
+	if matches, err := db.Search(ctx, "  ", 10); err != nil || matches != nil {
+		t.Errorf("Expected an empty query to match nothing, got %+v, %v", matches, err)
+	}
+}
+
+func TestNewDB_Memory(t *testing.T) {
+	ctx := context.Background()
+	first, err := NewDB(MemoryPath)
+	if err != nil {
+		t.Fatalf("Failed to create in-memory database: %v", err)
+	}
+	defer first.Close()
+	second, err := NewDB(MemoryPath)
+	if err != nil {
+		t.Fatalf("Failed to create second in-memory database: %v", err)
+	}
+	defer second.Close()
+
+	if err := first.SaveFileChange(ctx, NewFileChangeFromModel(models.FileChange{Path: "/a.txt", Modified: time.Now()})); err != nil {
+		t.Fatalf("Failed to save file change: %v", err)
+	}
+	changes, err := second.GetRecentFileChanges(ctx, time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to get file changes: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Expected in-memory databases to be separate, got %d changes", len(changes))
+	}
+	if _, err := os.Stat(MemoryPath); !os.IsNotExist(err) {
+		t.Errorf("Expected no database file, got %v", err)
+	}
+}
+
+func TestContentPolicy_Apply(t *testing.T) {
+	for _, tc := range []struct {
+		policy    ContentPolicy
+		text      string
+		want      string
+		truncated bool
+	}{
+		{ContentPolicy{Mode: ContentFull}, "all of it", "all of it", false},
+		{ContentPolicy{Mode: ContentNone}, "all of it", "", true},
+		{ContentPolicy{Mode: ContentNone}, "", "", false},
+		{ContentPolicy{Mode: ContentPreview, PreviewBytes: 6}, "all of it", "all of", true},
+		{ContentPolicy{Mode: ContentPreview, PreviewBytes: 20}, "all of it", "all of it", false},
+		{ContentPolicy{PreviewBytes: 3}, "café", "caf", true},
+		{ContentPolicy{PreviewBytes: 4}, "café", "caf", true},
+	} {
+		got, truncated := tc.policy.Apply(tc.text)
+		if got != tc.want || truncated != tc.truncated {
+			t.Errorf("%+v.Apply(%q) = %q, %v; want %q, %v", tc.policy, tc.text, got, truncated, tc.want, tc.truncated)
+		}
+	}
+}
+
+func TestContentRevisions(t *testing.T) {
+	db, err := NewDB(MemoryPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	db.SetContentPolicy(ContentPolicy{Mode: ContentPreview, PreviewBytes: 10})
+
+	ctx := context.Background()
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	changes := []FileChange{
+		*NewFileChangeFromModel(models.FileChange{Path: "/notes.txt", Modified: day, ContentHash: "v1"}),
+		*NewFileChangeFromModel(models.FileChange{Path: "/notes.txt", Modified: day.Add(time.Hour), ContentHash: "v2"}),
+	}
+	if err := db.SaveFileChanges(ctx, changes); err != nil {
+		t.Fatalf("Failed to save file changes: %v", err)
+	}
+
+	for _, tc := range []struct {
+		hash string
+		want int64
+	}{{"v1", changes[0].ID}, {"v2", changes[1].ID}, {"", changes[1].ID}} {
+		id, ok, err := db.FileChangeIDForContent(ctx, "/notes.txt", tc.hash)
+		if err != nil || !ok || id != tc.want {
+			t.Errorf("FileChangeIDForContent(%q) = %d, %v, %v; want %d", tc.hash, id, ok, err, tc.want)
+		}
+	}
+	if _, ok, err := db.FileChangeIDForContent(ctx, "/notes.txt", "v3"); err != nil || ok {
+		t.Errorf("Expected no change for an unknown hash, got %v, %v", ok, err)
+	}
+
+	for i, text := range []string{"first draft", "second draft"} {
+		if err := db.SaveFileContent(ctx, &FileContent{FileChangeID: changes[i].ID, Content: text, ContentType: "text/plain"}); err != nil {
+			t.Fatalf("Failed to save file content: %v", err)
+		}
+	}
+	// A change keeps the content first stored with it
+	if err := db.SaveFileContent(ctx, &FileContent{FileChangeID: changes[1].ID, Content: "rewritten"}); err != nil {
+		t.Fatalf("Failed to save file content again: %v", err)
+	}
+
+	revisions, err := db.GetContentRevisions(ctx, "/notes.txt", 10)
+	if err != nil {
+		t.Fatalf("Failed to get content revisions: %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("Expected 2 revisions, got %d", len(revisions))
+	}
+	latest, first := revisions[0], revisions[1]
+	if latest.ContentHash != "v2" || latest.Content != "second dra" || !latest.Truncated || latest.Size != 12 {
+		t.Errorf("Unexpected latest revision %+v", latest)
+	}
+	if first.ContentHash != "v1" || first.Content != "first draf" || !first.Truncated || first.Size != 11 {
+		t.Errorf("Unexpected first revision %+v", first)
+	}
+	if !latest.ModifiedAt.Equal(day.Add(time.Hour)) {
+		t.Errorf("Expected the latest revision to be modified at %v, got %v", day.Add(time.Hour), latest.ModifiedAt)
+	}
+
+	if revisions, err := db.GetContentRevisions(ctx, "/notes.txt", 1); err != nil || len(revisions) != 1 {
+		t.Errorf("Expected the limit to apply, got %d revisions, %v", len(revisions), err)
+	}
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// GetDocumentText returns the last stored text of a document and whether one was found
+func (db *DB) GetDocumentText(ctx context.Context, path string) (string, bool, error) {
+	var text string
+	err := db.DB.QueryRowContext(ctx,
+		`SELECT content FROM document_texts WHERE path = ?`, path).Scan(&text)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("error querying document text: %v", err)
+	}
+	return text, true, nil
+}
+
+// GetDocumentHash returns the Dropbox content hash of the last stored text of
+// a document and whether one was found
+func (db *DB) GetDocumentHash(ctx context.Context, path string) (string, bool, error) {
+	var hash sql.NullString
+	err := db.DB.QueryRowContext(ctx,
+		`SELECT content_hash FROM document_texts WHERE path = ?`, path).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !hash.Valid) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("error querying document hash: %v", err)
+	}
+	return hash.String, true, nil
+}
+
+// SaveDocumentHash records the Dropbox content hash of a document's stored text
+func (db *DB) SaveDocumentHash(ctx context.Context, path, hash string) error {
+	if _, err := db.DB.ExecContext(ctx,
+		`UPDATE document_texts SET content_hash = ? WHERE path = ?`, hash, path); err != nil {
+		return fmt.Errorf("error saving document hash: %v", err)
+	}
+	return nil
+}
+
+// SaveDocumentText stores the current text of a document, replacing the previous version
+func (db *DB) SaveDocumentText(ctx context.Context, path, text string) error {
+	_, err := db.DB.ExecContext(ctx, `
+		INSERT INTO document_texts (path, content, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(path) DO UPDATE SET content = excluded.content, content_hash = NULL, updated_at = excluded.updated_at`,
+		path, text, time.Now())
+	if err != nil {
+		return fmt.Errorf("error saving document text: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// RecordEmailDelivery counts a delivery to address and clears its run of bounces
+func (db *DB) RecordEmailDelivery(ctx context.Context, address string, at time.Time) error {
+	_, err := db.DB.ExecContext(ctx, `
+		INSERT INTO email_recipients (address, delivered, last_delivered_at)
+		VALUES (?, 1, ?)
+		ON CONFLICT(address) DO UPDATE SET
+			delivered = email_recipients.delivered + 1,
+			consecutive_bounces = 0,
+			last_delivered_at = excluded.last_delivered_at`,
+		models.NormalizeEmailAddress(address), at)
+	if err != nil {
+		return fmt.Errorf("error recording email delivery: %v", err)
+	}
+	return nil
+}
+
+// RecordEmailFailure counts a failed delivery to address, and a bounce when
+// hard is set, returning the updated record
+func (db *DB) RecordEmailFailure(ctx context.Context, address string, hard bool, reason string, at time.Time) (models.EmailRecipient, error) {
+	bounce := 0
+	if hard {
+		bounce = 1
+	}
+	_, err := db.DB.ExecContext(ctx, `
+		INSERT INTO email_recipients (address, failed, hard_bounces, consecutive_bounces, last_error, last_failed_at)
+		VALUES (?, 1, ?, ?, ?, ?)
+		ON CONFLICT(address) DO UPDATE SET
+			failed = email_recipients.failed + 1,
+			hard_bounces = email_recipients.hard_bounces + excluded.hard_bounces,
+			consecutive_bounces = email_recipients.consecutive_bounces + excluded.consecutive_bounces,
+			last_error = excluded.last_error,
+			last_failed_at = excluded.last_failed_at`,
+		models.NormalizeEmailAddress(address), bounce, bounce, reason, at)
+	if err != nil {
+		return models.EmailRecipient{}, fmt.Errorf("error recording email failure: %v", err)
+	}
+
+	recipient, _, err := db.GetEmailRecipient(ctx, address)
+	return recipient, err
+}
+
+// SetEmailRecipientDisabled stops or resumes mail to address. Resuming also
+// clears its run of bounces.
+func (db *DB) SetEmailRecipientDisabled(ctx context.Context, address string, disabled bool, at time.Time) error {
+	var disabledAt interface{}
+	if disabled {
+		disabledAt = at
+	}
+	_, err := db.DB.ExecContext(ctx, `
+		INSERT INTO email_recipients (address, disabled_at)
+		VALUES (?, ?)
+		ON CONFLICT(address) DO UPDATE SET
+			disabled_at = excluded.disabled_at,
+			consecutive_bounces = CASE WHEN excluded.disabled_at IS NULL THEN 0 ELSE email_recipients.consecutive_bounces END`,
+		models.NormalizeEmailAddress(address), disabledAt)
+	if err != nil {
+		return fmt.Errorf("error updating email recipient: %v", err)
+	}
+	return nil
+}
+
+// GetEmailRecipient returns the delivery record of address, if there is one
+func (db *DB) GetEmailRecipient(ctx context.Context, address string) (models.EmailRecipient, bool, error) {
+	recipients, err := db.queryEmailRecipients(ctx, `WHERE address = ?`, models.NormalizeEmailAddress(address))
+	if err != nil || len(recipients) == 0 {
+		return models.EmailRecipient{}, false, err
+	}
+	return recipients[0], true, nil
+}
+
+// EmailRecipients returns the delivery records of every address, ordered by address
+func (db *DB) EmailRecipients(ctx context.Context) ([]models.EmailRecipient, error) {
+	return db.queryEmailRecipients(ctx, `ORDER BY address`)
+}
+
+// queryEmailRecipients selects delivery records with the given clause
+func (db *DB) queryEmailRecipients(ctx context.Context, clause string, args ...interface{}) ([]models.EmailRecipient, error) {
+	rows, err := db.DB.QueryContext(ctx, `
+		SELECT address, delivered, failed, hard_bounces, consecutive_bounces,
+			last_error, last_delivered_at, last_failed_at, disabled_at
+		FROM email_recipients `+clause, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying email recipients: %v", err)
+	}
+	defer rows.Close()
+
+	var recipients []models.EmailRecipient
+	for rows.Next() {
+		var r models.EmailRecipient
+		var lastError sql.NullString
+		var delivered, failed, disabled sql.NullTime
+		if err := rows.Scan(&r.Address, &r.Delivered, &r.Failed, &r.HardBounces, &r.ConsecutiveBounces,
+			&lastError, &delivered, &failed, &disabled); err != nil {
+			return nil, fmt.Errorf("error scanning email recipient: %v", err)
+		}
+		r.LastError = lastError.String
+		r.LastDeliveredAt = nullTime(delivered)
+		r.LastFailedAt = nullTime(failed)
+		r.DisabledAt = nullTime(disabled)
+		recipients = append(recipients, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating email recipients: %v", err)
+	}
+	return recipients, nil
+}
+
+// nullTime returns the time of t, or nil when it is null
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
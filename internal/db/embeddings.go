@@ -0,0 +1,169 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// SimilarDocument is a document ranked by the similarity of its embedding
+// to a query
+type SimilarDocument struct {
+	Path      string    `json:"path"`
+	Score     float64   `json:"score"`
+	Model     string    `json:"model"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SaveEmbedding stores the embedding of the current version of a document,
+// replacing the previous one. Stored changes of the same version get the
+// embedding in their embedding column.
+func (db *DB) SaveEmbedding(ctx context.Context, path, contentHash, model string, embedding Vector) error {
+	data, err := json.Marshal(embedding)
+	if err != nil {
+		return fmt.Errorf("error marshaling embedding: %v", err)
+	}
+
+	if _, err := db.DB.ExecContext(ctx, `
+		INSERT INTO document_embeddings (path, embedding, model, content_hash, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(path) DO UPDATE SET embedding = excluded.embedding, model = excluded.model,
+			content_hash = excluded.content_hash, updated_at = excluded.updated_at`,
+		path, string(data), model, contentHash, time.Now()); err != nil {
+		return fmt.Errorf("error saving embedding: %v", err)
+	}
+
+	if contentHash != "" {
+		if _, err := db.DB.ExecContext(ctx,
+			`UPDATE file_changes SET embedding = ? WHERE file_path = ? AND content_hash = ?`,
+			string(data), path, contentHash); err != nil {
+			return fmt.Errorf("error saving embedding of file changes: %v", err)
+		}
+	}
+	return nil
+}
+
+// GetEmbeddingHash returns the content hash and model of a document's stored
+// embedding and whether one was found
+func (db *DB) GetEmbeddingHash(ctx context.Context, path string) (string, string, bool, error) {
+	var hash sql.NullString
+	var model string
+	err := db.DB.QueryRowContext(ctx,
+		`SELECT content_hash, model FROM document_embeddings WHERE path = ?`, path).Scan(&hash, &model)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", false, nil
+	}
+	if err != nil {
+		return "", "", false, fmt.Errorf("error querying embedding hash: %v", err)
+	}
+	return hash.String, model, true, nil
+}
+
+// DeleteEmbedding removes the embedding of a deleted document
+func (db *DB) DeleteEmbedding(ctx context.Context, path string) error {
+	if _, err := db.DB.ExecContext(ctx, `DELETE FROM document_embeddings WHERE path = ?`, path); err != nil {
+		return fmt.Errorf("error deleting embedding: %v", err)
+	}
+	return nil
+}
+
+// SearchSimilar returns the k documents whose embeddings are most similar to
+// query by cosine similarity, best first. Embeddings of another dimension,
+// made by a different model, are skipped.
+func (db *DB) SearchSimilar(ctx context.Context, query Vector, k int) ([]SimilarDocument, error) {
+	if k <= 0 || len(query) == 0 {
+		return nil, nil
+	}
+	queryNorm := norm(query)
+	if queryNorm == 0 {
+		return nil, nil
+	}
+	if db.DBType == Postgres {
+		return db.searchSimilarPostgres(ctx, query, k)
+	}
+
+	rows, err := db.DB.QueryContext(ctx,
+		`SELECT path, embedding, model, updated_at FROM document_embeddings`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying embeddings: %v", err)
+	}
+	defer rows.Close()
+
+	var results []SimilarDocument
+	for rows.Next() {
+		var doc SimilarDocument
+		var embedding Vector
+		if err := rows.Scan(&doc.Path, &embedding, &doc.Model, &doc.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning embedding: %v", err)
+		}
+		if len(embedding) != len(query) {
+			continue
+		}
+		embeddingNorm := norm(embedding)
+		if embeddingNorm == 0 {
+			continue
+		}
+
+		var dot float64
+		for i, v := range embedding {
+			dot += float64(v) * float64(query[i])
+		}
+		doc.Score = dot / (queryNorm * embeddingNorm)
+		results = append(results, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating embeddings: %v", err)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// searchSimilarPostgres ranks the embeddings with pgvector's cosine distance
+// operator so only the best k leave the database
+func (db *DB) searchSimilarPostgres(ctx context.Context, query Vector, k int) ([]SimilarDocument, error) {
+	data, err := json.Marshal(query)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling query embedding: %v", err)
+	}
+
+	rows, err := db.DB.QueryContext(ctx, `
+		SELECT path, 1 - (embedding <=> ?::vector) AS score, model, updated_at
+		FROM document_embeddings
+		WHERE vector_dims(embedding) = ? AND vector_norm(embedding) > 0
+		ORDER BY score DESC
+		LIMIT ?`, string(data), len(query), k)
+	if err != nil {
+		return nil, fmt.Errorf("error querying embeddings: %v", err)
+	}
+	defer rows.Close()
+
+	var results []SimilarDocument
+	for rows.Next() {
+		var doc SimilarDocument
+		if err := rows.Scan(&doc.Path, &doc.Score, &doc.Model, &doc.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning embedding: %v", err)
+		}
+		results = append(results, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating embeddings: %v", err)
+	}
+	return results, nil
+}
+
+// norm returns the Euclidean length of a vector
+func norm(v Vector) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	return math.Sqrt(sum)
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// InventoryFile is the latest known metadata of a file that exists in
+// Dropbox, as recorded by the initial and incremental syncs
+type InventoryFile struct {
+	Path           string    `json:"path"`
+	Size           int64     `json:"size"`
+	Rev            string    `json:"rev,omitempty"`
+	ContentHash    string    `json:"content_hash,omitempty"`
+	ModifiedByID   string    `json:"modified_by_id,omitempty"`
+	ServerModified time.Time `json:"server_modified"`
+	AccountID      string    `json:"account_id,omitempty"`
+	SyncedAt       time.Time `json:"synced_at"`
+	// Deleted removes the file from the inventory when saved
+	Deleted bool `json:"-"`
+}
+
+// SaveInventory records the files seen by a sync in one transaction,
+// replacing what was known about them; deleted files are removed. Paths are
+// matched case-insensitively, as Dropbox does.
+func (db *DB) SaveInventory(ctx context.Context, files []InventoryFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting inventory transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	upsert, err := tx.PrepareContext(ctx, `
+		INSERT INTO file_inventory (account_id, path_lower, path, size, rev, content_hash, modified_by_id, server_modified, synced_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (account_id, path_lower) DO UPDATE SET
+			path = excluded.path, size = excluded.size, rev = excluded.rev,
+			content_hash = excluded.content_hash, modified_by_id = excluded.modified_by_id,
+			server_modified = excluded.server_modified, synced_at = excluded.synced_at`)
+	if err != nil {
+		return fmt.Errorf("error preparing inventory update: %v", err)
+	}
+	defer upsert.Close()
+
+	remove, err := tx.PrepareContext(ctx, `DELETE FROM file_inventory WHERE account_id = ? AND path_lower = ?`)
+	if err != nil {
+		return fmt.Errorf("error preparing inventory removal: %v", err)
+	}
+	defer remove.Close()
+
+	for _, file := range files {
+		key := strings.ToLower(file.Path)
+		if file.Deleted {
+			_, err = remove.ExecContext(ctx, file.AccountID, key)
+		} else {
+			_, err = upsert.ExecContext(ctx, file.AccountID, key, file.Path, file.Size, file.Rev,
+				file.ContentHash, file.ModifiedByID, file.ServerModified, file.SyncedAt)
+		}
+		if err != nil {
+			return fmt.Errorf("error saving inventory entry %s: %v", file.Path, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing inventory: %v", err)
+	}
+	return nil
+}
+
+// GetInventory returns the files known to exist under root, ordered by
+// path. An empty accountID covers all accounts, and an empty root or "/"
+// the whole tree.
+func (db *DB) GetInventory(ctx context.Context, accountID, root string) ([]InventoryFile, error) {
+	query := `
+		SELECT path, size, COALESCE(rev, ''), COALESCE(content_hash, ''), COALESCE(modified_by_id, ''),
+			server_modified, account_id, synced_at
+		FROM file_inventory
+		WHERE 1 = 1`
+	var args []interface{}
+	if root = strings.ToLower(strings.TrimRight(root, "/")); root != "" {
+		query += ` AND (path_lower = ? OR path_lower LIKE ? ESCAPE '\')`
+		args = append(args, root, escapeLike(root)+"/%")
+	}
+	if accountID != "" {
+		query += ` AND account_id = ?`
+		args = append(args, accountID)
+	}
+	query += ` ORDER BY path_lower`
+
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying inventory: %v", err)
+	}
+	defer rows.Close()
+
+	var files []InventoryFile
+	for rows.Next() {
+		var file InventoryFile
+		if err := rows.Scan(&file.Path, &file.Size, &file.Rev, &file.ContentHash, &file.ModifiedByID,
+			&file.ServerModified, &file.AccountID, &file.SyncedAt); err != nil {
+			return nil, fmt.Errorf("error scanning inventory: %v", err)
+		}
+		files = append(files, file)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating inventory: %v", err)
+	}
+	return files, nil
+}
@@ -0,0 +1,286 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds the schema migrations of each database driver in
+// migrations/<driver>, named <version>_<name>.up.sql and
+// <version>_<name>.down.sql. Both drivers share version numbers.
+//
+//go:embed migrations/*/*.sql
+var migrationFiles embed.FS
+
+// Migration is one versioned change to the schema
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// AppliedMigration is a migration recorded in schema_migrations
+type AppliedMigration struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// Migrations returns the migrations for the database's driver ordered by
+// version
+func (db *DB) Migrations() ([]Migration, error) {
+	return loadMigrations(migrationFiles, "migrations/"+db.driverName())
+}
+
+// driverName returns the name of the database's driver
+func (db *DB) driverName() string {
+	if db.DBType == Postgres {
+		return DriverPostgres
+	}
+	return DriverSQLite
+}
+
+// loadMigrations reads the migrations in dir of fsys
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %v", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		prefix, label, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s is not named <version>_<name>.%s.sql", name, direction)
+		}
+
+		content, err := fs.ReadFile(fsys, dir+"/"+name)
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %s: %v", name, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		} else if m.Name != label {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, m.Name, label)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrate applies every migration not yet applied
+func (db *DB) Migrate(ctx context.Context) error {
+	return db.migrate(ctx, -1)
+}
+
+// MigrateTo applies or reverts migrations until the schema is at version;
+// version 0 reverts them all
+func (db *DB) MigrateTo(ctx context.Context, version int) error {
+	if version < 0 {
+		return fmt.Errorf("invalid schema version %d", version)
+	}
+	return db.migrate(ctx, version)
+}
+
+// SchemaVersion returns the migrations applied to the database, oldest first
+func (db *DB) SchemaVersion(ctx context.Context) ([]AppliedMigration, error) {
+	if err := db.ensureMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := db.DB.QueryContext(ctx, `SELECT version, name, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying schema migrations: %v", err)
+	}
+	defer rows.Close()
+
+	var applied []AppliedMigration
+	for rows.Next() {
+		var m AppliedMigration
+		if err := rows.Scan(&m.Version, &m.Name, &m.AppliedAt); err != nil {
+			return nil, fmt.Errorf("error scanning schema migration: %v", err)
+		}
+		applied = append(applied, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating schema migrations: %v", err)
+	}
+	return applied, nil
+}
+
+// migrate moves the schema to target, or to the latest version when target
+// is negative. Each migration runs in its own transaction along with its
+// record in schema_migrations.
+func (db *DB) migrate(ctx context.Context, target int) error {
+	migrations, err := db.Migrations()
+	if err != nil {
+		return err
+	}
+	if err := db.ensureMigrationsTable(ctx); err != nil {
+		return err
+	}
+	if db.DBType == SQLite {
+		if err := upgradeLegacySchema(ctx, db.DB); err != nil {
+			return err
+		}
+	}
+
+	var current int
+	if err := db.DB.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("error reading schema version: %v", err)
+	}
+	if target < 0 && len(migrations) > 0 {
+		target = migrations[len(migrations)-1].Version
+	}
+	if target > 0 && !hasMigration(migrations, target) {
+		return fmt.Errorf("unknown schema version %d", target)
+	}
+
+	if target >= current {
+		for _, m := range migrations {
+			if m.Version <= current || m.Version > target {
+				continue
+			}
+			if err := runMigration(ctx, db.DB, m.Up,
+				`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+				m.Version, m.Name, time.Now()); err != nil {
+				return fmt.Errorf("error applying migration %d_%s: %v", m.Version, m.Name, err)
+			}
+		}
+		return nil
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version > current || m.Version <= target {
+			continue
+		}
+		if m.Down == "" {
+			return fmt.Errorf("migration %d_%s cannot be reverted", m.Version, m.Name)
+		}
+		if err := runMigration(ctx, db.DB, m.Down,
+			`DELETE FROM schema_migrations WHERE version = ?`, m.Version); err != nil {
+			return fmt.Errorf("error reverting migration %d_%s: %v", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+// runMigration runs script and records it with the record statement in one
+// transaction
+func runMigration(ctx context.Context, conn *sql.DB, script, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// hasMigration reports whether migrations include version
+func hasMigration(migrations []Migration, version int) bool {
+	for _, m := range migrations {
+		if m.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureMigrationsTable creates the table recording applied migrations
+func (db *DB) ensureMigrationsTable(ctx context.Context) error {
+	timestamp := "DATETIME"
+	if db.DBType == Postgres {
+		timestamp = "TIMESTAMPTZ"
+	}
+	_, err := db.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at `+timestamp+` NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("error creating schema_migrations table: %v", err)
+	}
+	return nil
+}
+
+// upgradeLegacySchema adds the columns that SQLite databases created before
+// versioned migrations may lack, so the initial migration can adopt them
+func upgradeLegacySchema(ctx context.Context, conn *sql.DB) error {
+	var migrated int
+	if err := conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations`).Scan(&migrated); err != nil {
+		return fmt.Errorf("error reading schema migrations: %v", err)
+	}
+	if migrated > 0 {
+		return nil
+	}
+
+	columns := []struct{ table, column, columnType string }{
+		// Databases created before multi-account support lack account_id,
+		{"file_changes", "account_id", "TEXT"},
+		// those created before content hashing lack the local hash columns,
+		{"file_changes", "sha256", "TEXT"},
+		{"document_texts", "content_hash", "TEXT"},
+		// those created before deletions were stored lack is_deleted
+		{"file_changes", "is_deleted", "BOOLEAN NOT NULL DEFAULT 0"},
+		// and those created before enrichment lack details
+		{"file_changes", "details", "TEXT"},
+	}
+	for _, c := range columns {
+		var exists int
+		err := conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, c.table).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("error checking if table %s exists: %v", c.table, err)
+		}
+		if exists == 0 {
+			continue
+		}
+		if err := ensureColumn(conn, c.table, c.column, c.columnType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
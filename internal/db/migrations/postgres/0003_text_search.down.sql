@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_document_texts_search;
+ALTER TABLE document_texts DROP COLUMN IF EXISTS search_vector;
+DROP INDEX IF EXISTS idx_file_contents_search;
+ALTER TABLE file_contents DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,10 @@
+-- Full-text indexes over stored file contents and extracted document texts.
+-- The simple configuration matches words as written, in any language.
+
+ALTER TABLE file_contents ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (to_tsvector('simple', COALESCE(content, ''))) STORED;
+CREATE INDEX IF NOT EXISTS idx_file_contents_search ON file_contents USING GIN (search_vector);
+
+ALTER TABLE document_texts ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED;
+CREATE INDEX IF NOT EXISTS idx_document_texts_search ON document_texts USING GIN (search_vector);
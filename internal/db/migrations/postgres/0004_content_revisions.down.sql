@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_file_contents_change;
+ALTER TABLE file_contents DROP COLUMN IF EXISTS truncated;
+ALTER TABLE file_contents DROP COLUMN IF EXISTS size;
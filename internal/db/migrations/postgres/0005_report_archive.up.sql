@@ -0,0 +1,22 @@
+-- Every report sent is archived with its rendered content, so past reports
+-- can be browsed, downloaded and re-sent. Recipients are comma-separated.
+
+CREATE TABLE IF NOT EXISTS reports (
+    id BIGSERIAL PRIMARY KEY,
+    report_type TEXT NOT NULL,
+    title TEXT,
+    language TEXT,
+    period TEXT,
+    period_start TIMESTAMPTZ,
+    period_end TIMESTAMPTZ,
+    generated_at TIMESTAMPTZ NOT NULL,
+    total_changes INTEGER NOT NULL DEFAULT 0,
+    recipients TEXT,
+    status TEXT NOT NULL,
+    last_error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    last_attempt_at TIMESTAMPTZ,
+    content TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_reports_generated_at ON reports(generated_at);
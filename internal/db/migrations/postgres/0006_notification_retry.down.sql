@@ -0,0 +1,2 @@
+ALTER TABLE notification_queue DROP COLUMN IF EXISTS dead_lettered;
+ALTER TABLE notification_queue DROP COLUMN IF EXISTS next_attempt_at;
@@ -0,0 +1,5 @@
+-- Queued notifications wait for their backoff to pass before the next
+-- retry, and are dead-lettered once they run out of attempts.
+
+ALTER TABLE notification_queue ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ;
+ALTER TABLE notification_queue ADD COLUMN IF NOT EXISTS dead_lettered BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,13 @@
+-- Changes waiting for the scheduled report, and for each saved query, are
+-- kept until they are reported, so a restart does not lose them and other
+-- processes can preview them. Queue is "" for the scheduled report and the
+-- saved query's name otherwise; a change detected again is stored once.
+
+CREATE TABLE IF NOT EXISTS pending_changes (
+    id BIGSERIAL PRIMARY KEY,
+    queue TEXT NOT NULL,
+    change_key TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (queue, change_key)
+);
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS email_recipients;
+DROP TABLE IF EXISTS document_embeddings;
+DROP TABLE IF EXISTS document_texts;
+DROP TABLE IF EXISTS notification_queue;
+DROP TABLE IF EXISTS team_events;
+DROP TABLE IF EXISTS folder_usage;
+DROP TABLE IF EXISTS sync_state;
+DROP TABLE IF EXISTS daily_summaries;
+DROP TABLE IF EXISTS file_contents;
+DROP TABLE IF EXISTS file_changes;
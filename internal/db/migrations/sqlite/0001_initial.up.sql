@@ -0,0 +1,125 @@
+-- The schema as it stood before versioned migrations. Tables are created only
+-- if missing, so databases created before then are adopted as they are.
+
+CREATE TABLE IF NOT EXISTS file_changes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    file_path TEXT NOT NULL,
+    modified_at DATETIME NOT NULL,
+    file_type TEXT,
+    portfolio TEXT,
+    project TEXT,
+    document_type TEXT,
+    author TEXT,
+    content_hash TEXT,
+    embedding TEXT,
+    dropbox_id TEXT,
+    dropbox_rev TEXT,
+    client_modified DATETIME,
+    server_modified DATETIME,
+    size INTEGER,
+    is_downloadable BOOLEAN,
+    modified_by_id TEXT,
+    modified_by_name TEXT,
+    shared_folder_id TEXT,
+    lock_holder_name TEXT,
+    lock_holder_id TEXT,
+    lock_created_at DATETIME,
+    account_id TEXT,
+    sha256 TEXT,
+    is_deleted BOOLEAN NOT NULL DEFAULT 0,
+    details TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS file_contents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    file_change_id INTEGER NOT NULL,
+    content TEXT,
+    content_type TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (file_change_id) REFERENCES file_changes(id)
+);
+
+CREATE TABLE IF NOT EXISTS daily_summaries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    summary_date DATE NOT NULL,
+    total_files INTEGER NOT NULL,
+    summary TEXT,
+    portfolio_stats TEXT,
+    project_stats TEXT,
+    author_stats TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS sync_state (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    cursor TEXT NOT NULL,
+    last_sync DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS folder_usage (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    path TEXT NOT NULL,
+    bytes INTEGER NOT NULL,
+    scanned_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS team_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    occurred_at DATETIME NOT NULL,
+    category TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    description TEXT,
+    actor TEXT,
+    ip_address TEXT,
+    account_id TEXT,
+    details TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS notification_queue (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    channel TEXT NOT NULL,
+    message TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT
+);
+
+CREATE TABLE IF NOT EXISTS document_texts (
+    path TEXT PRIMARY KEY,
+    content TEXT NOT NULL,
+    content_hash TEXT,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS document_embeddings (
+    path TEXT PRIMARY KEY,
+    embedding TEXT NOT NULL,
+    model TEXT NOT NULL,
+    content_hash TEXT,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS email_recipients (
+    address TEXT PRIMARY KEY,
+    delivered INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    hard_bounces INTEGER NOT NULL DEFAULT 0,
+    consecutive_bounces INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    last_delivered_at DATETIME,
+    last_failed_at DATETIME,
+    disabled_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_changes_file_path ON file_changes(file_path);
+CREATE INDEX IF NOT EXISTS idx_file_changes_modified_at ON file_changes(modified_at);
+CREATE INDEX IF NOT EXISTS idx_file_changes_content_hash ON file_changes(content_hash);
+CREATE INDEX IF NOT EXISTS idx_file_changes_dropbox_id ON file_changes(dropbox_id);
+CREATE INDEX IF NOT EXISTS idx_file_changes_account_id ON file_changes(account_id);
+CREATE INDEX IF NOT EXISTS idx_daily_summaries_date ON daily_summaries(summary_date);
+CREATE INDEX IF NOT EXISTS idx_folder_usage_path_scanned_at ON folder_usage(path, scanned_at);
+CREATE INDEX IF NOT EXISTS idx_team_events_occurred_at ON team_events(occurred_at);
+CREATE INDEX IF NOT EXISTS idx_notification_queue_channel ON notification_queue(channel, created_at);
@@ -0,0 +1,13 @@
+-- The latest metadata of every synced file, keyed case-insensitively by path
+CREATE TABLE IF NOT EXISTS file_inventory (
+    account_id TEXT NOT NULL DEFAULT '',
+    path_lower TEXT NOT NULL,
+    path TEXT NOT NULL,
+    size INTEGER NOT NULL DEFAULT 0,
+    rev TEXT,
+    content_hash TEXT,
+    modified_by_id TEXT,
+    server_modified DATETIME,
+    synced_at DATETIME NOT NULL,
+    PRIMARY KEY (account_id, path_lower)
+);
@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS document_texts_fts_update;
+DROP TRIGGER IF EXISTS document_texts_fts_delete;
+DROP TRIGGER IF EXISTS document_texts_fts_insert;
+DROP TABLE IF EXISTS document_texts_fts;
+DROP TRIGGER IF EXISTS file_contents_fts_update;
+DROP TRIGGER IF EXISTS file_contents_fts_delete;
+DROP TRIGGER IF EXISTS file_contents_fts_insert;
+DROP TABLE IF EXISTS file_contents_fts;
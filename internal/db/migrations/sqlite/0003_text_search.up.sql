@@ -0,0 +1,45 @@
+-- Full-text indexes over stored file contents and extracted document texts.
+-- The FTS5 tables hold only the index; triggers keep them in step with the
+-- tables they index.
+
+CREATE VIRTUAL TABLE IF NOT EXISTS file_contents_fts USING fts5(
+    content,
+    content = 'file_contents',
+    content_rowid = 'id'
+);
+
+CREATE TRIGGER IF NOT EXISTS file_contents_fts_insert AFTER INSERT ON file_contents BEGIN
+    INSERT INTO file_contents_fts (rowid, content) VALUES (new.id, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS file_contents_fts_delete AFTER DELETE ON file_contents BEGIN
+    INSERT INTO file_contents_fts (file_contents_fts, rowid, content) VALUES ('delete', old.id, old.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS file_contents_fts_update AFTER UPDATE OF content ON file_contents BEGIN
+    INSERT INTO file_contents_fts (file_contents_fts, rowid, content) VALUES ('delete', old.id, old.content);
+    INSERT INTO file_contents_fts (rowid, content) VALUES (new.id, new.content);
+END;
+
+CREATE VIRTUAL TABLE IF NOT EXISTS document_texts_fts USING fts5(
+    content,
+    content = 'document_texts',
+    content_rowid = 'rowid'
+);
+
+CREATE TRIGGER IF NOT EXISTS document_texts_fts_insert AFTER INSERT ON document_texts BEGIN
+    INSERT INTO document_texts_fts (rowid, content) VALUES (new.rowid, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS document_texts_fts_delete AFTER DELETE ON document_texts BEGIN
+    INSERT INTO document_texts_fts (document_texts_fts, rowid, content) VALUES ('delete', old.rowid, old.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS document_texts_fts_update AFTER UPDATE OF content ON document_texts BEGIN
+    INSERT INTO document_texts_fts (document_texts_fts, rowid, content) VALUES ('delete', old.rowid, old.content);
+    INSERT INTO document_texts_fts (rowid, content) VALUES (new.rowid, new.content);
+END;
+
+-- Index what was stored before
+INSERT INTO file_contents_fts (file_contents_fts) VALUES ('rebuild');
+INSERT INTO document_texts_fts (document_texts_fts) VALUES ('rebuild');
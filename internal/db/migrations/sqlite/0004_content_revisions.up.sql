@@ -0,0 +1,7 @@
+-- File contents are stored per change, so every revision of a path keeps
+-- its own content. Size is the length of the full text in bytes and
+-- truncated marks contents stored as a shorter preview.
+
+ALTER TABLE file_contents ADD COLUMN size INTEGER DEFAULT 0;
+ALTER TABLE file_contents ADD COLUMN truncated BOOLEAN DEFAULT FALSE;
+CREATE INDEX IF NOT EXISTS idx_file_contents_change ON file_contents(file_change_id);
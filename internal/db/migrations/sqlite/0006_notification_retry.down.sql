@@ -0,0 +1,2 @@
+ALTER TABLE notification_queue DROP COLUMN dead_lettered;
+ALTER TABLE notification_queue DROP COLUMN next_attempt_at;
@@ -0,0 +1,5 @@
+-- Queued reports keep the report itself, encoded as JSON, so retries send
+-- the same email and webhook payload as the first attempt rather than the
+-- text rendering only.
+
+ALTER TABLE notification_queue ADD COLUMN report TEXT;
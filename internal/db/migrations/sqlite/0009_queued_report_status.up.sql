@@ -0,0 +1,4 @@
+-- Queued report deliveries name their archived report, so retries can
+-- record whether the report was delivered or dead-lettered.
+
+ALTER TABLE notification_queue ADD COLUMN archive_id INTEGER;
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// EnqueueNotification stores a notification for a later retry
+func (db *DB) EnqueueNotification(ctx context.Context, n models.QueuedNotification) error {
+	var report sql.NullString
+	if n.Report != nil {
+		data, err := json.Marshal(n.Report)
+		if err != nil {
+			return fmt.Errorf("error encoding queued report: %v", err)
+		}
+		report = sql.NullString{String: string(data), Valid: true}
+	}
+	_, err := db.DB.ExecContext(ctx, `
+		INSERT INTO notification_queue (channel, message, created_at, attempts, last_error, next_attempt_at, dead_lettered, report, archive_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		n.Channel, n.Message, n.CreatedAt, n.Attempts, n.LastError, optionalTime(n.NextAttemptAt), n.DeadLettered, report,
+		sql.NullInt64{Int64: n.ArchiveID, Valid: n.ArchiveID != 0})
+	if err != nil {
+		return fmt.Errorf("error queueing notification: %v", err)
+	}
+	return nil
+}
+
+// PendingNotifications returns the queued notifications for a channel that
+// have not been dead-lettered, oldest first; an empty channel returns those
+// of every channel
+func (db *DB) PendingNotifications(ctx context.Context, channel string) ([]models.QueuedNotification, error) {
+	return db.queuedNotifications(ctx, channel, false)
+}
+
+// DeadLetteredNotifications returns the notifications for a channel that ran
+// out of attempts, oldest first; an empty channel returns those of every
+// channel
+func (db *DB) DeadLetteredNotifications(ctx context.Context, channel string) ([]models.QueuedNotification, error) {
+	return db.queuedNotifications(ctx, channel, true)
+}
+
+// queuedNotifications returns the queued notifications for a channel that
+// are, or are not, dead-lettered
+func (db *DB) queuedNotifications(ctx context.Context, channel string, deadLettered bool) ([]models.QueuedNotification, error) {
+	rows, err := db.DB.QueryContext(ctx, `
+		SELECT id, channel, message, created_at, attempts, last_error, next_attempt_at, dead_lettered, report, archive_id
+		FROM notification_queue
+		WHERE (? = '' OR channel = ?) AND dead_lettered = ?
+		ORDER BY channel, created_at ASC, id ASC`, channel, channel, deadLettered)
+	if err != nil {
+		return nil, fmt.Errorf("error querying notification queue: %v", err)
+	}
+	defer rows.Close()
+
+	var pending []models.QueuedNotification
+	for rows.Next() {
+		var n models.QueuedNotification
+		var lastError, report sql.NullString
+		var nextAttempt sql.NullTime
+		var archiveID sql.NullInt64
+		if err := rows.Scan(&n.ID, &n.Channel, &n.Message, &n.CreatedAt, &n.Attempts, &lastError, &nextAttempt, &n.DeadLettered, &report, &archiveID); err != nil {
+			return nil, fmt.Errorf("error scanning queued notification: %v", err)
+		}
+		n.LastError = lastError.String
+		n.NextAttemptAt = nextAttempt.Time
+		n.ArchiveID = archiveID.Int64
+		if report.Valid {
+			n.Report = &models.Report{}
+			if err := json.Unmarshal([]byte(report.String), n.Report); err != nil {
+				return nil, fmt.Errorf("error decoding queued report %d: %v", n.ID, err)
+			}
+		}
+		pending = append(pending, n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	return pending, nil
+}
+
+// DeleteNotification removes a delivered notification from the queue
+func (db *DB) DeleteNotification(ctx context.Context, id int64) error {
+	if _, err := db.DB.ExecContext(ctx, `DELETE FROM notification_queue WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("error deleting queued notification: %v", err)
+	}
+	return nil
+}
+
+// MarkNotificationFailed records a failed retry of a queued notification,
+// which is next due at next
+func (db *DB) MarkNotificationFailed(ctx context.Context, id int64, lastError string, next time.Time) error {
+	_, err := db.DB.ExecContext(ctx,
+		`UPDATE notification_queue SET attempts = attempts + 1, last_error = ?, next_attempt_at = ? WHERE id = ?`,
+		lastError, optionalTime(next), id)
+	if err != nil {
+		return fmt.Errorf("error updating queued notification: %v", err)
+	}
+	return nil
+}
+
+// DeadLetterNotification records the last failed retry of a queued
+// notification, which is then no longer retried
+func (db *DB) DeadLetterNotification(ctx context.Context, id int64, lastError string) error {
+	_, err := db.DB.ExecContext(ctx,
+		`UPDATE notification_queue SET attempts = attempts + 1, last_error = ?, dead_lettered = TRUE WHERE id = ?`,
+		lastError, id)
+	if err != nil {
+		return fmt.Errorf("error dead-lettering queued notification: %v", err)
+	}
+	return nil
+}
+
+// RequeueNotification returns a dead-lettered notification to the queue with
+// its attempts reset, due at once. It reports whether there was such a
+// notification.
+func (db *DB) RequeueNotification(ctx context.Context, id int64) (bool, error) {
+	res, err := db.DB.ExecContext(ctx,
+		`UPDATE notification_queue SET attempts = 0, next_attempt_at = NULL, dead_lettered = FALSE WHERE id = ? AND dead_lettered = TRUE`,
+		id)
+	if err != nil {
+		return false, fmt.Errorf("error requeueing notification: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error requeueing notification: %v", err)
+	}
+	return n > 0, nil
+}
+
+// optionalTime stores the zero time as null
+func optionalTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// AddPendingChanges stores changes waiting to be reported in queue, ""
+// being the scheduled report. Changes already waiting in queue are skipped.
+func (db *DB) AddPendingChanges(ctx context.Context, queue string, changes []models.FileChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting pending change transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO pending_changes (queue, change_key, payload, created_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(queue, change_key) DO NOTHING`)
+	if err != nil {
+		return fmt.Errorf("error preparing pending change insert: %v", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	for _, change := range changes {
+		payload, err := json.Marshal(change)
+		if err != nil {
+			return fmt.Errorf("error encoding pending change %s: %v", change.Path, err)
+		}
+		if _, err := stmt.ExecContext(ctx, queue, change.Key(), string(payload), now); err != nil {
+			return fmt.Errorf("error saving pending change %s: %v", change.Path, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing pending changes: %v", err)
+	}
+	return nil
+}
+
+// PendingChanges returns the changes waiting to be reported in queue, in
+// the order they were added
+func (db *DB) PendingChanges(ctx context.Context, queue string) ([]models.FileChange, error) {
+	rows, err := db.DB.QueryContext(ctx, `SELECT payload FROM pending_changes WHERE queue = ? ORDER BY id ASC`, queue)
+	if err != nil {
+		return nil, fmt.Errorf("error querying pending changes: %v", err)
+	}
+	defer rows.Close()
+
+	var changes []models.FileChange
+	for rows.Next() {
+		var payload string
+		if err := rows.Scan(&payload); err != nil {
+			return nil, fmt.Errorf("error scanning pending change: %v", err)
+		}
+		var change models.FileChange
+		if err := json.Unmarshal([]byte(payload), &change); err != nil {
+			return nil, fmt.Errorf("error decoding pending change: %v", err)
+		}
+		changes = append(changes, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	return changes, nil
+}
+
+// RemovePendingChanges removes reported changes from queue
+func (db *DB) RemovePendingChanges(ctx context.Context, queue string, changes []models.FileChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting pending change transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `DELETE FROM pending_changes WHERE queue = ? AND change_key = ?`)
+	if err != nil {
+		return fmt.Errorf("error preparing pending change delete: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, change := range changes {
+		if _, err := stmt.ExecContext(ctx, queue, change.Key()); err != nil {
+			return fmt.Errorf("error removing pending change %s: %v", change.Path, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing pending changes: %v", err)
+	}
+	return nil
+}
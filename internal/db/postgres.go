@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// openPostgres opens a PostgreSQL database. Queries are written with the ?
+// placeholders SQLite uses, so connections rewrite them to $1, $2, ...
+func openPostgres(dsn string) (*sql.DB, error) {
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing PostgreSQL connection string: %v", err)
+	}
+	return sql.OpenDB(rebindConnector{connector}), nil
+}
+
+// rebindConnector opens connections that rewrite ? placeholders
+type rebindConnector struct {
+	connector *pq.Connector
+}
+
+// Connect implements driver.Connector
+func (c rebindConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pqc, ok := conn.(postgresConn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected PostgreSQL connection type %T", conn)
+	}
+	return rebindConn{pqc}, nil
+}
+
+// Driver implements driver.Connector
+func (c rebindConnector) Driver() driver.Driver {
+	return c.connector.Driver()
+}
+
+// postgresConn is the part of a pq connection that rebindConn passes on
+type postgresConn interface {
+	driver.Conn
+	driver.ConnPrepareContext
+	driver.ConnBeginTx
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+// rebindConn rewrites the placeholders of every query before passing it on
+type rebindConn struct {
+	postgresConn
+}
+
+func (c rebindConn) Prepare(query string) (driver.Stmt, error) {
+	return c.postgresConn.Prepare(rebind(query))
+}
+
+func (c rebindConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.postgresConn.PrepareContext(ctx, rebind(query))
+}
+
+func (c rebindConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.postgresConn.ExecContext(ctx, rebind(query), args)
+}
+
+func (c rebindConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.postgresConn.QueryContext(ctx, rebind(query), args)
+}
+
+// rebind rewrites the ? placeholders of query to PostgreSQL's numbered
+// ones, leaving question marks in string literals, quoted identifiers and
+// comments alone
+func rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\'' || ch == '"':
+			end := strings.IndexByte(query[i+1:], ch)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 1
+		case ch == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end])
+			i += end - 1
+		case ch == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
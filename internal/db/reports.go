@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// reportColumns are the columns of an archived report listing, without its
+// content
+const reportColumns = `id, report_type, title, language, period, period_start, period_end,
+	generated_at, total_changes, recipients, status, last_error, attempts, last_attempt_at`
+
+// ArchiveReport stores a sent report and returns its ID. Times are stored in
+// UTC, since SQLite orders them as text.
+func (db *DB) ArchiveReport(ctx context.Context, report *models.ArchivedReport) (int64, error) {
+	var id int64
+	err := db.DB.QueryRowContext(ctx, `
+		INSERT INTO reports (report_type, title, language, period, period_start, period_end,
+			generated_at, total_changes, recipients, status, last_error, attempts, last_attempt_at, content)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		string(report.Type), report.Title, report.Language, report.Period, report.Since.UTC(), report.Until.UTC(),
+		report.GeneratedAt.UTC(), report.Changes, strings.Join(report.Recipients, ","), report.Status,
+		report.LastError, report.Attempts, report.LastAttemptAt.UTC(), report.Content).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error archiving report: %v", err)
+	}
+	return id, nil
+}
+
+// ListReports returns up to limit archived reports without their content,
+// newest first
+func (db *DB) ListReports(ctx context.Context, limit int) ([]models.ArchivedReport, error) {
+	rows, err := db.DB.QueryContext(ctx, `
+		SELECT `+reportColumns+`
+		FROM reports
+		ORDER BY generated_at DESC, id DESC
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying reports: %v", err)
+	}
+	defer rows.Close()
+
+	var reports []models.ArchivedReport
+	for rows.Next() {
+		report, err := scanArchivedReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *report)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	return reports, nil
+}
+
+// GetReport returns an archived report with its content, or nil if there is
+// no report with the given ID
+func (db *DB) GetReport(ctx context.Context, id int64) (*models.ArchivedReport, error) {
+	row := db.DB.QueryRowContext(ctx, `
+		SELECT `+reportColumns+`, content
+		FROM reports
+		WHERE id = ?`, id)
+
+	var content sql.NullString
+	report, err := scanArchivedReport(row, &content)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	report.Content = content.String
+	return report, nil
+}
+
+// UpdateReportDelivery records another attempt to send an archived report
+func (db *DB) UpdateReportDelivery(ctx context.Context, id int64, status, lastError string, at time.Time) error {
+	_, err := db.DB.ExecContext(ctx, `
+		UPDATE reports
+		SET status = ?, last_error = ?, attempts = attempts + 1, last_attempt_at = ?
+		WHERE id = ?`,
+		status, lastError, at.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("error updating report delivery: %v", err)
+	}
+	return nil
+}
+
+// SetReportStatus records the delivery status of an archived report without
+// counting another attempt, as queued deliveries progress
+func (db *DB) SetReportStatus(ctx context.Context, id int64, status, lastError string) error {
+	_, err := db.DB.ExecContext(ctx, `UPDATE reports SET status = ?, last_error = ? WHERE id = ?`, status, lastError, id)
+	if err != nil {
+		return fmt.Errorf("error updating report status: %v", err)
+	}
+	return nil
+}
+
+// scanArchivedReport scans the report columns of a row, followed by extra
+func scanArchivedReport(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*models.ArchivedReport, error) {
+	var report models.ArchivedReport
+	var reportType string
+	var title, language, period, recipients, lastError sql.NullString
+	var since, until, lastAttemptAt sql.NullTime
+	dest := append([]interface{}{
+		&report.ID, &reportType, &title, &language, &period, &since, &until,
+		&report.GeneratedAt, &report.Changes, &recipients, &report.Status, &lastError,
+		&report.Attempts, &lastAttemptAt,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error scanning report: %v", err)
+	}
+
+	report.Type = models.ReportType(reportType)
+	report.Title = title.String
+	report.Language = language.String
+	report.Period = period.String
+	report.Since = since.Time
+	report.Until = until.Time
+	report.LastError = lastError.String
+	report.LastAttemptAt = lastAttemptAt.Time
+	if recipients.String != "" {
+		report.Recipients = strings.Split(recipients.String, ",")
+	}
+	return &report, nil
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RetentionPolicy sets how long each table keeps its history; zero keeps it
+// forever
+type RetentionPolicy struct {
+	FileChanges  time.Duration
+	FileContents time.Duration
+	TeamEvents   time.Duration
+	FolderUsage  time.Duration
+}
+
+// Enabled reports whether the policy prunes any table
+func (p RetentionPolicy) Enabled() bool {
+	return p.FileChanges > 0 || p.FileContents > 0 || p.TeamEvents > 0 || p.FolderUsage > 0
+}
+
+// PruneResult counts the rows removed from each table, or that would be
+// removed by a dry run
+type PruneResult struct {
+	FileChanges  int64 `json:"file_changes"`
+	FileContents int64 `json:"file_contents"`
+	TeamEvents   int64 `json:"team_events"`
+	FolderUsage  int64 `json:"folder_usage"`
+}
+
+// Total returns the number of rows removed from all tables
+func (r PruneResult) Total() int64 {
+	return r.FileChanges + r.FileContents + r.TeamEvents + r.FolderUsage
+}
+
+// prunableChanges selects the stored changes older than a cutoff that no
+// longer describe a current file: versions superseded by a later one and
+// deletions. The latest version of every existing file is kept however old,
+// so the current tree and content hashes stay known.
+const prunableChanges = `
+	modified_at < ? AND (
+		COALESCE(is_deleted, FALSE) OR EXISTS (
+			SELECT 1 FROM file_changes newer
+			WHERE newer.file_path = file_changes.file_path
+				AND (newer.modified_at > file_changes.modified_at
+					OR (newer.modified_at = file_changes.modified_at AND newer.id > file_changes.id))
+		)
+	)`
+
+// Prune removes the history that policy no longer keeps as of now, in one
+// transaction. Contents stored with a pruned change are removed with it. A
+// dry run counts the rows without removing them.
+func (db *DB) Prune(ctx context.Context, policy RetentionPolicy, now time.Time, dryRun bool) (PruneResult, error) {
+	var result PruneResult
+	if !policy.Enabled() {
+		return result, nil
+	}
+
+	// SQLite compares times as text, and CURRENT_TIMESTAMP is in UTC
+	now = now.UTC()
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return result, fmt.Errorf("error starting prune transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	exec := func(count *int64, query string, args ...interface{}) error {
+		res, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		*count += n
+		return nil
+	}
+
+	if policy.FileChanges > 0 {
+		cutoff := now.Add(-policy.FileChanges)
+		if err := exec(&result.FileContents, `
+			DELETE FROM file_contents WHERE file_change_id IN (
+				SELECT id FROM file_changes WHERE `+prunableChanges+`
+			)`, cutoff); err != nil {
+			return result, fmt.Errorf("error pruning contents of file changes: %v", err)
+		}
+		if err := exec(&result.FileChanges, `DELETE FROM file_changes WHERE `+prunableChanges, cutoff); err != nil {
+			return result, fmt.Errorf("error pruning file changes: %v", err)
+		}
+	}
+	if policy.FileContents > 0 {
+		if err := exec(&result.FileContents, `DELETE FROM file_contents WHERE created_at < ?`,
+			now.Add(-policy.FileContents)); err != nil {
+			return result, fmt.Errorf("error pruning file contents: %v", err)
+		}
+	}
+	if policy.TeamEvents > 0 {
+		if err := exec(&result.TeamEvents, `DELETE FROM team_events WHERE occurred_at < ?`,
+			now.Add(-policy.TeamEvents)); err != nil {
+			return result, fmt.Errorf("error pruning team events: %v", err)
+		}
+	}
+	if policy.FolderUsage > 0 {
+		if err := exec(&result.FolderUsage, `DELETE FROM folder_usage WHERE scanned_at < ?`,
+			now.Add(-policy.FolderUsage)); err != nil {
+			return result, fmt.Errorf("error pruning folder usage: %v", err)
+		}
+	}
+
+	if dryRun {
+		return result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("error committing prune: %v", err)
+	}
+	return result, nil
+}
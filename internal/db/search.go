@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Result counts for full-text searches
+const (
+	DefaultSearchResults = 20
+	MaxSearchResults     = 100
+)
+
+// TextMatch is a file whose stored text contains the words searched for
+type TextMatch struct {
+	Path string `json:"path"`
+	// Snippet is an excerpt of the text with the matching words in brackets
+	Snippet   string    `json:"snippet"`
+	Score     float64   `json:"score"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Search returns up to limit files whose stored contents or extracted
+// document texts contain every word of query, best match first. Each path
+// is listed once, with its best matching version. Scores rank the results
+// of one search and are not comparable between drivers.
+func (db *DB) Search(ctx context.Context, query string, limit int) ([]TextMatch, error) {
+	if limit <= 0 || strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+
+	contentsQuery, documentsQuery, arg := sqliteContentSearch, sqliteDocumentSearch, interface{}(ftsQuery(query))
+	if db.DBType == Postgres {
+		contentsQuery, documentsQuery, arg = postgresContentSearch, postgresDocumentSearch, query
+	}
+
+	var matches []TextMatch
+	for _, q := range []string{contentsQuery, documentsQuery} {
+		found, err := db.searchText(ctx, q, arg, limit)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, found...)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	seen := make(map[string]bool)
+	results := matches[:0]
+	for _, match := range matches {
+		if seen[match.Path] {
+			continue
+		}
+		seen[match.Path] = true
+		results = append(results, match)
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// searchText runs one of the full-text search queries
+func (db *DB) searchText(ctx context.Context, query string, arg interface{}, limit int) ([]TextMatch, error) {
+	rows, err := db.DB.QueryContext(ctx, query, arg, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error searching text: %v", err)
+	}
+	defer rows.Close()
+
+	var matches []TextMatch
+	for rows.Next() {
+		var match TextMatch
+		if err := rows.Scan(&match.Path, &match.UpdatedAt, &match.Snippet, &match.Score); err != nil {
+			return nil, fmt.Errorf("error scanning text match: %v", err)
+		}
+		matches = append(matches, match)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating text matches: %v", err)
+	}
+	return matches, nil
+}
+
+// The full-text search queries of each driver. They select the path, the
+// time of the matching version, a snippet and a score where higher is
+// better, for a query and a limit.
+const (
+	sqliteContentSearch = `
+		SELECT fc.file_path, fc.modified_at, snippet(file_contents_fts, 0, '[', ']', '...', 12),
+			-bm25(file_contents_fts)
+		FROM file_contents_fts
+		JOIN file_contents c ON c.id = file_contents_fts.rowid
+		JOIN file_changes fc ON fc.id = c.file_change_id
+		WHERE file_contents_fts MATCH ?
+		ORDER BY bm25(file_contents_fts)
+		LIMIT ?`
+	sqliteDocumentSearch = `
+		SELECT d.path, d.updated_at, snippet(document_texts_fts, 0, '[', ']', '...', 12),
+			-bm25(document_texts_fts)
+		FROM document_texts_fts
+		JOIN document_texts d ON d.rowid = document_texts_fts.rowid
+		WHERE document_texts_fts MATCH ?
+		ORDER BY bm25(document_texts_fts)
+		LIMIT ?`
+	postgresContentSearch = `
+		SELECT fc.file_path, fc.modified_at,
+			ts_headline('simple', c.content, q, 'StartSel=[, StopSel=], MaxWords=24, MinWords=8'),
+			ts_rank(c.search_vector, q) AS score
+		FROM file_contents c
+		JOIN file_changes fc ON fc.id = c.file_change_id
+		CROSS JOIN plainto_tsquery('simple', ?) q
+		WHERE c.search_vector @@ q
+		ORDER BY score DESC
+		LIMIT ?`
+	postgresDocumentSearch = `
+		SELECT d.path, d.updated_at,
+			ts_headline('simple', d.content, q, 'StartSel=[, StopSel=], MaxWords=24, MinWords=8'),
+			ts_rank(d.search_vector, q) AS score
+		FROM document_texts d
+		CROSS JOIN plainto_tsquery('simple', ?) q
+		WHERE d.search_vector @@ q
+		ORDER BY score DESC
+		LIMIT ?`
+)
+
+// ftsQuery turns free text into an FTS5 query for the rows containing every
+// word, quoting each word so punctuation is never read as query syntax
+func ftsQuery(text string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
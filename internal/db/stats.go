@@ -0,0 +1,367 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StatsBucket is the span of time change statistics are grouped into
+type StatsBucket string
+
+// Buckets of change statistics
+const (
+	StatsHour  StatsBucket = "hour"
+	StatsDay   StatsBucket = "day"
+	StatsWeek  StatsBucket = "week"
+	StatsMonth StatsBucket = "month"
+)
+
+// MaxStatsBuckets caps the buckets a stats query may span
+const MaxStatsBuckets = 1000
+
+// noExtension names the group of files without an extension
+const noExtension = "(none)"
+
+// ParseStatsBucket returns the bucket named s; an empty name is a day
+func ParseStatsBucket(s string) (StatsBucket, error) {
+	switch bucket := StatsBucket(strings.ToLower(s)); bucket {
+	case "":
+		return StatsDay, nil
+	case StatsHour, StatsDay, StatsWeek, StatsMonth:
+		return bucket, nil
+	}
+	return "", fmt.Errorf("unknown stats bucket %q", s)
+}
+
+// Start returns the start of the bucket containing t, in the location of t.
+// Weeks start on Monday.
+func (b StatsBucket) Start(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch b {
+	case StatsHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case StatsWeek:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case StatsMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	default:
+		return day
+	}
+}
+
+// next returns the start of the bucket after the one starting at start
+func (b StatsBucket) next(start time.Time) time.Time {
+	switch b {
+	case StatsHour:
+		return start.Add(time.Hour)
+	case StatsWeek:
+		return start.AddDate(0, 0, 7)
+	case StatsMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// StatsDimension is what change statistics are grouped by
+type StatsDimension string
+
+const (
+	// StatsByFolder groups changes by the longest of StatsQuery.Folders
+	// containing them, or by their top-level folder
+	StatsByFolder StatsDimension = "folder"
+	// StatsByExtension groups changes by their lower-case file extension
+	StatsByExtension StatsDimension = "extension"
+	// StatsByAuthor groups changes by who made them
+	StatsByAuthor StatsDimension = "author"
+)
+
+// StatsQuery selects the changes aggregated into statistics
+type StatsQuery struct {
+	// AccountID limits the changes to one account; empty includes all
+	AccountID string
+	// Since and Until bound the changes by modification time, as
+	// [Since, Until); a zero Until has no upper bound. Buckets are in the
+	// location of Since.
+	Since, Until time.Time
+	Bucket       StatsBucket
+	// Folders are the folders changes are grouped into by StatsByFolder
+	Folders []string
+	// Limit keeps the groups with the most changes; zero keeps all
+	Limit int
+}
+
+// Validate reports whether the query can be answered
+func (q StatsQuery) Validate() error {
+	if _, err := ParseStatsBucket(string(q.Bucket)); err != nil {
+		return err
+	}
+	if !q.Until.IsZero() && !q.Until.After(q.Since) {
+		return fmt.Errorf("stats window ends before it starts")
+	}
+	if q.Limit < 0 {
+		return fmt.Errorf("stats limit cannot be negative")
+	}
+	if _, err := q.buckets(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// buckets returns the start of every bucket from Since to Until, or to now
+// when Until is zero; it returns none when Since is zero
+func (q StatsQuery) buckets() ([]time.Time, error) {
+	if q.Since.IsZero() {
+		return nil, nil
+	}
+	bucket, _ := ParseStatsBucket(string(q.Bucket))
+	until := q.Until
+	if until.IsZero() {
+		until = time.Now()
+	}
+
+	var starts []time.Time
+	for start := bucket.Start(q.Since); start.Before(until); start = bucket.next(start) {
+		if len(starts) == MaxStatsBuckets {
+			return nil, fmt.Errorf("stats window spans more than %d %s buckets", MaxStatsBuckets, bucket)
+		}
+		starts = append(starts, start)
+	}
+	return starts, nil
+}
+
+// ChangeStats counts a set of changes
+type ChangeStats struct {
+	Changes int `json:"changes"`
+	// Files counts the distinct paths changed
+	Files   int   `json:"files"`
+	Deleted int   `json:"deleted"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// BucketStats counts the changes in the bucket starting at Start
+type BucketStats struct {
+	Start time.Time `json:"start"`
+	ChangeStats
+}
+
+// StatsSeries counts changes in total and per bucket
+type StatsSeries struct {
+	ChangeStats
+	Buckets []BucketStats `json:"buckets"`
+}
+
+// GroupStats counts the changes of one folder, extension or author
+type GroupStats struct {
+	Name string `json:"name"`
+	StatsSeries
+}
+
+// statsCounter accumulates ChangeStats, counting each path once
+type statsCounter struct {
+	ChangeStats
+	files map[string]bool
+}
+
+// add counts a change
+func (c *statsCounter) add(fc FileChange) {
+	if c.files == nil {
+		c.files = make(map[string]bool)
+	}
+	c.Changes++
+	c.Bytes += fc.Size
+	if fc.IsDeleted {
+		c.Deleted++
+	}
+	if key := strings.ToLower(fc.FilePath); !c.files[key] {
+		c.files[key] = true
+		c.Files++
+	}
+}
+
+// timeline counts changes per bucket
+type timeline struct {
+	bucket  StatsBucket
+	loc     *time.Location
+	total   statsCounter
+	buckets map[time.Time]*statsCounter
+}
+
+// newTimeline creates a timeline of the query's buckets
+func newTimeline(q StatsQuery) *timeline {
+	bucket, _ := ParseStatsBucket(string(q.Bucket))
+	return &timeline{bucket: bucket, loc: q.Since.Location(), buckets: make(map[time.Time]*statsCounter)}
+}
+
+// add counts a change in its bucket
+func (t *timeline) add(fc FileChange) {
+	t.total.add(fc)
+	start := t.bucket.Start(fc.ModifiedAt.In(t.loc))
+	counter, ok := t.buckets[start]
+	if !ok {
+		counter = &statsCounter{}
+		t.buckets[start] = counter
+	}
+	counter.add(fc)
+}
+
+// series returns the totals and a bucket for each of starts, and for any
+// other bucket with changes, in time order
+func (t *timeline) series(starts []time.Time) StatsSeries {
+	seen := make(map[time.Time]bool, len(starts))
+	result := make([]BucketStats, 0, len(starts))
+	for _, start := range starts {
+		seen[start] = true
+		bucket := BucketStats{Start: start}
+		if counter, ok := t.buckets[start]; ok {
+			bucket.ChangeStats = counter.ChangeStats
+		}
+		result = append(result, bucket)
+	}
+	for start, counter := range t.buckets {
+		if !seen[start] {
+			result = append(result, BucketStats{Start: start, ChangeStats: counter.ChangeStats})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Start.Before(result[j].Start) })
+	return StatsSeries{ChangeStats: t.total.ChangeStats, Buckets: result}
+}
+
+// Timeline counts changes in total and per bucket of the query. Every
+// bucket of the query's window is included, with or without changes.
+func Timeline(changes []FileChange, q StatsQuery) (StatsSeries, error) {
+	starts, err := q.buckets()
+	if err != nil {
+		return StatsSeries{}, err
+	}
+	t := newTimeline(q)
+	for _, fc := range changes {
+		t.add(fc)
+	}
+	return t.series(starts), nil
+}
+
+// GroupStatsBy counts changes per group of the dimension and per bucket of
+// the query, ordered by the most changes first and then by name
+func GroupStatsBy(changes []FileChange, q StatsQuery, dimension StatsDimension) ([]GroupStats, error) {
+	starts, err := q.buckets()
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]*timeline)
+	for _, fc := range changes {
+		name, err := statsGroup(fc, q, dimension)
+		if err != nil {
+			return nil, err
+		}
+		t, ok := groups[name]
+		if !ok {
+			t = newTimeline(q)
+			groups[name] = t
+		}
+		t.add(fc)
+	}
+
+	result := make([]GroupStats, 0, len(groups))
+	for name, t := range groups {
+		result = append(result, GroupStats{Name: name, StatsSeries: t.series(starts)})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Changes != result[j].Changes {
+			return result[i].Changes > result[j].Changes
+		}
+		return result[i].Name < result[j].Name
+	})
+	if q.Limit > 0 && len(result) > q.Limit {
+		result = result[:q.Limit]
+	}
+	return result, nil
+}
+
+// statsGroup names the group of a change along a dimension
+func statsGroup(fc FileChange, q StatsQuery, dimension StatsDimension) (string, error) {
+	switch dimension {
+	case StatsByFolder:
+		return folderOf(fc.FilePath, q.Folders), nil
+	case StatsByExtension:
+		if ext := strings.ToLower(path.Ext(fc.FilePath)); ext != "" {
+			return ext, nil
+		}
+		return noExtension, nil
+	case StatsByAuthor:
+		switch {
+		case fc.ModifiedByName != "":
+			return fc.ModifiedByName, nil
+		case fc.Author != "":
+			return fc.Author, nil
+		}
+		return unknownAuthor, nil
+	}
+	return "", fmt.Errorf("unknown stats dimension %q", dimension)
+}
+
+// GetStats counts the changes selected by the query in total and per bucket
+func (db *DB) GetStats(ctx context.Context, q StatsQuery) (StatsSeries, error) {
+	changes, err := db.statsChanges(ctx, q)
+	if err != nil {
+		return StatsSeries{}, err
+	}
+	return Timeline(changes, q)
+}
+
+// GetStatsBy counts the changes selected by the query per group of the
+// dimension and per bucket
+func (db *DB) GetStatsBy(ctx context.Context, q StatsQuery, dimension StatsDimension) ([]GroupStats, error) {
+	changes, err := db.statsChanges(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return GroupStatsBy(changes, q, dimension)
+}
+
+// statsChanges loads the columns of the changes selected by the query that
+// statistics are computed from
+func (db *DB) statsChanges(ctx context.Context, q StatsQuery) ([]FileChange, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT file_path, modified_at, size, COALESCE(is_deleted, FALSE),
+			COALESCE(modified_by_name, ''), COALESCE(author, '')
+		FROM file_changes
+		WHERE modified_at >= ?`
+	args := []interface{}{q.Since}
+	if !q.Until.IsZero() {
+		query += ` AND modified_at < ?`
+		args = append(args, q.Until)
+	}
+	if q.AccountID != "" {
+		query += ` AND account_id = ?`
+		args = append(args, q.AccountID)
+	}
+
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying change stats: %v", err)
+	}
+	defer rows.Close()
+
+	var changes []FileChange
+	for rows.Next() {
+		var fc FileChange
+		if err := rows.Scan(&fc.FilePath, &fc.ModifiedAt, &fc.Size, &fc.IsDeleted, &fc.ModifiedByName, &fc.Author); err != nil {
+			return nil, fmt.Errorf("error scanning change stats: %v", err)
+		}
+		changes = append(changes, fc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return changes, nil
+}
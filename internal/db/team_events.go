@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// SaveTeamEvents stores team log events in a single transaction
+func (db *DB) SaveTeamEvents(ctx context.Context, events []models.TeamEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, e := range events {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO team_events (
+				occurred_at, category, event_type, description, actor, ip_address, account_id, details
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			e.Timestamp, e.Category, e.Type, e.Description, e.Actor, e.IPAddress, e.AccountID, e.Details)
+		if err != nil {
+			return fmt.Errorf("error saving team event: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing team events: %v", err)
+	}
+	return nil
+}
+
+// GetTeamEventsSince returns team log events that occurred after the given time, oldest first
+func (db *DB) GetTeamEventsSince(ctx context.Context, since time.Time) ([]models.TeamEvent, error) {
+	rows, err := db.DB.QueryContext(ctx, `
+		SELECT occurred_at, category, event_type, COALESCE(description, ''), COALESCE(actor, ''),
+			COALESCE(ip_address, ''), COALESCE(account_id, ''), COALESCE(details, '')
+		FROM team_events
+		WHERE occurred_at > ?
+		ORDER BY occurred_at ASC`, since)
+	if err != nil {
+		return nil, fmt.Errorf("error querying team events: %v", err)
+	}
+	defer rows.Close()
+
+	var events []models.TeamEvent
+	for rows.Next() {
+		var e models.TeamEvent
+		if err := rows.Scan(&e.Timestamp, &e.Category, &e.Type, &e.Description, &e.Actor,
+			&e.IPAddress, &e.AccountID, &e.Details); err != nil {
+			return nil, fmt.Errorf("error scanning team event: %v", err)
+		}
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TreeEntry is the state of one file at a point in time
+type TreeEntry struct {
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+	ModifiedBy string    `json:"modified_by,omitempty"`
+	AccountID  string    `json:"account_id,omitempty"`
+	Deleted    bool      `json:"deleted,omitempty"`
+}
+
+// GetTreeAsOf reconstructs the files under root as they were at asOf from
+// the stored change history: the latest version of each path changed at or
+// before asOf, ordered by path. Files deleted by then are included with
+// Deleted set. Files that never changed while being monitored are not known
+// and so are missing. An empty accountID covers all accounts, and an empty
+// root or "/" the whole tree.
+func (db *DB) GetTreeAsOf(ctx context.Context, accountID, root string, asOf time.Time) ([]TreeEntry, error) {
+	query := `
+		SELECT file_path, size, modified_at, modified_by, account_id, is_deleted FROM (
+			SELECT file_path, COALESCE(size, 0) AS size, modified_at,
+				COALESCE(NULLIF(modified_by_name, ''), author, '') AS modified_by,
+				COALESCE(account_id, '') AS account_id, COALESCE(is_deleted, FALSE) AS is_deleted,
+				ROW_NUMBER() OVER (PARTITION BY file_path ORDER BY modified_at DESC, id DESC) AS version
+			FROM file_changes
+			WHERE modified_at <= ?`
+	args := []interface{}{asOf}
+	if root = strings.TrimRight(root, "/"); root != "" {
+		query += ` AND (file_path = ? OR file_path LIKE ? ESCAPE '\')`
+		args = append(args, root, escapeLike(root)+"/%")
+	}
+	if accountID != "" {
+		query += ` AND account_id = ?`
+		args = append(args, accountID)
+	}
+	query += `
+		) AS versions WHERE version = 1
+		ORDER BY file_path`
+
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying file tree: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []TreeEntry
+	for rows.Next() {
+		var entry TreeEntry
+		if err := rows.Scan(&entry.Path, &entry.Size, &entry.ModifiedAt, &entry.ModifiedBy, &entry.AccountID, &entry.Deleted); err != nil {
+			return nil, fmt.Errorf("error scanning file tree: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating file tree: %v", err)
+	}
+	return entries, nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
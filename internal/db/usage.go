@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// FolderUsage is the cumulative size of a folder at the time of a scan
+type FolderUsage struct {
+	Path      string    `json:"path"`
+	Bytes     int64     `json:"bytes"`
+	ScannedAt time.Time `json:"scanned_at"`
+}
+
+// SaveFolderUsage records the result of a folder size scan
+func (db *DB) SaveFolderUsage(ctx context.Context, usage FolderUsage) error {
+	_, err := db.DB.ExecContext(ctx,
+		`INSERT INTO folder_usage (path, bytes, scanned_at) VALUES (?, ?, ?)`,
+		usage.Path, usage.Bytes, usage.ScannedAt)
+	if err != nil {
+		return fmt.Errorf("error saving folder usage: %v", err)
+	}
+	return nil
+}
+
+// GetFolderUsage returns the scans of a folder since the given time, oldest first
+func (db *DB) GetFolderUsage(ctx context.Context, path string, since time.Time) ([]FolderUsage, error) {
+	rows, err := db.DB.QueryContext(ctx, `
+		SELECT path, bytes, scanned_at
+		FROM folder_usage
+		WHERE path = ? AND scanned_at > ?
+		ORDER BY scanned_at ASC`, path, since)
+	if err != nil {
+		return nil, fmt.Errorf("error querying folder usage: %v", err)
+	}
+	defer rows.Close()
+
+	var usage []FolderUsage
+	for rows.Next() {
+		var u FolderUsage
+		if err := rows.Scan(&u.Path, &u.Bytes, &u.ScannedAt); err != nil {
+			return nil, fmt.Errorf("error scanning folder usage: %v", err)
+		}
+		usage = append(usage, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	return usage, nil
+}
@@ -0,0 +1,124 @@
+// Package diff summarises how text documents change between reports.
+package diff
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// maxSections caps how many changed sections a summary names
+const maxSections = 5
+
+// htmlHeading matches a single-line HTML heading
+var htmlHeading = regexp.MustCompile(`(?i)^\s*<h[1-6][^>]*>(.*?)</h[1-6]>`)
+
+// htmlTag matches any HTML tag inside a heading
+var htmlTag = regexp.MustCompile(`<[^>]+>`)
+
+// Summarize compares two versions of a document line by line. Lines are
+// matched by content after the unchanged beginning and end are set aside, so
+// a line that only moved is not counted. Changed lines are attributed to the
+// nearest Markdown or HTML heading above them.
+func Summarize(path, previous, current string) models.DiffSummary {
+	oldLines := splitLines(previous)
+	newLines := splitLines(current)
+
+	// Skip the common prefix and suffix
+	start := 0
+	for start < len(oldLines) && start < len(newLines) && oldLines[start] == newLines[start] {
+		start++
+	}
+	oldEnd, newEnd := len(oldLines), len(newLines)
+	for oldEnd > start && newEnd > start && oldLines[oldEnd-1] == newLines[newEnd-1] {
+		oldEnd--
+		newEnd--
+	}
+
+	remaining := make(map[string]int)
+	for _, line := range oldLines[start:oldEnd] {
+		remaining[line]++
+	}
+
+	summary := models.DiffSummary{Path: path}
+	sections := &sectionSet{}
+	newHeadings := headings(newLines)
+	for i := start; i < newEnd; i++ {
+		line := newLines[i]
+		if remaining[line] > 0 {
+			remaining[line]--
+			continue
+		}
+		summary.Added++
+		sections.add(newHeadings[i])
+	}
+
+	oldHeadings := headings(oldLines)
+	for i := start; i < oldEnd; i++ {
+		line := oldLines[i]
+		if remaining[line] > 0 {
+			remaining[line]--
+			summary.Removed++
+			sections.add(oldHeadings[i])
+		}
+	}
+
+	summary.Sections = sections.names
+	return summary
+}
+
+// splitLines splits text into lines, ignoring a trailing newline
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// headings returns, for every line, the heading of the section it belongs to
+func headings(lines []string) []string {
+	result := make([]string, len(lines))
+	current := ""
+	for i, line := range lines {
+		if h := heading(line); h != "" {
+			current = h
+		}
+		result[i] = current
+	}
+	return result
+}
+
+// heading returns the title of a Markdown or HTML heading line
+func heading(line string) string {
+	trimmed := strings.TrimSpace(line)
+	if rest := strings.TrimLeft(trimmed, "#"); rest != trimmed {
+		// "#hashtag" and "#!/bin/sh" are not headings
+		if level := len(trimmed) - len(rest); level > 6 || !strings.HasPrefix(rest, " ") {
+			return ""
+		}
+		return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(rest), "#"))
+	}
+	if m := htmlHeading.FindStringSubmatch(trimmed); m != nil {
+		return strings.TrimSpace(htmlTag.ReplaceAllString(m[1], ""))
+	}
+	return ""
+}
+
+// sectionSet collects distinct section names in the order they are found
+type sectionSet struct {
+	names []string
+	seen  map[string]bool
+}
+
+func (s *sectionSet) add(name string) {
+	if name == "" || s.seen[name] || len(s.names) >= maxSections {
+		return
+	}
+	if s.seen == nil {
+		s.seen = make(map[string]bool)
+	}
+	s.seen[name] = true
+	s.names = append(s.names, name)
+}
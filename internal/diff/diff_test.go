@@ -0,0 +1,197 @@
+package diff
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const previousDoc = `# Plan
+Intro line
+
+## Budget
+Travel: 100
+Equipment: 200
+
+## Timeline
+Start in March
+`
+
+func TestSummarize(t *testing.T) {
+	current := strings.Replace(previousDoc, "Travel: 100", "Travel: 150", 1)
+	current = strings.Replace(current, "Start in March", "Start in April\nFinish in June", 1)
+
+	summary := Summarize("/plan.md", previousDoc, current)
+	assert.Equal(t, 3, summary.Added)
+	assert.Equal(t, 2, summary.Removed)
+	assert.Equal(t, []string{"Budget", "Timeline"}, summary.Sections)
+	assert.Equal(t, "+3 / -2 lines, sections changed: Budget, Timeline", summary.String())
+
+	// Moved lines are not counted
+	moved := Summarize("/a.txt", "one\ntwo\nthree\n", "three\none\ntwo\n")
+	assert.Equal(t, 0, moved.Added)
+	assert.Equal(t, 0, moved.Removed)
+
+	html := Summarize("/a.html", "<h2>Costs</h2>\n<p>1</p>", "<h2>Costs</h2>\n<p>2</p>")
+	assert.Equal(t, []string{"Costs"}, html.Sections)
+
+	plain := Summarize("/a.txt", "", "a\nb\n")
+	assert.Equal(t, "+2 / -0 lines", plain.String())
+}
+
+func TestHeading(t *testing.T) {
+	assert.Equal(t, "Budget", heading("## Budget ##"))
+	assert.Equal(t, "", heading("#hashtag"))
+	assert.Equal(t, "", heading("#!/bin/sh"))
+	assert.Equal(t, "Costs 2024", heading(`<h3 class="x">Costs <em>2024</em></h3>`))
+}
+
+type fakeFetcher struct {
+	files   map[string]string
+	fetched []string
+}
+
+func (f *fakeFetcher) GetFileContent(ctx context.Context, path string) ([]byte, error) {
+	f.fetched = append(f.fetched, path)
+	content, ok := f.files[path]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return []byte(content), nil
+}
+
+type memoryStore map[string]string
+
+func (m memoryStore) GetDocumentText(ctx context.Context, path string) (string, bool, error) {
+	text, ok := m[path]
+	return text, ok, nil
+}
+
+func (m memoryStore) SaveDocumentText(ctx context.Context, path, text string) error {
+	m[path] = text
+	return nil
+}
+
+func TestTracker_Summarize(t *testing.T) {
+	fetcher := &fakeFetcher{files: map[string]string{
+		"/plan.md":   previousDoc,
+		"/new.txt":   "hello\n",
+		"/photo.jpg": "binary",
+		"/data.csv":  "\xff\xfe",
+	}}
+	store := memoryStore{"/plan.md": strings.Replace(previousDoc, "Equipment: 200\n", "", 1)}
+	tracker := NewTracker(fetcher, store, Config{MaxFileBytes: 1024})
+
+	changes := []models.FileChange{
+		{Path: "/plan.md", Extension: ".md", Size: 100},
+		{Path: "/new.txt", Extension: ".txt", Size: 6},
+		{Path: "/photo.jpg", Extension: ".jpg", Size: 6},
+		{Path: "/big.txt", Extension: ".txt", Size: 4096},
+		{Path: "/gone.md", Extension: ".md", IsDeleted: true},
+		{Path: "/data.csv", Extension: ".csv", Size: 2},
+		{Path: "/missing.md", Extension: ".md", Size: 2},
+	}
+
+	summaries := tracker.Summarize(context.Background(), changes)
+	assert.Equal(t, []models.DiffSummary{{Path: "/plan.md", Added: 1, Sections: []string{"Budget"}}}, summaries)
+	assert.ElementsMatch(t, []string{"/missing.md", "/data.csv", "/new.txt", "/plan.md"}, fetcher.fetched)
+
+	// New documents record a baseline; binary content is not stored
+	assert.Equal(t, "hello\n", store["/new.txt"])
+	assert.NotContains(t, store, "/data.csv")
+	assert.Equal(t, previousDoc, store["/plan.md"])
+
+	// Unchanged documents produce no summary
+	assert.Empty(t, tracker.Summarize(context.Background(), changes[:1]))
+}
+
+func TestTracker_MaxFiles(t *testing.T) {
+	fetcher := &fakeFetcher{files: map[string]string{}}
+	tracker := NewTracker(fetcher, memoryStore{}, Config{MaxFiles: 2})
+
+	changes := []models.FileChange{
+		{Path: "/a.txt"}, {Path: "/b.txt"}, {Path: "/c.txt"}, {Path: "/c.txt"},
+	}
+	tracker.Summarize(context.Background(), changes)
+	assert.Equal(t, []string{"/c.txt", "/b.txt"}, fetcher.fetched)
+}
+
+type hashingStore struct {
+	memoryStore
+	hashes map[string]string
+}
+
+func (h hashingStore) GetDocumentHash(ctx context.Context, path string) (string, bool, error) {
+	hash, ok := h.hashes[path]
+	return hash, ok, nil
+}
+
+func (h hashingStore) SaveDocumentHash(ctx context.Context, path, hash string) error {
+	h.hashes[path] = hash
+	return nil
+}
+
+func TestTracker_SkipsUnchangedContentHash(t *testing.T) {
+	fetcher := &fakeFetcher{files: map[string]string{"/notes.md": "hello\n"}}
+	store := hashingStore{memoryStore: memoryStore{}, hashes: map[string]string{}}
+	tracker := NewTracker(fetcher, store, Config{})
+
+	change := models.FileChange{Path: "/notes.md", Extension: ".md", Size: 6, ContentHash: dropbox.ContentHash([]byte("hello\n"))}
+	tracker.Summarize(context.Background(), []models.FileChange{change})
+	assert.Equal(t, change.ContentHash, store.hashes["/notes.md"])
+
+	// The same content hash is not downloaded again
+	tracker.Summarize(context.Background(), []models.FileChange{change})
+	assert.Equal(t, []string{"/notes.md"}, fetcher.fetched)
+
+	// A new hash is
+	fetcher.files["/notes.md"] = "hello again\n"
+	change.ContentHash = dropbox.ContentHash([]byte("hello again\n"))
+	summaries := tracker.Summarize(context.Background(), []models.FileChange{change})
+	assert.Len(t, fetcher.fetched, 2)
+	assert.Len(t, summaries, 1)
+	assert.Equal(t, change.ContentHash, store.hashes["/notes.md"])
+}
+
+// docx builds a Word document with one paragraph per line of text
+func docx(t *testing.T, text string) string {
+	var body strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		body.WriteString("<w:p><w:r><w:t>" + line + "</w:t></w:r></w:p>")
+	}
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.Create("word/document.xml")
+	require.NoError(t, err)
+	_, err = f.Write([]byte(`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		body.String() + `</w:body></w:document>`))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.String()
+}
+
+func TestTracker_ExtractsDocumentText(t *testing.T) {
+	fetcher := &fakeFetcher{files: map[string]string{
+		"/plan.docx":   docx(t, "Budget\nTravel: 150"),
+		"/broken.xlsx": "not a zip",
+	}}
+	store := memoryStore{"/plan.docx": "Budget\nTravel: 100"}
+	tracker := NewTracker(fetcher, store, Config{})
+
+	summaries := tracker.Summarize(context.Background(), []models.FileChange{
+		{Path: "/plan.docx", Extension: ".docx", Size: 100},
+		{Path: "/broken.xlsx", Extension: ".xlsx", Size: 9},
+	})
+	require.Len(t, summaries, 1)
+	assert.Equal(t, "+1 / -1 lines", summaries[0].String())
+	assert.Equal(t, "Budget\nTravel: 150", store["/plan.docx"])
+	assert.NotContains(t, store, "/broken.xlsx")
+}
@@ -0,0 +1,216 @@
+package diff
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/extract"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// Default limits for diff summaries
+const (
+	DefaultMaxFileBytes = 1024 * 1024
+	DefaultMaxFiles     = 20
+)
+
+// DefaultExtensions are the text-like file types summarised when none are configured
+var DefaultExtensions = []string{
+	".txt", ".md", ".markdown", ".rst", ".csv", ".tsv", ".json", ".yaml", ".yml",
+	".xml", ".html", ".htm", ".tex", ".sql", ".go", ".py", ".js", ".ts",
+	".pdf", ".docx", ".xlsx",
+}
+
+// documentExtensions are the file types whose text is extracted before
+// comparing; other types are compared as they are
+var documentExtensions = map[string]bool{".pdf": true, ".docx": true, ".xlsx": true}
+
+// ContentFetcher downloads file contents
+type ContentFetcher interface {
+	GetFileContent(ctx context.Context, path string) ([]byte, error)
+}
+
+// Store keeps the last seen text of each document
+type Store interface {
+	GetDocumentText(ctx context.Context, path string) (string, bool, error)
+	SaveDocumentText(ctx context.Context, path, text string) error
+}
+
+// HashStore is implemented by stores that also keep the Dropbox content hash
+// of each stored text. Documents whose hash is unchanged are then skipped
+// without downloading them.
+type HashStore interface {
+	GetDocumentHash(ctx context.Context, path string) (string, bool, error)
+	SaveDocumentHash(ctx context.Context, path, hash string) error
+}
+
+// Config limits which documents are summarised
+type Config struct {
+	// MaxFileBytes skips files larger than this
+	MaxFileBytes int64
+	// MaxFiles caps how many documents are downloaded per report
+	MaxFiles int
+	// Extensions lists the file types treated as text
+	Extensions []string
+}
+
+// Tracker summarises changed documents against the text seen last time
+type Tracker struct {
+	fetcher    ContentFetcher
+	store      Store
+	config     Config
+	extensions map[string]bool
+	logger     *slog.Logger
+}
+
+// NewTracker creates a tracker; zero limits fall back to the defaults
+func NewTracker(fetcher ContentFetcher, store Store, cfg Config) *Tracker {
+	if cfg.MaxFileBytes <= 0 {
+		cfg.MaxFileBytes = DefaultMaxFileBytes
+	}
+	if cfg.MaxFiles <= 0 {
+		cfg.MaxFiles = DefaultMaxFiles
+	}
+	if len(cfg.Extensions) == 0 {
+		cfg.Extensions = DefaultExtensions
+	}
+
+	extensions := make(map[string]bool, len(cfg.Extensions))
+	for _, ext := range cfg.Extensions {
+		ext = strings.ToLower(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		extensions[ext] = true
+	}
+
+	return &Tracker{fetcher: fetcher, store: store, config: cfg, extensions: extensions, logger: logging.Component(nil, "diff")}
+}
+
+// Summarize downloads the text-like documents among changes, compares each
+// with its previously stored text and stores the new version. Documents seen
+// for the first time only record a baseline. Failures are logged and skipped
+// so that a report is never held back by a summary.
+func (t *Tracker) Summarize(ctx context.Context, changes []models.FileChange) []models.DiffSummary {
+	var summaries []models.DiffSummary
+	seen := make(map[string]bool)
+	fetched := 0
+
+	// The newest change of each path is last
+	for i := len(changes) - 1; i >= 0 && fetched < t.config.MaxFiles; i-- {
+		change := changes[i]
+		if seen[change.Path] {
+			continue
+		}
+		seen[change.Path] = true
+		if !t.eligible(change) {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if t.unchanged(ctx, change) {
+			continue
+		}
+
+		fetched++
+		summary, ok, err := t.summarize(ctx, change.Path)
+		if err != nil {
+			t.logger.Warn("Skipping diff summary", "path", change.Path, "error", err)
+			continue
+		}
+		if ok {
+			summaries = append(summaries, summary)
+		}
+	}
+
+	// Restore report order
+	for i, j := 0, len(summaries)-1; i < j; i, j = i+1, j-1 {
+		summaries[i], summaries[j] = summaries[j], summaries[i]
+	}
+	return summaries
+}
+
+// eligible reports whether a change is a text document within the size limit
+func (t *Tracker) eligible(change models.FileChange) bool {
+	if change.IsDeleted || change.Size > t.config.MaxFileBytes {
+		return false
+	}
+	ext := change.Extension
+	if ext == "" {
+		ext = filepath.Ext(change.Path)
+	}
+	return t.extensions[strings.ToLower(ext)]
+}
+
+// unchanged reports whether the stored text of a change's document has the
+// same content hash as the change
+func (t *Tracker) unchanged(ctx context.Context, change models.FileChange) bool {
+	hashes, ok := t.store.(HashStore)
+	if !ok || change.ContentHash == "" {
+		return false
+	}
+	hash, found, err := hashes.GetDocumentHash(ctx, change.Path)
+	if err != nil {
+		t.logger.Error("Failed to load content hash", "path", change.Path, "error", err)
+		return false
+	}
+	return found && hash == change.ContentHash
+}
+
+// summarize compares one document with its stored text
+func (t *Tracker) summarize(ctx context.Context, path string) (models.DiffSummary, bool, error) {
+	content, err := t.fetcher.GetFileContent(ctx, path)
+	if err != nil {
+		return models.DiffSummary{}, false, err
+	}
+	if int64(len(content)) > t.config.MaxFileBytes {
+		return models.DiffSummary{}, false, nil
+	}
+	current, ok, err := documentText(ctx, path, content)
+	if err != nil || !ok {
+		return models.DiffSummary{}, false, err
+	}
+
+	previous, found, err := t.store.GetDocumentText(ctx, path)
+	if err != nil {
+		return models.DiffSummary{}, false, err
+	}
+	if !found || previous != current {
+		if err := t.store.SaveDocumentText(ctx, path, current); err != nil {
+			return models.DiffSummary{}, false, err
+		}
+	}
+	if hashes, ok := t.store.(HashStore); ok {
+		if err := hashes.SaveDocumentHash(ctx, path, dropbox.ContentHash(content)); err != nil {
+			return models.DiffSummary{}, false, err
+		}
+	}
+	if !found || previous == current {
+		return models.DiffSummary{}, false, nil
+	}
+
+	return Summarize(path, previous, current), true, nil
+}
+
+// documentText returns the text of a document: extracted for PDF, DOCX and
+// XLSX files and the content itself for text files, which are skipped when
+// they are not valid UTF-8
+func documentText(ctx context.Context, path string, content []byte) (string, bool, error) {
+	if documentExtensions[strings.ToLower(filepath.Ext(path))] {
+		text, err := extract.Default.Extract(ctx, path, content)
+		if err != nil {
+			return "", false, err
+		}
+		return text, true, nil
+	}
+	if !utf8.Valid(content) {
+		return "", false, nil
+	}
+	return string(content), true, nil
+}
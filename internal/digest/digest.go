@@ -0,0 +1,331 @@
+// Package digest aggregates stored file changes into daily and weekly digests.
+package digest
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/i18n"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// Period is the span a digest covers
+type Period string
+
+const (
+	// Daily digests cover the previous calendar day
+	Daily Period = "daily"
+	// Weekly digests cover the seven days before today
+	Weekly Period = "weekly"
+)
+
+// Days returns how many days the period covers
+func (p Period) Days() int {
+	if p == Weekly {
+		return 7
+	}
+	return 1
+}
+
+// Interval is how often a digest for the period is sent
+func (p Period) Interval() time.Duration {
+	return time.Duration(p.Days()) * 24 * time.Hour
+}
+
+// maxRanked caps the entries listed in each section of a digest
+const maxRanked = 10
+
+// Count is a name with the number of changes attributed to it
+type Count struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Summary holds the aggregated statistics of a digest
+type Summary struct {
+	Period       Period
+	Since        time.Time
+	Until        time.Time
+	TotalChanges int
+	TotalFiles   int
+	TotalBytes   int64
+	Portfolios   map[string]int
+	Projects     map[string]int
+	Authors      map[string]int
+	// FileTypes counts the changes per file extension
+	FileTypes map[string]int
+	// Days counts the changes on each day of the period
+	Days    []db.BucketStats
+	Changes []models.FileChange
+	// Language is the tag of the language the digest is rendered in;
+	// empty is English
+	Language string
+}
+
+// Aggregate summarises the stored changes made between since and until.
+// Changes without a recorded portfolio or project take them from the first
+// and second folders of their path, e.g. /Portfolio/Project/file.docx.
+// Authors, file types and days are counted as by the stats API.
+func Aggregate(period Period, since, until time.Time, changes []db.FileChange) *Summary {
+	s := &Summary{
+		Period:     period,
+		Since:      since,
+		Until:      until,
+		Portfolios: make(map[string]int),
+		Projects:   make(map[string]int),
+		Changes:    make([]models.FileChange, 0, len(changes)),
+	}
+
+	files := make(map[string]bool)
+	for _, fc := range changes {
+		change := fc.ToModel()
+		s.Changes = append(s.Changes, change)
+		s.TotalChanges++
+		s.TotalBytes += fc.Size
+		files[fc.FilePath] = true
+
+		portfolio, project := portfolioAndProject(fc)
+		if portfolio != "" {
+			s.Portfolios[portfolio]++
+		}
+		if project != "" {
+			s.Projects[project]++
+		}
+	}
+	s.TotalFiles = len(files)
+
+	// A digest spans at most a week of day buckets, well within
+	// db.MaxStatsBuckets
+	timeline, _ := db.Timeline(changes, db.StatsQuery{Since: since, Until: until, Bucket: db.StatsDay})
+	s.Days = timeline.Buckets
+	s.Authors = groupCounts(changes, db.StatsByAuthor)
+	s.FileTypes = groupCounts(changes, db.StatsByExtension)
+
+	return s
+}
+
+// groupCounts counts the changes per group of a dimension
+func groupCounts(changes []db.FileChange, dimension db.StatsDimension) map[string]int {
+	counts := make(map[string]int)
+	groups, _ := db.GroupStatsBy(changes, db.StatsQuery{}, dimension)
+	for _, group := range groups {
+		counts[group.Name] = group.Changes
+	}
+	return counts
+}
+
+// portfolioAndProject returns the portfolio and project of a stored change
+func portfolioAndProject(fc db.FileChange) (string, string) {
+	portfolio, project := fc.Portfolio, fc.Project
+	folders := strings.Split(strings.Trim(fc.FilePath, "/"), "/")
+	folders = folders[:len(folders)-1]
+	if portfolio == "" && len(folders) > 0 {
+		portfolio = folders[0]
+	}
+	if project == "" && len(folders) > 1 {
+		project = folders[1]
+	}
+	return portfolio, project
+}
+
+// catalog returns the catalog of the digest's language
+func (s *Summary) catalog() *i18n.Catalog {
+	return i18n.Lookup(s.Language)
+}
+
+// Title names the digest, e.g. "Daily Digest for 2024-03-01"
+func (s *Summary) Title() string {
+	if s.Period == Weekly {
+		return s.catalog().T("digest.weekly_title",
+			s.Since.Format("2006-01-02"), s.Until.Add(-time.Nanosecond).Format("2006-01-02"))
+	}
+	return s.catalog().T("digest.daily_title", s.Since.Format("2006-01-02"))
+}
+
+// Text renders the digest as plain text
+func (s *Summary) Text() string {
+	catalog := s.catalog()
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n", s.Title())
+	fmt.Fprintf(&b, "%s\n", catalog.T("digest.totals", s.TotalChanges, s.TotalFiles, float64(s.TotalBytes)/(1024*1024)))
+
+	for _, section := range s.sections() {
+		if len(section.Counts) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s:\n", section.Title)
+		for _, c := range section.Counts {
+			fmt.Fprintf(&b, "- %s: %s\n", c.Name, catalog.T("report.n_changes", c.Count))
+		}
+	}
+
+	return b.String()
+}
+
+// Markdown renders the digest as Markdown, with a table per section
+func (s *Summary) Markdown() string {
+	catalog := s.catalog()
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n", s.Title())
+	fmt.Fprintf(&b, "%s\n", catalog.T("digest.totals_markdown", s.TotalChanges, s.TotalFiles, float64(s.TotalBytes)/(1024*1024)))
+
+	for _, section := range s.sections() {
+		if len(section.Counts) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n| %s | %s |\n| --- | ---: |\n",
+			section.Title, catalog.T("digest.name"), catalog.T("report.changes"))
+		for _, c := range section.Counts {
+			fmt.Fprintf(&b, "| %s | %d |\n", markdownCell(c.Name), c.Count)
+		}
+	}
+
+	return b.String()
+}
+
+// markdownCell escapes a name for use in a Markdown table cell
+func markdownCell(name string) string {
+	return strings.NewReplacer(`\`, `\\`, "|", `\|`, "\n", " ").Replace(name)
+}
+
+// htmlTemplate renders a digest for HTML email
+var htmlTemplate = template.Must(template.New("digest").Parse(`<!DOCTYPE html>
+<html lang="{{.Language}}">
+<head><title>{{.Title}}</title></head>
+<body style="font-family: Arial, sans-serif; color: #333;">
+    <h1>{{.Title}}</h1>
+    <p>{{.Totals}}</p>
+    {{range .Sections}}{{if .Counts}}
+    <h2>{{.Title}}</h2>
+    <ul>
+        {{range .Counts}}<li>{{.Name}}: {{call $.Changes .Count}}</li>
+        {{end}}
+    </ul>
+    {{end}}{{end}}
+</body>
+</html>
+`))
+
+// section is a ranked list in a rendered digest
+type section struct {
+	Title  string
+	Counts []Count
+}
+
+// HTML renders the digest as an HTML document
+func (s *Summary) HTML() (string, error) {
+	catalog := s.catalog()
+	data := struct {
+		Language i18n.Language
+		Title    string
+		Totals   string
+		Sections []section
+		Changes  func(count int) string
+	}{
+		Language: catalog.Language(),
+		Title:    s.Title(),
+		Totals:   catalog.T("digest.totals", s.TotalChanges, s.TotalFiles, float64(s.TotalBytes)/(1024*1024)),
+		Sections: s.sections(),
+		Changes:  func(count int) string { return catalog.T("report.n_changes", count) },
+	}
+
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render digest: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// sections returns the ranked portfolio, project, author and file type
+// lists, followed for weekly digests by the changes on each day
+func (s *Summary) sections() []section {
+	catalog := s.catalog()
+	sections := []section{
+		{Title: catalog.T("digest.portfolios"), Counts: Ranked(s.Portfolios)},
+		{Title: catalog.T("digest.projects"), Counts: Ranked(s.Projects)},
+		{Title: catalog.T("digest.authors"), Counts: Ranked(s.Authors)},
+		{Title: catalog.T("report.file_types"), Counts: Ranked(s.FileTypes)},
+	}
+	if s.Period == Weekly {
+		days := section{Title: catalog.T("digest.days")}
+		for _, day := range s.Days {
+			weekday := catalog.T("weekday." + strings.ToLower(day.Start.Format("Mon")))
+			days.Counts = append(days.Counts, Count{Name: weekday + " " + day.Start.Format("2006-01-02"), Count: day.Changes})
+		}
+		sections = append(sections, days)
+	}
+	return sections
+}
+
+// Report renders the digest as a report of the given type, ready to send
+func (s *Summary) Report(reportType models.ReportType) (*models.Report, error) {
+	report := models.NewReport(reportType)
+	report.Title = s.Title()
+	report.Language = s.Language
+	report.Period = string(s.Period)
+	report.Since = s.Since
+	report.Until = s.Until
+	for _, change := range s.Changes {
+		report.AddChange(change)
+	}
+
+	switch reportType {
+	case models.HTMLReport:
+		content, err := s.HTML()
+		if err != nil {
+			return nil, err
+		}
+		report.Metadata["content"] = content
+	case models.MarkdownReport:
+		report.Metadata["content"] = s.Markdown()
+	default:
+		report.Metadata["content"] = s.Text()
+	}
+	report.Metadata["digest"] = string(s.Period)
+
+	return report, nil
+}
+
+// DailySummary converts the digest to its stored form
+func (s *Summary) DailySummary() *db.DailySummary {
+	return &db.DailySummary{
+		SummaryDate:    s.Since,
+		TotalFiles:     s.TotalFiles,
+		Summary:        s.Text(),
+		PortfolioStats: stats(s.Portfolios),
+		ProjectStats:   stats(s.Projects),
+		AuthorStats:    stats(s.Authors),
+	}
+}
+
+// stats converts counts to the map form stored with daily summaries
+func stats(counts map[string]int) map[string]interface{} {
+	result := make(map[string]interface{}, len(counts))
+	for name, count := range counts {
+		result[name] = count
+	}
+	return result
+}
+
+// Ranked returns the largest counts first, capped to the ten most active
+func Ranked(counts map[string]int) []Count {
+	ranked := make([]Count, 0, len(counts))
+	for name, count := range counts {
+		ranked = append(ranked, Count{Name: name, Count: count})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Count != ranked[j].Count {
+			return ranked[i].Count > ranked[j].Count
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+	if len(ranked) > maxRanked {
+		ranked = ranked[:maxRanked]
+	}
+	return ranked
+}
@@ -0,0 +1,166 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testChanges(day time.Time) []db.FileChange {
+	return []db.FileChange{
+		{FilePath: "/Energy/Solar/plan.docx", ModifiedAt: day.Add(time.Hour), Size: 1024, ModifiedByName: "Alice"},
+		{FilePath: "/Energy/Solar/plan.docx", ModifiedAt: day.Add(2 * time.Hour), Size: 2048, ModifiedByName: "Alice"},
+		{FilePath: "/Energy/Wind/budget.xlsx", ModifiedAt: day.Add(3 * time.Hour), Size: 512, Author: "Bob"},
+		{FilePath: "/notes.txt", ModifiedAt: day.Add(4 * time.Hour), Portfolio: "Admin"},
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	summary := Aggregate(Daily, day, day.AddDate(0, 0, 1), testChanges(day))
+
+	assert.Equal(t, 4, summary.TotalChanges)
+	assert.Equal(t, 3, summary.TotalFiles)
+	assert.Equal(t, int64(3584), summary.TotalBytes)
+	assert.Equal(t, map[string]int{"Energy": 3, "Admin": 1}, summary.Portfolios)
+	assert.Equal(t, map[string]int{"Solar": 2, "Wind": 1}, summary.Projects)
+	assert.Equal(t, map[string]int{"Alice": 2, "Bob": 1, "unknown": 1}, summary.Authors)
+	assert.Equal(t, map[string]int{".docx": 2, ".xlsx": 1, ".txt": 1}, summary.FileTypes)
+	require.Len(t, summary.Days, 1)
+	assert.Equal(t, 4, summary.Days[0].Changes)
+
+	assert.Equal(t, "Daily Digest for 2024-03-01", summary.Title())
+	assert.Contains(t, summary.Text(), "4 changes to 3 files")
+	assert.Contains(t, summary.Text(), "- Energy: 3 changes")
+
+	stored := summary.DailySummary()
+	assert.Equal(t, day, stored.SummaryDate)
+	assert.Equal(t, 3, stored.TotalFiles)
+	assert.Equal(t, 2, stored.ProjectStats["Solar"])
+
+	weekly := Aggregate(Weekly, day, day.AddDate(0, 0, 7), testChanges(day))
+	assert.Equal(t, "Weekly Digest for 2024-03-01 to 2024-03-07", weekly.Title())
+	assert.Len(t, weekly.Days, 7)
+	assert.Contains(t, weekly.Text(), "Days:\n- Fri 2024-03-01: 4 changes\n- Sat 2024-03-02: 0 changes")
+	assert.NotContains(t, summary.Text(), "Days:")
+}
+
+func TestSummary_Report(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	summary := Aggregate(Daily, day, day.AddDate(0, 0, 1), testChanges(day))
+
+	report, err := summary.Report(models.HTMLReport)
+	require.NoError(t, err)
+	assert.Equal(t, summary.Title(), report.Title)
+	assert.Equal(t, 4, report.TotalChanges)
+	assert.Contains(t, report.Metadata["content"], "<li>Solar: 2 changes</li>")
+	assert.Equal(t, "daily", report.Metadata["digest"])
+
+	text, err := summary.Report(models.NarrativeReport)
+	require.NoError(t, err)
+	assert.Equal(t, summary.Text(), text.Metadata["content"])
+
+	markdown, err := summary.Report(models.MarkdownReport)
+	require.NoError(t, err)
+	assert.Contains(t, markdown.Metadata["content"], "## Daily Digest for 2024-03-01")
+	assert.Contains(t, markdown.Metadata["content"], "| Solar | 2 |")
+}
+
+func TestSummary_Language(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	summary := Aggregate(Weekly, day, day.AddDate(0, 0, 7), testChanges(day))
+	summary.Language = "nl"
+
+	assert.Equal(t, "Wekelijks overzicht voor 2024-03-01 tot 2024-03-07", summary.Title())
+	assert.Contains(t, summary.Text(), "4 wijzigingen aan 3 bestanden")
+	assert.Contains(t, summary.Text(), "Dagen:\n- vr 2024-03-01: 4 wijzigingen\n")
+	assert.Contains(t, summary.Markdown(), "| Naam | Wijzigingen |")
+
+	report, err := summary.Report(models.HTMLReport)
+	require.NoError(t, err)
+	assert.Equal(t, "nl", report.Language)
+	assert.Contains(t, report.Metadata["content"], `<html lang="nl">`)
+	assert.Contains(t, report.Metadata["content"], "<li>Solar: 2 wijzigingen</li>")
+}
+
+func TestRanked(t *testing.T) {
+	counts := make(map[string]int)
+	for i := 0; i < 12; i++ {
+		counts[string(rune('a'+i))] = i % 3
+	}
+	ranked := Ranked(counts)
+	assert.Len(t, ranked, maxRanked)
+	assert.Equal(t, Count{Name: "c", Count: 2}, ranked[0])
+}
+
+type fakeStore struct {
+	changes    []db.FileChange
+	since      time.Time
+	until      time.Time
+	summaries  []*db.DailySummary
+	queryError error
+}
+
+func (s *fakeStore) GetFileChangesBetween(ctx context.Context, since, until time.Time) ([]db.FileChange, error) {
+	s.since, s.until = since, until
+	return s.changes, s.queryError
+}
+
+func (s *fakeStore) SaveDailySummary(ctx context.Context, ds *db.DailySummary) error {
+	s.summaries = append(s.summaries, ds)
+	return nil
+}
+
+type fakeSender struct {
+	reports []*models.Report
+}
+
+func (s *fakeSender) SendReport(ctx context.Context, report *models.Report) error {
+	s.reports = append(s.reports, report)
+	return nil
+}
+
+func TestDigester_Run(t *testing.T) {
+	today := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+	store := &fakeStore{changes: testChanges(today.AddDate(0, 0, -1))}
+	sender := &fakeSender{}
+
+	digester, err := NewDigester(store, sender, "")
+	require.NoError(t, err)
+	digester.now = func() time.Time { return today.Add(9 * time.Hour) }
+
+	require.NoError(t, digester.Run(context.Background(), Daily))
+	assert.Equal(t, today.AddDate(0, 0, -1), store.since)
+	assert.Equal(t, today, store.until)
+	require.Len(t, store.summaries, 1)
+	require.Len(t, sender.reports, 1)
+	assert.Equal(t, models.NarrativeReport, sender.reports[0].Type)
+
+	// Weekly digests are sent but not stored as daily summaries
+	require.NoError(t, digester.Run(context.Background(), Weekly))
+	assert.Equal(t, today.AddDate(0, 0, -7), store.since)
+	assert.Len(t, store.summaries, 1)
+	assert.Len(t, sender.reports, 2)
+
+	// Quiet days are stored but not sent
+	store.changes = nil
+	require.NoError(t, digester.Run(context.Background(), Daily))
+	assert.Len(t, store.summaries, 2)
+	assert.Len(t, sender.reports, 2)
+
+	store.queryError = errors.New("database locked")
+	assert.Error(t, digester.Run(context.Background(), Daily))
+}
+
+func TestNewDigester(t *testing.T) {
+	_, err := NewDigester(nil, &fakeSender{}, "")
+	assert.Error(t, err)
+	_, err = NewDigester(&fakeStore{}, nil, "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,105 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// Store reads stored changes and persists daily summaries
+type Store interface {
+	GetFileChangesBetween(ctx context.Context, since, until time.Time) ([]db.FileChange, error)
+	SaveDailySummary(ctx context.Context, ds *db.DailySummary) error
+}
+
+// Sender delivers a finished report, e.g. a reporting.Reporter
+type Sender interface {
+	SendReport(ctx context.Context, report *models.Report) error
+}
+
+// Digester builds digests from the store and sends them
+type Digester struct {
+	store      Store
+	sender     Sender
+	reportType models.ReportType
+	language   string
+	now        func() time.Time
+	logger     *slog.Logger
+}
+
+// NewDigester creates a digester sending reports of the given type; an empty
+// type sends plain text digests
+func NewDigester(store Store, sender Sender, reportType models.ReportType) (*Digester, error) {
+	if store == nil {
+		return nil, fmt.Errorf("digest store cannot be nil")
+	}
+	if sender == nil {
+		return nil, fmt.Errorf("digest sender cannot be nil")
+	}
+	if reportType == "" {
+		reportType = models.NarrativeReport
+	}
+
+	return &Digester{
+		store:      store,
+		sender:     sender,
+		reportType: reportType,
+		now:        time.Now,
+		logger:     logging.Component(nil, "digest"),
+	}, nil
+}
+
+// WithLanguage renders the digests in the language of the tag, as
+// understood by the i18n package, rather than English
+func (d *Digester) WithLanguage(language string) *Digester {
+	d.language = language
+	return d
+}
+
+// Run builds the digest for the period ending at midnight today and sends it.
+// Daily digests are also stored as daily summaries. Periods without changes
+// are stored but not sent.
+func (d *Digester) Run(ctx context.Context, period Period) error {
+	now := d.now()
+	until := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	since := until.AddDate(0, 0, -period.Days())
+
+	changes, err := d.store.GetFileChangesBetween(ctx, since, until)
+	if err != nil {
+		return fmt.Errorf("failed to load changes for %s digest: %w", period, err)
+	}
+	summary := Aggregate(period, since, until, changes)
+	summary.Language = d.language
+
+	if period == Daily {
+		if err := d.store.SaveDailySummary(ctx, summary.DailySummary()); err != nil {
+			return fmt.Errorf("failed to save daily summary: %w", err)
+		}
+	}
+
+	if summary.TotalChanges == 0 {
+		d.logger.Info("No changes for digest", "period", period, "since", since.Format("2006-01-02"))
+		return nil
+	}
+
+	report, err := summary.Report(d.reportType)
+	if err != nil {
+		return err
+	}
+	if err := d.sender.SendReport(ctx, report); err != nil {
+		return fmt.Errorf("failed to send %s digest: %w", period, err)
+	}
+	return nil
+}
+
+// Task returns a scheduler task that runs the digest for period
+func (d *Digester) Task(period Period) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return d.Run(ctx, period)
+	}
+}
@@ -0,0 +1,145 @@
+package dropbox
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// Account endpoints; variables so tests can point them at a local server
+var (
+	currentAccountURL = "https://api.dropboxapi.com/2/users/get_current_account"
+	spaceUsageURL     = "https://api.dropboxapi.com/2/users/get_space_usage"
+	accountBatchURL   = "https://api.dropboxapi.com/2/users/get_account_batch"
+)
+
+// maxAccountBatch is the most accounts get_account_batch looks up at once
+const maxAccountBatch = 300
+
+// DefaultAccountCacheTTL is how long account details are reused before being fetched again
+const DefaultAccountCacheTTL = 24 * time.Hour
+
+// currentAccountResult is the subset of get_current_account used
+type currentAccountResult struct {
+	AccountID string `json:"account_id"`
+	Name      struct {
+		DisplayName string `json:"display_name"`
+	} `json:"name"`
+	Email       string `json:"email"`
+	AccountType struct {
+		Tag string `json:".tag"`
+	} `json:"account_type"`
+	RootInfo struct {
+		RootNamespaceID string `json:"root_namespace_id"`
+		HomeNamespaceID string `json:"home_namespace_id"`
+	} `json:"root_info"`
+}
+
+// spaceUsageResult is the response of get_space_usage
+type spaceUsageResult struct {
+	Used       int64 `json:"used"`
+	Allocation struct {
+		Allocated int64 `json:"allocated"`
+	} `json:"allocation"`
+}
+
+// GetCurrentAccount returns the name, email, type and quota of the account
+// the client is authorised for
+func (c *DropboxClient) GetCurrentAccount(ctx context.Context) (*models.AccountInfo, error) {
+	var account currentAccountResult
+	if err := c.postJSON(ctx, currentAccountURL, nil, &account); err != nil {
+		return nil, err
+	}
+
+	var usage spaceUsageResult
+	if err := c.postJSON(ctx, spaceUsageURL, nil, &usage); err != nil {
+		return nil, err
+	}
+
+	info := &models.AccountInfo{
+		ID:             account.AccountID,
+		Name:           account.Name.DisplayName,
+		Email:          account.Email,
+		Type:           account.AccountType.Tag,
+		UsedBytes:      usage.Used,
+		AllocatedBytes: usage.Allocation.Allocated,
+		FetchedAt:      time.Now(),
+	}
+	if root := account.RootInfo.RootNamespaceID; root != account.RootInfo.HomeNamespaceID {
+		info.TeamSpaceNamespaceID = root
+	}
+	return info, nil
+}
+
+// AccountNames returns the display names of the given accounts, such as
+// those that modified files in shared folders, keyed by account ID
+func (c *DropboxClient) AccountNames(ctx context.Context, ids []string) (map[string]string, error) {
+	names := make(map[string]string, len(ids))
+	for start := 0; start < len(ids); start += maxAccountBatch {
+		end := start + maxAccountBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		var accounts []currentAccountResult
+		body := map[string]interface{}{"account_ids": ids[start:end]}
+		if err := c.postJSON(ctx, accountBatchURL, body, &accounts); err != nil {
+			return nil, err
+		}
+		for _, account := range accounts {
+			names[account.AccountID] = account.Name.DisplayName
+		}
+	}
+	return names, nil
+}
+
+// AccountSource fetches the details of the current account
+type AccountSource interface {
+	GetCurrentAccount(ctx context.Context) (*models.AccountInfo, error)
+}
+
+// AccountCache keeps the current account details, fetching them again once
+// they are older than the TTL
+type AccountCache struct {
+	source  AccountSource
+	ttl     time.Duration
+	now     func() time.Time
+	logger  *slog.Logger
+	mu      sync.Mutex
+	account *models.AccountInfo
+}
+
+// NewAccountCache creates a cache over source; a zero TTL uses DefaultAccountCacheTTL
+func NewAccountCache(source AccountSource, ttl time.Duration) *AccountCache {
+	if ttl <= 0 {
+		ttl = DefaultAccountCacheTTL
+	}
+	return &AccountCache{source: source, ttl: ttl, now: time.Now, logger: logging.Component(nil, "dropbox")}
+}
+
+// GetAccountInfo returns the cached account details, refreshing them when
+// stale. If a refresh fails the previous details are returned.
+func (c *AccountCache) GetAccountInfo(ctx context.Context) (*models.AccountInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.account != nil && c.now().Sub(c.account.FetchedAt) < c.ttl {
+		return c.account, nil
+	}
+
+	account, err := c.source.GetCurrentAccount(ctx)
+	if err != nil {
+		if c.account != nil {
+			c.logger.Warn("Using cached account details", "error", err)
+			return c.account, nil
+		}
+		return nil, err
+	}
+	account.FetchedAt = c.now()
+	c.account = account
+	return account, nil
+}
@@ -0,0 +1,118 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDropboxClient_GetCurrentAccount(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "null", string(body))
+
+		switch r.URL.Path {
+		case "/2/users/get_current_account":
+			fmt.Fprint(w, `{"account_id": "dbid:1", "name": {"display_name": "Jane Doe"},
+				"email": "jane@example.com", "account_type": {".tag": "business"},
+				"root_info": {".tag": "team", "root_namespace_id": "100", "home_namespace_id": "200"}}`)
+		case "/2/users/get_space_usage":
+			fmt.Fprint(w, `{"used": 1073741824, "allocation": {".tag": "team", "allocated": 2199023255552}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := setupTestClient(t, server, DefaultClientConfig())
+
+	origAccount, origUsage := currentAccountURL, spaceUsageURL
+	currentAccountURL = server.URL + "/2/users/get_current_account"
+	spaceUsageURL = server.URL + "/2/users/get_space_usage"
+	defer func() { currentAccountURL, spaceUsageURL = origAccount, origUsage }()
+
+	account, err := client.GetCurrentAccount(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "dbid:1", account.ID)
+	assert.Equal(t, "Jane Doe", account.Name)
+	assert.Equal(t, "jane@example.com", account.Email)
+	assert.Equal(t, "business", account.Type)
+	assert.Equal(t, int64(1073741824), account.UsedBytes)
+	assert.Equal(t, int64(2199023255552), account.AllocatedBytes)
+	assert.Equal(t, "100", account.TeamSpaceNamespaceID)
+}
+
+// accountSource returns canned account details and counts calls
+type accountSource struct {
+	calls int
+	err   error
+}
+
+func (s *accountSource) GetCurrentAccount(ctx context.Context) (*models.AccountInfo, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &models.AccountInfo{Name: fmt.Sprintf("Jane %d", s.calls)}, nil
+}
+
+func TestAccountCache(t *testing.T) {
+	source := &accountSource{}
+	cache := NewAccountCache(source, time.Hour)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	account, err := cache.GetAccountInfo(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "Jane 1", account.Name)
+
+	// Fresh details are reused
+	now = now.Add(30 * time.Minute)
+	account, _ = cache.GetAccountInfo(context.Background())
+	assert.Equal(t, "Jane 1", account.Name)
+	assert.Equal(t, 1, source.calls)
+
+	// Stale details are fetched again, falling back to the cached copy on error
+	now = now.Add(time.Hour)
+	source.err = errors.New("unavailable")
+	account, err = cache.GetAccountInfo(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "Jane 1", account.Name)
+
+	source.err = nil
+	account, _ = cache.GetAccountInfo(context.Background())
+	assert.Equal(t, "Jane 3", account.Name)
+
+	// Without cached details the error is returned
+	empty := NewAccountCache(&accountSource{err: errors.New("unavailable")}, 0)
+	_, err = empty.GetAccountInfo(context.Background())
+	assert.Error(t, err)
+}
+
+func TestDropboxClient_AccountNames(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"account_ids": ["dbid:1", "dbid:2"]}`, string(body))
+		fmt.Fprint(w, `[{"account_id": "dbid:1", "name": {"display_name": "Jane Doe"}},
+			{"account_id": "dbid:2", "name": {"display_name": "John Roe"}}]`)
+	}))
+	defer server.Close()
+
+	client := setupTestClient(t, server, DefaultClientConfig())
+	orig := accountBatchURL
+	accountBatchURL = server.URL + "/2/users/get_account_batch"
+	defer func() { accountBatchURL = orig }()
+
+	names, err := client.AccountNames(context.Background(), []string{"dbid:1", "dbid:2"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"dbid:1": "Jane Doe", "dbid:2": "John Roe"}, names)
+}
@@ -4,13 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/interfaces"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/limits"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
 )
 
@@ -33,8 +37,10 @@ func (rc *realClock) Sleep(d time.Duration) {
 
 // Default API URLs
 var (
-	listFolderURL = "https://api.dropboxapi.com/2/files/list_folder"
-	downloadURL   = "https://content.dropboxapi.com/2/files/download"
+	listFolderURL         = "https://api.dropboxapi.com/2/files/list_folder"
+	listFolderContinueURL = "https://api.dropboxapi.com/2/files/list_folder/continue"
+	getLatestCursorURL    = "https://api.dropboxapi.com/2/files/list_folder/get_latest_cursor"
+	downloadURL           = "https://content.dropboxapi.com/2/files/download"
 )
 
 // CircuitBreakerConfig holds configuration for the circuit breaker
@@ -56,6 +62,20 @@ type ClientConfig struct {
 	RetryConfig          RetryConfig
 	CircuitBreakerConfig CircuitBreakerConfig
 	Transport            *http.Transport
+	// Limits caps concurrent downloads and in-memory content; nil means no limits
+	Limits *limits.Guard
+	// Fixtures records API responses to files or replays them offline
+	Fixtures FixtureConfig
+	// Team scopes requests made with a Dropbox Business team token
+	Team TeamConfig
+	// RateLimit spreads requests over time; it is not applied to replayed
+	// fixtures
+	RateLimit RateLimitConfig
+	// RateLimitPause pauses requests while the API rate limit is exhausted
+	RateLimitPause RateLimitPauseConfig
+	// TraversalWorkers lists up to this many folders at a time during the
+	// initial listing; 0 or 1 lists the whole tree with one recursive request
+	TraversalWorkers int
 }
 
 // DefaultClientConfig returns a default configuration
@@ -125,7 +145,9 @@ func (cb *circuitBreaker) isOpen() bool {
 		// Check if enough time has passed to transition to half-open
 		if cb.clock.Now().Sub(cb.lastFailure) > cb.config.ResetTimeout {
 			cb.state = "half-open"
+			circuitBreakerState.Set(circuitHalfOpen)
 			cb.failures = 0
+			circuitBreakerFailures.Set(0)
 			cb.halfOpenTries = 0
 			return false
 		}
@@ -141,9 +163,11 @@ func (cb *circuitBreaker) recordSuccess() {
 
 	if cb.state == "half-open" {
 		cb.state = "closed"
+		circuitBreakerState.Set(circuitClosed)
 		cb.halfOpenTries = 0
 	} else {
 		cb.failures = 0
+		circuitBreakerFailures.Set(0)
 	}
 }
 
@@ -154,34 +178,59 @@ func (cb *circuitBreaker) recordFailure() {
 
 	cb.failures++
 	cb.lastFailure = cb.clock.Now()
+	circuitBreakerFailures.Set(float64(cb.failures))
 
 	if cb.state == "half-open" {
 		cb.halfOpenTries++
 		if cb.halfOpenTries >= cb.config.HalfOpenMaxTries {
-			cb.state = "open"
+			cb.open()
 		}
 	} else if cb.state == "closed" && cb.failures >= cb.config.MaxFailures {
-		cb.state = "open"
+		cb.open()
 	}
 }
 
-// Client defines the interface for Dropbox operations
-type Client interface {
-	ListFolder(ctx context.Context, path string) ([]*models.FileMetadata, error)
-	GetFileContent(ctx context.Context, path string) ([]byte, error)
-	GetChangesLast24Hours(ctx context.Context) ([]*models.FileMetadata, error)
-	GetChangesLast10Minutes(ctx context.Context) ([]*models.FileMetadata, error)
-	GetChanges(ctx context.Context) ([]*models.FileMetadata, error)
-	GetFileChanges(ctx context.Context) ([]models.FileChange, error)
+// open moves the breaker to the open state; the caller holds the lock
+func (cb *circuitBreaker) open() {
+	cb.state = "open"
+	circuitBreakerState.Set(circuitOpen)
+	circuitBreakerOpens.Inc()
 }
 
+// reset closes the breaker and forgets its failures
+func (cb *circuitBreaker) reset() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.state = "closed"
+	cb.failures = 0
+	cb.halfOpenTries = 0
+	circuitBreakerState.Set(circuitClosed)
+	circuitBreakerFailures.Set(0)
+	circuitBreakerResets.Inc()
+}
+
+// CircuitStatus describes the circuit breaker of a client
+type CircuitStatus struct {
+	// State is "closed", "open" or "half-open"
+	State string `json:"state"`
+	// Failures counts the failures towards opening the breaker
+	Failures int `json:"failures"`
+	// LastFailure is when the latest request failed, if any has
+	LastFailure *time.Time `json:"last_failure,omitempty"`
+}
+
+var _ interfaces.DropboxClient = (*DropboxClient)(nil)
+
 // DropboxClient handles interactions with the Dropbox API
 type DropboxClient struct {
-	accessToken    string
+	tokens         TokenSource
 	httpClient     *http.Client
 	config         ClientConfig
 	circuitBreaker *circuitBreaker
 	metrics        *clientMetrics
+	pause          *rateLimitPause
+	limiter        *rateLimiter
 }
 
 // clientMetrics tracks client operation metrics
@@ -189,31 +238,145 @@ type clientMetrics struct {
 	retryCount    int64
 	requestCount  int64
 	errorCount    int64
+	rateLimitWait time.Duration
 	lastError     error
 	lastErrorTime time.Time
+	endpoints     map[string]*EndpointMetrics
+	since         time.Time
 	mu            sync.RWMutex
 }
 
-func (m *clientMetrics) recordRetry() {
+// EndpointMetrics counts the calls made to one Dropbox API endpoint
+type EndpointMetrics struct {
+	Requests int64 `json:"requests"`
+	Retries  int64 `json:"retries"`
+	Errors   int64 `json:"errors"`
+}
+
+// MetricsSnapshot is a consistent copy of a client's metrics, covering the
+// period from Since, when the client was created or its metrics were last
+// reset, to TakenAt. Endpoints are keyed by API path, such as
+// "files/list_folder" or "files/download".
+type MetricsSnapshot struct {
+	Requests      int64                      `json:"requests"`
+	Retries       int64                      `json:"retries"`
+	Errors        int64                      `json:"errors"`
+	LastError     string                     `json:"last_error,omitempty"`
+	LastErrorTime time.Time                  `json:"last_error_time,omitempty"`
+	Endpoints     map[string]EndpointMetrics `json:"endpoints"`
+	Since         time.Time                  `json:"since"`
+	TakenAt       time.Time                  `json:"taken_at"`
+	// RateLimitWait is how long requests waited for the shared rate limiter
+	// or a Retry-After hint
+	RateLimitWait time.Duration `json:"rate_limit_wait"`
+}
+
+// newClientMetrics creates metrics counting from now
+func newClientMetrics() *clientMetrics {
+	return &clientMetrics{since: time.Now()}
+}
+
+// endpoint returns the counts for an endpoint; the caller holds the lock
+func (m *clientMetrics) endpoint(name string) *EndpointMetrics {
+	if m.endpoints == nil {
+		m.endpoints = make(map[string]*EndpointMetrics)
+	}
+	e, ok := m.endpoints[name]
+	if !ok {
+		e = &EndpointMetrics{}
+		m.endpoints[name] = e
+	}
+	return e
+}
+
+func (m *clientMetrics) recordRetry(endpoint string) {
+	apiRetriesTotal.Inc()
+	apiEndpointRetriesTotal.With(endpoint).Inc()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.retryCount++
+	m.endpoint(endpoint).Retries++
 }
 
-func (m *clientMetrics) recordRequest() {
+func (m *clientMetrics) recordRequest(endpoint string) {
+	apiRequestsTotal.Inc()
+	apiEndpointRequestsTotal.With(endpoint).Inc()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.requestCount++
+	m.endpoint(endpoint).Requests++
 }
 
-func (m *clientMetrics) recordError(err error) {
+func (m *clientMetrics) recordError(endpoint string, err error) {
+	apiErrorsTotal.With(errorType(err)).Inc()
+	apiEndpointErrorsTotal.With(endpoint).Inc()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.errorCount++
+	m.endpoint(endpoint).Errors++
 	m.lastError = err
 	m.lastErrorTime = time.Now()
 }
 
+// recordRateLimitWait adds the time a request waited for the rate limiter
+func (m *clientMetrics) recordRateLimitWait(wait time.Duration) {
+	if wait <= 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rateLimitWait += wait
+}
+
+// Snapshot returns a copy of the metrics
+func (m *clientMetrics) Snapshot() MetricsSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.snapshot()
+}
+
+// Reset zeroes the metrics and returns their values just before the reset,
+// so no calls are lost between reading and resetting
+func (m *clientMetrics) Reset() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := m.snapshot()
+	m.retryCount, m.requestCount, m.errorCount = 0, 0, 0
+	m.rateLimitWait = 0
+	m.lastError, m.lastErrorTime = nil, time.Time{}
+	m.endpoints = nil
+	m.since = snapshot.TakenAt
+	return snapshot
+}
+
+// snapshot copies the metrics; the caller holds the lock
+func (m *clientMetrics) snapshot() MetricsSnapshot {
+	snapshot := MetricsSnapshot{
+		Requests:      m.requestCount,
+		Retries:       m.retryCount,
+		Errors:        m.errorCount,
+		RateLimitWait: m.rateLimitWait,
+		LastErrorTime: m.lastErrorTime,
+		Endpoints:     make(map[string]EndpointMetrics, len(m.endpoints)),
+		Since:         m.since,
+		TakenAt:       time.Now(),
+	}
+	if m.lastError != nil {
+		snapshot.LastError = m.lastError.Error()
+	}
+	for name, e := range m.endpoints {
+		snapshot.Endpoints[name] = *e
+	}
+	return snapshot
+}
+
+// endpointName returns the API path of a request URL path, such as
+// "files/list_folder" for "/2/files/list_folder"
+func endpointName(path string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(path, "/"), "2/")
+}
+
 // NewDropboxClient creates a new Dropbox client
 func NewDropboxClient(token string) (*DropboxClient, error) {
 	if token == "" {
@@ -230,14 +393,42 @@ func NewDropboxClientWithConfig(token string, config ClientConfig) (*DropboxClie
 		return nil, NewInvalidInputError("token cannot be empty", nil)
 	}
 
+	return NewDropboxClientWithTokenSource(StaticTokenSource(token), config)
+}
+
+// NewDropboxClientWithTokenSource creates a new Dropbox client that obtains
+// access tokens from the given source, such as a RefreshTokenSource
+func NewDropboxClientWithTokenSource(tokens TokenSource, config ClientConfig) (*DropboxClient, error) {
+	if tokens == nil {
+		return nil, NewInvalidInputError("token source cannot be nil", nil)
+	}
+
+	var transport http.RoundTripper = http.DefaultTransport
+	if config.Transport != nil {
+		transport = config.Transport
+	}
+	var limiter *rateLimiter
+	if config.Fixtures.Mode != FixtureReplay {
+		limiter = newRateLimiter(config.RateLimit)
+	}
+	if config.Fixtures.Mode != FixtureOff {
+		fixtures, err := newFixtureTransport(config.Fixtures, transport)
+		if err != nil {
+			return nil, err
+		}
+		transport = fixtures
+	}
+
 	return &DropboxClient{
-		accessToken: token,
+		tokens: tokens,
 		httpClient: &http.Client{
-			Transport: config.Transport,
+			Transport: transport,
 		},
 		config:         config,
 		circuitBreaker: newCircuitBreaker(config.CircuitBreakerConfig),
-		metrics:        &clientMetrics{},
+		metrics:        newClientMetrics(),
+		pause:          newRateLimitPause(config.RateLimitPause),
+		limiter:        limiter,
 	}, nil
 }
 
@@ -248,20 +439,65 @@ func (c *DropboxClient) GetMetrics() (retryCount, requestCount, errorCount int64
 	return c.metrics.retryCount, c.metrics.requestCount, c.metrics.errorCount
 }
 
+// MetricsSnapshot returns a consistent copy of the client metrics, including
+// the per-endpoint breakdown
+func (c *DropboxClient) MetricsSnapshot() MetricsSnapshot {
+	return c.metrics.Snapshot()
+}
+
+// ResetMetrics zeroes the client metrics and returns their values just before
+// the reset, for exporters that report rates over fixed intervals
+func (c *DropboxClient) ResetMetrics() MetricsSnapshot {
+	return c.metrics.Reset()
+}
+
+// CircuitState returns the circuit breaker state: "closed", "open" or "half-open"
+func (c *DropboxClient) CircuitState() string {
+	c.circuitBreaker.mu.Lock()
+	defer c.circuitBreaker.mu.Unlock()
+	return c.circuitBreaker.state
+}
+
+// CircuitStatus returns the state of the circuit breaker, its failure count
+// and the time of the latest failure
+func (c *DropboxClient) CircuitStatus() CircuitStatus {
+	c.circuitBreaker.mu.Lock()
+	defer c.circuitBreaker.mu.Unlock()
+
+	status := CircuitStatus{State: c.circuitBreaker.state, Failures: c.circuitBreaker.failures}
+	if lastFailure := c.circuitBreaker.lastFailure; !lastFailure.IsZero() {
+		status.LastFailure = &lastFailure
+	}
+	return status
+}
+
+// ResetCircuit closes the circuit breaker and clears its failures, so
+// requests are made again straight away, such as after a known outage ended
+func (c *DropboxClient) ResetCircuit() {
+	c.circuitBreaker.reset()
+}
+
 // doRequestWithRetry performs an HTTP request with retry logic and circuit breaker
 func (c *DropboxClient) doRequestWithRetry(req *http.Request) (*http.Response, error) {
+	if err := c.pause.check(); err != nil {
+		return nil, err
+	}
 	if c.circuitBreaker.isOpen() {
 		return nil, NewCircuitOpenError("circuit breaker is open", nil)
 	}
 
-	c.metrics.recordRequest()
+	endpoint := endpointName(req.URL.Path)
+	c.metrics.recordRequest(endpoint)
 	var lastErr error
 	wait := c.config.RetryConfig.InitialWait
+	refreshed := false
 
 	for attempt := 0; attempt <= c.config.RetryConfig.MaxRetries; attempt++ {
 		if attempt > 0 {
-			c.metrics.recordRetry()
-			time.Sleep(wait)
+			c.metrics.recordRetry(endpoint)
+			if err := sleepContext(req.Context(), wait); err != nil {
+				return nil, fmt.Errorf("waiting to retry %s: %w", endpoint, err)
+			}
 			// Exponential backoff with jitter
 			wait = time.Duration(float64(wait) * 1.5)
 			if wait > c.config.RetryConfig.MaxWait {
@@ -269,39 +505,86 @@ func (c *DropboxClient) doRequestWithRetry(req *http.Request) (*http.Response, e
 			}
 		}
 
-		// Clone the request to avoid reusing the same request multiple times
-		reqClone := req.Clone(req.Context())
-		resp, err := c.httpClient.Do(reqClone)
+		waited, err := c.limiter.wait(req.Context())
+		c.metrics.recordRateLimitWait(waited)
+		if err != nil {
+			return nil, fmt.Errorf("waiting for the rate limit on %s: %w", endpoint, err)
+		}
+
+		resp, err := c.send(req)
+		if err == nil && resp.StatusCode == http.StatusUnauthorized && !refreshed {
+			// The access token may have expired; refresh it and retry once
+			resp.Body.Close()
+			refreshed = true
+			if _, err := c.tokens.Refresh(req.Context()); err != nil {
+				c.metrics.recordError(endpoint, err)
+				return nil, err
+			}
+			resp, err = c.send(req)
+		}
+		var dbErr *Error
+		if errors.As(err, &dbErr) && dbErr.Type == ErrorTypeAuth {
+			c.metrics.recordError(endpoint, err)
+			return nil, err
+		}
+		if errors.Is(err, ErrNoFixture) {
+			// Replaying again will not find it either
+			err = NewInvalidInputError("request was not recorded", err)
+			c.metrics.recordError(endpoint, err)
+			return nil, err
+		}
 		if err != nil {
 			lastErr = NewNetworkError(fmt.Sprintf("attempt %d: request failed", attempt+1), err)
-			c.metrics.recordError(lastErr)
+			c.metrics.recordError(endpoint, lastErr)
 			c.circuitBreaker.recordFailure()
 			continue
 		}
 
 		// Handle response based on status code
 		switch {
-		case resp.StatusCode == http.StatusOK:
+		case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusPartialContent:
 			c.circuitBreaker.recordSuccess()
+			c.pause.succeeded()
 			return resp, nil
 		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
 			resp.Body.Close()
 			err := NewAuthError(fmt.Sprintf("authentication failed: status %d", resp.StatusCode), nil)
-			c.metrics.recordError(err)
+			c.metrics.recordError(endpoint, err)
+			return nil, err
+		case resp.StatusCode == http.StatusConflict:
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+			err := NewConflictError(fmt.Sprintf("request conflict: %s", bytes.TrimSpace(body)), nil)
+			c.metrics.recordError(endpoint, err)
 			return nil, err
 		case resp.StatusCode == http.StatusTooManyRequests:
 			resp.Body.Close()
-			lastErr = NewRateLimitError(fmt.Sprintf("rate limited on attempt %d", attempt+1), nil)
-			c.metrics.recordError(lastErr)
+			hint := retryAfter(resp.Header, time.Now())
+			rateErr := NewRateLimitError(fmt.Sprintf("rate limited on attempt %d", attempt+1), nil)
+			if hint > 0 {
+				// Hold back every request, not just this retry
+				rateErr.ResumeAt = time.Now().Add(hint)
+				c.limiter.backoff(rateErr.ResumeAt)
+			}
+			lastErr = rateErr
+			c.metrics.recordError(endpoint, lastErr)
 			c.circuitBreaker.recordFailure()
+			if resumeAt := c.pause.limited(hint); !resumeAt.IsZero() {
+				// Rate limiting persists; stop retrying until the pause ends
+				return nil, NewQuotaPausedError(resumeAt)
+			}
 			if attempt == c.config.RetryConfig.MaxRetries {
 				return nil, lastErr
 			}
+			if c.limiter == nil && hint > wait {
+				// Wait at least as long as Dropbox asked, within the retry limit
+				wait = min(hint, c.config.RetryConfig.MaxWait)
+			}
 			continue
 		case resp.StatusCode >= 500:
 			resp.Body.Close()
 			lastErr = NewServerError(fmt.Sprintf("server error on attempt %d: status %d", attempt+1, resp.StatusCode), nil)
-			c.metrics.recordError(lastErr)
+			c.metrics.recordError(endpoint, lastErr)
 			c.circuitBreaker.recordFailure()
 			if attempt == c.config.RetryConfig.MaxRetries {
 				return nil, lastErr
@@ -310,7 +593,7 @@ func (c *DropboxClient) doRequestWithRetry(req *http.Request) (*http.Response, e
 		default:
 			resp.Body.Close()
 			err := NewError(ErrorTypeUnknown, fmt.Sprintf("unexpected status: %d", resp.StatusCode), nil)
-			c.metrics.recordError(err)
+			c.metrics.recordError(endpoint, err)
 			return nil, err
 		}
 	}
@@ -318,6 +601,40 @@ func (c *DropboxClient) doRequestWithRetry(req *http.Request) (*http.Response, e
 	return nil, lastErr
 }
 
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// send performs a single attempt of the request with the current access token
+func (c *DropboxClient) send(req *http.Request) (*http.Response, error) {
+	token, err := c.tokens.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	// Clone the request to avoid reusing the same request multiple times
+	reqClone := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, NewInvalidInputError("failed to reset request body", err)
+		}
+		reqClone.Body = body
+	}
+	reqClone.Header.Set("Authorization", "Bearer "+token)
+	c.config.Team.apply(reqClone)
+
+	return c.httpClient.Do(reqClone)
+}
+
 // dropboxFileMetadata represents the raw metadata from Dropbox API
 type dropboxFileMetadata struct {
 	Tag            string `json:".tag"`
@@ -350,10 +667,11 @@ func (c *DropboxClient) toFileMetadata(dbx *dropboxFileMetadata) (*models.FileMe
 	}
 
 	return &models.FileMetadata{
-		Path:     dbx.PathDisplay,
-		Name:     dbx.Name,
-		Size:     dbx.Size,
-		Modified: modTime,
+		Path:        dbx.PathDisplay,
+		Name:        dbx.Name,
+		Size:        dbx.Size,
+		Modified:    modTime,
+		ContentHash: dbx.ContentHash,
 	}, nil
 }
 
@@ -377,7 +695,6 @@ func (c *DropboxClient) ListFolder(ctx context.Context, path string) ([]*models.
 		return nil, NewInvalidInputError(fmt.Sprintf("failed to create request for path %s", path), err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.accessToken)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.doRequestWithRetry(req)
@@ -428,28 +745,71 @@ func (c *DropboxClient) GetFileContent(ctx context.Context, path string) ([]byte
 		return nil, NewInvalidInputError(fmt.Sprintf("failed to create request for path %s", path), err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.accessToken)
 	req.Header.Set("Dropbox-API-Arg", string(jsonBody))
 
+	guard := c.config.Limits
+	releaseDownload, err := guard.AcquireDownload(ctx)
+	if err != nil {
+		return nil, NewNetworkError(fmt.Sprintf("waiting for download slot for path %s", path), err)
+	}
+	defer releaseDownload()
+
 	resp, err := c.doRequestWithRetry(req)
 	if err != nil {
 		return nil, err // Already wrapped by doRequestWithRetry with proper context
 	}
 	defer resp.Body.Close()
 
-	// Check if the file is too large (>100MB) to prevent memory issues
-	if resp.ContentLength > 100*1024*1024 {
-		return nil, NewFileSizeLimitError(fmt.Sprintf("file %s exceeds maximum size of 100MB (size: %d bytes)", path, resp.ContentLength), nil)
+	// Check if the file is too large to prevent memory issues
+	maxSize := int64(limits.DefaultMaxContentBytes)
+	if guard != nil {
+		maxSize = guard.Config().MaxContentBytes
+	}
+	if resp.ContentLength > maxSize {
+		return nil, NewFileSizeLimitError(fmt.Sprintf("file %s exceeds maximum size of %d bytes (size: %d bytes)", path, maxSize, resp.ContentLength), nil)
+	}
+
+	// Reserve memory for the content; an unknown length reserves the whole per-file limit
+	reserve := resp.ContentLength
+	if reserve < 0 {
+		reserve = maxSize
 	}
+	releaseContent, err := guard.ReserveContent(ctx, reserve)
+	if err != nil {
+		if errors.Is(err, limits.ErrContentTooLarge) {
+			return nil, NewFileSizeLimitError(fmt.Sprintf("file %s exceeds memory limit", path), err)
+		}
+		return nil, NewNetworkError(fmt.Sprintf("waiting for memory for path %s", path), err)
+	}
+	defer releaseContent()
 
-	content, err := io.ReadAll(resp.Body)
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
 	if err != nil {
 		return nil, NewNetworkError(fmt.Sprintf("failed to read response body for path %s", path), err)
 	}
+	if int64(len(content)) > maxSize {
+		return nil, NewFileSizeLimitError(fmt.Sprintf("file %s exceeds maximum size of %d bytes", path, maxSize), nil)
+	}
+	if err := verifyContentHash(resp.Header.Get("Dropbox-API-Result"), content); err != nil {
+		return nil, NewNetworkError(fmt.Sprintf("download of path %s is corrupt", path), err)
+	}
 
 	return content, nil
 }
 
+// verifyContentHash checks content against the content hash in the download
+// result metadata. Responses without a content hash are accepted.
+func verifyContentHash(apiResult string, content []byte) error {
+	expected := resultContentHash(apiResult)
+	if expected == "" {
+		return nil
+	}
+	if got := ContentHash(content); got != expected {
+		return fmt.Errorf("content hash %s does not match %s", got, expected)
+	}
+	return nil
+}
+
 // GetChangesLast24Hours returns changes from the last 24 hours
 func (c *DropboxClient) GetChangesLast24Hours(ctx context.Context) ([]*models.FileMetadata, error) {
 	return c.ListFolder(ctx, "")
@@ -473,3 +833,210 @@ func (c *DropboxClient) GetFileChanges(ctx context.Context) ([]models.FileChange
 	}
 	return models.BatchConvertMetadataToChanges(changes), nil
 }
+
+// listFolderResult is the response of the list_folder endpoints
+type listFolderResult struct {
+	Entries []dropboxFileMetadata `json:"entries"`
+	HasMore bool                  `json:"has_more"`
+	Cursor  string                `json:"cursor"`
+}
+
+// postJSON sends a JSON request to an RPC endpoint and decodes the JSON response into out
+func (c *DropboxClient) postJSON(ctx context.Context, url string, body interface{}, out interface{}) error {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return NewInvalidInputError("failed to marshal request body", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return NewInvalidInputError("failed to create request", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.doRequestWithRetry(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return NewServerError("failed to decode response", err)
+	}
+	return nil
+}
+
+// GetLatestCursor returns a cursor for the current state of path, covering
+// all nested entries and deletions; "" or "/" is the Dropbox root
+func (c *DropboxClient) GetLatestCursor(ctx context.Context, path string) (string, error) {
+	if path == "/" {
+		path = ""
+	}
+
+	body := map[string]interface{}{
+		"path":            path,
+		"recursive":       true,
+		"include_deleted": true,
+	}
+
+	var result struct {
+		Cursor string `json:"cursor"`
+	}
+	if err := c.postJSON(ctx, getLatestCursorURL, body, &result); err != nil {
+		return "", err
+	}
+	if result.Cursor == "" {
+		return "", NewServerError(fmt.Sprintf("no cursor returned for path %s", path), nil)
+	}
+
+	return result.Cursor, nil
+}
+
+// ListFolderContinue returns the files added, modified or deleted since the
+// cursor was issued, along with the cursor to use for the next call. A
+// conflict error means the cursor was reset and a new one must be obtained.
+func (c *DropboxClient) ListFolderContinue(ctx context.Context, cursor string) ([]*models.FileMetadata, string, error) {
+	if cursor == "" {
+		return nil, "", NewInvalidInputError("cursor cannot be empty", nil)
+	}
+
+	files := make([]*models.FileMetadata, 0)
+	for {
+		var result listFolderResult
+		if err := c.postJSON(ctx, listFolderContinueURL, map[string]string{"cursor": cursor}, &result); err != nil {
+			return nil, "", err
+		}
+
+		for i := range result.Entries {
+			file, err := c.toChangedFileMetadata(&result.Entries[i])
+			if err != nil {
+				return nil, "", err
+			}
+			if file != nil {
+				files = append(files, file)
+			}
+		}
+
+		cursor = result.Cursor
+		if !result.HasMore {
+			return files, cursor, nil
+		}
+	}
+}
+
+// toChangedFileMetadata converts a delta entry; folders are skipped and
+// deleted entries, which carry no size or content hash, are marked as such
+func (c *DropboxClient) toChangedFileMetadata(entry *dropboxFileMetadata) (*models.FileMetadata, error) {
+	switch entry.Tag {
+	case "folder":
+		return nil, nil
+	case "deleted":
+		return models.NewFileMetadata(entry.PathDisplay, 0, time.Now(), true), nil
+	}
+
+	modTime, err := time.Parse(time.RFC3339, entry.ServerModified)
+	if err != nil {
+		return nil, NewServerError(fmt.Sprintf("invalid server modified time for %s", entry.PathDisplay), err)
+	}
+
+	file := models.NewFileMetadata(entry.PathDisplay, entry.Size, modTime, false)
+	file.ServerModified = modTime
+	file.ContentHash = entry.ContentHash
+	file.Rev = entry.Rev
+	if id, ok := entry.SharingInfo.ModifiedBy.(string); ok {
+		file.ModifiedByID = id
+	}
+	return file, nil
+}
+
+// FolderSize returns the cumulative size of all files under path
+func (c *DropboxClient) FolderSize(ctx context.Context, path string) (int64, error) {
+	if path == "/" {
+		path = ""
+	}
+
+	body := map[string]interface{}{
+		"path":      path,
+		"recursive": true,
+	}
+
+	var result listFolderResult
+	if err := c.postJSON(ctx, listFolderURL, body, &result); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for {
+		for _, entry := range result.Entries {
+			if entry.Tag == "file" {
+				total += entry.Size
+			}
+		}
+		if !result.HasMore {
+			return total, nil
+		}
+
+		cursor := result.Cursor
+		result = listFolderResult{}
+		if err := c.postJSON(ctx, listFolderContinueURL, map[string]string{"cursor": cursor}, &result); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// ListFolderRecursive lists every file under path, along with a cursor for
+// the changes made after the listing; "" or "/" is the Dropbox root. With
+// more than one traversal worker the folders are walked in parallel.
+func (c *DropboxClient) ListFolderRecursive(ctx context.Context, path string) ([]*models.FileMetadata, string, error) {
+	if path == "/" {
+		path = ""
+	}
+
+	if c.config.TraversalWorkers > 1 {
+		// The cursor is taken first so changes made during the walk are
+		// reported afterwards rather than missed
+		cursor, err := c.GetLatestCursor(ctx, path)
+		if err != nil {
+			return nil, "", err
+		}
+		files, err := c.Walk(ctx, path, c.config.TraversalWorkers)
+		if err != nil {
+			return nil, "", err
+		}
+		return files, cursor, nil
+	}
+
+	// Deleted entries are requested so the cursor reports later deletions
+	body := map[string]interface{}{
+		"path":            path,
+		"recursive":       true,
+		"include_deleted": true,
+	}
+
+	var result listFolderResult
+	if err := c.postJSON(ctx, listFolderURL, body, &result); err != nil {
+		return nil, "", err
+	}
+
+	files := make([]*models.FileMetadata, 0, len(result.Entries))
+	for {
+		for i := range result.Entries {
+			file, err := c.toChangedFileMetadata(&result.Entries[i])
+			if err != nil {
+				return nil, "", err
+			}
+			if file != nil && !file.IsDeleted {
+				files = append(files, file)
+			}
+		}
+		if !result.HasMore {
+			return files, result.Cursor, nil
+		}
+
+		cursor := result.Cursor
+		result = listFolderResult{}
+		if err := c.postJSON(ctx, listFolderContinueURL, map[string]string{"cursor": cursor}, &result); err != nil {
+			return nil, "", err
+		}
+	}
+}
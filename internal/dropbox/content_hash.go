@@ -0,0 +1,86 @@
+package dropbox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+)
+
+// ContentHashBlockSize is the block size of the Dropbox content hash
+const ContentHashBlockSize = 4 << 20
+
+// contentHasher computes the Dropbox content hash: the SHA-256 of the
+// concatenated SHA-256 digests of each 4 MB block of the content
+type contentHasher struct {
+	digests   []byte
+	block     hash.Hash
+	blockSize int
+}
+
+// NewContentHasher returns a hash.Hash computing the Dropbox content hash, as
+// reported in file metadata, so downloads can be checked against it
+func NewContentHasher() hash.Hash {
+	return &contentHasher{block: sha256.New()}
+}
+
+// Write adds data to the running hash, closing each block as it fills
+func (h *contentHasher) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := ContentHashBlockSize - h.blockSize
+		if n > len(p) {
+			n = len(p)
+		}
+		h.block.Write(p[:n])
+		h.blockSize += n
+		p = p[n:]
+
+		if h.blockSize == ContentHashBlockSize {
+			h.digests = h.block.Sum(h.digests)
+			h.block.Reset()
+			h.blockSize = 0
+		}
+	}
+	return written, nil
+}
+
+// Sum appends the content hash to b without changing the running state
+func (h *contentHasher) Sum(b []byte) []byte {
+	overall := sha256.New()
+	overall.Write(h.digests)
+	if h.blockSize > 0 {
+		overall.Write(h.block.Sum(nil))
+	}
+	return overall.Sum(b)
+}
+
+// Reset clears the running hash
+func (h *contentHasher) Reset() {
+	h.digests = h.digests[:0]
+	h.block.Reset()
+	h.blockSize = 0
+}
+
+// Size returns the length of the hash in bytes
+func (h *contentHasher) Size() int {
+	return sha256.Size
+}
+
+// BlockSize returns the block size of the underlying SHA-256
+func (h *contentHasher) BlockSize() int {
+	return sha256.BlockSize
+}
+
+// ContentHash returns the hex-encoded Dropbox content hash of data
+func ContentHash(data []byte) string {
+	h := NewContentHasher()
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// SHA256 returns the hex-encoded SHA-256 of data, the local hash stored
+// alongside the Dropbox content hash
+func SHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,112 @@
+package dropbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockHash computes the Dropbox content hash directly from its definition
+func blockHash(data []byte) string {
+	var digests []byte
+	for start := 0; start < len(data); start += ContentHashBlockSize {
+		end := start + ContentHashBlockSize
+		if end > len(data) {
+			end = len(data)
+		}
+		sum := sha256.Sum256(data[start:end])
+		digests = append(digests, sum[:]...)
+	}
+	sum := sha256.Sum256(digests)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestContentHash(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"empty", 0},
+		{"small", 100},
+		{"one block", ContentHashBlockSize},
+		{"block and a byte", ContentHashBlockSize + 1},
+		{"several blocks", 2*ContentHashBlockSize + 12345},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := bytes.Repeat([]byte("dropbox!"), tt.size/8+1)[:tt.size]
+			assert.Equal(t, blockHash(data), ContentHash(data))
+		})
+	}
+
+	// The empty content hashes no blocks
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", ContentHash(nil))
+}
+
+func TestContentHasher_Streaming(t *testing.T) {
+	data := bytes.Repeat([]byte{1, 2, 3, 4, 5, 6, 7}, ContentHashBlockSize/3)
+
+	h := NewContentHasher()
+	for start := 0; start < len(data); start += 1 << 20 {
+		end := start + 1<<20
+		if end > len(data) {
+			end = len(data)
+		}
+		h.Write(data[start:end])
+		// Sum must not disturb the running hash
+		h.Sum(nil)
+	}
+	assert.Equal(t, ContentHash(data), hex.EncodeToString(h.Sum(nil)))
+
+	h.Reset()
+	h.Write([]byte("abc"))
+	assert.Equal(t, ContentHash([]byte("abc")), hex.EncodeToString(h.Sum(nil)))
+}
+
+func TestDropboxClient_GetFileContentVerifiesHash(t *testing.T) {
+	content := []byte("file content")
+	tests := []struct {
+		name    string
+		result  string
+		wantErr bool
+	}{
+		{"matching hash", fmt.Sprintf(`{"content_hash": %q}`, ContentHash(content)), false},
+		{"no result header", "", false},
+		{"mismatched hash", fmt.Sprintf(`{"content_hash": %q}`, ContentHash([]byte("other"))), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.result != "" {
+					w.Header().Set("Dropbox-API-Result", tt.result)
+				}
+				w.Write(content)
+			}))
+			defer server.Close()
+
+			origURL := downloadURL
+			downloadURL = server.URL + "/2/files/download"
+			defer func() { downloadURL = origURL }()
+
+			client := setupTestClient(t, server, DefaultClientConfig())
+			got, err := client.GetFileContent(context.Background(), "/test.txt")
+			if tt.wantErr {
+				var dbErr *Error
+				require.True(t, errors.As(err, &dbErr))
+				assert.Equal(t, ErrorTypeNetwork, dbErr.Type)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, content, got)
+		})
+	}
+}
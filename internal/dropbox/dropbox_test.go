@@ -2,13 +2,16 @@ package dropbox
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/limits"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -89,8 +92,8 @@ func setupTestServer(t *testing.T, statusCode int, response string) *httptest.Se
 func setupTestClient(t *testing.T, server *httptest.Server, config ClientConfig) *DropboxClient {
 	clock := newMockClock()
 	client := &DropboxClient{
-		accessToken: "test-token",
-		httpClient:  server.Client(),
+		tokens:     StaticTokenSource("test-token"),
+		httpClient: server.Client(),
 		config:     config,
 		circuitBreaker: &circuitBreaker{
 			config: config.CircuitBreakerConfig,
@@ -98,6 +101,7 @@ func setupTestClient(t *testing.T, server *httptest.Server, config ClientConfig)
 			clock:  clock,
 		},
 		metrics: &clientMetrics{},
+		pause:   newRateLimitPause(config.RateLimitPause),
 	}
 	return client
 }
@@ -272,6 +276,218 @@ func TestDropboxClient_GetFileContent(t *testing.T) {
 	}
 }
 
+func TestDropboxClient_GetFileContentLimits(t *testing.T) {
+	server := setupTestServer(t, http.StatusOK, "0123456789")
+	defer server.Close()
+
+	origURL := downloadURL
+	downloadURL = server.URL + "/2/files/download"
+	defer func() { downloadURL = origURL }()
+
+	guard, err := limits.NewGuard(limits.Config{MaxContentBytes: 5})
+	require.NoError(t, err)
+
+	config := DefaultClientConfig()
+	config.Limits = guard
+	client := setupTestClient(t, server, config)
+
+	_, err = client.GetFileContent(context.Background(), "/big.txt")
+	require.Error(t, err)
+	var dbErr *Error
+	require.True(t, errors.As(err, &dbErr))
+	assert.Equal(t, ErrorTypeFileSizeLimit, dbErr.Type)
+
+	stats := guard.Stats()
+	assert.Equal(t, int64(0), stats.ActiveDownloads)
+	assert.Equal(t, int64(0), stats.ContentBytesInUse)
+}
+
+func TestDropboxClient_FolderSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2/files/list_folder":
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "/Raw-Video", body["path"])
+			assert.Equal(t, true, body["recursive"])
+			fmt.Fprint(w, `{"entries": [
+				{".tag": "file", "path_display": "/Raw-Video/a.mov", "size": 100},
+				{".tag": "folder", "path_display": "/Raw-Video/day1"}
+			], "cursor": "c1", "has_more": true}`)
+		case "/2/files/list_folder/continue":
+			fmt.Fprint(w, `{"entries": [
+				{".tag": "file", "path_display": "/Raw-Video/day1/b.mov", "size": 250}
+			], "cursor": "c2", "has_more": false}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := setupTestClient(t, server, DefaultClientConfig())
+
+	origList, origContinue := listFolderURL, listFolderContinueURL
+	listFolderURL = server.URL + "/2/files/list_folder"
+	listFolderContinueURL = server.URL + "/2/files/list_folder/continue"
+	defer func() { listFolderURL, listFolderContinueURL = origList, origContinue }()
+
+	size, err := client.FolderSize(context.Background(), "/Raw-Video")
+	require.NoError(t, err)
+	assert.Equal(t, int64(350), size)
+}
+
+func TestDropboxClient_ListFolderRecursive(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2/files/list_folder":
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "", body["path"])
+			assert.Equal(t, true, body["recursive"])
+			assert.Equal(t, true, body["include_deleted"])
+			fmt.Fprint(w, `{"entries": [
+				{".tag": "file", "path_display": "/a.txt", "server_modified": "2021-01-01T00:00:00Z", "size": 10},
+				{".tag": "folder", "path_display": "/docs"},
+				{".tag": "deleted", "path_display": "/gone.txt"}
+			], "cursor": "c1", "has_more": true}`)
+		case "/2/files/list_folder/continue":
+			fmt.Fprint(w, `{"entries": [
+				{".tag": "file", "path_display": "/docs/b.txt", "server_modified": "2021-01-02T00:00:00Z", "size": 20}
+			], "cursor": "c2", "has_more": false}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := setupTestClient(t, server, DefaultClientConfig())
+
+	origList, origContinue := listFolderURL, listFolderContinueURL
+	listFolderURL = server.URL + "/2/files/list_folder"
+	listFolderContinueURL = server.URL + "/2/files/list_folder/continue"
+	defer func() { listFolderURL, listFolderContinueURL = origList, origContinue }()
+
+	files, cursor, err := client.ListFolderRecursive(context.Background(), "/")
+	require.NoError(t, err)
+	assert.Equal(t, "c2", cursor)
+	require.Len(t, files, 2)
+	assert.Equal(t, "/a.txt", files[0].Path)
+	assert.Equal(t, "/docs/b.txt", files[1].Path)
+	assert.Equal(t, int64(20), files[1].Size)
+}
+
+func TestDropboxClient_GetLatestCursor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/2/files/list_folder/get_latest_cursor", r.URL.Path)
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "", body["path"])
+		assert.Equal(t, true, body["recursive"])
+		assert.Equal(t, true, body["include_deleted"])
+		fmt.Fprint(w, `{"cursor": "cursor-1"}`)
+	}))
+	defer server.Close()
+
+	client := setupTestClient(t, server, DefaultClientConfig())
+
+	origURL := getLatestCursorURL
+	getLatestCursorURL = server.URL + "/2/files/list_folder/get_latest_cursor"
+	defer func() { getLatestCursorURL = origURL }()
+
+	cursor, err := client.GetLatestCursor(context.Background(), "/")
+	require.NoError(t, err)
+	assert.Equal(t, "cursor-1", cursor)
+}
+
+func TestDropboxClient_ListFolderContinue(t *testing.T) {
+	pages := map[string]string{
+		"cursor-1": `{
+			"entries": [
+				{".tag": "file", "path_display": "/a.txt", "server_modified": "2021-01-01T00:00:00Z", "size": 10, "content_hash": "hash-a"},
+				{".tag": "folder", "path_display": "/docs"}
+			],
+			"cursor": "cursor-2",
+			"has_more": true
+		}`,
+		"cursor-2": `{
+			"entries": [
+				{".tag": "deleted", "path_display": "/old.txt"}
+			],
+			"cursor": "cursor-3",
+			"has_more": false
+		}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		page, ok := pages[body["cursor"]]
+		if !ok {
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `{"error_summary": "reset/"}`)
+			return
+		}
+		fmt.Fprint(w, page)
+	}))
+	defer server.Close()
+
+	client := setupTestClient(t, server, DefaultClientConfig())
+
+	origURL := listFolderContinueURL
+	listFolderContinueURL = server.URL + "/2/files/list_folder/continue"
+	defer func() { listFolderContinueURL = origURL }()
+
+	t.Run("Follows pages", func(t *testing.T) {
+		files, cursor, err := client.ListFolderContinue(context.Background(), "cursor-1")
+		require.NoError(t, err)
+		assert.Equal(t, "cursor-3", cursor)
+		require.Len(t, files, 2)
+		assert.Equal(t, "/a.txt", files[0].Path)
+		assert.False(t, files[0].IsDeleted)
+		assert.Equal(t, "hash-a", files[0].ContentHash)
+		assert.Equal(t, "/old.txt", files[1].Path)
+		assert.True(t, files[1].IsDeleted)
+	})
+
+	t.Run("Reset cursor", func(t *testing.T) {
+		_, _, err := client.ListFolderContinue(context.Background(), "expired")
+		require.Error(t, err)
+		var dbErr *Error
+		require.True(t, errors.As(err, &dbErr))
+		assert.Equal(t, ErrorTypeConflict, dbErr.Type)
+	})
+
+	t.Run("Empty cursor", func(t *testing.T) {
+		_, _, err := client.ListFolderContinue(context.Background(), "")
+		require.Error(t, err)
+	})
+}
+
+func TestDropboxClient_RetryHonorsCancellation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	config := DefaultClientConfig()
+	config.RetryConfig.InitialWait = time.Hour
+	config.RetryConfig.MaxWait = time.Hour
+	client := setupTestClient(t, server, config)
+
+	origURL := getLatestCursorURL
+	getLatestCursorURL = server.URL + "/2/files/list_folder/get_latest_cursor"
+	defer func() { getLatestCursorURL = origURL }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.GetLatestCursor(ctx, "/")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second, "the retry wait should end with the context")
+}
+
 func TestCircuitBreaker(t *testing.T) {
 	clock := newMockClock()
 	config := CircuitBreakerConfig{
@@ -310,13 +526,42 @@ func TestCircuitBreaker(t *testing.T) {
 	assert.True(t, cb.isOpen())
 }
 
+func TestCircuitBreaker_Reset(t *testing.T) {
+	clock := newMockClock()
+	client := &DropboxClient{circuitBreaker: newCircuitBreakerWithClock(CircuitBreakerConfig{
+		MaxFailures:      2,
+		ResetTimeout:     time.Hour,
+		HalfOpenMaxTries: 1,
+	}, clock)}
+	assert.Equal(t, CircuitStatus{State: "closed"}, client.CircuitStatus())
+
+	client.circuitBreaker.recordFailure()
+	client.circuitBreaker.recordFailure()
+	status := client.CircuitStatus()
+	assert.Equal(t, "open", status.State)
+	assert.Equal(t, 2, status.Failures)
+	require.NotNil(t, status.LastFailure)
+	assert.Equal(t, clock.Now(), *status.LastFailure)
+
+	// Resetting closes the breaker before its timeout, keeping the time of
+	// the last failure
+	resets := circuitBreakerResets.Value()
+	client.ResetCircuit()
+	status = client.CircuitStatus()
+	assert.Equal(t, "closed", status.State)
+	assert.Zero(t, status.Failures)
+	assert.NotNil(t, status.LastFailure)
+	assert.False(t, client.circuitBreaker.isOpen())
+	assert.Equal(t, resets+1, circuitBreakerResets.Value())
+}
+
 func TestClientMetrics(t *testing.T) {
 	metrics := &clientMetrics{}
 
 	// Test recording metrics
-	metrics.recordRequest()
-	metrics.recordRetry()
-	metrics.recordError(assert.AnError)
+	metrics.recordRequest("files/list_folder")
+	metrics.recordRetry("files/list_folder")
+	metrics.recordError("files/list_folder", assert.AnError)
 
 	retries, requests, errors := metrics.retryCount, metrics.requestCount, metrics.errorCount
 	assert.Equal(t, int64(1), retries)
@@ -325,6 +570,71 @@ func TestClientMetrics(t *testing.T) {
 	assert.Equal(t, assert.AnError, metrics.lastError)
 }
 
+func TestClientMetrics_SnapshotAndReset(t *testing.T) {
+	metrics := newClientMetrics()
+	start := metrics.since
+
+	metrics.recordRequest("files/list_folder")
+	metrics.recordRequest("files/list_folder")
+	metrics.recordRequest("files/download")
+	metrics.recordRetry("files/download")
+	metrics.recordError("files/download", assert.AnError)
+
+	snapshot := metrics.Snapshot()
+	assert.Equal(t, int64(3), snapshot.Requests)
+	assert.Equal(t, EndpointMetrics{Requests: 2}, snapshot.Endpoints["files/list_folder"])
+	assert.Equal(t, EndpointMetrics{Requests: 1, Retries: 1, Errors: 1}, snapshot.Endpoints["files/download"])
+	assert.Equal(t, assert.AnError.Error(), snapshot.LastError)
+	assert.Equal(t, start, snapshot.Since)
+
+	// Snapshots are copies
+	snapshot.Endpoints["files/download"] = EndpointMetrics{}
+	assert.Equal(t, int64(1), metrics.Snapshot().Endpoints["files/download"].Requests)
+
+	final := metrics.Reset()
+	assert.Equal(t, int64(3), final.Requests)
+
+	after := metrics.Snapshot()
+	assert.Zero(t, after.Requests)
+	assert.Empty(t, after.Endpoints)
+	assert.Empty(t, after.LastError)
+	assert.Equal(t, final.TakenAt, after.Since)
+}
+
+func TestClientMetrics_Concurrent(t *testing.T) {
+	metrics := newClientMetrics()
+
+	var wg sync.WaitGroup
+	var reset int64
+	var mu sync.Mutex
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				metrics.recordRequest("files/download")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			snapshot := metrics.Reset()
+			mu.Lock()
+			reset += snapshot.Requests
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	// Every request is counted exactly once across the resets
+	assert.Equal(t, int64(800), reset+metrics.Snapshot().Requests)
+}
+
+func TestEndpointName(t *testing.T) {
+	assert.Equal(t, "files/list_folder", endpointName("/2/files/list_folder"))
+	assert.Equal(t, "files/list_folder/continue", endpointName("/2/files/list_folder/continue"))
+	assert.Equal(t, "files/download", endpointName("/files/download"))
+}
+
 func TestIsRetryable(t *testing.T) {
 	tests := []struct {
 		name string
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	cerrors "github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/errors"
 )
@@ -28,12 +29,20 @@ const (
 	ErrorTypeCircuitOpen ErrorType = "circuit_open"
 	// ErrorTypeFileSizeLimit represents a file size limit error
 	ErrorTypeFileSizeLimit ErrorType = "file_size_limit"
+	// ErrorTypeConflict represents an endpoint-specific error, such as a reset cursor
+	ErrorTypeConflict ErrorType = "conflict"
+	// ErrorTypeQuotaPaused represents a request refused while requests are
+	// paused after persistent rate limiting
+	ErrorTypeQuotaPaused ErrorType = "quota_paused"
 )
 
 // Error represents a Dropbox API error
 type Error struct {
 	cerr *cerrors.Error
 	Type ErrorType
+	// ResumeAt is when requests may be made again after a rate limit or
+	// quota paused error; zero when unknown
+	ResumeAt time.Time
 }
 
 // Error implements the error interface
@@ -98,6 +107,18 @@ func NewFileSizeLimitError(msg string, cause error) *Error {
 	return NewError(ErrorTypeFileSizeLimit, msg, cause)
 }
 
+// NewConflictError creates a new conflict error
+func NewConflictError(msg string, cause error) *Error {
+	return NewError(ErrorTypeConflict, msg, cause)
+}
+
+// NewQuotaPausedError creates an error for a request refused until resumeAt
+func NewQuotaPausedError(resumeAt time.Time) *Error {
+	err := NewError(ErrorTypeQuotaPaused, fmt.Sprintf("requests paused after persistent rate limiting until %s", resumeAt.Format(time.RFC3339)), nil)
+	err.ResumeAt = resumeAt
+	return err
+}
+
 // IsRetryable returns true if the error is retryable
 func IsRetryable(err error) bool {
 	var dbErr *Error
@@ -108,7 +129,7 @@ func IsRetryable(err error) bool {
 	switch dbErr.Type {
 	case ErrorTypeNetwork, ErrorTypeRateLimit, ErrorTypeServer:
 		return true
-	case ErrorTypeAuth, ErrorTypeInvalidInput, ErrorTypeCircuitOpen, ErrorTypeFileSizeLimit:
+	case ErrorTypeAuth, ErrorTypeInvalidInput, ErrorTypeCircuitOpen, ErrorTypeFileSizeLimit, ErrorTypeConflict, ErrorTypeQuotaPaused:
 		return false
 	default:
 		return false
@@ -122,6 +143,8 @@ func statusToErrorType(status int) ErrorType {
 		return ErrorTypeAuth
 	case status == http.StatusTooManyRequests:
 		return ErrorTypeRateLimit
+	case status == http.StatusConflict:
+		return ErrorTypeConflict
 	case status >= 500:
 		return ErrorTypeServer
 	case status >= 400:
@@ -148,6 +171,10 @@ func typeToCategory(typ ErrorType) cerrors.Category {
 		return cerrors.CategoryUnavailable
 	case ErrorTypeFileSizeLimit:
 		return cerrors.CategoryInvalidArgument
+	case ErrorTypeConflict:
+		return cerrors.CategoryInvalidState
+	case ErrorTypeQuotaPaused:
+		return cerrors.CategoryUnavailable
 	default:
 		return cerrors.CategoryUnknown
 	}
@@ -0,0 +1,88 @@
+package dropbox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixtures_RecordAndReplay(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Path {
+		case "/2/files/list_folder":
+			fmt.Fprintf(w, `{"entries": [
+				{".tag": "file", "path_display": "/a.bin", "server_modified": "2021-01-01T00:00:00Z", "size": %d}
+			], "cursor": "c%d", "has_more": false}`, calls, calls)
+		case "/2/files/download":
+			w.Write([]byte{0xff, 0x00, 0xfe})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	origList, origDownload := listFolderURL, downloadURL
+	listFolderURL = server.URL + "/2/files/list_folder"
+	downloadURL = server.URL + "/2/files/download"
+	defer func() { listFolderURL, downloadURL = origList, origDownload }()
+
+	dir := t.TempDir()
+	ctx := context.Background()
+	config := DefaultClientConfig()
+	config.Fixtures = FixtureConfig{Mode: FixtureRecord, Dir: dir}
+	recorder, err := NewDropboxClientWithConfig("real-token", config)
+	require.NoError(t, err)
+
+	_, cursor, err := recorder.ListFolderRecursive(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, "c1", cursor)
+	_, cursor, err = recorder.ListFolderRecursive(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, "c2", cursor)
+	content, err := recorder.GetFileContent(ctx, "/a.bin")
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "one fixture per distinct request")
+	data, err := os.ReadFile(dir + "/" + entries[0].Name())
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "real-token")
+
+	// Replay serves the same responses, in order, without the server
+	server.Close()
+	config.Fixtures.Mode = FixtureReplay
+	config.RetryConfig.MaxRetries = 0
+	replayer, err := NewDropboxClientWithConfig("any-token", config)
+	require.NoError(t, err)
+
+	for _, want := range []string{"c1", "c2", "c2"} {
+		files, cursor, err := replayer.ListFolderRecursive(ctx, "")
+		require.NoError(t, err)
+		assert.Equal(t, want, cursor)
+		require.Len(t, files, 1)
+		assert.Equal(t, "/a.bin", files[0].Path)
+	}
+	replayed, err := replayer.GetFileContent(ctx, "/a.bin")
+	require.NoError(t, err)
+	assert.Equal(t, content, replayed)
+
+	_, err = replayer.GetFileContent(ctx, "/missing.txt")
+	assert.ErrorIs(t, err, ErrNoFixture)
+	assert.Equal(t, "closed", replayer.CircuitState())
+}
+
+func TestNewFixtureTransport_Invalid(t *testing.T) {
+	_, err := newFixtureTransport(FixtureConfig{Mode: "rewind", Dir: t.TempDir()}, http.DefaultTransport)
+	assert.Error(t, err)
+	_, err = newFixtureTransport(FixtureConfig{Mode: FixtureReplay}, http.DefaultTransport)
+	assert.Error(t, err)
+}
@@ -0,0 +1,101 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Link and thumbnail endpoints; variables so tests can point them at a local server
+var (
+	getTemporaryLinkURL = "https://api.dropboxapi.com/2/files/get_temporary_link"
+	getThumbnailURL     = "https://content.dropboxapi.com/2/files/get_thumbnail_v2"
+)
+
+// maxThumbnailBytes caps the size of a downloaded thumbnail
+const maxThumbnailBytes = 1 << 20
+
+// ThumbnailSizes are the sizes Dropbox scales thumbnails to
+var ThumbnailSizes = []string{
+	"w32h32", "w64h64", "w128h128", "w256h256", "w480h320", "w640h480", "w960h640", "w1024h768", "w2048h1536",
+}
+
+// GetFileSharedLink returns the URL of a shared link to the file at path
+// itself, or "" when the file has none
+func (c *DropboxClient) GetFileSharedLink(ctx context.Context, path string) (string, error) {
+	if path == "" {
+		return "", NewInvalidInputError("path cannot be empty", nil)
+	}
+
+	var result sharedLinksResult
+	body := map[string]interface{}{"path": path, "direct_only": true}
+	if err := c.postJSON(ctx, listSharedLinksURL, body, &result); err != nil {
+		return "", err
+	}
+	for _, link := range result.Links {
+		if link.URL != "" {
+			return link.URL, nil
+		}
+	}
+	return "", nil
+}
+
+// GetTemporaryLink returns a link that downloads the file at path without
+// signing in; Dropbox expires it after four hours
+func (c *DropboxClient) GetTemporaryLink(ctx context.Context, path string) (string, error) {
+	if path == "" {
+		return "", NewInvalidInputError("path cannot be empty", nil)
+	}
+
+	var result struct {
+		Link string `json:"link"`
+	}
+	if err := c.postJSON(ctx, getTemporaryLinkURL, map[string]string{"path": path}, &result); err != nil {
+		return "", err
+	}
+	if result.Link == "" {
+		return "", NewServerError(fmt.Sprintf("no temporary link returned for path %s", path), nil)
+	}
+	return result.Link, nil
+}
+
+// GetThumbnail returns a JPEG thumbnail of the image at path, scaled to fit
+// size, one of ThumbnailSizes
+func (c *DropboxClient) GetThumbnail(ctx context.Context, path, size string) ([]byte, error) {
+	if path == "" {
+		return nil, NewInvalidInputError("path cannot be empty", nil)
+	}
+
+	arg, err := json.Marshal(map[string]interface{}{
+		"resource": map[string]string{".tag": "path", "path": path},
+		"format":   "jpeg",
+		"size":     size,
+		"mode":     "bestfit",
+	})
+	if err != nil {
+		return nil, NewInvalidInputError(fmt.Sprintf("failed to marshal thumbnail request for path %s", path), err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", getThumbnailURL, nil)
+	if err != nil {
+		return nil, NewInvalidInputError(fmt.Sprintf("failed to create thumbnail request for path %s", path), err)
+	}
+	req.Header.Set("Dropbox-API-Arg", string(arg))
+
+	resp, err := c.doRequestWithRetry(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	thumbnail, err := io.ReadAll(io.LimitReader(resp.Body, maxThumbnailBytes+1))
+	if err != nil {
+		return nil, NewNetworkError(fmt.Sprintf("failed to read thumbnail of path %s", path), err)
+	}
+	if len(thumbnail) > maxThumbnailBytes {
+		return nil, NewFileSizeLimitError(fmt.Sprintf("thumbnail of path %s exceeds %d bytes", path, maxThumbnailBytes), nil)
+	}
+	return thumbnail, nil
+}
@@ -0,0 +1,71 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDropboxClient_Links(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2/sharing/list_shared_links":
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, true, body["direct_only"])
+			if body["path"] == "/docs/a.pdf" {
+				fmt.Fprint(w, `{"links": [{".tag": "file", "url": "https://db.tt/a", "path_lower": "/docs/a.pdf"}], "has_more": false}`)
+				return
+			}
+			fmt.Fprint(w, `{"links": [], "has_more": false}`)
+		case "/2/files/get_temporary_link":
+			var body map[string]string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			fmt.Fprintf(w, `{"metadata": {"path_display": %q}, "link": "https://dl.dropboxusercontent.com/t/b"}`, body["path"])
+		case "/2/files/get_thumbnail_v2":
+			var arg map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(r.Header.Get("Dropbox-API-Arg")), &arg))
+			assert.Equal(t, "w128h128", arg["size"])
+			assert.Equal(t, map[string]interface{}{".tag": "path", "path": "/photos/c.png"}, arg["resource"])
+			w.Write([]byte("jpeg"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := setupTestClient(t, server, DefaultClientConfig())
+	origShared, origTemporary, origThumbnail := listSharedLinksURL, getTemporaryLinkURL, getThumbnailURL
+	listSharedLinksURL = server.URL + "/2/sharing/list_shared_links"
+	getTemporaryLinkURL = server.URL + "/2/files/get_temporary_link"
+	getThumbnailURL = server.URL + "/2/files/get_thumbnail_v2"
+	defer func() {
+		listSharedLinksURL, getTemporaryLinkURL, getThumbnailURL = origShared, origTemporary, origThumbnail
+	}()
+
+	ctx := context.Background()
+	link, err := client.GetFileSharedLink(ctx, "/docs/a.pdf")
+	require.NoError(t, err)
+	assert.Equal(t, "https://db.tt/a", link)
+
+	link, err = client.GetFileSharedLink(ctx, "/docs/b.pdf")
+	require.NoError(t, err)
+	assert.Empty(t, link)
+
+	link, err = client.GetTemporaryLink(ctx, "/docs/b.pdf")
+	require.NoError(t, err)
+	assert.Equal(t, "https://dl.dropboxusercontent.com/t/b", link)
+
+	thumbnail, err := client.GetThumbnail(ctx, "/photos/c.png", "w128h128")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("jpeg"), thumbnail)
+
+	_, err = client.GetTemporaryLink(ctx, "")
+	assert.Error(t, err)
+}
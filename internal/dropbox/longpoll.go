@@ -0,0 +1,89 @@
+package dropbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// listFolderLongpollURL is a variable so tests can point it at a local server
+var listFolderLongpollURL = "https://notify.dropboxapi.com/2/files/list_folder/longpoll"
+
+// The longpoll timeouts Dropbox accepts
+const (
+	MinLongpollTimeout = 30 * time.Second
+	MaxLongpollTimeout = 480 * time.Second
+)
+
+// longpollResult is the response of list_folder/longpoll
+type longpollResult struct {
+	Changes bool `json:"changes"`
+	// Backoff is how many seconds to wait before the next longpoll, if set
+	Backoff int `json:"backoff"`
+}
+
+// ListFolderLongpoll waits up to timeout, clamped to the range Dropbox
+// accepts, for changes under cursor. It reports whether there are changes to
+// list with ListFolderContinue, and how long Dropbox asked to wait before
+// the next longpoll. Dropbox may hold the request up to 90 seconds longer
+// than timeout. The request carries no access token, which the endpoint
+// rejects, and is not retried; a conflict error means the cursor was reset.
+func (c *DropboxClient) ListFolderLongpoll(ctx context.Context, cursor string, timeout time.Duration) (bool, time.Duration, error) {
+	if cursor == "" {
+		return false, 0, NewInvalidInputError("cursor cannot be empty", nil)
+	}
+	timeout = min(max(timeout, MinLongpollTimeout), MaxLongpollTimeout)
+	if err := c.pause.check(); err != nil {
+		return false, 0, err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"cursor":  cursor,
+		"timeout": int(timeout / time.Second),
+	})
+	if err != nil {
+		return false, 0, NewInvalidInputError("failed to marshal request body", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", listFolderLongpollURL, bytes.NewReader(body))
+	if err != nil {
+		return false, 0, NewInvalidInputError("failed to create request", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	endpoint := endpointName(req.URL.Path)
+	c.metrics.recordRequest(endpoint)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		err = NewNetworkError("longpoll request failed", err)
+		c.metrics.recordError(endpoint, err)
+		return false, 0, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusConflict:
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		err := NewConflictError(fmt.Sprintf("request conflict: %s", bytes.TrimSpace(detail)), nil)
+		c.metrics.recordError(endpoint, err)
+		return false, 0, err
+	case resp.StatusCode >= 500:
+		err := NewServerError(fmt.Sprintf("server error: status %d", resp.StatusCode), nil)
+		c.metrics.recordError(endpoint, err)
+		return false, 0, err
+	default:
+		err := NewError(ErrorTypeUnknown, fmt.Sprintf("unexpected status: %d", resp.StatusCode), nil)
+		c.metrics.recordError(endpoint, err)
+		return false, 0, err
+	}
+
+	var result longpollResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, 0, NewServerError("failed to decode response", err)
+	}
+	return result.Changes, time.Duration(result.Backoff) * time.Second, nil
+}
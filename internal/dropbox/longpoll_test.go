@@ -0,0 +1,62 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDropboxClient_ListFolderLongpoll(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The endpoint rejects requests carrying an access token
+		assert.Empty(t, r.Header.Get("Authorization"))
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		switch body["cursor"] {
+		case "reset":
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `{"error_summary": "reset/"}`)
+		case "idle":
+			assert.Equal(t, float64(MinLongpollTimeout/time.Second), body["timeout"])
+			fmt.Fprint(w, `{"changes": false}`)
+		default:
+			assert.Equal(t, float64(MaxLongpollTimeout/time.Second), body["timeout"])
+			fmt.Fprint(w, `{"changes": true, "backoff": 60}`)
+		}
+	}))
+	defer server.Close()
+
+	client := setupTestClient(t, server, DefaultClientConfig())
+	orig := listFolderLongpollURL
+	listFolderLongpollURL = server.URL + "/2/files/list_folder/longpoll"
+	defer func() { listFolderLongpollURL = orig }()
+
+	ctx := context.Background()
+	changes, backoff, err := client.ListFolderLongpoll(ctx, "busy", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, changes)
+	assert.Equal(t, time.Minute, backoff)
+
+	changes, backoff, err = client.ListFolderLongpoll(ctx, "idle", 0)
+	require.NoError(t, err)
+	assert.False(t, changes)
+	assert.Zero(t, backoff)
+
+	_, _, err = client.ListFolderLongpoll(ctx, "reset", time.Minute)
+	var dbErr *Error
+	require.True(t, errors.As(err, &dbErr))
+	assert.Equal(t, ErrorTypeConflict, dbErr.Type)
+
+	_, _, err = client.ListFolderLongpoll(ctx, "", time.Minute)
+	assert.Error(t, err)
+	assert.Equal(t, int64(3), client.MetricsSnapshot().Endpoints["files/list_folder/longpoll"].Requests)
+}
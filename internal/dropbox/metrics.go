@@ -0,0 +1,51 @@
+package dropbox
+
+import (
+	"errors"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/metrics"
+)
+
+// Circuit breaker states as reported by the state gauge
+const (
+	circuitClosed   = 0
+	circuitHalfOpen = 1
+	circuitOpen     = 2
+)
+
+// Prometheus metrics shared by all Dropbox clients
+var (
+	apiRequestsTotal = metrics.Default.Counter("dropbox_api_requests_total",
+		"Dropbox API calls made, excluding retries.")
+	apiRetriesTotal = metrics.Default.Counter("dropbox_api_retries_total",
+		"Dropbox API calls retried after a retryable failure.")
+	apiErrorsTotal = metrics.Default.CounterVec("dropbox_api_errors_total",
+		"Failed Dropbox API attempts by error type.", "type")
+	apiEndpointRequestsTotal = metrics.Default.CounterVec("dropbox_api_endpoint_requests_total",
+		"Dropbox API calls made by endpoint, excluding retries.", "endpoint")
+	apiEndpointRetriesTotal = metrics.Default.CounterVec("dropbox_api_endpoint_retries_total",
+		"Dropbox API calls retried by endpoint.", "endpoint")
+	apiEndpointErrorsTotal = metrics.Default.CounterVec("dropbox_api_endpoint_errors_total",
+		"Failed Dropbox API attempts by endpoint.", "endpoint")
+	circuitBreakerState = metrics.Default.Gauge("dropbox_circuit_breaker_state",
+		"Most recent circuit breaker state: 0 closed, 1 half-open, 2 open.")
+	circuitBreakerOpens = metrics.Default.Counter("dropbox_circuit_breaker_opens_total",
+		"Times the circuit breaker opened.")
+	circuitBreakerFailures = metrics.Default.Gauge("dropbox_circuit_breaker_failures",
+		"Failures counted by the circuit breaker towards opening.")
+	circuitBreakerResets = metrics.Default.Counter("dropbox_circuit_breaker_resets_total",
+		"Times the circuit breaker was closed by hand.")
+	rateLimitWait = metrics.Default.Histogram("dropbox_api_rate_limit_wait_seconds",
+		"Time requests waited for the shared rate limiter or a Retry-After hint.", []float64{0.1, 0.5, 1, 5, 15, 60, 300})
+	quotaPaused = metrics.Default.Gauge("dropbox_api_quota_paused",
+		"1 while requests are paused because the API rate limit is exhausted, otherwise 0.")
+)
+
+// errorType returns the metric label for an error
+func errorType(err error) string {
+	var dbErr *Error
+	if errors.As(err, &dbErr) {
+		return string(dbErr.Type)
+	}
+	return string(ErrorTypeUnknown)
+}
@@ -0,0 +1,190 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/interfaces"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+)
+
+// tokenURL is the Dropbox OAuth2 token endpoint
+var tokenURL = "https://api.dropboxapi.com/oauth2/token"
+
+const (
+	// accessTokenStateKey is the state key holding the current short-lived access token
+	accessTokenStateKey = "dropbox_access_token"
+	// tokenExpiryStateKey is the state key holding the access token expiry (RFC3339)
+	tokenExpiryStateKey = "dropbox_access_token_expiry"
+	// tokenExpiryMargin refreshes tokens slightly before Dropbox expires them
+	tokenExpiryMargin = time.Minute
+)
+
+// TokenSource supplies access tokens for Dropbox API requests
+type TokenSource interface {
+	// Token returns a valid access token
+	Token(ctx context.Context) (string, error)
+	// Refresh obtains a new access token after the current one was rejected
+	Refresh(ctx context.Context) (string, error)
+}
+
+// StaticTokenSource is a TokenSource for a long-lived access token
+type StaticTokenSource string
+
+// Token implements TokenSource
+func (s StaticTokenSource) Token(ctx context.Context) (string, error) {
+	return string(s), nil
+}
+
+// Refresh implements TokenSource; static tokens cannot be refreshed
+func (s StaticTokenSource) Refresh(ctx context.Context) (string, error) {
+	return "", NewAuthError("authentication failed: static access token cannot be refreshed", nil)
+}
+
+// OAuthConfig holds the app credentials and refresh token for the OAuth2 refresh-token flow
+type OAuthConfig struct {
+	AppKey       string
+	AppSecret    string
+	RefreshToken string
+}
+
+// RefreshTokenSource is a TokenSource that exchanges a refresh token for
+// short-lived access tokens and persists them via the state manager
+type RefreshTokenSource struct {
+	config      OAuthConfig
+	state       interfaces.StateManager
+	httpClient  *http.Client
+	clock       Clock
+	mu          sync.Mutex
+	accessToken string
+	expiry      time.Time
+	loaded      bool
+	logger      *slog.Logger
+}
+
+// NewRefreshTokenSource creates a token source for the OAuth2 refresh-token flow.
+// The state manager is optional; without it tokens are kept in memory only.
+func NewRefreshTokenSource(config OAuthConfig, state interfaces.StateManager) (*RefreshTokenSource, error) {
+	if config.AppKey == "" {
+		return nil, NewInvalidInputError("app key cannot be empty", nil)
+	}
+	if config.RefreshToken == "" {
+		return nil, NewInvalidInputError("refresh token cannot be empty", nil)
+	}
+
+	return &RefreshTokenSource{
+		config:     config,
+		state:      state,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		clock:      &realClock{},
+		logger:     logging.Component(nil, "dropbox"),
+	}, nil
+}
+
+// Token implements TokenSource, refreshing the access token when it is missing or about to expire
+func (s *RefreshTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.loadPersisted()
+	if s.accessToken != "" && s.clock.Now().Add(tokenExpiryMargin).Before(s.expiry) {
+		return s.accessToken, nil
+	}
+
+	return s.refreshLocked(ctx)
+}
+
+// Refresh implements TokenSource
+func (s *RefreshTokenSource) Refresh(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.refreshLocked(ctx)
+}
+
+// loadPersisted reads a previously persisted access token from state once
+func (s *RefreshTokenSource) loadPersisted() {
+	if s.loaded || s.state == nil {
+		return
+	}
+	s.loaded = true
+
+	token := s.state.GetString(accessTokenStateKey)
+	expiry, err := time.Parse(time.RFC3339, s.state.GetString(tokenExpiryStateKey))
+	if token == "" || err != nil {
+		return
+	}
+	s.accessToken = token
+	s.expiry = expiry
+}
+
+// refreshLocked exchanges the refresh token for a new access token; s.mu must be held
+func (s *RefreshTokenSource) refreshLocked(ctx context.Context) (string, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {s.config.RefreshToken},
+		"client_id":     {s.config.AppKey},
+	}
+	if s.config.AppSecret != "" {
+		form.Set("client_secret", s.config.AppSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", NewInvalidInputError("failed to create token refresh request", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", NewNetworkError("token refresh request failed", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized:
+		return "", NewAuthError(fmt.Sprintf("failed to refresh access token: status %d", resp.StatusCode), nil)
+	default:
+		return "", NewServerError(fmt.Sprintf("failed to refresh access token: status %d", resp.StatusCode), nil)
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", NewServerError("failed to decode token refresh response", err)
+	}
+	if result.AccessToken == "" {
+		return "", NewAuthError("token refresh response did not contain an access token", nil)
+	}
+
+	s.accessToken = result.AccessToken
+	s.expiry = s.clock.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+	s.loaded = true
+	s.persist()
+
+	return s.accessToken, nil
+}
+
+// persist saves the current access token to state so restarts can reuse it
+func (s *RefreshTokenSource) persist() {
+	if s.state == nil {
+		return
+	}
+	if err := s.state.SetString(accessTokenStateKey, s.accessToken); err != nil {
+		s.logger.Error("Failed to persist Dropbox access token", "error", err)
+		return
+	}
+	if err := s.state.SetString(tokenExpiryStateKey, s.expiry.Format(time.RFC3339)); err != nil {
+		s.logger.Error("Failed to persist Dropbox access token expiry", "error", err)
+	}
+}
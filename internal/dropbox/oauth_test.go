@@ -0,0 +1,162 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryState is an in-memory interfaces.StateManager
+type memoryState map[string]string
+
+func (m memoryState) GetString(key string) string {
+	return m[key]
+}
+
+func (m memoryState) SetString(key, value string) error {
+	m[key] = value
+	return nil
+}
+
+// setupTokenServer returns a token endpoint that issues numbered access tokens
+func setupTokenServer(t *testing.T, statusCode int) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "refresh_token", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "refresh-token", r.PostForm.Get("refresh_token"))
+		assert.Equal(t, "app-key", r.PostForm.Get("client_id"))
+		assert.Equal(t, "app-secret", r.PostForm.Get("client_secret"))
+
+		w.WriteHeader(statusCode)
+		if statusCode == http.StatusOK {
+			fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "bearer", "expires_in": 14400}`, n)
+		}
+	}))
+
+	origURL := tokenURL
+	tokenURL = server.URL
+	t.Cleanup(func() {
+		tokenURL = origURL
+		server.Close()
+	})
+	return server, &calls
+}
+
+func testOAuthConfig() OAuthConfig {
+	return OAuthConfig{AppKey: "app-key", AppSecret: "app-secret", RefreshToken: "refresh-token"}
+}
+
+func TestNewRefreshTokenSource(t *testing.T) {
+	_, err := NewRefreshTokenSource(OAuthConfig{RefreshToken: "refresh-token"}, nil)
+	assert.Error(t, err)
+
+	_, err = NewRefreshTokenSource(OAuthConfig{AppKey: "app-key"}, nil)
+	assert.Error(t, err)
+
+	source, err := NewRefreshTokenSource(testOAuthConfig(), nil)
+	require.NoError(t, err)
+	assert.NotNil(t, source)
+}
+
+func TestRefreshTokenSource_Token(t *testing.T) {
+	_, calls := setupTokenServer(t, http.StatusOK)
+	state := memoryState{}
+
+	source, err := NewRefreshTokenSource(testOAuthConfig(), state)
+	require.NoError(t, err)
+
+	token, err := source.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+	assert.Equal(t, "token-1", state[accessTokenStateKey])
+	assert.NotEmpty(t, state[tokenExpiryStateKey])
+
+	// Cached token is reused until it nears expiry
+	token, err = source.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+
+	clock := newMockClock()
+	source.clock = clock
+	clock.Sleep(4 * time.Hour)
+	token, err = source.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token)
+}
+
+func TestRefreshTokenSource_UsesPersistedToken(t *testing.T) {
+	_, calls := setupTokenServer(t, http.StatusOK)
+	state := memoryState{
+		accessTokenStateKey: "persisted-token",
+		tokenExpiryStateKey: time.Now().Add(time.Hour).Format(time.RFC3339),
+	}
+
+	source, err := NewRefreshTokenSource(testOAuthConfig(), state)
+	require.NoError(t, err)
+
+	token, err := source.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "persisted-token", token)
+	assert.Equal(t, int32(0), atomic.LoadInt32(calls))
+}
+
+func TestRefreshTokenSource_RefreshRejected(t *testing.T) {
+	setupTokenServer(t, http.StatusBadRequest)
+
+	source, err := NewRefreshTokenSource(testOAuthConfig(), nil)
+	require.NoError(t, err)
+
+	_, err = source.Token(context.Background())
+	require.Error(t, err)
+	var dbErr *Error
+	require.True(t, errors.As(err, &dbErr))
+	assert.Equal(t, ErrorTypeAuth, dbErr.Type)
+}
+
+func TestDropboxClient_RetriesAfterTokenRefresh(t *testing.T) {
+	_, calls := setupTokenServer(t, http.StatusOK)
+	state := memoryState{
+		accessTokenStateKey: "expired-token",
+		tokenExpiryStateKey: time.Now().Add(time.Hour).Format(time.RFC3339),
+	}
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"entries": [{".tag": "file", "name": "test.txt", "path_display": "/test.txt", "server_modified": "2021-01-01T00:00:00Z"}]}`))
+	}))
+	defer api.Close()
+
+	origURL := listFolderURL
+	listFolderURL = api.URL + "/2/files/list_folder"
+	defer func() { listFolderURL = origURL }()
+
+	source, err := NewRefreshTokenSource(testOAuthConfig(), state)
+	require.NoError(t, err)
+
+	config := DefaultClientConfig()
+	config.RetryConfig = RetryConfig{MaxRetries: 0, InitialWait: time.Millisecond, MaxWait: time.Millisecond}
+	client, err := NewDropboxClientWithTokenSource(source, config)
+	require.NoError(t, err)
+
+	files, err := client.ListFolder(context.Background(), "/test")
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "/test.txt", files[0].Path)
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+	assert.Equal(t, "token-1", state[accessTokenStateKey])
+}
@@ -0,0 +1,157 @@
+package dropbox
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// Defaults for pausing requests when the API rate limit is exhausted
+const (
+	// DefaultRateLimitPauseAfter is how long requests must keep being rate
+	// limited before they are paused
+	DefaultRateLimitPauseAfter = 5 * time.Minute
+	// DefaultRateLimitPause is how long requests are paused when Dropbox
+	// gives no Retry-After hint
+	DefaultRateLimitPause = 15 * time.Minute
+)
+
+// quotaGapReason describes gaps caused by a rate limit pause
+const quotaGapReason = "Dropbox API rate limit exhausted"
+
+// RateLimitPauseConfig controls how the client stops calling Dropbox while
+// its API quota is exhausted; zero values use the defaults
+type RateLimitPauseConfig struct {
+	After time.Duration
+	Pause time.Duration
+}
+
+// rateLimitPause suspends requests once rate limiting persists, until the
+// time Dropbox hinted, and records the resulting gaps in monitoring
+type rateLimitPause struct {
+	config RateLimitPauseConfig
+	now    func() time.Time
+	logger *slog.Logger
+
+	mu sync.Mutex
+	// limitedSince is the first rate limited response since the last success
+	limitedSince time.Time
+	until        time.Time
+	gaps         []models.MonitoringGap
+}
+
+// newRateLimitPause creates a pause tracker, filling in the defaults
+func newRateLimitPause(config RateLimitPauseConfig) *rateLimitPause {
+	if config.After <= 0 {
+		config.After = DefaultRateLimitPauseAfter
+	}
+	if config.Pause <= 0 {
+		config.Pause = DefaultRateLimitPause
+	}
+	return &rateLimitPause{config: config, now: time.Now, logger: logging.Component(nil, "dropbox")}
+}
+
+// check returns a quota paused error while requests are paused
+func (p *rateLimitPause) check() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.now().Before(p.until) {
+		return NewQuotaPausedError(p.until)
+	}
+	return nil
+}
+
+// limited records a rate limited response and returns when requests resume
+// if it starts a pause, or the zero time otherwise
+func (p *rateLimitPause) limited(retryAfter time.Duration) time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	if p.limitedSince.IsZero() {
+		p.limitedSince = now
+	}
+	if now.Sub(p.limitedSince) < p.config.After {
+		return time.Time{}
+	}
+
+	if retryAfter <= 0 {
+		retryAfter = p.config.Pause
+	}
+	p.until = now.Add(retryAfter)
+	quotaPaused.Set(1)
+	p.logger.Warn("Dropbox API rate limited, pausing requests",
+		"since", p.limitedSince.Format(time.RFC3339), "until", p.until.Format(time.RFC3339))
+	return p.until
+}
+
+// succeeded records a successful response, closing the gap of a pause
+func (p *rateLimitPause) succeeded() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.until.IsZero() {
+		p.gaps = append(p.gaps, models.MonitoringGap{Start: p.limitedSince, End: p.now(), Reason: quotaGapReason})
+		p.until = time.Time{}
+		quotaPaused.Set(0)
+		p.logger.Info("Dropbox API requests resumed")
+	}
+	p.limitedSince = time.Time{}
+}
+
+// status returns when requests resume and whether they are paused
+func (p *rateLimitPause) status() (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.until, p.now().Before(p.until)
+}
+
+// QuotaPause returns when requests resume and whether they are paused
+// because the API rate limit is exhausted
+func (c *DropboxClient) QuotaPause() (time.Time, bool) {
+	return c.pause.status()
+}
+
+// MonitoringGaps returns the periods requests were paused by the rate limit
+// that have not been reported yet
+func (c *DropboxClient) MonitoringGaps() []models.MonitoringGap {
+	c.pause.mu.Lock()
+	defer c.pause.mu.Unlock()
+	return append([]models.MonitoringGap(nil), c.pause.gaps...)
+}
+
+// GapsReported forgets the gaps that ended before until, once a report
+// including them was sent
+func (c *DropboxClient) GapsReported(until time.Time) {
+	c.pause.mu.Lock()
+	defer c.pause.mu.Unlock()
+
+	kept := c.pause.gaps[:0]
+	for _, gap := range c.pause.gaps {
+		if gap.End.After(until) {
+			kept = append(kept, gap)
+		}
+	}
+	c.pause.gaps = kept
+}
+
+// retryAfter parses the Retry-After header of a response, in seconds or as
+// an HTTP date; zero means no hint
+func retryAfter(header http.Header, now time.Time) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,98 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDropboxClient_RateLimitPause(t *testing.T) {
+	limited := true
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if limited {
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"cursor": "c1"}`)
+	}))
+	defer server.Close()
+
+	config := DefaultClientConfig()
+	config.RetryConfig = RetryConfig{MaxRetries: 0}
+	config.CircuitBreakerConfig.MaxFailures = 100
+	config.RateLimitPause = RateLimitPauseConfig{After: 10 * time.Minute}
+	client := setupTestClient(t, server, config)
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	client.pause.now = func() time.Time { return now }
+
+	orig := getLatestCursorURL
+	getLatestCursorURL = server.URL + "/2/files/list_folder/get_latest_cursor"
+	defer func() { getLatestCursorURL = orig }()
+
+	// Short bursts of rate limiting are retried as usual
+	_, err := client.GetLatestCursor(context.Background(), "")
+	var dbErr *Error
+	require.True(t, errors.As(err, &dbErr))
+	assert.Equal(t, ErrorTypeRateLimit, dbErr.Type)
+	assert.False(t, dbErr.ResumeAt.IsZero(), "the Retry-After hint is kept")
+	_, paused := client.QuotaPause()
+	assert.False(t, paused)
+
+	// Once it persists, requests pause until the hinted time
+	now = now.Add(10 * time.Minute)
+	_, err = client.GetLatestCursor(context.Background(), "")
+	require.True(t, errors.As(err, &dbErr))
+	assert.Equal(t, ErrorTypeQuotaPaused, dbErr.Type)
+	assert.Equal(t, now.Add(time.Minute), dbErr.ResumeAt)
+	resumeAt, paused := client.QuotaPause()
+	assert.True(t, paused)
+	assert.Equal(t, now.Add(time.Minute), resumeAt)
+
+	// Paused requests do not reach Dropbox
+	_, err = client.GetLatestCursor(context.Background(), "")
+	require.True(t, errors.As(err, &dbErr))
+	assert.Equal(t, ErrorTypeQuotaPaused, dbErr.Type)
+	assert.Equal(t, 2, requests)
+
+	// After the pause, a successful request closes the gap
+	limited = false
+	start := now.Add(-10 * time.Minute)
+	now = now.Add(2 * time.Minute)
+	_, err = client.GetLatestCursor(context.Background(), "")
+	require.NoError(t, err)
+	_, paused = client.QuotaPause()
+	assert.False(t, paused)
+	gaps := client.MonitoringGaps()
+	require.Len(t, gaps, 1)
+	assert.Equal(t, start, gaps[0].Start)
+	assert.Equal(t, now, gaps[0].End)
+	assert.Equal(t, 12*time.Minute, gaps[0].Duration())
+
+	client.GapsReported(now)
+	assert.Empty(t, client.MonitoringGaps())
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	header := http.Header{}
+	assert.Zero(t, retryAfter(header, now))
+
+	header.Set("Retry-After", "30")
+	assert.Equal(t, 30*time.Second, retryAfter(header, now))
+
+	header.Set("Retry-After", now.Add(2*time.Minute).Format(http.TimeFormat))
+	assert.Equal(t, 2*time.Minute, retryAfter(header, now))
+
+	header.Set("Retry-After", "soon")
+	assert.Zero(t, retryAfter(header, now))
+}
@@ -0,0 +1,105 @@
+package dropbox
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Defaults for the request rate limit shared by all requests of a client
+const (
+	DefaultRequestsPerSecond = 10
+	DefaultRequestBurst      = 20
+)
+
+// RateLimitConfig spreads a client's requests over time with a token
+// bucket. Zero values use the defaults; a negative rate disables the limit,
+// though Retry-After hints from Dropbox are still honored.
+type RateLimitConfig struct {
+	RequestsPerSecond float64
+	Burst             int
+}
+
+// rateLimiter is a token bucket shared by all requests of a client. A
+// Retry-After hint holds back every request until it passes, not just the
+// retry of the request that was rate limited.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	// blockedUntil is when the last Retry-After hint expires
+	blockedUntil time.Time
+}
+
+// newRateLimiter creates a limiter with a full bucket, filling in the defaults
+func newRateLimiter(config RateLimitConfig) *rateLimiter {
+	if config.RequestsPerSecond == 0 {
+		config.RequestsPerSecond = DefaultRequestsPerSecond
+	}
+	if config.Burst <= 0 {
+		config.Burst = DefaultRequestBurst
+	}
+	l := &rateLimiter{rate: config.RequestsPerSecond, burst: float64(config.Burst), now: time.Now}
+	if l.rate < 0 {
+		l.rate = 0
+	}
+	l.tokens = l.burst
+	l.last = l.now()
+	return l
+}
+
+// reserve takes a token and returns how long the caller must wait before
+// sending its request
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var wait time.Duration
+	if l.rate > 0 {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+		// A negative balance reserves tokens that have yet to be refilled
+		l.tokens--
+		if l.tokens < 0 {
+			wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+		}
+	}
+	if blocked := l.blockedUntil.Sub(now); blocked > wait {
+		wait = blocked
+	}
+	return wait
+}
+
+// wait blocks until the request may be sent or ctx is done, recording how
+// long it waited
+func (l *rateLimiter) wait(ctx context.Context) (time.Duration, error) {
+	if l == nil {
+		return 0, nil
+	}
+	wait := l.reserve()
+	if wait <= 0 {
+		return 0, nil
+	}
+	rateLimitWait.Observe(wait.Seconds())
+	return wait, sleepContext(ctx, wait)
+}
+
+// backoff holds back every request until at, as hinted by Retry-After
+func (l *rateLimiter) backoff(at time.Time) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if at.After(l.blockedUntil) {
+		l.blockedUntil = at
+	}
+}
@@ -0,0 +1,88 @@
+package dropbox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter(RateLimitConfig{RequestsPerSecond: 2, Burst: 2})
+	limiter.now = func() time.Time { return now }
+	limiter.last = now
+
+	// The burst is available at once, then requests are spaced out
+	assert.Zero(t, limiter.reserve())
+	assert.Zero(t, limiter.reserve())
+	assert.Equal(t, 500*time.Millisecond, limiter.reserve())
+	assert.Equal(t, time.Second, limiter.reserve())
+
+	// Tokens refill over time, up to the burst
+	now = now.Add(10 * time.Second)
+	assert.Zero(t, limiter.reserve())
+	assert.Zero(t, limiter.reserve())
+	assert.Equal(t, 500*time.Millisecond, limiter.reserve())
+
+	// A Retry-After hint holds back every request until it passes
+	now = now.Add(10 * time.Second)
+	limiter.backoff(now.Add(30 * time.Second))
+	limiter.backoff(now.Add(10 * time.Second))
+	assert.Equal(t, 30*time.Second, limiter.reserve(), "a shorter hint does not shorten the wait")
+	now = now.Add(30 * time.Second)
+	assert.Zero(t, limiter.reserve())
+}
+
+func TestRateLimiter_Disabled(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter(RateLimitConfig{RequestsPerSecond: -1})
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 100; i++ {
+		require.Zero(t, limiter.reserve())
+	}
+	limiter.backoff(now.Add(time.Minute))
+	assert.Equal(t, time.Minute, limiter.reserve(), "Retry-After hints are honored without a rate")
+
+	var nilLimiter *rateLimiter
+	waited, err := nilLimiter.wait(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, waited)
+}
+
+func TestDropboxClient_RetryAfterHoldsBackRequests(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"cursor": "c1"}`)
+	}))
+	defer server.Close()
+
+	config := DefaultClientConfig()
+	config.RetryConfig = RetryConfig{MaxRetries: 1, InitialWait: time.Millisecond, MaxWait: time.Millisecond}
+	client := setupTestClient(t, server, config)
+	client.limiter = newRateLimiter(RateLimitConfig{})
+
+	orig := getLatestCursorURL
+	getLatestCursorURL = server.URL + "/2/files/list_folder/get_latest_cursor"
+	defer func() { getLatestCursorURL = orig }()
+
+	start := time.Now()
+	cursor, err := client.GetLatestCursor(context.Background(), "")
+	require.NoError(t, err)
+	assert.Equal(t, "c1", cursor)
+	assert.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond, "the retry waits for the Retry-After hint")
+	assert.Equal(t, 2, requests)
+	assert.Greater(t, client.MetricsSnapshot().RateLimitWait, 800*time.Millisecond)
+}
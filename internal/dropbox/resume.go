@@ -0,0 +1,233 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// SyncProgress is the checkpoint of a listing of every file under a folder,
+// from which an interrupted listing resumes instead of starting over. The
+// zero value starts a new listing.
+type SyncProgress struct {
+	// Cursor continues a sequential listing
+	Cursor string `json:"cursor,omitempty"`
+	// ChangesCursor is the cursor taken before a parallel walk, reporting
+	// the changes made while it runs
+	ChangesCursor string `json:"changes_cursor,omitempty"`
+	// Pending are the folders a parallel walk has yet to list
+	Pending []string `json:"pending,omitempty"`
+	// Pages is how many pages, or folders when walking, have been listed
+	Pages int `json:"pages"`
+	// Files is how many files have been listed
+	Files int `json:"files"`
+	// StartedAt is when the listing began
+	StartedAt time.Time `json:"started_at"`
+}
+
+// PageHandler receives each page of files of a resumable listing, along with
+// the progress to resume from once the page has been handled
+type PageHandler func(files []*models.FileMetadata, progress SyncProgress) error
+
+// ListFolderResumable lists every file under path, "" or "/" being the
+// Dropbox root, passing them to onPage a page at a time, and returns the
+// cursor for later changes. progress, when not zero, resumes a listing that
+// was interrupted. Folders are listed in parallel, a folder per page, when
+// the client is configured with several traversal workers.
+func (c *DropboxClient) ListFolderResumable(ctx context.Context, path string, progress SyncProgress, onPage PageHandler) (string, error) {
+	if path == "/" {
+		path = ""
+	}
+	if progress.StartedAt.IsZero() {
+		progress.StartedAt = time.Now()
+	}
+
+	// A listing resumes the way it started
+	walk := c.config.TraversalWorkers > 1
+	if progress.Cursor != "" {
+		walk = false
+	} else if progress.ChangesCursor != "" {
+		walk = true
+	}
+	if walk {
+		return c.walkResumable(ctx, path, progress, onPage)
+	}
+	return c.listResumable(ctx, path, progress, onPage)
+}
+
+// listResumable lists path recursively one page at a time. Each page's
+// progress holds the cursor of the next page, or the cursor for later
+// changes after the last page.
+func (c *DropboxClient) listResumable(ctx context.Context, path string, progress SyncProgress, onPage PageHandler) (string, error) {
+	var result listFolderResult
+	if progress.Cursor == "" {
+		// Deleted entries are requested so the cursor reports later deletions
+		body := map[string]interface{}{
+			"path":            path,
+			"recursive":       true,
+			"include_deleted": true,
+		}
+		if err := c.postJSON(ctx, listFolderURL, body, &result); err != nil {
+			return "", err
+		}
+	} else if err := c.postJSON(ctx, listFolderContinueURL, map[string]string{"cursor": progress.Cursor}, &result); err != nil {
+		return "", err
+	}
+
+	for {
+		files := make([]*models.FileMetadata, 0, len(result.Entries))
+		for i := range result.Entries {
+			file, err := c.toChangedFileMetadata(&result.Entries[i])
+			if err != nil {
+				return "", err
+			}
+			if file != nil && !file.IsDeleted {
+				files = append(files, file)
+			}
+		}
+
+		progress.Cursor = result.Cursor
+		progress.Pages++
+		progress.Files += len(files)
+		if err := onPage(files, progress); err != nil {
+			return "", err
+		}
+		if !result.HasMore {
+			return result.Cursor, nil
+		}
+
+		result = listFolderResult{}
+		if err := c.postJSON(ctx, listFolderContinueURL, map[string]string{"cursor": progress.Cursor}, &result); err != nil {
+			return "", err
+		}
+	}
+}
+
+// walkResumable lists the folders under path in parallel, as Walk does,
+// passing each folder's files to onPage. The progress holds the folders not
+// yet listed, so a resumed walk lists only those; folders being listed when
+// the walk was interrupted are listed again.
+func (c *DropboxClient) walkResumable(ctx context.Context, path string, progress SyncProgress, onPage PageHandler) (string, error) {
+	if progress.ChangesCursor == "" {
+		// The cursor is taken first so changes made during the walk are
+		// reported afterwards rather than missed
+		cursor, err := c.GetLatestCursor(ctx, path)
+		if err != nil {
+			return "", err
+		}
+		progress.ChangesCursor = cursor
+		progress.Pending = []string{path}
+	}
+	workers := c.config.TraversalWorkers
+	if workers <= 1 {
+		workers = DefaultTraversalWorkers
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	pending := make(map[string]bool, len(progress.Pending))
+	for _, folder := range progress.Pending {
+		pending[folder] = true
+	}
+	slots := make(chan struct{}, workers)
+
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	var visit func(folder string)
+	visit = func(folder string) {
+		defer wg.Done()
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		entries, err := c.listFolderEntries(ctx, folder)
+		<-slots
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			// A folder deleted since it was found has nothing left to list
+			var dbErr *Error
+			if folder == path || !errors.As(err, &dbErr) || dbErr.Type != ErrorTypeConflict {
+				fail(err)
+				return
+			}
+			entries = nil
+		}
+
+		var files []*models.FileMetadata
+		var subfolders []string
+		for i := range entries {
+			entry := &entries[i]
+			if entry.Tag == "folder" {
+				subfolders = append(subfolders, entry.folderPath())
+				continue
+			}
+			file, err := c.toChangedFileMetadata(entry)
+			if err != nil {
+				fail(err)
+				return
+			}
+			if file != nil && !file.IsDeleted {
+				files = append(files, file)
+			}
+		}
+
+		delete(pending, folder)
+		for _, subfolder := range subfolders {
+			pending[subfolder] = true
+		}
+		progress.Pending = sortedKeys(pending)
+		progress.Pages++
+		progress.Files += len(files)
+		if err := onPage(files, progress); err != nil {
+			fail(err)
+			return
+		}
+
+		for _, subfolder := range subfolders {
+			wg.Add(1)
+			go visit(subfolder)
+		}
+	}
+
+	for _, folder := range progress.Pending {
+		wg.Add(1)
+		go visit(folder)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return "", firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return progress.ChangesCursor, nil
+}
+
+// sortedKeys returns the keys of set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,123 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pagedServer serves a recursive listing in three pages, recording the
+// requests it receives
+func pagedServer(t *testing.T, requests *[]string) *httptest.Server {
+	pages := map[string]string{
+		"": `{"entries": [{".tag": "file", "path_display": "/a.txt", "server_modified": "2021-01-01T00:00:00Z", "size": 10}],
+			"cursor": "c1", "has_more": true}`,
+		"c1": `{"entries": [{".tag": "file", "path_display": "/b.txt", "server_modified": "2021-01-02T00:00:00Z", "size": 20}],
+			"cursor": "c2", "has_more": true}`,
+		"c2": `{"entries": [{".tag": "file", "path_display": "/c.txt", "server_modified": "2021-01-03T00:00:00Z", "size": 30}],
+			"cursor": "final", "has_more": false}`,
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		switch r.URL.Path {
+		case "/2/files/list_folder":
+			assert.Equal(t, true, body["recursive"])
+			*requests = append(*requests, "list")
+			fmt.Fprint(w, pages[""])
+		case "/2/files/list_folder/continue":
+			cursor := body["cursor"].(string)
+			*requests = append(*requests, cursor)
+			fmt.Fprint(w, pages[cursor])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+// interruptAfter returns a page handler that collects the listed paths and
+// the latest progress, failing once pages pages have been handled
+func interruptAfter(pages int, paths *[]string, progress *SyncProgress) PageHandler {
+	return func(files []*models.FileMetadata, p SyncProgress) error {
+		if pages >= 0 && p.Pages > pages {
+			return errors.New("interrupted")
+		}
+		for _, file := range files {
+			*paths = append(*paths, file.Path)
+		}
+		*progress = p
+		return nil
+	}
+}
+
+func TestDropboxClient_ListFolderResumable(t *testing.T) {
+	var requests []string
+	server := pagedServer(t, &requests)
+	defer server.Close()
+	useServer(t, server)
+
+	client := setupTestClient(t, server, DefaultClientConfig())
+	ctx := context.Background()
+
+	var paths []string
+	var progress SyncProgress
+	_, err := client.ListFolderResumable(ctx, "/", SyncProgress{}, interruptAfter(1, &paths, &progress))
+	require.Error(t, err)
+	assert.Equal(t, []string{"/a.txt"}, paths)
+	assert.Equal(t, "c1", progress.Cursor)
+	assert.Equal(t, 1, progress.Pages)
+	assert.False(t, progress.StartedAt.IsZero())
+
+	// The resumed listing continues from the second page
+	requests = nil
+	cursor, err := client.ListFolderResumable(ctx, "/", progress, interruptAfter(-1, &paths, &progress))
+	require.NoError(t, err)
+	assert.Equal(t, "final", cursor)
+	assert.Equal(t, []string{"/a.txt", "/b.txt", "/c.txt"}, paths)
+	assert.Equal(t, []string{"c1", "c2"}, requests)
+	assert.Equal(t, 3, progress.Pages)
+	assert.Equal(t, 3, progress.Files)
+}
+
+func TestDropboxClient_ListFolderResumableWalk(t *testing.T) {
+	var inFlight, maxInFlight int32
+	server := treeServer(t, &inFlight, &maxInFlight)
+	defer server.Close()
+	useServer(t, server)
+
+	config := DefaultClientConfig()
+	config.TraversalWorkers = 2
+	client := setupTestClient(t, server, config)
+	ctx := context.Background()
+
+	// Stop after the root folder has been listed
+	var paths []string
+	var progress SyncProgress
+	_, err := client.ListFolderResumable(ctx, "", SyncProgress{}, interruptAfter(1, &paths, &progress))
+	require.Error(t, err)
+	assert.ElementsMatch(t, []string{"/a.txt", "/z.txt"}, paths)
+	assert.Equal(t, "latest", progress.ChangesCursor)
+	assert.Equal(t, []string{"/docs", "/photos"}, progress.Pending)
+
+	// The resumed walk lists only the pending folders, plus a folder that
+	// was deleted meanwhile
+	progress.Pending = append(progress.Pending, "/deleted")
+	paths = nil
+	cursor, err := client.ListFolderResumable(ctx, "", progress, interruptAfter(-1, &paths, &progress))
+	require.NoError(t, err)
+	assert.Equal(t, "latest", cursor)
+	sort.Strings(paths)
+	assert.Equal(t, []string{"/Docs/2021/c.txt", "/Docs/b.txt"}, paths)
+	assert.Empty(t, progress.Pending)
+	assert.Equal(t, 4, progress.Files)
+}
@@ -0,0 +1,60 @@
+package dropbox
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// listRevisionsURL is a variable so tests can point it at a local server
+var listRevisionsURL = "https://api.dropboxapi.com/2/files/list_revisions"
+
+// MaxRevisions is the most revisions Dropbox returns for a file
+const MaxRevisions = 100
+
+// listRevisionsResult is the response of list_revisions
+type listRevisionsResult struct {
+	IsDeleted bool                  `json:"is_deleted"`
+	Entries   []dropboxFileMetadata `json:"entries"`
+}
+
+// ListRevisions returns up to limit of the latest revisions of the file at
+// path, oldest first, with their modification times, sizes, content hashes
+// and, in shared folders, the account that made them. A limit outside 1 to
+// MaxRevisions asks for MaxRevisions.
+func (c *DropboxClient) ListRevisions(ctx context.Context, path string, limit int) ([]*models.FileMetadata, error) {
+	if path == "" {
+		return nil, NewInvalidInputError("path cannot be empty", nil)
+	}
+	if limit < 1 || limit > MaxRevisions {
+		limit = MaxRevisions
+	}
+
+	body := map[string]interface{}{
+		"path":  path,
+		"mode":  "path",
+		"limit": limit,
+	}
+	var result listRevisionsResult
+	if err := c.postJSON(ctx, listRevisionsURL, body, &result); err != nil {
+		return nil, err
+	}
+
+	// Dropbox lists the newest revision first
+	revisions := make([]*models.FileMetadata, 0, len(result.Entries))
+	for i := len(result.Entries) - 1; i >= 0; i-- {
+		entry := &result.Entries[i]
+		if entry.PathDisplay == "" {
+			entry.PathDisplay = path
+		}
+		revision, err := c.toChangedFileMetadata(entry)
+		if err != nil {
+			return nil, NewServerError(fmt.Sprintf("failed to convert revision %s of %s", entry.Rev, path), err)
+		}
+		if revision != nil {
+			revisions = append(revisions, revision)
+		}
+	}
+	return revisions, nil
+}
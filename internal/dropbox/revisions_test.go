@@ -0,0 +1,50 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDropboxClient_ListRevisions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "/docs/plan.txt", body["path"])
+		assert.Equal(t, "path", body["mode"])
+		assert.Equal(t, float64(MaxRevisions), body["limit"])
+
+		fmt.Fprint(w, `{"is_deleted": false, "entries": [
+			{"path_display": "/Docs/plan.txt", "rev": "2", "size": 20, "server_modified": "2021-02-01T00:00:00Z",
+				"content_hash": "h2", "sharing_info": {"modified_by": "dbid:1"}},
+			{"path_display": "/Docs/plan.txt", "rev": "1", "size": 10, "server_modified": "2021-01-01T00:00:00Z",
+				"content_hash": "h1"}]}`)
+	}))
+	defer server.Close()
+
+	client := setupTestClient(t, server, DefaultClientConfig())
+	orig := listRevisionsURL
+	listRevisionsURL = server.URL + "/2/files/list_revisions"
+	defer func() { listRevisionsURL = orig }()
+
+	revisions, err := client.ListRevisions(context.Background(), "/docs/plan.txt", 0)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+
+	// The oldest revision comes first
+	assert.Equal(t, "1", revisions[0].Rev)
+	assert.Equal(t, int64(10), revisions[0].Size)
+	assert.Equal(t, "2", revisions[1].Rev)
+	assert.Equal(t, "h2", revisions[1].ContentHash)
+	assert.Equal(t, "dbid:1", revisions[1].ModifiedByID)
+	assert.Equal(t, "/Docs/plan.txt", revisions[1].Path)
+
+	_, err = client.ListRevisions(context.Background(), "", 10)
+	assert.Error(t, err)
+}
@@ -0,0 +1,165 @@
+package dropbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// Sharing and Paper endpoints; variables so tests can point them at a local server
+var (
+	listSharedLinksURL   = "https://api.dropboxapi.com/2/sharing/list_shared_links"
+	paperDocsListURL     = "https://api.dropboxapi.com/2/paper/docs/list"
+	paperDocsContinueURL = "https://api.dropboxapi.com/2/paper/docs/list/continue"
+	paperDocsMetadataURL = "https://api.dropboxapi.com/2/paper/docs/get_metadata"
+)
+
+// paperDocsPageSize is the number of Paper doc IDs requested per page
+const paperDocsPageSize = 100
+
+// sharedLinksResult is the response of list_shared_links
+type sharedLinksResult struct {
+	Links []struct {
+		URL             string `json:"url"`
+		Name            string `json:"name"`
+		PathLower       string `json:"path_lower"`
+		Expires         string `json:"expires"`
+		LinkPermissions struct {
+			ResolvedVisibility struct {
+				Tag string `json:".tag"`
+			} `json:"resolved_visibility"`
+		} `json:"link_permissions"`
+	} `json:"links"`
+	HasMore bool   `json:"has_more"`
+	Cursor  string `json:"cursor"`
+}
+
+// ListSharedLinks returns every shared link created by the account
+func (c *DropboxClient) ListSharedLinks(ctx context.Context) ([]models.SharedLink, error) {
+	var result sharedLinksResult
+	if err := c.postJSON(ctx, listSharedLinksURL, map[string]string{}, &result); err != nil {
+		return nil, err
+	}
+
+	links := make([]models.SharedLink, 0, len(result.Links))
+	for {
+		for _, raw := range result.Links {
+			link := models.SharedLink{
+				URL:        raw.URL,
+				Name:       raw.Name,
+				Path:       raw.PathLower,
+				Visibility: raw.LinkPermissions.ResolvedVisibility.Tag,
+			}
+			if raw.Expires != "" {
+				expires, err := time.Parse(time.RFC3339, raw.Expires)
+				if err != nil {
+					return nil, NewServerError(fmt.Sprintf("invalid expiry for shared link %s", raw.URL), err)
+				}
+				link.Expires = expires
+			}
+			links = append(links, link)
+		}
+		if !result.HasMore {
+			return links, nil
+		}
+
+		cursor := result.Cursor
+		result = sharedLinksResult{}
+		if err := c.postJSON(ctx, listSharedLinksURL, map[string]string{"cursor": cursor}, &result); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// paperDocsListResult is the response of the Paper doc list endpoints
+type paperDocsListResult struct {
+	DocIDs []string `json:"doc_ids"`
+	Cursor struct {
+		Value string `json:"value"`
+	} `json:"cursor"`
+	HasMore bool `json:"has_more"`
+}
+
+// paperDocMetadata is the response of get_metadata
+type paperDocMetadata struct {
+	DocID           string `json:"doc_id"`
+	Owner           string `json:"owner"`
+	Title           string `json:"title"`
+	CreatedDate     string `json:"created_date"`
+	LastUpdatedDate string `json:"last_updated_date"`
+	LastEditor      string `json:"last_editor"`
+	Revision        int64  `json:"revision"`
+	Status          struct {
+		Tag string `json:".tag"`
+	} `json:"status"`
+}
+
+// ListPaperDocs returns the Paper docs the account has accessed that were
+// updated after since, most recently updated first. Docs in the new Paper
+// format are .paper files and appear as regular file changes instead.
+func (c *DropboxClient) ListPaperDocs(ctx context.Context, since time.Time) ([]models.PaperDoc, error) {
+	body := map[string]interface{}{
+		"filter_by":  map[string]string{".tag": "docs_accessed"},
+		"sort_by":    map[string]string{".tag": "modified"},
+		"sort_order": map[string]string{".tag": "descending"},
+		"limit":      paperDocsPageSize,
+	}
+	var result paperDocsListResult
+	if err := c.postJSON(ctx, paperDocsListURL, body, &result); err != nil {
+		return nil, err
+	}
+
+	docs := make([]models.PaperDoc, 0)
+	for {
+		for _, id := range result.DocIDs {
+			doc, err := c.getPaperDoc(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if !doc.Updated.After(since) {
+				// Docs are sorted by modification, so the rest are older
+				return docs, nil
+			}
+			docs = append(docs, *doc)
+		}
+		if !result.HasMore {
+			return docs, nil
+		}
+
+		cursor := result.Cursor.Value
+		result = paperDocsListResult{}
+		if err := c.postJSON(ctx, paperDocsContinueURL, map[string]string{"cursor": cursor}, &result); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// getPaperDoc fetches the metadata of one Paper doc
+func (c *DropboxClient) getPaperDoc(ctx context.Context, id string) (*models.PaperDoc, error) {
+	var raw paperDocMetadata
+	if err := c.postJSON(ctx, paperDocsMetadataURL, map[string]string{"doc_id": id}, &raw); err != nil {
+		return nil, err
+	}
+
+	created, err := time.Parse(time.RFC3339, raw.CreatedDate)
+	if err != nil {
+		return nil, NewServerError(fmt.Sprintf("invalid created date for Paper doc %s", id), err)
+	}
+	updated, err := time.Parse(time.RFC3339, raw.LastUpdatedDate)
+	if err != nil {
+		return nil, NewServerError(fmt.Sprintf("invalid update date for Paper doc %s", id), err)
+	}
+
+	return &models.PaperDoc{
+		ID:         raw.DocID,
+		Title:      raw.Title,
+		Owner:      raw.Owner,
+		LastEditor: raw.LastEditor,
+		Status:     raw.Status.Tag,
+		Revision:   raw.Revision,
+		Created:    created,
+		Updated:    updated,
+	}, nil
+}
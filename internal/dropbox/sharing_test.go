@@ -0,0 +1,90 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDropboxClient_ListSharedLinks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if body["cursor"] == "" {
+			fmt.Fprint(w, `{"links": [{".tag": "file", "url": "https://db.tt/a", "name": "a.pdf",
+				"path_lower": "/docs/a.pdf", "expires": "2025-04-01T00:00:00Z",
+				"link_permissions": {"resolved_visibility": {".tag": "public"}}}], "has_more": true, "cursor": "c1"}`)
+			return
+		}
+		assert.Equal(t, "c1", body["cursor"])
+		fmt.Fprint(w, `{"links": [{".tag": "folder", "url": "https://db.tt/b", "name": "b"}], "has_more": false}`)
+	}))
+	defer server.Close()
+
+	client := setupTestClient(t, server, DefaultClientConfig())
+	orig := listSharedLinksURL
+	listSharedLinksURL = server.URL + "/2/sharing/list_shared_links"
+	defer func() { listSharedLinksURL = orig }()
+
+	links, err := client.ListSharedLinks(context.Background())
+	require.NoError(t, err)
+	require.Len(t, links, 2)
+	assert.Equal(t, "https://db.tt/a", links[0].URL)
+	assert.Equal(t, "/docs/a.pdf", links[0].Path)
+	assert.Equal(t, "public", links[0].Visibility)
+	assert.Equal(t, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), links[0].Expires)
+	assert.Equal(t, "b", links[1].Name)
+	assert.True(t, links[1].Expires.IsZero())
+}
+
+func TestDropboxClient_ListPaperDocs(t *testing.T) {
+	updated := map[string]string{"d1": "2025-03-02T10:00:00Z", "d2": "2025-03-01T12:00:00Z", "d3": "2025-02-01T00:00:00Z"}
+	var fetched []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		switch r.URL.Path {
+		case "/2/paper/docs/list":
+			assert.Equal(t, map[string]interface{}{".tag": "modified"}, body["sort_by"])
+			fmt.Fprint(w, `{"doc_ids": ["d1"], "cursor": {"value": "c1"}, "has_more": true}`)
+		case "/2/paper/docs/list/continue":
+			assert.Equal(t, "c1", body["cursor"])
+			fmt.Fprint(w, `{"doc_ids": ["d2", "d3"], "cursor": {"value": "c2"}, "has_more": false}`)
+		case "/2/paper/docs/get_metadata":
+			id := body["doc_id"].(string)
+			fetched = append(fetched, id)
+			fmt.Fprintf(w, `{"doc_id": %q, "owner": "jane@example.com", "title": "Doc %s",
+				"created_date": "2025-01-01T00:00:00Z", "last_updated_date": %q,
+				"last_editor": "sam@example.com", "revision": 3, "status": {".tag": "active"}}`, id, id, updated[id])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := setupTestClient(t, server, DefaultClientConfig())
+	origList, origContinue, origMetadata := paperDocsListURL, paperDocsContinueURL, paperDocsMetadataURL
+	paperDocsListURL = server.URL + "/2/paper/docs/list"
+	paperDocsContinueURL = server.URL + "/2/paper/docs/list/continue"
+	paperDocsMetadataURL = server.URL + "/2/paper/docs/get_metadata"
+	defer func() {
+		paperDocsListURL, paperDocsContinueURL, paperDocsMetadataURL = origList, origContinue, origMetadata
+	}()
+
+	docs, err := client.ListPaperDocs(context.Background(), time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	assert.Equal(t, "Doc d1", docs[0].Title)
+	assert.Equal(t, "sam@example.com", docs[0].LastEditor)
+	assert.Equal(t, int64(3), docs[0].Revision)
+	assert.Equal(t, "d2", docs[1].ID)
+	assert.Equal(t, []string{"d1", "d2", "d3"}, fetched, "listing stops at the first older doc")
+}
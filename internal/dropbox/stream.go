@@ -0,0 +1,154 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// maxStreamResumes is how many times a streamed download resumes after its
+// connection breaks
+const maxStreamResumes = 3
+
+// GetFileContentStream opens a download of the file at path. Unlike
+// GetFileContent the content is neither buffered nor limited in size. When
+// the connection breaks mid-download the stream resumes from the last byte
+// read with a range request, and once fully read the content is checked
+// against its Dropbox content hash. The caller must close the stream.
+func (c *DropboxClient) GetFileContentStream(ctx context.Context, path string) (io.ReadCloser, error) {
+	if path == "" {
+		return nil, NewInvalidInputError("path cannot be empty", nil)
+	}
+
+	arg, err := json.Marshal(map[string]interface{}{"path": path})
+	if err != nil {
+		return nil, NewInvalidInputError(fmt.Sprintf("failed to marshal request body for path %s", path), err)
+	}
+
+	release, err := c.config.Limits.AcquireDownload(ctx)
+	if err != nil {
+		return nil, NewNetworkError(fmt.Sprintf("waiting for download slot for path %s", path), err)
+	}
+
+	stream := &contentStream{
+		client:  c,
+		ctx:     ctx,
+		path:    path,
+		arg:     string(arg),
+		hasher:  NewContentHasher(),
+		release: release,
+	}
+	if err := stream.open(); err != nil {
+		release()
+		return nil, err
+	}
+	return stream, nil
+}
+
+// contentStream is a download that resumes with range requests
+type contentStream struct {
+	client       *DropboxClient
+	ctx          context.Context
+	path         string
+	arg          string
+	body         io.ReadCloser
+	offset       int64
+	expectedHash string
+	hasher       hash.Hash
+	resumes      int
+	release      func()
+	closeOnce    sync.Once
+}
+
+// open requests the content from the current offset onwards
+func (s *contentStream) open() error {
+	req, err := http.NewRequestWithContext(s.ctx, "POST", downloadURL, nil)
+	if err != nil {
+		return NewInvalidInputError(fmt.Sprintf("failed to create request for path %s", s.path), err)
+	}
+	req.Header.Set("Dropbox-API-Arg", s.arg)
+	if s.offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", s.offset))
+	}
+
+	resp, err := s.client.doRequestWithRetry(req)
+	if err != nil {
+		return err
+	}
+	if s.offset == 0 {
+		s.expectedHash = resultContentHash(resp.Header.Get("Dropbox-API-Result"))
+	} else if resp.StatusCode != http.StatusPartialContent {
+		// The server ignored the range, so skip the bytes already read
+		if _, err := io.CopyN(io.Discard, resp.Body, s.offset); err != nil {
+			resp.Body.Close()
+			return NewNetworkError(fmt.Sprintf("failed to resume download of path %s", s.path), err)
+		}
+	}
+	s.body = resp.Body
+	return nil
+}
+
+// Read reads the next chunk of content, resuming the download if the
+// connection breaks
+func (s *contentStream) Read(p []byte) (int, error) {
+	if s.body == nil {
+		return 0, NewNetworkError(fmt.Sprintf("download of path %s is not open", s.path), nil)
+	}
+
+	n, err := s.body.Read(p)
+	s.offset += int64(n)
+	s.hasher.Write(p[:n])
+
+	switch {
+	case err == io.EOF:
+		if got := hex.EncodeToString(s.hasher.Sum(nil)); s.expectedHash != "" && got != s.expectedHash {
+			return n, NewNetworkError(fmt.Sprintf("download of path %s is corrupt", s.path),
+				fmt.Errorf("content hash %s does not match %s", got, s.expectedHash))
+		}
+		return n, io.EOF
+	case err != nil && s.ctx.Err() == nil && s.resumes < maxStreamResumes:
+		s.body.Close()
+		s.body = nil
+		s.resumes++
+		if err := s.open(); err != nil {
+			return n, err
+		}
+		return n, nil
+	case err != nil:
+		return n, NewNetworkError(fmt.Sprintf("failed to read content of path %s", s.path), err)
+	}
+	return n, nil
+}
+
+// Close ends the download and frees its download slot
+func (s *contentStream) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		if s.body != nil {
+			err = s.body.Close()
+			s.body = nil
+		}
+		s.release()
+	})
+	return err
+}
+
+// resultContentHash returns the content hash in the download result
+// metadata, or "" when there is none
+func resultContentHash(apiResult string) string {
+	if apiResult == "" {
+		return ""
+	}
+	var result struct {
+		ContentHash string `json:"content_hash"`
+	}
+	if err := json.Unmarshal([]byte(apiResult), &result); err != nil {
+		return ""
+	}
+	return result.ContentHash
+}
@@ -0,0 +1,112 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/limits"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStreamServer serves content, cutting the first response short after
+// cutAfter bytes and honouring Range requests when ranges is set
+func newStreamServer(t *testing.T, content []byte, cutAfter int, ranges bool, requests *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.Header.Get("Range"))
+		w.Header().Set("Dropbox-API-Result", fmt.Sprintf(`{"content_hash": %q}`, ContentHash(content)))
+
+		if len(*requests) == 1 && cutAfter > 0 {
+			// Promise the whole file but hang up part way through
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write(content[:cutAfter])
+			return
+		}
+
+		if ranges && r.Header.Get("Range") != "" {
+			offset, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(r.Header.Get("Range"), "bytes="), "-"))
+			require.NoError(t, err)
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content[offset:])
+			return
+		}
+		w.Write(content)
+	}))
+}
+
+func TestDropboxClient_GetFileContentStream(t *testing.T) {
+	content := []byte(strings.Repeat("streamed content ", 1000))
+
+	tests := []struct {
+		name         string
+		cutAfter     int
+		ranges       bool
+		wantRequests []string
+	}{
+		{"uninterrupted", 0, true, []string{""}},
+		{"resumes with a range request", 5000, true, []string{"", "bytes=5000-"}},
+		{"resumes when the range is ignored", 5000, false, []string{"", "bytes=5000-"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []string
+			server := newStreamServer(t, content, tt.cutAfter, tt.ranges, &requests)
+			defer server.Close()
+
+			origURL := downloadURL
+			downloadURL = server.URL + "/2/files/download"
+			defer func() { downloadURL = origURL }()
+
+			guard, err := limits.NewGuard(limits.Config{MaxContentBytes: 100})
+			require.NoError(t, err)
+			config := DefaultClientConfig()
+			config.Limits = guard
+			client := setupTestClient(t, server, config)
+
+			stream, err := client.GetFileContentStream(context.Background(), "/big.txt")
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), guard.Stats().ActiveDownloads)
+
+			got, err := io.ReadAll(stream)
+			require.NoError(t, err)
+			assert.Equal(t, content, got, "content larger than the buffered download limit is streamed")
+			assert.Equal(t, tt.wantRequests, requests)
+
+			require.NoError(t, stream.Close())
+			require.NoError(t, stream.Close())
+			assert.Equal(t, int64(0), guard.Stats().ActiveDownloads)
+		})
+	}
+}
+
+func TestDropboxClient_GetFileContentStreamVerifiesHash(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Dropbox-API-Result", fmt.Sprintf(`{"content_hash": %q}`, ContentHash([]byte("other"))))
+		w.Write([]byte("file content"))
+	}))
+	defer server.Close()
+
+	origURL := downloadURL
+	downloadURL = server.URL + "/2/files/download"
+	defer func() { downloadURL = origURL }()
+
+	client := setupTestClient(t, server, DefaultClientConfig())
+	stream, err := client.GetFileContentStream(context.Background(), "/test.txt")
+	require.NoError(t, err)
+	defer stream.Close()
+
+	_, err = io.ReadAll(stream)
+	var dbErr *Error
+	require.True(t, errors.As(err, &dbErr))
+	assert.Equal(t, ErrorTypeNetwork, dbErr.Type)
+
+	_, err = client.GetFileContentStream(context.Background(), "")
+	assert.Error(t, err)
+}
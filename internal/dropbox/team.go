@@ -0,0 +1,155 @@
+package dropbox
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// Team endpoints; variables so tests can point them at a local server
+var (
+	teamFolderListURL         = "https://api.dropboxapi.com/2/team/team_folder/list"
+	teamFolderListContinueURL = "https://api.dropboxapi.com/2/team/team_folder/list/continue"
+	namespacesListURL         = "https://api.dropboxapi.com/2/team/namespaces/list"
+	namespacesListContinueURL = "https://api.dropboxapi.com/2/team/namespaces/list/continue"
+)
+
+// Headers scoping requests made with a team token
+const (
+	selectUserHeader = "Dropbox-API-Select-User"
+	pathRootHeader   = "Dropbox-API-Path-Root"
+)
+
+// teamPageSize is the number of team folders or namespaces requested per page
+const teamPageSize = 1000
+
+// TeamConfig scopes requests made with a Dropbox Business team token. Team
+// endpoints, such as the team log, act on the whole team and are sent
+// without either header.
+type TeamConfig struct {
+	// MemberID is the team member whose files are read, e.g. "dbmid:..."; a
+	// team token needs one to call the file endpoints
+	MemberID string
+	// NamespaceID roots paths at a namespace, such as the team space or a
+	// team folder, instead of the member's home folder
+	NamespaceID string
+}
+
+// Scope identifies the member and namespace that paths are resolved in, such
+// as "dbmid:AAH/ns:123", or "" when neither is set. State tied to a folder,
+// such as its list_folder cursor, is only valid within one scope.
+func (t TeamConfig) Scope() string {
+	var parts []string
+	if t.MemberID != "" {
+		parts = append(parts, t.MemberID)
+	}
+	if t.NamespaceID != "" {
+		parts = append(parts, "ns:"+t.NamespaceID)
+	}
+	return strings.Join(parts, "/")
+}
+
+// apply sets the member and namespace headers on requests to user endpoints
+func (t TeamConfig) apply(req *http.Request) {
+	if strings.HasPrefix(endpointName(req.URL.Path), "team") {
+		return
+	}
+	if t.MemberID != "" {
+		req.Header.Set(selectUserHeader, t.MemberID)
+	}
+	if t.NamespaceID != "" {
+		req.Header.Set(pathRootHeader, `{".tag": "namespace_id", "namespace_id": `+strconv.Quote(t.NamespaceID)+`}`)
+	}
+}
+
+// teamFolderListResult is the response of the team folder list endpoints
+type teamFolderListResult struct {
+	TeamFolders []struct {
+		TeamFolderID string `json:"team_folder_id"`
+		Name         string `json:"name"`
+		Status       struct {
+			Tag string `json:".tag"`
+		} `json:"status"`
+		IsTeamSharedDropbox bool `json:"is_team_shared_dropbox"`
+	} `json:"team_folders"`
+	Cursor  string `json:"cursor"`
+	HasMore bool   `json:"has_more"`
+}
+
+// ListTeamFolders returns every team folder, including archived ones. This
+// requires a Dropbox Business team token.
+func (c *DropboxClient) ListTeamFolders(ctx context.Context) ([]models.TeamFolder, error) {
+	var result teamFolderListResult
+	if err := c.postJSON(ctx, teamFolderListURL, map[string]int{"limit": teamPageSize}, &result); err != nil {
+		return nil, err
+	}
+
+	folders := make([]models.TeamFolder, 0, len(result.TeamFolders))
+	for {
+		for _, folder := range result.TeamFolders {
+			folders = append(folders, models.TeamFolder{
+				ID:                folder.TeamFolderID,
+				Name:              folder.Name,
+				Status:            folder.Status.Tag,
+				TeamSharedDropbox: folder.IsTeamSharedDropbox,
+			})
+		}
+		if !result.HasMore {
+			return folders, nil
+		}
+
+		cursor := result.Cursor
+		result = teamFolderListResult{}
+		if err := c.postJSON(ctx, teamFolderListContinueURL, map[string]string{"cursor": cursor}, &result); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// namespacesListResult is the response of the namespace list endpoints
+type namespacesListResult struct {
+	Namespaces []struct {
+		Name          string `json:"name"`
+		NamespaceID   string `json:"namespace_id"`
+		NamespaceType struct {
+			Tag string `json:".tag"`
+		} `json:"namespace_type"`
+		TeamMemberID string `json:"team_member_id"`
+	} `json:"namespaces"`
+	Cursor  string `json:"cursor"`
+	HasMore bool   `json:"has_more"`
+}
+
+// ListNamespaces returns the namespaces of the team: team folders, shared
+// folders and member home folders. This requires a Dropbox Business team
+// token.
+func (c *DropboxClient) ListNamespaces(ctx context.Context) ([]models.Namespace, error) {
+	var result namespacesListResult
+	if err := c.postJSON(ctx, namespacesListURL, map[string]int{"limit": teamPageSize}, &result); err != nil {
+		return nil, err
+	}
+
+	namespaces := make([]models.Namespace, 0, len(result.Namespaces))
+	for {
+		for _, namespace := range result.Namespaces {
+			namespaces = append(namespaces, models.Namespace{
+				ID:       namespace.NamespaceID,
+				Name:     namespace.Name,
+				Type:     namespace.NamespaceType.Tag,
+				MemberID: namespace.TeamMemberID,
+			})
+		}
+		if !result.HasMore {
+			return namespaces, nil
+		}
+
+		cursor := result.Cursor
+		result = namespacesListResult{}
+		if err := c.postJSON(ctx, namespacesListContinueURL, map[string]string{"cursor": cursor}, &result); err != nil {
+			return nil, err
+		}
+	}
+}
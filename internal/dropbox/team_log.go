@@ -0,0 +1,138 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// Team log endpoints; variables so tests can point them at a local server
+var (
+	teamEventsURL         = "https://api.dropboxapi.com/2/team_log/get_events"
+	teamEventsContinueURL = "https://api.dropboxapi.com/2/team_log/get_events/continue"
+)
+
+// teamEventsPageSize is the number of events requested per page
+const teamEventsPageSize = 1000
+
+// teamEventsResult is the response of the team log endpoints
+type teamEventsResult struct {
+	Events  []teamEvent `json:"events"`
+	Cursor  string      `json:"cursor"`
+	HasMore bool        `json:"has_more"`
+}
+
+// teamEvent is a raw team log event
+type teamEvent struct {
+	Timestamp     string `json:"timestamp"`
+	EventCategory struct {
+		Tag string `json:".tag"`
+	} `json:"event_category"`
+	EventType struct {
+		Tag         string `json:".tag"`
+		Description string `json:"description"`
+	} `json:"event_type"`
+	Actor  json.RawMessage `json:"actor"`
+	Origin struct {
+		GeoLocation struct {
+			IPAddress string `json:"ip_address"`
+		} `json:"geo_location"`
+	} `json:"origin"`
+	Details json.RawMessage `json:"details"`
+}
+
+// teamActor is the subset of an actor used to name it
+type teamActor struct {
+	Tag  string `json:".tag"`
+	User *struct {
+		DisplayName string `json:"display_name"`
+		Email       string `json:"email"`
+	} `json:"user"`
+	Admin *struct {
+		DisplayName string `json:"display_name"`
+		Email       string `json:"email"`
+	} `json:"admin"`
+}
+
+// GetTeamEvents returns team log events recorded since the cursor was issued,
+// along with the cursor for the next call. With an empty cursor, events since
+// the given time are returned. This requires a Dropbox Business team token.
+func (c *DropboxClient) GetTeamEvents(ctx context.Context, cursor string, since time.Time) ([]models.TeamEvent, string, error) {
+	var result teamEventsResult
+	if cursor == "" {
+		body := map[string]interface{}{
+			"limit": teamEventsPageSize,
+			"time": map[string]string{
+				"start_time": since.UTC().Format(time.RFC3339),
+			},
+		}
+		if err := c.postJSON(ctx, teamEventsURL, body, &result); err != nil {
+			return nil, "", err
+		}
+	} else {
+		if err := c.postJSON(ctx, teamEventsContinueURL, map[string]string{"cursor": cursor}, &result); err != nil {
+			return nil, "", err
+		}
+	}
+
+	events := make([]models.TeamEvent, 0, len(result.Events))
+	for {
+		for i := range result.Events {
+			event, err := toTeamEvent(&result.Events[i])
+			if err != nil {
+				return nil, "", err
+			}
+			events = append(events, event)
+		}
+
+		cursor = result.Cursor
+		if !result.HasMore {
+			return events, cursor, nil
+		}
+
+		result = teamEventsResult{}
+		if err := c.postJSON(ctx, teamEventsContinueURL, map[string]string{"cursor": cursor}, &result); err != nil {
+			return nil, "", err
+		}
+	}
+}
+
+// toTeamEvent converts a raw team log event
+func toTeamEvent(raw *teamEvent) (models.TeamEvent, error) {
+	timestamp, err := time.Parse(time.RFC3339, raw.Timestamp)
+	if err != nil {
+		return models.TeamEvent{}, NewServerError(fmt.Sprintf("invalid team event timestamp %q", raw.Timestamp), err)
+	}
+
+	event := models.TeamEvent{
+		Timestamp:   timestamp,
+		Category:    raw.EventCategory.Tag,
+		Type:        raw.EventType.Tag,
+		Description: raw.EventType.Description,
+		IPAddress:   raw.Origin.GeoLocation.IPAddress,
+	}
+	if len(raw.Details) > 0 {
+		event.Details = string(raw.Details)
+	}
+
+	var actor teamActor
+	if len(raw.Actor) > 0 && json.Unmarshal(raw.Actor, &actor) == nil {
+		switch {
+		case actor.User != nil && actor.User.DisplayName != "":
+			event.Actor = actor.User.DisplayName
+		case actor.User != nil:
+			event.Actor = actor.User.Email
+		case actor.Admin != nil && actor.Admin.DisplayName != "":
+			event.Actor = actor.Admin.DisplayName
+		case actor.Admin != nil:
+			event.Actor = actor.Admin.Email
+		default:
+			event.Actor = actor.Tag
+		}
+	}
+
+	return event, nil
+}
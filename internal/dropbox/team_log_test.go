@@ -0,0 +1,74 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDropboxClient_GetTeamEvents(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		switch r.URL.Path {
+		case "/2/team_log/get_events":
+			timeRange := body["time"].(map[string]interface{})
+			assert.Equal(t, "2024-01-01T00:00:00Z", timeRange["start_time"])
+			fmt.Fprint(w, `{"events": [{
+				"timestamp": "2024-01-02T10:00:00Z",
+				"event_category": {".tag": "logins"},
+				"event_type": {".tag": "login_success", "description": "Signed in"},
+				"actor": {".tag": "user", "user": {"display_name": "Alice", "email": "alice@example.com"}},
+				"origin": {"geo_location": {"ip_address": "10.0.0.1"}},
+				"details": {".tag": "login_success_details"}
+			}], "cursor": "c1", "has_more": true}`)
+		case "/2/team_log/get_events/continue":
+			assert.Contains(t, []interface{}{"c1", "c2"}, body["cursor"])
+			fmt.Fprint(w, `{"events": [{
+				"timestamp": "2024-01-02T11:00:00Z",
+				"event_category": {".tag": "sharing"},
+				"event_type": {".tag": "shared_link_create", "description": "Created shared link"},
+				"actor": {".tag": "admin", "admin": {"email": "admin@example.com"}}
+			}], "cursor": "c2", "has_more": false}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := setupTestClient(t, server, DefaultClientConfig())
+
+	origEvents, origContinue := teamEventsURL, teamEventsContinueURL
+	teamEventsURL = server.URL + "/2/team_log/get_events"
+	teamEventsContinueURL = server.URL + "/2/team_log/get_events/continue"
+	defer func() { teamEventsURL, teamEventsContinueURL = origEvents, origContinue }()
+
+	events, cursor, err := client.GetTeamEvents(context.Background(), "", since)
+	require.NoError(t, err)
+	assert.Equal(t, "c2", cursor)
+	require.Len(t, events, 2)
+
+	assert.Equal(t, "logins", events[0].Category)
+	assert.Equal(t, "login_success", events[0].Type)
+	assert.Equal(t, "Signed in", events[0].Description)
+	assert.Equal(t, "Alice", events[0].Actor)
+	assert.Equal(t, "10.0.0.1", events[0].IPAddress)
+	assert.JSONEq(t, `{".tag": "login_success_details"}`, events[0].Details)
+	assert.Equal(t, "admin@example.com", events[1].Actor)
+
+	// Resuming from a cursor uses the continue endpoint
+	events, cursor, err = client.GetTeamEvents(context.Background(), "c2", since)
+	require.NoError(t, err)
+	assert.Equal(t, "c2", cursor)
+	assert.Len(t, events, 1)
+}
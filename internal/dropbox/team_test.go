@@ -0,0 +1,119 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDropboxClient_TeamScope(t *testing.T) {
+	headers := make(map[string]http.Header)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers[r.URL.Path] = r.Header.Clone()
+		switch r.URL.Path {
+		case "/2/files/list_folder/get_latest_cursor":
+			fmt.Fprint(w, `{"cursor": "c1"}`)
+		case "/2/team/team_folder/list":
+			fmt.Fprint(w, `{"team_folders": [], "has_more": false}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := DefaultClientConfig()
+	config.Team = TeamConfig{MemberID: "dbmid:abc", NamespaceID: "12345"}
+	client := setupTestClient(t, server, config)
+
+	origCursor, origFolders := getLatestCursorURL, teamFolderListURL
+	getLatestCursorURL = server.URL + "/2/files/list_folder/get_latest_cursor"
+	teamFolderListURL = server.URL + "/2/team/team_folder/list"
+	defer func() { getLatestCursorURL, teamFolderListURL = origCursor, origFolders }()
+
+	_, err := client.GetLatestCursor(context.Background(), "/Projects")
+	require.NoError(t, err)
+	files := headers["/2/files/list_folder/get_latest_cursor"]
+	assert.Equal(t, "dbmid:abc", files.Get("Dropbox-API-Select-User"))
+	var root map[string]string
+	require.NoError(t, json.Unmarshal([]byte(files.Get("Dropbox-API-Path-Root")), &root))
+	assert.Equal(t, map[string]string{".tag": "namespace_id", "namespace_id": "12345"}, root)
+
+	// Team endpoints act on the whole team
+	_, err = client.ListTeamFolders(context.Background())
+	require.NoError(t, err)
+	team := headers["/2/team/team_folder/list"]
+	assert.Empty(t, team.Get("Dropbox-API-Select-User"))
+	assert.Empty(t, team.Get("Dropbox-API-Path-Root"))
+}
+
+func TestTeamConfig_Scope(t *testing.T) {
+	assert.Empty(t, TeamConfig{}.Scope())
+	assert.Equal(t, "dbmid:abc", TeamConfig{MemberID: "dbmid:abc"}.Scope())
+	assert.Equal(t, "dbmid:abc/ns:12345", TeamConfig{MemberID: "dbmid:abc", NamespaceID: "12345"}.Scope())
+}
+
+func TestDropboxClient_ListTeamFolders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2/team/team_folder/list":
+			fmt.Fprint(w, `{"team_folders": [{"team_folder_id": "1", "name": "Finance",
+				"status": {".tag": "active"}, "is_team_shared_dropbox": true}], "cursor": "c1", "has_more": true}`)
+		case "/2/team/team_folder/list/continue":
+			fmt.Fprint(w, `{"team_folders": [{"team_folder_id": "2", "name": "Old",
+				"status": {".tag": "archived"}}], "cursor": "c2", "has_more": false}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := setupTestClient(t, server, DefaultClientConfig())
+	origList, origContinue := teamFolderListURL, teamFolderListContinueURL
+	teamFolderListURL = server.URL + "/2/team/team_folder/list"
+	teamFolderListContinueURL = server.URL + "/2/team/team_folder/list/continue"
+	defer func() { teamFolderListURL, teamFolderListContinueURL = origList, origContinue }()
+
+	folders, err := client.ListTeamFolders(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []models.TeamFolder{
+		{ID: "1", Name: "Finance", Status: "active", TeamSharedDropbox: true},
+		{ID: "2", Name: "Old", Status: "archived"},
+	}, folders)
+}
+
+func TestDropboxClient_ListNamespaces(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2/team/namespaces/list":
+			fmt.Fprint(w, `{"namespaces": [{"name": "Finance", "namespace_id": "10",
+				"namespace_type": {".tag": "team_folder"}}], "cursor": "c1", "has_more": true}`)
+		case "/2/team/namespaces/list/continue":
+			fmt.Fprint(w, `{"namespaces": [{"name": "Jane Doe", "namespace_id": "20",
+				"namespace_type": {".tag": "team_member_folder"}, "team_member_id": "dbmid:jane"}],
+				"cursor": "c2", "has_more": false}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := setupTestClient(t, server, DefaultClientConfig())
+	origList, origContinue := namespacesListURL, namespacesListContinueURL
+	namespacesListURL = server.URL + "/2/team/namespaces/list"
+	namespacesListContinueURL = server.URL + "/2/team/namespaces/list/continue"
+	defer func() { namespacesListURL, namespacesListContinueURL = origList, origContinue }()
+
+	namespaces, err := client.ListNamespaces(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []models.Namespace{
+		{ID: "10", Name: "Finance", Type: "team_folder"},
+		{ID: "20", Name: "Jane Doe", Type: "team_member_folder", MemberID: "dbmid:jane"},
+	}, namespaces)
+}
@@ -0,0 +1,121 @@
+package dropbox
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// DefaultTraversalWorkers is how many folders Walk lists at a time when no
+// number is given
+const DefaultTraversalWorkers = 4
+
+// Walk lists every file under path, "" or "/" being the Dropbox root, by
+// listing each folder on its own and up to workers folders at a time, so
+// accounts with thousands of folders are listed in parallel. Every request
+// still passes the client's shared rate limiter. The first error stops the
+// walk. Files are returned sorted by path.
+func (c *DropboxClient) Walk(ctx context.Context, path string, workers int) ([]*models.FileMetadata, error) {
+	if path == "/" {
+		path = ""
+	}
+	if workers <= 0 {
+		workers = DefaultTraversalWorkers
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		files    []*models.FileMetadata
+		firstErr error
+	)
+	slots := make(chan struct{}, workers)
+
+	var visit func(folder string)
+	visit = func(folder string) {
+		defer wg.Done()
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		entries, err := c.listFolderEntries(ctx, folder)
+		<-slots
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+				cancel()
+			}
+			return
+		}
+		for i := range entries {
+			entry := &entries[i]
+			if entry.Tag == "folder" {
+				wg.Add(1)
+				go visit(entry.folderPath())
+				continue
+			}
+			file, err := c.toChangedFileMetadata(entry)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				return
+			}
+			if file != nil && !file.IsDeleted {
+				files = append(files, file)
+			}
+		}
+	}
+
+	wg.Add(1)
+	go visit(path)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// listFolderEntries returns the entries directly inside folder, following
+// every page of the listing
+func (c *DropboxClient) listFolderEntries(ctx context.Context, folder string) ([]dropboxFileMetadata, error) {
+	var result listFolderResult
+	if err := c.postJSON(ctx, listFolderURL, map[string]interface{}{"path": folder}, &result); err != nil {
+		return nil, err
+	}
+
+	entries := result.Entries
+	for result.HasMore {
+		cursor := result.Cursor
+		result = listFolderResult{}
+		if err := c.postJSON(ctx, listFolderContinueURL, map[string]string{"cursor": cursor}, &result); err != nil {
+			return nil, err
+		}
+		entries = append(entries, result.Entries...)
+	}
+	return entries, nil
+}
+
+// folderPath returns the path to list a folder entry by, preferring the
+// lowercased path Dropbox reports
+func (e *dropboxFileMetadata) folderPath() string {
+	if e.PathLower != "" {
+		return e.PathLower
+	}
+	return e.PathDisplay
+}
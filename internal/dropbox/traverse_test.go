@@ -0,0 +1,129 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// treeServer serves a small folder tree one folder at a time, counting how
+// many listings are in flight at once
+func treeServer(t *testing.T, inFlight, maxInFlight *int32) *httptest.Server {
+	tree := map[string]string{
+		"": `{"entries": [
+			{".tag": "file", "path_display": "/a.txt", "server_modified": "2021-01-01T00:00:00Z", "size": 10},
+			{".tag": "folder", "path_display": "/Docs", "path_lower": "/docs"},
+			{".tag": "folder", "path_display": "/Photos", "path_lower": "/photos"}
+		], "cursor": "root1", "has_more": true}`,
+		"/docs": `{"entries": [
+			{".tag": "file", "path_display": "/Docs/b.txt", "server_modified": "2021-01-02T00:00:00Z", "size": 20},
+			{".tag": "folder", "path_display": "/Docs/2021", "path_lower": "/docs/2021"}
+		], "has_more": false}`,
+		"/docs/2021": `{"entries": [
+			{".tag": "file", "path_display": "/Docs/2021/c.txt", "server_modified": "2021-01-03T00:00:00Z", "size": 30}
+		], "has_more": false}`,
+		"/photos": `{"entries": [], "has_more": false}`,
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(inFlight, 1)
+		defer atomic.AddInt32(inFlight, -1)
+		for {
+			max := atomic.LoadInt32(maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		switch r.URL.Path {
+		case "/2/files/list_folder":
+			assert.Nil(t, body["recursive"])
+			listing, ok := tree[body["path"].(string)]
+			if !ok {
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprint(w, `{"error_summary": "path/not_found/"}`)
+				return
+			}
+			fmt.Fprint(w, listing)
+		case "/2/files/list_folder/continue":
+			assert.Equal(t, "root1", body["cursor"])
+			fmt.Fprint(w, `{"entries": [
+				{".tag": "file", "path_display": "/z.txt", "server_modified": "2021-01-04T00:00:00Z", "size": 40}
+			], "has_more": false}`)
+		case "/2/files/list_folder/get_latest_cursor":
+			assert.Equal(t, true, body["recursive"])
+			fmt.Fprint(w, `{"cursor": "latest"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+// useServer points the listing endpoints at server for the rest of the test
+func useServer(t *testing.T, server *httptest.Server) {
+	origList, origContinue, origLatest := listFolderURL, listFolderContinueURL, getLatestCursorURL
+	listFolderURL = server.URL + "/2/files/list_folder"
+	listFolderContinueURL = server.URL + "/2/files/list_folder/continue"
+	getLatestCursorURL = server.URL + "/2/files/list_folder/get_latest_cursor"
+	t.Cleanup(func() { listFolderURL, listFolderContinueURL, getLatestCursorURL = origList, origContinue, origLatest })
+}
+
+func TestDropboxClient_Walk(t *testing.T) {
+	for _, workers := range []int{1, 2, 8} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			var inFlight, maxInFlight int32
+			server := treeServer(t, &inFlight, &maxInFlight)
+			defer server.Close()
+			useServer(t, server)
+
+			client := setupTestClient(t, server, DefaultClientConfig())
+			files, err := client.Walk(context.Background(), "/", workers)
+			require.NoError(t, err)
+
+			var paths []string
+			for _, file := range files {
+				paths = append(paths, file.Path)
+			}
+			assert.Equal(t, []string{"/Docs/2021/c.txt", "/Docs/b.txt", "/a.txt", "/z.txt"}, paths)
+			assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(workers))
+		})
+	}
+}
+
+func TestDropboxClient_WalkStopsOnError(t *testing.T) {
+	var inFlight, maxInFlight int32
+	server := treeServer(t, &inFlight, &maxInFlight)
+	defer server.Close()
+	useServer(t, server)
+
+	client := setupTestClient(t, server, DefaultClientConfig())
+	_, err := client.Walk(context.Background(), "/missing", 4)
+	assert.Error(t, err)
+}
+
+func TestDropboxClient_ListFolderRecursiveParallel(t *testing.T) {
+	var inFlight, maxInFlight int32
+	server := treeServer(t, &inFlight, &maxInFlight)
+	defer server.Close()
+	useServer(t, server)
+
+	config := DefaultClientConfig()
+	config.TraversalWorkers = 3
+	client := setupTestClient(t, server, config)
+
+	files, cursor, err := client.ListFolderRecursive(context.Background(), "")
+	require.NoError(t, err)
+	assert.Equal(t, "latest", cursor)
+	assert.Len(t, files, 4)
+}
@@ -0,0 +1,218 @@
+// Package embeddings turns the extracted text of changed documents into
+// vectors and searches them by meaning. Vectors come from an Embedder: a
+// hosted model (OpenAI, Google), a local model served by Ollama, or the
+// built-in hashing embedder that needs neither.
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/extract"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// Embedding providers
+const (
+	ProviderHashing = "hashing"
+	ProviderOpenAI  = "openai"
+	ProviderGoogle  = "google"
+	ProviderOllama  = "ollama"
+)
+
+// Default limits for indexing
+const (
+	DefaultMaxFileBytes = 10 * 1024 * 1024
+	DefaultMaxFiles     = 50
+	DefaultMaxChars     = 8000
+	DefaultResults      = 10
+	MaxResults          = 100
+)
+
+// ErrEmptyQuery is returned when searching for blank text
+var ErrEmptyQuery = errors.New("search query is empty")
+
+// Embedder turns text into a vector
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+	// Model names the model, so embeddings of different models are not mixed
+	Model() string
+}
+
+// Config selects the embedding provider and limits what is indexed
+type Config struct {
+	// Provider is hashing (the default), openai, google or ollama
+	Provider string
+	// Model overrides the provider's default model
+	Model string
+	// URL overrides the provider's API address
+	URL string
+	// APIKey authenticates with hosted providers
+	APIKey string
+	// Dimensions sizes the vectors of the hashing embedder
+	Dimensions int
+	// MaxFileBytes skips files larger than this
+	MaxFileBytes int64
+	// MaxFiles caps how many documents are embedded per set of changes
+	MaxFiles int
+	// MaxChars truncates the text embedded for each document
+	MaxChars int
+}
+
+// New creates the embedder for the configured provider
+func New(cfg Config) (Embedder, error) {
+	switch cfg.Provider {
+	case "", ProviderHashing:
+		return NewHashing(cfg.Dimensions), nil
+	case ProviderOpenAI:
+		return NewOpenAI(cfg)
+	case ProviderGoogle:
+		return NewGoogle(cfg)
+	case ProviderOllama:
+		return NewOllama(cfg), nil
+	}
+	return nil, fmt.Errorf("unknown embedding provider %q", cfg.Provider)
+}
+
+// ContentFetcher downloads file contents
+type ContentFetcher interface {
+	GetFileContent(ctx context.Context, path string) ([]byte, error)
+}
+
+// Store keeps one embedding per document
+type Store interface {
+	SaveEmbedding(ctx context.Context, path, contentHash, model string, embedding db.Vector) error
+	GetEmbeddingHash(ctx context.Context, path string) (string, string, bool, error)
+	DeleteEmbedding(ctx context.Context, path string) error
+	SearchSimilar(ctx context.Context, query db.Vector, k int) ([]db.SimilarDocument, error)
+}
+
+// Index embeds changed documents and searches their embeddings
+type Index struct {
+	embedder Embedder
+	fetcher  ContentFetcher
+	store    Store
+	config   Config
+	logger   *slog.Logger
+}
+
+// NewIndex creates an index; zero limits fall back to the defaults
+func NewIndex(embedder Embedder, fetcher ContentFetcher, store Store, cfg Config) *Index {
+	if cfg.MaxFileBytes <= 0 {
+		cfg.MaxFileBytes = DefaultMaxFileBytes
+	}
+	if cfg.MaxFiles <= 0 {
+		cfg.MaxFiles = DefaultMaxFiles
+	}
+	if cfg.MaxChars <= 0 {
+		cfg.MaxChars = DefaultMaxChars
+	}
+	return &Index{embedder: embedder, fetcher: fetcher, store: store, config: cfg, logger: logging.Component(nil, "embeddings")}
+}
+
+// Update embeds the documents among changes whose text can be extracted and
+// drops the embeddings of deleted ones. Documents whose content hash and
+// model match their stored embedding are skipped. Failures are logged and
+// skipped so that one unreadable document does not hold back the rest.
+func (x *Index) Update(ctx context.Context, changes []models.FileChange) error {
+	seen := make(map[string]bool)
+	embedded := 0
+
+	// The newest change of each path is last
+	for i := len(changes) - 1; i >= 0 && embedded < x.config.MaxFiles; i-- {
+		change := changes[i]
+		if seen[change.Path] {
+			continue
+		}
+		seen[change.Path] = true
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if change.IsDeleted {
+			if err := x.store.DeleteEmbedding(ctx, change.Path); err != nil {
+				x.logger.Error("Failed to delete embedding", "path", change.Path, "error", err)
+			}
+			continue
+		}
+		if change.Size > x.config.MaxFileBytes || !extract.Default.Supports(change.Path) || x.unchanged(ctx, change) {
+			continue
+		}
+
+		embedded++
+		if err := x.embed(ctx, change); err != nil {
+			x.logger.Warn("Skipping embedding", "path", change.Path, "error", err)
+		}
+	}
+	return nil
+}
+
+// unchanged reports whether the stored embedding of a change's document was
+// made by the current model from the same content
+func (x *Index) unchanged(ctx context.Context, change models.FileChange) bool {
+	if change.ContentHash == "" {
+		return false
+	}
+	hash, model, found, err := x.store.GetEmbeddingHash(ctx, change.Path)
+	if err != nil {
+		x.logger.Error("Failed to load embedding hash", "path", change.Path, "error", err)
+		return false
+	}
+	return found && hash == change.ContentHash && model == x.embedder.Model()
+}
+
+// embed downloads, extracts and embeds one document
+func (x *Index) embed(ctx context.Context, change models.FileChange) error {
+	content, err := x.fetcher.GetFileContent(ctx, change.Path)
+	if err != nil {
+		return err
+	}
+	if int64(len(content)) > x.config.MaxFileBytes {
+		return nil
+	}
+
+	text, err := extract.Default.Extract(ctx, change.Path, content)
+	if err != nil {
+		return err
+	}
+	if text = truncate(text, x.config.MaxChars); text == "" {
+		return x.store.DeleteEmbedding(ctx, change.Path)
+	}
+
+	vector, err := x.embedder.Embed(ctx, text)
+	if err != nil {
+		return err
+	}
+	return x.store.SaveEmbedding(ctx, change.Path, change.ContentHash, x.embedder.Model(), vector)
+}
+
+// Search returns the k documents most similar in meaning to query, best first
+func (x *Index) Search(ctx context.Context, query string, k int) ([]db.SimilarDocument, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, ErrEmptyQuery
+	}
+	if k <= 0 {
+		k = DefaultResults
+	}
+
+	vector, err := x.embedder.Embed(ctx, truncate(query, x.config.MaxChars))
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	return x.store.SearchSimilar(ctx, vector, min(k, MaxResults))
+}
+
+// truncate cuts text to at most max characters without splitting a rune
+func truncate(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	return string([]rune(text)[:max])
+}
@@ -0,0 +1,181 @@
+package embeddings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeFetcher struct {
+	files   map[string]string
+	fetched []string
+}
+
+func (f *fakeFetcher) GetFileContent(ctx context.Context, path string) ([]byte, error) {
+	f.fetched = append(f.fetched, path)
+	content, ok := f.files[path]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return []byte(content), nil
+}
+
+func newTestStore(t *testing.T) *db.DB {
+	store, err := db.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestHashing(t *testing.T) {
+	embedder := NewHashing(0)
+	assert.Equal(t, "hashing-512", embedder.Model())
+
+	budget, err := embedder.Embed(context.Background(), "Travel budget for the field trip")
+	require.NoError(t, err)
+	assert.Len(t, budget, DefaultHashDimensions)
+
+	query, _ := embedder.Embed(context.Background(), "TRAVEL BUDGET")
+	other, _ := embedder.Embed(context.Background(), "Minutes of the board meeting")
+	assert.Greater(t, dot(query, budget), dot(query, other))
+	assert.InDelta(t, 1, dot(budget, budget), 1e-5, "embeddings are normalized")
+
+	empty, err := embedder.Embed(context.Background(), "  ")
+	require.NoError(t, err)
+	assert.Zero(t, dot(empty, empty))
+}
+
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func TestNew(t *testing.T) {
+	embedder, err := New(Config{})
+	require.NoError(t, err)
+	assert.IsType(t, &Hashing{}, embedder)
+
+	_, err = New(Config{Provider: ProviderOpenAI})
+	assert.Error(t, err, "openai requires an API key")
+
+	embedder, err = New(Config{Provider: ProviderOllama, Model: "all-minilm"})
+	require.NoError(t, err)
+	assert.Equal(t, "all-minilm", embedder.Model())
+
+	_, err = New(Config{Provider: "telepathy"})
+	assert.Error(t, err)
+}
+
+func TestHTTPProviders(t *testing.T) {
+	var request map[string]interface{}
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		switch r.URL.Path {
+		case "/v1/embeddings":
+			w.Write([]byte(`{"data": [{"embedding": [0.1, 0.2]}]}`))
+		case "/models/text-embedding-004:embedContent":
+			w.Write([]byte(`{"embedding": {"values": [0.3, 0.4]}}`))
+		case "/api/embed":
+			w.Write([]byte(`{"embeddings": [[0.5, 0.6]]}`))
+		default:
+			http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	openai, err := NewOpenAI(Config{URL: server.URL + "/v1/embeddings", APIKey: "sk-test"})
+	require.NoError(t, err)
+	vector, err := openai.Embed(ctx, "hello")
+	require.NoError(t, err)
+	assert.Equal(t, []float32{0.1, 0.2}, vector)
+	assert.Equal(t, "Bearer sk-test", header.Get("Authorization"))
+	assert.Equal(t, DefaultOpenAIModel, request["model"])
+
+	google, err := NewGoogle(Config{URL: server.URL, APIKey: "g-test"})
+	require.NoError(t, err)
+	vector, err = google.Embed(ctx, "hello")
+	require.NoError(t, err)
+	assert.Equal(t, []float32{0.3, 0.4}, vector)
+	assert.Equal(t, "g-test", header.Get("X-Goog-Api-Key"))
+
+	vector, err = NewOllama(Config{URL: server.URL + "/"}).Embed(ctx, "hello")
+	require.NoError(t, err)
+	assert.Equal(t, []float32{0.5, 0.6}, vector)
+	assert.Equal(t, "hello", request["input"])
+
+	_, err = NewOllama(Config{URL: server.URL + "/missing"}).Embed(ctx, "hello")
+	assert.ErrorContains(t, err, "status 429: quota exceeded")
+}
+
+func TestIndex_UpdateAndSearch(t *testing.T) {
+	store := newTestStore(t)
+	fetcher := &fakeFetcher{files: map[string]string{
+		"/docs/budget.md":  "# Budget\nTravel costs for the field trip",
+		"/docs/minutes.md": "Minutes of the board meeting",
+		"/docs/photo.jpg":  "binary",
+		"/docs/broken.txt": "\xff\x00",
+	}}
+	index := NewIndex(NewHashing(64), fetcher, store, Config{MaxFileBytes: 1024})
+	ctx := context.Background()
+
+	changes := []models.FileChange{
+		{Path: "/docs/budget.md", Size: 40, ContentHash: "b1"},
+		{Path: "/docs/minutes.md", Size: 28, ContentHash: "m1"},
+		{Path: "/docs/photo.jpg", Size: 6},
+		{Path: "/docs/broken.txt", Size: 2},
+		{Path: "/docs/huge.txt", Size: 4096},
+	}
+	require.NoError(t, index.Update(ctx, changes))
+	assert.ElementsMatch(t, []string{"/docs/budget.md", "/docs/minutes.md", "/docs/broken.txt"}, fetcher.fetched)
+
+	results, err := index.Search(ctx, "field trip travel", 5)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "/docs/budget.md", results[0].Path)
+	assert.Equal(t, "hashing-64", results[0].Model)
+
+	_, err = index.Search(ctx, " ", 5)
+	assert.ErrorIs(t, err, ErrEmptyQuery)
+
+	// Unchanged content is not downloaded again, deleted documents drop out
+	fetcher.fetched = nil
+	require.NoError(t, index.Update(ctx, []models.FileChange{
+		{Path: "/docs/budget.md", Size: 40, ContentHash: "b1"},
+		{Path: "/docs/minutes.md", IsDeleted: true},
+	}))
+	assert.Empty(t, fetcher.fetched)
+	results, err = index.Search(ctx, "board meeting", 5)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "/docs/budget.md", results[0].Path)
+
+	// A different model embeds the same content again
+	require.NoError(t, NewIndex(NewHashing(32), fetcher, store, Config{}).Update(ctx, changes[:1]))
+	assert.Equal(t, []string{"/docs/budget.md"}, fetcher.fetched)
+}
+
+func TestIndex_MaxFiles(t *testing.T) {
+	fetcher := &fakeFetcher{files: map[string]string{}}
+	index := NewIndex(NewHashing(8), fetcher, newTestStore(t), Config{MaxFiles: 2})
+
+	changes := []models.FileChange{
+		{Path: "/a.txt"}, {Path: "/b.txt"}, {Path: "/c.txt"}, {Path: "/c.txt"},
+	}
+	require.NoError(t, index.Update(context.Background(), changes))
+	assert.Equal(t, []string{"/c.txt", "/b.txt"}, fetcher.fetched)
+}
@@ -0,0 +1,215 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/jsonhttp"
+)
+
+// Default provider settings
+const (
+	DefaultOpenAIModel    = "text-embedding-3-small"
+	DefaultOpenAIURL      = "https://api.openai.com/v1/embeddings"
+	DefaultGoogleModel    = "text-embedding-004"
+	DefaultGoogleURL      = "https://generativelanguage.googleapis.com/v1beta"
+	DefaultOllamaModel    = "nomic-embed-text"
+	DefaultOllamaURL      = "http://localhost:11434"
+	DefaultHashDimensions = 512
+	defaultRequestTimeout = 30 * time.Second
+)
+
+// httpEmbedder holds what the HTTP providers share
+type httpEmbedder struct {
+	client *http.Client
+	url    string
+	apiKey string
+	model  string
+}
+
+// newHTTPEmbedder applies the defaults for a provider to cfg
+func newHTTPEmbedder(cfg Config, defaultURL, defaultModel string) httpEmbedder {
+	e := httpEmbedder{
+		client: &http.Client{Timeout: defaultRequestTimeout},
+		url:    strings.TrimRight(cfg.URL, "/"),
+		apiKey: cfg.APIKey,
+		model:  cfg.Model,
+	}
+	if e.url == "" {
+		e.url = defaultURL
+	}
+	if e.model == "" {
+		e.model = defaultModel
+	}
+	return e
+}
+
+// Model returns the model name
+func (e httpEmbedder) Model() string {
+	return e.model
+}
+
+// post sends a JSON request and decodes the JSON response into out
+func (e httpEmbedder) post(ctx context.Context, endpoint string, header http.Header, body, out interface{}) error {
+	return jsonhttp.Post(ctx, e.client, "embedding", endpoint, header, body, out)
+}
+
+// OpenAI embeds text with the OpenAI embeddings API
+type OpenAI struct {
+	httpEmbedder
+}
+
+// NewOpenAI creates an OpenAI embedder; it requires an API key
+func NewOpenAI(cfg Config) (*OpenAI, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("openai embeddings require an API key")
+	}
+	return &OpenAI{newHTTPEmbedder(cfg, DefaultOpenAIURL, DefaultOpenAIModel)}, nil
+}
+
+// Embed returns the embedding of text
+func (e *OpenAI) Embed(ctx context.Context, text string) ([]float32, error) {
+	var resp struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	header := http.Header{"Authorization": {"Bearer " + e.apiKey}}
+	if err := e.post(ctx, e.url, header, map[string]interface{}{"model": e.model, "input": text}, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("embedding response has no data")
+	}
+	return resp.Data[0].Embedding, nil
+}
+
+// Google embeds text with the Gemini API
+type Google struct {
+	httpEmbedder
+}
+
+// NewGoogle creates a Google embedder; it requires an API key
+func NewGoogle(cfg Config) (*Google, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("google embeddings require an API key")
+	}
+	return &Google{newHTTPEmbedder(cfg, DefaultGoogleURL, DefaultGoogleModel)}, nil
+}
+
+// Embed returns the embedding of text
+func (e *Google) Embed(ctx context.Context, text string) ([]float32, error) {
+	var resp struct {
+		Embedding struct {
+			Values []float32 `json:"values"`
+		} `json:"embedding"`
+	}
+	body := map[string]interface{}{
+		"model":   "models/" + e.model,
+		"content": map[string]interface{}{"parts": []map[string]string{{"text": text}}},
+	}
+	endpoint := fmt.Sprintf("%s/models/%s:embedContent", e.url, url.PathEscape(e.model))
+	header := http.Header{"X-Goog-Api-Key": {e.apiKey}}
+	if err := e.post(ctx, endpoint, header, body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Embedding.Values) == 0 {
+		return nil, fmt.Errorf("embedding response has no values")
+	}
+	return resp.Embedding.Values, nil
+}
+
+// Ollama embeds text with a model served locally by Ollama
+type Ollama struct {
+	httpEmbedder
+}
+
+// NewOllama creates an embedder for a local Ollama server
+func NewOllama(cfg Config) *Ollama {
+	return &Ollama{newHTTPEmbedder(cfg, DefaultOllamaURL, DefaultOllamaModel)}
+}
+
+// Embed returns the embedding of text
+func (e *Ollama) Embed(ctx context.Context, text string) ([]float32, error) {
+	var resp struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	body := map[string]interface{}{"model": e.model, "input": text}
+	if err := e.post(ctx, e.url+"/api/embed", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) == 0 {
+		return nil, fmt.Errorf("embedding response has no embeddings")
+	}
+	return resp.Embeddings[0], nil
+}
+
+// Hashing embeds text locally by hashing its words and word pairs into a
+// fixed number of dimensions. It needs no model or network access and finds
+// documents sharing vocabulary with the query, though not synonyms.
+type Hashing struct {
+	dimensions int
+}
+
+// NewHashing creates a hashing embedder; zero dimensions use the default
+func NewHashing(dimensions int) *Hashing {
+	if dimensions <= 0 {
+		dimensions = DefaultHashDimensions
+	}
+	return &Hashing{dimensions: dimensions}
+}
+
+// Model returns the model name, which includes the dimensions so that
+// embeddings of different sizes are told apart
+func (e *Hashing) Model() string {
+	return fmt.Sprintf("hashing-%d", e.dimensions)
+}
+
+// Embed returns the normalized embedding of text
+func (e *Hashing) Embed(ctx context.Context, text string) ([]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	vector := make([]float32, e.dimensions)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for i, word := range words {
+		e.add(vector, word, 1)
+		if i > 0 {
+			e.add(vector, words[i-1]+" "+word, 0.5)
+		}
+	}
+
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum > 0 {
+		scale := float32(1 / math.Sqrt(sum))
+		for i := range vector {
+			vector[i] *= scale
+		}
+	}
+	return vector, nil
+}
+
+// add hashes a term into the vector, with the sign taken from the hash so
+// that collisions tend to cancel out
+func (e *Hashing) add(vector []float32, term string, weight float32) {
+	h := fnv.New64a()
+	h.Write([]byte(term))
+	sum := h.Sum64()
+	if sum>>63 == 1 {
+		weight = -weight
+	}
+	vector[sum%uint64(e.dimensions)] += weight
+}
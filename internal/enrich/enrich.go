@@ -0,0 +1,169 @@
+// Package enrich attaches custom fields to changes before they are stored,
+// such as ticket IDs parsed from file names or client codes from paths.
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"regexp"
+	"sync"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// Enricher attaches custom fields to a change before it is stored, such as
+// a ticket ID parsed from the file name. The returned fields are added to
+// the change's Details; an error skips the enricher for that change only.
+type Enricher interface {
+	Name() string
+	Enrich(ctx context.Context, change models.FileChange) (map[string]string, error)
+}
+
+// Pipeline runs enrichers over changes in the order they were added. Fields
+// set by an earlier enricher are not overridden by later ones.
+type Pipeline struct {
+	mu        sync.RWMutex
+	enrichers []Enricher
+	logger    *slog.Logger
+}
+
+// NewPipeline creates a pipeline running the given enrichers
+func NewPipeline(enrichers ...Enricher) *Pipeline {
+	return &Pipeline{enrichers: enrichers, logger: logging.Component(nil, "enrich")}
+}
+
+// Add appends an enricher to the pipeline
+func (p *Pipeline) Add(enricher Enricher) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.enrichers = append(p.enrichers, enricher)
+}
+
+// Len returns the number of enrichers in the pipeline
+func (p *Pipeline) Len() int {
+	if p == nil {
+		return 0
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.enrichers)
+}
+
+// Apply enriches each change in place
+func (p *Pipeline) Apply(ctx context.Context, changes []models.FileChange) {
+	if p == nil {
+		return
+	}
+	p.mu.RLock()
+	enrichers := p.enrichers
+	p.mu.RUnlock()
+
+	for i := range changes {
+		change := &changes[i]
+		for _, enricher := range enrichers {
+			if ctx.Err() != nil {
+				return
+			}
+			fields, err := enricher.Enrich(ctx, *change)
+			if err != nil {
+				p.logger.Warn("Enricher skipped change", "enricher", enricher.Name(), "path", change.Path, "error", err)
+				continue
+			}
+			for key, value := range fields {
+				if value == "" {
+					continue
+				}
+				if change.Details == nil {
+					change.Details = make(map[string]string)
+				}
+				if _, ok := change.Details[key]; !ok {
+					change.Details[key] = value
+				}
+			}
+		}
+	}
+}
+
+// Pattern sources: the expression is matched against the full path or the
+// file name only
+const (
+	SourcePath = "path"
+	SourceName = "name"
+)
+
+// PatternRule sets Field to Value for the changes whose path or file name
+// matches the regular expression Match. Value may refer to submatches, as
+// in "$1" or "${ticket}"; an empty Value uses the whole match.
+type PatternRule struct {
+	Field  string
+	Match  string
+	Value  string
+	Source string
+}
+
+// Patterns is an enricher setting fields from regular expressions
+type Patterns struct {
+	rules []compiledPattern
+}
+
+// compiledPattern is a pattern rule with its expression compiled
+type compiledPattern struct {
+	PatternRule
+	re *regexp.Regexp
+}
+
+// NewPatterns compiles the pattern rules. Expressions match
+// case-insensitively.
+func NewPatterns(rules []PatternRule) (*Patterns, error) {
+	p := &Patterns{}
+	for _, rule := range rules {
+		if rule.Field == "" {
+			return nil, fmt.Errorf("pattern %q has no field", rule.Match)
+		}
+		switch rule.Source {
+		case "":
+			rule.Source = SourcePath
+		case SourcePath, SourceName:
+		default:
+			return nil, fmt.Errorf("pattern for %s has unknown source %q", rule.Field, rule.Source)
+		}
+		re, err := regexp.Compile("(?i)" + rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for %s: %w", rule.Field, err)
+		}
+		p.rules = append(p.rules, compiledPattern{PatternRule: rule, re: re})
+	}
+	return p, nil
+}
+
+// Name implements Enricher
+func (p *Patterns) Name() string {
+	return "patterns"
+}
+
+// Enrich implements Enricher. The first matching rule for a field wins.
+func (p *Patterns) Enrich(ctx context.Context, change models.FileChange) (map[string]string, error) {
+	fields := make(map[string]string)
+	for _, rule := range p.rules {
+		if _, ok := fields[rule.Field]; ok {
+			continue
+		}
+		subject := change.Path
+		if rule.Source == SourceName {
+			subject = path.Base(change.Path)
+		}
+		match := rule.re.FindStringSubmatchIndex(subject)
+		if match == nil {
+			continue
+		}
+		if rule.Value == "" {
+			fields[rule.Field] = subject[match[0]:match[1]]
+		} else {
+			fields[rule.Field] = string(rule.re.ExpandString(nil, rule.Value, subject, match))
+		}
+	}
+	return fields, nil
+}
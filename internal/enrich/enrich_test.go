@@ -0,0 +1,73 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatterns(t *testing.T) {
+	patterns, err := NewPatterns([]PatternRule{
+		{Field: "ticket", Match: `\b([a-z]+-\d+)\b`, Value: "$1", Source: SourceName},
+		{Field: "client", Match: `^/clients/(?P<code>[^/]+)/`, Value: "${code}"},
+		{Field: "client", Match: `^/clients/`, Value: "unknown"},
+		{Field: "year", Match: `20\d\d`},
+	})
+	require.NoError(t, err)
+
+	fields, err := patterns.Enrich(context.Background(), models.FileChange{Path: "/clients/ACME/2024/PROJ-42 budget.xlsx"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"ticket": "PROJ-42", "client": "ACME", "year": "2024"}, fields)
+
+	fields, err = patterns.Enrich(context.Background(), models.FileChange{Path: "/PROJ-7/notes.txt"})
+	require.NoError(t, err)
+	assert.Empty(t, fields, "name patterns ignore the folders")
+}
+
+func TestNewPatterns_Invalid(t *testing.T) {
+	_, err := NewPatterns([]PatternRule{{Match: "x"}})
+	assert.Error(t, err, "field is required")
+
+	_, err = NewPatterns([]PatternRule{{Field: "ticket", Match: "(["}})
+	assert.Error(t, err)
+
+	_, err = NewPatterns([]PatternRule{{Field: "ticket", Match: "x", Source: "content"}})
+	assert.Error(t, err)
+}
+
+// staticEnricher returns fixed fields, or an error for failPath
+type staticEnricher struct {
+	fields   map[string]string
+	failPath string
+}
+
+func (e staticEnricher) Name() string {
+	return "static"
+}
+
+func (e staticEnricher) Enrich(ctx context.Context, change models.FileChange) (map[string]string, error) {
+	if change.Path == e.failPath {
+		return nil, errors.New("lookup failed")
+	}
+	return e.fields, nil
+}
+
+func TestPipeline_Apply(t *testing.T) {
+	pipeline := NewPipeline(staticEnricher{fields: map[string]string{"client": "ACME", "empty": ""}, failPath: "/b.txt"})
+	pipeline.Add(staticEnricher{fields: map[string]string{"client": "Other", "ticket": "T-1"}})
+	assert.Equal(t, 2, pipeline.Len())
+
+	changes := []models.FileChange{{Path: "/a.txt"}, {Path: "/b.txt"}}
+	pipeline.Apply(context.Background(), changes)
+
+	assert.Equal(t, map[string]string{"client": "ACME", "ticket": "T-1"}, changes[0].Details, "earlier enrichers take precedence")
+	assert.Equal(t, map[string]string{"client": "Other", "ticket": "T-1"}, changes[1].Details, "a failing enricher is skipped")
+
+	var nilPipeline *Pipeline
+	nilPipeline.Apply(context.Background(), changes)
+	assert.Zero(t, nilPipeline.Len())
+}
@@ -3,6 +3,8 @@ package errors
 import (
 	"errors"
 	"fmt"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/redact"
 )
 
 // Common error types
@@ -130,11 +132,12 @@ func GetCategory(err error) Category {
 	}
 }
 
-// FormatError formats an error with its full context
+// FormatError formats an error with its full context. Registered secrets
+// and details naming a credential are masked.
 func FormatError(err error) string {
 	var e *Error
 	if !errors.As(err, &e) {
-		return err.Error()
+		return redact.String(err.Error())
 	}
 
 	var result string
@@ -149,8 +152,8 @@ func FormatError(err error) string {
 	}
 
 	if len(e.Details) > 0 {
-		result = fmt.Sprintf("%s (Details: %v)", result, e.Details)
+		result = fmt.Sprintf("%s (Details: %v)", result, redact.Map(e.Details))
 	}
 
-	return result
+	return redact.String(result)
 }
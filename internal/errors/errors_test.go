@@ -4,6 +4,7 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/redact"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -124,3 +125,16 @@ func TestFormatError(t *testing.T) {
 	standardErr := errors.New("standard error")
 	assert.Equal(t, "standard error", FormatError(standardErr))
 }
+
+func TestFormatError_Redacts(t *testing.T) {
+	redact.Register("sl.format-error-token")
+
+	err := Wrap(errors.New("auth failed for sl.format-error-token"), CategoryPermissionDenied, "").
+		WithDetails(map[string]interface{}{"access_token": "anything", "path": "/Projects"})
+
+	formatted := FormatError(err)
+	assert.NotContains(t, formatted, "sl.format-error-token")
+	assert.NotContains(t, formatted, "anything")
+	assert.Contains(t, formatted, "auth failed for [REDACTED]")
+	assert.Contains(t, formatted, "path:/Projects")
+}
@@ -0,0 +1,126 @@
+// Package events carries the changes detected by the file change agents to
+// the components that consume them, such as the database, the reports and
+// the search index, so producers and consumers do not call each other.
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// FileChanged reports changes detected in a monitored folder, or ingested
+// from outside the monitor
+type FileChanged struct {
+	// Folder is the monitored folder the changes were detected in; it is
+	// empty for ingested changes
+	Folder string
+	// Changes are the changed files
+	Changes []models.FileChange
+	// DetectedAt is when the changes were detected
+	DetectedAt time.Time
+}
+
+// Handler consumes FileChanged events
+type Handler func(ctx context.Context, event FileChanged) error
+
+// Changes adapts a handler of changes alone to a Handler
+func Changes(handler func(ctx context.Context, changes []models.FileChange) error) Handler {
+	return func(ctx context.Context, event FileChanged) error {
+		return handler(ctx, event.Changes)
+	}
+}
+
+// subscription is a named handler
+type subscription struct {
+	id      int
+	name    string
+	handler Handler
+	// optional handlers' failures are logged but not returned by Publish
+	optional bool
+}
+
+// Bus delivers FileChanged events to its subscribers. Handlers run one after
+// another, in the order they subscribed, on the publishing goroutine, so a
+// handler can annotate the changes for the handlers after it, as the
+// classification rules do before the changes are stored.
+type Bus struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   []subscription
+	logger *slog.Logger
+}
+
+// NewBus creates a bus logging failed handlers to logger
+func NewBus(logger *slog.Logger) *Bus {
+	return &Bus{logger: logging.Component(logger, "events")}
+}
+
+// Subscribe registers handler under name, used in logs, and returns a
+// function that removes it. The handler's failures are returned by Publish,
+// so the changes are published again by the next check.
+func (b *Bus) Subscribe(name string, handler Handler) func() {
+	return b.subscribe(subscription{name: name, handler: handler})
+}
+
+// SubscribeOptional registers a handler like Subscribe, for consumers that
+// can miss changes, such as alerts and live views: its failures are logged
+// but not returned by Publish
+func (b *Bus) SubscribeOptional(name string, handler Handler) func() {
+	return b.subscribe(subscription{name: name, handler: handler, optional: true})
+}
+
+// subscribe registers sub and returns a function that removes it
+func (b *Bus) subscribe(sub subscription) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	sub.id = id
+	b.subs = append(b.subs, sub)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			for i, sub := range b.subs {
+				if sub.id == id {
+					b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+					return
+				}
+			}
+		})
+	}
+}
+
+// Publish passes event to every subscriber. A failed handler is logged and
+// does not keep the event from the others; the failures of the handlers
+// that are not optional are returned together.
+func (b *Bus) Publish(ctx context.Context, event FileChanged) error {
+	if event.DetectedAt.IsZero() {
+		event.DetectedAt = time.Now()
+	}
+
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, sub := range subs {
+		if err := sub.handler(ctx, event); err != nil {
+			b.logger.Error("Change subscriber failed", "subscriber", sub.name, "folder", event.Folder, "error", err)
+			if !sub.optional {
+				errs = append(errs, fmt.Errorf("%s: %w", sub.name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
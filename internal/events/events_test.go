@@ -0,0 +1,64 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus(nil)
+	ctx := context.Background()
+
+	// Handlers run in the order they subscribed, seeing earlier annotations
+	var seen []string
+	bus.Subscribe("classify", func(ctx context.Context, event FileChanged) error {
+		for i := range event.Changes {
+			event.Changes[i].Project = "Planning"
+		}
+		seen = append(seen, "classify")
+		return nil
+	})
+	bus.Subscribe("failing", func(ctx context.Context, event FileChanged) error {
+		seen = append(seen, "failing")
+		return assert.AnError
+	})
+	unsubscribe := bus.Subscribe("store", Changes(func(ctx context.Context, changes []models.FileChange) error {
+		require.Len(t, changes, 1)
+		assert.Equal(t, "Planning", changes[0].Project)
+		seen = append(seen, "store")
+		return nil
+	}))
+
+	// A failed handler does not keep the event from the others
+	err := bus.Publish(ctx, FileChanged{Folder: "/docs", Changes: []models.FileChange{{Path: "/docs/plan.txt"}}})
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.ErrorContains(t, err, "failing")
+	assert.Equal(t, []string{"classify", "failing", "store"}, seen)
+
+	unsubscribe()
+	unsubscribe()
+	seen = nil
+	bus.Publish(ctx, FileChanged{Changes: []models.FileChange{{Path: "/docs/plan.txt"}}})
+	assert.Equal(t, []string{"classify", "failing"}, seen)
+}
+
+func TestBus_Optional(t *testing.T) {
+	bus := NewBus(nil)
+	var seen []string
+	bus.SubscribeOptional("alerts", func(ctx context.Context, event FileChanged) error {
+		seen = append(seen, "alerts")
+		return assert.AnError
+	})
+	bus.Subscribe("store", func(ctx context.Context, event FileChanged) error {
+		seen = append(seen, "store")
+		return nil
+	})
+
+	// Failed optional handlers are not returned
+	require.NoError(t, bus.Publish(context.Background(), FileChanged{Changes: []models.FileChange{{Path: "/docs/plan.txt"}}}))
+	assert.Equal(t, []string{"alerts", "store"}, seen)
+}
@@ -0,0 +1,156 @@
+// Package extract turns document content into normalized plain text for
+// analysis, diff summaries and embedding. Extractors are chosen by file
+// extension; plain text, PDF, DOCX and XLSX are supported out of the box and
+// more can be registered.
+package extract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxDecompressedBytes caps how much a compressed part of a document may
+// expand to, guarding against decompression bombs
+const maxDecompressedBytes = 64 << 20
+
+var (
+	// ErrUnsupported is returned for file types without an extractor
+	ErrUnsupported = errors.New("no text extractor for file type")
+	// ErrTooLarge is returned when a document expands beyond the decompression limit
+	ErrTooLarge = errors.New("document expands beyond the extraction limit")
+)
+
+// Extractor turns the content of one file type into plain text
+type Extractor interface {
+	Extract(ctx context.Context, content []byte) (string, error)
+}
+
+// ExtractorFunc adapts a function to the Extractor interface
+type ExtractorFunc func(ctx context.Context, content []byte) (string, error)
+
+// Extract calls f
+func (f ExtractorFunc) Extract(ctx context.Context, content []byte) (string, error) {
+	return f(ctx, content)
+}
+
+// TextExtensions are the file types read as plain text by the default registry
+var TextExtensions = []string{
+	".txt", ".md", ".markdown", ".rst", ".csv", ".tsv", ".json", ".yaml", ".yml",
+	".xml", ".html", ".htm", ".tex", ".sql", ".log", ".go", ".py", ".js", ".ts",
+}
+
+// Registry maps file extensions to extractors
+type Registry struct {
+	mu         sync.RWMutex
+	extractors map[string]Extractor
+}
+
+// Default is the registry of the built-in extractors
+var Default = NewDefaultRegistry()
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{extractors: make(map[string]Extractor)}
+}
+
+// NewDefaultRegistry creates a registry with the plain text, PDF, DOCX and
+// XLSX extractors
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry()
+	for _, ext := range TextExtensions {
+		r.Register(ext, ExtractorFunc(PlainText))
+	}
+	r.Register(".pdf", ExtractorFunc(PDF))
+	r.Register(".docx", ExtractorFunc(DOCX))
+	r.Register(".xlsx", ExtractorFunc(XLSX))
+	return r
+}
+
+// Register sets the extractor for a file extension, replacing any existing one
+func (r *Registry) Register(ext string, extractor Extractor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.extractors[normalizeExt(ext)] = extractor
+}
+
+// Supports reports whether the registry has an extractor for path
+func (r *Registry) Supports(path string) bool {
+	return r.lookup(path) != nil
+}
+
+// Extract returns the normalized text of the file at path
+func (r *Registry) Extract(ctx context.Context, path string, content []byte) (string, error) {
+	extractor := r.lookup(path)
+	if extractor == nil {
+		return "", fmt.Errorf("%w: %s", ErrUnsupported, path)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("context cancelled: %w", err)
+	}
+
+	text, err := extractor.Extract(ctx, content)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract text from %s: %w", path, err)
+	}
+	return Normalize(text), nil
+}
+
+// lookup returns the extractor for path's extension, or nil
+func (r *Registry) lookup(path string) Extractor {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.extractors[normalizeExt(filepath.Ext(path))]
+}
+
+// normalizeExt lowercases an extension and gives it a leading dot
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(ext)
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+var (
+	// horizontalSpace matches runs of spaces
+	horizontalSpace = regexp.MustCompile(`[ \f\v\x{00A0}]+`)
+	// blankLines matches three or more line breaks
+	blankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// Normalize cleans extracted text: invalid UTF-8 and control characters are
+// dropped, line endings become \n, runs of spaces collapse to one, lines are
+// trimmed and at most one blank line is kept in a row. Tabs separating table
+// cells are kept, including leading ones that stand for empty cells.
+func Normalize(text string) string {
+	text = strings.TrimPrefix(text, "\uFEFF")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case r == utf8.RuneError:
+			return -1
+		case r == '\n' || r == '\t':
+			return r
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, text)
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = strings.Trim(horizontalSpace.ReplaceAllString(line, " "), " ")
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	text = strings.Join(lines, "\n")
+	return strings.Trim(blankLines.ReplaceAllString(text, "\n\n"), "\n")
+}
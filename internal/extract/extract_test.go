@@ -0,0 +1,150 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// zipArchive builds an Office archive from part names and contents
+func zipArchive(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range parts {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+// pdfDocument builds a PDF with one Flate-compressed content stream
+func pdfDocument(t *testing.T, content string) []byte {
+	t.Helper()
+	var stream bytes.Buffer
+	w := zlib.NewWriter(&stream)
+	_, err := w.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	var doc bytes.Buffer
+	doc.WriteString("%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	fmt.Fprintf(&doc, "4 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", stream.Len())
+	doc.Write(stream.Bytes())
+	doc.WriteString("\nendstream\nendobj\n")
+	doc.WriteString("5 0 obj\n<< /Subtype /Image /Length 9 >>\nstream\n(Hidden) Tj\nendstream\nendobj\n%%EOF\n")
+	return doc.Bytes()
+}
+
+func TestNormalize(t *testing.T) {
+	text := "\uFEFF  Title  \r\n\r\n\r\n\r\nBody  text\x07\rmore\n\tcell\t\t\n\n"
+	assert.Equal(t, "Title\n\nBody text\nmore\n\tcell", Normalize(text))
+	assert.Equal(t, "", Normalize(" \n\n "))
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	assert.True(t, Default.Supports("/docs/Report.PDF"))
+	assert.True(t, Default.Supports("notes.md"))
+	assert.False(t, Default.Supports("photo.jpg"))
+
+	_, err := Default.Extract(ctx, "photo.jpg", []byte("x"))
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	r := NewRegistry()
+	r.Register("LOG", ExtractorFunc(func(ctx context.Context, content []byte) (string, error) {
+		return "  upper  " + string(bytes.ToUpper(content)), nil
+	}))
+	text, err := r.Extract(ctx, "/app.log", []byte("ok"))
+	require.NoError(t, err)
+	assert.Equal(t, "upper OK", text)
+}
+
+func TestPlainText(t *testing.T) {
+	ctx := context.Background()
+	text, err := Default.Extract(ctx, "a.txt", []byte("line one\r\nline two\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "line one\nline two", text)
+
+	// UTF-16 little endian with a byte order mark
+	text, err = PlainText(ctx, []byte{0xFF, 0xFE, 'h', 0, 'i', 0})
+	require.NoError(t, err)
+	assert.Equal(t, "hi", text)
+
+	_, err = Default.Extract(ctx, "a.txt", []byte("\xff\x00binary"))
+	assert.Error(t, err)
+}
+
+func TestDOCX(t *testing.T) {
+	document := `<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:r><w:t>Project</w:t></w:r><w:r><w:t xml:space="preserve"> plan</w:t></w:r></w:p>
+<w:p><w:r><w:t>Budget</w:t><w:tab/><w:t>2024</w:t></w:r></w:p>
+<w:tbl>
+<w:tr><w:tc><w:p><w:r><w:t>Item</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Cost</w:t></w:r></w:p></w:tc></w:tr>
+<w:tr><w:tc><w:p><w:r><w:t>Travel</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>150</w:t></w:r></w:p></w:tc></w:tr>
+</w:tbl>
+<w:p><w:r><w:t>Line</w:t><w:br/><w:t>break</w:t></w:r></w:p>
+</w:body></w:document>`
+	content := zipArchive(t, map[string]string{"word/document.xml": document})
+
+	text, err := Default.Extract(context.Background(), "/plan.docx", content)
+	require.NoError(t, err)
+	assert.Equal(t, "Project plan\nBudget\t2024\nItem\tCost\nTravel\t150\nLine\nbreak", text)
+
+	_, err = DOCX(context.Background(), []byte("not a zip"))
+	assert.Error(t, err)
+	_, err = DOCX(context.Background(), zipArchive(t, map[string]string{"other.xml": "<x/>"}))
+	assert.Error(t, err)
+}
+
+func TestXLSX(t *testing.T) {
+	content := zipArchive(t, map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+ xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Budget" sheetId="1" r:id="rId2"/><sheet name="Notes" sheetId="2" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Target="worksheets/sheet2.xml"/><Relationship Id="rId2" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst><si><t>Item</t></si><si><t>Cost</t></si><si><r><t>Tra</t></r><r><t>vel</t></r></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row>
+<row r="2"><c r="A2" t="s"><v>2</v></c><c r="C2"><v>150</v></c></row>
+</sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<worksheet><sheetData>
+<row r="1"><c r="B1" t="inlineStr"><is><t>Approved</t></is></c><c r="C1" t="b"><v>1</v></c></row>
+</sheetData></worksheet>`,
+	})
+
+	text, err := Default.Extract(context.Background(), "/budget.xlsx", content)
+	require.NoError(t, err)
+	assert.Equal(t, "Sheet: Budget\nItem\tCost\nTravel\t\t150\n\nSheet: Notes\n\tApproved\tTRUE", text)
+}
+
+func TestPDF(t *testing.T) {
+	content := pdfDocument(t, `BT /F1 12 Tf 72 720 Td (Quarterly \(draft\) report) Tj
+0 -14 Td [(Reve) 20 (nue) -300 (grew)] TJ
+T* <FEFF00E9007400E9> Tj
+(caf\351) ' ET`)
+
+	text, err := Default.Extract(context.Background(), "/report.pdf", content)
+	require.NoError(t, err)
+	assert.Equal(t, "Quarterly (draft) report\nRevenue grew\nété\ncafé", text)
+	assert.NotContains(t, text, "Hidden")
+
+	_, err = PDF(context.Background(), []byte("plain text"))
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = PDF(ctx, content)
+	assert.ErrorIs(t, err, context.Canceled)
+}
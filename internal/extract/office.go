@@ -0,0 +1,295 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DOCX extracts the paragraphs of a Word document, one per line, with each
+// table row on a line of its own and its cells separated by tabs
+func DOCX(ctx context.Context, content []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", fmt.Errorf("not a DOCX file: %w", err)
+	}
+	part, err := readPart(archive, "word/document.xml")
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	decoder := xml.NewDecoder(bytes.NewReader(part))
+	inText := false
+	cellDepth := 0
+	// cellBreak defers the space between paragraphs of a table cell until
+	// more text follows, so cells don't end in a space
+	cellBreak := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse document: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tc":
+				cellDepth++
+			case "tab":
+				b.WriteByte('\t')
+			case "br", "cr":
+				b.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				if cellDepth > 0 {
+					cellBreak = true
+				} else {
+					b.WriteByte('\n')
+				}
+			case "tc":
+				cellDepth--
+				cellBreak = false
+				b.WriteByte('\t')
+			case "tr":
+				b.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				if cellBreak {
+					b.WriteByte(' ')
+					cellBreak = false
+				}
+				b.Write(t)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// XLSX extracts the cells of each worksheet of an Excel workbook, one row
+// per line with cells separated by tabs, under a line naming the sheet
+func XLSX(ctx context.Context, content []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", fmt.Errorf("not an XLSX file: %w", err)
+	}
+
+	shared, err := sharedStrings(archive)
+	if err != nil {
+		return "", err
+	}
+	sheets, err := worksheets(archive)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, sheet := range sheets {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		part, err := readPart(archive, sheet.part)
+		if err != nil {
+			return "", err
+		}
+		var ws struct {
+			Rows []struct {
+				Cells []xlsxCell `xml:"c"`
+			} `xml:"sheetData>row"`
+		}
+		if err := xml.Unmarshal(part, &ws); err != nil {
+			return "", fmt.Errorf("failed to parse sheet %s: %w", sheet.name, err)
+		}
+
+		fmt.Fprintf(&b, "Sheet: %s\n", sheet.name)
+		for _, row := range ws.Rows {
+			prev := -1
+			for _, cell := range row.Cells {
+				column := columnIndex(cell.Ref)
+				if column <= prev {
+					column = prev + 1
+				}
+				// Pad skipped columns so values stay under their headers
+				tabs := column - prev - 1
+				if prev >= 0 {
+					tabs++
+				}
+				b.WriteString(strings.Repeat("\t", tabs))
+				b.WriteString(cell.text(shared))
+				prev = column
+			}
+			b.WriteByte('\n')
+		}
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+// xlsxCell is a worksheet cell
+type xlsxCell struct {
+	Ref    string `xml:"r,attr"`
+	Type   string `xml:"t,attr"`
+	Value  string `xml:"v"`
+	Inline struct {
+		Text []string `xml:"t"`
+		Runs []string `xml:"r>t"`
+	} `xml:"is"`
+}
+
+// text returns the displayed text of a cell
+func (c xlsxCell) text(shared []string) string {
+	switch c.Type {
+	case "s":
+		var i int
+		if _, err := fmt.Sscan(c.Value, &i); err == nil && i >= 0 && i < len(shared) {
+			return shared[i]
+		}
+		return ""
+	case "inlineStr":
+		return strings.Join(c.Inline.Text, "") + strings.Join(c.Inline.Runs, "")
+	case "b":
+		if c.Value == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	return c.Value
+}
+
+// columnIndex returns the zero-based column of a cell reference such as
+// "C7", or -1 when there is none
+func columnIndex(ref string) int {
+	column := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		column = column*26 + int(r-'A'+1)
+	}
+	return column - 1
+}
+
+// sharedStrings reads the workbook's shared string table
+func sharedStrings(archive *zip.Reader) ([]string, error) {
+	part, err := readPart(archive, "xl/sharedStrings.xml")
+	if err != nil {
+		// Workbooks without text cells have no shared strings
+		return nil, nil
+	}
+	var table struct {
+		Items []struct {
+			Text []string `xml:"t"`
+			Runs []string `xml:"r>t"`
+		} `xml:"si"`
+	}
+	if err := xml.Unmarshal(part, &table); err != nil {
+		return nil, fmt.Errorf("failed to parse shared strings: %w", err)
+	}
+
+	shared := make([]string, len(table.Items))
+	for i, item := range table.Items {
+		shared[i] = strings.Join(item.Text, "") + strings.Join(item.Runs, "")
+	}
+	return shared, nil
+}
+
+// sheet is a worksheet and the archive part holding it
+type sheet struct {
+	name string
+	part string
+}
+
+// worksheets lists the worksheets in workbook order, falling back to the
+// worksheet parts in name order when the workbook cannot be read
+func worksheets(archive *zip.Reader) ([]sheet, error) {
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+
+	workbookPart, err := readPart(archive, "xl/workbook.xml")
+	if err == nil {
+		err = xml.Unmarshal(workbookPart, &workbook)
+	}
+	if err == nil {
+		var relsPart []byte
+		if relsPart, err = readPart(archive, "xl/_rels/workbook.xml.rels"); err == nil {
+			err = xml.Unmarshal(relsPart, &rels)
+		}
+	}
+
+	var sheets []sheet
+	if err == nil {
+		targets := make(map[string]string, len(rels.Relationships))
+		for _, rel := range rels.Relationships {
+			target := strings.TrimPrefix(rel.Target, "/")
+			if !strings.HasPrefix(target, "xl/") {
+				target = path.Join("xl", target)
+			}
+			targets[rel.ID] = target
+		}
+		for _, s := range workbook.Sheets {
+			if target, ok := targets[s.ID]; ok {
+				sheets = append(sheets, sheet{name: s.Name, part: target})
+			}
+		}
+	}
+	if len(sheets) > 0 {
+		return sheets, nil
+	}
+
+	for _, file := range archive.File {
+		if strings.HasPrefix(file.Name, "xl/worksheets/") && strings.HasSuffix(file.Name, ".xml") {
+			name := strings.TrimSuffix(path.Base(file.Name), ".xml")
+			sheets = append(sheets, sheet{name: name, part: file.Name})
+		}
+	}
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("workbook has no worksheets")
+	}
+	sort.Slice(sheets, func(i, j int) bool { return sheets[i].part < sheets[j].part })
+	return sheets, nil
+}
+
+// readPart reads a file from an Office archive, refusing to expand it beyond
+// the decompression limit
+func readPart(archive *zip.Reader, name string) ([]byte, error) {
+	file, err := archive.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("missing %s: %w", name, err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxDecompressedBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if len(data) > maxDecompressedBytes {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
@@ -0,0 +1,387 @@
+package extract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// errNotPDF is returned for content without a PDF header
+var errNotPDF = errors.New("not a PDF file")
+
+var (
+	// pdfFilter matches the names of stream filters
+	pdfFilter = regexp.MustCompile(`/[A-Za-z0-9]+Decode\b`)
+	// pdfSkippedStream matches dictionaries of streams that hold no page text:
+	// images, embedded fonts, cross-reference tables and object streams
+	pdfSkippedStream = regexp.MustCompile(`/Subtype\s*/Image\b|/Length[123]\b|/Type\s*/(XRef|ObjStm|Metadata)\b`)
+)
+
+// PDF extracts the text shown by the content streams of a PDF document.
+// Uncompressed and Flate-compressed streams are read and text is decoded as
+// PDFDocEncoding or UTF-16; text in fonts with custom encodings comes out
+// garbled, and scanned pages have no text to extract.
+func PDF(ctx context.Context, content []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(content, "\x00\t\n\r "), []byte("%PDF-")) {
+		return "", errNotPDF
+	}
+
+	var b strings.Builder
+	decompressed := 0
+	rest := content
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		dict, data, next, ok := nextPDFStream(rest)
+		if !ok {
+			break
+		}
+		rest = rest[next:]
+
+		data, ok = decodePDFStream(dict, data, maxDecompressedBytes-decompressed)
+		if !ok {
+			continue
+		}
+		decompressed += len(data)
+		if decompressed >= maxDecompressedBytes {
+			return "", ErrTooLarge
+		}
+		showPDFText(data, &b)
+	}
+	return b.String(), nil
+}
+
+// nextPDFStream finds the next stream object in content and returns its
+// dictionary, its raw data and the offset just past it
+func nextPDFStream(content []byte) (dict, data []byte, next int, ok bool) {
+	offset := 0
+	for {
+		i := bytes.Index(content[offset:], []byte("stream"))
+		if i < 0 {
+			return nil, nil, 0, false
+		}
+		start := offset + i
+		offset = start + len("stream")
+		if bytes.HasSuffix(content[:start], []byte("end")) {
+			continue
+		}
+
+		// The dictionary runs from the object header to the stream keyword
+		objStart := bytes.LastIndex(content[:start], []byte("obj"))
+		if objStart < 0 {
+			continue
+		}
+		dict = content[objStart:start]
+
+		dataStart := offset
+		if bytes.HasPrefix(content[dataStart:], []byte("\r\n")) {
+			dataStart += 2
+		} else if dataStart < len(content) && (content[dataStart] == '\n' || content[dataStart] == '\r') {
+			dataStart++
+		}
+		end := bytes.Index(content[dataStart:], []byte("endstream"))
+		if end < 0 {
+			return nil, nil, 0, false
+		}
+		// The end of line before endstream is left on the data; neither
+		// decompression nor content parsing minds it
+		return dict, content[dataStart : dataStart+end], dataStart + end + len("endstream"), true
+	}
+}
+
+// decodePDFStream returns the decoded data of a stream holding page content,
+// expanding it to at most limit bytes. Streams in other formats, or with
+// filters other than Flate, are skipped.
+func decodePDFStream(dict, data []byte, limit int) ([]byte, bool) {
+	if pdfSkippedStream.Match(dict) {
+		return nil, false
+	}
+
+	filters := pdfFilter.FindAll(dict, -1)
+	switch {
+	case len(filters) == 0:
+		return data, true
+	case len(filters) == 1 && string(filters[0]) == "/FlateDecode":
+		r, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, false
+		}
+		defer r.Close()
+		// Keep what was decoded from truncated or slightly damaged streams
+		decoded, _ := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+		return decoded, len(decoded) > 0
+	}
+	return nil, false
+}
+
+// pdfOperand is an operand of a content stream operator
+type pdfOperand struct {
+	text   string
+	number float64
+	kind   byte // 's' string, 'n' number, 'a' array, 'o' other
+	array  []pdfOperand
+}
+
+// showPDFText writes the text shown by the operators of a content stream
+func showPDFText(content []byte, b *strings.Builder) {
+	s := &pdfScanner{data: content}
+	var operands []pdfOperand
+	var arrays [][]pdfOperand
+
+	push := func(op pdfOperand) {
+		if len(arrays) > 0 {
+			arrays[len(arrays)-1] = append(arrays[len(arrays)-1], op)
+		} else {
+			operands = append(operands, op)
+		}
+	}
+	lastString := func() (string, bool) {
+		if len(operands) > 0 && operands[len(operands)-1].kind == 's' {
+			return operands[len(operands)-1].text, true
+		}
+		return "", false
+	}
+
+	for {
+		kind, token, ok := s.next()
+		if !ok {
+			return
+		}
+
+		switch kind {
+		case 's':
+			push(pdfOperand{kind: 's', text: token})
+		case 'n':
+			n, _ := strconv.ParseFloat(token, 64)
+			push(pdfOperand{kind: 'n', number: n})
+		case '[':
+			arrays = append(arrays, nil)
+		case ']':
+			if len(arrays) > 0 {
+				array := arrays[len(arrays)-1]
+				arrays = arrays[:len(arrays)-1]
+				push(pdfOperand{kind: 'a', array: array})
+			}
+		case 'o':
+			if len(arrays) > 0 {
+				continue
+			}
+			switch token {
+			case "Tj":
+				if text, ok := lastString(); ok {
+					b.WriteString(text)
+				}
+			case "'", "\"":
+				b.WriteByte('\n')
+				if text, ok := lastString(); ok {
+					b.WriteString(text)
+				}
+			case "TJ":
+				if len(operands) > 0 && operands[len(operands)-1].kind == 'a' {
+					for _, op := range operands[len(operands)-1].array {
+						switch {
+						case op.kind == 's':
+							b.WriteString(op.text)
+						case op.kind == 'n' && op.number < -250:
+							// A large negative adjustment is a word gap
+							b.WriteByte(' ')
+						}
+					}
+				}
+			case "Td", "TD":
+				if len(operands) >= 2 && operands[len(operands)-1].number != 0 {
+					b.WriteByte('\n')
+				} else {
+					b.WriteByte(' ')
+				}
+			case "T*", "Tm", "ET":
+				b.WriteByte('\n')
+			case "ID":
+				s.skipInlineImage()
+			}
+			operands = operands[:0]
+		default:
+			push(pdfOperand{kind: 'o'})
+		}
+	}
+}
+
+// pdfScanner splits a content stream into tokens
+type pdfScanner struct {
+	data []byte
+	pos  int
+}
+
+// isPDFSpace reports whether c is PDF whitespace
+func isPDFSpace(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+// isPDFDelimiter reports whether c ends a regular token
+func isPDFDelimiter(c byte) bool {
+	return isPDFSpace(c) || strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// next returns the next token and its kind: 's' for strings, 'n' for
+// numbers, '[' and ']' for array bounds, 'o' for operators and '/' for other
+// tokens such as names and dictionaries
+func (s *pdfScanner) next() (byte, string, bool) {
+	for s.pos < len(s.data) {
+		c := s.data[s.pos]
+		switch {
+		case isPDFSpace(c):
+			s.pos++
+		case c == '%':
+			for s.pos < len(s.data) && s.data[s.pos] != '\n' && s.data[s.pos] != '\r' {
+				s.pos++
+			}
+		case c == '(':
+			s.pos++
+			return 's', decodePDFString(s.literal()), true
+		case c == '<' && s.pos+1 < len(s.data) && s.data[s.pos+1] == '<':
+			s.pos += 2
+			return '/', "<<", true
+		case c == '>' && s.pos+1 < len(s.data) && s.data[s.pos+1] == '>':
+			s.pos += 2
+			return '/', ">>", true
+		case c == '<':
+			s.pos++
+			return 's', decodePDFString(s.hex()), true
+		case c == '[' || c == ']':
+			s.pos++
+			return c, string(c), true
+		case c == '/':
+			s.pos++
+			start := s.pos
+			for s.pos < len(s.data) && !isPDFDelimiter(s.data[s.pos]) {
+				s.pos++
+			}
+			return '/', string(s.data[start:s.pos]), true
+		case strings.IndexByte(">{}", c) >= 0:
+			s.pos++
+		default:
+			start := s.pos
+			for s.pos < len(s.data) && !isPDFDelimiter(s.data[s.pos]) {
+				s.pos++
+			}
+			token := string(s.data[start:s.pos])
+			if _, err := strconv.ParseFloat(token, 64); err == nil {
+				return 'n', token, true
+			}
+			return 'o', token, true
+		}
+	}
+	return 0, "", false
+}
+
+// literal reads the rest of a literal string, decoding its escapes
+func (s *pdfScanner) literal() []byte {
+	var out []byte
+	depth := 1
+	for s.pos < len(s.data) {
+		c := s.data[s.pos]
+		s.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+		case '\\':
+			if s.pos >= len(s.data) {
+				return out
+			}
+			e := s.data[s.pos]
+			s.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// A backslash at the end of a line continues the string
+				if e == '\r' && s.pos < len(s.data) && s.data[s.pos] == '\n' {
+					s.pos++
+				}
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && s.pos < len(s.data) && s.data[s.pos] >= '0' && s.data[s.pos] <= '7'; i++ {
+						n = n*8 + int(s.data[s.pos]-'0')
+						s.pos++
+					}
+					c = byte(n)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// hex reads the rest of a hexadecimal string
+func (s *pdfScanner) hex() []byte {
+	var digits []byte
+	for s.pos < len(s.data) && s.data[s.pos] != '>' {
+		c := s.data[s.pos]
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+		s.pos++
+	}
+	s.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		n, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(n)
+	}
+	return out
+}
+
+// skipInlineImage skips the data of an inline image up to its EI operator
+func (s *pdfScanner) skipInlineImage() {
+	for s.pos+2 < len(s.data) {
+		if isPDFSpace(s.data[s.pos]) && s.data[s.pos+1] == 'E' && s.data[s.pos+2] == 'I' &&
+			(s.pos+3 == len(s.data) || isPDFDelimiter(s.data[s.pos+3])) {
+			s.pos += 3
+			return
+		}
+		s.pos++
+	}
+	s.pos = len(s.data)
+}
+
+// decodePDFString decodes a string as UTF-16 when it starts with a byte
+// order mark and as PDFDocEncoding, approximated by Latin-1, otherwise
+func decodePDFString(raw []byte) string {
+	if bytes.HasPrefix(raw, []byte{0xFE, 0xFF}) {
+		return decodeUTF16(raw[2:], true)
+	}
+	runes := make([]rune, len(raw))
+	for i, c := range raw {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
@@ -0,0 +1,40 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// errNotText is returned for plain text files whose content is binary
+var errNotText = errors.New("content is not UTF-8 or UTF-16 text")
+
+// PlainText returns UTF-8 content as is and decodes UTF-16 content marked
+// with a byte order mark
+func PlainText(ctx context.Context, content []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(content, []byte{0xFE, 0xFF}):
+		return decodeUTF16(content[2:], true), nil
+	case bytes.HasPrefix(content, []byte{0xFF, 0xFE}):
+		return decodeUTF16(content[2:], false), nil
+	}
+	if !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0 {
+		return "", errNotText
+	}
+	return string(content), nil
+}
+
+// decodeUTF16 decodes UTF-16 in the given byte order, ignoring a trailing odd byte
+func decodeUTF16(content []byte, bigEndian bool) string {
+	units := make([]uint16, len(content)/2)
+	for i := range units {
+		hi, lo := content[2*i], content[2*i+1]
+		if !bigEndian {
+			hi, lo = lo, hi
+		}
+		units[i] = uint16(hi)<<8 | uint16(lo)
+	}
+	return string(utf16.Decode(units))
+}
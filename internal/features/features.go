@@ -0,0 +1,172 @@
+// Package features gates experimental subsystems behind flags that are set in
+// the configuration and can be overridden at runtime, so new code can ship
+// dark and be enabled per deployment.
+package features
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Flag names a feature
+type Flag string
+
+// Known flags
+const (
+	// Webhooks enables the Dropbox webhook receiver
+	Webhooks Flag = "webhooks"
+	// LLMAnalysis enables language model analysis of changed files
+	LLMAnalysis Flag = "llm_analysis"
+	// VectorSearch enables semantic search over file embeddings
+	VectorSearch Flag = "vector_search"
+)
+
+// Definition describes a flag
+type Definition struct {
+	Name        Flag
+	Description string
+	Default     bool
+}
+
+// Definitions lists every known flag
+var Definitions = []Definition{
+	{Name: Webhooks, Description: "Dropbox webhook receiver at /webhook", Default: true},
+	{Name: LLMAnalysis, Description: "Language model analysis of changed files", Default: false},
+	{Name: VectorSearch, Description: "Semantic search over file embeddings", Default: false},
+}
+
+// Sources of a flag's state
+const (
+	SourceDefault  = "default"
+	SourceConfig   = "config"
+	SourceOverride = "override"
+)
+
+// Status is the current state of a flag
+type Status struct {
+	Name        Flag   `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	Source      string `json:"source"`
+}
+
+// Registry holds the state of every known flag. Its version increases with
+// every runtime override so clients can detect changes.
+type Registry struct {
+	mu         sync.RWMutex
+	configured map[Flag]bool
+	overrides  map[Flag]bool
+	version    int
+}
+
+// Validate returns an error if any of the names is not a known flag
+func Validate(names []string) error {
+	for _, name := range names {
+		if _, ok := lookup(Flag(name)); !ok {
+			return fmt.Errorf("unknown feature flag %q", name)
+		}
+	}
+	return nil
+}
+
+// NewRegistry creates a registry with the configured flag values; flags that
+// are not configured use their defaults
+func NewRegistry(configured map[string]bool) (*Registry, error) {
+	r := &Registry{
+		configured: make(map[Flag]bool, len(configured)),
+		overrides:  make(map[Flag]bool),
+	}
+	for name, enabled := range configured {
+		if _, ok := lookup(Flag(name)); !ok {
+			return nil, fmt.Errorf("unknown feature flag %q", name)
+		}
+		r.configured[Flag(name)] = enabled
+	}
+	return r, nil
+}
+
+// Enabled reports whether the flag is on. A nil registry uses the defaults.
+func (r *Registry) Enabled(flag Flag) bool {
+	enabled, _ := r.state(flag)
+	return enabled
+}
+
+// Set overrides a flag at runtime
+func (r *Registry) Set(flag Flag, enabled bool) error {
+	if _, ok := lookup(flag); !ok {
+		return fmt.Errorf("unknown feature flag %q", flag)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.overrides[flag] = enabled
+	r.version++
+	return nil
+}
+
+// Clear removes a runtime override, restoring the configured value
+func (r *Registry) Clear(flag Flag) error {
+	if _, ok := lookup(flag); !ok {
+		return fmt.Errorf("unknown feature flag %q", flag)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.overrides[flag]; ok {
+		delete(r.overrides, flag)
+		r.version++
+	}
+	return nil
+}
+
+// Version returns the number of runtime changes made to the registry
+func (r *Registry) Version() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.version
+}
+
+// Status returns the state of every known flag, sorted by name. A nil
+// registry reports the defaults.
+func (r *Registry) Status() []Status {
+	statuses := make([]Status, 0, len(Definitions))
+	for _, def := range Definitions {
+		enabled, source := r.state(def.Name)
+		statuses = append(statuses, Status{
+			Name:        def.Name,
+			Description: def.Description,
+			Enabled:     enabled,
+			Source:      source,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// state returns whether the flag is on and where that value comes from
+func (r *Registry) state(flag Flag) (bool, string) {
+	if r != nil {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		if enabled, ok := r.overrides[flag]; ok {
+			return enabled, SourceOverride
+		}
+		if enabled, ok := r.configured[flag]; ok {
+			return enabled, SourceConfig
+		}
+	}
+	def, _ := lookup(flag)
+	return def.Default, SourceDefault
+}
+
+// lookup returns the definition of a known flag
+func lookup(flag Flag) (Definition, bool) {
+	for _, def := range Definitions {
+		if def.Name == flag {
+			return def, true
+		}
+	}
+	return Definition{}, false
+}
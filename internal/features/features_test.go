@@ -0,0 +1,47 @@
+package features
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	r, err := NewRegistry(map[string]bool{"llm_analysis": true})
+	require.NoError(t, err)
+
+	assert.True(t, r.Enabled(Webhooks), "webhooks default to on")
+	assert.True(t, r.Enabled(LLMAnalysis), "configured value")
+	assert.False(t, r.Enabled(VectorSearch), "experimental features ship dark")
+
+	require.NoError(t, r.Set(LLMAnalysis, false))
+	require.NoError(t, r.Set(VectorSearch, true))
+	assert.False(t, r.Enabled(LLMAnalysis))
+	assert.True(t, r.Enabled(VectorSearch))
+	assert.Equal(t, 2, r.Version())
+
+	require.NoError(t, r.Clear(LLMAnalysis))
+	assert.True(t, r.Enabled(LLMAnalysis), "clearing restores the configured value")
+	assert.Equal(t, 3, r.Version())
+
+	assert.Equal(t, []Status{
+		{Name: LLMAnalysis, Description: "Language model analysis of changed files", Enabled: true, Source: SourceConfig},
+		{Name: VectorSearch, Description: "Semantic search over file embeddings", Enabled: true, Source: SourceOverride},
+		{Name: Webhooks, Description: "Dropbox webhook receiver at /webhook", Enabled: true, Source: SourceDefault},
+	}, r.Status())
+
+	assert.Error(t, r.Set("teleport", true))
+	assert.Error(t, r.Clear("teleport"))
+}
+
+func TestRegistry_UnknownFlags(t *testing.T) {
+	_, err := NewRegistry(map[string]bool{"teleport": true})
+	assert.ErrorContains(t, err, `unknown feature flag "teleport"`)
+	assert.Error(t, Validate([]string{"webhooks", "teleport"}))
+	assert.NoError(t, Validate([]string{"webhooks"}))
+
+	var nilRegistry *Registry
+	assert.True(t, nilRegistry.Enabled(Webhooks))
+	assert.False(t, nilRegistry.Enabled(VectorSearch))
+}
@@ -0,0 +1,117 @@
+package filter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Filter decides which file paths are reported using include and exclude
+// glob patterns. Patterns are matched case-insensitively. A pattern without a
+// slash matches the file name at any depth; a pattern with a slash matches the
+// whole relative path, where "**" matches any number of folders.
+type Filter struct {
+	include []string
+	exclude []string
+}
+
+// New creates a filter. An empty include list matches every path; exclusions
+// take precedence over inclusions.
+func New(include, exclude []string) (*Filter, error) {
+	if err := Validate(include); err != nil {
+		return nil, err
+	}
+	if err := Validate(exclude); err != nil {
+		return nil, err
+	}
+
+	return &Filter{
+		include: normalize(include),
+		exclude: normalize(exclude),
+	}, nil
+}
+
+// Validate checks that every pattern is a well-formed glob
+func Validate(patterns []string) error {
+	for _, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("empty pattern")
+		}
+		for _, segment := range strings.Split(pattern, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// IsEmpty reports whether the filter has no patterns and so matches everything
+func (f *Filter) IsEmpty() bool {
+	return f == nil || (len(f.include) == 0 && len(f.exclude) == 0)
+}
+
+// Match reports whether the relative path passes the filter; a nil filter
+// matches everything
+func (f *Filter) Match(rel string) bool {
+	if f.IsEmpty() {
+		return true
+	}
+
+	rel = strings.Trim(strings.ToLower(rel), "/")
+	if matchAny(f.exclude, rel) {
+		return false
+	}
+	return len(f.include) == 0 || matchAny(f.include, rel)
+}
+
+// normalize lowercases patterns and strips leading and trailing slashes
+func normalize(patterns []string) []string {
+	normalized := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		normalized = append(normalized, strings.Trim(strings.ToLower(strings.TrimSpace(pattern)), "/"))
+	}
+	return normalized
+}
+
+// matchAny reports whether rel matches any of the patterns
+func matchAny(patterns []string, rel string) bool {
+	segments := strings.Split(rel, "/")
+	for _, pattern := range patterns {
+		if !strings.Contains(pattern, "/") && pattern != "**" {
+			if ok, _ := path.Match(pattern, segments[len(segments)-1]); ok {
+				return true
+			}
+			continue
+		}
+		if matchSegments(strings.Split(pattern, "/"), segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments matches path segments against pattern segments, letting "**"
+// stand for zero or more segments
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
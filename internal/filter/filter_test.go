@@ -0,0 +1,57 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter_Match(t *testing.T) {
+	f, err := New([]string{"*.docx", "Reports/**"}, []string{"*.tmp", ".~lock*"})
+	require.NoError(t, err)
+
+	tests := map[string]bool{
+		"letter.docx":             true,
+		"Drafts/deep/Letter.DOCX": true,
+		"reports/2025/q1.pdf":     true,
+		"Reports":                 true,
+		"notes.txt":               false,
+		"Other/Reports/q1.pdf":    false,
+		"Reports/q1.tmp":          false,
+		".~lock.letter.docx#":     false,
+	}
+	for rel, want := range tests {
+		assert.Equal(t, want, f.Match(rel), rel)
+	}
+}
+
+func TestFilter_DoubleStar(t *testing.T) {
+	f, err := New([]string{"**/build/*.log", "docs/**/*.md"}, nil)
+	require.NoError(t, err)
+
+	assert.True(t, f.Match("build/out.log"))
+	assert.True(t, f.Match("a/b/build/out.log"))
+	assert.False(t, f.Match("a/build/sub/out.log"))
+	assert.True(t, f.Match("docs/readme.md"))
+	assert.True(t, f.Match("docs/a/b/readme.md"))
+	assert.False(t, f.Match("src/readme.md"))
+}
+
+func TestFilter_Empty(t *testing.T) {
+	var f *Filter
+	assert.True(t, f.IsEmpty())
+	assert.True(t, f.Match("anything.txt"))
+
+	f, err := New(nil, nil)
+	require.NoError(t, err)
+	assert.True(t, f.IsEmpty())
+	assert.True(t, f.Match("anything.txt"))
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate([]string{"*.txt", "a/**/b", "[ab]*"}))
+	assert.Error(t, Validate([]string{"[bad"}))
+	assert.Error(t, Validate([]string{"a/[bad/b"}))
+	assert.Error(t, Validate([]string{" "}))
+}
@@ -0,0 +1,129 @@
+// Package freshness tracks how long detected changes wait to be reported,
+// giving the monitoring pipeline a freshness SLO.
+package freshness
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/metrics"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// Prometheus metrics describing the reporting backlog
+var (
+	oldestAgeSeconds = metrics.Default.Gauge("oldest_unreported_change_age_seconds",
+		"Seconds since the oldest detected but unreported change was detected; 0 when none are waiting.")
+	unreportedChanges = metrics.Default.Gauge("unreported_changes",
+		"Detected changes waiting to be reported.")
+	thresholdSeconds = metrics.Default.Gauge("oldest_unreported_change_threshold_seconds",
+		"Age of the oldest unreported change above which the freshness SLO is breached; 0 when unset.")
+	breachedGauge = metrics.Default.Gauge("freshness_slo_breached",
+		"1 while the oldest unreported change is older than the threshold, otherwise 0.")
+)
+
+// Status is the result of a freshness check
+type Status struct {
+	// Age is how long the oldest unreported change has been waiting
+	Age time.Duration
+	// Pending is the number of unreported changes
+	Pending   int
+	Threshold time.Duration
+	Breached  bool
+	// Changed is set when the check breached or recovered the SLO
+	Changed bool
+}
+
+// Tracker records when changes are detected and forgets them once they are
+// reported. A change detected again before it is reported keeps its first
+// detection time.
+type Tracker struct {
+	mu        sync.Mutex
+	pending   map[string]time.Time
+	threshold time.Duration
+	breached  bool
+	now       func() time.Time
+}
+
+// NewTracker creates a tracker whose SLO is breached once a change has waited
+// longer than threshold; a zero threshold only tracks the age
+func NewTracker(threshold time.Duration) *Tracker {
+	thresholdSeconds.Set(threshold.Seconds())
+	return &Tracker{
+		pending:   make(map[string]time.Time),
+		threshold: threshold,
+		now:       time.Now,
+	}
+}
+
+// Detected records changes that are waiting to be reported
+func (t *Tracker) Detected(changes []models.FileChange) {
+	if t == nil || len(changes) == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	for _, change := range changes {
+		key := pathKey(change.Path)
+		if _, ok := t.pending[key]; !ok {
+			t.pending[key] = now
+		}
+	}
+	t.updateGauges(now)
+}
+
+// Reported forgets changes that have been reported, or deliberately not
+// reported such as those in a muted folder
+func (t *Tracker) Reported(changes []models.FileChange) {
+	if t == nil || len(changes) == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, change := range changes {
+		delete(t.pending, pathKey(change.Path))
+	}
+	t.updateGauges(t.now())
+}
+
+// Check refreshes the metrics and reports whether the SLO is breached
+func (t *Tracker) Check() Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := t.updateGauges(t.now())
+	status.Changed = status.Breached != t.breached
+	t.breached = status.Breached
+	return status
+}
+
+// updateGauges computes the current status and publishes it; t.mu must be held
+func (t *Tracker) updateGauges(now time.Time) Status {
+	status := Status{Pending: len(t.pending), Threshold: t.threshold}
+	for _, detected := range t.pending {
+		if age := now.Sub(detected); age > status.Age {
+			status.Age = age
+		}
+	}
+	status.Breached = t.threshold > 0 && status.Age > t.threshold
+
+	oldestAgeSeconds.Set(status.Age.Seconds())
+	unreportedChanges.Set(float64(status.Pending))
+	if status.Breached {
+		breachedGauge.Set(1)
+	} else {
+		breachedGauge.Set(0)
+	}
+	return status
+}
+
+// pathKey normalises a path so detection and reporting of the same file match
+func pathKey(path string) string {
+	return strings.ToLower(path)
+}
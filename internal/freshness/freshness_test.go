@@ -0,0 +1,67 @@
+package freshness
+
+import (
+	"testing"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/metrics"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracker_OldestUnreportedChange(t *testing.T) {
+	now := time.Now()
+	tracker := NewTracker(10 * time.Minute)
+	tracker.now = func() time.Time { return now }
+
+	status := tracker.Check()
+	assert.Zero(t, status.Age)
+	assert.False(t, status.Breached)
+	assert.False(t, status.Changed)
+
+	tracker.Detected([]models.FileChange{{Path: "/docs/a.txt"}})
+	now = now.Add(5 * time.Minute)
+	tracker.Detected([]models.FileChange{{Path: "/docs/b.txt"}, {Path: "/Docs/A.txt"}})
+
+	now = now.Add(6 * time.Minute)
+	status = tracker.Check()
+	assert.Equal(t, 11*time.Minute, status.Age, "a redetected change keeps its first detection time")
+	assert.Equal(t, 2, status.Pending)
+	assert.True(t, status.Breached)
+	assert.True(t, status.Changed)
+	assert.Equal(t, float64(660), metrics.Default.Snapshot()["oldest_unreported_change_age_seconds"])
+	assert.Equal(t, float64(1), metrics.Default.Snapshot()["freshness_slo_breached"])
+
+	status = tracker.Check()
+	assert.True(t, status.Breached)
+	assert.False(t, status.Changed, "still breached")
+
+	tracker.Reported([]models.FileChange{{Path: "/docs/a.txt"}})
+	status = tracker.Check()
+	assert.Equal(t, 6*time.Minute, status.Age)
+	assert.Equal(t, 1, status.Pending)
+	assert.False(t, status.Breached)
+	assert.True(t, status.Changed)
+
+	tracker.Reported([]models.FileChange{{Path: "/docs/b.txt"}})
+	assert.Zero(t, tracker.Check().Age)
+	assert.Equal(t, float64(0), metrics.Default.Snapshot()["unreported_changes"])
+}
+
+func TestTracker_NoThreshold(t *testing.T) {
+	now := time.Now()
+	tracker := NewTracker(0)
+	tracker.now = func() time.Time { return now }
+
+	tracker.Detected([]models.FileChange{{Path: "/a.txt"}})
+	now = now.Add(24 * time.Hour)
+	status := tracker.Check()
+	assert.Equal(t, 24*time.Hour, status.Age)
+	assert.False(t, status.Breached)
+}
+
+func TestTracker_Nil(t *testing.T) {
+	var tracker *Tracker
+	tracker.Detected([]models.FileChange{{Path: "/a.txt"}})
+	tracker.Reported([]models.FileChange{{Path: "/a.txt"}})
+}
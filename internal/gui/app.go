@@ -11,6 +11,7 @@ import (
 
 	dicontainer "github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/container"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/safego"
 )
 
 // App represents the GUI application
@@ -20,6 +21,7 @@ type App struct {
 	app          fyne.App
 	window       fyne.Window
 	monContainer *dicontainer.Container
+	cancel       context.CancelFunc
 }
 
 // NewApp creates a new GUI application
@@ -54,8 +56,20 @@ func (a *App) Start(ctx context.Context) error {
 		statusLabel,
 	)
 
+	// Show recent changes below the status, refreshed incrementally
+	content := fyne.CanvasObject(a.guiContainer)
+	if store := a.monContainer.GetDB(); store != nil {
+		changes := newChangeList(store)
+		content = container.NewBorder(a.guiContainer, nil, nil, nil, changes.widget)
+		a.window.Resize(fyne.NewSize(800, 600))
+
+		var refreshCtx context.Context
+		refreshCtx, a.cancel = context.WithCancel(context.Background())
+		safego.Go(nil, "change list", func() { changes.run(refreshCtx) })
+	}
+
 	// Set window content
-	a.window.SetContent(a.guiContainer)
+	a.window.SetContent(content)
 
 	// Show and run
 	a.window.Show()
@@ -72,6 +86,9 @@ func (a *App) Stop(ctx context.Context) error {
 	}
 
 	// TODO: Clean up GUI components here
+	if a.cancel != nil {
+		a.cancel()
+	}
 
 	// Stop container
 	if err := a.monContainer.Stop(ctx); err != nil {
@@ -0,0 +1,167 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+)
+
+// Change list settings
+const (
+	changeWindow    = 24 * time.Hour
+	changeRefresh   = 30 * time.Second
+	changePageSize  = 500
+	maxChangesShown = 1000
+)
+
+// changeList shows the most recent changes, newest first. It loads the last
+// day of changes once and from then on fetches only the changes stored after
+// its cursor, so refreshing stays cheap however large the history grows.
+type changeList struct {
+	store  *db.DB
+	widget *widget.List
+
+	mu      sync.Mutex
+	cursor  int64
+	changes []db.FileChange
+	shown   map[int64]bool
+}
+
+// newChangeList creates an empty change list backed by store
+func newChangeList(store *db.DB) *changeList {
+	l := &changeList{store: store, shown: make(map[int64]bool)}
+	l.widget = widget.NewList(
+		l.length,
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		l.update,
+	)
+	return l
+}
+
+// length returns the number of changes shown
+func (l *changeList) length() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.changes)
+}
+
+// update renders the change at index id into item
+func (l *changeList) update(id widget.ListItemID, item fyne.CanvasObject) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if id >= len(l.changes) {
+		return
+	}
+	change := l.changes[id]
+	item.(*widget.Label).SetText(fmt.Sprintf("%s  %s  %.2f MB",
+		change.ModifiedAt.Local().Format("2006-01-02 15:04"), change.FilePath, float64(change.Size)/1048576))
+}
+
+// load replaces the list with the changes of the last day. The cursor is
+// taken first, so a change stored while loading is fetched again by the
+// next refresh and skipped as already shown.
+func (l *changeList) load(ctx context.Context) error {
+	cursor, err := l.store.LatestFileChangeID(ctx)
+	if err != nil {
+		return err
+	}
+	changes, err := l.store.GetRecentFileChangesForAccount(ctx, "", time.Now().Add(-changeWindow))
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.cursor = cursor
+	l.changes = nil
+	l.shown = make(map[int64]bool)
+	l.add(changes)
+	l.mu.Unlock()
+
+	l.widget.Refresh()
+	return nil
+}
+
+// refresh adds the changes stored since the last load or refresh
+func (l *changeList) refresh(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		cursor := l.cursor
+		l.mu.Unlock()
+
+		page, err := l.store.GetFileChangesAfter(ctx, "", cursor, changePageSize)
+		if err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			return nil
+		}
+
+		// Pages are oldest first and the list is newest first
+		newest := make([]db.FileChange, len(page))
+		for i, change := range page {
+			newest[len(page)-1-i] = change
+		}
+
+		l.mu.Lock()
+		l.cursor = page[len(page)-1].ID
+		l.add(newest)
+		l.mu.Unlock()
+		l.widget.Refresh()
+
+		if len(page) < changePageSize {
+			return nil
+		}
+	}
+}
+
+// add puts changes, newest first, at the top of the list and drops the
+// oldest beyond maxChangesShown. The caller holds l.mu.
+func (l *changeList) add(changes []db.FileChange) {
+	fresh := make([]db.FileChange, 0, len(changes)+len(l.changes))
+	for _, change := range changes {
+		if !l.shown[change.ID] {
+			l.shown[change.ID] = true
+			fresh = append(fresh, change)
+		}
+	}
+	fresh = append(fresh, l.changes...)
+	for _, change := range fresh[min(len(fresh), maxChangesShown):] {
+		delete(l.shown, change.ID)
+	}
+	l.changes = fresh[:min(len(fresh), maxChangesShown)]
+}
+
+// run loads the list and refreshes it until ctx is done
+func (l *changeList) run(ctx context.Context) {
+	loaded := false
+	poll := func() {
+		var err error
+		if loaded {
+			err = l.refresh(ctx)
+		} else if err = l.load(ctx); err == nil {
+			loaded = true
+		}
+		if err != nil && ctx.Err() == nil {
+			logging.Component(nil, "gui").Error("Failed to refresh changes", "error", err)
+		}
+	}
+
+	poll()
+	ticker := time.NewTicker(changeRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			poll()
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"sync"
 	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
 )
 
 // Status represents the health status of a component
@@ -33,6 +35,7 @@ type HealthChecker struct {
 	interval   time.Duration
 	mu         sync.RWMutex
 	stopCh     chan struct{}
+	stopOnce   sync.Once
 }
 
 // NewHealthChecker creates a new health checker
@@ -73,9 +76,9 @@ func (h *HealthChecker) Start(ctx context.Context) {
 	}
 }
 
-// Stop stops the health checker
+// Stop stops the health checker; calling it again has no effect
 func (h *HealthChecker) Stop() {
-	close(h.stopCh)
+	h.stopOnce.Do(func() { close(h.stopCh) })
 }
 
 // checkAll checks all registered components
@@ -132,3 +135,47 @@ func (h *HealthChecker) IsHealthy() bool {
 	}
 	return true
 }
+
+// Report is the health of one component at the time it was checked
+type Report struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	// State is the lifecycle state of the component, or for Dropbox the
+	// state of the client's circuit breaker
+	State       string     `json:"state,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	// LastSuccess is when the component last polled Dropbox successfully
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+}
+
+// Ready reports whether every component is healthy
+func Ready(reports []Report) bool {
+	for _, report := range reports {
+		if report.Status != StatusHealthy {
+			return false
+		}
+	}
+	return true
+}
+
+// ComponentReport reports a component by its lifecycle state: it is healthy
+// while running without err, and starting or stopped around that
+func ComponentReport(name string, component lifecycle.Component, err error) Report {
+	state := component.State()
+	report := Report{Name: name, State: state.String()}
+	switch state {
+	case lifecycle.StateRunning:
+		report.Status = StatusHealthy
+	case lifecycle.StateUninitialized, lifecycle.StateInitialized, lifecycle.StateStarting:
+		report.Status = StatusStarting
+	case lifecycle.StateStopping, lifecycle.StateStopped:
+		report.Status = StatusStopped
+	default:
+		report.Status = StatusUnhealthy
+	}
+	if err != nil && report.Status == StatusHealthy {
+		report.Status, report.LastError = StatusUnhealthy, err.Error()
+	}
+	return report
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -74,7 +75,42 @@ func TestHealthChecker_Start(t *testing.T) {
 
 	// Stop the checker
 	checker.Stop()
+	checker.Stop() // stopping twice must not panic
 
 	// Verify check was called multiple times
 	assert.Greater(t, checkCount, 1)
 }
+
+func TestComponentReport(t *testing.T) {
+	component := lifecycle.NewBaseComponent("test")
+
+	tests := []struct {
+		state lifecycle.ComponentState
+		err   error
+		want  Status
+	}{
+		{lifecycle.StateInitialized, nil, StatusStarting},
+		{lifecycle.StateRunning, nil, StatusHealthy},
+		{lifecycle.StateRunning, errors.New("test error"), StatusUnhealthy},
+		{lifecycle.StateStopped, errors.New("test error"), StatusStopped},
+		{lifecycle.StateFailed, nil, StatusUnhealthy},
+	}
+	for _, tt := range tests {
+		component.SetState(tt.state)
+		report := ComponentReport("test", testComponent{component}, tt.err)
+		assert.Equal(t, tt.want, report.Status, tt.state.String())
+		assert.Equal(t, tt.state.String(), report.State)
+	}
+
+	assert.True(t, Ready([]Report{{Status: StatusHealthy}, {Status: StatusHealthy}}))
+	assert.False(t, Ready([]Report{{Status: StatusHealthy}, {Status: StatusStarting}}))
+}
+
+// testComponent is a lifecycle component in whatever state it is set to
+type testComponent struct {
+	*lifecycle.BaseComponent
+}
+
+func (c testComponent) Start(ctx context.Context) error  { return nil }
+func (c testComponent) Stop(ctx context.Context) error   { return nil }
+func (c testComponent) Health(ctx context.Context) error { return nil }
@@ -0,0 +1,144 @@
+// Package i18n translates the text of reports, email subjects and the web
+// dashboard. Messages are looked up by key in the catalog of the configured
+// language, falling back to English and then to the key itself.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Language is a supported language, named by its ISO 639-1 code
+type Language string
+
+// The supported languages
+const (
+	English   Language = "en"
+	Afrikaans Language = "af"
+	Dutch     Language = "nl"
+)
+
+// Default is the language used when none is configured
+const Default = English
+
+//go:embed catalogs/*.json
+var catalogFiles embed.FS
+
+// catalogs holds the messages of each supported language by key
+var catalogs = mustLoad()
+
+// mustLoad reads the embedded catalogs, one per language
+func mustLoad() map[Language]map[string]string {
+	entries, err := catalogFiles.ReadDir("catalogs")
+	if err != nil {
+		panic(fmt.Sprintf("failed to read message catalogs: %v", err))
+	}
+
+	result := make(map[Language]map[string]string, len(entries))
+	for _, entry := range entries {
+		data, err := catalogFiles.ReadFile("catalogs/" + entry.Name())
+		if err != nil {
+			panic(fmt.Sprintf("failed to read message catalog %s: %v", entry.Name(), err))
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("failed to parse message catalog %s: %v", entry.Name(), err))
+		}
+		result[Language(strings.TrimSuffix(entry.Name(), ".json"))] = messages
+	}
+	return result
+}
+
+// Languages returns the supported languages in alphabetical order
+func Languages() []Language {
+	languages := make([]Language, 0, len(catalogs))
+	for language := range catalogs {
+		languages = append(languages, language)
+	}
+	sort.Slice(languages, func(i, j int) bool { return languages[i] < languages[j] })
+	return languages
+}
+
+// ParseLanguage returns the supported language of a tag such as "nl" or
+// "nl-BE", ignoring case and the region; an empty tag is the default
+func ParseLanguage(tag string) (Language, error) {
+	base := strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(base, "-_"); i >= 0 {
+		base = base[:i]
+	}
+	if base == "" {
+		return Default, nil
+	}
+	if _, ok := catalogs[Language(base)]; !ok {
+		return "", fmt.Errorf("unsupported language %q, expected one of %v", tag, Languages())
+	}
+	return Language(base), nil
+}
+
+// Catalog translates messages into one language
+type Catalog struct {
+	language Language
+	messages map[string]string
+}
+
+// New returns the catalog of the language tag, or an error when the
+// language is not supported
+func New(tag string) (*Catalog, error) {
+	language, err := ParseLanguage(tag)
+	if err != nil {
+		return nil, err
+	}
+	return &Catalog{language: language, messages: catalogs[language]}, nil
+}
+
+// Lookup returns the catalog of the language tag, or the default catalog
+// when the language is not supported
+func Lookup(tag string) *Catalog {
+	catalog, err := New(tag)
+	if err != nil {
+		catalog, _ = New(string(Default))
+	}
+	return catalog
+}
+
+// Language returns the language of the catalog; a nil catalog is English
+func (c *Catalog) Language() Language {
+	if c == nil {
+		return Default
+	}
+	return c.language
+}
+
+// T returns the message of the key formatted with args as by fmt.Sprintf.
+// Messages missing from the catalog are taken from the default language,
+// and unknown keys are returned as they are.
+func (c *Catalog) T(key string, args ...interface{}) string {
+	message, ok := "", false
+	if c != nil {
+		message, ok = c.messages[key]
+	}
+	if !ok {
+		if message, ok = catalogs[Default][key]; !ok {
+			return key
+		}
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// Messages returns the messages whose keys start with prefix, completed
+// from the default language, for rendering on the client
+func (c *Catalog) Messages(prefix string) map[string]string {
+	result := make(map[string]string)
+	for key := range catalogs[Default] {
+		if strings.HasPrefix(key, prefix) {
+			result[key] = c.T(key)
+		}
+	}
+	return result
+}
@@ -0,0 +1,60 @@
+package i18n
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// verbs matches the formatting verbs of a message
+var verbs = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestCatalogs_Complete(t *testing.T) {
+	require.ElementsMatch(t, []Language{Afrikaans, English, Dutch}, Languages())
+
+	for _, language := range Languages() {
+		for key, message := range catalogs[Default] {
+			translated, ok := catalogs[language][key]
+			if !assert.True(t, ok, "%s is missing %s", language, key) {
+				continue
+			}
+			assert.Equal(t, verbs.FindAllString(message, -1), verbs.FindAllString(translated, -1),
+				"%s %s formats different values", language, key)
+		}
+		for key := range catalogs[language] {
+			assert.Contains(t, catalogs[Default], key, "%s has unknown key", language)
+		}
+	}
+}
+
+func TestParseLanguage(t *testing.T) {
+	for tag, want := range map[string]Language{"": English, "en": English, "AF": Afrikaans, "nl-BE": Dutch, "nl_NL": Dutch} {
+		language, err := ParseLanguage(tag)
+		require.NoError(t, err, tag)
+		assert.Equal(t, want, language, tag)
+	}
+
+	_, err := ParseLanguage("fr")
+	assert.Error(t, err)
+}
+
+func TestCatalog_T(t *testing.T) {
+	nl, err := New("nl")
+	require.NoError(t, err)
+	assert.Equal(t, "3 bestanden", nl.T("report.n_files", 3))
+	assert.Equal(t, "Dropbox-wijzigingen in /docs", nl.T("report.folder_title", "/docs"))
+	assert.Equal(t, "no.such.key", nl.T("no.such.key"))
+
+	// Unsupported languages and nil catalogs fall back to English
+	assert.Equal(t, English, Lookup("fr").Language())
+	assert.Equal(t, "3 files", Lookup("fr").T("report.n_files", 3))
+	var none *Catalog
+	assert.Equal(t, "Total Changes", none.T("report.total_changes"))
+
+	af := Lookup("af")
+	messages := af.Messages("ui.")
+	assert.Equal(t, "Rekening", messages["ui.account"])
+	assert.NotContains(t, messages, "report.title")
+}
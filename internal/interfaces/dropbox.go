@@ -2,15 +2,24 @@ package interfaces
 
 import (
 	"context"
+	"io"
+
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
 )
 
-// DropboxClient defines the interface for Dropbox operations
+// DropboxClient defines the interface for Dropbox operations. It is the one
+// client contract injected across the monitor; dropbox.DropboxClient is the
+// implementation talking to Dropbox and mocks.DropboxClient the test double.
 type DropboxClient interface {
 	ListFolder(ctx context.Context, path string) ([]*models.FileMetadata, error)
 	GetFileContent(ctx context.Context, path string) ([]byte, error)
+	// GetFileContentStream opens a download of the file at path without
+	// buffering it; the caller closes the stream
+	GetFileContentStream(ctx context.Context, path string) (io.ReadCloser, error)
 	GetChangesLast24Hours(ctx context.Context) ([]*models.FileMetadata, error)
 	GetChangesLast10Minutes(ctx context.Context) ([]*models.FileMetadata, error)
 	GetChanges(ctx context.Context) ([]*models.FileMetadata, error)
 	GetFileChanges(ctx context.Context) ([]models.FileChange, error)
+	GetLatestCursor(ctx context.Context, path string) (string, error)
+	ListFolderContinue(ctx context.Context, cursor string) ([]*models.FileMetadata, string, error)
 }
@@ -0,0 +1,48 @@
+// Package jsonhttp sends JSON requests to the HTTP APIs of AI providers
+package jsonhttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBodyBytes caps how much of an error response is quoted in errors
+const maxErrorBodyBytes = 4096
+
+// Post sends body as JSON to endpoint with the extra header and decodes the
+// JSON response into out. name describes the request in errors, such as
+// "embedding" or a provider name.
+func Post(ctx context.Context, client *http.Client, name, endpoint string, header http.Header, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s request: %w", name, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", name, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+		return fmt.Errorf("%s request failed with status %d: %s", name, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", name, err)
+	}
+	return nil
+}
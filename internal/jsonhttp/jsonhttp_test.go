@@ -0,0 +1,37 @@
+package jsonhttp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		if r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "invalid key", http.StatusUnauthorized)
+			return
+		}
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		json.NewEncoder(w).Encode(map[string]string{"echo": body["text"]})
+	}))
+	defer server.Close()
+
+	var out struct {
+		Echo string `json:"echo"`
+	}
+	header := http.Header{"Authorization": {"Bearer key"}}
+	err := Post(context.Background(), server.Client(), "test", server.URL, header, map[string]string{"text": "hi"}, &out)
+	require.NoError(t, err)
+	assert.Equal(t, "hi", out.Echo)
+
+	err = Post(context.Background(), server.Client(), "test", server.URL, nil, map[string]string{}, &out)
+	assert.EqualError(t, err, "test request failed with status 401: invalid key")
+}
@@ -67,6 +67,19 @@ type Component interface {
 	Health(context.Context) error
 }
 
+// Initializer is implemented by components that must be initialized before
+// they are started
+type Initializer interface {
+	Initialize(context.Context) error
+}
+
+// Reloader is implemented by components that can apply a new configuration
+// while running, without a stop and start cycle. cfg is the application
+// configuration; each component takes the settings it uses from it.
+type Reloader interface {
+	Reload(ctx context.Context, cfg interface{}) error
+}
+
 // BaseComponent provides a base implementation of the Component interface
 type BaseComponent struct {
 	mu    sync.RWMutex
@@ -0,0 +1,166 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Graph starts components after the components they depend on and stops
+// them in the reverse order. A component is only started once each of its
+// dependencies is healthy; if one fails, the components already started are
+// stopped again.
+type Graph struct {
+	mu    sync.Mutex
+	nodes map[string]*graphNode
+	// names holds the components in the order they were added, which
+	// orders components that do not depend on each other
+	names []string
+}
+
+// graphNode is a component of a graph and the names of its dependencies
+type graphNode struct {
+	component Component
+	dependsOn []string
+}
+
+// NewGraph creates an empty component graph
+func NewGraph() *Graph {
+	return &Graph{nodes: make(map[string]*graphNode)}
+}
+
+// Add adds component under name, to be started after the components named
+// in dependsOn. Dependencies may be added after the components that need them.
+func (g *Graph) Add(name string, component Component, dependsOn ...string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if component == nil {
+		return fmt.Errorf("component %s is nil", name)
+	}
+	if _, ok := g.nodes[name]; ok {
+		return fmt.Errorf("component %s is already added", name)
+	}
+	g.nodes[name] = &graphNode{component: component, dependsOn: dependsOn}
+	g.names = append(g.names, name)
+	return nil
+}
+
+// Order returns the names of the components in the order they are started.
+// It fails if a dependency is missing or the dependencies form a cycle.
+func (g *Graph) Order() ([]string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.order()
+}
+
+// order sorts the components topologically, keeping the order they were
+// added in where dependencies allow; the caller holds the lock
+func (g *Graph) order() ([]string, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[string]int, len(g.nodes))
+	order := make([]string, 0, len(g.nodes))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch marks[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle: %v", append(path, name))
+		}
+		marks[name] = visiting
+		for _, dep := range g.nodes[name].dependsOn {
+			if _, ok := g.nodes[dep]; !ok {
+				return fmt.Errorf("component %s depends on unknown component %s", name, dep)
+			}
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		marks[name] = visited
+		order = append(order, name)
+		return nil
+	}
+
+	for _, name := range g.names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// Start starts the components in dependency order. Before a component is
+// started the health of its dependencies is checked; on any failure the
+// components started so far are stopped in reverse order.
+func (g *Graph) Start(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	order, err := g.order()
+	if err != nil {
+		return err
+	}
+
+	started := make([]string, 0, len(order))
+	healthy := make(map[string]bool)
+	rollback := func(err error) error {
+		if stopErr := g.stop(ctx, started); stopErr != nil {
+			return errors.Join(err, fmt.Errorf("rollback failed: %w", stopErr))
+		}
+		return err
+	}
+
+	for _, name := range order {
+		node := g.nodes[name]
+		for _, dep := range node.dependsOn {
+			if healthy[dep] {
+				continue
+			}
+			if err := g.nodes[dep].component.Health(ctx); err != nil {
+				return rollback(fmt.Errorf("cannot start %s: %s is unhealthy: %w", name, dep, err))
+			}
+			healthy[dep] = true
+		}
+		if err := node.component.Start(ctx); err != nil {
+			return rollback(fmt.Errorf("failed to start %s: %w", name, err))
+		}
+		started = append(started, name)
+	}
+	return nil
+}
+
+// Stop stops the running components in the reverse of their start order,
+// carrying on past failures; components that are not running are skipped
+func (g *Graph) Stop(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	order, err := g.order()
+	if err != nil {
+		return err
+	}
+	return g.stop(ctx, order)
+}
+
+// stop stops the running components in names in reverse order; the caller
+// holds the lock
+func (g *Graph) stop(ctx context.Context, names []string) error {
+	var errs []error
+	for i := len(names) - 1; i >= 0; i-- {
+		component := g.nodes[names[i]].component
+		if component.State() != StateRunning {
+			continue
+		}
+		if err := component.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop %s: %w", names[i], err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,137 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// orderedComponent records the order components are started and stopped in
+type orderedComponent struct {
+	*mockComponent
+	events *[]string
+}
+
+func newOrderedComponent(name string, events *[]string) *orderedComponent {
+	return &orderedComponent{mockComponent: newMockComponent(name), events: events}
+}
+
+func (c *orderedComponent) Start(ctx context.Context) error {
+	if err := c.mockComponent.Start(ctx); err != nil {
+		return err
+	}
+	*c.events = append(*c.events, "start "+c.Name())
+	return nil
+}
+
+func (c *orderedComponent) Stop(ctx context.Context) error {
+	*c.events = append(*c.events, "stop "+c.Name())
+	return c.mockComponent.Stop(ctx)
+}
+
+func TestGraph_Order(t *testing.T) {
+	var events []string
+	g := NewGraph()
+	// Dependencies may be added after the components that need them
+	mustAdd(t, g, "files", newOrderedComponent("files", &events), "database", "reporting")
+	mustAdd(t, g, "reporting", newOrderedComponent("reporting", &events), "database")
+	mustAdd(t, g, "scheduler", newOrderedComponent("scheduler", &events))
+	mustAdd(t, g, "database", newOrderedComponent("database", &events))
+
+	order, err := g.Order()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"database", "reporting", "files", "scheduler"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+
+	if err := g.Start(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := g.Stop(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = []string{
+		"start database", "start reporting", "start files", "start scheduler",
+		"stop scheduler", "stop files", "stop reporting", "stop database",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+
+	// Stopping again skips the components that are no longer running
+	events = nil
+	if err := g.Stop(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("stopped components were stopped again: %v", events)
+	}
+}
+
+func TestGraph_InvalidDependencies(t *testing.T) {
+	g := NewGraph()
+	mustAdd(t, g, "a", newMockComponent("a"), "b")
+	if err := g.Add("a", newMockComponent("a")); err == nil {
+		t.Error("expected an error adding a component twice")
+	}
+	if _, err := g.Order(); err == nil {
+		t.Error("expected an error for an unknown dependency")
+	}
+
+	mustAdd(t, g, "b", newMockComponent("b"), "c")
+	mustAdd(t, g, "c", newMockComponent("c"), "a")
+	if err := g.Start(context.Background()); err == nil {
+		t.Error("expected an error for a dependency cycle")
+	}
+}
+
+func TestGraph_RollsBack(t *testing.T) {
+	var events []string
+	database := newOrderedComponent("database", &events)
+	reporting := newOrderedComponent("reporting", &events)
+	files := newOrderedComponent("files", &events)
+
+	g := NewGraph()
+	mustAdd(t, g, "database", database)
+	mustAdd(t, g, "reporting", reporting, "database")
+	mustAdd(t, g, "files", files, "database", "reporting")
+
+	// A component that fails to start stops those started before it
+	startErr := errors.New("start error")
+	files.startErr = startErr
+	err := g.Start(context.Background())
+	if !errors.Is(err, startErr) {
+		t.Fatalf("err = %v, want %v", err, startErr)
+	}
+	want := []string{"start database", "start reporting", "stop reporting", "stop database"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+
+	// A component is not started while a dependency is unhealthy
+	events = nil
+	database.SetState(StateInitialized)
+	reporting.SetState(StateInitialized)
+	healthErr := errors.New("health error")
+	database.healthErr = healthErr
+	err = g.Start(context.Background())
+	if !errors.Is(err, healthErr) {
+		t.Fatalf("err = %v, want %v", err, healthErr)
+	}
+	want = []string{"start database", "stop database"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+}
+
+// mustAdd adds component to g, failing the test on error
+func mustAdd(t *testing.T, g *Graph, name string, component Component, dependsOn ...string) {
+	t.Helper()
+	if err := g.Add(name, component, dependsOn...); err != nil {
+		t.Fatalf("failed to add %s: %v", name, err)
+	}
+}
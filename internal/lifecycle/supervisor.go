@@ -0,0 +1,243 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/safego"
+)
+
+// Restarter is implemented by components that can be started again after
+// they failed or stopped on their own
+type Restarter interface {
+	Restart(ctx context.Context) error
+}
+
+// LivenessChecker is implemented by components with a check cheaper than
+// Health that tells whether they are still doing their work, such as one
+// that makes no network requests; the supervisor checks it instead of Health
+type LivenessChecker interface {
+	Live(ctx context.Context) error
+}
+
+// Defaults of the supervisor
+const (
+	// DefaultSupervisorInterval is how often components are checked when not configured
+	DefaultSupervisorInterval = time.Minute
+	// DefaultRestartBackoff is how long a failed component waits before
+	// its first restart when not configured
+	DefaultRestartBackoff = 10 * time.Second
+	// DefaultMaxRestartBackoff caps the wait between restarts when not configured
+	DefaultMaxRestartBackoff = 10 * time.Minute
+	// DefaultAlertAfter is how many failures in a row raise an alert when not configured
+	DefaultAlertAfter = 3
+)
+
+// SupervisorConfig holds the settings of a supervisor; zero values use the
+// defaults
+type SupervisorConfig struct {
+	// Interval is how often the health of the components is checked
+	Interval time.Duration
+	// Backoff is how long a failed component waits before it is restarted
+	// again, doubling after each further failure up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// AlertAfter is how many failures in a row call OnFailure
+	AlertAfter int
+	// OnFailure, if set, is called once a component has failed AlertAfter
+	// times in a row, with the latest error
+	OnFailure func(ctx context.Context, name string, failures int, err error)
+	// Logger, if set, is the logger of the supervisor
+	Logger *slog.Logger
+}
+
+// supervised is a component watched by a supervisor
+type supervised struct {
+	name      string
+	component Component
+	// failures counts the failed checks in a row, and retryAt is when the
+	// component is next checked after a restart
+	failures int
+	retryAt  time.Time
+}
+
+// Supervisor periodically checks the liveness of its components and restarts
+// those that failed, waiting longer after each failure. Only components
+// that are running or failed are checked, so components stopped on purpose
+// are left alone.
+type Supervisor struct {
+	*BaseComponent
+	config     SupervisorConfig
+	mu         sync.Mutex
+	components []*supervised
+	stopCh     chan struct{}
+	stopOnce   sync.Once
+	now        func() time.Time
+}
+
+// NewSupervisor creates a supervisor without components
+func NewSupervisor(config SupervisorConfig) *Supervisor {
+	if config.Interval <= 0 {
+		config.Interval = DefaultSupervisorInterval
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = DefaultRestartBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = DefaultMaxRestartBackoff
+	}
+	if config.AlertAfter <= 0 {
+		config.AlertAfter = DefaultAlertAfter
+	}
+	if config.Logger == nil {
+		config.Logger = logging.Component(nil, "supervisor")
+	}
+	s := &Supervisor{
+		BaseComponent: NewBaseComponent("Supervisor"),
+		config:        config,
+		stopCh:        make(chan struct{}),
+		now:           time.Now,
+	}
+	s.SetState(StateInitialized)
+	return s
+}
+
+// Register supervises component under name
+func (s *Supervisor) Register(name string, component Component) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if component == nil {
+		return fmt.Errorf("component %s is nil", name)
+	}
+	for _, c := range s.components {
+		if c.name == name {
+			return fmt.Errorf("component %s is already supervised", name)
+		}
+	}
+	s.components = append(s.components, &supervised{name: name, component: component})
+	return nil
+}
+
+// Start checks the components every interval until the supervisor stops
+func (s *Supervisor) Start(ctx context.Context) error {
+	if err := s.DefaultStart(ctx); err != nil {
+		return err
+	}
+	safego.Go(s.config.Logger, "supervisor", func() { s.run(ctx) })
+	return nil
+}
+
+// Stop stops supervising; stopping it again has no effect
+func (s *Supervisor) Stop(ctx context.Context) error {
+	if s.State() == StateStopped {
+		return nil
+	}
+	if err := s.DefaultStop(ctx); err != nil {
+		return err
+	}
+	s.stopOnce.Do(func() { close(s.stopCh) })
+	return nil
+}
+
+// Health implements Component
+func (s *Supervisor) Health(ctx context.Context) error {
+	return s.DefaultHealth(ctx)
+}
+
+// run checks the components on every tick
+func (s *Supervisor) run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.check(ctx)
+		}
+	}
+}
+
+// check checks every component that is due, restarting those that fail
+func (s *Supervisor) check(ctx context.Context) {
+	s.mu.Lock()
+	components := append([]*supervised{}, s.components...)
+	s.mu.Unlock()
+
+	for _, c := range components {
+		state := c.component.State()
+		if state != StateRunning && state != StateFailed {
+			continue
+		}
+		if s.now().Before(c.retryAt) {
+			continue
+		}
+
+		err := live(ctx, c.component)
+		if err == nil {
+			if c.failures > 0 {
+				s.config.Logger.Info("Component recovered", "component", c.name, "failures", c.failures)
+			}
+			c.failures, c.retryAt = 0, time.Time{}
+			continue
+		}
+
+		c.failures++
+		s.config.Logger.Warn("Component is unhealthy, restarting", "component", c.name, "failures", c.failures, "error", err)
+		if restartErr := restart(ctx, c.component); restartErr != nil {
+			err = fmt.Errorf("%w; restart failed: %v", err, restartErr)
+			s.config.Logger.Error("Failed to restart component", "component", c.name, "error", restartErr)
+		}
+		c.retryAt = s.now().Add(s.backoff(c.failures))
+
+		if c.failures == s.config.AlertAfter && s.config.OnFailure != nil {
+			s.config.OnFailure(ctx, c.name, c.failures, err)
+		}
+	}
+}
+
+// backoff returns how long a component waits after its failures-th failure
+func (s *Supervisor) backoff(failures int) time.Duration {
+	delay := s.config.Backoff
+	for i := 1; i < failures && delay < s.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, s.config.MaxBackoff)
+}
+
+// live checks the liveness of component, or its health when it has no
+// liveness check
+func live(ctx context.Context, component Component) error {
+	if checker, ok := component.(LivenessChecker); ok {
+		return checker.Live(ctx)
+	}
+	return component.Health(ctx)
+}
+
+// restart starts component again, stopping it first if it is still running.
+// Components that cannot restart themselves are initialized again when
+// they support it.
+func restart(ctx context.Context, component Component) error {
+	if restarter, ok := component.(Restarter); ok {
+		return restarter.Restart(ctx)
+	}
+	if component.State() == StateRunning {
+		if err := component.Stop(ctx); err != nil {
+			return fmt.Errorf("failed to stop: %w", err)
+		}
+	}
+	if initializer, ok := component.(Initializer); ok {
+		if err := initializer.Initialize(ctx); err != nil {
+			return fmt.Errorf("failed to initialize: %w", err)
+		}
+	}
+	return component.Start(ctx)
+}
@@ -0,0 +1,113 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// restartableComponent is a component whose health can be broken, counting
+// its restarts
+type restartableComponent struct {
+	*mockComponent
+	restarts int
+}
+
+func (c *restartableComponent) Restart(ctx context.Context) error {
+	c.restarts++
+	return c.restartErr()
+}
+
+// restartErr fails restarts while the component is unhealthy
+func (c *restartableComponent) restartErr() error {
+	if c.healthErr != nil {
+		return errors.New("still broken")
+	}
+	return nil
+}
+
+func TestSupervisor_RestartsWithBackoff(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var alerts []string
+	s := NewSupervisor(SupervisorConfig{
+		Backoff:    time.Minute,
+		MaxBackoff: 3 * time.Minute,
+		AlertAfter: 2,
+		OnFailure: func(ctx context.Context, name string, failures int, err error) {
+			alerts = append(alerts, name)
+		},
+	})
+	s.now = func() time.Time { return now }
+
+	component := &restartableComponent{mockComponent: newMockComponent("folder")}
+	component.SetState(StateRunning)
+	if err := s.Register("folder", component); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.Register("folder", component); err == nil {
+		t.Error("expected an error registering a component twice")
+	}
+
+	ctx := context.Background()
+	s.check(ctx)
+	if component.restarts != 0 {
+		t.Errorf("healthy component was restarted %d times", component.restarts)
+	}
+
+	// A failed component is restarted, then waits for the backoff, which
+	// doubles up to its maximum
+	component.healthErr = errors.New("poll loop has exited")
+	for i, wait := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		s.check(ctx)
+		if component.restarts != i+1 {
+			t.Fatalf("restarts = %d, want %d", component.restarts, i+1)
+		}
+		now = now.Add(wait - time.Second)
+		s.check(ctx)
+		if component.restarts != i+1 {
+			t.Fatalf("component was restarted before its backoff of %s", wait)
+		}
+		now = now.Add(time.Second)
+	}
+
+	// Only the failure that reaches the threshold raises an alert
+	if len(alerts) != 1 || alerts[0] != "folder" {
+		t.Errorf("alerts = %v, want one for folder", alerts)
+	}
+
+	// Recovering resets the backoff
+	component.healthErr = nil
+	s.check(ctx)
+	component.healthErr = errors.New("poll loop has exited")
+	s.check(ctx)
+	now = now.Add(time.Minute)
+	s.check(ctx)
+	if component.restarts != 6 {
+		t.Errorf("restarts = %d, want 6", component.restarts)
+	}
+}
+
+func TestSupervisor_SkipsStoppedComponents(t *testing.T) {
+	s := NewSupervisor(SupervisorConfig{})
+	component := newMockComponent("scheduler")
+	component.healthErr = errors.New("unhealthy")
+	if err := s.Register("scheduler", component); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Components stopped on purpose are left alone
+	component.SetState(StateStopped)
+	s.check(context.Background())
+	if component.State() != StateStopped {
+		t.Errorf("stopped component was restarted, state = %s", component.State())
+	}
+
+	// Failed components without a restart of their own are started again
+	component.SetState(StateFailed)
+	component.healthErr = nil
+	s.check(context.Background())
+	if component.State() != StateRunning {
+		t.Errorf("state = %s, want %s", component.State(), StateRunning)
+	}
+}
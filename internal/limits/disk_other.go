@@ -0,0 +1,10 @@
+//go:build !unix
+
+package limits
+
+import "errors"
+
+// freeDiskSpace is not supported on this platform
+func freeDiskSpace(path string) (int64, error) {
+	return 0, errors.New("free disk space check is not supported on this platform")
+}
@@ -0,0 +1,9 @@
+package limits
+
+import "errors"
+
+// ErrContentTooLarge is returned when content exceeds the whole memory budget
+var ErrContentTooLarge = errors.New("content exceeds memory limit")
+
+// ErrDiskSpaceLow is returned for downloads while free disk space is below the threshold
+var ErrDiskSpaceLow = errors.New("content downloads paused: free disk space is low")
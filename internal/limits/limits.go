@@ -0,0 +1,279 @@
+package limits
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+)
+
+// Default limits, sized for a small VPS
+const (
+	DefaultMaxConcurrentDownloads = 4
+	DefaultMaxContentBytes        = 100 * 1024 * 1024
+	DefaultMaxReportBytes         = 1024 * 1024
+	DefaultDiskPath               = "."
+)
+
+// truncationNotice is appended to reports cut down to the size limit
+const truncationNotice = "\n\n[report truncated: size limit reached]"
+
+// Config holds the resource limits shared by all agents
+type Config struct {
+	// MaxConcurrentDownloads caps the number of file downloads in flight
+	MaxConcurrentDownloads int
+	// MaxContentBytes caps the file content held in memory across all downloads
+	MaxContentBytes int64
+	// MaxReportBytes caps the size of a rendered report
+	MaxReportBytes int
+	// MinFreeDiskBytes pauses content downloads while free space on DiskPath
+	// is below it; zero disables the check
+	MinFreeDiskBytes int64
+	// DiskPath is the directory whose file system is checked
+	DiskPath string
+}
+
+// DefaultConfig returns the default limits
+func DefaultConfig() Config {
+	return Config{
+		MaxConcurrentDownloads: DefaultMaxConcurrentDownloads,
+		MaxContentBytes:        DefaultMaxContentBytes,
+		MaxReportBytes:         DefaultMaxReportBytes,
+		DiskPath:               DefaultDiskPath,
+	}
+}
+
+// Stats counts how often the limits throttled or rejected work
+type Stats struct {
+	DownloadsThrottled int64 `json:"downloads_throttled"`
+	ContentThrottled   int64 `json:"content_throttled"`
+	ContentRejected    int64 `json:"content_rejected"`
+	ReportsTruncated   int64 `json:"reports_truncated"`
+	ActiveDownloads    int64 `json:"active_downloads"`
+	ContentBytesInUse  int64 `json:"content_bytes_in_use"`
+	DownloadsPaused    int64 `json:"downloads_paused"`
+	DiskLow            bool  `json:"disk_low"`
+	DiskFreeBytes      int64 `json:"disk_free_bytes"`
+}
+
+// Guard enforces a Config. A nil Guard imposes no limits.
+type Guard struct {
+	config    Config
+	downloads chan struct{}
+
+	mu       sync.Mutex
+	used     int64
+	released chan struct{}
+
+	downloadsThrottled int64
+	contentThrottled   int64
+	contentRejected    int64
+	reportsTruncated   int64
+	downloadsPaused    int64
+
+	diskLow   int32
+	diskFree  int64
+	freeSpace func(path string) (int64, error)
+	logger    *slog.Logger
+}
+
+// NewGuard creates a guard for the given limits; zero values fall back to the defaults
+func NewGuard(cfg Config) (*Guard, error) {
+	if cfg.MaxConcurrentDownloads < 0 || cfg.MaxContentBytes < 0 || cfg.MaxReportBytes < 0 || cfg.MinFreeDiskBytes < 0 {
+		return nil, fmt.Errorf("limits cannot be negative")
+	}
+
+	defaults := DefaultConfig()
+	if cfg.MaxConcurrentDownloads == 0 {
+		cfg.MaxConcurrentDownloads = defaults.MaxConcurrentDownloads
+	}
+	if cfg.MaxContentBytes == 0 {
+		cfg.MaxContentBytes = defaults.MaxContentBytes
+	}
+	if cfg.MaxReportBytes == 0 {
+		cfg.MaxReportBytes = defaults.MaxReportBytes
+	}
+	if cfg.DiskPath == "" {
+		cfg.DiskPath = defaults.DiskPath
+	}
+
+	return &Guard{
+		config:    cfg,
+		downloads: make(chan struct{}, cfg.MaxConcurrentDownloads),
+		released:  make(chan struct{}),
+		freeSpace: freeDiskSpace,
+		logger:    logging.Component(nil, "limits"),
+	}, nil
+}
+
+// Config returns the effective limits
+func (g *Guard) Config() Config {
+	if g == nil {
+		return Config{}
+	}
+	return g.config
+}
+
+// AcquireDownload waits for a download slot; the returned func releases it.
+// While free disk space is low it fails with ErrDiskSpaceLow.
+func (g *Guard) AcquireDownload(ctx context.Context) (func(), error) {
+	if g == nil {
+		return func() {}, nil
+	}
+	if atomic.LoadInt32(&g.diskLow) == 1 {
+		atomic.AddInt64(&g.downloadsPaused, 1)
+		return nil, fmt.Errorf("%w: %d bytes free, need %d", ErrDiskSpaceLow, atomic.LoadInt64(&g.diskFree), g.config.MinFreeDiskBytes)
+	}
+
+	select {
+	case g.downloads <- struct{}{}:
+	default:
+		atomic.AddInt64(&g.downloadsThrottled, 1)
+		g.logger.Info("Download throttled", "in_flight", g.config.MaxConcurrentDownloads)
+		select {
+		case g.downloads <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	var once sync.Once
+	return func() { once.Do(func() { <-g.downloads }) }, nil
+}
+
+// ReserveContent waits until n bytes of the content budget are free and
+// reserves them; the returned func releases the reservation. Requests larger
+// than the whole budget fail with ErrContentTooLarge.
+func (g *Guard) ReserveContent(ctx context.Context, n int64) (func(), error) {
+	if g == nil {
+		return func() {}, nil
+	}
+	if n > g.config.MaxContentBytes {
+		atomic.AddInt64(&g.contentRejected, 1)
+		return nil, fmt.Errorf("%w: %d bytes requested, limit is %d", ErrContentTooLarge, n, g.config.MaxContentBytes)
+	}
+
+	throttled := false
+	for {
+		g.mu.Lock()
+		if g.used+n <= g.config.MaxContentBytes {
+			g.used += n
+			g.mu.Unlock()
+			break
+		}
+		released := g.released
+		g.mu.Unlock()
+
+		if !throttled {
+			throttled = true
+			atomic.AddInt64(&g.contentThrottled, 1)
+			g.logger.Info("Content download throttled, waiting for memory budget", "bytes", n)
+		}
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	var once sync.Once
+	return func() { once.Do(func() { g.release(n) }) }, nil
+}
+
+// release returns n bytes to the content budget and wakes any waiters
+func (g *Guard) release(n int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.used -= n
+	close(g.released)
+	g.released = make(chan struct{})
+}
+
+// LimitReport truncates report content to the report size limit, reporting
+// whether it was cut
+func (g *Guard) LimitReport(content string) (string, bool) {
+	if g == nil || len(content) <= g.config.MaxReportBytes {
+		return content, false
+	}
+
+	atomic.AddInt64(&g.reportsTruncated, 1)
+	g.logger.Warn("Report truncated", "bytes", len(content), "limit", g.config.MaxReportBytes)
+
+	cut := g.config.MaxReportBytes - len(truncationNotice)
+	if cut < 0 {
+		cut = 0
+	}
+	return content[:cut] + truncationNotice, true
+}
+
+// Stats returns a snapshot of the guard's metrics
+func (g *Guard) Stats() Stats {
+	if g == nil {
+		return Stats{}
+	}
+
+	g.mu.Lock()
+	used := g.used
+	g.mu.Unlock()
+
+	return Stats{
+		DownloadsThrottled: atomic.LoadInt64(&g.downloadsThrottled),
+		ContentThrottled:   atomic.LoadInt64(&g.contentThrottled),
+		ContentRejected:    atomic.LoadInt64(&g.contentRejected),
+		ReportsTruncated:   atomic.LoadInt64(&g.reportsTruncated),
+		ActiveDownloads:    int64(len(g.downloads)),
+		ContentBytesInUse:  used,
+		DownloadsPaused:    atomic.LoadInt64(&g.downloadsPaused),
+		DiskLow:            atomic.LoadInt32(&g.diskLow) == 1,
+		DiskFreeBytes:      atomic.LoadInt64(&g.diskFree),
+	}
+}
+
+// DiskStatus is the result of a disk space check
+type DiskStatus struct {
+	Path      string
+	FreeBytes int64
+	MinBytes  int64
+	Low       bool
+	// Changed is set when the check paused or resumed downloads
+	Changed bool
+}
+
+// CheckDisk measures free space on the configured path, pausing content
+// downloads while it is below the threshold and resuming them once it
+// recovers. Without a threshold it does nothing.
+func (g *Guard) CheckDisk() (DiskStatus, error) {
+	if g == nil || g.config.MinFreeDiskBytes == 0 {
+		return DiskStatus{}, nil
+	}
+
+	free, err := g.freeSpace(g.config.DiskPath)
+	if err != nil {
+		return DiskStatus{}, fmt.Errorf("failed to check free disk space: %w", err)
+	}
+	atomic.StoreInt64(&g.diskFree, free)
+
+	status := DiskStatus{
+		Path:      g.config.DiskPath,
+		FreeBytes: free,
+		MinBytes:  g.config.MinFreeDiskBytes,
+		Low:       free < g.config.MinFreeDiskBytes,
+	}
+	var low int32
+	if status.Low {
+		low = 1
+	}
+	status.Changed = atomic.SwapInt32(&g.diskLow, low) != low
+	if status.Changed {
+		if status.Low {
+			g.logger.Warn("Free disk space low, pausing content downloads", "path", status.Path, "free", free, "min", status.MinBytes)
+		} else {
+			g.logger.Info("Free disk space recovered, resuming content downloads", "path", status.Path, "free", free)
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,159 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGuard(t *testing.T) {
+	guard, err := NewGuard(Config{})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultConfig(), guard.Config())
+
+	_, err = NewGuard(Config{MaxReportBytes: -1})
+	assert.Error(t, err)
+}
+
+func TestGuard_AcquireDownload(t *testing.T) {
+	guard, err := NewGuard(Config{MaxConcurrentDownloads: 1})
+	require.NoError(t, err)
+
+	release, err := guard.AcquireDownload(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), guard.Stats().ActiveDownloads)
+
+	// A second download waits until the first is released
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = guard.AcquireDownload(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int64(1), guard.Stats().DownloadsThrottled)
+
+	release()
+	release() // releasing twice is harmless
+
+	release, err = guard.AcquireDownload(context.Background())
+	require.NoError(t, err)
+	release()
+	assert.Equal(t, int64(0), guard.Stats().ActiveDownloads)
+}
+
+func TestGuard_ReserveContent(t *testing.T) {
+	guard, err := NewGuard(Config{MaxContentBytes: 100})
+	require.NoError(t, err)
+
+	_, err = guard.ReserveContent(context.Background(), 101)
+	assert.True(t, errors.Is(err, ErrContentTooLarge))
+	assert.Equal(t, int64(1), guard.Stats().ContentRejected)
+
+	release, err := guard.ReserveContent(context.Background(), 60)
+	require.NoError(t, err)
+	assert.Equal(t, int64(60), guard.Stats().ContentBytesInUse)
+
+	// The next reservation blocks until enough of the budget is released
+	done := make(chan error, 1)
+	go func() {
+		releaseNext, err := guard.ReserveContent(context.Background(), 60)
+		if err == nil {
+			releaseNext()
+		}
+		done <- err
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("reservation should wait for the budget")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	release()
+	require.NoError(t, <-done)
+	assert.Equal(t, int64(1), guard.Stats().ContentThrottled)
+	assert.Equal(t, int64(0), guard.Stats().ContentBytesInUse)
+}
+
+func TestGuard_LimitReport(t *testing.T) {
+	guard, err := NewGuard(Config{MaxReportBytes: 100})
+	require.NoError(t, err)
+
+	content, truncated := guard.LimitReport("short")
+	assert.False(t, truncated)
+	assert.Equal(t, "short", content)
+
+	content, truncated = guard.LimitReport(strings.Repeat("x", 500))
+	assert.True(t, truncated)
+	assert.Len(t, content, 100)
+	assert.True(t, strings.HasSuffix(content, truncationNotice))
+	assert.Equal(t, int64(1), guard.Stats().ReportsTruncated)
+}
+
+func TestGuard_CheckDisk(t *testing.T) {
+	guard, err := NewGuard(Config{MinFreeDiskBytes: 1000, DiskPath: "/data"})
+	require.NoError(t, err)
+	free := int64(5000)
+	guard.freeSpace = func(path string) (int64, error) {
+		assert.Equal(t, "/data", path)
+		return free, nil
+	}
+
+	status, err := guard.CheckDisk()
+	require.NoError(t, err)
+	assert.False(t, status.Low)
+	assert.False(t, status.Changed)
+
+	free = 500
+	status, err = guard.CheckDisk()
+	require.NoError(t, err)
+	assert.True(t, status.Low)
+	assert.True(t, status.Changed)
+
+	_, err = guard.AcquireDownload(context.Background())
+	assert.ErrorIs(t, err, ErrDiskSpaceLow)
+	assert.Equal(t, int64(1), guard.Stats().DownloadsPaused)
+	assert.True(t, guard.Stats().DiskLow)
+
+	status, err = guard.CheckDisk()
+	require.NoError(t, err)
+	assert.False(t, status.Changed, "still low")
+
+	free = 2000
+	status, err = guard.CheckDisk()
+	require.NoError(t, err)
+	assert.False(t, status.Low)
+	assert.True(t, status.Changed)
+
+	release, err := guard.AcquireDownload(context.Background())
+	require.NoError(t, err)
+	release()
+
+	guard.freeSpace = func(string) (int64, error) { return 0, errors.New("statfs failed") }
+	_, err = guard.CheckDisk()
+	assert.Error(t, err)
+}
+
+func TestGuard_Nil(t *testing.T) {
+	var guard *Guard
+
+	release, err := guard.AcquireDownload(context.Background())
+	require.NoError(t, err)
+	release()
+
+	release, err = guard.ReserveContent(context.Background(), 1<<40)
+	require.NoError(t, err)
+	release()
+
+	content, truncated := guard.LimitReport("anything")
+	assert.False(t, truncated)
+	assert.Equal(t, "anything", content)
+	assert.Equal(t, Stats{}, guard.Stats())
+
+	status, err := guard.CheckDisk()
+	require.NoError(t, err)
+	assert.Equal(t, DiskStatus{}, status)
+}
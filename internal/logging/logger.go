@@ -0,0 +1,104 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/redact"
+)
+
+// Log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Redacted replaces secrets in log output
+const Redacted = redact.Mask
+
+// LoggerOptions configures a structured logger
+type LoggerOptions struct {
+	// Level is the least severe level logged
+	Level slog.Level
+	// Format is text (the default) or json
+	Format string
+	// Secrets are values, such as access tokens, removed from every message
+	// and attribute
+	Secrets []string
+}
+
+// ParseLevel parses a level name: debug, info (the default for ""), warn or
+// error
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if name == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q", name)
+	}
+	return level, nil
+}
+
+// NewLogger creates a logger writing to w in the configured format, with
+// secrets redacted. Attributes whose keys name a credential, such as token
+// or password, are redacted whatever their value.
+func NewLogger(w io.Writer, opts LoggerOptions) *slog.Logger {
+	handlerOpts := &slog.HandlerOptions{
+		Level:       opts.Level,
+		ReplaceAttr: replaceAttr(redact.New(opts.Secrets...)),
+	}
+	if opts.Format == FormatJSON {
+		return slog.New(slog.NewJSONHandler(w, handlerOpts))
+	}
+	return slog.New(slog.NewTextHandler(w, handlerOpts))
+}
+
+// Configure makes a logger writing to stderr, and to the rotated file of
+// fileOpts when it has a path, the default for slog and the log package. The
+// secrets are also registered for redaction of formatted errors. The
+// returned closer closes the file.
+func Configure(fileOpts Options, opts LoggerOptions) (io.Closer, error) {
+	redact.Register(opts.Secrets...)
+	var w io.Writer = os.Stderr
+	var closer io.Closer = io.NopCloser(nil)
+	if fileOpts.Path != "" {
+		file, err := NewRotatingFile(fileOpts)
+		if err != nil {
+			return nil, err
+		}
+		w, closer = io.MultiWriter(os.Stderr, file), file
+	}
+	slog.SetDefault(NewLogger(w, opts))
+	return closer, nil
+}
+
+// Component returns logger, or the default logger when it is nil, with a
+// component field naming the part of the monitor that logs
+func Component(logger *slog.Logger, name string) *slog.Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return logger.With("component", name)
+}
+
+// replaceAttr returns a slog.HandlerOptions.ReplaceAttr that masks the
+// secrets of r, including those in the message and in errors
+func replaceAttr(r *redact.Redactor) func([]string, slog.Attr) slog.Attr {
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key != slog.MessageKey && redact.SensitiveKey(a.Key) {
+			return slog.String(a.Key, Redacted)
+		}
+		switch a.Value.Kind() {
+		case slog.KindString:
+			a.Value = slog.StringValue(r.String(a.Value.String()))
+		case slog.KindAny:
+			if err, ok := a.Value.Any().(error); ok {
+				a.Value = slog.StringValue(r.String(err.Error()))
+			}
+		}
+		return a
+	}
+}
@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLogger_RedactsSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(&buf, LoggerOptions{Format: FormatJSON, Secrets: []string{"sl.abcdef123456", "short"}})
+
+	logger.Info("Connecting with sl.abcdef123456",
+		"access_token", "anything",
+		"header", "Authorization: Bearer xyz.789",
+		"error", errors.New("request with sl.abcdef123456 failed"),
+		"word", "short")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "Connecting with "+Redacted, entry["msg"])
+	assert.Equal(t, Redacted, entry["access_token"])
+	assert.Equal(t, "Authorization: Bearer "+Redacted, entry["header"])
+	assert.Equal(t, "request with "+Redacted+" failed", entry["error"])
+	assert.Equal(t, "short", entry["word"], "short secrets are not redacted")
+	assert.NotContains(t, buf.String(), "sl.abcdef123456")
+}
+
+func TestNewLogger_LevelAndFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Component(NewLogger(&buf, LoggerOptions{Level: slog.LevelWarn}), "scheduler")
+
+	logger.Info("Not logged")
+	logger.Warn("Task is slow", "task", "retention")
+
+	out := buf.String()
+	assert.NotContains(t, out, "Not logged")
+	assert.True(t, strings.Contains(out, `level=WARN msg="Task is slow" component=scheduler task=retention`), out)
+}
+
+func TestParseLevel(t *testing.T) {
+	for name, want := range map[string]slog.Level{
+		"":      slog.LevelInfo,
+		"debug": slog.LevelDebug,
+		"info":  slog.LevelInfo,
+		"WARN":  slog.LevelWarn,
+		"error": slog.LevelError,
+	} {
+		level, err := ParseLevel(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, level, name)
+	}
+
+	_, err := ParseLevel("verbose")
+	assert.Error(t, err)
+}
@@ -0,0 +1,277 @@
+// Package logging configures the structured logger and writes logs to files
+// that are rotated by size and age.
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default rotation settings
+const (
+	DefaultMaxSize    = 100 * 1024 * 1024
+	DefaultMaxBackups = 7
+)
+
+// backupTimeFormat names rotated files, e.g. monitor-20250212T090000.log
+const backupTimeFormat = "20060102T150405"
+
+// Options configures a RotatingFile
+type Options struct {
+	// Path is the active log file
+	Path string
+	// MaxSize rotates the file before it grows beyond this many bytes
+	MaxSize int64
+	// MaxAge rotates the file once it is this old; zero disables time-based rotation
+	MaxAge time.Duration
+	// MaxBackups is how many rotated files are kept
+	MaxBackups int
+	// Compress gzips rotated files
+	Compress bool
+}
+
+// RotatingFile is an io.WriteCloser that rotates its file when it reaches
+// the size or age limit. Rotated files are renamed with a timestamp, optionally
+// gzipped, and pruned to the newest MaxBackups.
+type RotatingFile struct {
+	opts Options
+	now  func() time.Time
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	opened  time.Time
+	pending sync.WaitGroup
+	// cleanup serialises compression and pruning of backups
+	cleanup sync.Mutex
+}
+
+// NewRotatingFile opens or creates the log file; zero options use the defaults
+func NewRotatingFile(opts Options) (*RotatingFile, error) {
+	if opts.Path == "" {
+		return nil, fmt.Errorf("log file path is required")
+	}
+	if opts.MaxSize < 0 || opts.MaxAge < 0 || opts.MaxBackups < 0 {
+		return nil, fmt.Errorf("log rotation limits cannot be negative")
+	}
+	if opts.MaxSize == 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	if opts.MaxBackups == 0 {
+		opts.MaxBackups = DefaultMaxBackups
+	}
+
+	r := &RotatingFile{opts: opts, now: time.Now}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write appends p to the log file, rotating first if p would exceed the size
+// limit or the file has reached its maximum age
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.shouldRotate(int64(len(p))) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates the log file immediately
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rotate()
+}
+
+// Close closes the log file and waits for pending compression
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	r.mu.Unlock()
+
+	r.pending.Wait()
+	return err
+}
+
+// shouldRotate reports whether writing n more bytes requires a rotation;
+// a single write larger than the limit goes to a fresh file
+func (r *RotatingFile) shouldRotate(n int64) bool {
+	if r.size > 0 && r.size+n > r.opts.MaxSize {
+		return true
+	}
+	return r.opts.MaxAge > 0 && r.now().Sub(r.opened) >= r.opts.MaxAge
+}
+
+// open opens the log file for appending; r.mu must be held
+func (r *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.opts.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	file, err := os.OpenFile(r.opts.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	r.file = file
+	r.size = info.Size()
+	r.opened = r.now()
+	if info.Size() > 0 {
+		// Age an existing file from its last write so restarts don't postpone rotation forever
+		r.opened = info.ModTime()
+	}
+	return nil
+}
+
+// rotate renames the current file to a timestamped backup and opens a new
+// one; r.mu must be held
+func (r *RotatingFile) rotate() error {
+	if r.file != nil {
+		if err := r.file.Close(); err != nil {
+			return fmt.Errorf("failed to close log file: %w", err)
+		}
+		r.file = nil
+	}
+
+	backup := r.backupName(r.now())
+	if err := os.Rename(r.opts.Path, backup); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	if err := r.open(); err != nil {
+		return err
+	}
+	// A fresh file is always young, whatever the old file's age
+	r.opened = r.now()
+
+	r.pending.Add(1)
+	go func() {
+		defer r.pending.Done()
+		r.cleanup.Lock()
+		defer r.cleanup.Unlock()
+		if r.opts.Compress {
+			if err := compress(backup); err != nil {
+				Component(nil, "logging").Error("Failed to compress rotated log", "path", backup, "error", err)
+			}
+		}
+		if err := r.prune(); err != nil {
+			Component(nil, "logging").Error("Failed to prune rotated logs", "error", err)
+		}
+	}()
+	return nil
+}
+
+// backupName returns a unique name for a backup rotated at t
+func (r *RotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(r.opts.Path)
+	base := strings.TrimSuffix(r.opts.Path, ext)
+	name := fmt.Sprintf("%s-%s%s", base, t.Format(backupTimeFormat), ext)
+	for i := 1; exists(name) || exists(name+".gz"); i++ {
+		name = fmt.Sprintf("%s-%s.%d%s", base, t.Format(backupTimeFormat), i, ext)
+	}
+	return name
+}
+
+// backups returns rotated files, oldest first
+func (r *RotatingFile) backups() ([]string, error) {
+	ext := filepath.Ext(r.opts.Path)
+	prefix := filepath.Base(strings.TrimSuffix(r.opts.Path, ext)) + "-"
+
+	entries, err := os.ReadDir(filepath.Dir(r.opts.Path))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if strings.HasSuffix(name, ext) || strings.HasSuffix(name, ext+".gz") {
+			names = append(names, filepath.Join(filepath.Dir(r.opts.Path), name))
+		}
+	}
+	// The timestamp format sorts chronologically
+	sort.Strings(names)
+	return names, nil
+}
+
+// prune removes all but the newest MaxBackups rotated files
+func (r *RotatingFile) prune() error {
+	backups, err := r.backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > r.opts.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// compress gzips path to path.gz and removes the original
+func compress(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		gz.Close()
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// exists reports whether a file exists at path
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
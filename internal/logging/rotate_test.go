@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile_RotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "monitor.log")
+	now := time.Date(2025, 2, 12, 9, 0, 0, 0, time.UTC)
+
+	r, err := NewRotatingFile(Options{Path: path, MaxSize: 10, MaxBackups: 2})
+	require.NoError(t, err)
+	r.now = func() time.Time { return now }
+
+	for i := 0; i < 4; i++ {
+		_, err := r.Write([]byte("12345678\n"))
+		require.NoError(t, err)
+		now = now.Add(time.Second)
+	}
+	require.NoError(t, r.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "12345678\n", string(data))
+
+	backups, err := r.backups()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "monitor-20250212T090002.log"),
+		filepath.Join(dir, "monitor-20250212T090003.log"),
+	}, backups, "only the newest backups are kept")
+}
+
+func TestRotatingFile_RotatesByAgeAndCompresses(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "monitor.log")
+	now := time.Date(2025, 2, 12, 9, 0, 0, 0, time.UTC)
+
+	r, err := NewRotatingFile(Options{Path: path, MaxAge: time.Hour, Compress: true})
+	require.NoError(t, err)
+	r.now = func() time.Time { return now }
+	r.opened = now
+
+	_, err = r.Write([]byte("old\n"))
+	require.NoError(t, err)
+	now = now.Add(time.Hour)
+	_, err = r.Write([]byte("new\n"))
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	f, err := os.Open(filepath.Join(dir, "monitor-20250212T100000.log.gz"))
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	old, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, "old\n", string(old))
+
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new\n", string(current))
+	assert.NoFileExists(t, filepath.Join(dir, "monitor-20250212T100000.log"))
+}
+
+func TestNewRotatingFile_Validation(t *testing.T) {
+	_, err := NewRotatingFile(Options{})
+	assert.Error(t, err)
+	_, err = NewRotatingFile(Options{Path: filepath.Join(t.TempDir(), "a.log"), MaxSize: -1})
+	assert.Error(t, err)
+}
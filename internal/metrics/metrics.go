@@ -0,0 +1,370 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are histogram buckets suited to latencies in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is the registry exported by the web server's /metrics endpoint
+var Default = NewRegistry()
+
+// metric is a registered metric family
+type metric interface {
+	kind() string
+	help() string
+	write(buf *bytes.Buffer, name string)
+	// collect adds the metric's samples to into, zeroing them if reset is set
+	collect(into map[string]float64, name string, reset bool)
+}
+
+// Registry holds metrics and writes them in the Prometheus text format
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+// register returns the metric already registered under name, or registers the
+// one built by create. Registering a name twice with different types panics.
+func (r *Registry) register(name, kind string, create func() metric) metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.metrics[name]; ok {
+		if existing.kind() != kind {
+			panic(fmt.Sprintf("metric %s already registered as a %s", name, existing.kind()))
+		}
+		return existing
+	}
+
+	m := create()
+	r.metrics[name] = m
+	return m
+}
+
+// Counter returns the counter registered under name, creating it if needed
+func (r *Registry) Counter(name, help string) *Counter {
+	return r.register(name, "counter", func() metric { return &Counter{helpText: help} }).(*Counter)
+}
+
+// CounterVec returns the labelled counter registered under name, creating it if needed
+func (r *Registry) CounterVec(name, help, label string) *CounterVec {
+	return r.register(name, "counter", func() metric {
+		return &CounterVec{helpText: help, label: label, counters: make(map[string]*Counter)}
+	}).(*CounterVec)
+}
+
+// Gauge returns the gauge registered under name, creating it if needed
+func (r *Registry) Gauge(name, help string) *Gauge {
+	return r.register(name, "gauge", func() metric { return &Gauge{helpText: help} }).(*Gauge)
+}
+
+// Histogram returns the histogram registered under name, creating it with the
+// given upper bounds if needed; nil buckets use DefaultBuckets
+func (r *Registry) Histogram(name, help string, buckets []float64) *Histogram {
+	return r.register(name, "histogram", func() metric {
+		if buckets == nil {
+			buckets = DefaultBuckets
+		}
+		bounds := append([]float64(nil), buckets...)
+		sort.Float64s(bounds)
+		return &Histogram{helpText: help, bounds: bounds, counts: make([]uint64, len(bounds))}
+	}).(*Histogram)
+}
+
+// WriteTo writes every metric, sorted by name, in the Prometheus text format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	r.mu.Unlock()
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		r.mu.Lock()
+		m := r.metrics[name]
+		r.mu.Unlock()
+
+		fmt.Fprintf(&buf, "# HELP %s %s\n", name, escapeHelp(m.help()))
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", name, m.kind())
+		m.write(&buf, name)
+	}
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// Snapshot returns the current value of every sample, keyed by series name
+// as written in the Prometheus text format, e.g. `errors_total{type="auth"}`
+// or `latency_seconds_count`
+func (r *Registry) Snapshot() map[string]float64 {
+	return r.collect(false)
+}
+
+// Reset zeroes every counter and histogram and returns the samples as they
+// were just before, so periodic exporters can compute rates without missing
+// updates made between reading and resetting. Gauges describe current state
+// and keep their values.
+func (r *Registry) Reset() map[string]float64 {
+	return r.collect(true)
+}
+
+// collect gathers the samples of every metric, resetting them if asked
+func (r *Registry) collect(reset bool) map[string]float64 {
+	r.mu.Lock()
+	metrics := make(map[string]metric, len(r.metrics))
+	for name, m := range r.metrics {
+		metrics[name] = m
+	}
+	r.mu.Unlock()
+
+	samples := make(map[string]float64)
+	for name, m := range metrics {
+		m.collect(samples, name, reset)
+	}
+	return samples
+}
+
+// Handler returns an HTTP handler serving the registry
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if _, err := r.WriteTo(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	helpText string
+	mu       sync.Mutex
+	value    float64
+}
+
+// Inc adds one to the counter
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds a non-negative amount to the counter
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		return
+	}
+	c.mu.Lock()
+	c.value += v
+	c.mu.Unlock()
+}
+
+// Value returns the current count
+func (c *Counter) Value() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.value
+}
+
+func (c *Counter) kind() string { return "counter" }
+func (c *Counter) help() string { return c.helpText }
+
+func (c *Counter) write(buf *bytes.Buffer, name string) {
+	fmt.Fprintf(buf, "%s %s\n", name, formatFloat(c.Value()))
+}
+
+func (c *Counter) collect(into map[string]float64, name string, reset bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	into[name] = c.value
+	if reset {
+		c.value = 0
+	}
+}
+
+// CounterVec is a set of counters partitioned by one label
+type CounterVec struct {
+	helpText string
+	label    string
+	mu       sync.Mutex
+	counters map[string]*Counter
+}
+
+// With returns the counter for a label value
+func (v *CounterVec) With(value string) *Counter {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	c, ok := v.counters[value]
+	if !ok {
+		c = &Counter{}
+		v.counters[value] = c
+	}
+	return c
+}
+
+func (v *CounterVec) kind() string { return "counter" }
+func (v *CounterVec) help() string { return v.helpText }
+
+func (v *CounterVec) write(buf *bytes.Buffer, name string) {
+	v.mu.Lock()
+	values := make([]string, 0, len(v.counters))
+	for value := range v.counters {
+		values = append(values, value)
+	}
+	v.mu.Unlock()
+	sort.Strings(values)
+
+	for _, value := range values {
+		fmt.Fprintf(buf, "%s{%s=\"%s\"} %s\n", name, v.label, escapeLabel(value), formatFloat(v.With(value).Value()))
+	}
+}
+
+// collect keeps the counters registered so callers holding one from With
+// continue to update the exported series after a reset
+func (v *CounterVec) collect(into map[string]float64, name string, reset bool) {
+	v.mu.Lock()
+	counters := make(map[string]*Counter, len(v.counters))
+	for value, c := range v.counters {
+		counters[value] = c
+	}
+	v.mu.Unlock()
+
+	for value, c := range counters {
+		c.collect(into, fmt.Sprintf("%s{%s=\"%s\"}", name, v.label, escapeLabel(value)), reset)
+	}
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	helpText string
+	mu       sync.Mutex
+	value    float64
+}
+
+// Set sets the gauge
+func (g *Gauge) Set(v float64) {
+	g.mu.Lock()
+	g.value = v
+	g.mu.Unlock()
+}
+
+// Value returns the current value
+func (g *Gauge) Value() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.value
+}
+
+func (g *Gauge) kind() string { return "gauge" }
+func (g *Gauge) help() string { return g.helpText }
+
+func (g *Gauge) write(buf *bytes.Buffer, name string) {
+	fmt.Fprintf(buf, "%s %s\n", name, formatFloat(g.Value()))
+}
+
+func (g *Gauge) collect(into map[string]float64, name string, reset bool) {
+	into[name] = g.Value()
+}
+
+// Histogram counts observations in cumulative buckets
+type Histogram struct {
+	helpText string
+	bounds   []float64
+	mu       sync.Mutex
+	counts   []uint64
+	count    uint64
+	sum      float64
+}
+
+// Observe records a value
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// Count returns the number of observations
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+func (h *Histogram) kind() string { return "histogram" }
+func (h *Histogram) help() string { return h.helpText }
+
+func (h *Histogram) write(buf *bytes.Buffer, name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, bound := range h.bounds {
+		fmt.Fprintf(buf, "%s_bucket{le=\"%s\"} %d\n", name, formatFloat(bound), h.counts[i])
+	}
+	fmt.Fprintf(buf, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(buf, "%s_sum %s\n", name, formatFloat(h.sum))
+	fmt.Fprintf(buf, "%s_count %d\n", name, h.count)
+}
+
+func (h *Histogram) collect(into map[string]float64, name string, reset bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, bound := range h.bounds {
+		into[fmt.Sprintf("%s_bucket{le=\"%s\"}", name, formatFloat(bound))] = float64(h.counts[i])
+	}
+	into[name+`_bucket{le="+Inf"}`] = float64(h.count)
+	into[name+"_sum"] = h.sum
+	into[name+"_count"] = float64(h.count)
+
+	if reset {
+		for i := range h.counts {
+			h.counts[i] = 0
+		}
+		h.count = 0
+		h.sum = 0
+	}
+}
+
+// formatFloat formats a sample value as Prometheus expects
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeHelp escapes backslashes and newlines in help text
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+// escapeLabel escapes backslashes, quotes and newlines in label values
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("requests_total", "Requests made.").Add(3)
+	r.CounterVec("emails_total", "Emails sent.", "outcome").With("failure").Inc()
+	r.Gauge("breaker_state", "Breaker state.").Set(2)
+
+	h := r.Histogram("latency_seconds", "Latency.", []float64{1, 0.1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(5)
+
+	var out strings.Builder
+	_, err := r.WriteTo(&out)
+	require.NoError(t, err)
+
+	assert.Equal(t, `# HELP breaker_state Breaker state.
+# TYPE breaker_state gauge
+breaker_state 2
+# HELP emails_total Emails sent.
+# TYPE emails_total counter
+emails_total{outcome="failure"} 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 5.55
+latency_seconds_count 3
+# HELP requests_total Requests made.
+# TYPE requests_total counter
+requests_total 3
+`, out.String())
+}
+
+func TestRegistry_ReusesMetrics(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("total", "").Inc()
+	r.Counter("total", "").Inc()
+	assert.Equal(t, float64(2), r.Counter("total", "").Value())
+
+	assert.Panics(t, func() { r.Gauge("total", "") })
+}
+
+func TestRegistry_SnapshotAndReset(t *testing.T) {
+	r := NewRegistry()
+	errors := r.CounterVec("errors_total", "", "type")
+	errors.With("auth").Add(2)
+	r.Counter("requests_total", "").Add(5)
+	r.Gauge("breaker_state", "").Set(2)
+	r.Histogram("latency_seconds", "", []float64{1}).Observe(0.5)
+
+	expected := map[string]float64{
+		`errors_total{type="auth"}`:         2,
+		"requests_total":                    5,
+		"breaker_state":                     2,
+		`latency_seconds_bucket{le="1"}`:    1,
+		`latency_seconds_bucket{le="+Inf"}`: 1,
+		"latency_seconds_sum":               0.5,
+		"latency_seconds_count":             1,
+	}
+	assert.Equal(t, expected, r.Snapshot())
+	assert.Equal(t, expected, r.Reset())
+
+	after := r.Snapshot()
+	assert.Zero(t, after["requests_total"])
+	assert.Zero(t, after[`errors_total{type="auth"}`])
+	assert.Zero(t, after["latency_seconds_count"])
+	assert.Equal(t, float64(2), after["breaker_state"], "gauges keep their value")
+
+	// Counters obtained before the reset keep feeding the exported series
+	errors.With("auth").Inc()
+	assert.Equal(t, float64(1), r.Snapshot()[`errors_total{type="auth"}`])
+}
+
+func TestRegistry_ConcurrentReset(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("total", "")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var total float64
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Inc()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			value := r.Reset()["total"]
+			mu.Lock()
+			total += value
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, float64(800), total+c.Value())
+}
+
+func TestCounter_IgnoresNegative(t *testing.T) {
+	c := NewRegistry().Counter("total", "")
+	c.Add(-1)
+	assert.Equal(t, float64(0), c.Value())
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("total", "").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "version=0.0.4")
+	assert.Contains(t, rec.Body.String(), "total 1")
+}
@@ -0,0 +1,4 @@
+// Package mocks holds the testify mocks of the contracts in the interfaces
+// package, shared by the tests of every package that depends on them. They
+// follow mockery's layout: one file per interface and a type named after it.
+package mocks
@@ -0,0 +1,95 @@
+package mocks
+
+import (
+	"context"
+	"io"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/interfaces"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// DropboxClient is a mock implementation of interfaces.DropboxClient
+type DropboxClient struct {
+	mock.Mock
+}
+
+var _ interfaces.DropboxClient = (*DropboxClient)(nil)
+
+// ListFolder mocks the ListFolder method
+func (m *DropboxClient) ListFolder(ctx context.Context, path string) ([]*models.FileMetadata, error) {
+	args := m.Called(ctx, path)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.FileMetadata), args.Error(1)
+}
+
+// GetFileContent mocks the GetFileContent method
+func (m *DropboxClient) GetFileContent(ctx context.Context, path string) ([]byte, error) {
+	args := m.Called(ctx, path)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+// GetFileContentStream mocks the GetFileContentStream method
+func (m *DropboxClient) GetFileContentStream(ctx context.Context, path string) (io.ReadCloser, error) {
+	args := m.Called(ctx, path)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+// GetChangesLast24Hours mocks the GetChangesLast24Hours method
+func (m *DropboxClient) GetChangesLast24Hours(ctx context.Context) ([]*models.FileMetadata, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.FileMetadata), args.Error(1)
+}
+
+// GetChangesLast10Minutes mocks the GetChangesLast10Minutes method
+func (m *DropboxClient) GetChangesLast10Minutes(ctx context.Context) ([]*models.FileMetadata, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.FileMetadata), args.Error(1)
+}
+
+// GetChanges mocks the GetChanges method
+func (m *DropboxClient) GetChanges(ctx context.Context) ([]*models.FileMetadata, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.FileMetadata), args.Error(1)
+}
+
+// GetFileChanges mocks the GetFileChanges method
+func (m *DropboxClient) GetFileChanges(ctx context.Context) ([]models.FileChange, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.FileChange), args.Error(1)
+}
+
+// GetLatestCursor mocks the GetLatestCursor method
+func (m *DropboxClient) GetLatestCursor(ctx context.Context, path string) (string, error) {
+	args := m.Called(ctx, path)
+	return args.String(0), args.Error(1)
+}
+
+// ListFolderContinue mocks the ListFolderContinue method
+func (m *DropboxClient) ListFolderContinue(ctx context.Context, cursor string) ([]*models.FileMetadata, string, error) {
+	args := m.Called(ctx, cursor)
+	if args.Get(0) == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+	return args.Get(0).([]*models.FileMetadata), args.String(1), args.Error(2)
+}
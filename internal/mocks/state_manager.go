@@ -0,0 +1,25 @@
+package mocks
+
+import (
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/interfaces"
+	"github.com/stretchr/testify/mock"
+)
+
+// StateManager is a mock implementation of interfaces.StateManager
+type StateManager struct {
+	mock.Mock
+}
+
+var _ interfaces.StateManager = (*StateManager)(nil)
+
+// GetString mocks the GetString method
+func (m *StateManager) GetString(key string) string {
+	args := m.Called(key)
+	return args.String(0)
+}
+
+// SetString mocks the SetString method
+func (m *StateManager) SetString(key, value string) error {
+	args := m.Called(key, value)
+	return args.Error(0)
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// AccountInfo describes the Dropbox account being monitored
+type AccountInfo struct {
+	ID             string    `json:"id"`
+	Name           string    `json:"name"`
+	Email          string    `json:"email"`
+	Type           string    `json:"type"`
+	UsedBytes      int64     `json:"used_bytes"`
+	AllocatedBytes int64     `json:"allocated_bytes"`
+	FetchedAt      time.Time `json:"fetched_at"`
+	// TeamSpaceNamespaceID is the root namespace of a team space, set for
+	// members of teams whose root differs from their home folder
+	TeamSpaceNamespaceID string `json:"team_space_namespace_id,omitempty"`
+}
+
+// Header formats the account for the top of a report, e.g.
+// "Jane Doe <jane@example.com> (business), 1.5 GB of 2.0 TB used"
+func (a AccountInfo) Header() string {
+	header := a.Name
+	if a.Email != "" {
+		header += fmt.Sprintf(" <%s>", a.Email)
+	}
+	if a.Type != "" {
+		header += fmt.Sprintf(" (%s)", a.Type)
+	}
+	if a.AllocatedBytes > 0 {
+		header += fmt.Sprintf(", %s of %s used", formatBytes(a.UsedBytes), formatBytes(a.AllocatedBytes))
+	}
+	return header
+}
+
+// formatBytes formats a size with a binary unit, e.g. "1.5 GB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
@@ -0,0 +1,23 @@
+package models
+
+import "testing"
+
+func TestAccountInfoHeader(t *testing.T) {
+	tests := []struct {
+		account AccountInfo
+		want    string
+	}{
+		{
+			account: AccountInfo{Name: "Jane Doe", Email: "jane@example.com", Type: "business", UsedBytes: 1536 << 20, AllocatedBytes: 2 << 40},
+			want:    "Jane Doe <jane@example.com> (business), 1.5 GB of 2.0 TB used",
+		},
+		{account: AccountInfo{Name: "Jane Doe"}, want: "Jane Doe"},
+		{account: AccountInfo{Name: "Jane Doe", UsedBytes: 512, AllocatedBytes: 2048}, want: "Jane Doe, 512 B of 2.0 KB used"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.account.Header(); got != tt.want {
+			t.Errorf("Header() = %q, want %q", got, tt.want)
+		}
+	}
+}
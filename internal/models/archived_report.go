@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// Delivery statuses of an archived report
+const (
+	ReportSent   = "sent"
+	ReportFailed = "failed"
+	// ReportQueued reports wait in the notification queue for a retry
+	ReportQueued = "queued"
+)
+
+// ArchivedReport is a sent report kept with its rendered content, so it can
+// be browsed, downloaded and re-sent
+type ArchivedReport struct {
+	ID          int64      `json:"id"`
+	Type        ReportType `json:"type"`
+	Title       string     `json:"title,omitempty"`
+	Language    string     `json:"language,omitempty"`
+	Period      string     `json:"period,omitempty"`
+	Since       time.Time  `json:"since"`
+	Until       time.Time  `json:"until"`
+	GeneratedAt time.Time  `json:"generated_at"`
+	Changes     int        `json:"changes"`
+	// Recipients are the email addresses and other channels the report
+	// was sent to
+	Recipients    []string  `json:"recipients,omitempty"`
+	Status        string    `json:"status"`
+	LastError     string    `json:"last_error,omitempty"`
+	Attempts      int       `json:"attempts"`
+	LastAttemptAt time.Time `json:"last_attempt_at"`
+	// Content is the rendered report; listings leave it out
+	Content string `json:"content,omitempty"`
+}
+
+// NewArchivedReport returns the archive record of report, sent to recipients
+func NewArchivedReport(report *Report, recipients []string) *ArchivedReport {
+	return &ArchivedReport{
+		Type:        report.Type,
+		Title:       report.Title,
+		Language:    report.Language,
+		Period:      report.Period,
+		Since:       report.Since,
+		Until:       report.Until,
+		GeneratedAt: report.GeneratedAt,
+		Changes:     report.TotalChanges,
+		Recipients:  recipients,
+		Attempts:    1,
+		Content:     report.Metadata["content"],
+	}
+}
+
+// Report rebuilds the report from its archived content. Only the rendered
+// content is kept, so the changes themselves are not restored.
+func (a *ArchivedReport) Report() *Report {
+	return &Report{
+		Type:         a.Type,
+		Title:        a.Title,
+		Language:     a.Language,
+		Period:       a.Period,
+		Since:        a.Since,
+		Until:        a.Until,
+		GeneratedAt:  a.GeneratedAt,
+		TotalChanges: a.Changes,
+		Metadata:     map[string]string{"content": a.Content},
+	}
+}
+
+// Filename returns the name the archived report is downloaded under
+func (a *ArchivedReport) Filename() string {
+	return "report-" + a.GeneratedAt.Format("20060102-1504") + a.Type.Extension()
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// ChangeEventKind identifies a higher-level pattern found in file changes
+type ChangeEventKind string
+
+const (
+	// UploadBatchEvent is many files added in a short burst
+	UploadBatchEvent ChangeEventKind = "upload_batch"
+	// FolderReorganizationEvent is files moved between folders
+	FolderReorganizationEvent ChangeEventKind = "folder_reorganization"
+	// RevisionCycleEvent is a document edited or re-saved repeatedly
+	RevisionCycleEvent ChangeEventKind = "revision_cycle"
+)
+
+// ChangeEvent is a human-meaningful summary of a group of related file changes
+type ChangeEvent struct {
+	Kind    ChangeEventKind `json:"kind"`
+	Summary string          `json:"summary"`
+	Start   time.Time       `json:"start"`
+	End     time.Time       `json:"end"`
+	Paths   []string        `json:"paths"`
+	Bytes   int64           `json:"bytes"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiffSummary is a compact description of how a text document changed
+type DiffSummary struct {
+	Path     string   `json:"path"`
+	Added    int      `json:"added"`
+	Removed  int      `json:"removed"`
+	Sections []string `json:"sections,omitempty"`
+}
+
+// String formats the summary as "+120 / -45 lines, sections changed: Budget, Timeline"
+func (d DiffSummary) String() string {
+	s := fmt.Sprintf("+%d / -%d lines", d.Added, d.Removed)
+	if len(d.Sections) > 0 {
+		s += ", sections changed: " + strings.Join(d.Sections, ", ")
+	}
+	return s
+}
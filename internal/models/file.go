@@ -1,7 +1,9 @@
 package models
 
 import (
+	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 )
@@ -18,6 +20,10 @@ type FileMetadata struct {
 	Extension      string    `json:"extension"`      // File extension
 	Directory      string    `json:"directory"`      // Parent directory
 	ModTime        time.Time `json:"mod_time"`      // Last modification time
+	AccountID      string    `json:"account_id,omitempty"` // Owning account in multi-account mode
+	ContentHash    string    `json:"content_hash,omitempty"` // Dropbox content hash of the file
+	Rev            string    `json:"rev,omitempty"`          // Dropbox revision of the file
+	ModifiedByID   string    `json:"modified_by_id,omitempty"` // Account ID of the last modifier in shared folders
 }
 
 // FileContent represents analyzed content of a file
@@ -30,17 +36,125 @@ type FileContent struct {
 	Keywords     []string `json:"keywords,omitempty"`
 	Topics       []string `json:"topics,omitempty"`
 	Summary      string   `json:"summary,omitempty"`
+	// Text is the normalized text extracted from documents with a text extractor
+	Text string `json:"text,omitempty"`
 }
 
 // FileChange represents a processed file change with additional metadata
 type FileChange struct {
-	Path      string    `json:"path"`
-	Extension string    `json:"extension"`
-	Directory string    `json:"directory"`
-	ModTime   time.Time `json:"mod_time"`
-	Modified  time.Time `json:"modified"`
-	IsDeleted bool      `json:"is_deleted"`
-	Size      int64     `json:"size"`
+	Path       string    `json:"path"`
+	Extension  string    `json:"extension"`
+	Directory  string    `json:"directory"`
+	ModTime    time.Time `json:"mod_time"`
+	Modified   time.Time `json:"modified"`
+	IsDeleted  bool      `json:"is_deleted"`
+	Size       int64     `json:"size"`
+	AccountID  string    `json:"account_id,omitempty"`
+	ModifiedBy string    `json:"modified_by,omitempty"`
+	// ContentType is the MIME type sniffed from the file content, if it was downloaded
+	ContentType string `json:"content_type,omitempty"`
+	// ContentHash is the Dropbox content hash reported with the file metadata
+	ContentHash string `json:"content_hash,omitempty"`
+	// SHA256 is the SHA-256 of the file content, if it was downloaded
+	SHA256 string `json:"sha256,omitempty"`
+	// Portfolio, Project and DocumentType are set by the classification rules
+	Portfolio    string `json:"portfolio,omitempty"`
+	Project      string `json:"project,omitempty"`
+	DocumentType string `json:"document_type,omitempty"`
+	// ChangeType records what happened to the file when it was detected;
+	// Type derives it for changes without one
+	ChangeType ChangeType `json:"change_type,omitempty"`
+	// PreviousPath is the path a renamed file was moved from
+	PreviousPath string `json:"previous_path,omitempty"`
+	// URL is the link of a shared link or Paper doc change
+	URL string `json:"url,omitempty"`
+	// Details holds the custom fields attached by enrichers
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// ChangeType describes what happened to a file
+type ChangeType string
+
+const (
+	// ChangeModified is a file added or modified in place
+	ChangeModified ChangeType = "modified"
+	// ChangeDeleted is a deleted file
+	ChangeDeleted ChangeType = "deleted"
+	// ChangeRenamed is a file moved from PreviousPath without changing its content
+	ChangeRenamed ChangeType = "renamed"
+	// ChangeSharedLink is a shared link created for the file at Path
+	ChangeSharedLink ChangeType = "shared_link"
+	// ChangePaper is a Paper doc created or edited
+	ChangePaper ChangeType = "paper"
+)
+
+// Type returns the change type, deriving it from IsDeleted when unset
+func (fc FileChange) Type() ChangeType {
+	if fc.ChangeType != "" {
+		return fc.ChangeType
+	}
+	if fc.IsDeleted {
+		return ChangeDeleted
+	}
+	return ChangeModified
+}
+
+// IsRenamed reports whether the file was moved from PreviousPath
+func (fc FileChange) IsRenamed() bool {
+	return fc.Type() == ChangeRenamed
+}
+
+// Key identifies the detected version of the change: the same change
+// detected again has the same key
+func (fc FileChange) Key() string {
+	return fmt.Sprintf("%s|%d|%t|%s|%d|%s|%s", fc.Path, fc.Modified.UnixNano(), fc.IsDeleted,
+		fc.ContentHash, fc.Size, fc.Type(), fc.PreviousPath)
+}
+
+// DetailsString lists the custom fields as "key: value" pairs sorted by key,
+// or returns "" when there are none
+func (fc FileChange) DetailsString() string {
+	keys := make([]string, 0, len(fc.Details))
+	for key := range fc.Details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+": "+fc.Details[key])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// Validate checks that the change has the fields required for processing
+func (fc FileChange) Validate() error {
+	if fc.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+	if !strings.HasPrefix(fc.Path, "/") {
+		return fmt.Errorf("path %q must be absolute", fc.Path)
+	}
+	if fc.Size < 0 {
+		return fmt.Errorf("size cannot be negative")
+	}
+	if fc.Modified.IsZero() {
+		return fmt.Errorf("modified time is required")
+	}
+	return nil
+}
+
+// Normalize fills the fields derived from the path and modification time
+func (fc *FileChange) Normalize() {
+	if fc.Extension == "" {
+		fc.Extension = FileExtension(fc.Path)
+	}
+	if fc.Directory == "" {
+		fc.Directory = filepath.Dir(fc.Path)
+	}
+	if fc.ModTime.IsZero() {
+		fc.ModTime = fc.Modified
+	}
 }
 
 // NewFileMetadata creates a new FileMetadata with computed fields
@@ -52,7 +166,7 @@ func NewFileMetadata(path string, size int64, modified time.Time, isDeleted bool
 		Modified:  modified,
 		IsDeleted: isDeleted,
 		PathLower: strings.ToLower(path),
-		Extension: strings.ToLower(filepath.Ext(path)),
+		Extension: FileExtension(path),
 		Directory: filepath.Dir(path),
 		ModTime:   modified,
 	}
@@ -61,13 +175,15 @@ func NewFileMetadata(path string, size int64, modified time.Time, isDeleted bool
 // ToFileChange converts a FileMetadata to a FileChange
 func (fm *FileMetadata) ToFileChange() FileChange {
 	return FileChange{
-		Path:      fm.Path,
-		Extension: fm.Extension,
-		Directory: fm.Directory,
-		ModTime:   fm.ModTime,
-		Modified:  fm.Modified,
-		IsDeleted: fm.IsDeleted,
-		Size:      fm.Size,
+		Path:        fm.Path,
+		Extension:   fm.Extension,
+		Directory:   fm.Directory,
+		ModTime:     fm.ModTime,
+		Modified:    fm.Modified,
+		IsDeleted:   fm.IsDeleted,
+		Size:        fm.Size,
+		AccountID:   fm.AccountID,
+		ContentHash: fm.ContentHash,
 	}
 }
 
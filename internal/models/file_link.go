@@ -0,0 +1,23 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// FileLink opens a changed file from a report
+type FileLink struct {
+	URL string `json:"url"`
+	// Temporary links download the file without signing in and expire
+	// after four hours
+	Temporary bool `json:"temporary,omitempty"`
+	// Thumbnail is a JPEG preview of an image, embedded in HTML emails
+	Thumbnail []byte `json:"-"`
+}
+
+// ThumbnailContentID returns the Content-ID the thumbnail of the file at
+// path is embedded under in an HTML email
+func ThumbnailContentID(path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return "thumbnail-" + hex.EncodeToString(sum[:8]) + "@dropbox-monitor"
+}
@@ -138,3 +138,43 @@ func TestFileChangeJSON(t *testing.T) {
 		t.Errorf("IsDeleted mismatch: got %v, want %v", unmarshaled.IsDeleted, change.IsDeleted)
 	}
 }
+
+func TestFileChangeValidate(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		change  FileChange
+		wantErr bool
+	}{
+		{name: "valid", change: FileChange{Path: "/docs/a.txt", Modified: now, Size: 10}},
+		{name: "missing path", change: FileChange{Modified: now}, wantErr: true},
+		{name: "relative path", change: FileChange{Path: "docs/a.txt", Modified: now}, wantErr: true},
+		{name: "negative size", change: FileChange{Path: "/a.txt", Modified: now, Size: -1}, wantErr: true},
+		{name: "missing modified time", change: FileChange{Path: "/a.txt"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.change.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFileChangeNormalize(t *testing.T) {
+	now := time.Now()
+	change := FileChange{Path: "/docs/Report.PDF", Modified: now}
+	change.Normalize()
+
+	if change.Extension != ".pdf" {
+		t.Errorf("Extension = %q, want .pdf", change.Extension)
+	}
+	if change.Directory != "/docs" {
+		t.Errorf("Directory = %q, want /docs", change.Directory)
+	}
+	if !change.ModTime.Equal(now) {
+		t.Errorf("ModTime = %v, want %v", change.ModTime, now)
+	}
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// NoExtension is the extension statistics bucket for files without an extension
+const NoExtension = "none"
+
+// UnknownFileType is the file type statistics bucket for files whose type
+// could not be determined
+const UnknownFileType = "unknown"
+
+// FileExtension returns the lower-cased extension of the file name in p,
+// including the dot. Names without a dot, hidden files such as ".bashrc" and
+// names ending in a dot have no extension.
+func FileExtension(p string) string {
+	name := path.Base(p)
+	dot := strings.LastIndexByte(name, '.')
+	if dot <= 0 || dot == len(name)-1 {
+		return ""
+	}
+	return strings.ToLower(name[dot:])
+}
+
+// SniffContentType returns the MIME type of content, without parameters,
+// detected from its leading bytes
+func SniffContentType(content []byte) string {
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(content))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
+
+// ExtensionBucket returns the extension the change is counted under in
+// report statistics
+func (fc FileChange) ExtensionBucket() string {
+	if fc.Extension == "" {
+		return NoExtension
+	}
+	return fc.Extension
+}
+
+// FileType returns the MIME type the change is counted under in report
+// statistics: the sniffed content type when known, otherwise the type
+// registered for its extension, otherwise UnknownFileType
+func (fc FileChange) FileType() string {
+	contentType := fc.ContentType
+	if contentType == "" && fc.Extension != "" {
+		contentType = mime.TypeByExtension(fc.Extension)
+	}
+	if contentType == "" {
+		return UnknownFileType
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return UnknownFileType
+	}
+	return mediaType
+}
@@ -0,0 +1,77 @@
+package models
+
+import "testing"
+
+func TestFileExtension(t *testing.T) {
+	tests := map[string]string{
+		"/Work/Report.DOCX":    ".docx",
+		"/Work/archive.tar.gz": ".gz",
+		"/Work/Makefile":       "",
+		"/Work/.bashrc":        "",
+		"/Work/notes.":         "",
+		"/v1.2/README":         "",
+		"":                     "",
+		"/":                    "",
+	}
+
+	for path, want := range tests {
+		if got := FileExtension(path); got != want {
+			t.Errorf("FileExtension(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestFileChangeFileType(t *testing.T) {
+	tests := []struct {
+		change     FileChange
+		wantType   string
+		wantBucket string
+	}{
+		{change: FileChange{Path: "/a.pdf", Extension: ".pdf"}, wantType: "application/pdf", wantBucket: ".pdf"},
+		{change: FileChange{Path: "/a.html", Extension: ".html"}, wantType: "text/html", wantBucket: ".html"},
+		{change: FileChange{Path: "/LICENSE"}, wantType: UnknownFileType, wantBucket: NoExtension},
+		{change: FileChange{Path: "/LICENSE", ContentType: "text/plain; charset=utf-8"}, wantType: "text/plain", wantBucket: NoExtension},
+		{change: FileChange{Path: "/a.zzz", Extension: ".zzz"}, wantType: UnknownFileType, wantBucket: ".zzz"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.change.FileType(); got != tt.wantType {
+			t.Errorf("FileType() of %s = %q, want %q", tt.change.Path, got, tt.wantType)
+		}
+		if got := tt.change.ExtensionBucket(); got != tt.wantBucket {
+			t.Errorf("ExtensionBucket() of %s = %q, want %q", tt.change.Path, got, tt.wantBucket)
+		}
+	}
+}
+
+func TestSniffContentType(t *testing.T) {
+	tests := map[string]string{
+		"%PDF-1.7\n":        "application/pdf",
+		"plain notes\n":     "text/plain",
+		"\x89PNG\r\n\x1a\n": "image/png",
+		"\x00\x01\x02\x03":  "application/octet-stream",
+	}
+
+	for content, want := range tests {
+		if got := SniffContentType([]byte(content)); got != want {
+			t.Errorf("SniffContentType(%q) = %q, want %q", content, got, want)
+		}
+	}
+}
+
+func TestReportFileTypeCounts(t *testing.T) {
+	report := NewReport(FileListReport)
+	report.AddChange(FileChange{Path: "/Makefile"})
+	report.AddChange(FileChange{Path: "/a.pdf", Extension: ".pdf"})
+	report.AddChange(FileChange{Path: "/b.pdf", Extension: ".pdf"})
+
+	if report.ExtensionCount[NoExtension] != 1 || report.ExtensionCount[".pdf"] != 2 {
+		t.Errorf("Unexpected extension counts: %v", report.ExtensionCount)
+	}
+	if report.FileTypeCount[UnknownFileType] != 1 || report.FileTypeCount["application/pdf"] != 2 {
+		t.Errorf("Unexpected file type counts: %v", report.FileTypeCount)
+	}
+	if top := report.GetTopFileTypes(1); len(top) != 1 || top[0] != "application/pdf" {
+		t.Errorf("Unexpected top file types: %v", top)
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// MonitoringGap is a period in which changes were not being detected, such as
+// while Dropbox requests were paused by the API rate limit
+type MonitoringGap struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason"`
+}
+
+// Duration returns the length of the gap
+func (g MonitoringGap) Duration() time.Duration {
+	return g.End.Sub(g.Start)
+}
+
+// String describes the gap, such as "2024-05-01 12:00 to 12:20 (20m0s): reason"
+func (g MonitoringGap) String() string {
+	end := g.End.Format("15:04")
+	if g.End.Format("2006-01-02") != g.Start.Format("2006-01-02") {
+		end = g.End.Format("2006-01-02 15:04")
+	}
+	return fmt.Sprintf("%s to %s (%s): %s", g.Start.Format("2006-01-02 15:04"), end,
+		g.Duration().Round(time.Minute), g.Reason)
+}
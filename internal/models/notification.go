@@ -0,0 +1,55 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// QueuedNotification is a notification waiting to be retried on one channel
+type QueuedNotification struct {
+	ID        int64     `json:"id"`
+	Channel   string    `json:"channel"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+	// NextAttemptAt is when the notification is next due for a retry; the
+	// zero time is due at once
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	// DeadLettered notifications ran out of attempts and are no longer
+	// retried until requeued
+	DeadLettered bool `json:"dead_lettered,omitempty"`
+	// Report is the report a queued report delivery sends, with Message
+	// as its text rendering; it is nil for plain notifications
+	Report *Report `json:"-"`
+	// ArchiveID is the archived report whose delivery status is updated
+	// when the notification is delivered or dead-lettered
+	ArchiveID int64 `json:"archive_id,omitempty"`
+}
+
+// EmailRecipient is the delivery record of one email address
+type EmailRecipient struct {
+	Address   string `json:"address"`
+	Delivered int    `json:"delivered"`
+	Failed    int    `json:"failed"`
+	// HardBounces counts permanent failures, such as unknown mailboxes
+	HardBounces int `json:"hard_bounces"`
+	// ConsecutiveBounces counts hard bounces since the last delivery
+	ConsecutiveBounces int        `json:"consecutive_bounces"`
+	LastError          string     `json:"last_error,omitempty"`
+	LastDeliveredAt    *time.Time `json:"last_delivered_at,omitempty"`
+	LastFailedAt       *time.Time `json:"last_failed_at,omitempty"`
+	// DisabledAt is when the address stopped receiving mail, if it has
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+}
+
+// Disabled reports whether mail to the address is suppressed
+func (r EmailRecipient) Disabled() bool {
+	return r.DisabledAt != nil
+}
+
+// NormalizeEmailAddress lower-cases an email address and trims its spaces,
+// so each mailbox has one record
+func NormalizeEmailAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// ChangeQuery is a named filter over file changes. Empty criteria match
+// everything; non-empty criteria must all match.
+type ChangeQuery struct {
+	Name       string   `json:"name"`
+	PathPrefix string   `json:"path_prefix,omitempty"`
+	Extensions []string `json:"extensions,omitempty"`
+	Authors    []string `json:"authors,omitempty"`
+}
+
+// Matches reports whether the change satisfies the query
+func (q ChangeQuery) Matches(change FileChange) bool {
+	if q.PathPrefix != "" && !strings.HasPrefix(strings.ToLower(change.Path), strings.ToLower(q.PathPrefix)) {
+		return false
+	}
+
+	if len(q.Extensions) > 0 {
+		ext := change.Extension
+		if ext == "" {
+			ext = filepath.Ext(change.Path)
+		}
+		if !containsFold(q.Extensions, normalizeExtension(ext)) {
+			return false
+		}
+	}
+
+	if len(q.Authors) > 0 && !containsFold(q.Authors, change.ModifiedBy) {
+		return false
+	}
+
+	return true
+}
+
+// Filter returns the changes that satisfy the query
+func (q ChangeQuery) Filter(changes []FileChange) []FileChange {
+	matched := make([]FileChange, 0, len(changes))
+	for _, change := range changes {
+		if q.Matches(change) {
+			matched = append(matched, change)
+		}
+	}
+	return matched
+}
+
+// normalizeExtension lower-cases an extension and strips the leading dot
+func normalizeExtension(ext string) string {
+	return strings.TrimPrefix(strings.ToLower(ext), ".")
+}
+
+// containsFold reports whether value is in values, ignoring case and leading dots
+func containsFold(values []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, v := range values {
+		if strings.EqualFold(normalizeExtension(v), normalizeExtension(value)) {
+			return true
+		}
+	}
+	return false
+}
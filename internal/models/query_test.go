@@ -0,0 +1,77 @@
+package models
+
+import "testing"
+
+func TestChangeQueryMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  ChangeQuery
+		change FileChange
+		want   bool
+	}{
+		{
+			name:   "empty query matches everything",
+			query:  ChangeQuery{Name: "all"},
+			change: FileChange{Path: "/any/file.txt"},
+			want:   true,
+		},
+		{
+			name:   "path prefix and extension",
+			query:  ChangeQuery{PathPrefix: "/DB", Extensions: []string{".sql"}},
+			change: FileChange{Path: "/db/schema/tables.SQL"},
+			want:   true,
+		},
+		{
+			name:   "extension without dot",
+			query:  ChangeQuery{Extensions: []string{"sql"}},
+			change: FileChange{Path: "/db/tables.sql", Extension: ".sql"},
+			want:   true,
+		},
+		{
+			name:   "wrong extension",
+			query:  ChangeQuery{PathPrefix: "/DB", Extensions: []string{".sql"}},
+			change: FileChange{Path: "/DB/notes.txt"},
+			want:   false,
+		},
+		{
+			name:   "outside path prefix",
+			query:  ChangeQuery{PathPrefix: "/DB"},
+			change: FileChange{Path: "/docs/tables.sql"},
+			want:   false,
+		},
+		{
+			name:   "author match",
+			query:  ChangeQuery{Authors: []string{"Alice"}},
+			change: FileChange{Path: "/a.txt", ModifiedBy: "alice"},
+			want:   true,
+		},
+		{
+			name:   "unknown author",
+			query:  ChangeQuery{Authors: []string{"Alice"}},
+			change: FileChange{Path: "/a.txt"},
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.query.Matches(tt.change); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangeQueryFilter(t *testing.T) {
+	query := ChangeQuery{Extensions: []string{".sql"}}
+	changes := []FileChange{
+		{Path: "/a.sql"},
+		{Path: "/b.txt"},
+		{Path: "/c.sql"},
+	}
+
+	filtered := query.Filter(changes)
+	if len(filtered) != 2 || filtered[0].Path != "/a.sql" || filtered[1].Path != "/c.sql" {
+		t.Errorf("Filter() = %v, want /a.sql and /c.sql", filtered)
+	}
+}
@@ -15,8 +15,44 @@ const (
 	NarrativeReport ReportType = "narrative"
 	// HTMLReport is formatted in HTML
 	HTMLReport ReportType = "html"
+	// JSONReport is a schema-versioned, machine-readable document
+	JSONReport ReportType = "json"
+	// CSVReport lists the changes as comma-separated values
+	CSVReport ReportType = "csv"
+	// MarkdownReport is a compact digest for chat tools and issue trackers
+	MarkdownReport ReportType = "markdown"
 )
 
+// ContentType returns the MIME type of reports of this type
+func (t ReportType) ContentType() string {
+	switch t {
+	case HTMLReport:
+		return "text/html; charset=utf-8"
+	case JSONReport:
+		return "application/json"
+	case CSVReport:
+		return "text/csv; charset=utf-8"
+	case MarkdownReport:
+		return "text/markdown; charset=utf-8"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// Extension returns the file extension of reports of this type
+func (t ReportType) Extension() string {
+	switch t {
+	case HTMLReport:
+		return ".html"
+	case JSONReport:
+		return ".json"
+	case CSVReport:
+		return ".csv"
+	case MarkdownReport:
+		return ".md"
+	}
+	return ".txt"
+}
+
 // ActivityPattern represents a pattern of activity
 type ActivityPattern struct {
 	MainDirectories []string      `json:"main_directories"`
@@ -29,16 +65,40 @@ type ActivityPattern struct {
 // Report represents a complete change report
 type Report struct {
 	Type           ReportType         `json:"type"`
+	Title          string             `json:"title,omitempty"`
+	// Language is the tag of the language the report is written in, as
+	// understood by the i18n package; empty is English
+	Language       string             `json:"language,omitempty"`
 	Period         string             `json:"period"`
 	Since          time.Time          `json:"since"`
 	Until          time.Time          `json:"until"`
 	Changes        []FileChange       `json:"changes"`
 	ActivityStats  *ActivityPattern   `json:"activity_stats,omitempty"`
 	ExtensionCount map[string]int     `json:"extension_count"`
+	FileTypeCount  map[string]int     `json:"file_type_count"`
 	DirectoryCount map[string]int     `json:"directory_count"`
 	GeneratedAt    time.Time          `json:"generated_at"`
 	TotalChanges   int                `json:"total_changes"`
 	Metadata       map[string]string  `json:"metadata"`
+	SecurityEvents []TeamEvent        `json:"security_events,omitempty"`
+	Diffs          []DiffSummary      `json:"diffs,omitempty"`
+	Account        *AccountInfo       `json:"account,omitempty"`
+	Gaps           []MonitoringGap    `json:"gaps,omitempty"`
+	// Summary is an executive summary of the changes written by an AI
+	// provider; reports without one fall back to their template text
+	Summary        string             `json:"summary,omitempty"`
+	// KeywordCount and TopicCount count the changed documents in which
+	// content analysis found each keyword and topic
+	KeywordCount   map[string]int     `json:"keyword_count,omitempty"`
+	TopicCount     map[string]int     `json:"topic_count,omitempty"`
+	// CategoryCount counts the changes by the document type the
+	// classification rules gave them
+	CategoryCount  map[string]int     `json:"category_count,omitempty"`
+	// Links opens the changed files, keyed by path
+	Links          map[string]FileLink `json:"links,omitempty"`
+	// ArchiveID is the ID of the report's archive entry once it is archived;
+	// queued deliveries record their outcome there
+	ArchiveID      int64              `json:"-"`
 }
 
 // NewReport creates a new report instance
@@ -51,6 +111,7 @@ func NewReport(reportType ReportType) *Report {
 		Until:          now,
 		Changes:        make([]FileChange, 0),
 		ExtensionCount: make(map[string]int),
+		FileTypeCount:  make(map[string]int),
 		DirectoryCount: make(map[string]int),
 		GeneratedAt:    now,
 		Metadata:       make(map[string]string),
@@ -60,8 +121,15 @@ func NewReport(reportType ReportType) *Report {
 // AddChange adds a file change to the report and updates counts
 func (r *Report) AddChange(change FileChange) {
 	r.Changes = append(r.Changes, change)
-	r.ExtensionCount[change.Extension]++
+	r.ExtensionCount[change.ExtensionBucket()]++
+	r.FileTypeCount[change.FileType()]++
 	r.DirectoryCount[change.Directory]++
+	if change.DocumentType != "" {
+		if r.CategoryCount == nil {
+			r.CategoryCount = make(map[string]int)
+		}
+		r.CategoryCount[change.DocumentType]++
+	}
 	r.TotalChanges++
 }
 
@@ -70,11 +138,31 @@ func (r *Report) GetTopExtensions(n int) []string {
 	return getTopItems(r.ExtensionCount, n)
 }
 
+// GetTopFileTypes returns the n most common MIME types
+func (r *Report) GetTopFileTypes(n int) []string {
+	return getTopItems(r.FileTypeCount, n)
+}
+
 // GetTopDirectories returns the n most active directories
 func (r *Report) GetTopDirectories(n int) []string {
 	return getTopItems(r.DirectoryCount, n)
 }
 
+// GetTopKeywords returns the n keywords found in the most changed documents
+func (r *Report) GetTopKeywords(n int) []string {
+	return getTopItems(r.KeywordCount, n)
+}
+
+// GetTopTopics returns the n topics found in the most changed documents
+func (r *Report) GetTopTopics(n int) []string {
+	return getTopItems(r.TopicCount, n)
+}
+
+// GetTopCategories returns the n most common document types
+func (r *Report) GetTopCategories(n int) []string {
+	return getTopItems(r.CategoryCount, n)
+}
+
 // SetTimeRange sets the time range for the report
 func (r *Report) SetTimeRange(since, until time.Time) {
 	r.Since = since
@@ -100,7 +188,10 @@ func getTopItems(items map[string]int, n int) []string {
 	}
 
 	sort.Slice(sorted, func(i, j int) bool {
-		return sorted[i].Value > sorted[j].Value
+		if sorted[i].Value != sorted[j].Value {
+			return sorted[i].Value > sorted[j].Value
+		}
+		return sorted[i].Key < sorted[j].Key
 	})
 
 	result := make([]string, 0, n)
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// SharedLink is a shared link to a file or folder
+type SharedLink struct {
+	URL  string `json:"url"`
+	Name string `json:"name"`
+	// Path is the lower-case path of the linked item, empty when it is not
+	// in the account's Dropbox
+	Path string `json:"path,omitempty"`
+	// Visibility is who can open the link, e.g. "public" or "team_only"
+	Visibility string    `json:"visibility,omitempty"`
+	Expires    time.Time `json:"expires,omitempty"`
+}
+
+// PaperDoc describes a Dropbox Paper doc
+type PaperDoc struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title"`
+	Owner      string    `json:"owner"`
+	LastEditor string    `json:"last_editor,omitempty"`
+	Status     string    `json:"status"`
+	Revision   int64     `json:"revision"`
+	Created    time.Time `json:"created"`
+	Updated    time.Time `json:"updated"`
+}
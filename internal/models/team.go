@@ -0,0 +1,23 @@
+package models
+
+// TeamFolder is a folder owned by a Dropbox Business team rather than one of
+// its members
+type TeamFolder struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// TeamSharedDropbox is set for folders in a team space
+	TeamSharedDropbox bool `json:"team_shared_dropbox"`
+}
+
+// Namespace is a Dropbox Business namespace: a team folder, a shared folder
+// or a member's home folder. Paths can be rooted at a namespace with its ID.
+type Namespace struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Type is "app_folder", "shared_folder", "team_folder", "team_member_folder"
+	// or "team_member_root"
+	Type string `json:"type"`
+	// MemberID is the member owning a member folder
+	MemberID string `json:"member_id,omitempty"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// TeamEvent is an entry from a Dropbox Business team events log, such as a
+// sharing change, a sign-in or a linked device
+type TeamEvent struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Category    string    `json:"category"`
+	Type        string    `json:"type"`
+	Description string    `json:"description"`
+	Actor       string    `json:"actor,omitempty"`
+	IPAddress   string    `json:"ip_address,omitempty"`
+	AccountID   string    `json:"account_id,omitempty"`
+	// Details holds the raw event details as JSON
+	Details string `json:"details,omitempty"`
+}
@@ -0,0 +1,253 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/textproto"
+	"sync"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/safego"
+)
+
+// DefaultMaxBounces is how many hard bounces in a row disable a recipient
+// when no limit is configured
+const DefaultMaxBounces = 3
+
+// RecipientStore persists the delivery record of each email recipient
+type RecipientStore interface {
+	RecordEmailDelivery(ctx context.Context, address string, at time.Time) error
+	RecordEmailFailure(ctx context.Context, address string, hard bool, reason string, at time.Time) (models.EmailRecipient, error)
+	SetEmailRecipientDisabled(ctx context.Context, address string, disabled bool, at time.Time) error
+	EmailRecipients(ctx context.Context) ([]models.EmailRecipient, error)
+}
+
+// Bounce is a failed delivery to one recipient
+type Bounce struct {
+	Address string
+	// Hard marks permanent failures, such as an unknown mailbox
+	Hard   bool
+	Reason string
+}
+
+// RecipientError is a recipient the SMTP server refused
+type RecipientError struct {
+	Address string
+	Err     error
+}
+
+// Error implements error
+func (e *RecipientError) Error() string {
+	return fmt.Sprintf("recipient %s refused: %v", e.Address, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *RecipientError) Unwrap() error {
+	return e.Err
+}
+
+// Hard reports whether the server refused the recipient permanently, with
+// a 5xx reply
+func (e *RecipientError) Hard() bool {
+	var reply *textproto.Error
+	return errors.As(e.Err, &reply) && reply.Code >= 500
+}
+
+// DeliveryStats summarizes the delivery records of all recipients
+type DeliveryStats struct {
+	Delivered   int                     `json:"delivered"`
+	Failed      int                     `json:"failed"`
+	HardBounces int                     `json:"hard_bounces"`
+	Disabled    int                     `json:"disabled"`
+	Recipients  []models.EmailRecipient `json:"recipients"`
+}
+
+// Bounces keeps the delivery record of email recipients and disables those
+// whose mail bounces permanently MaxBounces times in a row. Bounces are
+// learnt from SMTP replies and, with a mailbox, from the bounce messages
+// delivered to it.
+type Bounces struct {
+	store      RecipientStore
+	maxBounces int
+	mailbox    *BounceMailbox
+	alerts     Notifier
+	now        func() time.Time
+	logger     *slog.Logger
+
+	mu       sync.Mutex
+	disabled map[string]bool
+}
+
+// NewBounces creates a bounce tracker; a zero maxBounces uses the default
+func NewBounces(store RecipientStore, maxBounces int) *Bounces {
+	if maxBounces <= 0 {
+		maxBounces = DefaultMaxBounces
+	}
+	return &Bounces{store: store, maxBounces: maxBounces, now: time.Now, logger: logging.Component(nil, "bounces")}
+}
+
+// WithMailbox reads bounce messages from mailbox in CheckMailbox
+func (b *Bounces) WithMailbox(mailbox *BounceMailbox) *Bounces {
+	b.mailbox = mailbox
+	return b
+}
+
+// WithAlerts tells operators through alerts when a recipient is disabled
+func (b *Bounces) WithAlerts(alerts Notifier) *Bounces {
+	b.alerts = alerts
+	return b
+}
+
+// Active returns the addresses that have not been disabled. If the records
+// cannot be loaded, every address is returned.
+func (b *Bounces) Active(ctx context.Context, addresses []string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.load(ctx); err != nil {
+		b.logger.Error("Failed to load email recipients, sending to all", "error", err)
+		return addresses
+	}
+	active := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		if !b.disabled[models.NormalizeEmailAddress(address)] {
+			active = append(active, address)
+		}
+	}
+	return active
+}
+
+// Delivered records a successful delivery to each address
+func (b *Bounces) Delivered(ctx context.Context, addresses []string) {
+	for _, address := range addresses {
+		if err := b.store.RecordEmailDelivery(ctx, address, b.now()); err != nil {
+			b.logger.Error("Failed to record delivery", "address", address, "error", err)
+		}
+	}
+}
+
+// Bounced records a failed delivery, disabling the recipient once it has
+// bounced permanently too often in a row
+func (b *Bounces) Bounced(ctx context.Context, bounce Bounce) {
+	recipient, err := b.store.RecordEmailFailure(ctx, bounce.Address, bounce.Hard, bounce.Reason, b.now())
+	if err != nil {
+		b.logger.Error("Failed to record bounce", "address", bounce.Address, "error", err)
+		return
+	}
+	if recipient.Disabled() || recipient.ConsecutiveBounces < b.maxBounces {
+		return
+	}
+
+	if err := b.store.SetEmailRecipientDisabled(ctx, bounce.Address, true, b.now()); err != nil {
+		b.logger.Error("Failed to disable recipient", "address", bounce.Address, "error", err)
+		return
+	}
+	b.mu.Lock()
+	if b.disabled != nil {
+		b.disabled[models.NormalizeEmailAddress(bounce.Address)] = true
+	}
+	b.mu.Unlock()
+
+	message := fmt.Sprintf("Email to %s has been disabled after %d bounces in a row. The last was: %s",
+		bounce.Address, recipient.ConsecutiveBounces, bounce.Reason)
+	b.logger.Warn("Recipient disabled after bounces", "address", bounce.Address,
+		"bounces", recipient.ConsecutiveBounces, "reason", bounce.Reason)
+	if b.alerts != nil {
+		// The alert may go out by email itself, so it must not wait for the
+		// delivery that reported this bounce
+		safego.Go(nil, "bounce alert", func() {
+			if err := b.alerts.SendNotification(context.Background(), message); err != nil {
+				b.logger.Error("Failed to send bounce alert", "error", err)
+			}
+		})
+	}
+}
+
+// Enable resumes mail to a disabled address
+func (b *Bounces) Enable(ctx context.Context, address string) error {
+	if err := b.store.SetEmailRecipientDisabled(ctx, address, false, b.now()); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	if b.disabled != nil {
+		delete(b.disabled, models.NormalizeEmailAddress(address))
+	}
+	b.mu.Unlock()
+	return nil
+}
+
+// Stats returns the delivery records of all recipients with their totals
+func (b *Bounces) Stats(ctx context.Context) (DeliveryStats, error) {
+	recipients, err := b.store.EmailRecipients(ctx)
+	if err != nil {
+		return DeliveryStats{}, err
+	}
+
+	stats := DeliveryStats{Recipients: make([]models.EmailRecipient, 0, len(recipients))}
+	for _, recipient := range recipients {
+		stats.Delivered += recipient.Delivered
+		stats.Failed += recipient.Failed
+		stats.HardBounces += recipient.HardBounces
+		if recipient.Disabled() {
+			stats.Disabled++
+		}
+		stats.Recipients = append(stats.Recipients, recipient)
+	}
+	return stats, nil
+}
+
+// CheckMailbox records the bounces found in the bounce mailbox, if one is
+// configured; it can be registered as a scheduler task
+func (b *Bounces) CheckMailbox(ctx context.Context) error {
+	if b.mailbox == nil {
+		return nil
+	}
+	bounces, err := b.mailbox.Fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read bounce mailbox: %w", err)
+	}
+	for _, bounce := range bounces {
+		b.Bounced(ctx, bounce)
+	}
+	return nil
+}
+
+// record updates the delivery records after a send to addresses, of which
+// the server refused those in rejected
+func (b *Bounces) record(ctx context.Context, addresses []string, rejected []*RecipientError) {
+	refused := make(map[string]bool, len(rejected))
+	for _, r := range rejected {
+		refused[r.Address] = true
+		b.Bounced(ctx, Bounce{Address: r.Address, Hard: r.Hard(), Reason: r.Err.Error()})
+	}
+
+	var delivered []string
+	for _, address := range addresses {
+		if !refused[address] {
+			delivered = append(delivered, address)
+		}
+	}
+	b.Delivered(ctx, delivered)
+}
+
+// load reads the disabled addresses once; the caller holds b.mu
+func (b *Bounces) load(ctx context.Context) error {
+	if b.disabled != nil {
+		return nil
+	}
+	recipients, err := b.store.EmailRecipients(ctx)
+	if err != nil {
+		return err
+	}
+	b.disabled = make(map[string]bool)
+	for _, recipient := range recipients {
+		if recipient.Disabled() {
+			b.disabled[recipient.Address] = true
+		}
+	}
+	return nil
+}
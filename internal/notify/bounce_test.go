@@ -0,0 +1,163 @@
+package notify
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryRecipients is an in-memory RecipientStore
+type memoryRecipients struct {
+	mu         sync.Mutex
+	recipients map[string]*models.EmailRecipient
+}
+
+func newMemoryRecipients() *memoryRecipients {
+	return &memoryRecipients{recipients: make(map[string]*models.EmailRecipient)}
+}
+
+func (m *memoryRecipients) get(address string) *models.EmailRecipient {
+	address = models.NormalizeEmailAddress(address)
+	r, ok := m.recipients[address]
+	if !ok {
+		r = &models.EmailRecipient{Address: address}
+		m.recipients[address] = r
+	}
+	return r
+}
+
+func (m *memoryRecipients) RecordEmailDelivery(ctx context.Context, address string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.get(address)
+	r.Delivered++
+	r.ConsecutiveBounces = 0
+	r.LastDeliveredAt = &at
+	return nil
+}
+
+func (m *memoryRecipients) RecordEmailFailure(ctx context.Context, address string, hard bool, reason string, at time.Time) (models.EmailRecipient, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.get(address)
+	r.Failed++
+	if hard {
+		r.HardBounces++
+		r.ConsecutiveBounces++
+	}
+	r.LastError = reason
+	r.LastFailedAt = &at
+	return *r, nil
+}
+
+func (m *memoryRecipients) SetEmailRecipientDisabled(ctx context.Context, address string, disabled bool, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.get(address)
+	r.DisabledAt = nil
+	if disabled {
+		r.DisabledAt = &at
+	} else {
+		r.ConsecutiveBounces = 0
+	}
+	return nil
+}
+
+func (m *memoryRecipients) EmailRecipients(ctx context.Context) ([]models.EmailRecipient, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var recipients []models.EmailRecipient
+	for _, r := range m.recipients {
+		recipients = append(recipients, *r)
+	}
+	sort.Slice(recipients, func(i, j int) bool { return recipients[i].Address < recipients[j].Address })
+	return recipients, nil
+}
+
+func TestEmailNotifier_DisablesBouncingRecipients(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+	server.refuse = map[string]string{
+		"gone@test.com": "550 5.1.1 No such user",
+		"full@test.com": "452 4.2.2 Mailbox full",
+	}
+
+	store := newMemoryRecipients()
+	alerts := &recordingNotifier{}
+	bounces := NewBounces(store, 2).WithAlerts(alerts)
+	notifier := NewEmailNotifierWithBounces(&config.EmailConfig{
+		SMTPHost:    "127.0.0.1",
+		SMTPPort:    server.port(t),
+		TLSMode:     config.SMTPTLSNone,
+		FromAddress: "from@test.com",
+		ToAddresses: []string{"to@test.com", "Gone@test.com", "full@test.com"},
+	}, bounces)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, notifier.SendNotification(ctx, "hello"), "refused recipients must not fail the send")
+	}
+
+	stats, err := bounces.Stats(ctx)
+	require.NoError(t, err)
+	require.Len(t, stats.Recipients, 3)
+	full, gone, to := stats.Recipients[0], stats.Recipients[1], stats.Recipients[2]
+
+	assert.Equal(t, 3, to.Delivered)
+	assert.False(t, to.Disabled())
+
+	assert.Equal(t, 2, gone.HardBounces, "the third send must skip the disabled recipient")
+	assert.True(t, gone.Disabled())
+	assert.Contains(t, gone.LastError, "No such user")
+
+	assert.Equal(t, 3, full.Failed)
+	assert.Zero(t, full.HardBounces, "temporary failures are not bounces")
+	assert.False(t, full.Disabled())
+
+	assert.Equal(t, 1, stats.Disabled)
+	assert.Equal(t, 3, stats.Delivered)
+	assert.Eventually(t, func() bool {
+		alerts.mu.Lock()
+		defer alerts.mu.Unlock()
+		return len(alerts.messages) == 1
+	}, time.Second, 10*time.Millisecond)
+	alerts.mu.Lock()
+	assert.Contains(t, alerts.messages[0], "Gone@test.com")
+	alerts.mu.Unlock()
+
+	assert.Equal(t, []string{"to@test.com", "full@test.com"},
+		bounces.Active(ctx, []string{"to@test.com", "Gone@test.com", "full@test.com"}))
+	require.NoError(t, bounces.Enable(ctx, "gone@test.com"))
+	assert.Len(t, bounces.Active(ctx, []string{"to@test.com", "Gone@test.com"}), 2)
+}
+
+func TestEmailNotifier_AllRecipientsRefused(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+	server.refuse = map[string]string{"gone@test.com": "550 5.1.1 No such user"}
+
+	store := newMemoryRecipients()
+	notifier := NewEmailNotifierWithBounces(&config.EmailConfig{
+		SMTPHost:    "127.0.0.1",
+		SMTPPort:    server.port(t),
+		TLSMode:     config.SMTPTLSNone,
+		FromAddress: "from@test.com",
+		ToAddresses: []string{"gone@test.com"},
+	}, NewBounces(store, 1))
+
+	ctx := context.Background()
+	err := notifier.SendNotification(ctx, "hello")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "all recipients were refused")
+
+	err = notifier.SendNotification(ctx, "hello")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "disabled")
+}
@@ -1,28 +1,102 @@
 package notify
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
 	"fmt"
-	"net/smtp"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/i18n"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/metrics"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
 )
 
+// emailsTotal counts SMTP deliveries by outcome
+var emailsTotal = metrics.Default.CounterVec("notify_emails_total",
+	"Email notifications sent, by outcome (success or failure).", "outcome")
+
 // EmailNotifier implements the Notifier interface for email notifications
 type EmailNotifier struct {
-	config *config.EmailConfig
+	config  *config.EmailConfig
+	bounces *Bounces
+	logger  *slog.Logger
 }
 
 // NewEmailNotifier creates a new email notifier
 func NewEmailNotifier(cfg *config.EmailConfig) Notifier {
 	return &EmailNotifier{
 		config: cfg,
+		logger: logging.Component(nil, "email"),
+	}
+}
+
+// NewEmailNotifierWithBounces creates an email notifier that skips disabled
+// recipients and records each delivery and bounce in bounces
+func NewEmailNotifierWithBounces(cfg *config.EmailConfig, bounces *Bounces) Notifier {
+	return &EmailNotifier{
+		config:  cfg,
+		bounces: bounces,
+		logger:  logging.Component(nil, "email"),
 	}
 }
 
+// defaultSubject is used for notifications without a subject of their own
+const defaultSubject = "Dropbox Monitor Notification"
+
 // SendNotification sends an email notification
 func (n *EmailNotifier) SendNotification(ctx context.Context, message string) error {
+	return n.Send(ctx, Email{Subject: defaultSubject, Text: message})
+}
+
+// SendReport emails a report. HTML reports are sent with a plain text
+// alternative, and the changes are attached as CSV when configured.
+func (n *EmailNotifier) SendReport(ctx context.Context, report *models.Report, message string) error {
+	if report == nil {
+		return fmt.Errorf("report cannot be nil")
+	}
+	email, err := n.reportEmail(report, message)
+	if err != nil {
+		return err
+	}
+	return n.Send(ctx, email)
+}
+
+// reportEmail builds the email of a report
+func (n *EmailNotifier) reportEmail(report *models.Report, message string) (Email, error) {
+	email := Email{Subject: report.Title, Text: message}
+	if email.Subject == "" {
+		email.Subject = i18n.Lookup(report.Language).T("report.changes_title")
+	}
+	if report.Type == models.HTMLReport {
+		email.HTML = report.Metadata["content"]
+		email.Text = htmlToText(message)
+		email.Attachments = append(email.Attachments, thumbnailAttachments(report, email.HTML)...)
+	}
+
+	if n.config != nil && n.config.AttachCSV && len(report.Changes) > 0 {
+		data, err := changesCSV(report.Changes)
+		if err != nil {
+			return Email{}, fmt.Errorf("failed to build CSV attachment: %w", err)
+		}
+		email.Attachments = append(email.Attachments, Attachment{
+			Filename:    fmt.Sprintf("changes-%s.csv", report.GeneratedAt.Format("20060102-1504")),
+			ContentType: "text/csv",
+			Data:        data,
+		})
+	}
+	return email, nil
+}
+
+// Send sends an email to the configured recipients
+func (n *EmailNotifier) Send(ctx context.Context, email Email) error {
 	if err := ctx.Err(); err != nil {
 		return fmt.Errorf("context cancelled: %w", err)
 	}
@@ -42,31 +116,85 @@ func (n *EmailNotifier) SendNotification(ctx context.Context, message string) er
 		return fmt.Errorf("from email address is required")
 	}
 
-	auth := smtp.PlainAuth("", n.config.SMTPUsername, n.config.SMTPPassword, n.config.SMTPHost)
-
 	// Compose email
 	from := n.config.FromAddress
 	to := n.config.ToAddresses
-	subject := "Dropbox Monitor Notification"
-	body := message
-
-	msg := fmt.Sprintf("From: %s\r\n"+
-		"To: %s\r\n"+
-		"Subject: %s\r\n"+
-		"\r\n"+
-		"%s\r\n", from, strings.Join(to, ", "), subject, body)
+	if n.bounces != nil {
+		if to = n.bounces.Active(ctx, to); len(to) == 0 {
+			return fmt.Errorf("all recipients are disabled after bouncing")
+		}
+	}
+	if email.Subject == "" {
+		email.Subject = defaultSubject
+	}
+	msg, err := buildMessage(from, to, email, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to compose email: %w", err)
+	}
 
 	// Send email
-	err := smtp.SendMail(
-		fmt.Sprintf("%s:%d", n.config.SMTPHost, n.config.SMTPPort),
-		auth,
-		from,
-		to,
-		[]byte(msg),
-	)
+	rejected, err := sendMail(ctx, n.config, from, to, msg)
+	if n.bounces != nil && (err == nil || len(rejected) == len(to)) {
+		n.bounces.record(ctx, to, rejected)
+	}
 	if err != nil {
+		emailsTotal.With("failure").Inc()
 		return fmt.Errorf("failed to send email: %w", err)
 	}
+	for _, r := range rejected {
+		n.logger.Warn("Email not delivered", "error", r)
+	}
 
+	emailsTotal.With("success").Inc()
 	return nil
 }
+
+// thumbnailAttachments returns the thumbnails of changed images that the
+// HTML body shows, as inline attachments in path order
+func thumbnailAttachments(report *models.Report, htmlBody string) []Attachment {
+	paths := make([]string, 0, len(report.Links))
+	for p, link := range report.Links {
+		if len(link.Thumbnail) > 0 {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var attachments []Attachment
+	for _, p := range paths {
+		id := models.ThumbnailContentID(p)
+		// A truncated report may no longer show every thumbnail
+		if !strings.Contains(htmlBody, "cid:"+id) {
+			continue
+		}
+		attachments = append(attachments, Attachment{
+			Filename:    strings.TrimSuffix(path.Base(p), path.Ext(p)) + ".jpg",
+			ContentType: "image/jpeg",
+			Data:        report.Links[p].Thumbnail,
+			ContentID:   id,
+		})
+	}
+	return attachments
+}
+
+// changesCSV renders changes as CSV with a header row
+func changesCSV(changes []models.FileChange) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"path", "directory", "extension", "size", "modified", "deleted", "modified_by", "change_type", "previous_path"})
+	for _, change := range changes {
+		w.Write([]string{
+			change.Path,
+			change.Directory,
+			change.Extension,
+			strconv.FormatInt(change.Size, 10),
+			change.Modified.Format(time.RFC3339),
+			strconv.FormatBool(change.IsDeleted),
+			change.ModifiedBy,
+			string(change.Type()),
+			change.PreviousPath,
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
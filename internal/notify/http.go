@@ -0,0 +1,298 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// defaultHTTPTimeout bounds a single webhook delivery
+const defaultHTTPTimeout = 10 * time.Second
+
+// SlackNotifier posts notifications to a Slack incoming webhook
+type SlackNotifier struct {
+	webhookURL string
+	client     *http.Client
+}
+
+// NewSlackNotifier creates a notifier for a Slack incoming webhook URL
+func NewSlackNotifier(webhookURL string) Notifier {
+	return &SlackNotifier{
+		webhookURL: webhookURL,
+		client:     &http.Client{Timeout: defaultHTTPTimeout},
+	}
+}
+
+// SendNotification posts the message to Slack
+func (n *SlackNotifier) SendNotification(ctx context.Context, message string) error {
+	if n.webhookURL == "" {
+		return fmt.Errorf("slack webhook URL is required")
+	}
+	if err := postJSON(ctx, n.client, n.webhookURL, nil, map[string]string{"text": message}); err != nil {
+		return fmt.Errorf("failed to send slack message: %w", err)
+	}
+	return nil
+}
+
+// WebhookSignatureHeader carries the hex-encoded HMAC-SHA256 of the request
+// body when a webhook secret is configured
+const WebhookSignatureHeader = "X-Monitor-Signature"
+
+const (
+	// DefaultWebhookRetries is how many times a failed webhook delivery is retried
+	DefaultWebhookRetries = 3
+
+	// DefaultWebhookBackoff is the delay before the first retry; it doubles
+	// with each further attempt
+	DefaultWebhookBackoff = time.Second
+)
+
+// WebhookConfig configures a WebhookNotifier
+type WebhookConfig struct {
+	// URLs receive every notification
+	URLs []string
+	// Headers are added to every request, e.g. an authorization token
+	Headers map[string]string
+	// Secret, if set, signs each body in WebhookSignatureHeader
+	Secret string
+	// MaxRetries is how often a failed delivery is retried; negative disables retries
+	MaxRetries int
+	// Backoff is the delay before the first retry
+	Backoff time.Duration
+}
+
+// WebhookNotifier posts notifications as JSON to one or more HTTP endpoints
+type WebhookNotifier struct {
+	config WebhookConfig
+	client *http.Client
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// WebhookPayload is the body sent by WebhookNotifier
+type WebhookPayload struct {
+	// Event is "notification" for plain messages and "report" for reports
+	Event   string         `json:"event"`
+	Message string         `json:"message"`
+	SentAt  time.Time      `json:"sent_at"`
+	Report  *ReportPayload `json:"report,omitempty"`
+}
+
+// ReportPayload describes a report for webhook consumers
+type ReportPayload struct {
+	Type        models.ReportType    `json:"type"`
+	Title       string               `json:"title,omitempty"`
+	Since       time.Time            `json:"since"`
+	Until       time.Time            `json:"until"`
+	GeneratedAt time.Time            `json:"generated_at"`
+	Changes     []models.FileChange  `json:"changes"`
+	Diffs       []models.DiffSummary `json:"diffs,omitempty"`
+	Stats       ReportStats          `json:"stats"`
+}
+
+// ReportStats summarises the changes in a report
+type ReportStats struct {
+	TotalChanges   int            `json:"total_changes"`
+	ExtensionCount map[string]int `json:"extension_count"`
+	FileTypeCount  map[string]int `json:"file_type_count"`
+	DirectoryCount map[string]int `json:"directory_count"`
+}
+
+// NewWebhookNotifier creates a notifier that posts to url with the given
+// extra headers, such as an authorization token
+func NewWebhookNotifier(url string, headers map[string]string) Notifier {
+	return NewWebhookNotifierWithConfig(WebhookConfig{URLs: []string{url}, Headers: headers})
+}
+
+// NewWebhookNotifierWithConfig creates a notifier that posts to every
+// configured URL, signing and retrying deliveries as configured
+func NewWebhookNotifierWithConfig(cfg WebhookConfig) *WebhookNotifier {
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = DefaultWebhookRetries
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultWebhookBackoff
+	}
+	return &WebhookNotifier{
+		config: cfg,
+		client: &http.Client{Timeout: defaultHTTPTimeout},
+		sleep:  sleepContext,
+	}
+}
+
+// SendNotification posts the message to the webhooks
+func (n *WebhookNotifier) SendNotification(ctx context.Context, message string) error {
+	payload := WebhookPayload{Event: "notification", Message: message, SentAt: time.Now().UTC()}
+	if err := n.post(ctx, payload); err != nil {
+		return fmt.Errorf("failed to send webhook notification: %w", err)
+	}
+	return nil
+}
+
+// SendReport posts the report, including its changes and statistics, to the
+// webhooks; message is its text rendering
+func (n *WebhookNotifier) SendReport(ctx context.Context, report *models.Report, message string) error {
+	if report == nil {
+		return fmt.Errorf("report cannot be nil")
+	}
+	payload := WebhookPayload{
+		Event:   "report",
+		Message: message,
+		SentAt:  time.Now().UTC(),
+		Report: &ReportPayload{
+			Type:        report.Type,
+			Title:       report.Title,
+			Since:       report.Since,
+			Until:       report.Until,
+			GeneratedAt: report.GeneratedAt,
+			Changes:     report.Changes,
+			Diffs:       report.Diffs,
+			Stats: ReportStats{
+				TotalChanges:   report.TotalChanges,
+				ExtensionCount: report.ExtensionCount,
+				FileTypeCount:  report.FileTypeCount,
+				DirectoryCount: report.DirectoryCount,
+			},
+		},
+	}
+	if err := n.post(ctx, payload); err != nil {
+		return fmt.Errorf("failed to send webhook report: %w", err)
+	}
+	return nil
+}
+
+// post delivers the payload to every URL, returning the joined failures
+func (n *WebhookNotifier) post(ctx context.Context, payload WebhookPayload) error {
+	var urls []string
+	for _, url := range n.config.URLs {
+		if url != "" {
+			urls = append(urls, url)
+		}
+	}
+	if len(urls) == 0 {
+		return fmt.Errorf("webhook URL is required")
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	headers := make(map[string]string, len(n.config.Headers)+1)
+	for key, value := range n.config.Headers {
+		headers[key] = value
+	}
+	if n.config.Secret != "" {
+		headers[WebhookSignatureHeader] = SignWebhook(data, []byte(n.config.Secret))
+	}
+
+	var errs []error
+	for _, url := range urls {
+		if err := n.postWithRetry(ctx, url, headers, data); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", url, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// postWithRetry posts data to url, retrying transient failures with
+// exponential backoff
+func (n *WebhookNotifier) postWithRetry(ctx context.Context, url string, headers map[string]string, data []byte) error {
+	backoff := n.config.Backoff
+	for attempt := 0; ; attempt++ {
+		err := postBody(ctx, n.client, url, headers, data)
+		if err == nil {
+			return nil
+		}
+		if attempt >= n.config.MaxRetries || !retryable(err) {
+			return err
+		}
+		if err := n.sleep(ctx, backoff); err != nil {
+			return fmt.Errorf("context cancelled: %w", err)
+		}
+		backoff *= 2
+	}
+}
+
+// SignWebhook returns the hex-encoded HMAC-SHA256 of body keyed with secret,
+// as sent in WebhookSignatureHeader
+func SignWebhook(body, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// statusError is a non-2xx webhook response
+type statusError struct {
+	code int
+	body string
+}
+
+// Error implements error
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.code, e.body)
+}
+
+// retryable reports whether a delivery failure may succeed on retry: network
+// errors, rate limiting and server errors are, other client errors are not
+func retryable(err error) bool {
+	var status *statusError
+	if !errors.As(err, &status) {
+		return true
+	}
+	return status.code == http.StatusTooManyRequests || status.code >= 500
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// postJSON posts body as JSON and fails on a non-2xx response
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+	return postBody(ctx, client, url, headers, data)
+}
+
+// postBody posts an encoded JSON body and fails on a non-2xx response
+func postBody(ctx context.Context, client *http.Client, url string, headers map[string]string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &statusError{code: resp.StatusCode, body: string(bytes.TrimSpace(msg))}
+	}
+	return nil
+}
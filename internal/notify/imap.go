@@ -0,0 +1,296 @@
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
+)
+
+// maxBounceMessageBytes skips bounce messages too large to be a report
+const maxBounceMessageBytes = 10 << 20
+
+// BounceMailbox reads the bounce messages delivered to an IMAP mailbox
+type BounceMailbox struct {
+	config  config.BounceMailboxConfig
+	timeout time.Duration
+}
+
+// NewBounceMailbox creates a reader for the configured mailbox; timeout
+// bounds connecting and each exchange with the server
+func NewBounceMailbox(cfg config.BounceMailboxConfig, timeout time.Duration) *BounceMailbox {
+	if timeout <= 0 {
+		timeout = config.DefaultSMTPTimeout
+	}
+	return &BounceMailbox{config: cfg, timeout: timeout}
+}
+
+// Fetch returns the bounces reported by the unread messages in the mailbox
+// and marks those messages as read
+func (m *BounceMailbox) Fetch(ctx context.Context) ([]Bounce, error) {
+	addr := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.GetPort()))
+	dialer := &net.Dialer{Timeout: m.timeout}
+	raw, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	defer raw.Close()
+
+	// Closing the connection unblocks any exchange in progress
+	stop := context.AfterFunc(ctx, func() { raw.Close() })
+	defer stop()
+
+	var conn net.Conn = &timeoutConn{Conn: raw, timeout: m.timeout}
+	if m.config.TLSMode != config.SMTPTLSNone {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: m.config.Host, MinVersion: tls.VersionTLS12})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, fmt.Errorf("TLS handshake failed: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	bounces, err := m.read(newIMAPConn(conn))
+	if err != nil && ctx.Err() != nil {
+		return nil, fmt.Errorf("context cancelled: %w", ctx.Err())
+	}
+	return bounces, err
+}
+
+// read runs the IMAP session on an established connection
+func (m *BounceMailbox) read(c *imapConn) ([]Bounce, error) {
+	greeting, err := c.readLine()
+	if err != nil {
+		return nil, fmt.Errorf("IMAP greeting failed: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		return nil, fmt.Errorf("unexpected IMAP greeting %q", greeting)
+	}
+
+	if _, err := c.command("LOGIN %s %s", imapQuote(m.config.Username), imapQuote(m.config.Password)); err != nil {
+		return nil, fmt.Errorf("IMAP login failed: %w", err)
+	}
+	if _, err := c.command("SELECT %s", imapQuote(m.config.GetFolder())); err != nil {
+		return nil, fmt.Errorf("failed to select %s: %w", m.config.GetFolder(), err)
+	}
+
+	responses, err := c.command("UID SEARCH UNSEEN")
+	if err != nil {
+		return nil, fmt.Errorf("IMAP search failed: %w", err)
+	}
+	var uids []string
+	for _, response := range responses {
+		if rest, ok := strings.CutPrefix(response.text, "* SEARCH"); ok {
+			uids = append(uids, strings.Fields(rest)...)
+		}
+	}
+
+	var bounces []Bounce
+	for _, uid := range uids {
+		responses, err := c.command("UID FETCH %s BODY.PEEK[]", uid)
+		if err != nil {
+			return bounces, fmt.Errorf("failed to fetch message %s: %w", uid, err)
+		}
+		for _, response := range responses {
+			for _, literal := range response.literals {
+				bounces = append(bounces, parseBounces(literal)...)
+			}
+		}
+		if _, err := c.command("UID STORE %s +FLAGS.SILENT (\\Seen)", uid); err != nil {
+			return bounces, fmt.Errorf("failed to mark message %s as read: %w", uid, err)
+		}
+	}
+
+	c.command("LOGOUT")
+	return bounces, nil
+}
+
+// imapResponse is an untagged server response with the literals it carried
+type imapResponse struct {
+	text     string
+	literals [][]byte
+}
+
+// imapConn sends tagged IMAP commands and collects their responses
+type imapConn struct {
+	r   *bufio.Reader
+	w   io.Writer
+	tag int
+}
+
+// newIMAPConn wraps an established connection
+func newIMAPConn(conn io.ReadWriter) *imapConn {
+	return &imapConn{r: bufio.NewReader(conn), w: conn}
+}
+
+// command sends a command and returns its untagged responses, failing
+// unless the server completes it with OK
+func (c *imapConn) command(format string, args ...interface{}) ([]imapResponse, error) {
+	c.tag++
+	tag := fmt.Sprintf("a%d", c.tag)
+	if _, err := fmt.Fprintf(c.w, "%s %s\r\n", tag, fmt.Sprintf(format, args...)); err != nil {
+		return nil, err
+	}
+
+	var responses []imapResponse
+	for {
+		response, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		if status, ok := strings.CutPrefix(response.text, tag+" "); ok {
+			if !strings.HasPrefix(status, "OK") {
+				return nil, fmt.Errorf("server replied %s", status)
+			}
+			return responses, nil
+		}
+		responses = append(responses, response)
+	}
+}
+
+// readResponse reads one response line, following the literals it announces
+// with a trailing {size}
+func (c *imapConn) readResponse() (imapResponse, error) {
+	var response imapResponse
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return response, err
+		}
+		response.text += line
+
+		size, ok := literalSize(line)
+		if !ok {
+			return response, nil
+		}
+		if size > maxBounceMessageBytes {
+			if _, err := io.CopyN(io.Discard, c.r, int64(size)); err != nil {
+				return response, err
+			}
+			continue
+		}
+		literal := make([]byte, size)
+		if _, err := io.ReadFull(c.r, literal); err != nil {
+			return response, err
+		}
+		response.literals = append(response.literals, literal)
+	}
+}
+
+// readLine reads a line without its line ending
+func (c *imapConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// literalSize returns the size of the literal announced at the end of line
+func literalSize(line string) (int, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	open := strings.LastIndexByte(line, '{')
+	if open < 0 {
+		return 0, false
+	}
+	size, err := strconv.Atoi(line[open+1 : len(line)-1])
+	return size, err == nil && size >= 0
+}
+
+// imapQuote quotes s as an IMAP string
+func imapQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// parseBounces returns the failed recipients of a bounce message. Standard
+// delivery status notifications are read recipient by recipient; other
+// bounces are recognised by their X-Failed-Recipients header.
+func parseBounces(raw []byte) []Bounce {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err == nil && mediaType == "multipart/report" && params["boundary"] != "" {
+		var bounces []Bounce
+		parts := multipart.NewReader(msg.Body, params["boundary"])
+		for {
+			part, err := parts.NextPart()
+			if err != nil {
+				break
+			}
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			if partType == "message/delivery-status" || partType == "message/global-delivery-status" {
+				bounces = append(bounces, parseDeliveryStatus(part)...)
+			}
+		}
+		if len(bounces) > 0 {
+			return bounces
+		}
+	}
+
+	var bounces []Bounce
+	reason := msg.Header.Get("Subject")
+	for _, address := range strings.Split(msg.Header.Get("X-Failed-Recipients"), ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			bounces = append(bounces, Bounce{Address: address, Hard: true, Reason: reason})
+		}
+	}
+	return bounces
+}
+
+// parseDeliveryStatus reads the per-recipient fields of a delivery status
+// report (RFC 3464) and returns the recipients whose delivery failed
+func parseDeliveryStatus(r io.Reader) []Bounce {
+	tp := textproto.NewReader(bufio.NewReader(r))
+
+	// The first block describes the message, the rest one recipient each
+	if _, err := tp.ReadMIMEHeader(); err != nil {
+		return nil
+	}
+
+	var bounces []Bounce
+	for {
+		fields, err := tp.ReadMIMEHeader()
+		if len(fields) > 0 && strings.EqualFold(fields.Get("Action"), "failed") {
+			recipient := fields.Get("Final-Recipient")
+			if recipient == "" {
+				recipient = fields.Get("Original-Recipient")
+			}
+			// Recipients are typed, as in "rfc822; user@example.com"
+			if _, address, ok := strings.Cut(recipient, ";"); ok {
+				recipient = address
+			}
+			recipient = strings.Trim(strings.TrimSpace(recipient), "<>")
+
+			reason := fields.Get("Diagnostic-Code")
+			if reason == "" {
+				reason = "status " + fields.Get("Status")
+			}
+			if recipient != "" {
+				bounces = append(bounces, Bounce{
+					Address: recipient,
+					Hard:    strings.HasPrefix(strings.TrimSpace(fields.Get("Status")), "5"),
+					Reason:  reason,
+				})
+			}
+		}
+		if err != nil {
+			return bounces
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDSN = "From: MAILER-DAEMON@test.com\r\n" +
+	"To: from@test.com\r\n" +
+	"Subject: Undelivered Mail Returned to Sender\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/report; report-type=delivery-status; boundary=\"b1\"\r\n" +
+	"\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"Your message could not be delivered.\r\n" +
+	"--b1\r\n" +
+	"Content-Type: message/delivery-status\r\n" +
+	"\r\n" +
+	"Reporting-MTA: dns; mx.test.com\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; gone@test.com\r\n" +
+	"Action: failed\r\n" +
+	"Status: 5.1.1\r\n" +
+	"Diagnostic-Code: smtp; 550 5.1.1 No such user\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; slow@test.com\r\n" +
+	"Action: delayed\r\n" +
+	"Status: 4.4.1\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822;<full@test.com>\r\n" +
+	"Action: failed\r\n" +
+	"Status: 4.2.2\r\n" +
+	"--b1--\r\n"
+
+func TestParseBounces(t *testing.T) {
+	assert.Equal(t, []Bounce{
+		{Address: "gone@test.com", Hard: true, Reason: "smtp; 550 5.1.1 No such user"},
+		{Address: "full@test.com", Hard: false, Reason: "status 4.2.2"},
+	}, parseBounces([]byte(testDSN)))
+
+	exim := "From: Mail Delivery System <Mailer-Daemon@test.com>\r\n" +
+		"X-Failed-Recipients: a@test.com, b@test.com\r\n" +
+		"Subject: Mail delivery failed\r\n\r\nbody\r\n"
+	assert.Equal(t, []Bounce{
+		{Address: "a@test.com", Hard: true, Reason: "Mail delivery failed"},
+		{Address: "b@test.com", Hard: true, Reason: "Mail delivery failed"},
+	}, parseBounces([]byte(exim)))
+
+	assert.Empty(t, parseBounces([]byte("Subject: hello\r\n\r\nnot a bounce\r\n")))
+}
+
+// serveMockIMAP answers one IMAP session with the unread messages and
+// records the commands it receives
+func serveMockIMAP(t *testing.T, messages map[string]string) (int, <-chan []string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	commands := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var received []string
+		defer func() { commands <- received }()
+		fmt.Fprint(conn, "* OK IMAP4rev1 ready\r\n")
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			tag, cmd, _ := strings.Cut(strings.TrimSpace(line), " ")
+			received = append(received, cmd)
+			switch {
+			case strings.HasPrefix(cmd, "LOGIN") && !strings.Contains(cmd, `"secret"`):
+				fmt.Fprintf(conn, "%s NO bad credentials\r\n", tag)
+				continue
+			case strings.HasPrefix(cmd, "UID SEARCH"):
+				var uids []string
+				for uid := range messages {
+					uids = append(uids, uid)
+				}
+				fmt.Fprintf(conn, "* SEARCH %s\r\n", strings.Join(uids, " "))
+			case strings.HasPrefix(cmd, "UID FETCH"):
+				uid := strings.Fields(cmd)[2]
+				msg := messages[uid]
+				fmt.Fprintf(conn, "* 1 FETCH (UID %s BODY[] {%d}\r\n%s)\r\n", uid, len(msg), msg)
+			case cmd == "LOGOUT":
+				fmt.Fprintf(conn, "* BYE\r\n%s OK done\r\n", tag)
+				return
+			}
+			fmt.Fprintf(conn, "%s OK done\r\n", tag)
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, commands
+}
+
+func TestBounceMailbox_Fetch(t *testing.T) {
+	port, commands := serveMockIMAP(t, map[string]string{"7": testDSN})
+	mailbox := NewBounceMailbox(config.BounceMailboxConfig{
+		Host:     "127.0.0.1",
+		Port:     port,
+		Username: "bounces",
+		Password: "secret",
+		TLSMode:  config.SMTPTLSNone,
+	}, time.Second)
+
+	store := newMemoryRecipients()
+	bounces := NewBounces(store, 1).WithMailbox(mailbox)
+	require.NoError(t, bounces.CheckMailbox(context.Background()))
+
+	assert.Equal(t, []string{
+		`LOGIN "bounces" "secret"`,
+		`SELECT "INBOX"`,
+		"UID SEARCH UNSEEN",
+		"UID FETCH 7 BODY.PEEK[]",
+		`UID STORE 7 +FLAGS.SILENT (\Seen)`,
+		"LOGOUT",
+	}, <-commands)
+
+	stats, err := bounces.Stats(context.Background())
+	require.NoError(t, err)
+	require.Len(t, stats.Recipients, 2)
+	assert.True(t, stats.Recipients[1].Disabled(), "gone@test.com bounced hard")
+	assert.False(t, stats.Recipients[0].Disabled(), "full@test.com failed temporarily")
+}
+
+func TestBounceMailbox_LoginFailure(t *testing.T) {
+	port, _ := serveMockIMAP(t, nil)
+	mailbox := NewBounceMailbox(config.BounceMailboxConfig{
+		Host:     "127.0.0.1",
+		Port:     port,
+		Username: "bounces",
+		Password: "wrong",
+		TLSMode:  config.SMTPTLSNone,
+	}, time.Second)
+
+	_, err := mailbox.Fetch(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bad credentials")
+}
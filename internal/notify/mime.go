@@ -0,0 +1,260 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Email is a message with a plain text body, an optional HTML alternative
+// and optional attachments
+type Email struct {
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []Attachment
+}
+
+// Attachment is a file attached to an email
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+	// ContentID, if set, shows the attachment inline in the HTML body,
+	// which refers to it as cid:ContentID
+	ContentID string
+}
+
+// buildMessage renders the email as a MIME message with From, To, Date and
+// Message-ID headers. A text-only email without attachments is sent as a
+// single text/plain part; HTML adds a multipart/alternative body, inline
+// attachments are related to the HTML part and other attachments wrap the
+// body in multipart/mixed.
+func buildMessage(from string, to []string, email Email, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+	header("From", from)
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", email.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", newMessageID(from))
+	header("MIME-Version", "1.0")
+
+	if email.HTML == "" && len(email.Attachments) == 0 {
+		header("Content-Type", `text/plain; charset="utf-8"`)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, email.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	inline, attached := splitAttachments(email)
+	if len(attached) == 0 {
+		body, boundary, err := alternativeBody(email, inline)
+		if err != nil {
+			return nil, err
+		}
+		header("Content-Type", "multipart/alternative; boundary="+boundary)
+		buf.WriteString("\r\n")
+		buf.Write(body)
+		return buf.Bytes(), nil
+	}
+
+	mixed := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/mixed; boundary="+mixed.Boundary())
+	buf.WriteString("\r\n")
+
+	if email.HTML != "" {
+		body, boundary, err := alternativeBody(email, inline)
+		if err != nil {
+			return nil, err
+		}
+		part, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {"multipart/alternative; boundary=" + boundary},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(body); err != nil {
+			return nil, err
+		}
+	} else if err := writeTextPart(mixed, "text/plain", email.Text); err != nil {
+		return nil, err
+	}
+
+	for _, attachment := range attached {
+		if err := writeAttachment(mixed, attachment); err != nil {
+			return nil, err
+		}
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// splitAttachments separates the attachments shown inline in the HTML body
+// from the others; without an HTML body every attachment is a plain one
+func splitAttachments(email Email) (inline, attached []Attachment) {
+	for _, attachment := range email.Attachments {
+		if attachment.ContentID != "" && email.HTML != "" {
+			inline = append(inline, attachment)
+		} else {
+			attached = append(attached, attachment)
+		}
+	}
+	return inline, attached
+}
+
+// alternativeBody renders the text and HTML alternatives of the email,
+// returning the body and its boundary. Inline attachments are added beside
+// the HTML part in a multipart/related part.
+func alternativeBody(email Email, inline []Attachment) ([]byte, string, error) {
+	var buf bytes.Buffer
+	alternative := multipart.NewWriter(&buf)
+	if err := writeTextPart(alternative, "text/plain", email.Text); err != nil {
+		return nil, "", err
+	}
+	if len(inline) == 0 {
+		if err := writeTextPart(alternative, "text/html", email.HTML); err != nil {
+			return nil, "", err
+		}
+	} else if err := writeRelatedPart(alternative, email.HTML, inline); err != nil {
+		return nil, "", err
+	}
+	if err := alternative.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), alternative.Boundary(), nil
+}
+
+// writeRelatedPart adds a multipart/related part holding the HTML and the
+// attachments it shows inline
+func writeRelatedPart(w *multipart.Writer, htmlBody string, inline []Attachment) error {
+	var buf bytes.Buffer
+	related := multipart.NewWriter(&buf)
+	if err := writeTextPart(related, "text/html", htmlBody); err != nil {
+		return err
+	}
+	for _, attachment := range inline {
+		if err := writeAttachment(related, attachment); err != nil {
+			return err
+		}
+	}
+	if err := related.Close(); err != nil {
+		return err
+	}
+
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {`multipart/related; type="text/html"; boundary=` + related.Boundary()},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(buf.Bytes())
+	return err
+}
+
+// writeTextPart adds a quoted-printable UTF-8 text part
+func writeTextPart(w *multipart.Writer, contentType, text string) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType + `; charset="utf-8"`},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	return writeQuotedPrintable(part, text)
+}
+
+// writeAttachment adds a base64-encoded attachment part, shown inline when
+// it has a content ID
+func writeAttachment(w *multipart.Writer, attachment Attachment) error {
+	contentType := attachment.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	disposition := "attachment"
+	header := textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"name": attachment.Filename})},
+		"Content-Transfer-Encoding": {"base64"},
+	}
+	if attachment.ContentID != "" {
+		disposition = "inline"
+		header.Set("Content-ID", "<"+attachment.ContentID+">")
+	}
+	header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	// Wrap base64 at 76 characters as RFC 2045 requires
+	encoded := base64.StdEncoding.EncodeToString(attachment.Data)
+	for len(encoded) > 76 {
+		if _, err := fmt.Fprintf(part, "%s\r\n", encoded[:76]); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err = fmt.Fprintf(part, "%s\r\n", encoded)
+	return err
+}
+
+// writeQuotedPrintable writes text with CRLF line endings in quoted-printable encoding
+func writeQuotedPrintable(w io.Writer, text string) error {
+	qp := quotedprintable.NewWriter(w)
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := qp.Write([]byte(text)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// newMessageID returns a unique Message-ID in the sender's domain
+func newMessageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = strings.Trim(from[at+1:], "> ")
+	}
+	var id [16]byte
+	rand.Read(id[:])
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(id[:]), domain)
+}
+
+var (
+	htmlHidden    = regexp.MustCompile(`(?is)<(style|script|head)[^>]*>.*?</(style|script|head)>`)
+	htmlBreaks    = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|h[1-6]|li|tr|table|ul|ol)>`)
+	htmlCells     = regexp.MustCompile(`(?i)</t[dh]>`)
+	htmlTags      = regexp.MustCompile(`<[^>]*>`)
+	htmlBlankRuns = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText renders HTML as readable plain text for the text alternative
+func htmlToText(s string) string {
+	s = htmlHidden.ReplaceAllString(s, "")
+	s = htmlBreaks.ReplaceAllString(s, "\n")
+	s = htmlCells.ReplaceAllString(s, "\t")
+	s = html.UnescapeString(htmlTags.ReplaceAllString(s, ""))
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.TrimSpace(htmlBlankRuns.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
@@ -0,0 +1,190 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var mimeDate = time.Date(2025, 2, 12, 9, 30, 0, 0, time.UTC)
+
+// parseMessage parses a built message and returns it with its media type
+func parseMessage(t *testing.T, data []byte) (*mail.Message, string, map[string]string) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	require.NoError(t, err)
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	return msg, mediaType, params
+}
+
+// readParts returns the decoded parts of a multipart body keyed by content type
+func readParts(t *testing.T, body io.Reader, boundary string) map[string]*multipart.Part {
+	parts := make(map[string]*multipart.Part)
+	reader := multipart.NewReader(body, boundary)
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			return parts
+		}
+		require.NoError(t, err)
+		mediaType, _, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		require.NoError(t, err)
+		data, err := io.ReadAll(part)
+		require.NoError(t, err)
+		part.Header.Set("X-Test-Body", string(data))
+		parts[mediaType] = part
+	}
+}
+
+func decodeQP(t *testing.T, s string) string {
+	data, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(s)))
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestBuildMessage_PlainText(t *testing.T) {
+	data, err := buildMessage("Monitor <monitor@example.com>", []string{"a@example.com", "b@example.com"},
+		Email{Subject: "Änderungen", Text: "line one\nline two"}, mimeDate)
+	require.NoError(t, err)
+
+	msg, mediaType, _ := parseMessage(t, data)
+	assert.Equal(t, "text/plain", mediaType)
+	assert.Equal(t, "Monitor <monitor@example.com>", msg.Header.Get("From"))
+	assert.Equal(t, "a@example.com, b@example.com", msg.Header.Get("To"))
+	assert.Equal(t, "Wed, 12 Feb 2025 09:30:00 +0000", msg.Header.Get("Date"))
+	assert.Regexp(t, `^<\d+\.[0-9a-f]{32}@example\.com>$`, msg.Header.Get("Message-ID"))
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Änderungen", subject)
+
+	body, err := io.ReadAll(msg.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "line one\r\nline two", decodeQP(t, string(body)))
+}
+
+func TestBuildMessage_HTMLWithAttachment(t *testing.T) {
+	email := Email{
+		Subject:     "Report",
+		Text:        "Report text",
+		HTML:        "<h1>Report</h1>",
+		Attachments: []Attachment{{Filename: "changes.csv", ContentType: "text/csv", Data: []byte("path\n/a.txt\n")}},
+	}
+	data, err := buildMessage("monitor@example.com", []string{"a@example.com"}, email, mimeDate)
+	require.NoError(t, err)
+
+	msg, mediaType, params := parseMessage(t, data)
+	require.Equal(t, "multipart/mixed", mediaType)
+	parts := readParts(t, msg.Body, params["boundary"])
+	require.Contains(t, parts, "multipart/alternative")
+	require.Contains(t, parts, "text/csv")
+
+	csvPart := parts["text/csv"]
+	assert.Equal(t, "changes.csv", csvPart.FileName())
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(csvPart.Header.Get("X-Test-Body"), "\r\n", ""))
+	require.NoError(t, err)
+	assert.Equal(t, "path\n/a.txt\n", string(decoded))
+
+	_, altParams, err := mime.ParseMediaType(parts["multipart/alternative"].Header.Get("Content-Type"))
+	require.NoError(t, err)
+	alternatives := readParts(t, strings.NewReader(parts["multipart/alternative"].Header.Get("X-Test-Body")), altParams["boundary"])
+	assert.Equal(t, "Report text", decodeQP(t, alternatives["text/plain"].Header.Get("X-Test-Body")))
+	assert.Equal(t, "<h1>Report</h1>", decodeQP(t, alternatives["text/html"].Header.Get("X-Test-Body")))
+}
+
+func TestBuildMessage_HTMLOnly(t *testing.T) {
+	data, err := buildMessage("monitor@example.com", []string{"a@example.com"}, Email{Text: "t", HTML: "<p>h</p>"}, mimeDate)
+	require.NoError(t, err)
+
+	msg, mediaType, params := parseMessage(t, data)
+	require.Equal(t, "multipart/alternative", mediaType)
+	parts := readParts(t, msg.Body, params["boundary"])
+	assert.Len(t, parts, 2)
+}
+
+func TestBuildMessage_InlineImages(t *testing.T) {
+	email := Email{
+		Text: "t",
+		HTML: `<img src="cid:thumb@example">`,
+		Attachments: []Attachment{
+			{Filename: "a.jpg", ContentType: "image/jpeg", Data: []byte("jpeg"), ContentID: "thumb@example"},
+		},
+	}
+	data, err := buildMessage("monitor@example.com", []string{"a@example.com"}, email, mimeDate)
+	require.NoError(t, err)
+
+	// Inline images alone do not make the message multipart/mixed
+	msg, mediaType, params := parseMessage(t, data)
+	require.Equal(t, "multipart/alternative", mediaType)
+	alternatives := readParts(t, msg.Body, params["boundary"])
+	require.Contains(t, alternatives, "multipart/related")
+
+	_, relatedParams, err := mime.ParseMediaType(alternatives["multipart/related"].Header.Get("Content-Type"))
+	require.NoError(t, err)
+	related := readParts(t, strings.NewReader(alternatives["multipart/related"].Header.Get("X-Test-Body")), relatedParams["boundary"])
+	assert.Equal(t, `<img src="cid:thumb@example">`, decodeQP(t, related["text/html"].Header.Get("X-Test-Body")))
+	require.Contains(t, related, "image/jpeg")
+	assert.Equal(t, "<thumb@example>", related["image/jpeg"].Header.Get("Content-ID"))
+	assert.True(t, strings.HasPrefix(related["image/jpeg"].Header.Get("Content-Disposition"), "inline"))
+}
+
+func TestThumbnailAttachments(t *testing.T) {
+	report := models.NewReport(models.HTMLReport)
+	report.Links = map[string]models.FileLink{
+		"/photos/b.png": {URL: "https://db.tt/b", Thumbnail: []byte("b")},
+		"/photos/a.JPG": {URL: "https://db.tt/a", Thumbnail: []byte("a")},
+		"/photos/c.gif": {URL: "https://db.tt/c", Thumbnail: []byte("c")},
+		"/docs/d.txt":   {URL: "https://db.tt/d"},
+	}
+	body := `<img src="cid:` + models.ThumbnailContentID("/photos/b.png") + `"><img src="cid:` +
+		models.ThumbnailContentID("/photos/a.JPG") + `">`
+
+	attachments := thumbnailAttachments(report, body)
+	require.Len(t, attachments, 2)
+	assert.Equal(t, "a.jpg", attachments[0].Filename)
+	assert.Equal(t, models.ThumbnailContentID("/photos/a.JPG"), attachments[0].ContentID)
+	assert.Equal(t, "b.jpg", attachments[1].Filename)
+	assert.Equal(t, "image/jpeg", attachments[1].ContentType)
+	assert.Equal(t, []byte("b"), attachments[1].Data)
+}
+
+func TestHTMLToText(t *testing.T) {
+	input := `<html><head><style>h1 { color: red; }</style></head><body>
+<h1>Changes &amp; more</h1><p>Two   files</p>
+<table><tr><td>/a.txt</td><td>1 KB</td></tr></table>
+</body></html>`
+	assert.Equal(t, "Changes & more\nTwo files\n\n/a.txt 1 KB", htmlToText(input))
+}
+
+func TestChangesCSV(t *testing.T) {
+	data, err := changesCSV([]models.FileChange{{
+		Path:      "/docs/a, b.txt",
+		Directory: "/docs",
+		Extension: ".txt",
+		Size:      42,
+		Modified:  mimeDate,
+	}, {
+		Path:         "/archive/c.txt",
+		Directory:    "/archive",
+		Extension:    ".txt",
+		Size:         7,
+		Modified:     mimeDate,
+		ChangeType:   models.ChangeRenamed,
+		PreviousPath: "/docs/c.txt",
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, "path,directory,extension,size,modified,deleted,modified_by,change_type,previous_path\n"+
+		"\"/docs/a, b.txt\",/docs,.txt,42,2025-02-12T09:30:00Z,false,,modified,\n"+
+		"/archive/c.txt,/archive,.txt,7,2025-02-12T09:30:00Z,false,,renamed,/docs/c.txt\n", string(data))
+}
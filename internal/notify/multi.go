@@ -0,0 +1,289 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// Channel is a named notifier that can be switched on and off
+type Channel struct {
+	Name     string
+	Notifier Notifier
+	Enabled  bool
+}
+
+// ChannelError is a failure to deliver through one channel
+type ChannelError struct {
+	Channel string
+	Err     error
+}
+
+// Error implements error
+func (e *ChannelError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Channel, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ChannelError) Unwrap() error {
+	return e.Err
+}
+
+// DispatchError aggregates the channel failures of one notification
+type DispatchError struct {
+	Failures  []*ChannelError
+	Attempted int
+}
+
+// Error implements error
+func (e *DispatchError) Error() string {
+	msgs := make([]string, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		msgs = append(msgs, failure.Error())
+	}
+	return fmt.Sprintf("notification failed on %d of %d channels: %s", len(e.Failures), e.Attempted, strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the channel errors
+func (e *DispatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		errs = append(errs, failure)
+	}
+	return errs
+}
+
+// MultiNotifier fans each notification out to every enabled channel. A
+// failing channel does not stop delivery through the others. With a queue,
+// failed deliveries are retried later in their original order.
+type MultiNotifier struct {
+	mu       sync.RWMutex
+	channels []Channel
+	queue    Queue
+	archive  DeliveryArchive
+	policy   RetryPolicy
+	locks    map[string]*sync.Mutex
+	now      func() time.Time
+	logger   *slog.Logger
+	// build creates the channels for a configuration; it is set for
+	// notifiers created from configuration, which support Reload
+	build func(cfg *config.Config) []Channel
+}
+
+// NewMultiNotifier creates a notifier that dispatches to the given channels
+func NewMultiNotifier(channels ...Channel) *MultiNotifier {
+	locks := make(map[string]*sync.Mutex, len(channels))
+	for _, channel := range channels {
+		locks[channel.Name] = &sync.Mutex{}
+	}
+	return &MultiNotifier{
+		channels: append([]Channel(nil), channels...),
+		locks:    locks,
+		now:      time.Now,
+		logger:   logging.Component(nil, "notify"),
+	}
+}
+
+// NewMultiNotifierFromConfig creates a notifier for the channels configured
+// in cfg. Disabled channels are still registered so they can be switched on
+// later. Email deliveries are recorded in bounces when set.
+func NewMultiNotifierFromConfig(cfg *config.Config, bounces *Bounces) *MultiNotifier {
+	return NewMultiNotifierForAudience(cfg, config.AudienceRealtime, bounces)
+}
+
+// NewMultiNotifierForAudience creates a notifier like
+// NewMultiNotifierFromConfig that emails the recipients of one audience
+func NewMultiNotifierForAudience(cfg *config.Config, audience config.Audience, bounces *Bounces) *MultiNotifier {
+	build := func(cfg *config.Config) []Channel {
+		channels := cfg.Notify.Channels
+		var emailConfig *config.EmailConfig
+		if cfg.EmailConfig != nil {
+			audienceConfig := *cfg.EmailConfig
+			audienceConfig.ToAddresses = cfg.EmailRecipients(audience)
+			emailConfig = &audienceConfig
+		}
+		return []Channel{
+			{
+				Name:     "email",
+				Notifier: NewEmailNotifierWithBounces(emailConfig, bounces),
+				Enabled:  channels.Email.IsEnabled(),
+			},
+			{
+				Name:     "slack",
+				Notifier: NewSlackNotifier(channels.Slack.WebhookURL),
+				Enabled:  channels.Slack.Enabled,
+			},
+			{
+				Name: "webhook",
+				Notifier: NewWebhookNotifierWithConfig(WebhookConfig{
+					URLs:       channels.Webhook.Endpoints(),
+					Headers:    channels.Webhook.Headers,
+					Secret:     channels.Webhook.Secret,
+					MaxRetries: channels.Webhook.MaxRetries,
+					Backoff:    channels.Webhook.RetryBackoff,
+				}),
+				Enabled: channels.Webhook.Enabled,
+			},
+		}
+	}
+
+	m := NewMultiNotifier(build(cfg)...)
+	m.build = build
+	m.policy = retryPolicy(cfg.Notify)
+	return m
+}
+
+// NewAnomalyNotifier creates a notifier for the anomaly alerts, sending
+// through their own channel configured under anomaly.alerts rather than the
+// regular notification channels. Alerts are sent at once and never queued
+// behind undelivered notifications.
+func NewAnomalyNotifier(cfg *config.Config, bounces *Bounces) *MultiNotifier {
+	alerts := cfg.Anomaly.Alerts
+	var emailConfig *config.EmailConfig
+	if cfg.EmailConfig != nil {
+		alertConfig := *cfg.EmailConfig
+		alertConfig.ToAddresses = alerts.Email
+		emailConfig = &alertConfig
+	}
+	return NewMultiNotifier(
+		Channel{
+			Name:     "email",
+			Notifier: NewEmailNotifierWithBounces(emailConfig, bounces),
+			Enabled:  emailConfig != nil && len(alerts.Email) > 0,
+		},
+		Channel{
+			Name:     "slack",
+			Notifier: NewSlackNotifier(alerts.SlackWebhookURL),
+			Enabled:  alerts.SlackWebhookURL != "",
+		},
+		Channel{
+			Name: "webhook",
+			Notifier: NewWebhookNotifierWithConfig(WebhookConfig{
+				URLs:    []string{alerts.WebhookURL},
+				Headers: alerts.Headers,
+				Secret:  alerts.Secret,
+			}),
+			Enabled: alerts.WebhookURL != "",
+		},
+	)
+}
+
+// Reload replaces the channels of a notifier created from configuration with
+// those configured in cfg, a *config.Config. Queued notifications are kept
+// and retried through the new channels. Notifiers created from explicit
+// channels are left unchanged.
+func (m *MultiNotifier) Reload(ctx context.Context, cfg interface{}) error {
+	newConfig, ok := cfg.(*config.Config)
+	if !ok {
+		return fmt.Errorf("unexpected configuration type %T", cfg)
+	}
+	if m.build == nil {
+		return nil
+	}
+
+	channels := m.build(newConfig)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.channels = channels
+	m.policy = retryPolicy(newConfig.Notify)
+	return nil
+}
+
+// SendNotification sends the message through every enabled channel
+// concurrently, returning a *DispatchError if any of them failed. With a
+// queue, failures that were queued for retry are not reported.
+func (m *MultiNotifier) SendNotification(ctx context.Context, message string) error {
+	return m.dispatch(ctx, nil, message, nil)
+}
+
+// SendReport sends a report like SendNotification. Channels implementing
+// ReportNotifier receive the report itself, the others its text rendering in
+// message. Queued retries keep the report.
+func (m *MultiNotifier) SendReport(ctx context.Context, report *models.Report, message string) error {
+	return m.dispatch(ctx, nil, message, report)
+}
+
+// SendReportTo sends a report like SendReport through the named channels
+// only; channels that are disabled are skipped
+func (m *MultiNotifier) SendReportTo(ctx context.Context, channels []string, report *models.Report, message string) error {
+	if len(channels) == 0 {
+		return nil
+	}
+	return m.dispatch(ctx, channels, message, report)
+}
+
+// dispatch delivers to every enabled channel concurrently, limited to the
+// named channels when names is set
+func (m *MultiNotifier) dispatch(ctx context.Context, names []string, message string, report *models.Report) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context cancelled: %w", err)
+	}
+
+	m.mu.RLock()
+	var enabled []Channel
+	for _, channel := range m.channels {
+		if channel.Enabled && channel.Notifier != nil && (names == nil || slices.Contains(names, channel.Name)) {
+			enabled = append(enabled, channel)
+		}
+	}
+	m.mu.RUnlock()
+
+	errs := make([]error, len(enabled))
+	var wg sync.WaitGroup
+	for i, channel := range enabled {
+		wg.Add(1)
+		go func(i int, channel Channel) {
+			defer wg.Done()
+			errs[i] = m.deliver(ctx, channel, message, report)
+		}(i, channel)
+	}
+	wg.Wait()
+
+	dispatchErr := &DispatchError{Attempted: len(enabled)}
+	for i, err := range errs {
+		if err != nil {
+			dispatchErr.Failures = append(dispatchErr.Failures, &ChannelError{Channel: enabled[i].Name, Err: err})
+		}
+	}
+	if len(dispatchErr.Failures) > 0 {
+		return dispatchErr
+	}
+	return nil
+}
+
+// send delivers directly through one channel, as a report where supported
+func send(ctx context.Context, channel Channel, message string, report *models.Report) error {
+	if rn, ok := channel.Notifier.(ReportNotifier); ok && report != nil {
+		return rn.SendReport(ctx, report, message)
+	}
+	return channel.Notifier.SendNotification(ctx, message)
+}
+
+// Channels returns the configured channels
+func (m *MultiNotifier) Channels() []Channel {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Channel(nil), m.channels...)
+}
+
+// SetEnabled switches a channel on or off
+func (m *MultiNotifier) SetEnabled(name string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.channels {
+		if m.channels[i].Name == name {
+			m.channels[i].Enabled = enabled
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown notification channel %q", name)
+}
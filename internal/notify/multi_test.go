@@ -0,0 +1,288 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingNotifier records messages and optionally fails
+type recordingNotifier struct {
+	mu       sync.Mutex
+	messages []string
+	err      error
+}
+
+func (n *recordingNotifier) SendNotification(ctx context.Context, message string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.messages = append(n.messages, message)
+	return n.err
+}
+
+func TestMultiNotifier_FansOut(t *testing.T) {
+	email, slack, off := &recordingNotifier{}, &recordingNotifier{}, &recordingNotifier{}
+	m := NewMultiNotifier(
+		Channel{Name: "email", Notifier: email, Enabled: true},
+		Channel{Name: "slack", Notifier: slack, Enabled: true},
+		Channel{Name: "webhook", Notifier: off},
+	)
+
+	require.NoError(t, m.SendNotification(context.Background(), "hello"))
+	assert.Equal(t, []string{"hello"}, email.messages)
+	assert.Equal(t, []string{"hello"}, slack.messages)
+	assert.Empty(t, off.messages)
+
+	require.NoError(t, m.SetEnabled("webhook", true))
+	require.NoError(t, m.SendNotification(context.Background(), "again"))
+	assert.Equal(t, []string{"again"}, off.messages)
+	assert.Error(t, m.SetEnabled("pager", true))
+}
+
+func TestMultiNotifier_IsolatesFailures(t *testing.T) {
+	boom := errors.New("smtp down")
+	email, slack := &recordingNotifier{err: boom}, &recordingNotifier{}
+	m := NewMultiNotifier(
+		Channel{Name: "email", Notifier: email, Enabled: true},
+		Channel{Name: "slack", Notifier: slack, Enabled: true},
+	)
+
+	err := m.SendNotification(context.Background(), "hello")
+	require.Error(t, err)
+	assert.Equal(t, []string{"hello"}, slack.messages, "a failing channel must not block the others")
+	assert.ErrorIs(t, err, boom)
+
+	var dispatchErr *DispatchError
+	require.ErrorAs(t, err, &dispatchErr)
+	require.Len(t, dispatchErr.Failures, 1)
+	assert.Equal(t, "email", dispatchErr.Failures[0].Channel)
+	assert.Equal(t, "notification failed on 1 of 2 channels: email: smtp down", err.Error())
+}
+
+func TestMultiNotifier_Reload(t *testing.T) {
+	var received int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+	}))
+	defer server.Close()
+
+	disabled := false
+	cfg := &config.Config{}
+	cfg.Notify.Channels.Email.Enabled = &disabled
+	m := NewMultiNotifierFromConfig(cfg, nil)
+	require.NoError(t, m.SendNotification(context.Background(), "before"))
+	assert.Zero(t, received)
+
+	reloaded := &config.Config{}
+	reloaded.Notify.Channels.Email.Enabled = &disabled
+	reloaded.Notify.Channels.Slack = config.SlackChannelConfig{Enabled: true, WebhookURL: server.URL}
+	require.NoError(t, m.Reload(context.Background(), reloaded))
+	require.NoError(t, m.SendNotification(context.Background(), "after"))
+	assert.Equal(t, 1, received)
+	for _, channel := range m.Channels() {
+		assert.Equal(t, channel.Name == "slack", channel.Enabled, channel.Name)
+	}
+
+	assert.Error(t, m.Reload(context.Background(), "not a config"))
+
+	// Notifiers built from explicit channels keep them
+	slack := &recordingNotifier{}
+	explicit := NewMultiNotifier(Channel{Name: "slack", Notifier: slack, Enabled: true})
+	require.NoError(t, explicit.Reload(context.Background(), reloaded))
+	assert.Same(t, slack, explicit.Channels()[0].Notifier)
+}
+
+func TestNewMultiNotifierForAudience(t *testing.T) {
+	cfg := &config.Config{EmailConfig: &config.EmailConfig{ToAddresses: []string{"team@example.com"}}}
+	cfg.Notify.Recipients.Weekly = []string{"leadership@example.com"}
+
+	recipients := func(m *MultiNotifier) []string {
+		email, ok := m.Channels()[0].Notifier.(*EmailNotifier)
+		require.True(t, ok)
+		return email.config.ToAddresses
+	}
+	assert.Equal(t, []string{"leadership@example.com"}, recipients(NewMultiNotifierForAudience(cfg, config.AudienceWeekly, nil)))
+	assert.Equal(t, []string{"team@example.com"}, recipients(NewMultiNotifierFromConfig(cfg, nil)))
+	assert.Equal(t, []string{"team@example.com"}, cfg.EmailConfig.ToAddresses, "shared config is not modified")
+}
+
+func TestNewAnomalyNotifier(t *testing.T) {
+	cfg := &config.Config{EmailConfig: &config.EmailConfig{ToAddresses: []string{"team@example.com"}}}
+	cfg.Notify.Channels.Slack = config.SlackChannelConfig{Enabled: true, WebhookURL: "https://hooks.slack.com/services/team"}
+	cfg.Anomaly.Alerts = config.AnomalyAlertsConfig{
+		Email:      []string{"security@example.com"},
+		WebhookURL: "https://pager.example.com/alerts",
+	}
+
+	// Alerts go to their own recipients and endpoints, never the regular
+	// channels
+	m := NewAnomalyNotifier(cfg, nil)
+	enabled := map[string]bool{}
+	for _, channel := range m.Channels() {
+		enabled[channel.Name] = channel.Enabled
+	}
+	assert.Equal(t, map[string]bool{"email": true, "slack": false, "webhook": true}, enabled)
+	email, ok := m.Channels()[0].Notifier.(*EmailNotifier)
+	require.True(t, ok)
+	assert.Equal(t, []string{"security@example.com"}, email.config.ToAddresses)
+	assert.Equal(t, []string{"team@example.com"}, cfg.EmailConfig.ToAddresses, "shared config is not modified")
+}
+
+func TestSlackNotifier(t *testing.T) {
+	var got map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer server.Close()
+
+	require.NoError(t, NewSlackNotifier(server.URL).SendNotification(context.Background(), "hi"))
+	assert.Equal(t, "hi", got["text"])
+	assert.Error(t, NewSlackNotifier("").SendNotification(context.Background(), "hi"))
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var payload WebhookPayload
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		if payload.Message == "fail" {
+			http.Error(w, "nope", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	n := NewWebhookNotifier(server.URL, map[string]string{"Authorization": "Bearer t"})
+	require.NoError(t, n.SendNotification(context.Background(), "hi"))
+	assert.Equal(t, "notification", payload.Event)
+	assert.Equal(t, "hi", payload.Message)
+	assert.Equal(t, "Bearer t", auth)
+	assert.False(t, payload.SentAt.IsZero())
+
+	assert.ErrorContains(t, n.SendNotification(context.Background(), "fail"), "unexpected status 400")
+}
+
+func TestWebhookNotifier_SignedReportToEveryURL(t *testing.T) {
+	var mu sync.Mutex
+	bodies := make(map[string][]byte)
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			assert.Equal(t, SignWebhook(body, []byte("s3cret")), r.Header.Get(WebhookSignatureHeader))
+			mu.Lock()
+			bodies[name] = body
+			mu.Unlock()
+		}
+	}
+	a := httptest.NewServer(handler("a"))
+	defer a.Close()
+	b := httptest.NewServer(handler("b"))
+	defer b.Close()
+
+	report := models.NewReport(models.FileListReport)
+	report.AddChange(models.FileChange{Path: "/docs/a.txt", Extension: ".txt", Directory: "/docs"})
+
+	n := NewWebhookNotifierWithConfig(WebhookConfig{URLs: []string{a.URL, b.URL}, Secret: "s3cret"})
+	require.NoError(t, n.SendReport(context.Background(), report, "1 change"))
+	require.Len(t, bodies, 2)
+
+	var payload WebhookPayload
+	require.NoError(t, json.Unmarshal(bodies["b"], &payload))
+	assert.Equal(t, "report", payload.Event)
+	assert.Equal(t, "1 change", payload.Message)
+	require.NotNil(t, payload.Report)
+	assert.Equal(t, models.FileListReport, payload.Report.Type)
+	assert.Equal(t, 1, payload.Report.Stats.TotalChanges)
+	assert.Equal(t, 1, payload.Report.Stats.ExtensionCount[".txt"])
+	require.Len(t, payload.Report.Changes, 1)
+	assert.Equal(t, "/docs/a.txt", payload.Report.Changes[0].Path)
+}
+
+func TestWebhookNotifier_RetriesWithBackoff(t *testing.T) {
+	var calls int
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(status)
+		}
+	}))
+	defer server.Close()
+
+	var delays []time.Duration
+	n := NewWebhookNotifierWithConfig(WebhookConfig{URLs: []string{server.URL}, Backoff: time.Second})
+	n.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	require.NoError(t, n.SendNotification(context.Background(), "hi"))
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, delays)
+
+	// Client errors other than rate limiting are not retried
+	calls, delays, status = 0, nil, http.StatusUnauthorized
+	assert.Error(t, n.SendNotification(context.Background(), "hi"))
+	assert.Equal(t, 1, calls)
+	assert.Empty(t, delays)
+}
+
+func TestMultiNotifier_SendReport(t *testing.T) {
+	email := &recordingNotifier{}
+	hook := &reportRecorder{}
+	m := NewMultiNotifier(
+		Channel{Name: "email", Notifier: email, Enabled: true},
+		Channel{Name: "webhook", Notifier: hook, Enabled: true},
+	)
+
+	report := models.NewReport(models.NarrativeReport)
+	require.NoError(t, m.SendReport(context.Background(), report, "text"))
+	assert.Equal(t, []string{"text"}, email.messages)
+	assert.Equal(t, []*models.Report{report}, hook.reports)
+}
+
+func TestMultiNotifier_SendReportTo(t *testing.T) {
+	email := &recordingNotifier{}
+	slack := &recordingNotifier{}
+	hook := &reportRecorder{}
+	m := NewMultiNotifier(
+		Channel{Name: "email", Notifier: email, Enabled: true},
+		Channel{Name: "slack", Notifier: slack, Enabled: true},
+		Channel{Name: "webhook", Notifier: hook, Enabled: false},
+	)
+
+	// Only the named channels that are enabled are sent to
+	report := models.NewReport(models.FileListReport)
+	require.NoError(t, m.SendReportTo(context.Background(), []string{"slack", "webhook"}, report, "text"))
+	assert.Empty(t, email.messages)
+	assert.Equal(t, []string{"text"}, slack.messages)
+	assert.Empty(t, hook.reports)
+
+	require.NoError(t, m.SendReportTo(context.Background(), nil, report, "text"))
+	assert.Len(t, slack.messages, 1)
+}
+
+// reportRecorder records the reports it receives and optionally fails
+type reportRecorder struct {
+	recordingNotifier
+	reports []*models.Report
+}
+
+func (n *reportRecorder) SendReport(ctx context.Context, report *models.Report, message string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.reports = append(n.reports, report)
+	return n.err
+}
@@ -1,8 +1,18 @@
 package notify
 
-import "context"
+import (
+	"context"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
 
 // Notifier defines the interface for sending notifications
 type Notifier interface {
 	SendNotification(ctx context.Context, message string) error
 }
+
+// ReportNotifier is implemented by notifiers that can deliver a report in a
+// structured form rather than as its text rendering
+type ReportNotifier interface {
+	SendReport(ctx context.Context, report *models.Report, message string) error
+}
@@ -2,13 +2,24 @@ package notify
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
 	"net"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -87,6 +98,13 @@ type mockSMTPServer struct {
 	t       *testing.T
 	ln      net.Listener
 	handler func(net.Conn)
+	// tlsConfig, when set, is offered through STARTTLS
+	tlsConfig *tls.Config
+	// refuse maps recipients to the reply that refuses them
+	refuse map[string]string
+
+	mu    sync.Mutex
+	auths []string
 }
 
 func newMockSMTPServer(t *testing.T) *mockSMTPServer {
@@ -94,10 +112,28 @@ func newMockSMTPServer(t *testing.T) *mockSMTPServer {
 	if err != nil {
 		t.Fatalf("Failed to start mock SMTP server: %v", err)
 	}
+	return serveMockSMTP(t, ln, nil)
+}
+
+// newMockTLSSMTPServer starts a mock server speaking implicit TLS, or offering
+// STARTTLS when starttls is set
+func newMockTLSSMTPServer(t *testing.T, cert tls.Certificate, starttls bool) *mockSMTPServer {
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start mock SMTP server: %v", err)
+	}
+	if starttls {
+		return serveMockSMTP(t, ln, tlsConfig)
+	}
+	return serveMockSMTP(t, tls.NewListener(ln, tlsConfig), nil)
+}
 
+func serveMockSMTP(t *testing.T, ln net.Listener, tlsConfig *tls.Config) *mockSMTPServer {
 	server := &mockSMTPServer{
-		t:  t,
-		ln: ln,
+		t:         t,
+		ln:        ln,
+		tlsConfig: tlsConfig,
 	}
 
 	go func() {
@@ -117,12 +153,15 @@ func newMockSMTPServer(t *testing.T) *mockSMTPServer {
 }
 
 func (s *mockSMTPServer) handleConnection(conn net.Conn) {
-	defer conn.Close()
+	defer func() { conn.Close() }()
 
 	// Send greeting
 	conn.Write([]byte("220 mock.smtp.server\r\n"))
 
-	// Simple SMTP conversation
+	// Simple SMTP conversation; message data may span several reads
+	inData := false
+	authSteps := 0
+	var data string
 	for {
 		buf := make([]byte, 1024)
 		n, err := conn.Read(buf)
@@ -131,19 +170,58 @@ func (s *mockSMTPServer) handleConnection(conn net.Conn) {
 		}
 
 		cmd := string(buf[:n])
+		if inData {
+			data += cmd
+			if strings.Contains(data, "\r\n.\r\n") {
+				inData, data = false, ""
+				conn.Write([]byte("250 Ok: message queued\r\n"))
+			}
+			continue
+		}
+		if authSteps > 0 {
+			// Responses to LOGIN and CRAM-MD5 challenges
+			authSteps--
+			if authSteps == 1 {
+				conn.Write([]byte("334 UGFzc3dvcmQ6\r\n"))
+			} else {
+				conn.Write([]byte("235 Authentication successful\r\n"))
+			}
+			continue
+		}
 		switch {
 		case strings.HasPrefix(cmd, "EHLO"):
-			conn.Write([]byte("250-mock.smtp.server\r\n250 AUTH LOGIN PLAIN\r\n"))
+			if _, secure := conn.(*tls.Conn); s.tlsConfig != nil && !secure {
+				conn.Write([]byte("250-mock.smtp.server\r\n250-STARTTLS\r\n250 AUTH LOGIN PLAIN CRAM-MD5\r\n"))
+			} else {
+				conn.Write([]byte("250-mock.smtp.server\r\n250 AUTH LOGIN PLAIN CRAM-MD5\r\n"))
+			}
+		case strings.HasPrefix(cmd, "STARTTLS"):
+			conn.Write([]byte("220 Ready to start TLS\r\n"))
+			conn = tls.Server(conn, s.tlsConfig)
 		case strings.HasPrefix(cmd, "AUTH PLAIN"):
+			s.recordAuth("PLAIN")
 			conn.Write([]byte("235 Authentication successful\r\n"))
+		case strings.HasPrefix(cmd, "AUTH LOGIN"):
+			s.recordAuth("LOGIN")
+			authSteps = 2
+			conn.Write([]byte("334 VXNlcm5hbWU6\r\n"))
+		case strings.HasPrefix(cmd, "AUTH CRAM-MD5"):
+			s.recordAuth("CRAM-MD5")
+			authSteps = 1
+			conn.Write([]byte("334 PDEyMzQ1QG1vY2suc210cC5zZXJ2ZXI+\r\n"))
 		case strings.HasPrefix(cmd, "MAIL FROM"):
 			conn.Write([]byte("250 Ok\r\n"))
 		case strings.HasPrefix(cmd, "RCPT TO"):
-			conn.Write([]byte("250 Ok\r\n"))
+			reply := "250 Ok"
+			for address, refusal := range s.refuse {
+				if strings.Contains(strings.ToLower(cmd), "<"+address+">") {
+					reply = refusal
+				}
+			}
+			conn.Write([]byte(reply + "\r\n"))
 		case strings.HasPrefix(cmd, "DATA"):
+			inData = true
 			conn.Write([]byte("354 End data with <CR><LF>.<CR><LF>\r\n"))
-		case strings.Contains(cmd, "\r\n.\r\n"):
-			conn.Write([]byte("250 Ok: message queued\r\n"))
 		case strings.HasPrefix(cmd, "QUIT"):
 			conn.Write([]byte("221 Bye\r\n"))
 			return
@@ -154,6 +232,18 @@ func (s *mockSMTPServer) handleConnection(conn net.Conn) {
 	}
 }
 
+func (s *mockSMTPServer) recordAuth(mechanism string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auths = append(s.auths, mechanism)
+}
+
+func (s *mockSMTPServer) authMechanisms() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.auths...)
+}
+
 func (s *mockSMTPServer) close() {
 	if s.ln != nil {
 		s.ln.Close()
@@ -188,3 +278,176 @@ func TestEmailNotifierSend(t *testing.T) {
 	err = notifier.SendNotification(ctx, "Test Message")
 	assert.NoError(t, err)
 }
+
+func TestEmailNotifierSendReport(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	port, err := strconv.Atoi(strings.Split(server.address(), ":")[1])
+	if err != nil {
+		t.Fatalf("Failed to parse port number: %v", err)
+	}
+
+	notifier := NewEmailNotifier(&config.EmailConfig{
+		SMTPHost:    "127.0.0.1",
+		SMTPPort:    port,
+		FromAddress: "from@test.com",
+		ToAddresses: []string{"to@test.com"},
+		AttachCSV:   true,
+	}).(*EmailNotifier)
+
+	report := models.NewReport(models.HTMLReport)
+	report.AddChange(models.FileChange{Path: "/docs/a.txt"})
+	report.Metadata["content"] = "<h1>Report</h1>"
+
+	assert.NoError(t, notifier.SendReport(context.Background(), report, "Report\n\n<h1>Report</h1>"))
+	assert.Error(t, notifier.SendReport(context.Background(), nil, ""))
+}
+
+// port returns the port the mock server listens on
+func (s *mockSMTPServer) port(t *testing.T) int {
+	port, err := strconv.Atoi(strings.Split(s.address(), ":")[1])
+	if err != nil {
+		t.Fatalf("Failed to parse port number: %v", err)
+	}
+	return port
+}
+
+// testCertificate creates a self-signed certificate for 127.0.0.1 and writes
+// it to a PEM file usable as a CA bundle
+func testCertificate(t *testing.T) (tls.Certificate, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Failed to write CA file: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, caFile
+}
+
+func TestEmailNotifierTLSModes(t *testing.T) {
+	cert, caFile := testCertificate(t)
+
+	plain := newMockSMTPServer(t)
+	defer plain.close()
+	implicit := newMockTLSSMTPServer(t, cert, false)
+	defer implicit.close()
+	starttls := newMockTLSSMTPServer(t, cert, true)
+	defer starttls.close()
+
+	tests := []struct {
+		name    string
+		server  *mockSMTPServer
+		mode    string
+		caFile  string
+		wantErr bool
+	}{
+		{name: "implicit TLS with CA", server: implicit, mode: config.SMTPTLSImplicit, caFile: caFile},
+		{name: "implicit TLS with untrusted certificate", server: implicit, mode: config.SMTPTLSImplicit, wantErr: true},
+		{name: "STARTTLS with CA", server: starttls, mode: config.SMTPTLSStartTLS, caFile: caFile},
+		{name: "STARTTLS with untrusted certificate", server: starttls, mode: config.SMTPTLSStartTLS, wantErr: true},
+		{name: "STARTTLS required but not offered", server: plain, mode: config.SMTPTLSStartTLS, caFile: caFile, wantErr: true},
+		{name: "opportunistic STARTTLS verifies certificate", server: starttls, wantErr: true},
+		{name: "no TLS", server: plain, mode: config.SMTPTLSNone},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			notifier := NewEmailNotifier(&config.EmailConfig{
+				SMTPHost:    "127.0.0.1",
+				SMTPPort:    tc.server.port(t),
+				FromAddress: "from@test.com",
+				ToAddresses: []string{"to@test.com"},
+				TLSMode:     tc.mode,
+				CAFile:      tc.caFile,
+				Timeout:     5 * time.Second,
+			})
+			err := notifier.SendNotification(context.Background(), "Test Message")
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestEmailNotifierAuthMethods(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	for _, method := range []string{"", config.SMTPAuthPlain, config.SMTPAuthLogin, config.SMTPAuthCRAMMD5} {
+		notifier := NewEmailNotifier(&config.EmailConfig{
+			SMTPHost:     "127.0.0.1",
+			SMTPPort:     server.port(t),
+			SMTPUsername: "test@test.com",
+			SMTPPassword: "password",
+			FromAddress:  "from@test.com",
+			ToAddresses:  []string{"to@test.com"},
+			AuthMethod:   method,
+		})
+		assert.NoError(t, notifier.SendNotification(context.Background(), "Test Message"), method)
+	}
+
+	assert.Equal(t, []string{"PLAIN", "PLAIN", "LOGIN", "CRAM-MD5"}, server.authMechanisms())
+}
+
+func TestEmailNotifierTimeout(t *testing.T) {
+	// A server that accepts connections but never greets
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	cfg := &config.EmailConfig{
+		SMTPHost:    "127.0.0.1",
+		SMTPPort:    ln.Addr().(*net.TCPAddr).Port,
+		FromAddress: "from@test.com",
+		ToAddresses: []string{"to@test.com"},
+		Timeout:     100 * time.Millisecond,
+	}
+
+	start := time.Now()
+	assert.Error(t, NewEmailNotifier(cfg).SendNotification(context.Background(), "Test Message"))
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	// Cancelling the context aborts the exchange before the timeout
+	cfg.Timeout = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = NewEmailNotifier(cfg).SendNotification(ctx, "Test Message")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,370 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/i18n"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/metrics"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// deadLettersTotal counts the notifications that ran out of attempts
+var deadLettersTotal = metrics.Default.CounterVec("notify_dead_letters_total",
+	"Queued notifications dead-lettered after their last attempt, by channel.", "channel")
+
+// lateThreshold is how old a queued notification must be before its delivery
+// is annotated as delayed
+const lateThreshold = time.Minute
+
+// Queue persists notifications that could not be delivered
+type Queue interface {
+	EnqueueNotification(ctx context.Context, n models.QueuedNotification) error
+	PendingNotifications(ctx context.Context, channel string) ([]models.QueuedNotification, error)
+	DeadLetteredNotifications(ctx context.Context, channel string) ([]models.QueuedNotification, error)
+	DeleteNotification(ctx context.Context, id int64) error
+	MarkNotificationFailed(ctx context.Context, id int64, lastError string, next time.Time) error
+	DeadLetterNotification(ctx context.Context, id int64, lastError string) error
+}
+
+// DeliveryArchive records the delivery status of archived reports as their
+// queued deliveries are retried
+type DeliveryArchive interface {
+	SetReportStatus(ctx context.Context, id int64, status, lastError string) error
+}
+
+// RetryPolicy sets how queued notifications are retried. The zero policy
+// retries every notification on every Retry until it is delivered.
+type RetryPolicy struct {
+	// Backoff is the wait after the first failed attempt, doubling after
+	// each further failure up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// MaxAttempts is how many times a notification is tried before it is
+	// dead-lettered; zero never gives up
+	MaxAttempts int
+}
+
+// retryPolicy returns the retry policy configured in n
+func retryPolicy(n config.NotifyConfig) RetryPolicy {
+	return RetryPolicy{
+		Backoff:     n.GetRetryBackoff(),
+		MaxBackoff:  n.GetMaxRetryBackoff(),
+		MaxAttempts: n.GetMaxAttempts(),
+	}
+}
+
+// delay returns the wait before the next attempt of a notification that
+// failed attempts times
+func (p RetryPolicy) delay(attempts int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempts && delay > 0; i++ {
+		delay *= 2
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	return delay
+}
+
+// exhausted reports whether a notification that failed attempts times is
+// dead-lettered
+func (p RetryPolicy) exhausted(attempts int) bool {
+	return p.MaxAttempts > 0 && attempts >= p.MaxAttempts
+}
+
+// WithQueue makes the notifier queue failed deliveries for Retry. Each
+// channel then delivers in chronological order: while a channel has queued
+// notifications, new ones wait behind them.
+func (m *MultiNotifier) WithQueue(queue Queue) *MultiNotifier {
+	m.queue = queue
+	return m
+}
+
+// WithArchive makes the notifier record the delivery status of archived
+// reports: queued while a delivery waits for a retry, then sent once every
+// queued delivery went out, or failed when one is dead-lettered
+func (m *MultiNotifier) WithArchive(archive DeliveryArchive) *MultiNotifier {
+	m.archive = archive
+	return m
+}
+
+// WithRetryPolicy sets how queued notifications are retried; notifiers
+// created from configuration use the policy configured under notify
+func (m *MultiNotifier) WithRetryPolicy(policy RetryPolicy) *MultiNotifier {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.policy = policy
+	return m
+}
+
+// retryPolicy returns the notifier's retry policy
+func (m *MultiNotifier) retryPolicy() RetryPolicy {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.policy
+}
+
+// Retry delivers queued notifications for every enabled channel, oldest
+// first, stopping a channel at its first failure to keep its order, or at a
+// notification still backing off; it can be registered as a scheduler task
+func (m *MultiNotifier) Retry(ctx context.Context) error {
+	if m.queue == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, channel := range m.Channels() {
+		if !channel.Enabled || channel.Notifier == nil {
+			continue
+		}
+		lock := m.lock(channel.Name)
+		lock.Lock()
+		err := m.flush(ctx, channel)
+		lock.Unlock()
+		if err != nil {
+			errs = append(errs, &ChannelError{Channel: channel.Name, Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliver sends a message, or report if given, through one channel, queueing
+// it behind earlier undelivered messages or for retry when a queue is
+// configured
+func (m *MultiNotifier) deliver(ctx context.Context, channel Channel, message string, report *models.Report) error {
+	if m.queue == nil {
+		return send(ctx, channel, message, report)
+	}
+
+	lock := m.lock(channel.Name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	createdAt := m.now()
+	var archiveID int64
+	if report != nil {
+		archiveID = report.ArchiveID
+	}
+	pending, err := m.queue.PendingNotifications(ctx, channel.Name)
+	if err != nil {
+		m.logger.Warn("Notification queue unavailable, sending directly", "channel", channel.Name, "error", err)
+		return send(ctx, channel, message, report)
+	}
+
+	if len(pending) == 0 {
+		sendErr := send(ctx, channel, message, report)
+		if sendErr == nil {
+			return nil
+		}
+		policy := m.retryPolicy()
+		return m.enqueue(ctx, models.QueuedNotification{
+			Channel:       channel.Name,
+			Message:       message,
+			Report:        report,
+			ArchiveID:     archiveID,
+			CreatedAt:     createdAt,
+			Attempts:      1,
+			LastError:     sendErr.Error(),
+			NextAttemptAt: createdAt.Add(policy.delay(1)),
+			DeadLettered:  policy.exhausted(1),
+		}, sendErr)
+	}
+
+	// Earlier messages are still waiting; queue behind them and try to catch up
+	if err := m.enqueue(ctx, models.QueuedNotification{
+		Channel:   channel.Name,
+		Message:   message,
+		Report:    report,
+		ArchiveID: archiveID,
+		CreatedAt: createdAt,
+	}, nil); err != nil {
+		return err
+	}
+	if err := m.flush(ctx, channel); err != nil {
+		m.logger.Warn("Notifications remain queued", "channel", channel.Name, "error", err)
+	}
+	return nil
+}
+
+// enqueue stores a notification for retry; cause is the delivery failure, if
+// any. Notifications dead-lettered at once are stored for inspection and
+// their failure is returned.
+func (m *MultiNotifier) enqueue(ctx context.Context, n models.QueuedNotification, cause error) error {
+	if err := m.queue.EnqueueNotification(ctx, n); err != nil {
+		if cause == nil {
+			return fmt.Errorf("failed to queue notification: %w", err)
+		}
+		return errors.Join(cause, fmt.Errorf("failed to queue notification for retry: %w", err))
+	}
+	if n.DeadLettered {
+		deadLettersTotal.With(n.Channel).Inc()
+		return cause
+	}
+	m.setReportStatus(ctx, n.ArchiveID, models.ReportQueued, n.LastError)
+	if cause != nil {
+		m.logger.Warn("Queued notification for retry", "channel", n.Channel, "error", cause)
+	}
+	return nil
+}
+
+// flush delivers a channel's queued notifications in order, queued reports
+// as reports where the channel supports them; the caller holds the channel
+// lock
+func (m *MultiNotifier) flush(ctx context.Context, channel Channel) error {
+	pending, err := m.queue.PendingNotifications(ctx, channel.Name)
+	if err != nil {
+		return err
+	}
+
+	policy := m.retryPolicy()
+	for _, n := range pending {
+		if n.NextAttemptAt.After(m.now()) {
+			return nil
+		}
+		if err := send(ctx, channel, m.annotate(n), m.annotateReport(n)); err != nil {
+			m.markFailed(ctx, policy, n, err)
+			return err
+		}
+		if err := m.queue.DeleteNotification(ctx, n.ID); err != nil {
+			return err
+		}
+		m.reportDelivered(ctx, n.ArchiveID)
+	}
+	return nil
+}
+
+// reportDelivered records an archived report as sent once none of its
+// deliveries are queued any more. A report with a dead-lettered delivery
+// stays failed until that delivery is requeued and goes out.
+func (m *MultiNotifier) reportDelivered(ctx context.Context, archiveID int64) {
+	if archiveID == 0 || m.archive == nil {
+		return
+	}
+	pending, err := m.queue.PendingNotifications(ctx, "")
+	if err != nil {
+		m.logger.Error("Failed to check the queued deliveries of report", "report", archiveID, "error", err)
+		return
+	}
+	deadLettered, err := m.queue.DeadLetteredNotifications(ctx, "")
+	if err != nil {
+		m.logger.Error("Failed to check the dead-lettered deliveries of report", "report", archiveID, "error", err)
+		return
+	}
+	for _, n := range append(pending, deadLettered...) {
+		if n.ArchiveID == archiveID {
+			return
+		}
+	}
+	m.setReportStatus(ctx, archiveID, models.ReportSent, "")
+}
+
+// setReportStatus records the delivery status of an archived report, if
+// the notification is for one
+func (m *MultiNotifier) setReportStatus(ctx context.Context, archiveID int64, status, lastError string) {
+	if archiveID == 0 || m.archive == nil {
+		return
+	}
+	if err := m.archive.SetReportStatus(ctx, archiveID, status, lastError); err != nil {
+		m.logger.Error("Failed to record the delivery of report", "report", archiveID, "error", err)
+	}
+}
+
+// markFailed records a failed retry of n, dead-lettering it once it runs
+// out of attempts
+func (m *MultiNotifier) markFailed(ctx context.Context, policy RetryPolicy, n models.QueuedNotification, err error) {
+	attempts := n.Attempts + 1
+	if policy.exhausted(attempts) {
+		if markErr := m.queue.DeadLetterNotification(ctx, n.ID, err.Error()); markErr != nil {
+			m.logger.Error("Failed to dead-letter notification", "channel", n.Channel, "id", n.ID, "error", markErr)
+			return
+		}
+		deadLettersTotal.With(n.Channel).Inc()
+		m.logger.Error("Gave up on notification", "channel", n.Channel, "id", n.ID, "attempts", attempts, "error", err)
+		m.setReportStatus(ctx, n.ArchiveID, models.ReportFailed, err.Error())
+		return
+	}
+
+	next := m.now().Add(policy.delay(attempts))
+	if markErr := m.queue.MarkNotificationFailed(ctx, n.ID, err.Error(), next); markErr != nil {
+		m.logger.Error("Failed to record retry of notification", "channel", n.Channel, "id", n.ID, "error", markErr)
+		return
+	}
+	m.setReportStatus(ctx, n.ArchiveID, models.ReportQueued, err.Error())
+}
+
+// annotate prefixes late deliveries with the time they were originally for
+func (m *MultiNotifier) annotate(n models.QueuedNotification) string {
+	note := m.delayNote(n)
+	if note == "" {
+		return n.Message
+	}
+	return note + "\n\n" + n.Message
+}
+
+// annotateReport returns the report of a queued notification, annotated
+// like its message when the delivery is late: the title, which emails use as
+// their subject, and the body of HTML reports start with the time the
+// report was originally for
+func (m *MultiNotifier) annotateReport(n models.QueuedNotification) *models.Report {
+	note := m.delayNote(n)
+	if n.Report == nil || note == "" {
+		return n.Report
+	}
+
+	report := *n.Report
+	title := report.Title
+	if title == "" {
+		title = i18n.Lookup(report.Language).T("report.changes_title")
+	}
+	report.Title = note + " " + title
+	report.Metadata = maps.Clone(n.Report.Metadata)
+	if report.Type == models.HTMLReport && report.Metadata != nil {
+		report.Metadata["content"] = prependHTML(report.Metadata["content"], "<p><em>"+html.EscapeString(note)+"</em></p>")
+	}
+	return &report
+}
+
+// delayNote describes when a late delivery was originally for, or returns
+// "" for a delivery on time
+func (m *MultiNotifier) delayNote(n models.QueuedNotification) string {
+	if m.now().Sub(n.CreatedAt) < lateThreshold {
+		return ""
+	}
+	return fmt.Sprintf("(delayed, originally for %s)", n.CreatedAt.In(time.Local).Format("15:04 on 2 Jan 2006"))
+}
+
+// prependHTML inserts fragment at the start of the body of an HTML
+// document, or before content without a body
+func prependHTML(content, fragment string) string {
+	if start := strings.Index(content, "<body"); start >= 0 {
+		if end := strings.Index(content[start:], ">"); end >= 0 {
+			at := start + end + 1
+			return content[:at] + "\n" + fragment + content[at:]
+		}
+	}
+	return fragment + "\n" + content
+}
+
+// lock returns the mutex serialising deliveries on a channel
+func (m *MultiNotifier) lock(name string) *sync.Mutex {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lock, ok := m.locks[name]
+	if !ok {
+		lock = &sync.Mutex{}
+		m.locks[name] = lock
+	}
+	return lock
+}
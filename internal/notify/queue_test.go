@@ -0,0 +1,341 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryQueue is an in-memory Queue
+type memoryQueue struct {
+	mu     sync.Mutex
+	nextID int64
+	items  []models.QueuedNotification
+}
+
+func (q *memoryQueue) EnqueueNotification(ctx context.Context, n models.QueuedNotification) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.nextID++
+	n.ID = q.nextID
+	q.items = append(q.items, n)
+	return nil
+}
+
+func (q *memoryQueue) PendingNotifications(ctx context.Context, channel string) ([]models.QueuedNotification, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var pending []models.QueuedNotification
+	for _, n := range q.items {
+		if (channel == "" || n.Channel == channel) && !n.DeadLettered {
+			pending = append(pending, n)
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+	return pending, nil
+}
+
+func (q *memoryQueue) DeadLetteredNotifications(ctx context.Context, channel string) ([]models.QueuedNotification, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var dead []models.QueuedNotification
+	for _, n := range q.items {
+		if (channel == "" || n.Channel == channel) && n.DeadLettered {
+			dead = append(dead, n)
+		}
+	}
+	return dead, nil
+}
+
+func (q *memoryQueue) DeleteNotification(ctx context.Context, id int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, n := range q.items {
+		if n.ID == id {
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (q *memoryQueue) MarkNotificationFailed(ctx context.Context, id int64, lastError string, next time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i := range q.items {
+		if q.items[i].ID == id {
+			q.items[i].Attempts++
+			q.items[i].LastError = lastError
+			q.items[i].NextAttemptAt = next
+		}
+	}
+	return nil
+}
+
+func (q *memoryQueue) DeadLetterNotification(ctx context.Context, id int64, lastError string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i := range q.items {
+		if q.items[i].ID == id {
+			q.items[i].Attempts++
+			q.items[i].LastError = lastError
+			q.items[i].DeadLettered = true
+		}
+	}
+	return nil
+}
+
+func TestMultiNotifier_QueuesFailures(t *testing.T) {
+	start := time.Date(2025, 2, 12, 9, 0, 0, 0, time.Local)
+	now := start
+	slack := &recordingNotifier{err: errors.New("slack down")}
+	email := &recordingNotifier{}
+	queue := &memoryQueue{}
+
+	m := NewMultiNotifier(
+		Channel{Name: "email", Notifier: email, Enabled: true},
+		Channel{Name: "slack", Notifier: slack, Enabled: true},
+	).WithQueue(queue)
+	m.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	require.NoError(t, m.SendNotification(ctx, "09:00 digest"), "queued failures are not reported")
+	require.Len(t, queue.items, 1)
+	assert.Equal(t, "slack", queue.items[0].Channel)
+	assert.Equal(t, 1, queue.items[0].Attempts)
+
+	// Slack recovers, but the 09:00 digest must still arrive before the 10:00 one
+	slack.err = nil
+	slack.messages = nil
+	now = start.Add(time.Hour)
+	require.NoError(t, m.SendNotification(ctx, "10:00 digest"))
+
+	assert.Equal(t, []string{
+		"(delayed, originally for 09:00 on 12 Feb 2025)\n\n09:00 digest",
+		"10:00 digest",
+	}, slack.messages)
+	assert.Equal(t, []string{"09:00 digest", "10:00 digest"}, email.messages)
+	assert.Empty(t, queue.items)
+}
+
+func TestMultiNotifier_Retry(t *testing.T) {
+	now := time.Date(2025, 2, 12, 9, 0, 0, 0, time.Local)
+	slack := &recordingNotifier{err: errors.New("slack down")}
+	queue := &memoryQueue{}
+
+	m := NewMultiNotifier(Channel{Name: "slack", Notifier: slack, Enabled: true}).WithQueue(queue)
+	m.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	require.NoError(t, m.SendNotification(ctx, "first"))
+	require.NoError(t, m.SendNotification(ctx, "second"))
+	require.Len(t, queue.items, 2)
+
+	// A failing retry keeps both messages queued in order
+	assert.Error(t, m.Retry(ctx))
+	require.Len(t, queue.items, 2)
+	assert.Equal(t, "first", queue.items[0].Message)
+
+	slack.err = nil
+	slack.messages = nil
+	require.NoError(t, m.Retry(ctx))
+	assert.Equal(t, []string{"first", "second"}, slack.messages, "retries within a minute are not annotated")
+	assert.Empty(t, queue.items)
+}
+
+func TestMultiNotifier_RetryBackoff(t *testing.T) {
+	start := time.Date(2025, 2, 12, 9, 0, 0, 0, time.Local)
+	now := start
+	slack := &recordingNotifier{err: errors.New("slack down")}
+	queue := &memoryQueue{}
+
+	m := NewMultiNotifier(Channel{Name: "slack", Notifier: slack, Enabled: true}).
+		WithQueue(queue).
+		WithRetryPolicy(RetryPolicy{Backoff: time.Minute, MaxBackoff: 3 * time.Minute, MaxAttempts: 4})
+	m.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	require.NoError(t, m.SendNotification(ctx, "report"))
+	require.Len(t, queue.items, 1)
+	assert.Equal(t, start.Add(time.Minute), queue.items[0].NextAttemptAt)
+
+	// Retries wait for the backoff, which doubles up to its maximum
+	require.NoError(t, m.Retry(ctx), "notifications backing off are not retried")
+	assert.Equal(t, 1, queue.items[0].Attempts)
+
+	now = start.Add(time.Minute)
+	assert.Error(t, m.Retry(ctx))
+	assert.Equal(t, 2, queue.items[0].Attempts)
+	assert.Equal(t, now.Add(2*time.Minute), queue.items[0].NextAttemptAt)
+
+	now = now.Add(2 * time.Minute)
+	assert.Error(t, m.Retry(ctx))
+	assert.Equal(t, now.Add(3*time.Minute), queue.items[0].NextAttemptAt)
+
+	// The last attempt dead-letters the notification, which later ones skip
+	now = now.Add(3 * time.Minute)
+	assert.Error(t, m.Retry(ctx))
+	require.Len(t, queue.items, 1)
+	assert.True(t, queue.items[0].DeadLettered)
+	assert.Equal(t, 4, queue.items[0].Attempts)
+
+	slack.err = nil
+	slack.messages = nil
+	require.NoError(t, m.SendNotification(ctx, "next report"))
+	assert.Equal(t, []string{"next report"}, slack.messages)
+}
+
+func TestMultiNotifier_RetriesReports(t *testing.T) {
+	webhook := &reportRecorder{recordingNotifier: recordingNotifier{err: errors.New("endpoint down")}}
+	queue := &memoryQueue{}
+	m := NewMultiNotifier(Channel{Name: "webhook", Notifier: webhook, Enabled: true}).WithQueue(queue)
+
+	ctx := context.Background()
+	report := &models.Report{Type: models.HTMLReport, Title: "Weekly changes"}
+	require.NoError(t, m.SendReport(ctx, report, "3 changes"))
+	require.Len(t, queue.items, 1)
+	assert.Same(t, report, queue.items[0].Report)
+
+	// The retry sends the report itself, not only its text
+	webhook.err = nil
+	webhook.reports = nil
+	require.NoError(t, m.Retry(ctx))
+	require.Len(t, webhook.reports, 1)
+	assert.Same(t, report, webhook.reports[0])
+	assert.Empty(t, webhook.messages)
+	assert.Empty(t, queue.items)
+}
+
+// memoryArchive records the delivery status of archived reports
+type memoryArchive struct {
+	status    map[int64]string
+	lastError map[int64]string
+}
+
+func (a *memoryArchive) SetReportStatus(ctx context.Context, id int64, status, lastError string) error {
+	if a.status == nil {
+		a.status = make(map[int64]string)
+		a.lastError = make(map[int64]string)
+	}
+	a.status[id], a.lastError[id] = status, lastError
+	return nil
+}
+
+func TestMultiNotifier_RecordsQueuedReports(t *testing.T) {
+	now := time.Date(2025, 2, 12, 9, 0, 0, 0, time.Local)
+	webhook := &reportRecorder{recordingNotifier: recordingNotifier{err: errors.New("endpoint down")}}
+	queue := &memoryQueue{}
+	archive := &memoryArchive{}
+	m := NewMultiNotifier(Channel{Name: "webhook", Notifier: webhook, Enabled: true}).
+		WithQueue(queue).
+		WithArchive(archive).
+		WithRetryPolicy(RetryPolicy{Backoff: time.Minute, MaxAttempts: 2})
+	m.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	require.NoError(t, m.SendReport(ctx, &models.Report{Title: "Weekly changes", ArchiveID: 7}, "3 changes"))
+	require.Len(t, queue.items, 1)
+	assert.Equal(t, int64(7), queue.items[0].ArchiveID)
+	assert.Equal(t, models.ReportQueued, archive.status[7])
+	assert.Equal(t, "endpoint down", archive.lastError[7])
+
+	// A delivered retry marks the report sent
+	webhook.err = nil
+	now = now.Add(time.Minute)
+	require.NoError(t, m.Retry(ctx))
+	assert.Empty(t, queue.items)
+	assert.Equal(t, models.ReportSent, archive.status[7])
+	assert.Empty(t, archive.lastError[7])
+
+	// A dead-lettered retry marks the report failed
+	webhook.err = errors.New("endpoint gone")
+	require.NoError(t, m.SendReport(ctx, &models.Report{Title: "Weekly changes", ArchiveID: 8}, "1 change"))
+	assert.Equal(t, models.ReportQueued, archive.status[8])
+	now = now.Add(time.Minute)
+	assert.Error(t, m.Retry(ctx))
+	assert.Equal(t, models.ReportFailed, archive.status[8])
+	assert.Equal(t, "endpoint gone", archive.lastError[8])
+}
+
+func TestMultiNotifier_AnnotatesLateReports(t *testing.T) {
+	start := time.Date(2025, 2, 12, 9, 0, 0, 0, time.Local)
+	now := start
+	webhook := &reportRecorder{recordingNotifier: recordingNotifier{err: errors.New("endpoint down")}}
+	m := NewMultiNotifier(Channel{Name: "email", Notifier: webhook, Enabled: true}).WithQueue(&memoryQueue{})
+	m.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	report := models.NewReport(models.HTMLReport)
+	report.Title = "Weekly changes"
+	report.Metadata["content"] = `<html><body class="page"><h1>Weekly changes</h1></body></html>`
+	require.NoError(t, m.SendReport(ctx, report, "Weekly changes"))
+
+	webhook.err = nil
+	webhook.reports = nil
+	now = start.Add(time.Hour)
+	require.NoError(t, m.Retry(ctx))
+	require.Len(t, webhook.reports, 1)
+
+	// The email of a late report carries the original time in its subject
+	// and both of its parts
+	email, err := (&EmailNotifier{}).reportEmail(webhook.reports[0], "Weekly changes")
+	require.NoError(t, err)
+	data, err := buildMessage("monitor@example.com", []string{"a@example.com"}, email, mimeDate)
+	require.NoError(t, err)
+	msg, _, params := parseMessage(t, data)
+	assert.Equal(t, "(delayed, originally for 09:00 on 12 Feb 2025) Weekly changes", msg.Header.Get("Subject"))
+	parts := readParts(t, msg.Body, params["boundary"])
+	assert.Equal(t, "<html><body class=\"page\">\r\n<p><em>(delayed, originally for 09:00 on 12 Feb 2025)</em></p><h1>Weekly changes</h1></body></html>",
+		decodeQP(t, parts["text/html"].Header.Get("X-Test-Body")))
+	assert.Contains(t, decodeQP(t, parts["text/plain"].Header.Get("X-Test-Body")), "Weekly changes")
+
+	// The queued report itself is left as it was
+	assert.Equal(t, "Weekly changes", report.Title)
+}
+
+func TestMultiNotifier_KeepsFailedReports(t *testing.T) {
+	now := time.Date(2025, 2, 12, 9, 0, 0, 0, time.Local)
+	slack := &reportRecorder{recordingNotifier: recordingNotifier{err: errors.New("slack down")}}
+	webhook := &reportRecorder{recordingNotifier: recordingNotifier{err: errors.New("endpoint down")}}
+	queue := &memoryQueue{}
+	archive := &memoryArchive{}
+	m := NewMultiNotifier(
+		Channel{Name: "slack", Notifier: slack, Enabled: true},
+		Channel{Name: "webhook", Notifier: webhook, Enabled: true},
+	).WithQueue(queue).WithArchive(archive).WithRetryPolicy(RetryPolicy{Backoff: time.Minute, MaxAttempts: 2})
+	m.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	require.NoError(t, m.SendReport(ctx, &models.Report{Title: "Weekly changes", ArchiveID: 7}, "3 changes"))
+	assert.Equal(t, models.ReportQueued, archive.status[7])
+
+	// The delivery on one channel does not hide the dead letter on the other
+	webhook.err = nil
+	webhook.reports = nil
+	now = now.Add(time.Minute)
+	assert.Error(t, m.Retry(ctx))
+	assert.Len(t, webhook.reports, 1)
+	assert.Equal(t, models.ReportFailed, archive.status[7])
+	assert.Equal(t, "slack down", archive.lastError[7])
+}
+
+func TestMultiNotifier_DeadLettersAtOnce(t *testing.T) {
+	slack := &recordingNotifier{err: errors.New("slack down")}
+	queue := &memoryQueue{}
+
+	m := NewMultiNotifier(Channel{Name: "slack", Notifier: slack, Enabled: true}).
+		WithQueue(queue).
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 1})
+
+	err := m.SendNotification(context.Background(), "report")
+	require.Error(t, err, "notifications that are not retried report their failure")
+	require.Len(t, queue.items, 1)
+	assert.True(t, queue.items[0].DeadLettered)
+}
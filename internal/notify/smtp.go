@@ -0,0 +1,206 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
+)
+
+// sendMail delivers msg over a connection that honours ctx and the configured
+// TLS mode, authentication method and timeout. Recipients the server refuses
+// are returned; the message still goes to the others, and only fails when
+// every recipient is refused.
+func sendMail(ctx context.Context, cfg *config.EmailConfig, from string, to []string, msg []byte) ([]*RecipientError, error) {
+	tlsConfig, err := smtpTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := cfg.GetTimeout()
+	addr := net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort))
+	dialer := &net.Dialer{Timeout: timeout}
+	raw, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	defer raw.Close()
+
+	// Closing the connection unblocks any exchange in progress
+	stop := context.AfterFunc(ctx, func() { raw.Close() })
+	defer stop()
+
+	rejected, err := converse(ctx, &timeoutConn{Conn: raw, timeout: timeout}, cfg, tlsConfig, from, to, msg)
+	if err != nil && ctx.Err() != nil {
+		return rejected, fmt.Errorf("context cancelled: %w", ctx.Err())
+	}
+	return rejected, err
+}
+
+// converse runs the SMTP exchange on an established connection
+func converse(ctx context.Context, conn net.Conn, cfg *config.EmailConfig, tlsConfig *tls.Config, from string, to []string, msg []byte) ([]*RecipientError, error) {
+	if cfg.TLSMode == config.SMTPTLSImplicit {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, fmt.Errorf("TLS handshake failed: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	c, err := smtp.NewClient(conn, cfg.SMTPHost)
+	if err != nil {
+		return nil, fmt.Errorf("SMTP greeting failed: %w", err)
+	}
+	defer c.Close()
+
+	if err := c.Hello("localhost"); err != nil {
+		return nil, fmt.Errorf("SMTP hello failed: %w", err)
+	}
+
+	if cfg.TLSMode == "" || cfg.TLSMode == config.SMTPTLSStartTLS {
+		ok, _ := c.Extension("STARTTLS")
+		if !ok && cfg.TLSMode == config.SMTPTLSStartTLS {
+			return nil, fmt.Errorf("server does not support STARTTLS")
+		}
+		if ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return nil, fmt.Errorf("STARTTLS failed: %w", err)
+			}
+		}
+	}
+
+	if cfg.SMTPUsername != "" {
+		if ok, _ := c.Extension("AUTH"); ok {
+			if err := c.Auth(smtpAuth(cfg)); err != nil {
+				return nil, fmt.Errorf("SMTP authentication failed: %w", err)
+			}
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return nil, err
+	}
+	var rejected []*RecipientError
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			var reply *textproto.Error
+			if !errors.As(err, &reply) {
+				return rejected, err
+			}
+			rejected = append(rejected, &RecipientError{Address: addr, Err: err})
+		}
+	}
+	if len(rejected) == len(to) {
+		errs := make([]error, len(rejected))
+		for i, r := range rejected {
+			errs[i] = r
+		}
+		return rejected, fmt.Errorf("all recipients were refused: %w", errors.Join(errs...))
+	}
+	w, err := c.Data()
+	if err != nil {
+		return rejected, err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return rejected, err
+	}
+	if err := w.Close(); err != nil {
+		return rejected, err
+	}
+	return rejected, c.Quit()
+}
+
+// smtpTLSConfig verifies the server against the system roots or the configured CA
+func smtpTLSConfig(cfg *config.EmailConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName: cfg.SMTPHost,
+		MinVersion: tls.VersionTLS12,
+	}
+	if cfg.CAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(cfg.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+	}
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
+
+// smtpAuth returns the configured authentication mechanism
+func smtpAuth(cfg *config.EmailConfig) smtp.Auth {
+	switch cfg.AuthMethod {
+	case config.SMTPAuthLogin:
+		return &loginAuth{username: cfg.SMTPUsername, password: cfg.SMTPPassword, host: cfg.SMTPHost}
+	case config.SMTPAuthCRAMMD5:
+		return smtp.CRAMMD5Auth(cfg.SMTPUsername, cfg.SMTPPassword)
+	default:
+		return smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+}
+
+// loginAuth implements the LOGIN mechanism. Like PLAIN, it sends the
+// password in the clear and so is refused on unencrypted remote connections.
+type loginAuth struct {
+	username, password, host string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(strings.TrimSuffix(string(fromServer), ":"))) {
+	case "username":
+		return []byte(a.username), nil
+	case "password":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+}
+
+// isLocalhost reports whether name refers to the local machine
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
+
+// timeoutConn extends the deadline before every read and write so that each
+// exchange, rather than the whole session, is bounded by timeout
+type timeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *timeoutConn) Read(b []byte) (int, error) {
+	c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Read(b)
+}
+
+func (c *timeoutConn) Write(b []byte) (int, error) {
+	c.Conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Write(b)
+}
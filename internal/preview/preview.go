@@ -0,0 +1,159 @@
+// Package preview resolves links that open the changed files listed in a
+// report, and downloads thumbnails of changed images to show beside them.
+package preview
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+const (
+	// DefaultThumbnailSize is the size thumbnails are scaled to when none
+	// is configured
+	DefaultThumbnailSize = "w128h128"
+	// DefaultMaxFiles caps the files linked in each report when no limit is
+	// configured
+	DefaultMaxFiles = 50
+	// maxImageBytes is the largest image Dropbox makes a thumbnail of
+	maxImageBytes = 20 << 20
+)
+
+// webURL is the Dropbox website, where a signed-in owner can open any file
+const webURL = "https://www.dropbox.com/home"
+
+// thumbnailExtensions are the image formats Dropbox makes thumbnails of
+var thumbnailExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".bmp": true,
+	".tif": true, ".tiff": true, ".webp": true, ".ppm": true, ".heic": true,
+}
+
+// Source resolves links to files and their thumbnails, e.g. a
+// dropbox.DropboxClient
+type Source interface {
+	GetFileSharedLink(ctx context.Context, path string) (string, error)
+	GetTemporaryLink(ctx context.Context, path string) (string, error)
+	GetThumbnail(ctx context.Context, path, size string) ([]byte, error)
+}
+
+// Config chooses the links and previews added to reports. Files are always
+// linked on the Dropbox website; the options replace that link with one
+// that opens without signing in.
+type Config struct {
+	// SharedLinks links files through their existing shared link
+	SharedLinks bool
+	// TemporaryLinks links files without a shared link through a download
+	// link that expires after four hours
+	TemporaryLinks bool
+	// Thumbnails previews changed images in HTML reports
+	Thumbnails bool
+	// ThumbnailSize is one of dropbox.ThumbnailSizes; empty uses
+	// DefaultThumbnailSize
+	ThumbnailSize string
+	// MaxFiles caps the files resolved for each report; zero uses
+	// DefaultMaxFiles
+	MaxFiles int
+}
+
+// Validate reports whether the configuration is usable
+func (c Config) Validate() error {
+	if c.MaxFiles < 0 {
+		return fmt.Errorf("max files cannot be negative")
+	}
+	if c.ThumbnailSize != "" && !slices.Contains(dropbox.ThumbnailSizes, c.ThumbnailSize) {
+		return fmt.Errorf("unknown thumbnail size %q, expected one of %s", c.ThumbnailSize, strings.Join(dropbox.ThumbnailSizes, ", "))
+	}
+	return nil
+}
+
+// Resolver resolves the links and thumbnails of changed files
+type Resolver struct {
+	source Source
+	config Config
+	logger *slog.Logger
+}
+
+// NewResolver creates a resolver asking source for links and thumbnails
+func NewResolver(source Source, cfg Config, logger *slog.Logger) (*Resolver, error) {
+	if source == nil {
+		return nil, fmt.Errorf("link source cannot be nil")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.ThumbnailSize == "" {
+		cfg.ThumbnailSize = DefaultThumbnailSize
+	}
+	if cfg.MaxFiles == 0 {
+		cfg.MaxFiles = DefaultMaxFiles
+	}
+	return &Resolver{source: source, config: cfg, logger: logging.Component(logger, "preview")}, nil
+}
+
+// Resolve returns the links of the changed files keyed by path, with the
+// thumbnails of changed images when thumbnails is set. Deleted files and
+// Paper docs are not linked, and a failure to resolve a link falls back to
+// the website link.
+func (r *Resolver) Resolve(ctx context.Context, changes []models.FileChange, thumbnails bool) map[string]models.FileLink {
+	links := make(map[string]models.FileLink)
+	for _, change := range changes {
+		if len(links) == r.config.MaxFiles || ctx.Err() != nil {
+			break
+		}
+		if _, ok := links[change.Path]; ok || change.IsDeleted || change.Type() == models.ChangePaper {
+			continue
+		}
+
+		link := r.link(ctx, change)
+		if thumbnails && r.config.Thumbnails && change.Size <= maxImageBytes &&
+			thumbnailExtensions[strings.ToLower(path.Ext(change.Path))] {
+			thumbnail, err := r.source.GetThumbnail(ctx, change.Path, r.config.ThumbnailSize)
+			if err != nil {
+				r.logger.Warn("Failed to download thumbnail", "path", change.Path, "error", err)
+			} else {
+				link.Thumbnail = thumbnail
+			}
+		}
+		links[change.Path] = link
+	}
+	return links
+}
+
+// link returns the link that opens a changed file
+func (r *Resolver) link(ctx context.Context, change models.FileChange) models.FileLink {
+	if change.Type() == models.ChangeSharedLink && change.URL != "" {
+		return models.FileLink{URL: change.URL}
+	}
+	if r.config.SharedLinks {
+		shared, err := r.source.GetFileSharedLink(ctx, change.Path)
+		if err != nil {
+			r.logger.Warn("Failed to look up shared link", "path", change.Path, "error", err)
+		} else if shared != "" {
+			return models.FileLink{URL: shared}
+		}
+	}
+	if r.config.TemporaryLinks {
+		temporary, err := r.source.GetTemporaryLink(ctx, change.Path)
+		if err != nil {
+			r.logger.Warn("Failed to create temporary link", "path", change.Path, "error", err)
+		} else {
+			return models.FileLink{URL: temporary, Temporary: true}
+		}
+	}
+	return models.FileLink{URL: WebURL(change.Path)}
+}
+
+// WebURL returns the address that opens the file at p on the Dropbox
+// website, for its signed-in owner
+func WebURL(p string) string {
+	dir, name := path.Split(path.Clean("/" + p))
+	return webURL + (&url.URL{Path: strings.TrimSuffix(dir, "/")}).EscapedPath() + "?preview=" + url.QueryEscape(name)
+}
@@ -0,0 +1,88 @@
+package preview
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSource serves shared links for the paths in shared and temporary links
+// for every other path unless failing
+type fakeSource struct {
+	shared     map[string]string
+	failing    bool
+	thumbnails []string
+}
+
+func (f *fakeSource) GetFileSharedLink(ctx context.Context, path string) (string, error) {
+	return f.shared[path], nil
+}
+
+func (f *fakeSource) GetTemporaryLink(ctx context.Context, path string) (string, error) {
+	if f.failing {
+		return "", fmt.Errorf("temporary links disabled")
+	}
+	return "https://dl.example/" + path, nil
+}
+
+func (f *fakeSource) GetThumbnail(ctx context.Context, path, size string) ([]byte, error) {
+	f.thumbnails = append(f.thumbnails, path+"@"+size)
+	return []byte("jpeg"), nil
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	source := &fakeSource{shared: map[string]string{"/docs/a.pdf": "https://db.tt/a"}}
+	resolver, err := NewResolver(source, Config{SharedLinks: true, TemporaryLinks: true, Thumbnails: true}, nil)
+	require.NoError(t, err)
+
+	changes := []models.FileChange{
+		{Path: "/docs/a.pdf"},
+		{Path: "/photos/b.PNG", Size: 2048},
+		{Path: "/photos/b.PNG", Size: 2048},
+		{Path: "/old/c.txt", IsDeleted: true},
+		{Path: "/Paper/d.paper", ChangeType: models.ChangePaper, URL: "https://paper.dropbox.com/doc/d"},
+		{Path: "/docs/e.pdf", ChangeType: models.ChangeSharedLink, URL: "https://db.tt/e"},
+	}
+	links := resolver.Resolve(context.Background(), changes, true)
+
+	assert.Equal(t, map[string]models.FileLink{
+		"/docs/a.pdf":   {URL: "https://db.tt/a"},
+		"/photos/b.PNG": {URL: "https://dl.example//photos/b.PNG", Temporary: true, Thumbnail: []byte("jpeg")},
+		"/docs/e.pdf":   {URL: "https://db.tt/e"},
+	}, links)
+	assert.Equal(t, []string{"/photos/b.PNG@w128h128"}, source.thumbnails)
+
+	// Markdown reports need no thumbnails
+	links = resolver.Resolve(context.Background(), changes[:2], false)
+	assert.Nil(t, links["/photos/b.PNG"].Thumbnail)
+	assert.Len(t, source.thumbnails, 1)
+}
+
+func TestResolver_FallsBackToWebsite(t *testing.T) {
+	resolver, err := NewResolver(&fakeSource{failing: true}, Config{TemporaryLinks: true, MaxFiles: 1}, nil)
+	require.NoError(t, err)
+
+	links := resolver.Resolve(context.Background(), []models.FileChange{{Path: "/My Docs/a&b.txt"}, {Path: "/x.txt"}}, true)
+	assert.Equal(t, map[string]models.FileLink{
+		"/My Docs/a&b.txt": {URL: "https://www.dropbox.com/home/My%20Docs?preview=a%26b.txt"},
+	}, links)
+}
+
+func TestWebURL(t *testing.T) {
+	assert.Equal(t, "https://www.dropbox.com/home?preview=a.txt", WebURL("/a.txt"))
+	assert.Equal(t, "https://www.dropbox.com/home/docs/2025?preview=report+v2.pdf", WebURL("docs/2025/report v2.pdf"))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	assert.NoError(t, Config{}.Validate())
+	assert.NoError(t, Config{ThumbnailSize: "w256h256", MaxFiles: 10}.Validate())
+	assert.Error(t, Config{ThumbnailSize: "huge"}.Validate())
+	assert.Error(t, Config{MaxFiles: -1}.Validate())
+
+	_, err := NewResolver(nil, Config{}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,22 @@
+package quota
+
+import (
+	"context"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/notify"
+)
+
+// NotifierAlerter sends quota alerts through a notifier
+type NotifierAlerter struct {
+	notifier notify.Notifier
+}
+
+// NewNotifierAlerter creates an alerter backed by the given notifier
+func NewNotifierAlerter(notifier notify.Notifier) *NotifierAlerter {
+	return &NotifierAlerter{notifier: notifier}
+}
+
+// Alert implements Alerter
+func (a *NotifierAlerter) Alert(ctx context.Context, alert Alert) error {
+	return a.notifier.SendNotification(ctx, alert.Message())
+}
@@ -0,0 +1,239 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+)
+
+// DefaultHistory is how far back folder scans are used to estimate growth
+const DefaultHistory = 30 * 24 * time.Hour
+
+// Budget is the storage limit for one folder
+type Budget struct {
+	Path     string
+	MaxBytes int64
+	// ProjectionDays enables growth alerts that far ahead; zero disables them
+	ProjectionDays int
+}
+
+// Level is the severity of a budget's status
+type Level int
+
+const (
+	// LevelOK means the folder is within budget
+	LevelOK Level = iota
+	// LevelProjected means the folder is expected to exceed its budget soon
+	LevelProjected
+	// LevelExceeded means the folder is over budget
+	LevelExceeded
+)
+
+// String returns the level name
+func (l Level) String() string {
+	switch l {
+	case LevelProjected:
+		return "projected"
+	case LevelExceeded:
+		return "exceeded"
+	default:
+		return "ok"
+	}
+}
+
+// Alert describes a folder that is over, or heading over, its budget
+type Alert struct {
+	Budget    Budget
+	Level     Level
+	UsedBytes int64
+	// ProjectedBytes is the expected size after Budget.ProjectionDays
+	ProjectedBytes int64
+	At             time.Time
+}
+
+// Message returns a human readable description of the alert
+func (a Alert) Message() string {
+	if a.Level == LevelExceeded {
+		return fmt.Sprintf("Storage quota exceeded for %s: %s used of %s",
+			a.Budget.Path, formatBytes(a.UsedBytes), formatBytes(a.Budget.MaxBytes))
+	}
+	return fmt.Sprintf("Storage quota for %s projected to be exceeded within %d days: %s used, %s expected, budget %s",
+		a.Budget.Path, a.Budget.ProjectionDays, formatBytes(a.UsedBytes), formatBytes(a.ProjectedBytes), formatBytes(a.Budget.MaxBytes))
+}
+
+// Sizer computes the cumulative size of a folder
+type Sizer interface {
+	FolderSize(ctx context.Context, path string) (int64, error)
+}
+
+// UsageStore persists folder scans so growth can be projected
+type UsageStore interface {
+	SaveFolderUsage(ctx context.Context, usage db.FolderUsage) error
+	GetFolderUsage(ctx context.Context, path string, since time.Time) ([]db.FolderUsage, error)
+}
+
+// Alerter delivers quota alerts
+type Alerter interface {
+	Alert(ctx context.Context, alert Alert) error
+}
+
+// Monitor scans folders and raises alerts when their budgets are exceeded or
+// projected to be exceeded. An alert is sent when a folder's level rises, so
+// repeated scans of a folder that stays over budget do not repeat the alert.
+type Monitor struct {
+	budgets  []Budget
+	sizer    Sizer
+	store    UsageStore
+	alerters []Alerter
+	history  time.Duration
+	now      func() time.Time
+	logger   *slog.Logger
+
+	mu     sync.Mutex
+	levels map[string]Level
+}
+
+// NewMonitor creates a quota monitor
+func NewMonitor(budgets []Budget, sizer Sizer, store UsageStore, alerters ...Alerter) (*Monitor, error) {
+	if sizer == nil {
+		return nil, fmt.Errorf("sizer cannot be nil")
+	}
+	if store == nil {
+		return nil, fmt.Errorf("usage store cannot be nil")
+	}
+	for _, budget := range budgets {
+		if budget.Path == "" {
+			return nil, fmt.Errorf("budget path cannot be empty")
+		}
+		if budget.MaxBytes <= 0 {
+			return nil, fmt.Errorf("budget for %s must be positive", budget.Path)
+		}
+	}
+
+	return &Monitor{
+		budgets:  budgets,
+		sizer:    sizer,
+		store:    store,
+		alerters: alerters,
+		history:  DefaultHistory,
+		now:      time.Now,
+		logger:   logging.Component(nil, "quota"),
+		levels:   make(map[string]Level),
+	}, nil
+}
+
+// Check scans every budgeted folder and sends alerts for any whose level
+// rose since the last check; it can be registered as a scheduler task
+func (m *Monitor) Check(ctx context.Context) error {
+	alerts, err := m.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, alert := range alerts {
+		if !m.raise(alert) {
+			continue
+		}
+		m.logger.Warn(alert.Message(), "path", alert.Budget.Path)
+		for _, alerter := range m.alerters {
+			if err := alerter.Alert(ctx, alert); err != nil {
+				return fmt.Errorf("failed to send quota alert for %s: %w", alert.Budget.Path, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Evaluate scans every budgeted folder, records the result and returns the
+// current status of each budget
+func (m *Monitor) Evaluate(ctx context.Context) ([]Alert, error) {
+	alerts := make([]Alert, 0, len(m.budgets))
+	for _, budget := range m.budgets {
+		alert, err := m.evaluate(ctx, budget)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+// evaluate scans a single folder
+func (m *Monitor) evaluate(ctx context.Context, budget Budget) (Alert, error) {
+	now := m.now()
+	used, err := m.sizer.FolderSize(ctx, budget.Path)
+	if err != nil {
+		return Alert{}, fmt.Errorf("failed to size %s: %w", budget.Path, err)
+	}
+
+	if err := m.store.SaveFolderUsage(ctx, db.FolderUsage{Path: budget.Path, Bytes: used, ScannedAt: now}); err != nil {
+		return Alert{}, fmt.Errorf("failed to record usage for %s: %w", budget.Path, err)
+	}
+
+	alert := Alert{Budget: budget, Level: LevelOK, UsedBytes: used, ProjectedBytes: used, At: now}
+	if used > budget.MaxBytes {
+		alert.Level = LevelExceeded
+		return alert, nil
+	}
+	if budget.ProjectionDays == 0 {
+		return alert, nil
+	}
+
+	history, err := m.store.GetFolderUsage(ctx, budget.Path, now.Add(-m.history))
+	if err != nil {
+		return Alert{}, fmt.Errorf("failed to load usage for %s: %w", budget.Path, err)
+	}
+	alert.ProjectedBytes = project(history, used, now, budget.ProjectionDays)
+	if alert.ProjectedBytes > budget.MaxBytes {
+		alert.Level = LevelProjected
+	}
+
+	return alert, nil
+}
+
+// project extrapolates the growth between the oldest scan in history and the
+// current size over the given number of days; shrinking folders are not projected
+func project(history []db.FolderUsage, used int64, now time.Time, days int) int64 {
+	if len(history) == 0 {
+		return used
+	}
+
+	oldest := history[0]
+	elapsed := now.Sub(oldest.ScannedAt)
+	if elapsed <= 0 || used <= oldest.Bytes {
+		return used
+	}
+
+	perDay := float64(used-oldest.Bytes) / elapsed.Hours() * 24
+	return used + int64(perDay*float64(days))
+}
+
+// raise records the alert's level and reports whether it rose since the last check
+func (m *Monitor) raise(alert Alert) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous := m.levels[alert.Budget.Path]
+	m.levels[alert.Budget.Path] = alert.Level
+	return alert.Level > previous
+}
+
+// formatBytes renders a size with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,132 @@
+package quota
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSizer returns configured folder sizes
+type fakeSizer map[string]int64
+
+func (s fakeSizer) FolderSize(ctx context.Context, path string) (int64, error) {
+	return s[path], nil
+}
+
+// memoryStore keeps folder usage in memory
+type memoryStore struct {
+	usage []db.FolderUsage
+}
+
+func (s *memoryStore) SaveFolderUsage(ctx context.Context, usage db.FolderUsage) error {
+	s.usage = append(s.usage, usage)
+	return nil
+}
+
+func (s *memoryStore) GetFolderUsage(ctx context.Context, path string, since time.Time) ([]db.FolderUsage, error) {
+	var result []db.FolderUsage
+	for _, u := range s.usage {
+		if u.Path == path && u.ScannedAt.After(since) {
+			result = append(result, u)
+		}
+	}
+	return result, nil
+}
+
+// recordingAlerter records the alerts it receives
+type recordingAlerter struct {
+	alerts []Alert
+}
+
+func (a *recordingAlerter) Alert(ctx context.Context, alert Alert) error {
+	a.alerts = append(a.alerts, alert)
+	return nil
+}
+
+func TestNewMonitor(t *testing.T) {
+	_, err := NewMonitor(nil, nil, &memoryStore{})
+	assert.Error(t, err)
+
+	_, err = NewMonitor([]Budget{{Path: "/Raw-Video"}}, fakeSizer{}, &memoryStore{})
+	assert.Error(t, err)
+
+	_, err = NewMonitor([]Budget{{Path: "/Raw-Video", MaxBytes: 100}}, fakeSizer{}, &memoryStore{})
+	assert.NoError(t, err)
+}
+
+func TestMonitor_Exceeded(t *testing.T) {
+	sizer := fakeSizer{"/Raw-Video": 150, "/Docs": 10}
+	alerter := &recordingAlerter{}
+	monitor, err := NewMonitor([]Budget{
+		{Path: "/Raw-Video", MaxBytes: 100},
+		{Path: "/Docs", MaxBytes: 100},
+	}, sizer, &memoryStore{}, alerter)
+	require.NoError(t, err)
+
+	require.NoError(t, monitor.Check(context.Background()))
+	require.Len(t, alerter.alerts, 1)
+	assert.Equal(t, "/Raw-Video", alerter.alerts[0].Budget.Path)
+	assert.Equal(t, LevelExceeded, alerter.alerts[0].Level)
+	assert.Contains(t, alerter.alerts[0].Message(), "exceeded for /Raw-Video")
+
+	// Staying over budget does not repeat the alert
+	require.NoError(t, monitor.Check(context.Background()))
+	assert.Len(t, alerter.alerts, 1)
+
+	// Dropping below and exceeding again alerts again
+	sizer["/Raw-Video"] = 50
+	require.NoError(t, monitor.Check(context.Background()))
+	sizer["/Raw-Video"] = 150
+	require.NoError(t, monitor.Check(context.Background()))
+	assert.Len(t, alerter.alerts, 2)
+}
+
+func TestMonitor_Projected(t *testing.T) {
+	now := time.Now()
+	store := &memoryStore{usage: []db.FolderUsage{
+		{Path: "/Raw-Video", Bytes: 500, ScannedAt: now.Add(-10 * 24 * time.Hour)},
+	}}
+	alerter := &recordingAlerter{}
+
+	// Growing 10 bytes a day from 600: 900 after 30 days, over the budget of 800
+	monitor, err := NewMonitor([]Budget{{Path: "/Raw-Video", MaxBytes: 800, ProjectionDays: 30}},
+		fakeSizer{"/Raw-Video": 600}, store, alerter)
+	require.NoError(t, err)
+	monitor.now = func() time.Time { return now }
+
+	require.NoError(t, monitor.Check(context.Background()))
+	require.Len(t, alerter.alerts, 1)
+	alert := alerter.alerts[0]
+	assert.Equal(t, LevelProjected, alert.Level)
+	assert.Equal(t, int64(600), alert.UsedBytes)
+	assert.Equal(t, int64(900), alert.ProjectedBytes)
+	assert.Len(t, store.usage, 2)
+}
+
+func TestMonitor_ProjectionWithinBudget(t *testing.T) {
+	now := time.Now()
+	store := &memoryStore{usage: []db.FolderUsage{
+		{Path: "/Raw-Video", Bytes: 500, ScannedAt: now.Add(-10 * 24 * time.Hour)},
+	}}
+
+	monitor, err := NewMonitor([]Budget{{Path: "/Raw-Video", MaxBytes: 800, ProjectionDays: 7}},
+		fakeSizer{"/Raw-Video": 600}, store)
+	require.NoError(t, err)
+	monitor.now = func() time.Time { return now }
+
+	alerts, err := monitor.Evaluate(context.Background())
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, LevelOK, alerts[0].Level)
+	assert.Equal(t, int64(670), alerts[0].ProjectedBytes)
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", formatBytes(512))
+	assert.Equal(t, "1.5 KiB", formatBytes(1536))
+	assert.Equal(t, "2.0 TiB", formatBytes(2<<40))
+}
@@ -0,0 +1,131 @@
+// Package redact masks secrets, such as access tokens, passwords and API
+// keys, in text written to logs, error messages and reports.
+package redact
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Mask replaces secrets
+const Mask = "[REDACTED]"
+
+// minSecretLength is the length below which secrets are not masked, as such
+// short values would mangle ordinary words
+const minSecretLength = 6
+
+// sensitiveKey matches keys whose values are always masked
+var sensitiveKey = regexp.MustCompile(`(?i)(token|secret|password|passwd|authorization|api_?key)`)
+
+// bearerToken matches bearer credentials written into text
+var bearerToken = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`)
+
+// Redactor masks a set of secret values
+type Redactor struct {
+	mu       sync.RWMutex
+	secrets  map[string]bool
+	replacer *strings.Replacer
+}
+
+// New creates a redactor for the given secret values
+func New(secrets ...string) *Redactor {
+	r := &Redactor{secrets: make(map[string]bool)}
+	r.Add(secrets...)
+	return r
+}
+
+// Add adds secret values; values shorter than six characters are ignored
+func (r *Redactor) Add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			r.secrets[secret] = true
+		}
+	}
+	var pairs []string
+	for secret := range r.secrets {
+		pairs = append(pairs, secret, Mask)
+	}
+	if len(pairs) > 0 {
+		r.replacer = strings.NewReplacer(pairs...)
+	}
+}
+
+// String masks the secrets and bearer credentials in s
+func (r *Redactor) String(s string) string {
+	r.mu.RLock()
+	replacer := r.replacer
+	r.mu.RUnlock()
+
+	if replacer != nil {
+		s = replacer.Replace(s)
+	}
+	return bearerToken.ReplaceAllString(s, "${1}"+Mask)
+}
+
+// Value masks value as stored under key: entirely when the key names a
+// credential, and otherwise the secrets in strings, errors and nested maps
+func (r *Redactor) Value(key string, value interface{}) interface{} {
+	if SensitiveKey(key) {
+		return Mask
+	}
+	switch v := value.(type) {
+	case string:
+		return r.String(v)
+	case error:
+		return r.String(v.Error())
+	case map[string]interface{}:
+		return r.Map(v)
+	case map[string]string:
+		masked := make(map[string]string, len(v))
+		for k, s := range v {
+			masked[k] = r.Value(k, s).(string)
+		}
+		return masked
+	}
+	return value
+}
+
+// Map returns a copy of details with every value masked by Value
+func (r *Redactor) Map(details map[string]interface{}) map[string]interface{} {
+	if details == nil {
+		return nil
+	}
+	masked := make(map[string]interface{}, len(details))
+	for key, value := range details {
+		masked[key] = r.Value(key, value)
+	}
+	return masked
+}
+
+// SensitiveKey reports whether key names a credential, such as a token or
+// password
+func SensitiveKey(key string) bool {
+	return sensitiveKey.MatchString(key)
+}
+
+// defaultRedactor holds the secrets of the running monitor
+var defaultRedactor = New()
+
+// Register adds secret values to the default redactor
+func Register(secrets ...string) {
+	defaultRedactor.Add(secrets...)
+}
+
+// Default returns the redactor holding the registered secrets
+func Default() *Redactor {
+	return defaultRedactor
+}
+
+// String masks the registered secrets and bearer credentials in s
+func String(s string) string {
+	return defaultRedactor.String(s)
+}
+
+// Map masks the registered secrets and credential keys in details
+func Map(details map[string]interface{}) map[string]interface{} {
+	return defaultRedactor.Map(details)
+}
@@ -0,0 +1,36 @@
+package redact
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactor_String(t *testing.T) {
+	r := New("sl.abcdef123456", "short")
+	r.Add("smtp-password")
+
+	assert.Equal(t, "token "+Mask+" and "+Mask, r.String("token sl.abcdef123456 and smtp-password"))
+	assert.Equal(t, "Authorization: Bearer "+Mask, r.String("Authorization: Bearer xyz.789"))
+	assert.Equal(t, "a short word", r.String("a short word"), "short secrets are not masked")
+}
+
+func TestRedactor_Map(t *testing.T) {
+	r := New("sl.abcdef123456")
+
+	masked := r.Map(map[string]interface{}{
+		"api_key": "anything",
+		"error":   errors.New("request with sl.abcdef123456 failed"),
+		"headers": map[string]string{"Authorization": "Basic abc", "Accept": "json"},
+		"config":  map[string]interface{}{"smtp_password": "hunter22", "host": "mail"},
+		"count":   3,
+	})
+
+	assert.Equal(t, Mask, masked["api_key"])
+	assert.Equal(t, "request with "+Mask+" failed", masked["error"])
+	assert.Equal(t, map[string]string{"Authorization": Mask, "Accept": "json"}, masked["headers"])
+	assert.Equal(t, map[string]interface{}{"smtp_password": Mask, "host": "mail"}, masked["config"])
+	assert.Equal(t, 3, masked["count"])
+	assert.Nil(t, r.Map(nil))
+}
@@ -0,0 +1,174 @@
+package generators
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// ExportColumns are the column headings of CSV and XLSX change exports
+var ExportColumns = []string{"path", "directory", "extension", "file_type", "size", "modified", "modified_by", "deleted", "account", "change_type", "previous_path", "details"}
+
+// sizeColumn is the index of the numeric size column in ExportColumns
+const sizeColumn = 4
+
+// exportRow returns the export cells of a change in ExportColumns order
+func exportRow(change models.FileChange) []string {
+	return []string{
+		change.Path,
+		change.Directory,
+		change.Extension,
+		change.FileType(),
+		strconv.FormatInt(change.Size, 10),
+		change.Modified.Format(time.RFC3339),
+		change.ModifiedBy,
+		strconv.FormatBool(change.IsDeleted),
+		change.AccountID,
+		string(change.Type()),
+		change.PreviousPath,
+		change.DetailsString(),
+	}
+}
+
+// WriteCSV writes the changes to w as CSV with a header row
+func WriteCSV(w io.Writer, changes []models.FileChange) error {
+	cw := csv.NewWriter(w)
+	cw.Write(ExportColumns)
+	for _, change := range changes {
+		cw.Write(exportRow(change))
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// xlsxParts are the fixed parts of a single-sheet workbook
+var xlsxParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Changes" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`},
+}
+
+// WriteXLSX writes the changes to w as an Excel workbook with a header row
+// and one row per change. Sizes are numeric cells; everything else is text.
+func WriteXLSX(w io.Writer, changes []models.FileChange) error {
+	zw := zip.NewWriter(w)
+	for _, part := range xlsxParts {
+		fw, err := zw.Create(part.name)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", part.name, err)
+		}
+		if _, err := io.WriteString(fw, part.content); err != nil {
+			return fmt.Errorf("failed to write %s: %w", part.name, err)
+		}
+	}
+
+	fw, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return fmt.Errorf("failed to create worksheet: %w", err)
+	}
+	if _, err := fw.Write(xlsxSheet(changes)); err != nil {
+		return fmt.Errorf("failed to write worksheet: %w", err)
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finish workbook: %w", err)
+	}
+	return nil
+}
+
+// xlsxSheet renders the worksheet XML for the changes
+func xlsxSheet(changes []models.FileChange) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	writeRow := func(row int, cells []string, numeric int) {
+		fmt.Fprintf(&buf, `<row r="%d">`, row)
+		for i, value := range cells {
+			ref := xlsxColumn(i) + strconv.Itoa(row)
+			if i == numeric {
+				fmt.Fprintf(&buf, `<c r="%s"><v>%s</v></c>`, ref, value)
+				continue
+			}
+			fmt.Fprintf(&buf, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+			xml.EscapeText(&buf, []byte(value))
+			buf.WriteString(`</t></is></c>`)
+		}
+		buf.WriteString(`</row>`)
+	}
+
+	writeRow(1, ExportColumns, -1)
+	for i, change := range changes {
+		writeRow(i+2, exportRow(change), sizeColumn)
+	}
+
+	buf.WriteString(`</sheetData></worksheet>`)
+	return buf.Bytes()
+}
+
+// xlsxColumn returns the spreadsheet column letters for a zero-based index
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// CSVGenerator generates reports listing the changes as CSV
+type CSVGenerator struct{}
+
+// NewCSVGenerator creates a new CSV generator
+func NewCSVGenerator() *CSVGenerator {
+	return &CSVGenerator{}
+}
+
+// Generate generates a CSV report
+func (g *CSVGenerator) Generate(ctx context.Context, report *models.Report) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context cancelled: %w", err)
+	}
+
+	if report == nil {
+		return fmt.Errorf("report cannot be nil")
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, report.Changes); err != nil {
+		return fmt.Errorf("failed to write CSV report: %w", err)
+	}
+
+	if report.Metadata == nil {
+		report.Metadata = make(map[string]string)
+	}
+	report.Metadata["content"] = buf.String()
+	report.Type = models.CSVReport
+	return nil
+}
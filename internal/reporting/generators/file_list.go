@@ -6,38 +6,56 @@ import (
 	"fmt"
 	"text/template"
 
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/i18n"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
 )
 
-const fileListTemplate = `Dropbox Change Report - {{ .GeneratedAt.Format "2006-01-02 15:04:05" }}
+const fileListTemplate = `{{ t "report.title" }} - {{ .GeneratedAt.Format "2006-01-02 15:04:05" }}
+{{ if .Account }}{{ t "report.account" }}: {{ .Account.Header }}
+{{ end }}
+{{ t "report.total_changes" }}: {{ .TotalChanges }}
 
-Total Changes: {{ .TotalChanges }}
+{{ t "report.file_changes" }}:
+{{ range .Changes }}  - {{ if .IsDeleted }}[{{ t "report.deleted" }}] {{ else if .IsRenamed }}[{{ t "report.renamed_from" }} {{ .PreviousPath }}] {{ else if eq .Type "shared_link" }}[{{ t "report.shared_link" }}] {{ else if eq .Type "paper" }}[{{ t "report.paper" }}] {{ end }}{{ .Path }} ({{ printf "%.2f" (divideFloat .Size 1048576) }} MB){{ if .URL }} {{ .URL }}{{ end }}{{ with .DetailsString }} [{{ . }}]{{ end }}
+{{ end }}
 
-File Changes:
-{{ range .Changes }}  - {{ if .IsDeleted }}[Deleted] {{ end }}{{ .Path }} ({{ printf "%.2f" (divideFloat .Size 1048576) }} MB)
+{{ t "report.most_active_extensions" }}:
+{{ range $ext, $count := .ExtensionCount }}  - {{ $ext }}: {{ t "report.n_files" $count }}
 {{ end }}
 
-Most Active Extensions:
-{{ range $ext, $count := .ExtensionCount }}  - {{ $ext }}: {{ $count }} files
+{{ t "report.file_types" }}:
+{{ range $type, $count := .FileTypeCount }}  - {{ $type }}: {{ t "report.n_files" $count }}
 {{ end }}
 
-Most Active Directories:
-{{ range $dir, $count := .DirectoryCount }}  - {{ $dir }}: {{ $count }} changes
+{{ t "report.most_active_directories" }}:
+{{ range $dir, $count := .DirectoryCount }}  - {{ $dir }}: {{ t "report.n_changes" $count }}
 {{ end }}
 
-Activity Summary:
-- Total Size: {{ printf "%.2f" (divideFloat .TotalSize 1048576) }} MB
-- Deleted Files: {{ .DeletedCount }}
-- Modified Files: {{ .ModifiedCount }}
-`
+{{ t "report.activity_summary" }}:
+- {{ t "report.total_size" }}: {{ printf "%.2f" (divideFloat .TotalSize 1048576) }} MB
+- {{ t "report.deleted_files" }}: {{ .DeletedCount }}
+- {{ t "report.renamed_files" }}: {{ .RenamedCount }}
+- {{ t "report.modified_files" }}: {{ .ModifiedCount }}
+{{ if .Diffs }}
+{{ t "report.document_changes" }}:
+{{ range .Diffs }}  - {{ .Path }}: {{ .String }}
+{{ end }}{{ end }}{{ if .SecurityEvents }}
+{{ t "report.security_events" }}:
+{{ range .SecurityEvents }}  - {{ .Timestamp.Format "2006-01-02 15:04:05" }} [{{ .Category }}] {{ .Type }}{{ if .Actor }} {{ t "report.by" .Actor }}{{ end }}{{ if .IPAddress }} {{ t "report.from" .IPAddress }}{{ end }}
+{{ end }}{{ end }}{{ if .Gaps }}
+{{ t "file_list.gaps" }}:
+{{ range .Gaps }}  - {{ .String }}
+{{ end }}{{ end }}`
 
 // FileListData represents the data needed for file list report generation
 type FileListData struct {
 	*models.Report
 	TotalSize     int64
 	DeletedCount  int
+	RenamedCount  int
 	ModifiedCount int
 	ExtensionCount map[string]int
+	FileTypeCount  map[string]int
 	DirectoryCount map[string]int
 }
 
@@ -53,23 +71,25 @@ func GenerateFileList(ctx context.Context, report *models.Report) (string, error
 
 	// Calculate additional stats
 	var totalSize int64
-	var deletedCount, modifiedCount int
+	var deletedCount, renamedCount, modifiedCount int
 	extensionCount := make(map[string]int)
+	fileTypeCount := make(map[string]int)
 	directoryCount := make(map[string]int)
 	for _, change := range report.Changes {
 		// Always add to total size
 		totalSize += change.Size
 
-		if change.IsDeleted {
+		switch change.Type() {
+		case models.ChangeDeleted:
 			deletedCount++
-		} else {
+		case models.ChangeRenamed:
+			renamedCount++
+		default:
 			modifiedCount++
 		}
 		
-		// Use the Extension field directly
-		if change.Extension != "" {
-			extensionCount[change.Extension]++
-		}
+		extensionCount[change.ExtensionBucket()]++
+		fileTypeCount[change.FileType()]++
 		
 		// Use the Directory field directly
 		if change.Directory != "" {
@@ -81,15 +101,16 @@ func GenerateFileList(ctx context.Context, report *models.Report) (string, error
 		Report:        report,
 		TotalSize:     totalSize,
 		DeletedCount:  deletedCount,
+		RenamedCount:  renamedCount,
 		ModifiedCount: modifiedCount,
 		ExtensionCount: extensionCount,
+		FileTypeCount:  fileTypeCount,
 		DirectoryCount: directoryCount,
 	}
 
-	funcMap := template.FuncMap{
-		"divideFloat": func(a int64, b float64) float64 {
-			return float64(a) / b
-		},
+	funcMap := template.FuncMap(catalogFuncs(i18n.Lookup(report.Language)))
+	funcMap["divideFloat"] = func(a int64, b float64) float64 {
+		return float64(a) / b
 	}
 
 	tmpl, err := template.New("filelist").Funcs(funcMap).Parse(fileListTemplate)
@@ -3,6 +3,7 @@ package generators
 import (
 	"context"
 
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/i18n"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
 )
 
@@ -10,3 +11,9 @@ import (
 type Generator interface {
 	Generate(ctx context.Context, report *models.Report) error
 }
+
+// catalogFuncs returns the template functions writing a report in the
+// language of catalog: t translates a message and lang names the language
+func catalogFuncs(catalog *i18n.Catalog) map[string]interface{} {
+	return map[string]interface{}{"t": catalog.T, "lang": catalog.Language}
+}
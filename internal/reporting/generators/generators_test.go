@@ -1,7 +1,14 @@
 package generators
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strings"
 	"testing"
 	"time"
 
@@ -104,6 +111,31 @@ func TestHTMLGenerator(t *testing.T) {
 	assert.Contains(t, content, "Modified Files: 2")
 }
 
+func TestHTMLGenerator_AccessibleEmailMarkup(t *testing.T) {
+	report := models.NewReport(models.HTMLReport)
+	changes := createTestChanges()
+	changes[0].Path = "/test/<script>.txt"
+	for _, change := range changes {
+		report.AddChange(change)
+	}
+
+	require.NoError(t, NewHTMLGenerator().Generate(context.Background(), report))
+	content := report.Metadata["content"]
+
+	assert.Contains(t, content, `<html lang="en">`)
+	assert.Contains(t, content, `<meta name="color-scheme" content="light dark">`)
+	assert.Contains(t, content, "@media (prefers-color-scheme: dark)")
+	assert.Contains(t, content, `<table role="presentation"`)
+	assert.Contains(t, content, `<th scope="col" align="left" class="row" style="border-bottom: 2px solid #d0d4da;">File</th>`)
+	assert.Contains(t, content, `<caption class="visually-hidden">`)
+	assert.Contains(t, content, `<section aria-label="Summary">`)
+
+	// Deleted files are labelled in text, not only by colour
+	assert.Contains(t, content, `<td class="row deleted" style="color: #b3261e; border-bottom: 1px solid #e3e6ea;">Deleted</td>`)
+	assert.Contains(t, content, "/test/&lt;script&gt;.txt")
+	assert.NotContains(t, content, "<script>")
+}
+
 func TestNarrativeGenerator(t *testing.T) {
 	generator := NewNarrativeGenerator()
 	require.NotNil(t, generator)
@@ -129,3 +161,429 @@ func TestNarrativeGenerator(t *testing.T) {
 	assert.Contains(t, content, ".jpg (1 files)")
 	assert.Contains(t, content, "3.50 MB")
 }
+
+func TestGenerators_SecurityEvents(t *testing.T) {
+	events := []models.TeamEvent{{
+		Timestamp: time.Date(2025, 2, 12, 9, 0, 0, 0, time.UTC),
+		Category:  "logins",
+		Type:      "login_fail",
+		Actor:     "Alice",
+		IPAddress: "203.0.113.7",
+	}}
+
+	tests := []struct {
+		name      string
+		generator Generator
+		section   string
+	}{
+		{"file list", NewFileListGenerator(), "Security Events:"},
+		{"html", NewHTMLGenerator(), "<h2>Security Events</h2>"},
+		{"narrative", NewNarrativeGenerator(), "Security Activity:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := models.NewReport(models.FileListReport)
+			require.NoError(t, tt.generator.Generate(context.Background(), report))
+			assert.NotContains(t, report.Metadata["content"], tt.section, "section is omitted without events")
+
+			report.SecurityEvents = events
+			require.NoError(t, tt.generator.Generate(context.Background(), report))
+			assert.Contains(t, report.Metadata["content"], tt.section)
+			assert.Contains(t, report.Metadata["content"], "login_fail")
+			assert.Contains(t, report.Metadata["content"], "Alice")
+		})
+	}
+}
+
+func TestGenerators_Details(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator Generator
+		want      string
+	}{
+		{"file list", NewFileListGenerator(), "[client: ACME, ticket: PROJ-42]"},
+		{"html", NewHTMLGenerator(), "client: ACME, ticket: PROJ-42"},
+		{"markdown", NewMarkdownGenerator(), "(1.0 MB, client: ACME, ticket: PROJ-42)"},
+		{"json", NewJSONGenerator(), `"ticket": "PROJ-42"`},
+		{"csv", NewCSVGenerator(), `"client: ACME, ticket: PROJ-42"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := models.NewReport(models.FileListReport)
+			change := createTestChanges()[0]
+			change.Details = map[string]string{"ticket": "PROJ-42", "client": "ACME"}
+			report.AddChange(change)
+
+			require.NoError(t, tt.generator.Generate(context.Background(), report))
+			assert.Contains(t, report.Metadata["content"], tt.want)
+		})
+	}
+}
+
+func TestGenerators_MonitoringGaps(t *testing.T) {
+	start := time.Date(2025, 2, 12, 9, 0, 0, 0, time.UTC)
+	gaps := []models.MonitoringGap{{Start: start, End: start.Add(20 * time.Minute), Reason: "Dropbox API rate limit exhausted"}}
+
+	tests := []struct {
+		name      string
+		generator Generator
+		section   string
+	}{
+		{"file list", NewFileListGenerator(), "Monitoring Gaps"},
+		{"html", NewHTMLGenerator(), "<h2>Monitoring Gaps</h2>"},
+		{"narrative", NewNarrativeGenerator(), "Monitoring was interrupted"},
+		{"markdown", NewMarkdownGenerator(), "### Monitoring Gaps"},
+		{"json", NewJSONGenerator(), `"gaps"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := models.NewReport(models.FileListReport)
+			require.NoError(t, tt.generator.Generate(context.Background(), report))
+			assert.NotContains(t, report.Metadata["content"], tt.section, "section is omitted without gaps")
+
+			report.Gaps = gaps
+			require.NoError(t, tt.generator.Generate(context.Background(), report))
+			assert.Contains(t, report.Metadata["content"], tt.section)
+			assert.Contains(t, report.Metadata["content"], "rate limit exhausted")
+		})
+	}
+}
+
+func TestNarrativeGenerator_Highlights(t *testing.T) {
+	report := models.NewReport(models.NarrativeReport)
+	now := time.Date(2025, 2, 12, 10, 0, 0, 0, time.UTC)
+	for _, name := range []string{"plan.docx", "plan_v2.docx", "plan final.docx"} {
+		report.AddChange(models.FileChange{Path: "/work/" + name, Extension: ".docx", Directory: "/work", Modified: now})
+		now = now.Add(time.Hour)
+	}
+
+	require.NoError(t, NewNarrativeGenerator().Generate(context.Background(), report))
+	content := report.Metadata["content"]
+	assert.Contains(t, content, "Highlights:")
+	assert.Contains(t, content, "Document revision cycle: plan final.docx in /work revised 3 times")
+	assert.Less(t, strings.Index(content, "Highlights:"), strings.Index(content, "File Activity:"))
+
+	require.NoError(t, NewNarrativeGeneratorWithClassifier(nil).Generate(context.Background(), report))
+	assert.NotContains(t, report.Metadata["content"], "Highlights:")
+}
+
+func TestJSONGenerator(t *testing.T) {
+	generator := NewJSONGenerator()
+	require.NotNil(t, generator)
+
+	report := models.NewReport(models.JSONReport)
+	report.Title = "Weekly changes"
+	report.Account = &models.AccountInfo{Name: "Jane Doe"}
+	for _, change := range createTestChanges() {
+		report.AddChange(change)
+	}
+
+	require.NoError(t, generator.Generate(context.Background(), report))
+
+	var doc JSONReportDocument
+	require.NoError(t, json.Unmarshal([]byte(report.Metadata["content"]), &doc))
+	assert.Equal(t, JSONSchemaVersion, doc.SchemaVersion)
+	assert.Equal(t, models.JSONReport, doc.Type)
+	assert.Equal(t, "Weekly changes", doc.Title)
+	assert.Equal(t, "Jane Doe", doc.Account.Name)
+	assert.Len(t, doc.Changes, 3)
+	assert.Equal(t, 3, doc.Summary.TotalChanges)
+	assert.Equal(t, 1, doc.Summary.DeletedCount)
+	assert.Equal(t, 2, doc.Summary.ModifiedCount)
+	assert.Equal(t, int64(3.5*1024*1024), doc.Summary.TotalSize)
+	assert.Equal(t, 2, doc.Summary.ExtensionCount[".txt"])
+	assert.Equal(t, 2, doc.Summary.DirectoryCount["/test"])
+
+	// Empty reports still encode an empty change list
+	empty := models.NewReport(models.JSONReport)
+	require.NoError(t, generator.Generate(context.Background(), empty))
+	assert.Contains(t, empty.Metadata["content"], `"changes": []`)
+}
+
+func TestCSVGenerator(t *testing.T) {
+	report := models.NewReport(models.CSVReport)
+	for _, change := range createTestChanges() {
+		report.AddChange(change)
+	}
+
+	require.NoError(t, NewCSVGenerator().Generate(context.Background(), report))
+	assert.Equal(t, models.CSVReport, report.Type)
+
+	records, err := csv.NewReader(strings.NewReader(report.Metadata["content"])).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	assert.Equal(t, ExportColumns, records[0])
+	assert.Equal(t, []string{"/test/file2.jpg", "/test", ".jpg", "image/jpeg", "2097152", "2025-02-12T10:06:00Z", "", "false", "", "modified", "", ""}, records[2])
+	assert.Equal(t, "true", records[3][7])
+	assert.Equal(t, "deleted", records[3][9])
+}
+
+func TestWriteXLSX(t *testing.T) {
+	changes := createTestChanges()
+	changes[0].ModifiedBy = "Jane <J&D>"
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteXLSX(&buf, changes))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	parts := make(map[string]string)
+	for _, file := range zr.File {
+		rc, err := file.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		parts[file.Name] = string(data)
+	}
+
+	require.Contains(t, parts, "[Content_Types].xml")
+	require.Contains(t, parts, "xl/workbook.xml")
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	assert.Contains(t, sheet, `<c r="A1" t="inlineStr"><is><t xml:space="preserve">path</t></is></c>`)
+	assert.Contains(t, sheet, `<c r="E2"><v>1048576</v></c>`)
+	assert.Contains(t, sheet, "Jane &lt;J&amp;D&gt;")
+	assert.Contains(t, sheet, `<row r="4">`)
+
+	// Every part must be well-formed XML
+	for name, content := range parts {
+		decoder := xml.NewDecoder(strings.NewReader(content))
+		for {
+			_, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err, name)
+		}
+	}
+}
+
+func TestXLSXColumn(t *testing.T) {
+	assert.Equal(t, "A", xlsxColumn(0))
+	assert.Equal(t, "Z", xlsxColumn(25))
+	assert.Equal(t, "AA", xlsxColumn(26))
+	assert.Equal(t, "BA", xlsxColumn(52))
+}
+
+func TestMarkdownGenerator(t *testing.T) {
+	report := models.NewReport(models.MarkdownReport)
+	changes := createTestChanges()
+	changes[0].ModifiedBy = "Jane_Doe"
+	for _, change := range changes {
+		report.AddChange(change)
+	}
+
+	require.NoError(t, NewMarkdownGenerator().Generate(context.Background(), report))
+	assert.Equal(t, models.MarkdownReport, report.Type)
+
+	content := report.Metadata["content"]
+	assert.Contains(t, content, "## Dropbox Change Report")
+	assert.Contains(t, content, "**3 changes** · 2 modified · 1 deleted")
+	assert.Contains(t, content, "| Extension | Files |")
+	assert.Contains(t, content, "| .txt | 2 |\n| .jpg | 1 |")
+	assert.Contains(t, content, "| /test | 2 |")
+	assert.Contains(t, content, "- `/test/file1.txt` (1.0 MB, by Jane\\_Doe)")
+	assert.Contains(t, content, "- ~~`/test/subdir/file3.txt`~~ deleted")
+}
+
+func TestGenerators_Renames(t *testing.T) {
+	newReport := func() *models.Report {
+		report := models.NewReport(models.FileListReport)
+		for _, change := range createTestChanges() {
+			report.AddChange(change)
+		}
+		report.AddChange(models.FileChange{
+			Path:         "/archive/file4.txt",
+			Extension:    ".txt",
+			Directory:    "/archive",
+			Modified:     time.Date(2025, 2, 12, 10, 6, 0, 0, time.UTC),
+			Size:         1024,
+			ChangeType:   models.ChangeRenamed,
+			PreviousPath: "/test/file4.txt",
+		})
+		return report
+	}
+	ctx := context.Background()
+
+	content, err := GenerateFileList(ctx, newReport())
+	require.NoError(t, err)
+	assert.Contains(t, content, "- [Renamed from /test/file4.txt] /archive/file4.txt")
+	assert.Contains(t, content, "- Renamed Files: 1")
+	assert.Contains(t, content, "- Modified Files: 2")
+
+	report := newReport()
+	require.NoError(t, NewHTMLGenerator().Generate(ctx, report))
+	assert.Contains(t, report.Metadata["content"], "Renamed from <span style=\"word-break: break-all;\">/test/file4.txt</span>")
+
+	report = newReport()
+	require.NoError(t, NewJSONGenerator().Generate(ctx, report))
+	var doc JSONReportDocument
+	require.NoError(t, json.Unmarshal([]byte(report.Metadata["content"]), &doc))
+	assert.Equal(t, 1, doc.Summary.RenamedCount)
+	assert.Equal(t, 2, doc.Summary.ModifiedCount)
+	assert.Equal(t, models.ChangeRenamed, doc.Changes[3].ChangeType)
+
+	report = newReport()
+	require.NoError(t, NewMarkdownGenerator().Generate(ctx, report))
+	assert.Contains(t, report.Metadata["content"], "**4 changes** · 2 modified · 1 renamed · 1 deleted")
+	assert.Contains(t, report.Metadata["content"], "- `/archive/file4.txt` (renamed from `/test/file4.txt`, 1.0 KB)")
+
+	report = newReport()
+	require.NoError(t, NewNarrativeGenerator().Generate(ctx, report))
+	assert.Contains(t, report.Metadata["content"], "1 files were renamed or moved")
+}
+
+func TestGenerators_SharingChanges(t *testing.T) {
+	newReport := func() *models.Report {
+		report := models.NewReport(models.FileListReport)
+		report.AddChange(models.FileChange{
+			Path:       "/docs/plan.pdf",
+			Modified:   time.Date(2025, 2, 12, 10, 6, 0, 0, time.UTC),
+			ChangeType: models.ChangeSharedLink,
+			URL:        "https://db.tt/plan",
+		})
+		report.AddChange(models.FileChange{
+			Path:       "/Paper/Roadmap.paper",
+			Modified:   time.Date(2025, 2, 12, 10, 7, 0, 0, time.UTC),
+			ChangeType: models.ChangePaper,
+			URL:        "https://paper.dropbox.com/doc/d1",
+		})
+		return report
+	}
+	ctx := context.Background()
+
+	content, err := GenerateFileList(ctx, newReport())
+	require.NoError(t, err)
+	assert.Contains(t, content, "- [Shared link] /docs/plan.pdf (0.00 MB) https://db.tt/plan")
+	assert.Contains(t, content, "- [Paper] /Paper/Roadmap.paper (0.00 MB) https://paper.dropbox.com/doc/d1")
+
+	report := newReport()
+	require.NoError(t, NewHTMLGenerator().Generate(ctx, report))
+	assert.Contains(t, report.Metadata["content"], `<a href="https://db.tt/plan">Shared link</a>`)
+
+	report = newReport()
+	require.NoError(t, NewMarkdownGenerator().Generate(ctx, report))
+	assert.Contains(t, report.Metadata["content"], "- `/Paper/Roadmap.paper` ([Paper doc](https://paper.dropbox.com/doc/d1) edited, 0 B)")
+}
+
+func TestMarkdownGenerator_TruncatesFileList(t *testing.T) {
+	report := models.NewReport(models.MarkdownReport)
+	for i := 0; i < markdownMaxFiles+3; i++ {
+		report.AddChange(models.FileChange{Path: "/a.txt", Directory: "/", Extension: ".txt"})
+	}
+
+	require.NoError(t, NewMarkdownGenerator().Generate(context.Background(), report))
+	assert.Equal(t, markdownMaxFiles, strings.Count(report.Metadata["content"], "- `/a.txt`"))
+	assert.Contains(t, report.Metadata["content"], "- …and 3 more")
+}
+
+func TestMarkdownEscaping(t *testing.T) {
+	assert.Equal(t, `a\|b \*c\*`, markdownText("a|b *c*"))
+	assert.Equal(t, "`/x.txt`", markdownCode("/x.txt"))
+	assert.Equal(t, "``/a`b.txt``", markdownCode("/a`b.txt"))
+}
+
+func TestGenerators_Language(t *testing.T) {
+	for _, tc := range []struct {
+		generator Generator
+		want      []string
+	}{
+		{NewFileListGenerator(), []string{"Dropbox-veranderingsverslag", "Totale veranderinge: 3", "[Geskrap] /test/subdir/file3.txt", "Mees aktiewe gidse:"}},
+		{NewNarrativeGenerator(), []string{"Dropbox-aktiwiteitsverslag", "Gedurende hierdie tydperk was daar 3 lêerveranderinge", "- 1 lêers is geskrap"}},
+		{NewHTMLGenerator(), []string{`<html lang="af">`, "<h2>Lêerveranderinge</h2>", ">Geskrap</td>"}},
+		{NewMarkdownGenerator(), []string{"## Dropbox-veranderingsverslag", "**3 veranderinge** · 2 gewysig · 1 geskrap", "### Veranderde lêers", "~~ geskrap"}},
+	} {
+		report := models.NewReport(models.FileListReport)
+		report.Language = "af"
+		for _, change := range createTestChanges() {
+			report.AddChange(change)
+		}
+		require.NoError(t, tc.generator.Generate(context.Background(), report))
+		for _, want := range tc.want {
+			assert.Contains(t, report.Metadata["content"], want)
+		}
+		assert.NotContains(t, report.Metadata["content"], "Total Changes")
+	}
+}
+
+func TestGenerators_Links(t *testing.T) {
+	newReport := func() *models.Report {
+		report := models.NewReport(models.HTMLReport)
+		for _, change := range createTestChanges() {
+			report.AddChange(change)
+		}
+		report.Links = map[string]models.FileLink{
+			"/test/file1.txt": {URL: "https://dl.dropboxusercontent.com/t/a b(1)", Temporary: true},
+			"/test/file2.jpg": {URL: "https://db.tt/photo", Thumbnail: []byte("jpeg")},
+		}
+		return report
+	}
+	ctx := context.Background()
+
+	report := newReport()
+	require.NoError(t, NewHTMLGenerator().Generate(ctx, report))
+	content := report.Metadata["content"]
+	assert.Contains(t, content, `<a href="https://db.tt/photo">/test/file2.jpg</a>`)
+	assert.Contains(t, content, `<img src="cid:`+models.ThumbnailContentID("/test/file2.jpg")+`"`)
+	assert.Equal(t, 1, strings.Count(content, "<img "))
+	assert.Contains(t, content, ">/test/subdir/file3.txt<")
+
+	report = newReport()
+	require.NoError(t, NewMarkdownGenerator().Generate(ctx, report))
+	content = report.Metadata["content"]
+	assert.Contains(t, content, "- [`/test/file1.txt`](https://dl.dropboxusercontent.com/t/a%20b%281%29) (")
+	assert.Contains(t, content, "- [`/test/file2.jpg`](https://db.tt/photo) (")
+	assert.Contains(t, content, "- ~~`/test/subdir/file3.txt`~~")
+}
+
+func TestGenerators_ExecutiveSummary(t *testing.T) {
+	for _, tc := range []struct {
+		generator Generator
+		want      string
+	}{
+		{NewHTMLGenerator(), "<h2>Executive Summary</h2>\n                <p>Budget work &amp; cleanup.</p>"},
+		{NewMarkdownGenerator(), "### Executive Summary\n\nBudget work & cleanup.\n"},
+		{NewNarrativeGenerator(), "Executive Summary:\nBudget work & cleanup.\n"},
+	} {
+		report := models.NewReport(models.HTMLReport)
+		for _, change := range createTestChanges() {
+			report.AddChange(change)
+		}
+		report.Summary = "Budget work & cleanup."
+		require.NoError(t, tc.generator.Generate(context.Background(), report))
+		assert.Contains(t, report.Metadata["content"], tc.want)
+	}
+}
+
+func TestGenerators_Topics(t *testing.T) {
+	for _, tc := range []struct {
+		generator Generator
+		want      []string
+	}{
+		{NewHTMLGenerator(), []string{"Top Keywords</h3>", "<li>budget: 3 documents</li>", "Top Topics</h3>", "<li>finance: 2 documents</li>", "<li>contract: 1 files</li>"}},
+		{NewMarkdownGenerator(), []string{"### Top Keywords\n\n| Keyword | Documents |\n| --- | ---: |\n| budget | 3 |\n| hiring | 1 |", "| finance | 2 |", "### Top Categories"}},
+		{NewNarrativeGenerator(), []string{"Top Keywords:\n- budget (3 documents)\n- hiring (1 documents)\n", "Top Topics:\n- finance (2 documents)\n", "Top Categories:\n- contract (1 files)\n"}},
+	} {
+		report := models.NewReport(models.HTMLReport)
+		for _, change := range createTestChanges() {
+			report.AddChange(change)
+		}
+		report.AddChange(models.FileChange{Path: "/legal/nda.pdf", Directory: "/legal", DocumentType: "contract"})
+		report.KeywordCount = map[string]int{"hiring": 1, "budget": 3}
+		report.TopicCount = map[string]int{"finance": 2}
+		require.NoError(t, tc.generator.Generate(context.Background(), report))
+		for _, want := range tc.want {
+			assert.Contains(t, report.Metadata["content"], want)
+		}
+	}
+
+	// Reports without content analysis have no keyword or topic sections
+	report := models.NewReport(models.MarkdownReport)
+	report.AddChange(createTestChanges()[0])
+	require.NoError(t, NewMarkdownGenerator().Generate(context.Background(), report))
+	assert.NotContains(t, report.Metadata["content"], "Keywords")
+	assert.NotContains(t, report.Metadata["content"], "Topics")
+}
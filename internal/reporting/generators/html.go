@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"html/template"
 
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/i18n"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
 )
 
@@ -17,132 +18,213 @@ func NewHTMLGenerator() *HTMLGenerator {
 	return &HTMLGenerator{}
 }
 
-const htmlTemplate = `
-<!DOCTYPE html>
-<html>
+// htmlTemplate lays the report out with presentation tables and inline styles
+// so it renders in email clients such as Outlook and Gmail. The style block
+// only adds dark-mode colours for clients that honour prefers-color-scheme.
+const htmlTemplate = `<!DOCTYPE html>
+<html lang="{{ lang }}">
 <head>
-    <title>Dropbox Change Report</title>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <meta name="color-scheme" content="light dark">
+    <meta name="supported-color-schemes" content="light dark">
+    <title>{{ t "report.title" }}</title>
     <style>
-        body {
-            font-family: Arial, sans-serif;
-            margin: 20px;
-            line-height: 1.6;
-            color: #333;
-        }
-        .header {
-            background-color: #0061ff;
-            color: white;
-            padding: 20px;
-            margin-bottom: 20px;
-            border-radius: 5px;
-            box-shadow: 0 2px 4px rgba(0,0,0,0.1);
-        }
-        .section {
-            margin-bottom: 30px;
-            padding: 20px;
-            background-color: #f8f9fa;
-            border-radius: 5px;
-            box-shadow: 0 1px 3px rgba(0,0,0,0.05);
-        }
-        .change-item {
-            padding: 10px;
-            margin: 5px 0;
-            border-left: 4px solid #0061ff;
-            background-color: white;
-            transition: transform 0.2s;
-        }
-        .change-item:hover {
-            transform: translateX(5px);
-        }
-        .deleted {
-            border-left-color: #dc3545;
-        }
-        .stats-grid {
-            display: grid;
-            grid-template-columns: repeat(auto-fit, minmax(200px, 1fr));
-            gap: 20px;
-            margin-top: 20px;
-        }
-        .stat-box {
-            background-color: white;
-            padding: 15px;
-            border-radius: 5px;
-            box-shadow: 0 1px 3px rgba(0,0,0,0.05);
-        }
-        .stat-box h3 {
-            margin-top: 0;
-            color: #0061ff;
-        }
-        .file-list {
-            max-height: 400px;
-            overflow-y: auto;
-            padding-right: 10px;
-        }
-        .file-list::-webkit-scrollbar {
-            width: 8px;
-        }
-        .file-list::-webkit-scrollbar-track {
-            background: #f1f1f1;
-        }
-        .file-list::-webkit-scrollbar-thumb {
-            background: #0061ff;
-            border-radius: 4px;
+        :root { color-scheme: light dark; supported-color-schemes: light dark; }
+        .visually-hidden { position: absolute; width: 1px; height: 1px; overflow: hidden; clip: rect(0 0 0 0); white-space: nowrap; }
+        @media (prefers-color-scheme: dark) {
+            .page { background-color: #121212 !important; color: #e6e6e6 !important; }
+            .card { background-color: #1e1e1e !important; color: #e6e6e6 !important; }
+            .header { background-color: #0b3d91 !important; }
+            .muted { color: #b3b3b3 !important; }
+            .accent { color: #8ab4ff !important; }
+            .row { border-color: #333333 !important; }
+            .deleted { color: #ff8a80 !important; }
         }
+        [data-ogsc] .page { background-color: #121212 !important; color: #e6e6e6 !important; }
+        [data-ogsc] .card { background-color: #1e1e1e !important; color: #e6e6e6 !important; }
+        [data-ogsc] .accent { color: #8ab4ff !important; }
+        [data-ogsc] .deleted { color: #ff8a80 !important; }
     </style>
 </head>
-<body>
-    <div class="header">
-        <h1>Dropbox Change Report</h1>
-        <p>Generated at: {{ .GeneratedAt.Format "2006-01-02 15:04:05" }}</p>
-    </div>
-
-    <div class="section">
-        <h2>Summary</h2>
-        <div class="stats-grid">
-            <div class="stat-box">
-                <h3>Overview</h3>
-                <ul>
-                    <li>Total Changes: {{ .TotalChanges }}</li>
-                    <li>Total Size: {{ printf "%.2f" (divideFloat .TotalSize 1048576) }} MB</li>
-                    <li>Deleted Files: {{ .DeletedCount }}</li>
-                    <li>Modified Files: {{ .ModifiedCount }}</li>
+<body class="page" style="margin: 0; padding: 0; background-color: #f4f5f7; color: #222222; font-family: Arial, Helvetica, sans-serif; font-size: 15px; line-height: 1.5;">
+<div role="article" aria-roledescription="email" aria-label="{{ t "report.title" }}" lang="{{ lang }}">
+<table role="presentation" class="page" width="100%" cellpadding="0" cellspacing="0" border="0" style="background-color: #f4f5f7;">
+<tr>
+<td align="center" style="padding: 20px 10px;">
+<table role="presentation" width="100%" cellpadding="0" cellspacing="0" border="0" style="max-width: 680px;">
+    <tr>
+        <td class="header" style="background-color: #0061ff; color: #ffffff; padding: 20px; border-radius: 5px;">
+            <header>
+                <h1 style="margin: 0 0 8px; font-size: 24px; color: #ffffff;">{{ t "report.title" }}</h1>
+                <p style="margin: 0; color: #ffffff;">{{ t "report.generated_at" }}: <time datetime="{{ .GeneratedAt.Format "2006-01-02T15:04:05Z07:00" }}">{{ .GeneratedAt.Format "2006-01-02 15:04:05" }}</time></p>
+                {{ if .Account }}<p style="margin: 4px 0 0; color: #ffffff;">{{ t "report.account" }}: {{ .Account.Header }}</p>{{ end }}
+            </header>
+        </td>
+    </tr>
+    <tr><td style="height: 20px; line-height: 20px; font-size: 0;">&nbsp;</td></tr>
+    <tr>
+        <td class="card" style="background-color: #ffffff; padding: 20px; border-radius: 5px;">
+            <main>
+            {{if .Summary}}
+            <section aria-label="{{ t "report.executive_summary" }}">
+                <h2>{{ t "report.executive_summary" }}</h2>
+                <p>{{.Summary}}</p>
+            </section>
+            {{end}}
+            <section aria-label="{{ t "report.summary" }}">
+                <h2>{{ t "report.summary" }}</h2>
+                <table role="presentation" width="100%" cellpadding="0" cellspacing="0" border="0">
+                    <tr>
+                        <td valign="top" style="padding: 0 0 16px;">
+                            <h3 class="accent" style="margin: 0 0 4px; font-size: 16px; color: #0050d8;">{{ t "report.overview" }}</h3>
+                            <ul style="margin: 0; padding-left: 20px;">
+                                <li>{{ t "report.total_changes" }}: {{ .TotalChanges }}</li>
+                                <li>{{ t "report.total_size" }}: {{ printf "%.2f" (divideFloat .TotalSize 1048576) }} MB</li>
+                                <li>{{ t "report.deleted_files" }}: {{ .DeletedCount }}</li>
+                                <li>{{ t "report.renamed_files" }}: {{ .RenamedCount }}</li>
+                                <li>{{ t "report.modified_files" }}: {{ .ModifiedCount }}</li>
+                            </ul>
+                        </td>
+                    </tr>
+                    <tr>
+                        <td valign="top" style="padding: 0 0 16px;">
+                            <h3 class="accent" style="margin: 0 0 4px; font-size: 16px; color: #0050d8;">{{ t "report.top_extensions" }}</h3>
+                            <ul style="margin: 0; padding-left: 20px;">
+                                {{range $ext, $count := .ExtensionCount}}<li>{{$ext}}: {{t "report.n_files" $count}}</li>
+                                {{end}}
+                            </ul>
+                        </td>
+                    </tr>
+                    <tr>
+                        <td valign="top" style="padding: 0 0 16px;">
+                            <h3 class="accent" style="margin: 0 0 4px; font-size: 16px; color: #0050d8;">{{ t "report.file_types" }}</h3>
+                            <ul style="margin: 0; padding-left: 20px;">
+                                {{range $type, $count := .FileTypeCount}}<li>{{$type}}: {{t "report.n_files" $count}}</li>
+                                {{end}}
+                            </ul>
+                        </td>
+                    </tr>
+                    {{if .KeywordCount}}
+                    <tr>
+                        <td valign="top" style="padding: 0 0 16px;">
+                            <h3 class="accent" style="margin: 0 0 4px; font-size: 16px; color: #0050d8;">{{ t "report.top_keywords" }}</h3>
+                            <ul style="margin: 0; padding-left: 20px;">
+                                {{range .GetTopKeywords 10}}<li>{{.}}: {{t "report.n_documents" (index $.KeywordCount .)}}</li>
+                                {{end}}
+                            </ul>
+                        </td>
+                    </tr>
+                    {{end}}
+                    {{if .TopicCount}}
+                    <tr>
+                        <td valign="top" style="padding: 0 0 16px;">
+                            <h3 class="accent" style="margin: 0 0 4px; font-size: 16px; color: #0050d8;">{{ t "report.top_topics" }}</h3>
+                            <ul style="margin: 0; padding-left: 20px;">
+                                {{range .GetTopTopics 10}}<li>{{.}}: {{t "report.n_documents" (index $.TopicCount .)}}</li>
+                                {{end}}
+                            </ul>
+                        </td>
+                    </tr>
+                    {{end}}
+                    {{if .CategoryCount}}
+                    <tr>
+                        <td valign="top" style="padding: 0 0 16px;">
+                            <h3 class="accent" style="margin: 0 0 4px; font-size: 16px; color: #0050d8;">{{ t "report.top_categories" }}</h3>
+                            <ul style="margin: 0; padding-left: 20px;">
+                                {{range .GetTopCategories 10}}<li>{{.}}: {{t "report.n_files" (index $.CategoryCount .)}}</li>
+                                {{end}}
+                            </ul>
+                        </td>
+                    </tr>
+                    {{end}}
+                    <tr>
+                        <td valign="top">
+                            <h3 class="accent" style="margin: 0 0 4px; font-size: 16px; color: #0050d8;">{{ t "report.most_active_directories" }}</h3>
+                            <ul style="margin: 0; padding-left: 20px;">
+                                {{range $dir, $count := .DirectoryCount}}<li>{{$dir}}: {{t "report.n_changes" $count}}</li>
+                                {{end}}
+                            </ul>
+                        </td>
+                    </tr>
+                </table>
+            </section>
+
+            <section aria-label="{{ t "report.file_changes" }}">
+                <h2>{{ t "report.file_changes" }}</h2>
+                <table width="100%" cellpadding="6" cellspacing="0" border="0" style="border-collapse: collapse; font-size: 14px;">
+                    <caption class="visually-hidden">{{ t "html.caption" }}</caption>
+                    <thead>
+                        <tr>
+                            <th scope="col" align="left" class="row" style="border-bottom: 2px solid #d0d4da;">{{ t "report.file" }}</th>
+                            <th scope="col" align="left" class="row" style="border-bottom: 2px solid #d0d4da;">{{ t "report.status" }}</th>
+                            <th scope="col" align="right" class="row" style="border-bottom: 2px solid #d0d4da;">{{ t "report.size" }}</th>
+                            <th scope="col" align="left" class="row" style="border-bottom: 2px solid #d0d4da;">{{ t "report.modified" }}</th>
+                        </tr>
+                    </thead>
+                    <tbody>
+                        {{range .Changes}}
+                        <tr>
+                            <th scope="row" align="left" class="row" style="font-weight: normal; word-break: break-all; border-bottom: 1px solid #e3e6ea;">{{$link := index $.Links .Path}}{{if $link.Thumbnail}}<img src="{{thumbnail .Path}}" alt="" style="display: block; max-width: 128px; max-height: 128px; margin: 0 0 4px; border: 0;">{{end}}{{if $link.URL}}<a href="{{$link.URL}}">{{.Path}}</a>{{else}}{{.Path}}{{end}}{{with .DetailsString}}<br><span class="muted" style="color: #555555;">{{.}}</span>{{end}}</th>
+                            {{if .IsDeleted}}
+                            <td class="row deleted" style="color: #b3261e; border-bottom: 1px solid #e3e6ea;">{{t "report.deleted"}}</td>
+                            {{else if .IsRenamed}}
+                            <td class="row" style="border-bottom: 1px solid #e3e6ea;">{{t "report.renamed_from"}} <span style="word-break: break-all;">{{.PreviousPath}}</span></td>
+                            {{else if eq .Type "shared_link"}}
+                            <td class="row" style="border-bottom: 1px solid #e3e6ea;">{{if .URL}}<a href="{{.URL}}">{{t "report.shared_link"}}</a>{{else}}{{t "report.shared_link"}}{{end}}</td>
+                            {{else if eq .Type "paper"}}
+                            <td class="row" style="border-bottom: 1px solid #e3e6ea;">{{if .URL}}<a href="{{.URL}}">{{t "report.paper_edited"}}</a>{{else}}{{t "report.paper_edited"}}{{end}}</td>
+                            {{else}}
+                            <td class="row" style="border-bottom: 1px solid #e3e6ea;">{{t "report.modified"}}</td>
+                            {{end}}
+                            <td align="right" class="row" style="white-space: nowrap; border-bottom: 1px solid #e3e6ea;">{{printf "%.2f" (divideFloat .Size 1048576)}} MB</td>
+                            <td class="row" style="white-space: nowrap; border-bottom: 1px solid #e3e6ea;">{{if not .IsDeleted}}<time datetime="{{.Modified.Format "2006-01-02T15:04:05Z07:00"}}">{{.Modified.Format "2006-01-02 15:04:05"}}</time>{{end}}</td>
+                        </tr>
+                        {{end}}
+                    </tbody>
+                </table>
+            </section>
+            {{if .Diffs}}
+            <section aria-label="{{ t "report.document_changes" }}">
+                <h2>{{ t "report.document_changes" }}</h2>
+                <ul style="margin: 0; padding-left: 20px;">
+                    {{range .Diffs}}<li><strong>{{.Path}}</strong>: {{.String}}</li>
+                    {{end}}
                 </ul>
-            </div>
-            <div class="stat-box">
-                <h3>Top Extensions</h3>
-                <ul>
-                    {{range $ext, $count := .ExtensionCount}}
-                    <li>{{$ext}}: {{$count}} files</li>
+            </section>
+            {{end}}
+            {{if .SecurityEvents}}
+            <section aria-label="{{ t "report.security_events" }}">
+                <h2>{{ t "report.security_events" }}</h2>
+                <ul style="margin: 0; padding-left: 20px;">
+                    {{range .SecurityEvents}}<li>
+                        <strong>{{.Type}}</strong> ({{.Category}}) {{t "report.at"}} <time datetime="{{.Timestamp.Format "2006-01-02T15:04:05Z07:00"}}">{{.Timestamp.Format "2006-01-02 15:04:05"}}</time>
+                        {{if .Actor}}<br>{{t "report.actor"}}: {{.Actor}}{{end}}
+                        {{if .IPAddress}}<br>{{t "report.ip_address"}}: {{.IPAddress}}{{end}}
+                        {{if .Description}}<br><span class="muted" style="color: #555555;">{{.Description}}</span>{{end}}
+                    </li>
                     {{end}}
                 </ul>
-            </div>
-            <div class="stat-box">
-                <h3>Most Active Directories</h3>
-                <ul>
-                    {{range $dir, $count := .DirectoryCount}}
-                    <li>{{$dir}}: {{$count}} changes</li>
+            </section>
+            {{end}}
+            {{if .Gaps}}
+            <section aria-label="{{ t "report.monitoring_gaps" }}">
+                <h2>{{ t "report.monitoring_gaps" }}</h2>
+                <p class="muted" style="color: #555555;">{{ t "report.gaps_missing" }}</p>
+                <ul style="margin: 0; padding-left: 20px;">
+                    {{range .Gaps}}<li>{{.String}}</li>
                     {{end}}
                 </ul>
-            </div>
-        </div>
-    </div>
-
-    <div class="section">
-        <h2>File Changes</h2>
-        <div class="file-list">
-            {{range .Changes}}
-            <div class="change-item {{if .IsDeleted}}deleted{{end}}">
-                <strong>{{.Path}}</strong><br>
-                Size: {{printf "%.2f" (divideFloat .Size 1048576)}} MB<br>
-                {{if .IsDeleted}}
-                Status: Deleted<br>
-                {{else}}
-                Modified: {{.Modified.Format "2006-01-02 15:04:05"}}<br>
-                {{end}}
-            </div>
+            </section>
             {{end}}
-        </div>
-    </div>
+            </main>
+        </td>
+    </tr>
+</table>
+</td>
+</tr>
+</table>
+</div>
 </body>
 </html>
 `
@@ -152,6 +234,7 @@ type HTMLData struct {
 	*models.Report
 	TotalSize     int64
 	DeletedCount  int
+	RenamedCount  int
 	ModifiedCount int
 }
 
@@ -167,14 +250,17 @@ func (g *HTMLGenerator) Generate(ctx context.Context, report *models.Report) err
 
 	// Calculate additional stats
 	var totalSize int64
-	var deletedCount, modifiedCount int
+	var deletedCount, renamedCount, modifiedCount int
 	for _, change := range report.Changes {
 		// Always add to total size
 		totalSize += change.Size
 
-		if change.IsDeleted {
+		switch change.Type() {
+		case models.ChangeDeleted:
 			deletedCount++
-		} else {
+		case models.ChangeRenamed:
+			renamedCount++
+		default:
 			modifiedCount++
 		}
 	}
@@ -183,13 +269,17 @@ func (g *HTMLGenerator) Generate(ctx context.Context, report *models.Report) err
 		Report:        report,
 		TotalSize:     totalSize,
 		DeletedCount:  deletedCount,
+		RenamedCount:  renamedCount,
 		ModifiedCount: modifiedCount,
 	}
 
-	funcMap := template.FuncMap{
-		"divideFloat": func(a int64, b float64) float64 {
-			return float64(a) / b
-		},
+	funcMap := template.FuncMap(catalogFuncs(i18n.Lookup(report.Language)))
+	funcMap["divideFloat"] = func(a int64, b float64) float64 {
+		return float64(a) / b
+	}
+	funcMap["thumbnail"] = func(path string) template.URL {
+		// Thumbnails are attached to the email inline, so a cid: URL is safe
+		return template.URL("cid:" + models.ThumbnailContentID(path))
 	}
 
 	tmpl, err := template.New("html").Funcs(funcMap).Parse(htmlTemplate)
@@ -0,0 +1,115 @@
+package generators
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// JSONSchemaVersion is the version of the JSON report format. It is bumped
+// whenever a field is renamed, removed or changes meaning; new fields may be
+// added without a bump.
+const JSONSchemaVersion = 1
+
+// JSONReportDocument is the machine-readable form of a report
+type JSONReportDocument struct {
+	SchemaVersion  int                    `json:"schema_version"`
+	Type           models.ReportType      `json:"type"`
+	Title          string                 `json:"title,omitempty"`
+	Period         string                 `json:"period"`
+	Since          time.Time              `json:"since"`
+	Until          time.Time              `json:"until"`
+	GeneratedAt    time.Time              `json:"generated_at"`
+	Account        *models.AccountInfo    `json:"account,omitempty"`
+	Summary        JSONReportSummary      `json:"summary"`
+	Changes        []models.FileChange    `json:"changes"`
+	Diffs          []models.DiffSummary   `json:"diffs,omitempty"`
+	SecurityEvents []models.TeamEvent     `json:"security_events,omitempty"`
+	Gaps           []models.MonitoringGap `json:"gaps,omitempty"`
+}
+
+// JSONReportSummary holds the aggregate statistics of a JSON report
+type JSONReportSummary struct {
+	TotalChanges   int            `json:"total_changes"`
+	DeletedCount   int            `json:"deleted_count"`
+	RenamedCount   int            `json:"renamed_count"`
+	ModifiedCount  int            `json:"modified_count"`
+	TotalSize      int64          `json:"total_size"`
+	ExtensionCount map[string]int `json:"extension_count"`
+	FileTypeCount  map[string]int `json:"file_type_count"`
+	DirectoryCount map[string]int `json:"directory_count"`
+}
+
+// JSONGenerator generates machine-readable reports for archiving, diffing
+// and posting to other services
+type JSONGenerator struct{}
+
+// NewJSONGenerator creates a new JSON generator
+func NewJSONGenerator() *JSONGenerator {
+	return &JSONGenerator{}
+}
+
+// Generate generates a JSON report
+func (g *JSONGenerator) Generate(ctx context.Context, report *models.Report) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context cancelled: %w", err)
+	}
+
+	if report == nil {
+		return fmt.Errorf("report cannot be nil")
+	}
+
+	doc := JSONReportDocument{
+		SchemaVersion:  JSONSchemaVersion,
+		Type:           models.JSONReport,
+		Title:          report.Title,
+		Period:         report.Period,
+		Since:          report.Since,
+		Until:          report.Until,
+		GeneratedAt:    report.GeneratedAt,
+		Account:        report.Account,
+		Changes:        report.Changes,
+		Diffs:          report.Diffs,
+		SecurityEvents: report.SecurityEvents,
+		Gaps:           report.Gaps,
+		Summary: JSONReportSummary{
+			ExtensionCount: make(map[string]int),
+			FileTypeCount:  make(map[string]int),
+			DirectoryCount: make(map[string]int),
+		},
+	}
+	if doc.Changes == nil {
+		doc.Changes = []models.FileChange{}
+	}
+
+	for _, change := range report.Changes {
+		doc.Summary.TotalChanges++
+		doc.Summary.TotalSize += change.Size
+		switch change.Type() {
+		case models.ChangeDeleted:
+			doc.Summary.DeletedCount++
+		case models.ChangeRenamed:
+			doc.Summary.RenamedCount++
+		default:
+			doc.Summary.ModifiedCount++
+		}
+		doc.Summary.ExtensionCount[change.ExtensionBucket()]++
+		doc.Summary.FileTypeCount[change.FileType()]++
+		doc.Summary.DirectoryCount[change.Directory]++
+	}
+
+	content, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON report: %w", err)
+	}
+
+	if report.Metadata == nil {
+		report.Metadata = make(map[string]string)
+	}
+	report.Metadata["content"] = string(content)
+	report.Type = models.JSONReport
+	return nil
+}
@@ -0,0 +1,203 @@
+package generators
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/i18n"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+const (
+	// markdownTopItems is the number of rows in the extension and directory tables
+	markdownTopItems = 5
+	// markdownMaxFiles is the number of changed files listed before the rest
+	// are summarised
+	markdownMaxFiles = 50
+)
+
+// MarkdownGenerator generates compact Markdown digests suited to chat tools
+// and issue trackers
+type MarkdownGenerator struct{}
+
+// NewMarkdownGenerator creates a new Markdown generator
+func NewMarkdownGenerator() *MarkdownGenerator {
+	return &MarkdownGenerator{}
+}
+
+// Generate generates a Markdown report
+func (g *MarkdownGenerator) Generate(ctx context.Context, report *models.Report) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context cancelled: %w", err)
+	}
+
+	if report == nil {
+		return fmt.Errorf("report cannot be nil")
+	}
+
+	catalog := i18n.Lookup(report.Language)
+	var b strings.Builder
+	title := report.Title
+	if title == "" {
+		title = catalog.T("report.title")
+	}
+	fmt.Fprintf(&b, "## %s\n\n", markdownText(title))
+	fmt.Fprintf(&b, "_%s", catalog.T("report.generated", report.GeneratedAt.Format("2006-01-02 15:04")))
+	if report.Account != nil {
+		fmt.Fprintf(&b, " · %s", markdownText(report.Account.Header()))
+	}
+	b.WriteString("_\n\n")
+
+	var totalSize int64
+	var deleted, renamed int
+	for _, change := range report.Changes {
+		totalSize += change.Size
+		switch change.Type() {
+		case models.ChangeDeleted:
+			deleted++
+		case models.ChangeRenamed:
+			renamed++
+		}
+	}
+	fmt.Fprintf(&b, "**%s** · %s · ", catalog.T("report.n_changes", len(report.Changes)),
+		catalog.T("report.n_modified", len(report.Changes)-deleted-renamed))
+	if renamed > 0 {
+		fmt.Fprintf(&b, "%s · ", catalog.T("report.n_renamed", renamed))
+	}
+	fmt.Fprintf(&b, "%s · %.2f MB\n", catalog.T("report.n_deleted", deleted), float64(totalSize)/(1024*1024))
+
+	if report.Summary != "" {
+		fmt.Fprintf(&b, "\n### %s\n\n%s\n", catalog.T("report.executive_summary"), markdownText(report.Summary))
+	}
+
+	if len(report.Changes) > 0 {
+		writeMarkdownTable(&b, catalog.T("report.top_extensions"), catalog.T("report.extension"), catalog.T("report.files"),
+			report.GetTopExtensions(markdownTopItems), report.ExtensionCount)
+		writeMarkdownTable(&b, catalog.T("report.top_directories"), catalog.T("report.directory"), catalog.T("report.changes"),
+			report.GetTopDirectories(markdownTopItems), report.DirectoryCount)
+		writeMarkdownTable(&b, catalog.T("report.top_keywords"), catalog.T("report.keyword"), catalog.T("report.documents"),
+			report.GetTopKeywords(markdownTopItems), report.KeywordCount)
+		writeMarkdownTable(&b, catalog.T("report.top_topics"), catalog.T("report.topic"), catalog.T("report.documents"),
+			report.GetTopTopics(markdownTopItems), report.TopicCount)
+		writeMarkdownTable(&b, catalog.T("report.top_categories"), catalog.T("report.category"), catalog.T("report.files"),
+			report.GetTopCategories(markdownTopItems), report.CategoryCount)
+
+		fmt.Fprintf(&b, "\n### %s\n\n", catalog.T("report.changed_files"))
+		for i, change := range report.Changes {
+			if i == markdownMaxFiles {
+				fmt.Fprintf(&b, "- %s\n", catalog.T("report.and_more", len(report.Changes)-markdownMaxFiles))
+				break
+			}
+			writeMarkdownChange(&b, catalog, change, report.Links[change.Path])
+		}
+	}
+
+	if len(report.SecurityEvents) > 0 {
+		fmt.Fprintf(&b, "\n### %s\n\n", catalog.T("report.security_events"))
+		for _, event := range report.SecurityEvents {
+			fmt.Fprintf(&b, "- %s **%s** (%s)", event.Timestamp.Format("2006-01-02 15:04"), markdownText(event.Type), markdownText(event.Category))
+			if event.Actor != "" {
+				fmt.Fprintf(&b, " %s", catalog.T("report.by", markdownText(event.Actor)))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if len(report.Gaps) > 0 {
+		fmt.Fprintf(&b, "\n### %s\n\n%s\n\n", catalog.T("report.monitoring_gaps"), catalog.T("report.gaps_missing"))
+		for _, gap := range report.Gaps {
+			fmt.Fprintf(&b, "- %s\n", markdownText(gap.String()))
+		}
+	}
+
+	if report.Metadata == nil {
+		report.Metadata = make(map[string]string)
+	}
+	report.Metadata["content"] = b.String()
+	report.Type = models.MarkdownReport
+	return nil
+}
+
+// writeMarkdownTable writes a two-column table of the given keys and their counts
+func writeMarkdownTable(b *strings.Builder, heading, keyColumn, countColumn string, keys []string, counts map[string]int) {
+	if len(keys) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n### %s\n\n| %s | %s |\n| --- | ---: |\n", heading, keyColumn, countColumn)
+	for _, key := range keys {
+		fmt.Fprintf(b, "| %s | %d |\n", markdownText(key), counts[key])
+	}
+}
+
+// writeMarkdownChange writes a bullet for one changed file in the language
+// of catalog, linking its path to link when set
+func writeMarkdownChange(b *strings.Builder, catalog *i18n.Catalog, change models.FileChange, link models.FileLink) {
+	path := markdownCode(change.Path)
+	if link.URL != "" && !change.IsDeleted {
+		path = fmt.Sprintf("[%s](%s)", path, markdownURL(link.URL))
+	}
+	if change.IsDeleted {
+		fmt.Fprintf(b, "- ~~%s~~ %s\n", path, catalog.T("markdown.deleted"))
+		return
+	}
+	fmt.Fprintf(b, "- %s (", path)
+	switch change.Type() {
+	case models.ChangeRenamed:
+		fmt.Fprintf(b, "%s, ", catalog.T("markdown.renamed_from", markdownCode(change.PreviousPath)))
+	case models.ChangeSharedLink:
+		fmt.Fprintf(b, "%s, ", catalog.T("markdown.shared_link", change.URL))
+	case models.ChangePaper:
+		fmt.Fprintf(b, "%s, ", catalog.T("markdown.paper_edited", change.URL))
+	}
+	b.WriteString(formatMarkdownSize(change.Size))
+	if change.ModifiedBy != "" {
+		fmt.Fprintf(b, ", %s", catalog.T("report.by", markdownText(change.ModifiedBy)))
+	}
+	if details := change.DetailsString(); details != "" {
+		fmt.Fprintf(b, ", %s", markdownText(details))
+	}
+	b.WriteString(")\n")
+}
+
+// formatMarkdownSize formats a byte count with a binary unit
+func formatMarkdownSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
+
+// markdownEscaper escapes characters that Markdown renderers treat as formatting
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "~", `\~`,
+	"[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`, "|", `\|`,
+)
+
+// markdownText escapes s for use in running text on a single line
+func markdownText(s string) string {
+	return markdownEscaper.Replace(strings.Join(strings.Fields(s), " "))
+}
+
+// markdownURL escapes the characters that would end a link destination early
+func markdownURL(u string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29", "<", "%3C", ">", "%3E").Replace(u)
+}
+
+// markdownCode formats s as inline code, using a longer fence when s itself
+// contains backticks
+func markdownCode(s string) string {
+	s = strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return fence + s + fence
+}
@@ -7,45 +7,116 @@ import (
 	"text/template"
 	"time"
 
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/analysis"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/i18n"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
 )
 
-const narrativeTemplate = `Dropbox Activity Report - {{ .Time.Format "2006-01-02 15:04:05" }}
-
-During this period, there were {{ .TotalChanges }} file changes in your Dropbox account.
-
-File Activity:
-{{ if gt .DeletedFiles 0 }}- {{ .DeletedFiles }} files were deleted{{ end }}
-{{ if gt .ModifiedFiles 0 }}- {{ .ModifiedFiles }} files were modified{{ end }}
-
-Most Active Extensions:
-{{ range $ext, $count := .ExtensionCount }}- {{ $ext }} ({{ $count }} files)
+const narrativeTemplate = `{{ t "report.activity_title" }} - {{ .Time.Format "2006-01-02 15:04:05" }}
+{{ if .Account }}{{ t "report.account" }}: {{ .Account.Header }}
+{{ end }}
+{{ if .Summary }}{{ t "report.executive_summary" }}:
+{{ .Summary }}{{ else }}{{ t "narrative.intro" .TotalChanges }}{{ end }}
+{{ if .Events }}
+{{ t "narrative.highlights" }}:
+{{ range .Events }}- {{ .Summary }}
+{{ end }}{{ end }}
+{{ t "narrative.file_activity" }}:
+{{ if gt .DeletedFiles 0 }}- {{ t "narrative.deleted" .DeletedFiles }}{{ end }}
+{{ if gt .RenamedFiles 0 }}- {{ t "narrative.renamed" .RenamedFiles }}{{ end }}
+{{ if gt .ModifiedFiles 0 }}- {{ t "narrative.modified" .ModifiedFiles }}{{ end }}
+
+{{ t "report.most_active_extensions" }}:
+{{ range $ext, $count := .ExtensionCount }}- {{ $ext }} ({{ t "report.n_files" $count }})
 {{ end }}
 
-Most Active Directories:
-{{ range $dir, $count := .DirectoryCount }}- {{ $dir }}: {{ $count }} changes
+{{ t "report.file_types" }}:
+{{ range $type, $count := .FileTypeCount }}- {{ $type }} ({{ t "report.n_files" $count }})
 {{ end }}
 
-Total Size of Changes: {{ printf "%.2f" .TotalSize }} MB`
+{{ t "report.most_active_directories" }}:
+{{ range $dir, $count := .DirectoryCount }}- {{ $dir }}: {{ t "report.n_changes" $count }}
+{{ end }}{{ if .Keywords }}
+{{ t "report.top_keywords" }}:
+{{ range .Keywords }}- {{ .Name }} ({{ t "report.n_documents" .Count }})
+{{ end }}{{ end }}{{ if .Topics }}
+{{ t "report.top_topics" }}:
+{{ range .Topics }}- {{ .Name }} ({{ t "report.n_documents" .Count }})
+{{ end }}{{ end }}{{ if .Categories }}
+{{ t "report.top_categories" }}:
+{{ range .Categories }}- {{ .Name }} ({{ t "report.n_files" .Count }})
+{{ end }}{{ end }}
+
+{{ t "narrative.total_size" }}: {{ printf "%.2f" .TotalSize }} MB{{ if .Diffs }}
+
+{{ t "report.document_changes" }}:
+{{ range .Diffs }}- {{ .Path }}: {{ .String }}
+{{ end }}{{ end }}{{ if .SecurityEvents }}
+
+{{ t "narrative.security_activity" }}:
+{{ range .SecurityEvents }}- {{ .Timestamp.Format "2006-01-02 15:04:05" }}: {{ if .Actor }}{{ t "narrative.triggered" .Actor .Type }}{{ else }}{{ .Type }}{{ end }} ({{ .Category }})
+{{ end }}{{ end }}{{ if .Gaps }}
+
+{{ t "narrative.gaps" }}:
+{{ range .Gaps }}- {{ .String }}
+{{ end }}{{ end }}`
 
 type narrativeData struct {
 	Time           time.Time
 	TotalChanges   int
 	DeletedFiles   int
+	RenamedFiles   int
 	ModifiedFiles  int
 	ExtensionCount map[string]int
+	FileTypeCount  map[string]int
 	DirectoryCount map[string]int
 	TotalSize      float64
+	SecurityEvents []models.TeamEvent
+	Diffs          []models.DiffSummary
+	Account        *models.AccountInfo
+	Events         []models.ChangeEvent
+	Gaps           []models.MonitoringGap
+	Summary        string
+	Keywords       []namedCount
+	Topics         []namedCount
+	Categories     []namedCount
+}
+
+// narrativeTopItems is the number of keywords, topics and categories listed
+const narrativeTopItems = 10
+
+// namedCount is a keyword, topic or category with the number of documents
+// or files it was found in
+type namedCount struct {
+	Name  string
+	Count int
+}
+
+// namedCounts pairs the given keys with their counts
+func namedCounts(keys []string, counts map[string]int) []namedCount {
+	named := make([]namedCount, len(keys))
+	for i, key := range keys {
+		named[i] = namedCount{Name: key, Count: counts[key]}
+	}
+	return named
 }
 
 type narrativeGenerator struct {
-	template *template.Template
+	template   *template.Template
+	classifier *analysis.Classifier
 }
 
-// NewNarrativeGenerator creates a new narrative generator
+// NewNarrativeGenerator creates a new narrative generator that leads with
+// the change events recognised by the default classifier
 func NewNarrativeGenerator() Generator {
-	tmpl := template.Must(template.New("narrative").Parse(narrativeTemplate))
-	return &narrativeGenerator{template: tmpl}
+	return NewNarrativeGeneratorWithClassifier(analysis.NewClassifier(analysis.DefaultClassifierConfig()))
+}
+
+// NewNarrativeGeneratorWithClassifier creates a narrative generator using the
+// given classifier; a nil classifier omits the highlights
+func NewNarrativeGeneratorWithClassifier(classifier *analysis.Classifier) Generator {
+	tmpl := template.Must(template.New("narrative").Funcs(catalogFuncs(nil)).Parse(narrativeTemplate))
+	return &narrativeGenerator{template: tmpl, classifier: classifier}
 }
 
 // Generate generates a narrative report
@@ -61,23 +132,45 @@ func (g *narrativeGenerator) Generate(ctx context.Context, report *models.Report
 	data := &narrativeData{
 		Time:           time.Now(),
 		ExtensionCount: make(map[string]int),
+		FileTypeCount:  make(map[string]int),
 		DirectoryCount: make(map[string]int),
+		SecurityEvents: report.SecurityEvents,
+		Diffs:          report.Diffs,
+		Account:        report.Account,
+		Gaps:           report.Gaps,
+		Summary:        report.Summary,
+		Keywords:       namedCounts(report.GetTopKeywords(narrativeTopItems), report.KeywordCount),
+		Topics:         namedCounts(report.GetTopTopics(narrativeTopItems), report.TopicCount),
+		Categories:     namedCounts(report.GetTopCategories(narrativeTopItems), report.CategoryCount),
+	}
+	if g.classifier != nil {
+		data.Events = g.classifier.Classify(report.Changes)
 	}
 
 	for _, change := range report.Changes {
 		data.TotalChanges++
-		if change.IsDeleted {
+		switch change.Type() {
+		case models.ChangeDeleted:
 			data.DeletedFiles++
-		} else {
+		case models.ChangeRenamed:
+			data.RenamedFiles++
+		default:
 			data.ModifiedFiles++
 		}
-		data.ExtensionCount[change.Extension]++
+		data.ExtensionCount[change.ExtensionBucket()]++
+		data.FileTypeCount[change.FileType()]++
 		data.DirectoryCount[change.Directory]++
 		data.TotalSize += float64(change.Size) / (1024 * 1024) // Convert to MB
 	}
 
+	tmpl, err := g.template.Clone()
+	if err != nil {
+		return fmt.Errorf("failed to clone narrative template: %w", err)
+	}
+	tmpl.Funcs(catalogFuncs(i18n.Lookup(report.Language)))
+
 	var buf bytes.Buffer
-	if err := g.template.Execute(&buf, data); err != nil {
+	if err := tmpl.Execute(&buf, data); err != nil {
 		return fmt.Errorf("failed to execute narrative template: %w", err)
 	}
 
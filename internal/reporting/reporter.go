@@ -2,15 +2,25 @@ package reporting
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/i18n"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/limits"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/metrics"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/notify"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/reporting/generators"
 )
 
+// reportLatency records how long report generation takes
+var reportLatency = metrics.Default.Histogram("report_generation_seconds",
+	"Time taken to generate a report.", nil)
+
 // Reporter interface defines methods for generating and sending reports
 type Reporter interface {
 	lifecycle.Component
@@ -21,20 +31,160 @@ type Reporter interface {
 // reporter implements the Reporter interface
 type reporter struct {
 	*lifecycle.BaseComponent
-	notifier notify.Notifier
+	notifier   notify.Notifier
 	generators map[models.ReportType]generators.Generator
+	limits     *limits.Guard
+	security   SecurityEventSource
+	window     time.Duration
+	diffs      DiffSource
+	account    AccountSource
+	types      TypeSniffer
+	gaps       GapSource
+	language   string
+	links      LinkSource
+	summary    SummarySource
+	topics     TopicSource
+	archive    ReportArchive
+	recipients []string
+	logger     *slog.Logger
+}
+
+// SecurityEventSource provides team log events for the security section of reports
+type SecurityEventSource interface {
+	GetTeamEventsSince(ctx context.Context, since time.Time) ([]models.TeamEvent, error)
+}
+
+// DiffSource summarises how changed documents differ from their last version
+type DiffSource interface {
+	Summarize(ctx context.Context, changes []models.FileChange) []models.DiffSummary
+}
+
+// AccountSource provides the details of the monitored account for report headers
+type AccountSource interface {
+	GetAccountInfo(ctx context.Context) (*models.AccountInfo, error)
+}
+
+// TypeSniffer fills in the MIME type of changes whose type is unknown
+type TypeSniffer interface {
+	SniffTypes(ctx context.Context, changes []models.FileChange)
+}
+
+// GapSource provides the periods in which changes were not being detected,
+// such as while the API rate limit paused monitoring
+type GapSource interface {
+	MonitoringGaps() []models.MonitoringGap
+	// GapsReported forgets the gaps that ended before until
+	GapsReported(until time.Time)
+}
+
+// LinkSource resolves the links that open changed files, with thumbnails of
+// changed images when thumbnails is set
+type LinkSource interface {
+	Resolve(ctx context.Context, changes []models.FileChange, thumbnails bool) map[string]models.FileLink
+}
+
+// TopicSource counts the changed documents mentioning each keyword and topic
+type TopicSource interface {
+	CountTopics(ctx context.Context, changes []models.FileChange) (keywords, topics map[string]int)
+}
+
+// SummarySource writes an executive summary of the changes in a report
+type SummarySource interface {
+	Summarize(ctx context.Context, report *models.Report) (string, error)
+}
+
+// ReportArchive keeps sent reports so they can be browsed and re-sent, with
+// their delivery status
+type ReportArchive interface {
+	ArchiveReport(ctx context.Context, report *models.ArchivedReport) (int64, error)
+	SetReportStatus(ctx context.Context, id int64, status, lastError string) error
+}
+
+// ArchivedReportStore loads archived reports and records attempts to re-send them
+type ArchivedReportStore interface {
+	GetReport(ctx context.Context, id int64) (*models.ArchivedReport, error)
+	UpdateReportDelivery(ctx context.Context, id int64, status, lastError string, at time.Time) error
+	SetReportStatus(ctx context.Context, id int64, status, lastError string) error
+}
+
+// DefaultSecurityWindow is how far back security events are included when no
+// window is configured
+const DefaultSecurityWindow = 24 * time.Hour
+
+// ReporterConfig holds optional reporter settings
+type ReporterConfig struct {
+	// Limits truncates reports to the guard's report size limit
+	Limits *limits.Guard
+	// SecurityEvents, if set, adds recent team events to every report
+	SecurityEvents SecurityEventSource
+	// SecurityWindow is how far back security events are included
+	SecurityWindow time.Duration
+	// Diffs, if set, adds document diff summaries to every report
+	Diffs DiffSource
+	// Account, if set, adds an account header to every report
+	Account AccountSource
+	// Types, if set, sniffs the content of files of unknown type for the
+	// file type statistics
+	Types TypeSniffer
+	// Gaps, if set, adds monitoring gaps to the next report sent
+	Gaps GapSource
+	// Language is the tag of the language reports are written in; empty
+	// is English
+	Language string
+	// Links, if set, links the changed files in HTML and Markdown reports,
+	// with thumbnails of changed images in HTML reports
+	Links LinkSource
+	// Summary, if set, leads narrative, HTML and Markdown reports with an
+	// executive summary of their changes
+	Summary SummarySource
+	// Topics, if set, adds the top keywords and topics of the changed
+	// documents to every report
+	Topics TopicSource
+	// Archive, if set, keeps every report sent with its delivery status
+	Archive ReportArchive
+	// Recipients are the addresses and channels archived reports are
+	// recorded as sent to
+	Recipients []string
 }
 
 // NewReporter creates a new Reporter instance
 func NewReporter(notifier notify.Notifier) (Reporter, error) {
+	return NewReporterWithLimits(notifier, nil)
+}
+
+// NewReporterWithLimits creates a new Reporter whose reports are truncated to
+// the guard's report size limit
+func NewReporterWithLimits(notifier notify.Notifier, guard *limits.Guard) (Reporter, error) {
+	return NewReporterWithConfig(notifier, ReporterConfig{Limits: guard})
+}
+
+// NewReporterWithConfig creates a new Reporter with the given settings
+func NewReporterWithConfig(notifier notify.Notifier, cfg ReporterConfig) (Reporter, error) {
 	if notifier == nil {
 		return nil, fmt.Errorf("notifier cannot be nil")
 	}
 
 	r := &reporter{
 		BaseComponent: lifecycle.NewBaseComponent("Reporter"),
-		notifier:     notifier,
-		generators:   make(map[models.ReportType]generators.Generator),
+		notifier:      notifier,
+		generators:    make(map[models.ReportType]generators.Generator),
+		limits:        cfg.Limits,
+		security:      cfg.SecurityEvents,
+		window:        cfg.SecurityWindow,
+		diffs:         cfg.Diffs,
+		account:       cfg.Account,
+		types:         cfg.Types,
+		gaps:          cfg.Gaps,
+		language:      cfg.Language,
+		links:         cfg.Links,
+		summary:       cfg.Summary,
+		topics:        cfg.Topics,
+		archive:       cfg.Archive,
+		recipients:    cfg.Recipients,
+		logger:        logging.Component(nil, "reporter"),
+	}
+	if r.window <= 0 {
+		r.window = DefaultSecurityWindow
 	}
 	r.SetState(lifecycle.StateInitialized)
 
@@ -42,6 +192,9 @@ func NewReporter(notifier notify.Notifier) (Reporter, error) {
 	r.generators[models.FileListReport] = generators.NewFileListGenerator()
 	r.generators[models.NarrativeReport] = generators.NewNarrativeGenerator()
 	r.generators[models.HTMLReport] = generators.NewHTMLGenerator()
+	r.generators[models.JSONReport] = generators.NewJSONGenerator()
+	r.generators[models.CSVReport] = generators.NewCSVGenerator()
+	r.generators[models.MarkdownReport] = generators.NewMarkdownGenerator()
 
 	return r, nil
 }
@@ -61,20 +214,94 @@ func (r *reporter) GenerateReport(ctx context.Context, changes []models.FileChan
 		return nil, fmt.Errorf("unsupported report type: %s", reportType)
 	}
 
+	start := time.Now()
+	defer func() { reportLatency.Observe(time.Since(start).Seconds()) }()
+
+	if r.types != nil {
+		// Sniff a copy so the caller's changes are left untouched
+		changes = append([]models.FileChange(nil), changes...)
+		r.types.SniffTypes(ctx, changes)
+	}
+
 	report := models.NewReport(reportType)
-	report.GeneratedAt = time.Now()
+	report.GeneratedAt = start
+	report.Language = r.language
 	for _, change := range changes {
 		report.AddChange(change)
 	}
 
+	if r.security != nil {
+		events, err := r.security.GetTeamEventsSince(ctx, report.GeneratedAt.Add(-r.window))
+		if err != nil {
+			// A missing security section should not hold back the change report
+			r.logger.Warn("Failed to load security events", "error", err)
+		} else {
+			report.SecurityEvents = events
+		}
+	}
+
+	if r.account != nil {
+		account, err := r.account.GetAccountInfo(ctx)
+		if err != nil {
+			r.logger.Warn("Failed to load account details", "error", err)
+		} else {
+			report.Account = account
+		}
+	}
+
+	if r.gaps != nil {
+		report.Gaps = r.gaps.MonitoringGaps()
+	}
+
+	if r.diffs != nil {
+		report.Diffs = r.diffs.Summarize(ctx, report.Changes)
+	}
+
+	if r.topics != nil {
+		report.KeywordCount, report.TopicCount = r.topics.CountTopics(ctx, report.Changes)
+	}
+
+	if r.links != nil && (reportType == models.HTMLReport || reportType == models.MarkdownReport) {
+		// Only HTML reports can show thumbnails, inline in the email
+		report.Links = r.links.Resolve(ctx, report.Changes, reportType == models.HTMLReport)
+	}
+
+	if r.summary != nil && len(report.Changes) > 0 && summarized(reportType) {
+		summary, err := r.summary.Summarize(ctx, report)
+		if err != nil {
+			// Reports fall back to their template text without a summary
+			r.logger.Warn("Failed to write executive summary", "error", err)
+		} else {
+			report.Summary = summary
+		}
+	}
+
 	if err := generator.Generate(ctx, report); err != nil {
 		return nil, fmt.Errorf("failed to generate report: %w", err)
 	}
 
+	if content, truncated := r.limits.LimitReport(report.Metadata["content"]); truncated {
+		report.Metadata["content"] = content
+		report.Metadata["truncated"] = "true"
+	}
+
 	return report, nil
 }
 
-// SendReport sends the report using the configured notifier
+// summarized reports whether reports of the given type show an executive
+// summary
+func summarized(reportType models.ReportType) bool {
+	switch reportType {
+	case models.NarrativeReport, models.HTMLReport, models.MarkdownReport:
+		return true
+	}
+	return false
+}
+
+// SendReport sends the report using the configured notifier, archiving it
+// with its delivery status when an archive is configured. The report is
+// archived as sent before it goes out, so a notifier queueing it for retry
+// can record its status against the archive entry.
 func (r *reporter) SendReport(ctx context.Context, report *models.Report) error {
 	if err := ctx.Err(); err != nil {
 		return fmt.Errorf("context cancelled: %w", err)
@@ -88,16 +315,79 @@ func (r *reporter) SendReport(ctx context.Context, report *models.Report) error
 		return fmt.Errorf("report has no content")
 	}
 
+	if r.archive != nil {
+		archived := models.NewArchivedReport(report, r.recipients)
+		archived.Status = models.ReportSent
+		archived.LastAttemptAt = time.Now()
+		if id, archiveErr := r.archive.ArchiveReport(ctx, archived); archiveErr != nil {
+			r.logger.Error("Failed to archive report", "error", archiveErr)
+		} else {
+			report.ArchiveID = id
+		}
+	}
+
+	if err := deliver(ctx, r.notifier, report); err != nil {
+		if report.ArchiveID != 0 {
+			if archiveErr := r.archive.SetReportStatus(ctx, report.ArchiveID, models.ReportFailed, err.Error()); archiveErr != nil {
+				r.logger.Error("Failed to record report delivery", "error", archiveErr)
+			}
+		}
+		return err
+	}
+
+	if r.gaps != nil && len(report.Gaps) > 0 {
+		r.gaps.GapsReported(report.GeneratedAt)
+	}
+
+	return nil
+}
+
+// Resend sends the archived report with the given ID again through notifier,
+// recording the attempt in store
+func Resend(ctx context.Context, notifier notify.Notifier, store ArchivedReportStore, id int64) error {
+	archived, err := store.GetReport(ctx, id)
+	if err != nil {
+		return err
+	}
+	if archived == nil {
+		return fmt.Errorf("report %d not found", id)
+	}
+
+	if err := store.UpdateReportDelivery(ctx, id, models.ReportSent, "", time.Now()); err != nil {
+		return fmt.Errorf("failed to record report delivery: %w", err)
+	}
+	report := archived.Report()
+	report.ArchiveID = id
+	if err := deliver(ctx, notifier, report); err != nil {
+		if statusErr := store.SetReportStatus(ctx, id, models.ReportFailed, err.Error()); statusErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to record report delivery: %w", statusErr))
+		}
+		return err
+	}
+	return nil
+}
+
+// deliver sends a report through notifier, in structured form where supported
+func deliver(ctx context.Context, notifier notify.Notifier, report *models.Report) error {
+	title := report.Title
+	if title == "" {
+		title = i18n.Lookup(report.Language).T("report.changes_title")
+	}
+
 	// Format report message
-	message := fmt.Sprintf("Dropbox Changes Report - %s\n\n%s", 
+	message := fmt.Sprintf("%s - %s\n\n%s", title,
 		report.GeneratedAt.Format("2006-01-02 15:04:05"),
 		report.Metadata["content"])
 
-	// Send report via notifier
-	if err := r.notifier.SendNotification(ctx, message); err != nil {
+	var err error
+	if rn, ok := notifier.(notify.ReportNotifier); ok {
+		err = rn.SendReport(ctx, report, message)
+	} else {
+		err = notifier.SendNotification(ctx, message)
+	}
+	if err != nil {
 		return fmt.Errorf("failed to send report: %w", err)
 	}
-
 	return nil
 }
 
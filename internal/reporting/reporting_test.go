@@ -2,11 +2,16 @@ package reporting
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/limits"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/reporting/generators"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -81,6 +86,167 @@ func TestReporter_GenerateReport(t *testing.T) {
 	assert.Contains(t, content, "Total Changes: 3")
 }
 
+func TestReporter_GenerateJSONReport(t *testing.T) {
+	reporter, err := NewReporter(&mockNotifier{})
+	require.NoError(t, err)
+
+	report, err := reporter.GenerateReport(context.Background(), createTestChanges(), models.JSONReport)
+	require.NoError(t, err)
+	assert.Equal(t, models.JSONReport, report.Type)
+
+	var doc generators.JSONReportDocument
+	require.NoError(t, json.Unmarshal([]byte(report.Metadata["content"]), &doc))
+	assert.Equal(t, generators.JSONSchemaVersion, doc.SchemaVersion)
+	assert.Equal(t, 3, doc.Summary.TotalChanges)
+	assert.Equal(t, "/docs/file1.txt", doc.Changes[0].Path)
+}
+
+func TestReporter_GenerateReportWithLimits(t *testing.T) {
+	guard, err := limits.NewGuard(limits.Config{MaxReportBytes: 120})
+	require.NoError(t, err)
+
+	reporter, err := NewReporterWithLimits(&mockNotifier{}, guard)
+	require.NoError(t, err)
+
+	report, err := reporter.GenerateReport(context.Background(), createTestChanges(), models.FileListReport)
+	require.NoError(t, err)
+
+	assert.Len(t, report.Metadata["content"], 120)
+	assert.Equal(t, "true", report.Metadata["truncated"])
+	assert.Equal(t, int64(1), guard.Stats().ReportsTruncated)
+}
+
+// securitySource returns canned team events and records the requested window start
+type securitySource struct {
+	events []models.TeamEvent
+	since  time.Time
+}
+
+func (s *securitySource) GetTeamEventsSince(ctx context.Context, since time.Time) ([]models.TeamEvent, error) {
+	s.since = since
+	return s.events, nil
+}
+
+func TestReporter_GenerateReportWithSecurityEvents(t *testing.T) {
+	source := &securitySource{events: []models.TeamEvent{{Category: "sharing", Type: "shared_link_create"}}}
+
+	reporter, err := NewReporterWithConfig(&mockNotifier{}, ReporterConfig{SecurityEvents: source, SecurityWindow: time.Hour})
+	require.NoError(t, err)
+
+	report, err := reporter.GenerateReport(context.Background(), createTestChanges(), models.FileListReport)
+	require.NoError(t, err)
+
+	assert.Equal(t, source.events, report.SecurityEvents)
+	assert.Equal(t, report.GeneratedAt.Add(-time.Hour), source.since)
+	assert.Contains(t, report.Metadata["content"], "shared_link_create")
+}
+
+// diffSource returns a canned summary for the first change
+type diffSource struct{}
+
+func (diffSource) Summarize(ctx context.Context, changes []models.FileChange) []models.DiffSummary {
+	return []models.DiffSummary{{Path: changes[0].Path, Added: 120, Removed: 45, Sections: []string{"Budget"}}}
+}
+
+// typeSniffer marks every change as a PDF
+type typeSniffer struct{}
+
+func (typeSniffer) SniffTypes(ctx context.Context, changes []models.FileChange) {
+	for i := range changes {
+		changes[i].ContentType = "application/pdf"
+	}
+}
+
+func TestReporter_GenerateReportFileTypes(t *testing.T) {
+	changes := []models.FileChange{{Path: "/scans/invoice", Directory: "/scans", Size: 1024}}
+
+	reporter, err := NewReporter(&mockNotifier{})
+	require.NoError(t, err)
+	report, err := reporter.GenerateReport(context.Background(), changes, models.FileListReport)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.ExtensionCount[models.NoExtension])
+	assert.Equal(t, 1, report.FileTypeCount[models.UnknownFileType])
+	assert.Contains(t, report.Metadata["content"], "  - none: 1 files")
+
+	reporter, err = NewReporterWithConfig(&mockNotifier{}, ReporterConfig{Types: typeSniffer{}})
+	require.NoError(t, err)
+	report, err = reporter.GenerateReport(context.Background(), changes, models.FileListReport)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.FileTypeCount["application/pdf"])
+	assert.Contains(t, report.Metadata["content"], "  - application/pdf: 1 files")
+	assert.Empty(t, changes[0].ContentType, "the caller's changes should be left untouched")
+}
+
+// accountSource returns canned account details
+type accountSource struct{}
+
+func (accountSource) GetAccountInfo(ctx context.Context) (*models.AccountInfo, error) {
+	return &models.AccountInfo{Name: "Jane Doe", Email: "jane@example.com"}, nil
+}
+
+func TestReporter_GenerateReportWithAccount(t *testing.T) {
+	reporter, err := NewReporterWithConfig(&mockNotifier{}, ReporterConfig{Account: accountSource{}})
+	require.NoError(t, err)
+
+	for _, reportType := range []models.ReportType{models.FileListReport, models.NarrativeReport, models.HTMLReport} {
+		report, err := reporter.GenerateReport(context.Background(), createTestChanges(), reportType)
+		require.NoError(t, err)
+
+		require.NotNil(t, report.Account)
+		assert.Contains(t, report.Metadata["content"], "Account: Jane Doe", reportType)
+	}
+}
+
+func TestReporter_GenerateReportWithDiffs(t *testing.T) {
+	reporter, err := NewReporterWithConfig(&mockNotifier{}, ReporterConfig{Diffs: diffSource{}})
+	require.NoError(t, err)
+
+	for _, reportType := range []models.ReportType{models.FileListReport, models.NarrativeReport, models.HTMLReport} {
+		report, err := reporter.GenerateReport(context.Background(), createTestChanges(), reportType)
+		require.NoError(t, err)
+
+		assert.Len(t, report.Diffs, 1)
+		assert.Contains(t, report.Metadata["content"], "120 / -45 lines, sections changed: Budget", reportType)
+	}
+}
+
+// gapSource records which gaps were reported
+type gapSource struct {
+	gaps     []models.MonitoringGap
+	reported time.Time
+}
+
+func (g *gapSource) MonitoringGaps() []models.MonitoringGap {
+	return g.gaps
+}
+
+func (g *gapSource) GapsReported(until time.Time) {
+	g.reported = until
+}
+
+func TestReporter_ReportsMonitoringGaps(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	source := &gapSource{gaps: []models.MonitoringGap{{Start: start, End: start.Add(20 * time.Minute), Reason: "rate limited"}}}
+	notifier := &mockNotifier{}
+	reporter, err := NewReporterWithConfig(notifier, ReporterConfig{Gaps: source})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	report, err := reporter.GenerateReport(ctx, createTestChanges(), models.FileListReport)
+	require.NoError(t, err)
+	assert.Equal(t, source.gaps, report.Gaps)
+	assert.True(t, source.reported.IsZero(), "gaps stay pending until the report is sent")
+
+	notifier.shouldError = true
+	require.Error(t, reporter.SendReport(ctx, report))
+	assert.True(t, source.reported.IsZero(), "gaps stay pending when sending fails")
+
+	notifier.shouldError = false
+	require.NoError(t, reporter.SendReport(ctx, report))
+	assert.Equal(t, report.GeneratedAt, source.reported)
+	assert.Contains(t, notifier.lastMessage, "rate limited")
+}
+
 func TestReporter_SendReport(t *testing.T) {
 	notifier := &mockNotifier{}
 	reporter, err := NewReporter(notifier)
@@ -104,6 +270,201 @@ func TestReporter_SendReport(t *testing.T) {
 	require.Error(t, err)
 }
 
+// reportNotifier implements notify.ReportNotifier for testing
+type reportNotifier struct {
+	mockNotifier
+	report *models.Report
+}
+
+func (m *reportNotifier) SendReport(ctx context.Context, report *models.Report, message string) error {
+	m.report = report
+	m.lastMessage = message
+	return nil
+}
+
+func TestReporter_SendReportStructured(t *testing.T) {
+	notifier := &reportNotifier{}
+	reporter, err := NewReporter(notifier)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	report, err := reporter.GenerateReport(ctx, createTestChanges(), models.FileListReport)
+	require.NoError(t, err)
+	require.NoError(t, reporter.SendReport(ctx, report))
+
+	assert.Same(t, report, notifier.report)
+	assert.Contains(t, notifier.lastMessage, "Total Changes: 3")
+	assert.Zero(t, notifier.sentMessages, "report notifiers receive the report, not a plain message")
+}
+
+func TestReporter_Language(t *testing.T) {
+	notifier := &mockNotifier{}
+	reporter, err := NewReporterWithConfig(notifier, ReporterConfig{Language: "nl"})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	report, err := reporter.GenerateReport(ctx, createTestChanges(), models.FileListReport)
+	require.NoError(t, err)
+	assert.Equal(t, "nl", report.Language)
+	assert.Contains(t, report.Metadata["content"], "Totaal aantal wijzigingen: 3")
+
+	require.NoError(t, reporter.SendReport(ctx, report))
+	assert.True(t, strings.HasPrefix(notifier.lastMessage, "Dropbox-wijzigingsrapport - "), "the default title is translated")
+}
+
+// linkRecorder links every change and records whether thumbnails were asked for
+type linkRecorder struct {
+	thumbnails []bool
+}
+
+func (l *linkRecorder) Resolve(ctx context.Context, changes []models.FileChange, thumbnails bool) map[string]models.FileLink {
+	l.thumbnails = append(l.thumbnails, thumbnails)
+	links := make(map[string]models.FileLink)
+	for _, change := range changes {
+		links[change.Path] = models.FileLink{URL: "https://db.tt" + change.Path}
+	}
+	return links
+}
+
+func TestReporter_Links(t *testing.T) {
+	links := &linkRecorder{}
+	reporter, err := NewReporterWithConfig(&mockNotifier{}, ReporterConfig{Links: links})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	report, err := reporter.GenerateReport(ctx, createTestChanges(), models.HTMLReport)
+	require.NoError(t, err)
+	assert.Contains(t, report.Metadata["content"], `<a href="https://db.tt/docs/file1.txt">`)
+
+	report, err = reporter.GenerateReport(ctx, createTestChanges(), models.MarkdownReport)
+	require.NoError(t, err)
+	assert.NotEmpty(t, report.Links)
+
+	// Plain text reports are not linked
+	report, err = reporter.GenerateReport(ctx, createTestChanges(), models.FileListReport)
+	require.NoError(t, err)
+	assert.Empty(t, report.Links)
+	assert.Equal(t, []bool{true, false}, links.thumbnails)
+}
+
+// fixedSummary returns summary, or fails with err
+type fixedSummary struct {
+	summary string
+	err     error
+	calls   int
+}
+
+func (f *fixedSummary) Summarize(ctx context.Context, report *models.Report) (string, error) {
+	f.calls++
+	return f.summary, f.err
+}
+
+func TestReporter_ExecutiveSummary(t *testing.T) {
+	summary := &fixedSummary{summary: "Most work went into the documents folder."}
+	reporter, err := NewReporterWithConfig(&mockNotifier{}, ReporterConfig{Summary: summary})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	report, err := reporter.GenerateReport(ctx, createTestChanges(), models.NarrativeReport)
+	require.NoError(t, err)
+	assert.Equal(t, summary.summary, report.Summary)
+	assert.Contains(t, report.Metadata["content"], "Executive Summary:\nMost work went into the documents folder.")
+	assert.NotContains(t, report.Metadata["content"], "During this period")
+
+	// CSV reports have nowhere to show a summary
+	_, err = reporter.GenerateReport(ctx, createTestChanges(), models.CSVReport)
+	require.NoError(t, err)
+	assert.Equal(t, 1, summary.calls)
+
+	// Without a summary the template text is kept
+	summary.err = fmt.Errorf("provider unavailable")
+	report, err = reporter.GenerateReport(ctx, createTestChanges(), models.NarrativeReport)
+	require.NoError(t, err)
+	assert.Empty(t, report.Summary)
+	assert.NotContains(t, report.Metadata["content"], "Executive Summary")
+	assert.Contains(t, report.Metadata["content"], "During this period")
+}
+
+// fixedTopics counts the same keywords and topics for every report
+type fixedTopics struct{}
+
+func (fixedTopics) CountTopics(ctx context.Context, changes []models.FileChange) (map[string]int, map[string]int) {
+	return map[string]int{"budget": len(changes)}, map[string]int{"finance": 1}
+}
+
+func TestReporter_Topics(t *testing.T) {
+	reporter, err := NewReporterWithConfig(&mockNotifier{}, ReporterConfig{Topics: fixedTopics{}})
+	require.NoError(t, err)
+
+	report, err := reporter.GenerateReport(context.Background(), createTestChanges(), models.NarrativeReport)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"budget": 3}, report.KeywordCount)
+	assert.Equal(t, map[string]int{"finance": 1}, report.TopicCount)
+	assert.Contains(t, report.Metadata["content"], "- budget (3 documents)")
+}
+
+// memoryArchive implements ReportArchive and ArchivedReportStore for testing
+type memoryArchive struct {
+	reports map[int64]*models.ArchivedReport
+}
+
+func (a *memoryArchive) ArchiveReport(ctx context.Context, report *models.ArchivedReport) (int64, error) {
+	if a.reports == nil {
+		a.reports = make(map[int64]*models.ArchivedReport)
+	}
+	report.ID = int64(len(a.reports) + 1)
+	a.reports[report.ID] = report
+	return report.ID, nil
+}
+
+func (a *memoryArchive) GetReport(ctx context.Context, id int64) (*models.ArchivedReport, error) {
+	return a.reports[id], nil
+}
+
+func (a *memoryArchive) SetReportStatus(ctx context.Context, id int64, status, lastError string) error {
+	report := a.reports[id]
+	report.Status, report.LastError = status, lastError
+	return nil
+}
+
+func (a *memoryArchive) UpdateReportDelivery(ctx context.Context, id int64, status, lastError string, at time.Time) error {
+	report := a.reports[id]
+	report.Status, report.LastError, report.LastAttemptAt = status, lastError, at
+	report.Attempts++
+	return nil
+}
+
+func TestReporter_ArchivesReports(t *testing.T) {
+	notifier := &mockNotifier{shouldError: true}
+	archive := &memoryArchive{}
+	reporter, err := NewReporterWithConfig(notifier, ReporterConfig{Archive: archive, Recipients: []string{"a@example.com"}})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	report, err := reporter.GenerateReport(ctx, createTestChanges(), models.FileListReport)
+	require.NoError(t, err)
+	require.Error(t, reporter.SendReport(ctx, report))
+
+	require.Len(t, archive.reports, 1)
+	archived := archive.reports[1]
+	assert.Equal(t, models.ReportFailed, archived.Status)
+	assert.NotEmpty(t, archived.LastError)
+	assert.Equal(t, []string{"a@example.com"}, archived.Recipients)
+	assert.Equal(t, 3, archived.Changes)
+	assert.Equal(t, report.Metadata["content"], archived.Content)
+
+	// Re-sending delivers the archived content and records the attempt
+	notifier.shouldError = false
+	require.NoError(t, Resend(ctx, notifier, archive, 1))
+	assert.Equal(t, 1, notifier.sentMessages)
+	assert.Contains(t, notifier.lastMessage, "Total Changes: 3")
+	assert.Equal(t, models.ReportSent, archived.Status)
+	assert.Empty(t, archived.LastError)
+	assert.Equal(t, 2, archived.Attempts)
+
+	assert.EqualError(t, Resend(ctx, notifier, archive, 7), "report 7 not found")
+}
+
 func TestReporter_Lifecycle(t *testing.T) {
 	notifier := &mockNotifier{}
 	reporter, err := NewReporter(notifier)
@@ -0,0 +1,92 @@
+// Package safego runs background goroutines that survive panics: a panic is
+// recovered, logged with its stack trace, counted and handed to the panic
+// handler instead of taking down the process.
+package safego
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/metrics"
+)
+
+// panicsTotal counts the panics recovered in background goroutines
+var panicsTotal = metrics.Default.CounterVec("goroutine_panics_total",
+	"Panics recovered in background goroutines, by goroutine.", "goroutine")
+
+// Panic describes a panic recovered in a background goroutine
+type Panic struct {
+	// Name names the goroutine that panicked
+	Name string
+	// Value is the value passed to panic
+	Value any
+	// Stack is the stack trace of the goroutine when it panicked
+	Stack []byte
+}
+
+// Error implements error
+func (p *Panic) Error() string {
+	return fmt.Sprintf("%s panicked: %v", p.Name, p.Value)
+}
+
+// handler is called with every recovered panic, if set
+var handler atomic.Pointer[func(p *Panic)]
+
+// SetHandler sets the function called after a panic is recovered, such as
+// one raising a notification; nil removes it. The handler runs in the
+// goroutine that panicked, after the panic is logged.
+func SetHandler(h func(p *Panic)) {
+	if h == nil {
+		handler.Store(nil)
+		return
+	}
+	handler.Store(&h)
+}
+
+// Go runs fn in a new goroutine named name, recovering any panic. A nil
+// logger logs to the default logger.
+func Go(logger *slog.Logger, name string, fn func()) {
+	go func() {
+		defer Recover(logger, name)
+		fn()
+	}()
+}
+
+// Call runs fn in the calling goroutine, returning a panic in it as a
+// *Panic error, so loops can carry on with their next iteration
+func Call(logger *slog.Logger, name string, fn func() error) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = recovered(logger, name, value)
+		}
+	}()
+	return fn()
+}
+
+// Recover recovers a panic of the calling goroutine, which must defer it.
+// The goroutine then ends normally, so functions it deferred earlier still
+// run.
+func Recover(logger *slog.Logger, name string) {
+	if value := recover(); value != nil {
+		recovered(logger, name, value)
+	}
+}
+
+// recovered reports the panic of the goroutine name with value
+func recovered(logger *slog.Logger, name string, value any) *Panic {
+	p := &Panic{Name: name, Value: value, Stack: debug.Stack()}
+
+	panicsTotal.With(name).Inc()
+	if logger == nil {
+		logger = logging.Component(nil, "safego")
+	}
+	logger.Error("Recovered panic in background goroutine", "goroutine", name, "panic", fmt.Sprint(value), "stack", string(p.Stack))
+
+	if h := handler.Load(); h != nil {
+		(*h)(p)
+	}
+	return p
+}
@@ -0,0 +1,41 @@
+package safego
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGo_RecoversPanic(t *testing.T) {
+	panics := make(chan *Panic, 1)
+	SetHandler(func(p *Panic) { panics <- p })
+	defer SetHandler(nil)
+	before := panicsTotal.With("test loop").Value()
+
+	done := make(chan struct{})
+	Go(nil, "test loop", func() {
+		defer close(done)
+		panic("boom")
+	})
+
+	<-done
+	p := <-panics
+	assert.Equal(t, "test loop", p.Name)
+	assert.Equal(t, "boom", p.Value)
+	assert.True(t, strings.Contains(string(p.Stack), "safego_test.go"), "the stack leads to the panic")
+	assert.Equal(t, before+1, panicsTotal.With("test loop").Value())
+}
+
+func TestCall(t *testing.T) {
+	errFailed := errors.New("failed")
+	assert.NoError(t, Call(nil, "task", func() error { return nil }))
+	assert.Equal(t, errFailed, Call(nil, "task", func() error { return errFailed }))
+
+	err := Call(nil, "task", func() error { panic("boom") })
+	var p *Panic
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, "task panicked: boom", err.Error())
+}
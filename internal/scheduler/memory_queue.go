@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// memoryQueue is a ChangeQueue held in memory, used until a durable queue is
+// set
+type memoryQueue struct {
+	mu     sync.Mutex
+	queues map[string][]models.FileChange
+}
+
+// newMemoryQueue creates an empty in-memory change queue
+func newMemoryQueue() *memoryQueue {
+	return &memoryQueue{queues: make(map[string][]models.FileChange)}
+}
+
+// AddPendingChanges appends the changes not yet waiting in queue
+func (q *memoryQueue) AddPendingChanges(ctx context.Context, queue string, changes []models.FileChange) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	waiting := make(map[string]bool, len(q.queues[queue]))
+	for _, change := range q.queues[queue] {
+		waiting[change.Key()] = true
+	}
+	for _, change := range changes {
+		if key := change.Key(); !waiting[key] {
+			waiting[key] = true
+			q.queues[queue] = append(q.queues[queue], change)
+		}
+	}
+	return nil
+}
+
+// PendingChanges returns a copy of the changes waiting in queue
+func (q *memoryQueue) PendingChanges(ctx context.Context, queue string) ([]models.FileChange, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]models.FileChange(nil), q.queues[queue]...), nil
+}
+
+// RemovePendingChanges removes changes from queue
+func (q *memoryQueue) RemovePendingChanges(ctx context.Context, queue string, changes []models.FileChange) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	reported := make(map[string]bool, len(changes))
+	for _, change := range changes {
+		reported[change.Key()] = true
+	}
+	remaining := q.queues[queue][:0]
+	for _, change := range q.queues[queue] {
+		if !reported[change.Key()] {
+			remaining = append(remaining, change)
+		}
+	}
+	q.queues[queue] = remaining
+	return nil
+}
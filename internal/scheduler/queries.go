@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// AddSavedQuery schedules a custom report of the changes matching query,
+// covering the changes collected during each interval
+func (s *Scheduler) AddSavedQuery(query models.ChangeQuery, interval time.Duration, reportType models.ReportType) error {
+	if query.Name == "" {
+		return fmt.Errorf("saved query name cannot be empty")
+	}
+
+	if err := s.RegisterTask("query:"+query.Name, interval, func(ctx context.Context) error {
+		return s.runSavedQuery(ctx, query, reportType)
+	}); err != nil {
+		return err
+	}
+	s.queriesMu.Lock()
+	defer s.queriesMu.Unlock()
+	s.queries = append(s.queries, query.Name)
+	return nil
+}
+
+// runSavedQuery generates the custom report for a saved query from the
+// changes collected since its previous run
+func (s *Scheduler) runSavedQuery(ctx context.Context, query models.ChangeQuery, reportType models.ReportType) error {
+	changes, err := s.changes.PendingChanges(ctx, query.Name)
+	if err != nil {
+		return fmt.Errorf("failed to read pending changes for saved query %s: %w", query.Name, err)
+	}
+
+	matched := query.Filter(changes)
+	if err := s.reportingAgent.GenerateCustomReport(ctx, query.Name, matched, reportType); err != nil {
+		return fmt.Errorf("failed to generate report for saved query %s: %w", query.Name, err)
+	}
+
+	if err := s.changes.RemovePendingChanges(ctx, query.Name, changes); err != nil {
+		return fmt.Errorf("failed to remove reported changes for saved query %s: %w", query.Name, err)
+	}
+	return nil
+}
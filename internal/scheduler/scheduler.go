@@ -3,28 +3,58 @@ package scheduler
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/agents"
-	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/interfaces"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/events"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/safego"
 )
 
-// Scheduler manages periodic execution of file change detection and reporting
+// Scheduler reports the changes published by the file change agents on a
+// fixed interval, and runs periodic tasks
 type Scheduler struct {
 	*lifecycle.BaseComponent
-	client        interfaces.DropboxClient
 	reportingAgent agents.ReportingAgent
 	interval      time.Duration
-	stopCh        chan struct{}
+	tasks         []task
+	mu            sync.Mutex
+	// stopCh ends the loops of the current run when stop closes it; done is
+	// closed when the report loop exits. They are guarded by mu.
+	stopCh chan struct{}
+	stop   func()
+	done   chan struct{}
+	// failedReports counts the scheduled reports in a row that failed,
+	// guarded by mu
+	failedReports int
+	reloadCh      chan struct{}
+	logger        *slog.Logger
+	// changes keeps the changes waiting for the next report and for the
+	// next run of each saved query
+	changes ChangeQueue
+	// queriesMu guards the names of the saved queries changes are queued for
+	queriesMu sync.Mutex
+	queries   []string
 }
 
-// NewScheduler creates a new scheduler
-func NewScheduler(client interfaces.DropboxClient, reportingAgent agents.ReportingAgent, interval time.Duration) (*Scheduler, error) {
-	if client == nil {
-		return nil, fmt.Errorf("client cannot be nil")
-	}
+// ChangeQueue keeps the changes waiting to be reported, by queue: "" for the
+// scheduled report and the saved query's name for a saved query. Adding a
+// change already waiting in a queue has no effect.
+type ChangeQueue interface {
+	AddPendingChanges(ctx context.Context, queue string, changes []models.FileChange) error
+	PendingChanges(ctx context.Context, queue string) ([]models.FileChange, error)
+	RemovePendingChanges(ctx context.Context, queue string, changes []models.FileChange) error
+}
+
+// NewScheduler creates a scheduler reporting the changes it collects every
+// interval; Collect must be subscribed to the event bus for it to see any.
+// The changes are kept in memory until SetChangeQueue sets a durable queue.
+func NewScheduler(reportingAgent agents.ReportingAgent, interval time.Duration) (*Scheduler, error) {
 	if reportingAgent == nil {
 		return nil, fmt.Errorf("reporting agent cannot be nil")
 	}
@@ -34,15 +64,21 @@ func NewScheduler(client interfaces.DropboxClient, reportingAgent agents.Reporti
 
 	scheduler := &Scheduler{
 		BaseComponent:  lifecycle.NewBaseComponent("Scheduler"),
-		client:        client,
 		reportingAgent: reportingAgent,
 		interval:      interval,
-		stopCh:        make(chan struct{}),
+		reloadCh:      make(chan struct{}, 1),
+		logger:        logging.Component(nil, "scheduler"),
+		changes:       newMemoryQueue(),
 	}
+	scheduler.resetStop()
 	scheduler.SetState(lifecycle.StateInitialized)
 	return scheduler, nil
 }
 
+// maxFailedReports is how many scheduled reports in a row may fail before
+// the scheduler reports itself unhealthy
+const maxFailedReports = 3
+
 // Start starts the scheduler
 func (s *Scheduler) Start(ctx context.Context) error {
 	if err := s.DefaultStart(ctx); err != nil {
@@ -53,19 +89,55 @@ func (s *Scheduler) Start(ctx context.Context) error {
 		return fmt.Errorf("context cancelled: %w", err)
 	}
 
-	go s.run(ctx)
+	s.mu.Lock()
+	stopCh, done := s.stopCh, make(chan struct{})
+	s.done = done
+	tasks := s.tasks
+	s.mu.Unlock()
+
+	safego.Go(s.logger, "scheduler", func() {
+		defer close(done)
+		s.run(ctx, stopCh)
+	})
+	for _, t := range tasks {
+		safego.Go(s.logger, "task "+t.name, func() { s.runTask(ctx, t, stopCh) })
+	}
 
 	s.SetState(lifecycle.StateRunning)
 	return nil
 }
 
-// Stop stops the scheduler
+// Restart stops the scheduler and starts it again, so reports and tasks
+// resume after their loops exited
+func (s *Scheduler) Restart(ctx context.Context) error {
+	if err := s.Stop(ctx); err != nil {
+		return err
+	}
+	s.resetStop()
+	s.SetState(lifecycle.StateInitialized)
+	return s.Start(ctx)
+}
+
+// resetStop creates the stop channel of the next run
+func (s *Scheduler) resetStop() {
+	stopCh := make(chan struct{})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopCh, s.stop = stopCh, sync.OnceFunc(func() { close(stopCh) })
+	s.failedReports = 0
+}
+
+// Stop stops the scheduler. Stopping it again, for example from both the
+// container and a signal handler, has no effect.
 func (s *Scheduler) Stop(ctx context.Context) error {
 	if err := ctx.Err(); err != nil {
 		return fmt.Errorf("context cancelled: %w", err)
 	}
 
-	close(s.stopCh)
+	s.mu.Lock()
+	stop := s.stop
+	s.mu.Unlock()
+	stop()
 	s.SetState(lifecycle.StateStopped)
 	return nil
 }
@@ -81,6 +153,20 @@ func (s *Scheduler) Health(ctx context.Context) error {
 		return fmt.Errorf("reporting agent unhealthy: %w", err)
 	}
 
+	s.mu.Lock()
+	done, failedReports := s.done, s.failedReports
+	s.mu.Unlock()
+	select {
+	case <-done:
+		if s.State() == lifecycle.StateRunning {
+			return fmt.Errorf("report loop has exited")
+		}
+	default:
+	}
+	if failedReports >= maxFailedReports {
+		return fmt.Errorf("the last %d scheduled reports failed", failedReports)
+	}
+
 	return nil
 }
 
@@ -91,9 +177,6 @@ func (s *Scheduler) Initialize(ctx context.Context) error {
 	}
 
 	// Validate dependencies are ready
-	if s.client == nil {
-		return fmt.Errorf("dropbox client not initialized")
-	}
 	if s.reportingAgent == nil {
 		return fmt.Errorf("reporting agent not initialized")
 	}
@@ -102,52 +185,138 @@ func (s *Scheduler) Initialize(ctx context.Context) error {
 	return nil
 }
 
-// run executes the scheduler loop
-func (s *Scheduler) run(ctx context.Context) {
-	ticker := time.NewTicker(s.interval)
+// Reload applies the poll interval of cfg, a *config.Config, to the running
+// scheduler. The next execution follows the new interval; registered tasks
+// keep theirs.
+func (s *Scheduler) Reload(ctx context.Context, cfg interface{}) error {
+	newConfig, ok := cfg.(*config.Config)
+	if !ok {
+		return fmt.Errorf("unexpected configuration type %T", cfg)
+	}
+	if newConfig.PollInterval <= 0 {
+		return fmt.Errorf("interval must be greater than 0")
+	}
+
+	s.mu.Lock()
+	changed := s.interval != newConfig.PollInterval
+	s.interval = newConfig.PollInterval
+	s.mu.Unlock()
+
+	if changed {
+		select {
+		case s.reloadCh <- struct{}{}:
+		default: // A reload is already pending and will read the new interval
+		}
+	}
+	return nil
+}
+
+// SetChangeQueue sets where the changes waiting to be reported are kept. It
+// must be set before any changes are collected.
+func (s *Scheduler) SetChangeQueue(queue ChangeQueue) {
+	s.changes = queue
+}
+
+// SetLogger sets the logger of the scheduler and its tasks
+func (s *Scheduler) SetLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
+// Interval returns how often changes are reported
+func (s *Scheduler) Interval() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.interval
+}
+
+// run executes the scheduler loop until ctx is done or stopCh is closed
+func (s *Scheduler) run(ctx context.Context, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(s.Interval())
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-s.stopCh:
+		case <-stopCh:
 			return
+		case <-s.reloadCh:
+			ticker.Reset(s.Interval())
 		case <-ticker.C:
-			if err := s.execute(ctx); err != nil {
-				fmt.Printf("Error executing scheduled task: %v\n", err)
+			err := s.execute(ctx)
+			if err != nil {
+				s.logger.Error("Scheduled report failed", "error", err)
 			}
+			s.recordReport(err)
 		}
 	}
 }
 
-// execute performs a single execution of the scheduler
-func (s *Scheduler) execute(ctx context.Context) error {
-	// Get file changes from Dropbox
-	changes, err := s.client.GetChanges(ctx)
+// recordReport counts the scheduled reports in a row that failed
+func (s *Scheduler) recordReport(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if err != nil {
-		return fmt.Errorf("failed to get file changes: %w", err)
+		s.failedReports++
+	} else {
+		s.failedReports = 0
 	}
+}
 
-	if len(changes) == 0 {
-		return nil // No changes to report
-	}
+// Collect queues the changes of a published event for the next report and
+// the next run of every saved query
+func (s *Scheduler) Collect(ctx context.Context, event events.FileChanged) error {
+	s.queriesMu.Lock()
+	queues := append([]string{""}, s.queries...)
+	s.queriesMu.Unlock()
 
-	// Convert to models.FileChange
-	fileChanges := make([]models.FileChange, len(changes))
-	for i, change := range changes {
-		fileChanges[i] = models.FileChange{
-			Path:      change.Path,
-			Size:      change.Size,
-			Modified:  change.Modified,
-			IsDeleted: change.IsDeleted,
+	for _, queue := range queues {
+		if err := s.changes.AddPendingChanges(ctx, queue, event.Changes); err != nil {
+			return fmt.Errorf("failed to queue changes: %w", err)
 		}
 	}
+	return nil
+}
+
+// execute reports the changes collected since the previous report. When the
+// report fails the changes are kept for the next one.
+func (s *Scheduler) execute(ctx context.Context) error {
+	fileChanges, err := s.changes.PendingChanges(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to read pending changes: %w", err)
+	}
+
+	if len(fileChanges) == 0 {
+		return nil // No changes to report
+	}
 
-	// Generate report
 	if err := s.reportingAgent.GenerateReport(ctx, fileChanges); err != nil {
 		return fmt.Errorf("failed to generate report: %w", err)
 	}
 
+	return s.Reported(ctx, fileChanges)
+}
+
+// Reported removes changes reported outside the schedule, such as by a
+// one-off check, from those waiting for the next report
+func (s *Scheduler) Reported(ctx context.Context, changes []models.FileChange) error {
+	if err := s.changes.RemovePendingChanges(ctx, "", changes); err != nil {
+		return fmt.Errorf("failed to remove reported changes: %w", err)
+	}
 	return nil
 }
+
+// Preview renders the report the next execution would send, without sending it
+func (s *Scheduler) Preview(ctx context.Context, reportType models.ReportType) (*models.Report, error) {
+	fileChanges, err := s.changes.PendingChanges(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending changes: %w", err)
+	}
+
+	report, err := s.reportingAgent.PreviewReport(ctx, fileChanges, reportType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to preview report: %w", err)
+	}
+
+	return report, nil
+}
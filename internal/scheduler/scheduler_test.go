@@ -2,52 +2,20 @@ package scheduler
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/agents"
-	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/interfaces"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/events"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
-// MockDropboxClient is a mock implementation of interfaces.DropboxClient
-type MockDropboxClient struct {
-	mock.Mock
-}
-
-func (m *MockDropboxClient) ListFolder(ctx context.Context, path string) ([]*models.FileMetadata, error) {
-	args := m.Called(ctx, path)
-	return args.Get(0).([]*models.FileMetadata), args.Error(1)
-}
-
-func (m *MockDropboxClient) GetFileContent(ctx context.Context, path string) ([]byte, error) {
-	args := m.Called(ctx, path)
-	return args.Get(0).([]byte), args.Error(1)
-}
-
-func (m *MockDropboxClient) GetChanges(ctx context.Context) ([]*models.FileMetadata, error) {
-	args := m.Called(ctx)
-	return args.Get(0).([]*models.FileMetadata), args.Error(1)
-}
-
-func (m *MockDropboxClient) GetChangesLast24Hours(ctx context.Context) ([]*models.FileMetadata, error) {
-	args := m.Called(ctx)
-	return args.Get(0).([]*models.FileMetadata), args.Error(1)
-}
-
-func (m *MockDropboxClient) GetChangesLast10Minutes(ctx context.Context) ([]*models.FileMetadata, error) {
-	args := m.Called(ctx)
-	return args.Get(0).([]*models.FileMetadata), args.Error(1)
-}
-
-func (m *MockDropboxClient) GetFileChanges(ctx context.Context) ([]models.FileChange, error) {
-	args := m.Called(ctx)
-	return args.Get(0).([]models.FileChange), args.Error(1)
-}
-
 // MockReportingAgent is a mock implementation of agents.ReportingAgent
 type MockReportingAgent struct {
 	mock.Mock
@@ -72,6 +40,19 @@ func (m *MockReportingAgent) NotifyChanges(ctx context.Context, changes []models
 	return args.Error(0)
 }
 
+func (m *MockReportingAgent) PreviewReport(ctx context.Context, changes []models.FileChange, reportType models.ReportType) (*models.Report, error) {
+	args := m.Called(ctx, changes, reportType)
+	if report, ok := args.Get(0).(*models.Report); ok {
+		return report, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockReportingAgent) GenerateCustomReport(ctx context.Context, title string, changes []models.FileChange, reportType models.ReportType) error {
+	args := m.Called(ctx, title, changes, reportType)
+	return args.Error(0)
+}
+
 func (m *MockReportingAgent) Start(ctx context.Context) error {
 	args := m.Called(ctx)
 	return args.Error(0)
@@ -95,35 +76,24 @@ func (m *MockReportingAgent) Initialize(ctx context.Context) error {
 func TestNewScheduler(t *testing.T) {
 	tests := []struct {
 		name          string
-		client        interfaces.DropboxClient
 		reportingAgent agents.ReportingAgent
 		interval      time.Duration
 		expectError   bool
 	}{
 		{
 			name:          "valid configuration",
-			client:        new(MockDropboxClient),
 			reportingAgent: NewMockReportingAgent(),
 			interval:      5 * time.Minute,
 			expectError:   false,
 		},
-		{
-			name:          "nil client",
-			client:        nil,
-			reportingAgent: NewMockReportingAgent(),
-			interval:      5 * time.Minute,
-			expectError:   true,
-		},
 		{
 			name:          "nil reporting agent",
-			client:        new(MockDropboxClient),
 			reportingAgent: nil,
 			interval:      5 * time.Minute,
 			expectError:   true,
 		},
 		{
 			name:          "zero interval",
-			client:        new(MockDropboxClient),
 			reportingAgent: NewMockReportingAgent(),
 			interval:      0,
 			expectError:   true,
@@ -132,7 +102,7 @@ func TestNewScheduler(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			scheduler, err := NewScheduler(tt.client, tt.reportingAgent, tt.interval)
+			scheduler, err := NewScheduler(tt.reportingAgent, tt.interval)
 			if tt.expectError {
 				assert.Error(t, err)
 				assert.Nil(t, scheduler)
@@ -148,87 +118,66 @@ func TestNewScheduler(t *testing.T) {
 }
 
 func TestScheduler_Execute(t *testing.T) {
-	tests := []struct {
-		name          string
-		changes       []*models.FileMetadata
-		clientErr     error
-		reportingErr  error
-		expectedError bool
-	}{
-		{
-			name: "successful execution with changes",
-			changes: []*models.FileMetadata{
-				{Path: "/test1.txt", Size: 100, Modified: time.Now()},
-				{Path: "/test2.txt", Size: 200, Modified: time.Now()},
-			},
-			clientErr:     nil,
-			reportingErr:  nil,
-			expectedError: false,
-		},
-		{
-			name:          "successful execution with no changes",
-			changes:       []*models.FileMetadata{},
-			clientErr:     nil,
-			reportingErr:  nil,
-			expectedError: false,
-		},
-		{
-			name:          "client error",
-			changes:       nil,
-			clientErr:     assert.AnError,
-			reportingErr:  nil,
-			expectedError: true,
-		},
-		{
-			name: "reporting error",
-			changes: []*models.FileMetadata{
-				{Path: "/test1.txt", Size: 100, Modified: time.Now()},
-			},
-			clientErr:     nil,
-			reportingErr:  assert.AnError,
-			expectedError: true,
-		},
-	}
+	ctx := context.Background()
+	reportingAgent := NewMockReportingAgent()
+	scheduler, err := NewScheduler(reportingAgent, time.Minute)
+	require.NoError(t, err)
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			client := new(MockDropboxClient)
-			reportingAgent := NewMockReportingAgent()
-			scheduler, _ := NewScheduler(client, reportingAgent, time.Minute)
-
-			client.On("GetChanges", mock.Anything).Return(tt.changes, tt.clientErr)
-			if tt.changes != nil && len(tt.changes) > 0 && tt.clientErr == nil {
-				expectedChanges := make([]models.FileChange, len(tt.changes))
-				for i, change := range tt.changes {
-					expectedChanges[i] = models.FileChange{
-						Path:      change.Path,
-						Size:      change.Size,
-						Modified:  change.Modified,
-						IsDeleted: false,
-					}
-				}
-				reportingAgent.On("GenerateReport", mock.Anything, expectedChanges).Return(tt.reportingErr)
-			}
+	// Nothing is reported without changes
+	require.NoError(t, scheduler.execute(ctx))
 
-			err := scheduler.execute(context.Background())
+	first := []models.FileChange{{Path: "/test1.txt", Size: 100}}
+	second := []models.FileChange{{Path: "/test2.txt", Size: 200}}
+	require.NoError(t, scheduler.Collect(ctx, events.FileChanged{Folder: "/", Changes: first}))
 
-			if tt.expectedError {
-				assert.Error(t, err)
-			} else {
-				assert.NoError(t, err)
-			}
+	// A failed report keeps the changes for the next one
+	reportingAgent.On("GenerateReport", mock.Anything, first).Return(assert.AnError).Once()
+	assert.Error(t, scheduler.execute(ctx))
 
-			client.AssertExpectations(t)
-			reportingAgent.AssertExpectations(t)
-		})
+	require.NoError(t, scheduler.Collect(ctx, events.FileChanged{Folder: "/", Changes: second}))
+	reportingAgent.On("GenerateReport", mock.Anything, append(first, second...)).Return(nil).Once()
+	require.NoError(t, scheduler.execute(ctx))
+
+	// Reported changes are not reported again
+	require.NoError(t, scheduler.execute(ctx))
+	reportingAgent.AssertExpectations(t)
+}
+
+func TestScheduler_ReportsOncePerInterval(t *testing.T) {
+	reportingAgent := NewMockReportingAgent()
+	scheduler, err := NewScheduler(reportingAgent, 10*time.Millisecond)
+	require.NoError(t, err)
+
+	reported := make(chan []models.FileChange, 10)
+	reportingAgent.On("GenerateReport", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		reported <- args.Get(1).([]models.FileChange)
+	})
+
+	// Changes published by two folders in the same interval go out in one report
+	ctx := context.Background()
+	first := []models.FileChange{{Path: "/docs/a.txt"}}
+	second := []models.FileChange{{Path: "/photos/b.jpg"}}
+	require.NoError(t, scheduler.Collect(ctx, events.FileChanged{Folder: "/docs", Changes: first}))
+	require.NoError(t, scheduler.Collect(ctx, events.FileChanged{Folder: "/photos", Changes: second}))
+	require.NoError(t, scheduler.Start(ctx))
+	defer scheduler.Stop(ctx)
+	select {
+	case changes := <-reported:
+		assert.Equal(t, append(first, second...), changes)
+	case <-time.After(time.Second):
+		t.Fatal("changes were not reported")
 	}
+
+	// Later intervals without new changes send nothing
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, reported)
+	reportingAgent.AssertNumberOfCalls(t, "GenerateReport", 1)
 }
 
 func TestScheduler_Lifecycle(t *testing.T) {
 	ctx := context.Background()
-	client := new(MockDropboxClient)
 	reportingAgent := NewMockReportingAgent()
-	scheduler, err := NewScheduler(client, reportingAgent, time.Minute)
+	scheduler, err := NewScheduler(reportingAgent, time.Minute)
 	assert.NoError(t, err)
 
 	// Test Initialize
@@ -253,11 +202,31 @@ func TestScheduler_Lifecycle(t *testing.T) {
 	assert.Equal(t, lifecycle.StateStopped, scheduler.State())
 }
 
+func TestScheduler_StopIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	scheduler, err := NewScheduler(NewMockReportingAgent(), time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, scheduler.RegisterTask("noop", time.Minute, func(ctx context.Context) error { return nil }))
+	require.NoError(t, scheduler.Start(ctx))
+
+	// Concurrent stops, e.g. from the container and a signal handler, must not
+	// close the stop channel twice
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, scheduler.Stop(ctx))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, lifecycle.StateStopped, scheduler.State())
+}
+
 func TestScheduler_Health_Error(t *testing.T) {
 	ctx := context.Background()
-	client := new(MockDropboxClient)
 	reportingAgent := NewMockReportingAgent()
-	scheduler, err := NewScheduler(client, reportingAgent, time.Minute)
+	scheduler, err := NewScheduler(reportingAgent, time.Minute)
 	assert.NoError(t, err)
 
 	reportingAgent.On("Health", mock.Anything).Return(assert.AnError).Once()
@@ -265,3 +234,192 @@ func TestScheduler_Health_Error(t *testing.T) {
 	assert.Error(t, err)
 	reportingAgent.AssertExpectations(t)
 }
+
+func TestScheduler_Preview(t *testing.T) {
+	reportingAgent := NewMockReportingAgent()
+	scheduler, err := NewScheduler(reportingAgent, time.Minute)
+	assert.NoError(t, err)
+
+	expectedChanges := []models.FileChange{{Path: "/test1.txt", Size: 100, Modified: time.Now()}}
+	require.NoError(t, scheduler.Collect(context.Background(), events.FileChanged{Changes: expectedChanges}))
+	expectedReport := models.NewReport(models.HTMLReport)
+	reportingAgent.On("PreviewReport", mock.Anything, expectedChanges, models.HTMLReport).Return(expectedReport, nil)
+
+	report, err := scheduler.Preview(context.Background(), models.HTMLReport)
+	assert.NoError(t, err)
+	assert.Same(t, expectedReport, report)
+
+	reportingAgent.AssertExpectations(t)
+	reportingAgent.AssertNotCalled(t, "GenerateReport", mock.Anything, mock.Anything)
+}
+
+func TestScheduler_ChangeQueue(t *testing.T) {
+	ctx := context.Background()
+	queue := newMemoryQueue()
+	reportingAgent := NewMockReportingAgent()
+	collector, err := NewScheduler(reportingAgent, time.Minute)
+	require.NoError(t, err)
+	collector.SetChangeQueue(queue)
+
+	changes := []models.FileChange{{Path: "/docs/a.txt", Size: 100}, {Path: "/docs/b.txt", Size: 200}}
+	require.NoError(t, collector.Collect(ctx, events.FileChanged{Folder: "/docs", Changes: changes}))
+	// A change detected again is queued once
+	require.NoError(t, collector.Collect(ctx, events.FileChanged{Folder: "/docs", Changes: changes[:1]}))
+
+	// Another scheduler on the same queue, as after a restart, sees the changes
+	restarted, err := NewScheduler(reportingAgent, time.Minute)
+	require.NoError(t, err)
+	restarted.SetChangeQueue(queue)
+	report := models.NewReport(models.FileListReport)
+	reportingAgent.On("PreviewReport", mock.Anything, changes, models.FileListReport).Return(report, nil).Once()
+	got, err := restarted.Preview(ctx, models.FileListReport)
+	require.NoError(t, err)
+	assert.Same(t, report, got)
+
+	// Changes reported by a one-off check are left out of the next report
+	require.NoError(t, restarted.Reported(ctx, changes[:1]))
+	reportingAgent.On("GenerateReport", mock.Anything, changes[1:]).Return(nil).Once()
+	require.NoError(t, restarted.execute(ctx))
+	pending, err := queue.PendingChanges(ctx, "")
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	reportingAgent.AssertExpectations(t)
+}
+
+func TestScheduler_RegisterTask(t *testing.T) {
+	reportingAgent := NewMockReportingAgent()
+	scheduler, err := NewScheduler(reportingAgent, time.Hour)
+	assert.NoError(t, err)
+
+	noop := func(ctx context.Context) error { return nil }
+	assert.Error(t, scheduler.RegisterTask("", time.Minute, noop))
+	assert.Error(t, scheduler.RegisterTask("task", 0, noop))
+	assert.Error(t, scheduler.RegisterTask("task", time.Minute, nil))
+
+	ran := make(chan struct{}, 1)
+	assert.NoError(t, scheduler.RegisterTask("task", 10*time.Millisecond, func(ctx context.Context) error {
+		select {
+		case ran <- struct{}{}:
+		default:
+		}
+		return nil
+	}))
+	assert.Error(t, scheduler.RegisterTask("task", time.Minute, noop), "duplicate names are rejected")
+
+	ctx := context.Background()
+	assert.NoError(t, scheduler.Start(ctx))
+	assert.Error(t, scheduler.RegisterTask("late", time.Minute, noop), "tasks cannot be added while running")
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("registered task did not run")
+	}
+	assert.NoError(t, scheduler.Stop(ctx))
+}
+
+func TestScheduler_TaskPanics(t *testing.T) {
+	reportingAgent := NewMockReportingAgent()
+	scheduler, err := NewScheduler(reportingAgent, time.Hour)
+	require.NoError(t, err)
+
+	// A panicking run fails on its own and the task keeps its schedule
+	runs := make(chan struct{}, 2)
+	require.NoError(t, scheduler.RegisterTask("task", 10*time.Millisecond, func(ctx context.Context) error {
+		select {
+		case runs <- struct{}{}:
+		default:
+		}
+		panic("boom")
+	}))
+
+	ctx := context.Background()
+	require.NoError(t, scheduler.Start(ctx))
+	for i := 0; i < 2; i++ {
+		select {
+		case <-runs:
+		case <-time.After(time.Second):
+			t.Fatal("task did not run again after panicking")
+		}
+	}
+	assert.NoError(t, scheduler.Stop(ctx))
+}
+
+func TestScheduler_Reload(t *testing.T) {
+	reportingAgent := NewMockReportingAgent()
+	scheduler, err := NewScheduler(reportingAgent, time.Hour)
+	require.NoError(t, err)
+
+	assert.Error(t, scheduler.Reload(context.Background(), "not a config"))
+	assert.Error(t, scheduler.Reload(context.Background(), &config.Config{}))
+	assert.Equal(t, time.Hour, scheduler.Interval())
+
+	ran := make(chan struct{}, 1)
+	reportingAgent.On("GenerateReport", mock.Anything, mock.Anything).Return(nil).Run(func(mock.Arguments) {
+		select {
+		case ran <- struct{}{}:
+		default:
+		}
+	})
+
+	ctx := context.Background()
+	require.NoError(t, scheduler.Collect(ctx, events.FileChanged{Changes: []models.FileChange{{Path: "/a.txt"}}}))
+	require.NoError(t, scheduler.Start(ctx))
+	defer scheduler.Stop(ctx)
+
+	require.NoError(t, scheduler.Reload(ctx, &config.Config{PollInterval: 10 * time.Millisecond}))
+	assert.Equal(t, 10*time.Millisecond, scheduler.Interval())
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler did not pick up the new interval")
+	}
+}
+
+func TestScheduler_SavedQuery(t *testing.T) {
+	reportingAgent := NewMockReportingAgent()
+	scheduler, err := NewScheduler(reportingAgent, time.Hour)
+	assert.NoError(t, err)
+
+	query := models.ChangeQuery{Name: "SQL changes", PathPrefix: "/DB", Extensions: []string{".sql"}}
+	assert.NoError(t, scheduler.AddSavedQuery(query, 7*24*time.Hour, models.FileListReport))
+	assert.Error(t, scheduler.AddSavedQuery(models.ChangeQuery{}, time.Hour, models.FileListReport))
+
+	ctx := context.Background()
+	now := time.Now()
+	require.NoError(t, scheduler.Collect(ctx, events.FileChanged{Folder: "/DB", Changes: []models.FileChange{
+		{Path: "/DB/schema.sql", Size: 100, Modified: now},
+		{Path: "/DB/notes.txt", Size: 100, Modified: now},
+	}}))
+
+	expected := []models.FileChange{{Path: "/DB/schema.sql", Size: 100, Modified: now}}
+	reportingAgent.On("GenerateCustomReport", mock.Anything, "SQL changes", expected, models.FileListReport).Return(nil).Once()
+	assert.NoError(t, scheduler.runSavedQuery(ctx, query, models.FileListReport))
+
+	// The next run covers only the changes collected since
+	reportingAgent.On("GenerateCustomReport", mock.Anything, "SQL changes", []models.FileChange{}, models.FileListReport).Return(nil).Once()
+	assert.NoError(t, scheduler.runSavedQuery(ctx, query, models.FileListReport))
+	reportingAgent.AssertExpectations(t)
+}
+
+func TestScheduler_HealthAndRestart(t *testing.T) {
+	ctx := context.Background()
+	reportingAgent := NewMockReportingAgent()
+	reportingAgent.On("Health", mock.Anything).Return(nil)
+	scheduler, err := NewScheduler(reportingAgent, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, scheduler.Start(ctx))
+	assert.NoError(t, scheduler.Health(ctx))
+
+	// Reports failing persistently make the scheduler unhealthy
+	for i := 0; i < maxFailedReports; i++ {
+		scheduler.recordReport(assert.AnError)
+	}
+	assert.Error(t, scheduler.Health(ctx))
+
+	// Restarting clears the failures and starts the loops again
+	require.NoError(t, scheduler.Restart(ctx))
+	assert.NoError(t, scheduler.Health(ctx))
+	assert.Equal(t, lifecycle.StateRunning, scheduler.State())
+	require.NoError(t, scheduler.Stop(ctx))
+}
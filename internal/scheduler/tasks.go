@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/safego"
+)
+
+// TaskFunc is a unit of periodic work run by the scheduler
+type TaskFunc func(ctx context.Context) error
+
+// task is a named job run on its own interval
+type task struct {
+	name     string
+	interval time.Duration
+	fn       TaskFunc
+}
+
+// RegisterTask adds a periodic task that runs alongside the default report.
+// Tasks must be registered before the scheduler is started.
+func (s *Scheduler) RegisterTask(name string, interval time.Duration, fn TaskFunc) error {
+	if name == "" {
+		return fmt.Errorf("task name cannot be empty")
+	}
+	if interval <= 0 {
+		return fmt.Errorf("interval for task %s must be greater than 0", name)
+	}
+	if fn == nil {
+		return fmt.Errorf("task function for %s cannot be nil", name)
+	}
+	if s.State() == lifecycle.StateRunning {
+		return fmt.Errorf("cannot register task %s while scheduler is running", name)
+	}
+
+	for _, t := range s.tasks {
+		if t.name == name {
+			return fmt.Errorf("task %s is already registered", name)
+		}
+	}
+
+	s.tasks = append(s.tasks, task{name: name, interval: interval, fn: fn})
+	return nil
+}
+
+// runTask executes a task on its interval until the scheduler stops. A task
+// that panics fails that run only.
+func (s *Scheduler) runTask(ctx context.Context, t task, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			err := safego.Call(s.logger, "task "+t.name, func() error { return t.fn(ctx) })
+			if err != nil {
+				s.logger.Error("Scheduled task failed", "task", t.name, "error", err)
+			}
+		}
+	}
+}
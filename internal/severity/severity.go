@@ -0,0 +1,251 @@
+// Package severity grades published changes by how urgently someone should
+// hear about them, so that a folder of hundreds of files deleted at once
+// raises an immediate alert while edits to temporary files wait for the
+// scheduled report.
+package severity
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/events"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/filter"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// Level is how urgent a set of changes is
+type Level string
+
+// Levels from the least to the most urgent
+const (
+	Info     Level = "info"
+	Warning  Level = "warning"
+	Critical Level = "critical"
+)
+
+// ParseLevel returns the level named s
+func ParseLevel(s string) (Level, error) {
+	switch level := Level(strings.ToLower(s)); level {
+	case Info, Warning, Critical:
+		return level, nil
+	}
+	return "", fmt.Errorf("unknown severity %q", s)
+}
+
+// rank orders the levels by urgency
+func (l Level) rank() int {
+	switch l {
+	case Warning:
+		return 1
+	case Critical:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// maxListed caps the changes listed in an alert
+const maxListed = 20
+
+// Rule grades the changes meeting all of its conditions; a condition left
+// empty is met by every change
+type Rule struct {
+	// Name identifies the rule in alerts
+	Name  string
+	Level Level
+	// Paths are globs matched against the path from the Dropbox root, as
+	// described by filter.Filter
+	Paths []string
+	// Extensions match files by extension, with or without the dot
+	Extensions []string
+	// Operations match changes by type, such as deleted or renamed
+	Operations []models.ChangeType
+	// MinSize matches files of at least this many bytes
+	MinSize int64
+	// MinFiles is how many changes in one set must meet the conditions for
+	// the rule to apply; zero means one
+	MinFiles int
+}
+
+// compiledRule is a rule with its globs compiled
+type compiledRule struct {
+	Rule
+	paths      *filter.Filter
+	extensions map[string]bool
+}
+
+// Classifier grades sets of changes by the first rule of the highest level
+// that applies to them
+type Classifier struct {
+	rules []compiledRule
+}
+
+// NewClassifier compiles the rules
+func NewClassifier(rules []Rule) (*Classifier, error) {
+	c := &Classifier{}
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("severity rule has no name")
+		}
+		if _, err := ParseLevel(string(rule.Level)); err != nil {
+			return nil, fmt.Errorf("severity rule %q: %w", rule.Name, err)
+		}
+		if rule.MinSize < 0 || rule.MinFiles < 0 {
+			return nil, fmt.Errorf("severity rule %q has a negative threshold", rule.Name)
+		}
+		paths, err := filter.New(rule.Paths, nil)
+		if err != nil {
+			return nil, fmt.Errorf("severity rule %q: %w", rule.Name, err)
+		}
+		extensions := make(map[string]bool)
+		for _, ext := range rule.Extensions {
+			extensions["."+strings.TrimPrefix(strings.ToLower(strings.TrimSpace(ext)), ".")] = true
+		}
+		c.rules = append(c.rules, compiledRule{Rule: rule, paths: paths, extensions: extensions})
+	}
+	return c, nil
+}
+
+// Assessment is the grade of a set of changes
+type Assessment struct {
+	Level Level
+	// Rule is the name of the rule that set Level; it is empty when no rule
+	// applied and Level is Info
+	Rule string
+	// Changes are the changes that met the rule's conditions
+	Changes []models.FileChange
+}
+
+// Classify grades a set of changes; a nil classifier grades every set Info
+func (c *Classifier) Classify(changes []models.FileChange) Assessment {
+	assessment := Assessment{Level: Info}
+	if c == nil {
+		return assessment
+	}
+	for _, rule := range c.rules {
+		if assessment.Rule != "" && rule.Level.rank() <= assessment.Level.rank() {
+			continue
+		}
+		var matched []models.FileChange
+		for _, change := range changes {
+			if rule.matches(change) {
+				matched = append(matched, change)
+			}
+		}
+		if len(matched) > 0 && len(matched) >= rule.MinFiles {
+			assessment = Assessment{Level: rule.Level, Rule: rule.Name, Changes: matched}
+		}
+	}
+	return assessment
+}
+
+// matches reports whether a change meets the rule's conditions
+func (r compiledRule) matches(change models.FileChange) bool {
+	if !r.paths.Match(strings.ToLower(strings.TrimPrefix(change.Path, "/"))) {
+		return false
+	}
+	if len(r.extensions) > 0 && !r.extensions[strings.ToLower(path.Ext(change.Path))] {
+		return false
+	}
+	if len(r.Operations) > 0 {
+		found := false
+		for _, operation := range r.Operations {
+			if change.Type() == operation {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return change.Size >= r.MinSize
+}
+
+// Tier is how the changes graded at one level are alerted
+type Tier struct {
+	// Channels are the notification channels alerted; a tier without
+	// channels sends no alert
+	Channels []string
+	// SubjectPrefix starts the subject of the alerts, such as "[CRITICAL]"
+	SubjectPrefix string
+}
+
+// Sender delivers a report through the named notification channels
+type Sender interface {
+	SendReportTo(ctx context.Context, channels []string, report *models.Report, message string) error
+}
+
+// Alerter grades published changes and alerts through the tier of their
+// level
+type Alerter struct {
+	classifier *Classifier
+	tiers      map[Level]Tier
+	sender     Sender
+	logger     *slog.Logger
+}
+
+// NewAlerter creates an alerter sending through sender
+func NewAlerter(classifier *Classifier, tiers map[Level]Tier, sender Sender, logger *slog.Logger) *Alerter {
+	return &Alerter{
+		classifier: classifier,
+		tiers:      tiers,
+		sender:     sender,
+		logger:     logging.Component(logger, "severity"),
+	}
+}
+
+// Handle grades a set of published changes and, when the tier of its level
+// has channels, alerts them; it is meant to be subscribed to the event bus
+func (a *Alerter) Handle(ctx context.Context, event events.FileChanged) error {
+	assessment := a.classifier.Classify(event.Changes)
+	tier := a.tiers[assessment.Level]
+	if assessment.Rule == "" || len(tier.Channels) == 0 {
+		return nil
+	}
+
+	report, message := alert(assessment, event, tier)
+	a.logger.Info("Sending severity alert", "severity", assessment.Level, "rule", assessment.Rule,
+		"changes", len(assessment.Changes), "channels", tier.Channels)
+	if err := a.sender.SendReportTo(ctx, tier.Channels, report, message); err != nil {
+		return fmt.Errorf("failed to send %s alert: %w", assessment.Level, err)
+	}
+	return nil
+}
+
+// alert renders the alert for an assessment as a report and its text
+func alert(assessment Assessment, event events.FileChanged, tier Tier) (*models.Report, string) {
+	where := ""
+	if event.Folder != "" {
+		where = " in " + event.Folder
+	}
+	title := fmt.Sprintf("%s: %d changes matched %q%s", strings.ToUpper(string(assessment.Level)),
+		len(assessment.Changes), assessment.Rule, where)
+	if tier.SubjectPrefix != "" {
+		title = tier.SubjectPrefix + " " + title
+	}
+
+	report := models.NewReport(models.FileListReport)
+	report.Title = title
+	report.Since, report.Until = event.DetectedAt, event.DetectedAt
+	for _, change := range assessment.Changes {
+		report.AddChange(change)
+	}
+	report.Metadata["severity"] = string(assessment.Level)
+	report.Metadata["rule"] = assessment.Rule
+
+	var b strings.Builder
+	b.WriteString(title + "\n\n")
+	for i, change := range assessment.Changes {
+		if i == maxListed {
+			fmt.Fprintf(&b, "... and %d more\n", len(assessment.Changes)-maxListed)
+			break
+		}
+		fmt.Fprintf(&b, "%s %s\n", change.Type(), change.Path)
+	}
+	return report, b.String()
+}
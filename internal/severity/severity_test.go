@@ -0,0 +1,117 @@
+package severity
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/events"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deletions returns n deleted files under dir
+func deletions(dir string, n int) []models.FileChange {
+	changes := make([]models.FileChange, n)
+	for i := range changes {
+		changes[i] = models.FileChange{Path: fmt.Sprintf("%s/file%d.docx", dir, i), IsDeleted: true}
+	}
+	return changes
+}
+
+func testRules() []Rule {
+	return []Rule{
+		{Name: "temp files", Level: Info, Extensions: []string{"tmp"}},
+		{Name: "large uploads", Level: Warning, MinSize: 1 << 30},
+		{Name: "contracts deleted", Level: Warning, Paths: []string{"legal/**"}, Operations: []models.ChangeType{models.ChangeDeleted}},
+		{Name: "mass deletion", Level: Critical, Operations: []models.ChangeType{models.ChangeDeleted}, MinFiles: 100},
+	}
+}
+
+func TestClassifier_Classify(t *testing.T) {
+	classifier, err := NewClassifier(testRules())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		changes []models.FileChange
+		level   Level
+		rule    string
+		matched int
+	}{
+		{"no rule applies", []models.FileChange{{Path: "/docs/a.docx"}}, Info, "", 0},
+		{"extension", []models.FileChange{{Path: "/docs/a.TMP"}}, Info, "temp files", 1},
+		{"size threshold", []models.FileChange{{Path: "/video.mp4", Size: 2 << 30}, {Path: "/a.txt", Size: 10}}, Warning, "large uploads", 1},
+		{"path and operation", []models.FileChange{{Path: "/Legal/nda.pdf", IsDeleted: true}, {Path: "/Legal/msa.pdf"}}, Warning, "contracts deleted", 1},
+		{"below file count", deletions("/docs", 99), Info, "", 0},
+		{"file count", deletions("/docs", 500), Critical, "mass deletion", 500},
+		{"highest level wins", append(deletions("/Legal", 100), models.FileChange{Path: "/a.tmp"}), Critical, "mass deletion", 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assessment := classifier.Classify(tt.changes)
+			assert.Equal(t, tt.level, assessment.Level)
+			assert.Equal(t, tt.rule, assessment.Rule)
+			assert.Len(t, assessment.Changes, tt.matched)
+		})
+	}
+
+	var none *Classifier
+	assert.Equal(t, Info, none.Classify(deletions("/docs", 1)).Level)
+}
+
+func TestNewClassifier_Invalid(t *testing.T) {
+	for _, rule := range []Rule{
+		{Level: Critical},
+		{Name: "unknown level", Level: "urgent"},
+		{Name: "bad glob", Level: Info, Paths: []string{"["}},
+		{Name: "negative", Level: Info, MinFiles: -1},
+	} {
+		_, err := NewClassifier([]Rule{rule})
+		assert.Error(t, err, rule.Name)
+	}
+}
+
+// recordingSender records the alerts it is asked to send
+type recordingSender struct {
+	channels [][]string
+	reports  []*models.Report
+	messages []string
+}
+
+func (s *recordingSender) SendReportTo(ctx context.Context, channels []string, report *models.Report, message string) error {
+	s.channels = append(s.channels, channels)
+	s.reports = append(s.reports, report)
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+func TestAlerter_Handle(t *testing.T) {
+	classifier, err := NewClassifier(testRules())
+	require.NoError(t, err)
+	sender := &recordingSender{}
+	alerter := NewAlerter(classifier, map[Level]Tier{
+		Critical: {Channels: []string{"email", "slack"}, SubjectPrefix: "[CRITICAL]"},
+		Info:     {Channels: []string{"slack"}},
+	}, sender, nil)
+
+	ctx := context.Background()
+	require.NoError(t, alerter.Handle(ctx, events.FileChanged{Folder: "/docs", Changes: deletions("/docs", 500)}))
+	require.Len(t, sender.reports, 1)
+	assert.Equal(t, []string{"email", "slack"}, sender.channels[0])
+	assert.Equal(t, `[CRITICAL] CRITICAL: 500 changes matched "mass deletion" in /docs`, sender.reports[0].Title)
+	assert.Equal(t, 500, sender.reports[0].TotalChanges)
+	assert.Equal(t, "critical", sender.reports[0].Metadata["severity"])
+	assert.Contains(t, sender.messages[0], "deleted /docs/file0.docx")
+	assert.Contains(t, sender.messages[0], "... and 480 more")
+
+	// Levels without channels, and changes no rule grades, send nothing
+	require.NoError(t, alerter.Handle(ctx, events.FileChanged{Changes: []models.FileChange{{Path: "/video.mp4", Size: 2 << 30}}}))
+	require.NoError(t, alerter.Handle(ctx, events.FileChanged{Changes: []models.FileChange{{Path: "/a.docx"}}}))
+	assert.Len(t, sender.reports, 1)
+
+	require.NoError(t, alerter.Handle(ctx, events.FileChanged{Changes: []models.FileChange{{Path: "/a.tmp"}}}))
+	require.Len(t, sender.reports, 2)
+	assert.Equal(t, `INFO: 1 changes matched "temp files"`, sender.reports[1].Title)
+}
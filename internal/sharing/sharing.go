@@ -0,0 +1,216 @@
+// Package sharing reports new shared links and edited Paper docs as changes,
+// so they are merged into the change stream alongside file changes.
+package sharing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/interfaces"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// State keys holding what the last poll saw
+const (
+	linksKey = "shared_links"
+	paperKey = "paper_docs_updated"
+)
+
+// paperDocURL is the address of a Paper doc, followed by its ID
+const paperDocURL = "https://paper.dropbox.com/doc/"
+
+// Source lists shared links and Paper docs
+type Source interface {
+	ListSharedLinks(ctx context.Context) ([]models.SharedLink, error)
+	ListPaperDocs(ctx context.Context, since time.Time) ([]models.PaperDoc, error)
+}
+
+// Sink receives the detected changes, such as Container.IngestChanges
+type Sink func(ctx context.Context, changes []models.FileChange) error
+
+// Config selects what is watched
+type Config struct {
+	SharedLinks bool
+	Paper       bool
+}
+
+// Watcher polls for shared links and Paper docs, passing new links and
+// updated docs to a sink. The first poll only records what exists, so
+// existing links and docs are not reported.
+type Watcher struct {
+	source Source
+	state  interfaces.StateManager
+	sink   Sink
+	config Config
+	now    func() time.Time
+	logger *slog.Logger
+}
+
+// NewWatcher creates a watcher keeping what it has seen in state
+func NewWatcher(source Source, state interfaces.StateManager, sink Sink, config Config) (*Watcher, error) {
+	if source == nil {
+		return nil, fmt.Errorf("sharing source cannot be nil")
+	}
+	if state == nil {
+		return nil, fmt.Errorf("state manager cannot be nil")
+	}
+	if sink == nil {
+		return nil, fmt.Errorf("change sink cannot be nil")
+	}
+
+	return &Watcher{
+		source: source,
+		state:  state,
+		sink:   sink,
+		config: config,
+		now:    time.Now,
+		logger: logging.Component(nil, "sharing"),
+	}, nil
+}
+
+// Poll reports the links created and Paper docs updated since the last poll;
+// it can be registered as a scheduler task
+func (w *Watcher) Poll(ctx context.Context) error {
+	var changes []models.FileChange
+	var saves []func() error
+
+	if w.config.SharedLinks {
+		linkChanges, save, err := w.sharedLinks(ctx)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, linkChanges...)
+		saves = append(saves, save)
+	}
+	if w.config.Paper {
+		paperChanges, save, err := w.paperDocs(ctx)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, paperChanges...)
+		saves = append(saves, save)
+	}
+
+	if len(changes) > 0 {
+		if err := w.sink(ctx, changes); err != nil {
+			return fmt.Errorf("failed to report sharing changes: %w", err)
+		}
+		w.logger.Info("Detected shared link and Paper changes", "changes", len(changes))
+	}
+
+	// Only remember what was seen once it has been reported
+	for _, save := range saves {
+		if err := save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sharedLinks returns changes for the links not seen before, and a function
+// saving the current links
+func (w *Watcher) sharedLinks(ctx context.Context) ([]models.FileChange, func() error, error) {
+	links, err := w.source.ListSharedLinks(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list shared links: %w", err)
+	}
+
+	urls := make([]string, 0, len(links))
+	for _, link := range links {
+		urls = append(urls, link.URL)
+	}
+	encoded, err := json.Marshal(urls)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode shared links: %w", err)
+	}
+	save := func() error {
+		if err := w.state.SetString(linksKey, string(encoded)); err != nil {
+			return fmt.Errorf("failed to save shared links: %w", err)
+		}
+		return nil
+	}
+
+	stored := w.state.GetString(linksKey)
+	if stored == "" {
+		return nil, save, nil
+	}
+	var seen []string
+	if err := json.Unmarshal([]byte(stored), &seen); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode shared links: %w", err)
+	}
+	known := make(map[string]bool, len(seen))
+	for _, url := range seen {
+		known[url] = true
+	}
+
+	var changes []models.FileChange
+	now := w.now()
+	for _, link := range links {
+		if known[link.URL] {
+			continue
+		}
+		path := link.Path
+		if path == "" {
+			path = "/" + link.Name
+		}
+		changes = append(changes, models.FileChange{
+			Path:       path,
+			Modified:   now,
+			ChangeType: models.ChangeSharedLink,
+			URL:        link.URL,
+		})
+	}
+	return changes, save, nil
+}
+
+// paperDocs returns changes for the docs updated since the last poll, and a
+// function saving the time of the latest update
+func (w *Watcher) paperDocs(ctx context.Context) ([]models.FileChange, func() error, error) {
+	stored := w.state.GetString(paperKey)
+	if stored == "" {
+		now := w.now()
+		return nil, func() error { return w.savePaper(now) }, nil
+	}
+	since, err := time.Parse(time.RFC3339Nano, stored)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode Paper doc update time: %w", err)
+	}
+
+	docs, err := w.source.ListPaperDocs(ctx, since)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list Paper docs: %w", err)
+	}
+
+	latest := since
+	changes := make([]models.FileChange, 0, len(docs))
+	for _, doc := range docs {
+		if doc.Updated.After(latest) {
+			latest = doc.Updated
+		}
+		title := strings.ReplaceAll(doc.Title, "/", "-")
+		if title == "" {
+			title = "Untitled"
+		}
+		changes = append(changes, models.FileChange{
+			Path:       "/Paper/" + title + ".paper",
+			Modified:   doc.Updated,
+			ModifiedBy: doc.LastEditor,
+			ChangeType: models.ChangePaper,
+			URL:        paperDocURL + doc.ID,
+		})
+	}
+	return changes, func() error { return w.savePaper(latest) }, nil
+}
+
+// savePaper saves the time of the latest Paper doc update seen
+func (w *Watcher) savePaper(updated time.Time) error {
+	if err := w.state.SetString(paperKey, updated.UTC().Format(time.RFC3339Nano)); err != nil {
+		return fmt.Errorf("failed to save Paper doc update time: %w", err)
+	}
+	return nil
+}
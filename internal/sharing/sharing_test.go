@@ -0,0 +1,139 @@
+package sharing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryState is an in-memory StateManager
+type memoryState map[string]string
+
+func (m memoryState) GetString(key string) string {
+	return m[key]
+}
+
+func (m memoryState) SetString(key, value string) error {
+	m[key] = value
+	return nil
+}
+
+// fakeSource returns canned links and docs
+type fakeSource struct {
+	links  []models.SharedLink
+	docs   []models.PaperDoc
+	sinces []time.Time
+}
+
+func (s *fakeSource) ListSharedLinks(ctx context.Context) ([]models.SharedLink, error) {
+	return s.links, nil
+}
+
+func (s *fakeSource) ListPaperDocs(ctx context.Context, since time.Time) ([]models.PaperDoc, error) {
+	s.sinces = append(s.sinces, since)
+	var docs []models.PaperDoc
+	for _, doc := range s.docs {
+		if doc.Updated.After(since) {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+// recordingSink keeps the changes it receives
+type recordingSink struct {
+	changes []models.FileChange
+	err     error
+}
+
+func (s *recordingSink) ingest(ctx context.Context, changes []models.FileChange) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.changes = append(s.changes, changes...)
+	return nil
+}
+
+func TestNewWatcher(t *testing.T) {
+	sink := &recordingSink{}
+	_, err := NewWatcher(nil, memoryState{}, sink.ingest, Config{})
+	assert.Error(t, err)
+	_, err = NewWatcher(&fakeSource{}, memoryState{}, nil, Config{})
+	assert.Error(t, err)
+}
+
+func TestWatcher_SharedLinks(t *testing.T) {
+	source := &fakeSource{links: []models.SharedLink{{URL: "https://db.tt/a", Name: "a.pdf", Path: "/docs/a.pdf"}}}
+	sink := &recordingSink{}
+	watcher, err := NewWatcher(source, memoryState{}, sink.ingest, Config{SharedLinks: true})
+	require.NoError(t, err)
+
+	// Existing links are recorded without being reported
+	require.NoError(t, watcher.Poll(context.Background()))
+	assert.Empty(t, sink.changes)
+
+	source.links = append(source.links,
+		models.SharedLink{URL: "https://db.tt/b", Name: "b.xlsx", Path: "/docs/b.xlsx"},
+		models.SharedLink{URL: "https://db.tt/c", Name: "c.txt"},
+	)
+	require.NoError(t, watcher.Poll(context.Background()))
+	require.Len(t, sink.changes, 2)
+	assert.Equal(t, "/docs/b.xlsx", sink.changes[0].Path)
+	assert.Equal(t, models.ChangeSharedLink, sink.changes[0].Type())
+	assert.Equal(t, "https://db.tt/b", sink.changes[0].URL)
+	assert.Equal(t, "/c.txt", sink.changes[1].Path, "links outside the Dropbox are named")
+
+	// Links are reported once
+	require.NoError(t, watcher.Poll(context.Background()))
+	assert.Len(t, sink.changes, 2)
+}
+
+func TestWatcher_PaperDocs(t *testing.T) {
+	start := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	source := &fakeSource{}
+	sink := &recordingSink{}
+	state := memoryState{}
+	watcher, err := NewWatcher(source, state, sink.ingest, Config{Paper: true})
+	require.NoError(t, err)
+	watcher.now = func() time.Time { return start }
+
+	require.NoError(t, watcher.Poll(context.Background()))
+	assert.Empty(t, source.sinces, "the first poll only records the time")
+
+	source.docs = []models.PaperDoc{{ID: "d1", Title: "Q1/Q2 plan", LastEditor: "jane@example.com", Updated: start.Add(time.Hour)}}
+	require.NoError(t, watcher.Poll(context.Background()))
+	require.Len(t, sink.changes, 1)
+	change := sink.changes[0]
+	assert.Equal(t, "/Paper/Q1-Q2 plan.paper", change.Path)
+	assert.Equal(t, models.ChangePaper, change.Type())
+	assert.Equal(t, "jane@example.com", change.ModifiedBy)
+	assert.Equal(t, "https://paper.dropbox.com/doc/d1", change.URL)
+	assert.Equal(t, []time.Time{start}, source.sinces)
+
+	// The next poll continues from the latest update
+	require.NoError(t, watcher.Poll(context.Background()))
+	assert.Len(t, sink.changes, 1)
+	assert.True(t, source.sinces[1].Equal(start.Add(time.Hour)))
+}
+
+func TestWatcher_KeepsStateWhenSinkFails(t *testing.T) {
+	source := &fakeSource{}
+	sink := &recordingSink{}
+	state := memoryState{}
+	watcher, err := NewWatcher(source, state, sink.ingest, Config{SharedLinks: true})
+	require.NoError(t, err)
+	require.NoError(t, watcher.Poll(context.Background()))
+
+	source.links = []models.SharedLink{{URL: "https://db.tt/a", Path: "/a.pdf"}}
+	sink.err = errors.New("report failed")
+	assert.Error(t, watcher.Poll(context.Background()))
+
+	sink.err = nil
+	require.NoError(t, watcher.Poll(context.Background()))
+	assert.Len(t, sink.changes, 1, "the link is reported on the next poll")
+}
@@ -0,0 +1,119 @@
+package teamlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/interfaces"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// cursorKey is the state key holding the team log cursor
+const cursorKey = "team_log_cursor"
+
+// DefaultBackfill is how far back the first ingestion reaches
+const DefaultBackfill = 24 * time.Hour
+
+// DefaultCategories are the security relevant event categories ingested when
+// none are configured
+var DefaultCategories = []string{"sharing", "logins", "devices"}
+
+// Source fetches team log events
+type Source interface {
+	GetTeamEvents(ctx context.Context, cursor string, since time.Time) ([]models.TeamEvent, string, error)
+}
+
+// Store persists team log events
+type Store interface {
+	SaveTeamEvents(ctx context.Context, events []models.TeamEvent) error
+}
+
+// Ingester copies new team log events into the store, resuming from a cursor
+// kept in the state manager
+type Ingester struct {
+	source     Source
+	store      Store
+	state      interfaces.StateManager
+	categories map[string]bool
+	backfill   time.Duration
+	now        func() time.Time
+	logger     *slog.Logger
+}
+
+// NewIngester creates a team log ingester for the given event categories;
+// an empty list uses DefaultCategories
+func NewIngester(source Source, store Store, state interfaces.StateManager, categories []string) (*Ingester, error) {
+	if source == nil {
+		return nil, fmt.Errorf("team log source cannot be nil")
+	}
+	if store == nil {
+		return nil, fmt.Errorf("team event store cannot be nil")
+	}
+	if state == nil {
+		return nil, fmt.Errorf("state manager cannot be nil")
+	}
+
+	if len(categories) == 0 {
+		categories = DefaultCategories
+	}
+	allowed := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		allowed[strings.ToLower(category)] = true
+	}
+
+	return &Ingester{
+		source:     source,
+		store:      store,
+		state:      state,
+		categories: allowed,
+		backfill:   DefaultBackfill,
+		now:        time.Now,
+		logger:     logging.Component(nil, "teamlog"),
+	}, nil
+}
+
+// Ingest fetches and stores events recorded since the last run; it can be
+// registered as a scheduler task
+func (i *Ingester) Ingest(ctx context.Context) error {
+	cursor := i.state.GetString(cursorKey)
+
+	events, next, err := i.source.GetTeamEvents(ctx, cursor, i.now().Add(-i.backfill))
+	if err != nil {
+		var dbErr *dropbox.Error
+		if cursor != "" && errors.As(err, &dbErr) && dbErr.Type == dropbox.ErrorTypeConflict {
+			// The cursor expired; start over from the backfill window on the next run
+			if clearErr := i.state.SetString(cursorKey, ""); clearErr != nil {
+				return fmt.Errorf("failed to clear team log cursor: %w", clearErr)
+			}
+		}
+		return fmt.Errorf("failed to fetch team events: %w", err)
+	}
+
+	kept := make([]models.TeamEvent, 0, len(events))
+	for _, event := range events {
+		if i.categories[strings.ToLower(event.Category)] {
+			kept = append(kept, event)
+		}
+	}
+
+	if err := i.store.SaveTeamEvents(ctx, kept); err != nil {
+		return fmt.Errorf("failed to save team events: %w", err)
+	}
+
+	if next != "" && next != cursor {
+		if err := i.state.SetString(cursorKey, next); err != nil {
+			return fmt.Errorf("failed to update team log cursor: %w", err)
+		}
+	}
+
+	if len(kept) > 0 {
+		i.logger.Info("Ingested team events", "events", len(kept))
+	}
+	return nil
+}
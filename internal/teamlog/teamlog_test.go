@@ -0,0 +1,94 @@
+package teamlog
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryState is an in-memory StateManager
+type memoryState map[string]string
+
+func (m memoryState) GetString(key string) string {
+	return m[key]
+}
+
+func (m memoryState) SetString(key, value string) error {
+	m[key] = value
+	return nil
+}
+
+// fakeSource returns canned events and records the cursors it was called with
+type fakeSource struct {
+	events  []models.TeamEvent
+	next    string
+	err     error
+	cursors []string
+}
+
+func (s *fakeSource) GetTeamEvents(ctx context.Context, cursor string, since time.Time) ([]models.TeamEvent, string, error) {
+	s.cursors = append(s.cursors, cursor)
+	return s.events, s.next, s.err
+}
+
+// memoryStore keeps saved events in memory
+type memoryStore struct {
+	events []models.TeamEvent
+}
+
+func (s *memoryStore) SaveTeamEvents(ctx context.Context, events []models.TeamEvent) error {
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func TestNewIngester(t *testing.T) {
+	_, err := NewIngester(nil, &memoryStore{}, memoryState{}, nil)
+	assert.Error(t, err)
+
+	ingester, err := NewIngester(&fakeSource{}, &memoryStore{}, memoryState{}, nil)
+	require.NoError(t, err)
+	for _, category := range DefaultCategories {
+		assert.True(t, ingester.categories[category])
+	}
+}
+
+func TestIngester_Ingest(t *testing.T) {
+	source := &fakeSource{
+		events: []models.TeamEvent{
+			{Category: "logins", Type: "login_success"},
+			{Category: "file_operations", Type: "file_download"},
+			{Category: "Sharing", Type: "shared_link_create"},
+		},
+		next: "c1",
+	}
+	store := &memoryStore{}
+	state := memoryState{}
+
+	ingester, err := NewIngester(source, store, state, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, ingester.Ingest(context.Background()))
+	assert.Len(t, store.events, 2, "events outside the configured categories are dropped")
+	assert.Equal(t, "c1", state[cursorKey])
+
+	// The next run resumes from the stored cursor
+	source.events = nil
+	require.NoError(t, ingester.Ingest(context.Background()))
+	assert.Equal(t, []string{"", "c1"}, source.cursors)
+}
+
+func TestIngester_ExpiredCursor(t *testing.T) {
+	source := &fakeSource{err: dropbox.NewConflictError("reset", nil)}
+	state := memoryState{cursorKey: "stale"}
+
+	ingester, err := NewIngester(source, &memoryStore{}, state, []string{"logins"})
+	require.NoError(t, err)
+
+	assert.Error(t, ingester.Ingest(context.Background()))
+	assert.Equal(t, "", state[cursorKey])
+}
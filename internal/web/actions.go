@@ -0,0 +1,105 @@
+package web
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/actions"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/i18n"
+)
+
+// actionApplier verifies and applies the signed links in alert emails
+type actionApplier interface {
+	Verify(token string) (actions.Claims, error)
+	Apply(token string) (actions.Claims, error)
+	Describe(claims actions.Claims, catalog *i18n.Catalog) string
+}
+
+const actionTemplate = `<!DOCTYPE html>
+<html lang="{{.Language}}">
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <title>Dropbox Monitor</title>
+    <style>
+        body { font-family: Arial, sans-serif; max-width: 480px; margin: 40px auto; padding: 0 20px; color: #333; }
+        button { padding: 8px 16px; background-color: #0061ff; color: white; border: none; border-radius: 4px; cursor: pointer; }
+    </style>
+</head>
+<body>
+    <h1>Dropbox Monitor</h1>
+    <p>{{.Message}}</p>
+    {{if .Token}}
+    <form method="post" action="actions">
+        <input type="hidden" name="token" value="{{.Token}}">
+        <button type="submit">{{.Button}}</button>
+    </form>
+    {{end}}
+</body>
+</html>`
+
+var actionTmpl = template.Must(template.New("action").Parse(actionTemplate))
+
+// actionPage is the data for the action confirmation and result pages
+type actionPage struct {
+	Language i18n.Language
+	Message  string
+	Token    string
+	Button   string
+}
+
+// handleAction serves the links in alert emails. GET shows a confirmation
+// page, so link scanners that prefetch email links change nothing, and
+// POST carries out the action. The pages are in the dashboard language.
+func (s *Server) handleAction(w http.ResponseWriter, r *http.Request) {
+	if s.actions == nil {
+		s.renderAction(w, http.StatusServiceUnavailable, actionPage{Message: s.catalog.T("action.not_enabled")})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		token := r.URL.Query().Get("token")
+		claims, err := s.actions.Verify(token)
+		if err != nil {
+			s.renderActionError(w, err)
+			return
+		}
+		s.renderAction(w, http.StatusOK, actionPage{
+			Message: s.catalog.T("action.question", s.actions.Describe(claims, s.catalog)),
+			Token:   token,
+			Button:  s.catalog.T("action.confirm"),
+		})
+	case http.MethodPost:
+		claims, err := s.actions.Apply(r.FormValue("token"))
+		if err != nil {
+			s.renderActionError(w, err)
+			return
+		}
+		s.renderAction(w, http.StatusOK, actionPage{Message: s.catalog.T("action.done", s.actions.Describe(claims, s.catalog))})
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		s.renderAction(w, http.StatusMethodNotAllowed, actionPage{Message: s.catalog.T("action.method_not_allowed")})
+	}
+}
+
+// renderActionError renders the page for a token that failed verification
+func (s *Server) renderActionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, actions.ErrExpiredToken):
+		s.renderAction(w, http.StatusForbidden, actionPage{Message: s.catalog.T("action.expired")})
+	case errors.Is(err, actions.ErrInvalidToken):
+		s.renderAction(w, http.StatusBadRequest, actionPage{Message: s.catalog.T("action.invalid")})
+	default:
+		s.renderAction(w, http.StatusInternalServerError, actionPage{Message: s.catalog.T("action.failed", err.Error())})
+	}
+}
+
+// renderAction writes an action page with the given status
+func (s *Server) renderAction(w http.ResponseWriter, status int, page actionPage) {
+	page.Language = s.catalog.Language()
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	actionTmpl.Execute(w, page)
+}
@@ -0,0 +1,328 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/limits"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/redact"
+)
+
+// allAccounts is the account selector value for the aggregate view
+const allAccounts = "all"
+
+// accountView is the JSON representation of a configured account
+type accountView struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// changeView is the JSON representation of a stored file change
+type changeView struct {
+	ID         int64     `json:"id,omitempty"`
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+	ModifiedBy string    `json:"modified_by,omitempty"`
+	AccountID  string    `json:"account_id,omitempty"`
+	// Portfolio, Project and DocumentType come from the classification rules
+	Portfolio    string `json:"portfolio,omitempty"`
+	Project      string `json:"project,omitempty"`
+	DocumentType string `json:"document_type,omitempty"`
+	// ChangeType is modified, deleted, renamed, shared_link or paper;
+	// renames of live changes carry the path the file was moved from
+	ChangeType   models.ChangeType `json:"change_type"`
+	PreviousPath string            `json:"previous_path,omitempty"`
+	// Details holds the custom fields attached by enrichers
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// handleAccounts lists the configured Dropbox accounts
+func (s *Server) handleAccounts(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.accounts())
+}
+
+// handleChanges returns recent changes scoped to the account in the "account"
+// query parameter; an empty value or "all" aggregates every account
+func (s *Server) handleChanges(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeError(w, http.StatusServiceUnavailable, "database is not available")
+		return
+	}
+
+	accountID, err := s.accountFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	since, err := sinceFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	changes, err := s.db.GetRecentFileChangesForAccount(r.Context(), accountID, since)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toChangeViews(changes))
+}
+
+// Page sizes of change updates
+const (
+	defaultUpdateLimit = 500
+	maxUpdateLimit     = 5000
+)
+
+// changeUpdatesView is the JSON representation of the changes stored since a cursor
+type changeUpdatesView struct {
+	Changes []changeView `json:"changes"`
+	Cursor  string       `json:"cursor"`
+	More    bool         `json:"more"`
+}
+
+// handleChangeUpdates returns the changes stored after the "cursor" query
+// parameter, oldest first, with the cursor to pass on the next refresh. "more"
+// is set when "limit" cut the page short. Without a cursor it returns the
+// changes in the "hours" window instead, so clients load a view once and then
+// fetch only new rows.
+func (s *Server) handleChangeUpdates(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeError(w, http.StatusServiceUnavailable, "database is not available")
+		return
+	}
+
+	accountID, err := s.accountFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit := defaultUpdateLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit value %q", value))
+			return
+		}
+		limit = min(parsed, maxUpdateLimit)
+	}
+
+	value := r.URL.Query().Get("cursor")
+	if value == "" {
+		s.writeInitialUpdates(w, r, accountID)
+		return
+	}
+
+	cursor, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || cursor < 0 {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid cursor %q", value))
+		return
+	}
+
+	// One extra row tells whether another page follows
+	changes, err := s.db.GetFileChangesAfter(r.Context(), accountID, cursor, limit+1)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	more := len(changes) > limit
+	if more {
+		changes = changes[:limit]
+	}
+	if len(changes) > 0 {
+		cursor = changes[len(changes)-1].ID
+	}
+
+	writeJSON(w, http.StatusOK, changeUpdatesView{
+		Changes: toChangeViews(changes),
+		Cursor:  strconv.FormatInt(cursor, 10),
+		More:    more,
+	})
+}
+
+// writeInitialUpdates writes the changes in the requested window with a
+// cursor taken before loading them. A change stored in between is returned
+// again after the cursor, so clients skip IDs they already have.
+func (s *Server) writeInitialUpdates(w http.ResponseWriter, r *http.Request, accountID string) {
+	since, err := sinceFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cursor, err := s.db.LatestFileChangeID(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	changes, err := s.db.GetRecentFileChangesForAccount(r.Context(), accountID, since)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	slices.Reverse(changes)
+
+	writeJSON(w, http.StatusOK, changeUpdatesView{
+		Changes: toChangeViews(changes),
+		Cursor:  strconv.FormatInt(cursor, 10),
+	})
+}
+
+// reportPreviewer renders the next scheduled report without sending it
+type reportPreviewer interface {
+	PreviewReport(ctx context.Context, reportType models.ReportType) (*models.Report, error)
+}
+
+// handleReportPreview renders the report that would be sent right now; the
+// "type" query parameter selects the report type and defaults to html
+func (s *Server) handleReportPreview(w http.ResponseWriter, r *http.Request) {
+	if s.previewer == nil {
+		writeError(w, http.StatusServiceUnavailable, "report preview is not available")
+		return
+	}
+
+	reportType := models.HTMLReport
+	if value := r.URL.Query().Get("type"); value != "" {
+		reportType = models.ReportType(value)
+	}
+
+	report, err := s.previewer.PreviewReport(r.Context(), reportType)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", reportType.ContentType())
+	w.Write([]byte(report.Metadata["content"]))
+}
+
+// accounts returns the configured accounts in display order
+func (s *Server) accounts() []accountView {
+	accounts := make([]accountView, 0)
+	if s.config == nil {
+		return accounts
+	}
+	for _, account := range s.config.Accounts {
+		name := account.Name
+		if name == "" {
+			name = account.ID
+		}
+		accounts = append(accounts, accountView{ID: account.ID, Name: name})
+	}
+	return accounts
+}
+
+// accountFromRequest resolves the account query parameter, returning an empty
+// ID for the aggregate view
+func (s *Server) accountFromRequest(r *http.Request) (string, error) {
+	accountID := r.URL.Query().Get("account")
+	if accountID == "" || accountID == allAccounts {
+		return "", nil
+	}
+	if s.config == nil {
+		return "", fmt.Errorf("unknown account %q", accountID)
+	}
+	if _, ok := s.config.GetAccount(accountID); !ok {
+		return "", fmt.Errorf("unknown account %q", accountID)
+	}
+	return accountID, nil
+}
+
+// sinceFromRequest parses the "hours" query parameter, defaulting to 24 hours
+func sinceFromRequest(r *http.Request) (time.Time, error) {
+	hours := 24
+	if value := r.URL.Query().Get("hours"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			return time.Time{}, fmt.Errorf("invalid hours value %q", value)
+		}
+		hours = parsed
+	}
+	return time.Now().Add(-time.Duration(hours) * time.Hour), nil
+}
+
+// toChangeViews converts stored changes to their JSON representation
+func toChangeViews(changes []db.FileChange) []changeView {
+	views := make([]changeView, 0, len(changes))
+	for _, change := range changes {
+		views = append(views, changeView{
+			ID:           change.ID,
+			Path:         change.FilePath,
+			Size:         change.Size,
+			ModifiedAt:   change.ModifiedAt,
+			ModifiedBy:   change.ModifiedByName,
+			AccountID:    change.AccountID,
+			Portfolio:    change.Portfolio,
+			Project:      change.Project,
+			DocumentType: change.DocumentType,
+			ChangeType:   change.ToModel().Type(),
+			Details:      change.Details,
+		})
+	}
+	return views
+}
+
+// accountInfoer provides the details of the monitored account
+type accountInfoer interface {
+	AccountInfo(ctx context.Context) (*models.AccountInfo, error)
+}
+
+// handleAccount returns the cached details of the monitored account
+func (s *Server) handleAccount(w http.ResponseWriter, r *http.Request) {
+	account := s.accountInfo(r.Context())
+	if account == nil {
+		writeError(w, http.StatusServiceUnavailable, "account details are not available")
+		return
+	}
+	writeJSON(w, http.StatusOK, account)
+}
+
+// accountInfo returns the account details, or nil when they cannot be loaded
+func (s *Server) accountInfo(ctx context.Context) *models.AccountInfo {
+	if s.account == nil {
+		return nil
+	}
+	account, err := s.account.AccountInfo(ctx)
+	if err != nil {
+		s.log().Warn("Failed to load account details", "error", err)
+		return nil
+	}
+	return account
+}
+
+// limitStatser reports resource limit metrics
+type limitStatser interface {
+	LimitStats() limits.Stats
+}
+
+// handleLimits returns how often the resource limits have throttled work
+func (s *Server) handleLimits(w http.ResponseWriter, r *http.Request) {
+	if s.limits == nil {
+		writeError(w, http.StatusServiceUnavailable, "limits are not available")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.limits.LimitStats())
+}
+
+// writeJSON writes a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a JSON error response, with secrets masked
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": redact.String(msg)})
+}
@@ -0,0 +1,570 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/i18n"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+const dashboardTemplate = `<!DOCTYPE html>
+<html lang="{{ .Language }}">
+<head>
+    <title>{{ t "ui.title" }}</title>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            max-width: 1200px;
+            margin: 0 auto;
+            padding: 20px;
+            background-color: #f5f5f5;
+            color: #333;
+        }
+        .container {
+            background-color: white;
+            padding: 20px;
+            border-radius: 8px;
+            box-shadow: 0 2px 4px rgba(0,0,0,0.1);
+        }
+        .controls {
+            margin-bottom: 20px;
+            padding: 15px;
+            background-color: #f8f9fa;
+            border-radius: 4px;
+        }
+        select, input {
+            padding: 6px 10px;
+            margin: 5px;
+            border: 1px solid #ddd;
+            border-radius: 4px;
+        }
+        table {
+            width: 100%;
+            border-collapse: collapse;
+        }
+        th, td {
+            text-align: left;
+            padding: 8px;
+            border-bottom: 1px solid #eee;
+        }
+        th {
+            color: #0061ff;
+        }
+        .account {
+            color: #666;
+            margin-top: -10px;
+        }
+        .status {
+            display: flex;
+            gap: 20px;
+            margin-bottom: 20px;
+        }
+        .status span {
+            padding: 4px 10px;
+            border-radius: 4px;
+            background-color: #f8f9fa;
+        }
+        .status .ok {
+            background-color: #d4edda;
+            color: #155724;
+        }
+        .status .warn {
+            background-color: #fff3cd;
+            color: #856404;
+        }
+        .status .bad {
+            background-color: #f8d7da;
+            color: #721c24;
+        }
+        .live-row {
+            background-color: #e8f0ff;
+        }
+        .folder-chart td.bars {
+            width: 70%;
+        }
+        .folder-chart .bar {
+            display: inline-block;
+            width: 12px;
+            margin-right: 2px;
+            vertical-align: bottom;
+            background-color: #0061ff;
+        }
+        .heatmap td {
+            text-align: center;
+            min-width: 24px;
+            font-size: 12px;
+        }
+        .heatmap th.day {
+            font-size: 11px;
+            writing-mode: vertical-rl;
+        }
+        .tree .folder {
+            color: #0061ff;
+            cursor: pointer;
+        }
+        .tree .deleted {
+            color: #999;
+            text-decoration: line-through;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1>{{ t "ui.title" }}</h1>
+        <p><a href="reports">{{ t "archive.title" }}</a></p>
+        {{ if .Account }}<p class="account">{{ t "ui.account" }}: {{ .Account.Header }}</p>{{ end }}
+
+        <div class="status">
+            <span id="health">{{ t "ui.health" (t "ui.loading") }}</span>
+            <span id="breaker">{{ t "ui.api" (t "ui.loading") }}</span>
+            <span id="live">{{ t "ui.live" (t "ui.connecting") }}</span>
+            <span id="email" hidden></span>
+        </div>
+
+        <div class="controls">
+            {{ if .MultiAccount }}
+            <label for="account">{{ t "ui.account" }}:</label>
+            <select id="account" onchange="refresh()">
+                <option value="all">{{ t "ui.all_accounts" }}</option>
+                {{ range .Accounts }}
+                <option value="{{ .ID }}">{{ .Name }}</option>
+                {{ end }}
+            </select>
+            {{ end }}
+            <label for="hours">{{ t "ui.window" }}:</label>
+            <select id="hours" onchange="loadChanges()">
+                <option value="1">{{ t "ui.last_hour" }}</option>
+                <option value="24" selected>{{ t "ui.last_24_hours" }}</option>
+                <option value="168">{{ t "ui.last_7_days" }}</option>
+            </select>
+        </div>
+
+        <section id="changes-section">
+            <h2>{{ t "ui.recent_changes" }}</h2>
+            <table>
+                <thead>
+                    <tr><th>{{ t "ui.path" }}</th>{{ if .MultiAccount }}<th>{{ t "ui.account" }}</th>{{ end }}<th>{{ t "report.modified" }}</th><th>{{ t "report.size" }}</th></tr>
+                </thead>
+                <tbody id="changes"></tbody>
+            </table>
+        </section>
+
+        <section id="folders-section">
+            <h2>{{ t "ui.activity_by_folder" }}</h2>
+            <table class="folder-chart">
+                <thead>
+                    <tr><th>{{ t "ui.folder" }}</th><th>{{ t "ui.changes_per_day" }}</th><th>{{ t "ui.total" }}</th></tr>
+                </thead>
+                <tbody id="folders"></tbody>
+            </table>
+        </section>
+
+        <section id="heatmap-section">
+            <h2>{{ t "ui.activity_by_author" }}</h2>
+            <table class="heatmap">
+                <thead id="heatmap-head"></thead>
+                <tbody id="heatmap-body"></tbody>
+            </table>
+        </section>
+
+        <section id="types-section">
+            <h2>{{ t "ui.activity_by_type" }}</h2>
+            <p id="stats-summary"></p>
+            <table>
+                <thead>
+                    <tr><th>{{ t "ui.type" }}</th><th>{{ t "report.changes" }}</th><th>{{ t "report.files" }}</th><th>{{ t "report.deleted" }}</th><th>{{ t "report.size" }}</th></tr>
+                </thead>
+                <tbody id="types"></tbody>
+            </table>
+        </section>
+
+        <section id="tree-section">
+            <h2>{{ t "ui.folder_as_of" }}</h2>
+            <div class="controls">
+                <label for="tree-path">{{ t "ui.folder" }}:</label>
+                <input id="tree-path" type="text" value="/">
+                <label for="tree-at">{{ t "ui.as_of" }}:</label>
+                <input id="tree-at" type="datetime-local">
+                <label><input id="tree-deleted" type="checkbox"> {{ t "ui.show_deleted" }}</label>
+                <button onclick="loadTree()">{{ t "ui.show" }}</button>
+            </div>
+            <p id="tree-summary"></p>
+            <table class="tree">
+                <thead>
+                    <tr><th>{{ t "ui.name" }}</th><th>{{ t "report.size" }}</th><th>{{ t "ui.last_modified" }}</th><th>{{ t "ui.by" }}</th></tr>
+                </thead>
+                <tbody id="tree"></tbody>
+            </table>
+        </section>
+    </div>
+
+    <script>
+        const messages = {{ .Messages }};
+
+        // t formats the message of key, replacing each %s or %d with the
+        // next argument
+        function t(key, ...args) {
+            let i = 0;
+            return (messages[key] || key).replace(/%[sd]/g, () => args[i++]);
+        }
+
+        function selectedAccount() {
+            const el = document.getElementById('account');
+            return el ? el.value : 'all';
+        }
+
+        function changeRow(change) {
+            const row = document.createElement('tr');
+            const cells = [change.path];
+            {{ if .MultiAccount }}cells.push(change.account_id || '');{{ end }}
+            cells.push(new Date(change.modified_at).toLocaleString());
+            cells.push((change.size / 1048576).toFixed(2) + ' MB');
+            cells.forEach(value => {
+                const cell = document.createElement('td');
+                cell.textContent = value;
+                row.appendChild(cell);
+            });
+            return row;
+        }
+
+        let changesCursor = null;
+        let shownChanges = new Set();
+
+        function changeKey(change) {
+            return change.path + '|' + Date.parse(change.modified_at);
+        }
+
+        function showChange(change, className) {
+            const key = changeKey(change);
+            if (shownChanges.has(key)) {
+                return;
+            }
+            shownChanges.add(key);
+            const row = changeRow(change);
+            row.className = className || '';
+            const body = document.getElementById('changes');
+            body.insertBefore(row, body.firstChild);
+        }
+
+        function loadChanges() {
+            const params = new URLSearchParams({
+                account: selectedAccount(),
+                hours: document.getElementById('hours').value
+            });
+            changesCursor = null;
+            fetch('/api/changes/updates?' + params.toString())
+                .then(resp => resp.json())
+                .then(updates => {
+                    document.getElementById('changes').innerHTML = '';
+                    shownChanges = new Set();
+                    updates.changes.forEach(change => showChange(change));
+                    changesCursor = updates.cursor;
+                });
+        }
+
+        function loadNewChanges() {
+            if (changesCursor === null) {
+                return;
+            }
+            const params = new URLSearchParams({ account: selectedAccount(), cursor: changesCursor });
+            fetch('/api/changes/updates?' + params.toString())
+                .then(resp => resp.json())
+                .then(updates => {
+                    updates.changes.forEach(change => showChange(change, 'live-row'));
+                    changesCursor = updates.cursor;
+                    if (updates.more) {
+                        loadNewChanges();
+                    }
+                });
+        }
+
+        function setStatus(id, text, level) {
+            const el = document.getElementById(id);
+            el.textContent = text;
+            el.className = level;
+        }
+
+        function loadHealth() {
+            fetch('/api/health')
+                .then(resp => resp.json())
+                .then(health => {
+                    setStatus('health', t('ui.health', health.healthy ? t('ui.ok') : health.error), health.healthy ? 'ok' : 'bad');
+                    const breaker = health.circuit_breaker || 'unknown';
+                    const levels = { 'closed': 'ok', 'half-open': 'warn', 'open': 'bad' };
+                    setStatus('breaker', t('ui.api', t('ui.circuit', breaker)), levels[breaker] || '');
+                    if (health.quota === 'quota-paused') {
+                        setStatus('breaker', t('ui.api', t('ui.quota_paused', new Date(health.resume_at).toLocaleString())), 'warn');
+                    }
+                })
+                .catch(() => setStatus('health', t('ui.health', t('ui.unreachable')), 'bad'));
+        }
+
+        function loadEmail() {
+            fetch('/api/email/recipients')
+                .then(resp => resp.ok ? resp.json() : null)
+                .then(stats => {
+                    if (!stats) return;
+                    const el = document.getElementById('email');
+                    el.hidden = false;
+                    const total = stats.recipients.length;
+                    if (stats.disabled > 0) {
+                        setStatus('email', t('ui.email_disabled', stats.disabled, total), 'warn');
+                    } else {
+                        setStatus('email', t('ui.email_delivered', stats.delivered, stats.failed), stats.failed > 0 ? 'warn' : 'ok');
+                    }
+                })
+                .catch(() => {});
+        }
+
+        function loadFolders() {
+            const params = new URLSearchParams({ account: selectedAccount() });
+            fetch('/api/activity/folders?' + params.toString())
+                .then(resp => resp.json())
+                .then(activity => {
+                    const body = document.getElementById('folders');
+                    body.innerHTML = '';
+                    activity.folders.forEach(folder => {
+                        const row = document.createElement('tr');
+                        const name = document.createElement('td');
+                        name.textContent = folder.folder;
+                        row.appendChild(name);
+                        const bars = document.createElement('td');
+                        bars.className = 'bars';
+                        folder.counts.forEach((count, i) => {
+                            const bar = document.createElement('span');
+                            bar.className = 'bar';
+                            bar.style.height = Math.max(1, Math.round(40 * count / activity.max)) + 'px';
+                            bar.style.opacity = count > 0 ? 1 : 0.15;
+                            bar.title = activity.days[i] + ': ' + count;
+                            bars.appendChild(bar);
+                        });
+                        row.appendChild(bars);
+                        const total = document.createElement('td');
+                        total.textContent = folder.total;
+                        row.appendChild(total);
+                        body.appendChild(row);
+                    });
+                });
+        }
+
+        let stream = null;
+
+        function connectLive() {
+            if (stream) {
+                stream.close();
+            }
+            const params = new URLSearchParams({ account: selectedAccount() });
+            stream = new EventSource('/api/changes/stream?' + params.toString());
+            stream.onopen = () => {
+                setStatus('live', t('ui.live', t('ui.connected')), 'ok');
+                loadNewChanges();
+            };
+            stream.onerror = () => setStatus('live', t('ui.live', t('ui.reconnecting')), 'warn');
+            stream.addEventListener('change', event => {
+                showChange(JSON.parse(event.data), 'live-row');
+            });
+        }
+
+        function heatColor(count, max) {
+            if (count === 0 || max === 0) {
+                return '#f8f9fa';
+            }
+            const alpha = 0.15 + 0.85 * (count / max);
+            return 'rgba(0, 97, 255, ' + alpha.toFixed(2) + ')';
+        }
+
+        function loadHeatmap() {
+            const params = new URLSearchParams({ account: selectedAccount() });
+            fetch('/api/activity/authors?' + params.toString())
+                .then(resp => resp.json())
+                .then(heatmap => {
+                    const head = document.getElementById('heatmap-head');
+                    const body = document.getElementById('heatmap-body');
+                    head.innerHTML = '';
+                    body.innerHTML = '';
+
+                    const headRow = document.createElement('tr');
+                    const authorHeader = document.createElement('th');
+                    authorHeader.textContent = t('ui.author');
+                    headRow.appendChild(authorHeader);
+                    heatmap.days.forEach(day => {
+                        const th = document.createElement('th');
+                        th.className = 'day';
+                        th.textContent = day.slice(5);
+                        headRow.appendChild(th);
+                    });
+                    const totalHeader = document.createElement('th');
+                    totalHeader.textContent = t('ui.total');
+                    headRow.appendChild(totalHeader);
+                    head.appendChild(headRow);
+
+                    heatmap.authors.forEach(author => {
+                        const row = document.createElement('tr');
+                        const name = document.createElement('td');
+                        name.textContent = author.author;
+                        row.appendChild(name);
+                        author.counts.forEach((count, i) => {
+                            const cell = document.createElement('td');
+                            cell.style.backgroundColor = heatColor(count, heatmap.max);
+                            cell.title = author.author + ' - ' + heatmap.days[i] + ': ' + count;
+                            cell.textContent = count > 0 ? count : '';
+                            row.appendChild(cell);
+                        });
+                        const total = document.createElement('td');
+                        total.textContent = author.total;
+                        row.appendChild(total);
+                        body.appendChild(row);
+                    });
+                });
+        }
+
+        function loadStats() {
+            const params = new URLSearchParams({ account: selectedAccount() });
+            fetch('/api/stats/daily?' + params.toString())
+                .then(resp => resp.json())
+                .then(stats => {
+                    const busiest = stats.buckets.reduce((a, b) => b.changes > a.changes ? b : a, stats.buckets[0]);
+                    document.getElementById('stats-summary').textContent = t('ui.stats_summary', stats.changes,
+                        stats.files, (stats.bytes / 1048576).toFixed(2), stats.buckets.length) + (busiest && busiest.changes > 0 ?
+                        t('ui.busiest', new Date(busiest.start).toLocaleDateString()) : '');
+                });
+            params.set('limit', 10);
+            fetch('/api/stats/by-extension?' + params.toString())
+                .then(resp => resp.json())
+                .then(stats => {
+                    const body = document.getElementById('types');
+                    body.innerHTML = '';
+                    stats.groups.forEach(group => {
+                        const row = document.createElement('tr');
+                        [group.name, group.changes, group.files, group.deleted,
+                         (group.bytes / 1048576).toFixed(2) + ' MB'].forEach(value => {
+                            const cell = document.createElement('td');
+                            cell.textContent = value;
+                            row.appendChild(cell);
+                        });
+                        body.appendChild(row);
+                    });
+                });
+        }
+
+        function treeRow(name, className, cells, onclick) {
+            const row = document.createElement('tr');
+            [name].concat(cells).forEach(value => {
+                const cell = document.createElement('td');
+                cell.textContent = value;
+                row.appendChild(cell);
+            });
+            row.firstChild.className = className;
+            if (onclick) {
+                row.firstChild.onclick = onclick;
+            }
+            return row;
+        }
+
+        function openFolder(path) {
+            document.getElementById('tree-path').value = path;
+            loadTree();
+        }
+
+        function loadTree() {
+            const params = new URLSearchParams({
+                account: selectedAccount(),
+                path: document.getElementById('tree-path').value,
+                include_deleted: document.getElementById('tree-deleted').checked
+            });
+            const at = document.getElementById('tree-at').value;
+            if (at) {
+                params.set('at', new Date(at).toISOString());
+            }
+            fetch('/api/tree?' + params.toString())
+                .then(resp => resp.json())
+                .then(tree => {
+                    document.getElementById('tree-summary').textContent = t('ui.tree_summary', tree.path,
+                        new Date(tree.as_of).toLocaleString(), tree.total_files, (tree.total_bytes / 1048576).toFixed(2));
+                    const body = document.getElementById('tree');
+                    body.innerHTML = '';
+                    if (tree.path !== '/') {
+                        const parent = tree.path.slice(0, tree.path.lastIndexOf('/')) || '/';
+                        body.appendChild(treeRow('..', 'folder', ['', '', ''], () => openFolder(parent)));
+                    }
+                    tree.folders.forEach(folder => {
+                        body.appendChild(treeRow(folder.name + '/', 'folder',
+                            [(folder.size / 1048576).toFixed(2) + ' MB', t('ui.n_files', folder.files), ''],
+                            () => openFolder(folder.path)));
+                    });
+                    tree.files.forEach(file => {
+                        body.appendChild(treeRow(file.name, file.deleted ? 'deleted' : '',
+                            [file.deleted ? t('ui.deleted') : (file.size / 1048576).toFixed(2) + ' MB',
+                             new Date(file.modified_at).toLocaleString(), file.modified_by || '']));
+                    });
+                });
+        }
+
+        function refresh() {
+            loadChanges();
+            loadFolders();
+            loadHeatmap();
+            loadStats();
+            loadTree();
+            connectLive();
+        }
+
+        refresh();
+        loadHealth();
+        loadEmail();
+        setInterval(loadHealth, 30000);
+        setInterval(loadEmail, 300000);
+        setInterval(loadNewChanges, 30000);
+    </script>
+</body>
+</html>
+`
+
+// dashboardTmpl is the parsed dashboard, cloned to render it in the
+// language of a catalog
+var dashboardTmpl = template.Must(template.New("dashboard").Funcs(dashboardFuncs(nil)).Parse(dashboardTemplate))
+
+// dashboardFuncs returns the template functions translating the dashboard
+// through catalog
+func dashboardFuncs(catalog *i18n.Catalog) template.FuncMap {
+	return template.FuncMap{"t": catalog.T}
+}
+
+// dashboardData holds the values rendered into the dashboard page
+type dashboardData struct {
+	MultiAccount bool
+	Accounts     []accountView
+	Account      *models.AccountInfo
+	Language     i18n.Language
+	// Messages are the ui messages translated by the page's script
+	Messages map[string]string
+}
+
+// handleIndex renders the dashboard page
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := dashboardData{
+		MultiAccount: s.config != nil && s.config.IsMultiAccount(),
+		Accounts:     s.accounts(),
+		Account:      s.accountInfo(r.Context()),
+		Language:     s.catalog.Language(),
+		Messages:     s.catalog.Messages("ui."),
+	}
+
+	tmpl, err := dashboardTmpl.Clone()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmpl.Funcs(dashboardFuncs(s.catalog))
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
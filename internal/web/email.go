@@ -0,0 +1,56 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/notify"
+)
+
+// deliveryTracker reports email delivery stats and re-enables recipients
+type deliveryTracker interface {
+	Stats(ctx context.Context) (notify.DeliveryStats, error)
+	Enable(ctx context.Context, address string) error
+}
+
+// recipientEnable is the body of a request to re-enable a recipient
+type recipientEnable struct {
+	Address string `json:"address"`
+}
+
+// handleEmailRecipients returns the delivery stats of every email recipient.
+// A POST carrying the API token re-enables the recipient in its body.
+func (s *Server) handleEmailRecipients(w http.ResponseWriter, r *http.Request) {
+	if s.deliveries == nil {
+		writeError(w, http.StatusServiceUnavailable, "email is not configured")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var enable recipientEnable
+		if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&enable); err != nil || strings.TrimSpace(enable.Address) == "" {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if err := s.deliveries.Enable(r.Context(), enable.Address); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	stats, err := s.deliveries.Stats(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,124 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// maxEventsBodySize limits the size of injected event payloads
+const maxEventsBodySize = 1 << 20
+
+// changeIngester accepts externally sourced changes into the pipeline
+type changeIngester interface {
+	IngestChanges(ctx context.Context, changes []models.FileChange) error
+}
+
+// handleEvents accepts a JSON change or array of changes from an external
+// source and feeds them through the regular storage and reporting pipeline.
+// Requests must carry the configured API token as a bearer token.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if s.ingester == nil {
+		writeError(w, http.StatusServiceUnavailable, "event injection is not enabled")
+		return
+	}
+
+	changes, err := decodeChanges(io.LimitReader(r.Body, maxEventsBodySize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for i := range changes {
+		changes[i].Normalize()
+		if err := changes[i].Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid change %d: %v", i, err))
+			return
+		}
+	}
+
+	if err := s.ingester.IngestChanges(r.Context(), changes); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]int{"accepted": len(changes)})
+}
+
+// authorized reports whether the request carries the configured API token
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.config.Web.APIToken)) == 1
+}
+
+// requireToken rejects requests that change state unless they carry the
+// configured API token; reads pass through, and without a token configured
+// such requests are refused outright
+func (s *Server) requireToken(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if s.config == nil || s.config.Web.APIToken == "" {
+			writeError(w, http.StatusServiceUnavailable, "no API token is configured")
+			return
+		}
+		if !s.authorized(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "invalid or missing API token")
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// decodeChanges parses a single change object or an array of changes,
+// rejecting fields that are not part of models.FileChange
+func decodeChanges(r io.Reader) ([]models.FileChange, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("request body is empty")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.DisallowUnknownFields()
+
+	if trimmed[0] == '{' {
+		var change models.FileChange
+		if err := decoder.Decode(&change); err != nil {
+			return nil, fmt.Errorf("invalid change: %w", err)
+		}
+		return []models.FileChange{change}, nil
+	}
+
+	var changes []models.FileChange
+	if err := decoder.Decode(&changes); err != nil {
+		return nil, fmt.Errorf("invalid changes: %w", err)
+	}
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("no changes provided")
+	}
+	return changes, nil
+}
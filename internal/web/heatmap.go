@@ -0,0 +1,199 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+)
+
+// defaultHeatmapDays is the number of days shown when no "days" parameter is given
+const defaultHeatmapDays = 14
+
+// heatmapView is the JSON representation of author activity per day
+type heatmapView struct {
+	Days    []string        `json:"days"`
+	Authors []authorHeatRow `json:"authors"`
+	Max     int             `json:"max"`
+}
+
+// authorHeatRow holds one author's change counts aligned with heatmapView.Days
+type authorHeatRow struct {
+	Author string `json:"author"`
+	Counts []int  `json:"counts"`
+	Total  int    `json:"total"`
+}
+
+// folderActivityView is the JSON representation of activity per folder per day
+type folderActivityView struct {
+	Days    []string        `json:"days"`
+	Folders []folderHeatRow `json:"folders"`
+	Max     int             `json:"max"`
+}
+
+// folderHeatRow holds one folder's change counts aligned with folderActivityView.Days
+type folderHeatRow struct {
+	Folder string `json:"folder"`
+	Counts []int  `json:"counts"`
+	Total  int    `json:"total"`
+}
+
+// handleAuthorHeatmap returns change counts per author per day for the
+// account in the "account" query parameter over the last "days" days
+func (s *Server) handleAuthorHeatmap(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeError(w, http.StatusServiceUnavailable, "database is not available")
+		return
+	}
+
+	accountID, err := s.accountFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	start, days, err := activityWindow(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	activity, err := s.db.GetAuthorActivity(r.Context(), accountID, start)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, buildHeatmap(activity, start, days))
+}
+
+// handleFolderActivity returns change counts per monitored folder per day for
+// the account in the "account" query parameter over the last "days" days.
+// Changes outside every monitored folder are grouped by their top-level folder.
+func (s *Server) handleFolderActivity(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeError(w, http.StatusServiceUnavailable, "database is not available")
+		return
+	}
+
+	accountID, err := s.accountFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	start, days, err := activityWindow(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var folders []string
+	if s.config != nil {
+		for _, folder := range s.config.Monitoring.GetFolders() {
+			folders = append(folders, folder.Path)
+		}
+	}
+
+	activity, err := s.db.GetFolderActivity(r.Context(), accountID, start, folders)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, buildFolderActivity(activity, start, days))
+}
+
+// activityWindow parses the "days" query parameter, returning the start of the
+// first day and the number of days
+func activityWindow(r *http.Request) (time.Time, int, error) {
+	days := defaultHeatmapDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			return time.Time{}, 0, fmt.Errorf("invalid days value %q", value)
+		}
+		days = parsed
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	return today.AddDate(0, 0, -(days - 1)), days, nil
+}
+
+// dayColumns returns the labels of days days starting at start and their
+// column indexes
+func dayColumns(start time.Time, days int) ([]string, map[string]int) {
+	labels := make([]string, days)
+	index := make(map[string]int, days)
+	for i := 0; i < days; i++ {
+		day := start.AddDate(0, 0, i).Format("2006-01-02")
+		labels[i] = day
+		index[day] = i
+	}
+	return labels, index
+}
+
+// buildFolderActivity lays out folder activity as one row per folder with a
+// column for each day starting at start
+func buildFolderActivity(activity []db.FolderActivity, start time.Time, days int) folderActivityView {
+	labels, index := dayColumns(start, days)
+	view := folderActivityView{
+		Days:    labels,
+		Folders: make([]folderHeatRow, 0),
+	}
+
+	rows := make(map[string]int)
+	for _, a := range activity {
+		column, ok := index[a.Day.Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		row, ok := rows[a.Folder]
+		if !ok {
+			row = len(view.Folders)
+			rows[a.Folder] = row
+			view.Folders = append(view.Folders, folderHeatRow{Folder: a.Folder, Counts: make([]int, days)})
+		}
+		view.Folders[row].Counts[column] += a.Count
+		view.Folders[row].Total += a.Count
+		if view.Folders[row].Counts[column] > view.Max {
+			view.Max = view.Folders[row].Counts[column]
+		}
+	}
+
+	return view
+}
+
+// buildHeatmap lays out author activity as one row per author with a column
+// for each day starting at start
+func buildHeatmap(activity []db.AuthorActivity, start time.Time, days int) heatmapView {
+	labels, index := dayColumns(start, days)
+	view := heatmapView{
+		Days:    labels,
+		Authors: make([]authorHeatRow, 0),
+	}
+
+	rows := make(map[string]int)
+	for _, a := range activity {
+		column, ok := index[a.Day.Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		row, ok := rows[a.Author]
+		if !ok {
+			row = len(view.Authors)
+			rows[a.Author] = row
+			view.Authors = append(view.Authors, authorHeatRow{Author: a.Author, Counts: make([]int, days)})
+		}
+		view.Authors[row].Counts[column] += a.Count
+		view.Authors[row].Total += a.Count
+		if view.Authors[row].Counts[column] > view.Max {
+			view.Max = view.Authors[row].Counts[column]
+		}
+	}
+
+	return view
+}
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"net/http"
+	"path"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+)
+
+// filesView is the JSON representation of the file inventory under a folder
+type filesView struct {
+	Path       string             `json:"path"`
+	Files      []db.InventoryFile `json:"files"`
+	TotalFiles int                `json:"total_files"`
+	TotalBytes int64              `json:"total_bytes"`
+}
+
+// handleFiles returns every file known to exist under the folder in the
+// "path" query parameter, as recorded by the initial and incremental syncs,
+// including files that never changed while being monitored
+func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeError(w, http.StatusServiceUnavailable, "database is not available")
+		return
+	}
+
+	accountID, err := s.accountFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	root := path.Clean("/" + r.URL.Query().Get("path"))
+	files, err := s.db.GetInventory(r.Context(), accountID, root)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	view := filesView{Path: root, Files: files}
+	if view.Files == nil {
+		view.Files = []db.InventoryFile{}
+	}
+	for _, file := range files {
+		view.TotalFiles++
+		view.TotalBytes += file.Size
+	}
+	writeJSON(w, http.StatusOK, view)
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/core"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+const (
+	// liveBufferSize is the number of change batches queued for a slow client
+	// before further batches are dropped
+	liveBufferSize = 32
+
+	// liveKeepAlive is how often an idle stream sends a comment so proxies
+	// keep the connection open
+	liveKeepAlive = 30 * time.Second
+)
+
+// changeSubscriber delivers changes as the monitored folders report them
+type changeSubscriber interface {
+	Subscribe(handler core.ChangeHandler) func()
+}
+
+// handleChangeStream streams changes to the client as Server-Sent Events, one
+// "change" event per file, scoped to the account in the "account" query
+// parameter
+func (s *Server) handleChangeStream(w http.ResponseWriter, r *http.Request) {
+	if s.subscriber == nil {
+		writeError(w, http.StatusServiceUnavailable, "live changes are not available")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	accountID, err := s.accountFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The handler runs on the change detection path, so it must never block
+	batches := make(chan []models.FileChange, liveBufferSize)
+	unsubscribe := s.subscriber.Subscribe(func(ctx context.Context, changes []models.FileChange) error {
+		select {
+		case batches <- changes:
+			return nil
+		default:
+			return fmt.Errorf("live change client is falling behind, dropped %d changes", len(changes))
+		}
+	})
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(liveKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case changes := <-batches:
+			for _, change := range changes {
+				if accountID != "" && change.AccountID != accountID {
+					continue
+				}
+				data, err := json.Marshal(toLiveChangeView(change))
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "event: change\ndata: %s\n\n", data)
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// toLiveChangeView converts a detected change to the JSON representation used
+// by /api/changes
+func toLiveChangeView(change models.FileChange) changeView {
+	modified := change.Modified
+	if modified.IsZero() {
+		modified = change.ModTime
+	}
+	return changeView{
+		Path:         change.Path,
+		Size:         change.Size,
+		ModifiedAt:   modified,
+		ModifiedBy:   change.ModifiedBy,
+		AccountID:    change.AccountID,
+		Portfolio:    change.Portfolio,
+		Project:      change.Project,
+		DocumentType: change.DocumentType,
+		ChangeType:   change.Type(),
+		PreviousPath: change.PreviousPath,
+		Details:      change.Details,
+	}
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// notificationQueueView is the JSON representation of the notification
+// retry queue
+type notificationQueueView struct {
+	Pending      []models.QueuedNotification `json:"pending"`
+	DeadLettered []models.QueuedNotification `json:"dead_lettered"`
+}
+
+// handleNotifications returns the notifications waiting to be retried and
+// those dead-lettered after their last attempt, oldest first; the "channel"
+// query parameter limits them to one channel
+func (s *Server) handleNotifications(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeError(w, http.StatusServiceUnavailable, "database is not available")
+		return
+	}
+
+	channel := r.URL.Query().Get("channel")
+	pending, err := s.db.PendingNotifications(r.Context(), channel)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	dead, err := s.db.DeadLetteredNotifications(r.Context(), channel)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	view := notificationQueueView{Pending: pending, DeadLettered: dead}
+	if view.Pending == nil {
+		view.Pending = []models.QueuedNotification{}
+	}
+	if view.DeadLettered == nil {
+		view.DeadLettered = []models.QueuedNotification{}
+	}
+	writeJSON(w, http.StatusOK, view)
+}
+
+// handleNotificationRequeue returns the dead-lettered notification in the
+// "id" query parameter to the retry queue; it requires the API token
+func (s *Server) handleNotificationRequeue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.db == nil {
+		writeError(w, http.StatusServiceUnavailable, "database is not available")
+		return
+	}
+
+	id, err := idFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	requeued, err := s.db.RequeueNotification(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !requeued {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no dead-lettered notification %d", id))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
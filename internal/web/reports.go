@@ -0,0 +1,269 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/i18n"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// Page sizes of the report archive
+const (
+	defaultReportLimit = 50
+	maxReportLimit     = 500
+)
+
+// reportResender sends archived reports again
+type reportResender interface {
+	ResendReport(ctx context.Context, id int64) error
+}
+
+const reportsTemplate = `<!DOCTYPE html>
+<html lang="{{ .Language }}">
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <title>{{ t "archive.title" }} - {{ t "ui.title" }}</title>
+    <style>
+        body { font-family: Arial, sans-serif; max-width: 1200px; margin: 0 auto; padding: 20px; background-color: #f5f5f5; color: #333; }
+        .container { background-color: white; padding: 20px; border-radius: 8px; box-shadow: 0 2px 4px rgba(0,0,0,0.1); }
+        table { width: 100%; border-collapse: collapse; }
+        th, td { padding: 8px; text-align: left; border-bottom: 1px solid #ddd; vertical-align: top; }
+        th { background-color: #f8f9fa; }
+        a { color: #0061ff; }
+        .failed { color: #c0392b; }
+        .error { color: #999; font-size: 0.9em; }
+        button { padding: 4px 10px; background-color: #0061ff; color: white; border: none; border-radius: 4px; cursor: pointer; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <p><a href="./">{{ t "ui.title" }}</a></p>
+        <h1>{{ t "archive.title" }}</h1>
+        {{ if .Reports }}
+        <table>
+            <thead>
+                <tr>
+                    <th>{{ t "archive.generated" }}</th>
+                    <th>{{ t "archive.report" }}</th>
+                    <th>{{ t "archive.period" }}</th>
+                    <th>{{ t "archive.changes" }}</th>
+                    <th>{{ t "archive.recipients" }}</th>
+                    <th>{{ t "archive.status" }}</th>
+                    <th></th>
+                </tr>
+            </thead>
+            <tbody>
+                {{ range .Reports }}
+                <tr>
+                    <td>{{ .GeneratedAt.Local.Format "2006-01-02 15:04" }}</td>
+                    <td>{{ if .Title }}{{ .Title }}{{ else }}{{ t "report.changes_title" }}{{ end }} ({{ .Type }})</td>
+                    <td>{{ .Period }}</td>
+                    <td>{{ .Changes }}</td>
+                    <td>{{ range $i, $r := .Recipients }}{{ if $i }}, {{ end }}{{ $r }}{{ end }}</td>
+                    <td class="{{ .Status }}">
+                        {{ t (print "archive." .Status) }}{{ if gt .Attempts 1 }} ({{ t "archive.attempts" .Attempts }}){{ end }}
+                        {{ if .LastError }}<div class="error">{{ .LastError }}</div>{{ end }}
+                    </td>
+                    <td>
+                        <a href="api/reports/download?id={{ .ID }}">{{ t "archive.download" }}</a>
+                        <button type="button" onclick="resend({{ .ID }})">{{ t "archive.resend" }}</button>
+                    </td>
+                </tr>
+                {{ end }}
+            </tbody>
+        </table>
+        {{ else }}
+        <p>{{ t "archive.empty" }}</p>
+        {{ end }}
+    </div>
+
+    <script>
+        const messages = {{ .Messages }};
+
+        // t formats the message of key, replacing each %s or %d with the
+        // next argument
+        function t(key, ...args) {
+            let i = 0;
+            return (messages[key] || key).replace(/%[sd]/g, () => args[i++]);
+        }
+
+        // resend asks the server to send a report again, prompting for the
+        // API token once per session
+        async function resend(id) {
+            const token = sessionStorage.getItem('apiToken') || prompt(t('archive.token_prompt'));
+            if (!token) {
+                return;
+            }
+            const response = await fetch('api/reports/resend?id=' + id, {
+                method: 'POST',
+                headers: { 'Authorization': 'Bearer ' + token },
+            });
+            if (response.status === 401) {
+                sessionStorage.removeItem('apiToken');
+            } else {
+                sessionStorage.setItem('apiToken', token);
+            }
+            if (response.ok) {
+                alert(t('archive.resent'));
+            } else {
+                const body = await response.json().catch(() => ({}));
+                alert(t('archive.resend_failed', body.error || response.statusText));
+            }
+            location.reload();
+        }
+    </script>
+</body>
+</html>
+`
+
+// reportsTmpl is the parsed report archive page, cloned to render it in the
+// language of a catalog
+var reportsTmpl = template.Must(template.New("reports").Funcs(dashboardFuncs(nil)).Parse(reportsTemplate))
+
+// reportsData holds the values rendered into the report archive page
+type reportsData struct {
+	Language i18n.Language
+	Reports  []models.ArchivedReport
+	// Messages are the archive messages translated by the page's script
+	Messages map[string]string
+}
+
+// handleReports renders the archive of sent reports, newest first
+func (s *Server) handleReports(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		http.Error(w, "database is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	reports, err := s.db.ListReports(r.Context(), defaultReportLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := reportsTmpl.Clone()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmpl.Funcs(dashboardFuncs(s.catalog))
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = tmpl.Execute(w, reportsData{
+		Language: s.catalog.Language(),
+		Reports:  reports,
+		Messages: s.catalog.Messages("archive."),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleReportList returns the archived reports without their content,
+// newest first; "limit" caps how many are returned
+func (s *Server) handleReportList(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeError(w, http.StatusServiceUnavailable, "database is not available")
+		return
+	}
+
+	limit := defaultReportLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit value %q", value))
+			return
+		}
+		limit = min(parsed, maxReportLimit)
+	}
+
+	reports, err := s.db.ListReports(r.Context(), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if reports == nil {
+		reports = []models.ArchivedReport{}
+	}
+	writeJSON(w, http.StatusOK, reports)
+}
+
+// handleReportDownload returns the content of the archived report in the
+// "id" query parameter as a file
+func (s *Server) handleReportDownload(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeError(w, http.StatusServiceUnavailable, "database is not available")
+		return
+	}
+
+	id, err := idFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := s.db.GetReport(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if report == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("report %d not found", id))
+		return
+	}
+
+	w.Header().Set("Content-Type", report.Type.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", report.Filename()))
+	w.Write([]byte(report.Content))
+}
+
+// handleReportResend sends the archived report in the "id" query parameter
+// again; it requires the API token
+func (s *Server) handleReportResend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.resender == nil {
+		writeError(w, http.StatusServiceUnavailable, "report re-sending is not available")
+		return
+	}
+
+	id, err := idFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if s.db != nil {
+		report, err := s.db.GetReport(r.Context(), id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if report == nil {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("report %d not found", id))
+			return
+		}
+	}
+	if err := s.resender.ResendReport(r.Context(), id); err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// idFromRequest parses the "id" query parameter
+func idFromRequest(r *http.Request) (int64, error) {
+	value := r.URL.Query().Get("id")
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid id %q", value)
+	}
+	return id, nil
+}
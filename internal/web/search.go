@@ -0,0 +1,84 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/embeddings"
+)
+
+// semanticSearcher finds documents similar in meaning to a query
+type semanticSearcher interface {
+	Search(ctx context.Context, query string, k int) ([]db.SimilarDocument, error)
+}
+
+// handleSearch returns the documents most similar to the "q" query
+// parameter; "k" sets how many, up to embeddings.MaxResults
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if s.search == nil {
+		writeError(w, http.StatusServiceUnavailable, "semantic search is not enabled")
+		return
+	}
+
+	k := embeddings.DefaultResults
+	if value := r.URL.Query().Get("k"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > embeddings.MaxResults {
+			writeError(w, http.StatusBadRequest, "k must be between 1 and "+strconv.Itoa(embeddings.MaxResults))
+			return
+		}
+		k = parsed
+	}
+
+	results, err := s.search.Search(r.Context(), r.URL.Query().Get("q"), k)
+	if errors.Is(err, embeddings.ErrEmptyQuery) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if results == nil {
+		results = []db.SimilarDocument{}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// handleTextSearch returns the files whose stored text contains every word
+// of the "q" query parameter; "k" sets how many, up to db.MaxSearchResults
+func (s *Server) handleTextSearch(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeError(w, http.StatusServiceUnavailable, "database is not available")
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	k := db.DefaultSearchResults
+	if value := r.URL.Query().Get("k"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > db.MaxSearchResults {
+			writeError(w, http.StatusBadRequest, "k must be between 1 and "+strconv.Itoa(db.MaxSearchResults))
+			return
+		}
+		k = parsed
+	}
+
+	results, err := s.db.Search(r.Context(), query, k)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if results == nil {
+		results = []db.TextMatch{}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
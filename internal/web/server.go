@@ -2,26 +2,106 @@ package web
 
 import (
 	"context"
+	"log/slog"
 	"net/http"
 
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/container"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/features"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/i18n"
 	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/metrics"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/safego"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/webhook"
 )
 
 // Server represents the web server
 type Server struct {
 	*lifecycle.BaseComponent
-	container *container.Container
-	server    *http.Server
+	container  *container.Container
+	server     *http.Server
+	config     *config.Config
+	db         *db.DB
+	previewer  reportPreviewer
+	resender   reportResender
+	webhook    http.Handler
+	ingester   changeIngester
+	limits     limitStatser
+	features   *features.Registry
+	account    accountInfoer
+	subscriber changeSubscriber
+	breaker    circuitStater
+	quota      quotaPauser
+	actions    actionApplier
+	search     semanticSearcher
+	deliveries deliveryTracker
+	components componentHealther
+	catalog    *i18n.Catalog
+	logger     *slog.Logger
 }
 
 // NewServer creates a new web server
 func NewServer(c *container.Container) *Server {
-	return &Server{
+	cfg := c.GetConfig()
+	addr := ":8080"
+	if cfg != nil && cfg.Web.Address != "" {
+		addr = cfg.Web.Address
+	}
+	var language string
+	if cfg != nil {
+		language = cfg.Report.Language
+	}
+
+	s := &Server{
 		BaseComponent: lifecycle.NewBaseComponent("WebServer"),
-		container:    c,
-		server:      &http.Server{Addr: ":8080"},
+		container:     c,
+		server:        &http.Server{Addr: addr},
+		config:        cfg,
+		db:            c.GetDB(),
+		previewer:     c,
+		resender:      c,
+		ingester:      c,
+		limits:        c,
+		features:      c.Features(),
+		account:       c,
+		subscriber:    c,
+		breaker:       c,
+		quota:         c,
+		components:    c,
+		catalog:       i18n.Lookup(language),
+		logger:        logging.Component(c.Logger(), "web"),
+	}
+
+	if links := c.Actions(); links != nil {
+		s.actions = links
+	}
+	if index := c.Embeddings(); index != nil {
+		s.search = index
+	}
+	if bounces := c.Bounces(); bounces != nil {
+		s.deliveries = bounces
+	}
+
+	if cfg != nil && len(cfg.Webhook.Secrets()) > 0 {
+		handler, err := webhook.NewHandler(cfg.Webhook.Secrets(), cfg.Webhook.ReplayWindow, c)
+		if err != nil {
+			s.log().Warn("Webhook receiver disabled", "error", err)
+		} else {
+			s.webhook = handler
+		}
+	}
+
+	return s
+}
+
+// log returns the logger of the web server
+func (s *Server) log() *slog.Logger {
+	if s.logger == nil {
+		return logging.Component(nil, "web")
 	}
+	return s.logger
 }
 
 // Start starts the web server
@@ -36,22 +116,63 @@ func (s *Server) Start(ctx context.Context) error {
 	}
 
 	// Set up routes
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", s.handleIndex)
-	mux.HandleFunc("/health", s.handleHealth)
-	s.server.Handler = mux
+	s.server.Handler = s.routes()
 
 	// Start server
-	go func() {
+	safego.Go(s.log(), "web server", func() {
 		if err := s.server.ListenAndServe(); err != http.ErrServerClosed {
 			s.SetState(lifecycle.StateFailed)
 		}
-	}()
+	})
 
 	s.SetState(lifecycle.StateRunning)
 	return nil
 }
 
+// routes builds the HTTP handler for all server endpoints
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleIndex)
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/healthz", s.handleLiveness)
+	mux.HandleFunc("/readyz", s.handleReadiness)
+	mux.HandleFunc("/actions", s.handleAction)
+	mux.HandleFunc("/reports", s.handleReports)
+	mux.HandleFunc("/status", s.handleStatus)
+	mux.Handle("/api/features", s.requireToken(http.HandlerFunc(s.handleFeatures)))
+	mux.HandleFunc("/api/account", s.handleAccount)
+	mux.HandleFunc("/api/accounts", s.handleAccounts)
+	mux.HandleFunc("/api/health", s.handleHealthStatus)
+	mux.Handle("/api/circuit-breaker/reset", s.requireToken(http.HandlerFunc(s.handleCircuitReset)))
+	mux.HandleFunc("/api/changes", s.handleChanges)
+	mux.HandleFunc("/api/changes/stream", s.handleChangeStream)
+	mux.HandleFunc("/api/changes/updates", s.handleChangeUpdates)
+	mux.HandleFunc("/api/report/preview", s.handleReportPreview)
+	mux.HandleFunc("/api/reports", s.handleReportList)
+	mux.HandleFunc("/api/reports/download", s.handleReportDownload)
+	mux.Handle("/api/reports/resend", s.requireToken(http.HandlerFunc(s.handleReportResend)))
+	mux.HandleFunc("/api/activity/authors", s.handleAuthorHeatmap)
+	mux.HandleFunc("/api/activity/folders", s.handleFolderActivity)
+	mux.HandleFunc("/api/stats/daily", s.handleStatsDaily)
+	mux.HandleFunc("/api/stats/by-folder", s.handleStatsBy(db.StatsByFolder))
+	mux.HandleFunc("/api/stats/by-extension", s.handleStatsBy(db.StatsByExtension))
+	mux.HandleFunc("/api/stats/by-author", s.handleStatsBy(db.StatsByAuthor))
+	mux.HandleFunc("/api/tree", s.handleTree)
+	mux.HandleFunc("/api/files", s.handleFiles)
+	mux.Handle("/api/events", s.requireToken(http.HandlerFunc(s.handleEvents)))
+	mux.HandleFunc("/api/limits", s.handleLimits)
+	mux.Handle("/api/email/recipients", s.requireToken(http.HandlerFunc(s.handleEmailRecipients)))
+	mux.HandleFunc("/api/notifications", s.handleNotifications)
+	mux.Handle("/api/notifications/requeue", s.requireToken(http.HandlerFunc(s.handleNotificationRequeue)))
+	mux.Handle("/api/search", s.gated(features.VectorSearch, http.HandlerFunc(s.handleSearch)))
+	mux.HandleFunc("/api/search/text", s.handleTextSearch)
+	mux.Handle("/metrics", metrics.Default.Handler())
+	if s.webhook != nil {
+		mux.Handle("/webhook", s.gated(features.Webhooks, s.webhook))
+	}
+	return mux
+}
+
 // Stop stops the web server
 func (s *Server) Stop(ctx context.Context) error {
 	if err := ctx.Err(); err != nil {
@@ -86,14 +207,12 @@ func (s *Server) Health(ctx context.Context) error {
 		return lifecycle.ErrNotRunning
 	}
 
+	if s.container == nil {
+		return nil
+	}
 	return s.container.Health(ctx)
 }
 
-// handleIndex handles the index page
-func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte("Welcome to Dropbox Monitor"))
-}
-
 // handleHealth handles the health check endpoint
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	if err := s.Health(r.Context()); err != nil {
@@ -0,0 +1,1029 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/actions"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/core"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/embeddings"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/features"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/health"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/i18n"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/limits"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) *Server {
+	database, err := db.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+
+	cfg := &config.Config{
+		Accounts: []config.AccountConfig{
+			{ID: "work", Name: "Work", DropboxToken: "work-token"},
+			{ID: "home", Name: "Home", DropboxToken: "home-token"},
+		},
+	}
+
+	now := time.Now()
+	for _, change := range []*db.FileChange{
+		{FilePath: "/work/report.docx", ModifiedAt: now, ContentHash: "a", AccountID: "work", Size: 10},
+		{FilePath: "/home/photo.jpg", ModifiedAt: now, ContentHash: "b", AccountID: "home", Size: 20},
+	} {
+		require.NoError(t, database.SaveFileChange(context.Background(), change))
+	}
+
+	return &Server{
+		BaseComponent: lifecycle.NewBaseComponent("WebServer"),
+		config:        cfg,
+		db:            database,
+	}
+}
+
+func getJSON(t *testing.T, handler http.Handler, url string, v interface{}) int {
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if v != nil && rec.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), v))
+	}
+	return rec.Code
+}
+
+func TestServer_Accounts(t *testing.T) {
+	handler := newTestServer(t).routes()
+
+	var accounts []accountView
+	assert.Equal(t, http.StatusOK, getJSON(t, handler, "/api/accounts", &accounts))
+	assert.Equal(t, []accountView{{ID: "work", Name: "Work"}, {ID: "home", Name: "Home"}}, accounts)
+}
+
+func TestServer_ChangesScopedByAccount(t *testing.T) {
+	handler := newTestServer(t).routes()
+
+	tests := []struct {
+		name      string
+		url       string
+		wantCode  int
+		wantPaths []string
+	}{
+		{name: "all accounts", url: "/api/changes?account=all", wantCode: http.StatusOK, wantPaths: []string{"/work/report.docx", "/home/photo.jpg"}},
+		{name: "default is all", url: "/api/changes", wantCode: http.StatusOK, wantPaths: []string{"/work/report.docx", "/home/photo.jpg"}},
+		{name: "single account", url: "/api/changes?account=work", wantCode: http.StatusOK, wantPaths: []string{"/work/report.docx"}},
+		{name: "unknown account", url: "/api/changes?account=other", wantCode: http.StatusBadRequest},
+		{name: "invalid window", url: "/api/changes?hours=abc", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var changes []changeView
+			code := getJSON(t, handler, tt.url, &changes)
+			assert.Equal(t, tt.wantCode, code)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			paths := make([]string, 0, len(changes))
+			for _, change := range changes {
+				paths = append(paths, change.Path)
+			}
+			assert.ElementsMatch(t, tt.wantPaths, paths)
+		})
+	}
+}
+
+func TestServer_DashboardShowsAccountSwitcher(t *testing.T) {
+	handler := newTestServer(t).routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `<select id="account"`)
+	assert.Contains(t, rec.Body.String(), "All accounts")
+	assert.Contains(t, rec.Body.String(), `<option value="home">Home</option>`)
+}
+
+// stubPreviewer returns a canned report for the requested type
+type stubPreviewer struct {
+	content string
+}
+
+func (p *stubPreviewer) PreviewReport(ctx context.Context, reportType models.ReportType) (*models.Report, error) {
+	if reportType != models.HTMLReport && reportType != models.FileListReport {
+		return nil, fmt.Errorf("unsupported report type: %s", reportType)
+	}
+	report := models.NewReport(reportType)
+	report.Metadata["content"] = p.content
+	return report, nil
+}
+
+func TestServer_ReportPreview(t *testing.T) {
+	s := newTestServer(t)
+	s.previewer = &stubPreviewer{content: "<h1>Preview</h1>"}
+	handler := s.routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/report/preview", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "<h1>Preview</h1>", rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/api/report/preview?type=file_list", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	req = httptest.NewRequest(http.MethodGet, "/api/report/preview?type=bogus", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestServer_AuthorHeatmap(t *testing.T) {
+	handler := newTestServer(t).routes()
+
+	var heatmap heatmapView
+	assert.Equal(t, http.StatusOK, getJSON(t, handler, "/api/activity/authors?days=7", &heatmap))
+	assert.Len(t, heatmap.Days, 7)
+	assert.Equal(t, time.Now().Format("2006-01-02"), heatmap.Days[6])
+	require.Len(t, heatmap.Authors, 1)
+	assert.Equal(t, "unknown", heatmap.Authors[0].Author)
+	assert.Equal(t, 2, heatmap.Authors[0].Counts[6])
+	assert.Equal(t, 2, heatmap.Max)
+
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, handler, "/api/activity/authors?days=0", nil))
+}
+
+func TestServer_FolderActivity(t *testing.T) {
+	s := newTestServer(t)
+	s.config.Monitoring.Folders = []config.MonitoredFolderConfig{{Path: "/Work"}}
+
+	var activity folderActivityView
+	require.Equal(t, http.StatusOK, getJSON(t, s.routes(), "/api/activity/folders?days=7", &activity))
+	assert.Len(t, activity.Days, 7)
+	require.Len(t, activity.Folders, 2)
+	assert.Equal(t, "/Work", activity.Folders[0].Folder)
+	assert.Equal(t, "/home", activity.Folders[1].Folder)
+	assert.Equal(t, 1, activity.Folders[0].Counts[6])
+	assert.Equal(t, 1, activity.Max)
+
+	require.Equal(t, http.StatusOK, getJSON(t, s.routes(), "/api/activity/folders?account=home", &activity))
+	require.Len(t, activity.Folders, 1)
+	assert.Equal(t, "/home", activity.Folders[0].Folder)
+
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, s.routes(), "/api/activity/folders?days=x", nil))
+}
+
+func TestServer_Stats(t *testing.T) {
+	s := newTestServer(t)
+	s.config.Monitoring.Folders = []config.MonitoredFolderConfig{{Path: "/work"}}
+	handler := s.routes()
+
+	var daily statsView
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/stats/daily?days=7", &daily))
+	assert.Equal(t, db.StatsDay, daily.Bucket)
+	require.Len(t, daily.Buckets, 7)
+	assert.Equal(t, 2, daily.Buckets[6].Changes)
+	assert.Equal(t, int64(30), daily.Bytes)
+
+	since := time.Now().AddDate(0, 0, -30).Format("2006-01-02")
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/stats/daily?account=home&bucket=week&since="+since, &daily))
+	assert.Equal(t, db.StatsWeek, daily.Bucket)
+	assert.Equal(t, 1, daily.Changes)
+
+	var groups groupStatsView
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/stats/by-folder", &groups))
+	require.Len(t, groups.Groups, 2)
+	assert.Equal(t, "/home", groups.Groups[0].Name)
+	assert.Equal(t, "/work", groups.Groups[1].Name)
+	assert.Len(t, groups.Groups[0].Buckets, defaultHeatmapDays)
+
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/stats/by-extension?limit=1", &groups))
+	assert.Equal(t, "extension", groups.By)
+	require.Len(t, groups.Groups, 1)
+	assert.Equal(t, ".docx", groups.Groups[0].Name, "ties are ordered by name")
+
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/stats/by-author?account=work", &groups))
+	require.Len(t, groups.Groups, 1)
+	assert.Equal(t, "unknown", groups.Groups[0].Name)
+
+	for _, url := range []string{
+		"/api/stats/daily?bucket=fortnight",
+		"/api/stats/daily?since=yesterday",
+		"/api/stats/daily?since=2024-03-02&until=2024-03-01",
+		"/api/stats/daily?days=365&bucket=hour",
+		"/api/stats/by-author?limit=0",
+	} {
+		assert.Equal(t, http.StatusBadRequest, getJSON(t, handler, url, nil), url)
+	}
+}
+
+func TestBuildHeatmap(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	activity := []db.AuthorActivity{
+		{Author: "Alice", Day: start, Count: 2},
+		{Author: "Alice", Day: start.AddDate(0, 0, 2), Count: 5},
+		{Author: "Bob", Day: start.AddDate(0, 0, 1), Count: 1},
+		{Author: "Bob", Day: start.AddDate(0, 0, 10), Count: 9},
+	}
+
+	view := buildHeatmap(activity, start, 3)
+	assert.Equal(t, []string{"2024-01-01", "2024-01-02", "2024-01-03"}, view.Days)
+	assert.Equal(t, []authorHeatRow{
+		{Author: "Alice", Counts: []int{2, 0, 5}, Total: 7},
+		{Author: "Bob", Counts: []int{0, 1, 0}, Total: 1},
+	}, view.Authors)
+	assert.Equal(t, 5, view.Max)
+}
+
+// stubIngester records ingested changes
+type stubIngester struct {
+	changes []models.FileChange
+}
+
+func (i *stubIngester) IngestChanges(ctx context.Context, changes []models.FileChange) error {
+	i.changes = append(i.changes, changes...)
+	return nil
+}
+
+func TestServer_Events(t *testing.T) {
+	s := newTestServer(t)
+	s.config.Web.APIToken = "secret"
+	ingester := &stubIngester{}
+	s.ingester = ingester
+	handler := s.routes()
+
+	post := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/events", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	valid := `[{"path": "/external/data.csv", "modified": "2024-01-01T10:00:00Z", "size": 10}]`
+
+	assert.Equal(t, http.StatusUnauthorized, post("", valid).Code)
+	assert.Equal(t, http.StatusUnauthorized, post("wrong", valid).Code)
+	assert.Equal(t, http.StatusBadRequest, post("secret", `[{"path": "relative.csv", "modified": "2024-01-01T10:00:00Z"}]`).Code)
+	assert.Equal(t, http.StatusBadRequest, post("secret", `[{"path": "/a.csv", "modified": "2024-01-01T10:00:00Z", "unknown": 1}]`).Code)
+	assert.Empty(t, ingester.changes)
+
+	rec := post("secret", valid)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	require.Len(t, ingester.changes, 1)
+	assert.Equal(t, ".csv", ingester.changes[0].Extension)
+	assert.Equal(t, "/external", ingester.changes[0].Directory)
+
+	rec = post("secret", `{"path": "/external/single.txt", "modified": "2024-01-01T10:00:00Z"}`)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Len(t, ingester.changes, 2)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/events", nil)
+	getRec := httptest.NewRecorder()
+	handler.ServeHTTP(getRec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, getRec.Code)
+}
+
+func TestServer_EventsDisabledWithoutToken(t *testing.T) {
+	s := newTestServer(t)
+	s.ingester = &stubIngester{}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/events", strings.NewReader(`[]`))
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+// stubAccount returns fixed account details
+type stubAccount struct{}
+
+func (stubAccount) AccountInfo(ctx context.Context) (*models.AccountInfo, error) {
+	return &models.AccountInfo{Name: "Jane Doe", Email: "jane@example.com", Type: "business"}, nil
+}
+
+func TestServer_Account(t *testing.T) {
+	s := newTestServer(t)
+
+	assert.Equal(t, http.StatusServiceUnavailable, getJSON(t, s.routes(), "/api/account", nil))
+
+	s.account = stubAccount{}
+	var account models.AccountInfo
+	require.Equal(t, http.StatusOK, getJSON(t, s.routes(), "/api/account", &account))
+	assert.Equal(t, "Jane Doe", account.Name)
+
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Jane Doe &lt;jane@example.com&gt; (business)")
+}
+
+// stubBreaker is a circuit breaker that closes when reset
+type stubBreaker struct {
+	status dropbox.CircuitStatus
+}
+
+func (b *stubBreaker) CircuitStatus() (dropbox.CircuitStatus, bool) {
+	return b.status, true
+}
+
+func (b *stubBreaker) ResetCircuit() bool {
+	b.status.State, b.status.Failures = "closed", 0
+	return true
+}
+
+func TestServer_HealthStatus(t *testing.T) {
+	s := newTestServer(t)
+	lastFailure := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.breaker = &stubBreaker{status: dropbox.CircuitStatus{State: "half-open", Failures: 3, LastFailure: &lastFailure}}
+
+	var health healthResponse
+	require.Equal(t, http.StatusOK, getJSON(t, s.routes(), "/api/health", &health))
+	assert.True(t, health.Healthy)
+	assert.Equal(t, "half-open", health.CircuitBreaker)
+	require.NotNil(t, health.Circuit)
+	assert.Equal(t, 3, health.Circuit.Failures)
+	require.NotNil(t, health.Circuit.LastFailure)
+	assert.True(t, lastFailure.Equal(*health.Circuit.LastFailure))
+
+	s.SetState(lifecycle.StateFailed)
+	require.Equal(t, http.StatusOK, getJSON(t, s.routes(), "/api/health", &health))
+	assert.False(t, health.Healthy)
+	assert.NotEmpty(t, health.Error)
+}
+
+func TestServer_RequireToken(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.requireToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	serve := func(method, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Reads never need the token
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodGet, "").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, serve(http.MethodPost, "").Code)
+
+	s.config.Web.APIToken = "secret"
+	rec := serve(http.MethodPost, "wrong")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodDelete, "").Code)
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodPost, "secret").Code)
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodGet, "").Code)
+}
+
+func TestServer_CircuitReset(t *testing.T) {
+	s := newTestServer(t)
+	breaker := &stubBreaker{status: dropbox.CircuitStatus{State: "open", Failures: 5}}
+	s.breaker = breaker
+	handler := s.routes()
+
+	post := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/circuit-breaker/reset", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, post("").Code)
+
+	s.config.Web.APIToken = "secret"
+	assert.Equal(t, http.StatusUnauthorized, post("wrong").Code)
+	assert.Equal(t, "open", breaker.status.State)
+	assert.Equal(t, http.StatusMethodNotAllowed, getJSON(t, handler, "/api/circuit-breaker/reset", nil))
+
+	rec := post("secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var status dropbox.CircuitStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	assert.Equal(t, dropbox.CircuitStatus{State: "closed"}, status)
+}
+
+// stubQuota reports a fixed rate limit pause
+type stubQuota time.Time
+
+func (q stubQuota) QuotaPause() (time.Time, bool) {
+	return time.Time(q), !time.Time(q).IsZero()
+}
+
+func TestServer_HealthStatusQuotaPaused(t *testing.T) {
+	s := newTestServer(t)
+	resumeAt := time.Date(2024, 5, 1, 12, 15, 0, 0, time.UTC)
+
+	var health healthResponse
+	require.Equal(t, http.StatusOK, getJSON(t, s.routes(), "/api/health", &health))
+	assert.Empty(t, health.Quota)
+	assert.Nil(t, health.ResumeAt)
+
+	s.quota = stubQuota(resumeAt)
+	require.Equal(t, http.StatusOK, getJSON(t, s.routes(), "/api/health", &health))
+	assert.Equal(t, "quota-paused", health.Quota)
+	require.NotNil(t, health.ResumeAt)
+	assert.True(t, resumeAt.Equal(*health.ResumeAt))
+}
+
+// stubComponents reports fixed component health
+type stubComponents []health.Report
+
+func (c stubComponents) ComponentHealth(ctx context.Context) []health.Report {
+	return c
+}
+
+func TestServer_Probes(t *testing.T) {
+	s := newTestServer(t)
+	s.SetState(lifecycle.StateRunning)
+	lastPoll := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	components := stubComponents{
+		{Name: "scheduler", Status: health.StatusHealthy, State: "Running"},
+		{Name: "folder:/docs", Status: health.StatusHealthy, State: "Running", LastSuccess: &lastPoll},
+	}
+	s.components = components
+
+	var probe probeResponse
+	require.Equal(t, http.StatusOK, getJSON(t, s.routes(), "/healthz", &probe))
+	assert.Equal(t, "ok", probe.Status)
+	require.Equal(t, http.StatusOK, getJSON(t, s.routes(), "/readyz", &probe))
+	assert.Equal(t, "ready", probe.Status)
+	require.Len(t, probe.Components, 3)
+	assert.Equal(t, "web", probe.Components[0].Name)
+	require.NotNil(t, probe.Components[2].LastSuccess)
+	assert.True(t, lastPoll.Equal(*probe.Components[2].LastSuccess))
+
+	// A failing folder makes the monitor unready but still alive
+	components[1].Status, components[1].LastError = health.StatusUnhealthy, "failed to list changes"
+	assert.Equal(t, http.StatusOK, getJSON(t, s.routes(), "/healthz", nil))
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &probe))
+	assert.Equal(t, "not ready", probe.Status)
+	assert.Equal(t, "failed to list changes", probe.Components[2].LastError)
+
+	s.SetState(lifecycle.StateFailed)
+	assert.Equal(t, http.StatusServiceUnavailable, getJSON(t, s.routes(), "/healthz", nil))
+}
+
+// stubSubscriber hands the registered change handler to the test
+type stubSubscriber struct {
+	handlers chan core.ChangeHandler
+}
+
+func (s *stubSubscriber) Subscribe(handler core.ChangeHandler) func() {
+	s.handlers <- handler
+	return func() {}
+}
+
+func TestServer_ChangeStream(t *testing.T) {
+	s := newTestServer(t)
+
+	assert.Equal(t, http.StatusServiceUnavailable, getJSON(t, s.routes(), "/api/changes/stream", nil))
+
+	subscriber := &stubSubscriber{handlers: make(chan core.ChangeHandler, 1)}
+	s.subscriber = subscriber
+	server := httptest.NewServer(s.routes())
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/changes/stream?account=work", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	handler := <-subscriber.handlers
+	now := time.Now()
+	require.NoError(t, handler(ctx, []models.FileChange{
+		{Path: "/home/photo.jpg", Modified: now, AccountID: "home"},
+		{Path: "/work/plan.docx", Modified: now, AccountID: "work", Size: 42},
+	}))
+
+	scanner := bufio.NewScanner(resp.Body)
+	var lines []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "data: ") {
+			var change changeView
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &change))
+			assert.Equal(t, "/work/plan.docx", change.Path)
+			assert.Equal(t, int64(42), change.Size)
+			break
+		}
+		lines = append(lines, line)
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, []string{": connected", "", "event: change"}, lines)
+}
+
+// stubLimits returns fixed limit metrics
+type stubLimits struct{}
+
+func (stubLimits) LimitStats() limits.Stats {
+	return limits.Stats{DownloadsThrottled: 2, ReportsTruncated: 1}
+}
+
+func TestServer_Limits(t *testing.T) {
+	s := newTestServer(t)
+
+	assert.Equal(t, http.StatusServiceUnavailable, getJSON(t, s.routes(), "/api/limits", nil))
+
+	s.limits = stubLimits{}
+	var stats limits.Stats
+	require.Equal(t, http.StatusOK, getJSON(t, s.routes(), "/api/limits", &stats))
+	assert.Equal(t, int64(2), stats.DownloadsThrottled)
+	assert.Equal(t, int64(1), stats.ReportsTruncated)
+}
+
+func TestServer_Metrics(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	for _, name := range []string{
+		"dropbox_api_requests_total",
+		"dropbox_api_retries_total",
+		"dropbox_circuit_breaker_state",
+		"dropbox_changes_per_poll",
+		"report_generation_seconds",
+		"notify_emails_total",
+	} {
+		assert.Contains(t, rec.Body.String(), "# TYPE "+name+" ")
+	}
+}
+
+func TestServer_StatusAndFeatures(t *testing.T) {
+	s := newTestServer(t)
+	s.config.Web.APIToken = "secret"
+	flags, err := features.NewRegistry(map[string]bool{"llm_analysis": true})
+	require.NoError(t, err)
+	s.features = flags
+	s.webhook = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := s.routes()
+
+	var status statusResponse
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/status", &status))
+	require.Len(t, status.Features, 3)
+	assert.Equal(t, features.Status{
+		Name:        features.LLMAnalysis,
+		Description: "Language model analysis of changed files",
+		Enabled:     true,
+		Source:      features.SourceConfig,
+	}, status.Features[0])
+
+	post := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/features", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusUnauthorized, post("wrong", `{"name": "webhooks", "enabled": false}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post("secret", `{"name": "teleport", "enabled": true}`).Code)
+	assert.Equal(t, http.StatusOK, getJSON(t, handler, "/webhook", nil))
+
+	// Switching webhooks off hides the receiver until the override is cleared
+	require.Equal(t, http.StatusOK, post("secret", `{"name": "webhooks", "enabled": false}`).Code)
+	assert.Equal(t, http.StatusNotFound, getJSON(t, handler, "/webhook", nil))
+
+	rec := post("secret", `{"name": "webhooks", "enabled": null}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	assert.Equal(t, 2, status.FeaturesVersion)
+	assert.Equal(t, http.StatusOK, getJSON(t, handler, "/webhook", nil))
+}
+
+// memoryState is an in-memory StateManager
+type memoryState map[string]string
+
+func (m memoryState) GetString(key string) string       { return m[key] }
+func (m memoryState) SetString(key, value string) error { m[key] = value; return nil }
+
+func TestServer_Actions(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.routes()
+	assert.Equal(t, http.StatusServiceUnavailable, getJSON(t, handler, "/actions?token=x", nil))
+
+	links, err := actions.NewService(actions.Config{
+		Secret:  "0123456789abcdef",
+		BaseURL: "https://monitor.example.com",
+	}, memoryState{})
+	require.NoError(t, err)
+	s.actions = links
+	token := links.Sign(actions.MuteFolder, "/docs")
+
+	// Opening the link only asks for confirmation
+	req := httptest.NewRequest(http.MethodGet, "/actions?token="+url.QueryEscape(token), nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Mute alerts for /docs for 24h?")
+	assert.Contains(t, rec.Body.String(), `method="post"`)
+	assert.False(t, links.Muted("/docs"))
+
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, handler, "/actions?token=forged", nil))
+
+	post := func(token string) *httptest.ResponseRecorder {
+		form := url.Values{"token": {token}}
+		req := httptest.NewRequest(http.MethodPost, "/actions", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	rec = post(token)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Done: Mute alerts for /docs for 24h.")
+	assert.True(t, links.Muted("/docs"))
+
+	assert.Equal(t, http.StatusBadRequest, post(token+"x").Code)
+
+	s.catalog = i18n.Lookup("af")
+	rec = post(token + "x")
+	assert.Contains(t, rec.Body.String(), `<html lang="af">`)
+	assert.Contains(t, rec.Body.String(), "Hierdie skakel is nie geldig nie.")
+	rec = post(links.Sign(actions.Acknowledge, "/docs"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Klaar: Erken die waarskuwing vir /docs.")
+}
+
+type fakeSearcher struct {
+	query string
+	k     int
+}
+
+func (f *fakeSearcher) Search(ctx context.Context, query string, k int) ([]db.SimilarDocument, error) {
+	f.query, f.k = query, k
+	if strings.TrimSpace(query) == "" {
+		return nil, embeddings.ErrEmptyQuery
+	}
+	return []db.SimilarDocument{{Path: "/docs/budget.md", Score: 0.9}}, nil
+}
+
+func TestServer_Search(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.routes()
+
+	// The endpoint is hidden until vector search is switched on
+	assert.Equal(t, http.StatusNotFound, getJSON(t, handler, "/api/search?q=budget", nil))
+
+	flags, err := features.NewRegistry(map[string]bool{"vector_search": true})
+	require.NoError(t, err)
+	s.features = flags
+	assert.Equal(t, http.StatusServiceUnavailable, getJSON(t, handler, "/api/search?q=budget", nil))
+
+	searcher := &fakeSearcher{}
+	s.search = searcher
+	var results []db.SimilarDocument
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/search?q=travel+budget&k=3", &results))
+	assert.Equal(t, []db.SimilarDocument{{Path: "/docs/budget.md", Score: 0.9}}, results)
+	assert.Equal(t, "travel budget", searcher.query)
+	assert.Equal(t, 3, searcher.k)
+
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, handler, "/api/search?q=", nil))
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, handler, "/api/search?q=budget&k=0", nil))
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, handler, "/api/search?q=budget&k=1000", nil))
+}
+
+func TestServer_TextSearch(t *testing.T) {
+	s := newTestServer(t)
+	require.NoError(t, s.db.SaveDocumentText(context.Background(), "/docs/budget.md", "The travel budget for March"))
+	handler := s.routes()
+
+	var results []db.TextMatch
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/search/text?q=travel+budget", &results))
+	require.Len(t, results, 1)
+	assert.Equal(t, "/docs/budget.md", results[0].Path)
+	assert.Contains(t, results[0].Snippet, "[budget]")
+
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/search/text?q=holiday&k=5", &results))
+	assert.Empty(t, results)
+
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, handler, "/api/search/text?q=+", nil))
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, handler, "/api/search/text?q=budget&k=1000", nil))
+}
+
+func TestServer_Tree(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	monday := time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)
+	for _, change := range []models.FileChange{
+		{Path: "/work/plans/q1.xlsx", Modified: monday, Size: 100, AccountID: "work"},
+		{Path: "/work/plans/q2.xlsx", Modified: monday, Size: 50, AccountID: "work"},
+		{Path: "/work/notes.txt", Modified: monday, Size: 5, AccountID: "work"},
+		{Path: "/work/old.txt", Modified: monday, Size: 7, AccountID: "work"},
+		{Path: "/work/old.txt", Modified: monday.Add(time.Hour), IsDeleted: true, AccountID: "work"},
+	} {
+		require.NoError(t, s.db.SaveFileChange(ctx, db.NewFileChangeFromModel(change)))
+	}
+	handler := s.routes()
+
+	var tree treeView
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/tree?path=/work&at=2024-03-04T12:30:00Z", &tree))
+	assert.Equal(t, "/work", tree.Path)
+	assert.Equal(t, []treeFolderView{{Path: "/work/plans", Name: "plans", Files: 2, Size: 150}}, tree.Folders)
+	require.Len(t, tree.Files, 2)
+	assert.Equal(t, "notes.txt", tree.Files[0].Name)
+	assert.Equal(t, "old.txt", tree.Files[1].Name)
+	assert.Equal(t, 4, tree.TotalFiles)
+	assert.Equal(t, int64(162), tree.TotalBytes)
+
+	// After the deletion the file is gone unless deleted files are asked for
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/tree?path=/work&at=2024-03-04T14:00:00Z", &tree))
+	require.Len(t, tree.Files, 1)
+	assert.Equal(t, 3, tree.TotalFiles)
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/tree?path=/work&at=2024-03-04T14:00:00Z&include_deleted=true", &tree))
+	require.Len(t, tree.Files, 2)
+	assert.True(t, tree.Files[1].Deleted)
+
+	// Before anything changed the folder is empty
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/tree?path=/work&at=2024-03-01", &tree))
+	assert.Empty(t, tree.Folders)
+	assert.Empty(t, tree.Files)
+
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/tree?account=home", &tree))
+	require.Len(t, tree.Folders, 1)
+	assert.Equal(t, "/home", tree.Folders[0].Path)
+
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, handler, "/api/tree?at=last+monday", nil))
+}
+
+func TestServer_Files(t *testing.T) {
+	s := newTestServer(t)
+	day := time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, s.db.SaveInventory(context.Background(), []db.InventoryFile{
+		{Path: "/work/plans/q1.xlsx", Size: 100, Rev: "r1", ServerModified: day, AccountID: "work", SyncedAt: day},
+		{Path: "/work/notes.txt", Size: 5, ServerModified: day, AccountID: "work", SyncedAt: day},
+		{Path: "/home/photo.jpg", Size: 20, ServerModified: day, AccountID: "home", SyncedAt: day},
+	}))
+	handler := s.routes()
+
+	var files filesView
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/files?path=/work", &files))
+	assert.Equal(t, "/work", files.Path)
+	require.Len(t, files.Files, 2)
+	assert.Equal(t, "/work/notes.txt", files.Files[0].Path)
+	assert.Equal(t, "r1", files.Files[1].Rev)
+	assert.Equal(t, 2, files.TotalFiles)
+	assert.Equal(t, int64(105), files.TotalBytes)
+
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/files?account=home", &files))
+	require.Len(t, files.Files, 1)
+	assert.Equal(t, "/home/photo.jpg", files.Files[0].Path)
+
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/files?path=/empty", &files))
+	assert.Empty(t, files.Files)
+}
+
+func TestParseAsOf(t *testing.T) {
+	now := time.Now()
+	at, err := parseAsOf("", now)
+	require.NoError(t, err)
+	assert.Equal(t, now, at)
+
+	at, err = parseAsOf("2024-03-04T09:30", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 4, 9, 30, 0, 0, time.Local), at)
+
+	at, err = parseAsOf("2024-03-04", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 4, 23, 59, 59, 999999999, time.Local), at)
+}
+
+func TestServer_ChangeUpdates(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.routes()
+
+	var updates changeUpdatesView
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/changes/updates?account=work", &updates))
+	require.Len(t, updates.Changes, 1)
+	assert.Equal(t, "/work/report.docx", updates.Changes[0].Path)
+	assert.NotZero(t, updates.Changes[0].ID)
+	assert.False(t, updates.More)
+	cursor := updates.Cursor
+
+	// Nothing new yet
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/changes/updates?cursor="+cursor, &updates))
+	assert.Empty(t, updates.Changes)
+	assert.Equal(t, cursor, updates.Cursor)
+
+	for i := 0; i < 3; i++ {
+		change := models.FileChange{Path: fmt.Sprintf("/work/new%d.txt", i), Modified: time.Now(), AccountID: "work"}
+		require.NoError(t, s.db.SaveFileChange(context.Background(), db.NewFileChangeFromModel(change)))
+	}
+
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/changes/updates?limit=2&cursor="+cursor, &updates))
+	require.Len(t, updates.Changes, 2)
+	assert.Equal(t, "/work/new0.txt", updates.Changes[0].Path)
+	assert.True(t, updates.More)
+
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/changes/updates?limit=2&cursor="+updates.Cursor, &updates))
+	require.Len(t, updates.Changes, 1)
+	assert.Equal(t, "/work/new2.txt", updates.Changes[0].Path)
+	assert.False(t, updates.More)
+
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, handler, "/api/changes/updates?cursor=abc", nil))
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, handler, "/api/changes/updates?limit=0", nil))
+}
+
+func TestServer_EmailRecipients(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.routes()
+	assert.Equal(t, http.StatusServiceUnavailable, getJSON(t, handler, "/api/email/recipients", nil))
+
+	ctx := context.Background()
+	now := time.Now()
+	require.NoError(t, s.db.RecordEmailDelivery(ctx, "ops@test.com", now))
+	_, err := s.db.RecordEmailFailure(ctx, "gone@test.com", true, "550 No such user", now)
+	require.NoError(t, err)
+	require.NoError(t, s.db.SetEmailRecipientDisabled(ctx, "gone@test.com", true, now))
+
+	s.config.Web.APIToken = "secret"
+	s.deliveries = notify.NewBounces(s.db, 3)
+
+	var stats notify.DeliveryStats
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/email/recipients", &stats))
+	assert.Equal(t, 1, stats.Delivered)
+	assert.Equal(t, 1, stats.HardBounces)
+	assert.Equal(t, 1, stats.Disabled)
+	require.Len(t, stats.Recipients, 2)
+
+	post := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/email/recipients", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusUnauthorized, post("wrong", `{"address": "gone@test.com"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post("secret", `{}`).Code)
+
+	rec := post("secret", `{"address": "gone@test.com"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	assert.Zero(t, stats.Disabled)
+}
+
+func TestServer_DashboardLanguage(t *testing.T) {
+	server := newTestServer(t)
+	render := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		server.routes().ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.String()
+	}
+
+	page := render()
+	assert.Contains(t, page, `<html lang="en">`)
+	assert.Contains(t, page, "<h2>Recent Changes</h2>")
+
+	server.catalog = i18n.Lookup("nl")
+	page = render()
+	assert.Contains(t, page, `<html lang="nl">`)
+	assert.Contains(t, page, "<h2>Recente wijzigingen</h2>")
+	assert.Contains(t, page, `"ui.live":"Live feed: %s"`)
+	assert.Contains(t, page, `"ui.tree_summary":"%s op %s: %d bestanden, %s MB"`)
+}
+
+type stubResender []int64
+
+func (s *stubResender) ResendReport(ctx context.Context, id int64) error {
+	*s = append(*s, id)
+	return nil
+}
+
+func TestServer_Reports(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.routes()
+
+	report := models.NewReport(models.MarkdownReport)
+	report.Title = "Weekly <digest>"
+	report.Metadata["content"] = "# Changes"
+	archived := models.NewArchivedReport(report, []string{"ops@test.com"})
+	archived.Status = models.ReportFailed
+	archived.LastError = "smtp down"
+	id, err := s.db.ArchiveReport(context.Background(), archived)
+	require.NoError(t, err)
+
+	var reports []models.ArchivedReport
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/reports", &reports))
+	require.Len(t, reports, 1)
+	assert.Equal(t, id, reports[0].ID)
+	assert.Equal(t, []string{"ops@test.com"}, reports[0].Recipients)
+	assert.Empty(t, reports[0].Content)
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, handler, "/api/reports?limit=0", nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Weekly &lt;digest&gt;")
+	assert.Contains(t, rec.Body.String(), "smtp down")
+	assert.Contains(t, rec.Body.String(), fmt.Sprintf("api/reports/download?id=%d", id))
+
+	req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/reports/download?id=%d", id), nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "# Changes", rec.Body.String())
+	assert.Equal(t, "text/markdown; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), `attachment; filename="report-`)
+	assert.Equal(t, http.StatusNotFound, getJSON(t, handler, "/api/reports/download?id=99", nil))
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, handler, "/api/reports/download?id=x", nil))
+
+	resender := &stubResender{}
+	s.resender = resender
+	s.config.Web.APIToken = "secret"
+	post := func(token, url string) int {
+		req := httptest.NewRequest(http.MethodPost, url, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusUnauthorized, post("wrong", fmt.Sprintf("/api/reports/resend?id=%d", id)))
+	assert.Equal(t, http.StatusNotFound, post("secret", "/api/reports/resend?id=99"))
+	assert.Equal(t, http.StatusNoContent, post("secret", fmt.Sprintf("/api/reports/resend?id=%d", id)))
+	assert.Equal(t, []int64{id}, []int64(*resender))
+	assert.Equal(t, http.StatusMethodNotAllowed, getJSON(t, handler, "/api/reports/resend?id=1", nil))
+}
+
+func TestServer_Notifications(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.routes()
+
+	ctx := context.Background()
+	now := time.Now()
+	for _, n := range []models.QueuedNotification{
+		{Channel: "email", Message: "dead", CreatedAt: now.Add(-time.Hour), Attempts: 10, LastError: "smtp down", DeadLettered: true},
+		{Channel: "email", Message: "waiting", CreatedAt: now, Attempts: 1, NextAttemptAt: now.Add(time.Minute)},
+	} {
+		require.NoError(t, s.db.EnqueueNotification(ctx, n))
+	}
+
+	var queue notificationQueueView
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/notifications?channel=email", &queue))
+	require.Len(t, queue.Pending, 1)
+	assert.Equal(t, "waiting", queue.Pending[0].Message)
+	require.Len(t, queue.DeadLettered, 1)
+	assert.Equal(t, "smtp down", queue.DeadLettered[0].LastError)
+	waiting, dead := queue.Pending[0].ID, queue.DeadLettered[0].ID
+
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/notifications?channel=slack", &queue))
+	assert.Empty(t, queue.Pending)
+	assert.Empty(t, queue.DeadLettered)
+
+	post := func(token, url string) int {
+		req := httptest.NewRequest(http.MethodPost, url, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, post("", fmt.Sprintf("/api/notifications/requeue?id=%d", dead)))
+
+	s.config.Web.APIToken = "secret"
+	assert.Equal(t, http.StatusUnauthorized, post("wrong", fmt.Sprintf("/api/notifications/requeue?id=%d", dead)))
+	assert.Equal(t, http.StatusNotFound, post("secret", fmt.Sprintf("/api/notifications/requeue?id=%d", waiting)))
+	assert.Equal(t, http.StatusNoContent, post("secret", fmt.Sprintf("/api/notifications/requeue?id=%d", dead)))
+
+	require.Equal(t, http.StatusOK, getJSON(t, handler, "/api/notifications", &queue))
+	assert.Len(t, queue.Pending, 2)
+	assert.Empty(t, queue.DeadLettered)
+}
@@ -0,0 +1,137 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+)
+
+// statsView is the JSON representation of change statistics over time
+type statsView struct {
+	Since  time.Time      `json:"since"`
+	Until  time.Time      `json:"until"`
+	Bucket db.StatsBucket `json:"bucket"`
+	db.StatsSeries
+}
+
+// groupStatsView is the JSON representation of change statistics per
+// folder, extension or author
+type groupStatsView struct {
+	Since  time.Time       `json:"since"`
+	Until  time.Time       `json:"until"`
+	Bucket db.StatsBucket  `json:"bucket"`
+	By     string          `json:"by"`
+	Groups []db.GroupStats `json:"groups"`
+}
+
+// handleStatsDaily returns change statistics per bucket, a day unless the
+// "bucket" query parameter names another, for the account in the "account"
+// query parameter. The window is the last "days" days, or runs from
+// "since" to "until".
+func (s *Server) handleStatsDaily(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeError(w, http.StatusServiceUnavailable, "database is not available")
+		return
+	}
+
+	query, err := s.statsQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	series, err := s.db.GetStats(r.Context(), query)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	view := statsView{Since: query.Since, Until: query.Until, Bucket: query.Bucket, StatsSeries: series}
+	writeJSON(w, http.StatusOK, view)
+}
+
+// handleStatsBy returns a handler for change statistics per group of the
+// dimension, taking the parameters of handleStatsDaily and "limit"
+func (s *Server) handleStatsBy(dimension db.StatsDimension) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.db == nil {
+			writeError(w, http.StatusServiceUnavailable, "database is not available")
+			return
+		}
+
+		query, err := s.statsQuery(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		groups, err := s.db.GetStatsBy(r.Context(), query, dimension)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		writeJSON(w, http.StatusOK, groupStatsView{
+			Since:  query.Since,
+			Until:  query.Until,
+			Bucket: query.Bucket,
+			By:     string(dimension),
+			Groups: groups,
+		})
+	}
+}
+
+// statsQuery parses the account, window, bucket and limit of a stats request.
+// Changes are grouped into the monitored folders by folder.
+func (s *Server) statsQuery(r *http.Request) (db.StatsQuery, error) {
+	accountID, err := s.accountFromRequest(r)
+	if err != nil {
+		return db.StatsQuery{}, err
+	}
+
+	params := r.URL.Query()
+	bucket, err := db.ParseStatsBucket(params.Get("bucket"))
+	if err != nil {
+		return db.StatsQuery{}, err
+	}
+	query := db.StatsQuery{AccountID: accountID, Bucket: bucket, Until: time.Now()}
+
+	if value := params.Get("since"); value != "" {
+		if query.Since, err = parseStatsTime(value); err != nil {
+			return db.StatsQuery{}, fmt.Errorf("invalid since value %q", value)
+		}
+		if value := params.Get("until"); value != "" {
+			if query.Until, err = parseStatsTime(value); err != nil {
+				return db.StatsQuery{}, fmt.Errorf("invalid until value %q", value)
+			}
+		}
+	} else if query.Since, _, err = activityWindow(r); err != nil {
+		return db.StatsQuery{}, err
+	}
+
+	if value := params.Get("limit"); value != "" {
+		query.Limit, err = strconv.Atoi(value)
+		if err != nil || query.Limit <= 0 {
+			return db.StatsQuery{}, fmt.Errorf("invalid limit value %q", value)
+		}
+	}
+
+	if s.config != nil {
+		for _, folder := range s.config.Monitoring.GetFolders() {
+			query.Folders = append(query.Folders, folder.Path)
+		}
+	}
+	return query, query.Validate()
+}
+
+// parseStatsTime parses an RFC 3339 time or a local date meaning the start
+// of that day
+func parseStatsTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Local(), nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
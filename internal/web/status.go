@@ -0,0 +1,206 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/dropbox"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/features"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/health"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/lifecycle"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/redact"
+)
+
+// statusResponse is the body of /status
+type statusResponse struct {
+	State           string            `json:"state"`
+	FeaturesVersion int               `json:"features_version"`
+	Features        []features.Status `json:"features"`
+}
+
+// healthResponse is the body of /api/health
+type healthResponse struct {
+	State          string `json:"state"`
+	Healthy        bool   `json:"healthy"`
+	Error          string `json:"error,omitempty"`
+	CircuitBreaker string `json:"circuit_breaker,omitempty"`
+	// Circuit details the circuit breaker with its failure count and the
+	// time of the latest failure
+	Circuit *dropbox.CircuitStatus `json:"circuit,omitempty"`
+	// Quota is "quota-paused" while Dropbox requests are suspended by the
+	// API rate limit; ResumeAt is when they resume
+	Quota    string     `json:"quota,omitempty"`
+	ResumeAt *time.Time `json:"resume_at,omitempty"`
+}
+
+// circuitStater reports and resets the Dropbox client's circuit breaker; both
+// report false when the client has none
+type circuitStater interface {
+	CircuitStatus() (dropbox.CircuitStatus, bool)
+	ResetCircuit() bool
+}
+
+// quotaPauser reports whether Dropbox requests are paused by the rate limit
+type quotaPauser interface {
+	QuotaPause() (time.Time, bool)
+}
+
+// componentHealther reports the health of each part of the monitor
+type componentHealther interface {
+	ComponentHealth(ctx context.Context) []health.Report
+}
+
+// probeResponse is the body of /healthz and /readyz
+type probeResponse struct {
+	Status     string          `json:"status"`
+	Components []health.Report `json:"components"`
+}
+
+// featureOverride is the body of a /api/features request; a null Enabled
+// clears the override
+type featureOverride struct {
+	Name    features.Flag `json:"name"`
+	Enabled *bool         `json:"enabled"`
+}
+
+// handleStatus reports the server state and the feature flags
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, statusResponse{
+		State:           s.State().String(),
+		FeaturesVersion: s.features.Version(),
+		Features:        s.features.Status(),
+	})
+}
+
+// handleHealthStatus reports the component health, the circuit breaker state
+// and any rate limit pause for the dashboard
+func (s *Server) handleHealthStatus(w http.ResponseWriter, r *http.Request) {
+	response := healthResponse{State: s.State().String(), Healthy: true}
+	if err := s.Health(r.Context()); err != nil {
+		response.Healthy = false
+		response.Error = err.Error()
+	}
+	if s.breaker != nil {
+		if status, ok := s.breaker.CircuitStatus(); ok {
+			response.CircuitBreaker = status.State
+			response.Circuit = &status
+		}
+	}
+	if s.quota != nil {
+		if resumeAt, paused := s.quota.QuotaPause(); paused {
+			response.Quota = "quota-paused"
+			response.ResumeAt = &resumeAt
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleCircuitReset closes the Dropbox client's circuit breaker after a
+// known outage and returns its status; it requires the API token
+func (s *Server) handleCircuitReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.breaker == nil {
+		writeError(w, http.StatusServiceUnavailable, "the Dropbox client has no circuit breaker")
+		return
+	}
+
+	before, _ := s.breaker.CircuitStatus()
+	if !s.breaker.ResetCircuit() {
+		writeError(w, http.StatusServiceUnavailable, "the Dropbox client has no circuit breaker")
+		return
+	}
+	s.log().Info("Circuit breaker reset", "previous_state", before.State, "failures", before.Failures)
+
+	status, _ := s.breaker.CircuitStatus()
+	writeJSON(w, http.StatusOK, status)
+}
+
+// componentReports returns the health of the web server and of each
+// component of the container
+func (s *Server) componentReports(ctx context.Context) []health.Report {
+	reports := []health.Report{health.ComponentReport("web", s, nil)}
+	if s.components != nil {
+		reports = append(reports, s.components.ComponentHealth(ctx)...)
+	}
+	for i := range reports {
+		reports[i].LastError = redact.String(reports[i].LastError)
+	}
+	return reports
+}
+
+// handleLiveness answers liveness probes: it fails only once a component has
+// failed for good, so a Dropbox outage does not get the monitor restarted
+func (s *Server) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	response := probeResponse{Status: "ok", Components: s.componentReports(r.Context())}
+	code := http.StatusOK
+	for _, report := range response.Components {
+		if report.State == lifecycle.StateFailed.String() {
+			response.Status, code = "failed", http.StatusServiceUnavailable
+			break
+		}
+	}
+	writeJSON(w, code, response)
+}
+
+// handleReadiness answers readiness probes: it succeeds only while every
+// component is healthy
+func (s *Server) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	response := probeResponse{Status: "ready", Components: s.componentReports(r.Context())}
+	code := http.StatusOK
+	if !health.Ready(response.Components) {
+		response.Status, code = "not ready", http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, response)
+}
+
+// handleFeatures overrides a feature flag at runtime. Requests must carry the
+// configured API token as a bearer token.
+func (s *Server) handleFeatures(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if s.features == nil {
+		writeError(w, http.StatusServiceUnavailable, "feature overrides are not enabled")
+		return
+	}
+
+	var override featureOverride
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&override); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var err error
+	if override.Enabled == nil {
+		err = s.features.Clear(override.Name)
+	} else {
+		err = s.features.Set(override.Name, *override.Enabled)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.handleStatus(w, r)
+}
+
+// gated serves the handler only while the feature is enabled
+func (s *Server) gated(flag features.Flag, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.features.Enabled(flag) {
+			http.NotFound(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,147 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/db"
+)
+
+// treeView is the JSON representation of one folder as of a point in time
+type treeView struct {
+	Path       string           `json:"path"`
+	AsOf       time.Time        `json:"as_of"`
+	Folders    []treeFolderView `json:"folders"`
+	Files      []treeFileView   `json:"files"`
+	TotalFiles int              `json:"total_files"`
+	TotalBytes int64            `json:"total_bytes"`
+}
+
+// treeFolderView summarizes the files that existed in a subfolder
+type treeFolderView struct {
+	Path  string `json:"path"`
+	Name  string `json:"name"`
+	Files int    `json:"files"`
+	Size  int64  `json:"size"`
+}
+
+// treeFileView is a file directly in the folder
+type treeFileView struct {
+	Path       string    `json:"path"`
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+	ModifiedBy string    `json:"modified_by,omitempty"`
+	Deleted    bool      `json:"deleted,omitempty"`
+}
+
+// handleTree returns the folder in the "path" query parameter as it was at
+// the time in the "at" parameter, reconstructed from the stored change
+// history. Files deleted by then are listed only with "include_deleted".
+func (s *Server) handleTree(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeError(w, http.StatusServiceUnavailable, "database is not available")
+		return
+	}
+
+	accountID, err := s.accountFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	asOf, err := parseAsOf(r.URL.Query().Get("at"), time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	root := path.Clean("/" + r.URL.Query().Get("path"))
+	entries, err := s.db.GetTreeAsOf(r.Context(), accountID, root, asOf)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	includeDeleted := r.URL.Query().Get("include_deleted") == "true"
+	writeJSON(w, http.StatusOK, buildTree(entries, root, asOf, includeDeleted))
+}
+
+// parseAsOf parses the "at" query parameter: an RFC 3339 time, a local
+// date and time as sent by datetime-local inputs, or a local date meaning
+// the end of that day. An empty value means now.
+func parseAsOf(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return now, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid at value %q", value)
+}
+
+// buildTree lists the files directly in root and totals the files that
+// existed in each of its subfolders
+func buildTree(entries []db.TreeEntry, root string, asOf time.Time, includeDeleted bool) treeView {
+	view := treeView{
+		Path:    root,
+		AsOf:    asOf,
+		Folders: []treeFolderView{},
+		Files:   []treeFileView{},
+	}
+
+	prefix := strings.TrimSuffix(root, "/") + "/"
+	folders := make(map[string]*treeFolderView)
+	for _, entry := range entries {
+		if !entry.Deleted {
+			view.TotalFiles++
+			view.TotalBytes += entry.Size
+		}
+
+		// A root that is itself a file is listed as that file
+		rel := strings.TrimPrefix(entry.Path, prefix)
+		if name, _, nested := strings.Cut(rel, "/"); nested && entry.Path != root {
+			if entry.Deleted {
+				continue
+			}
+			folder, ok := folders[name]
+			if !ok {
+				folder = &treeFolderView{Path: prefix + name, Name: name}
+				folders[name] = folder
+			}
+			folder.Files++
+			folder.Size += entry.Size
+			continue
+		}
+
+		if entry.Deleted && !includeDeleted {
+			continue
+		}
+		view.Files = append(view.Files, treeFileView{
+			Path:       entry.Path,
+			Name:       path.Base(entry.Path),
+			Size:       entry.Size,
+			ModifiedAt: entry.ModifiedAt,
+			ModifiedBy: entry.ModifiedBy,
+			Deleted:    entry.Deleted,
+		})
+	}
+
+	for _, folder := range folders {
+		view.Folders = append(view.Folders, *folder)
+	}
+	sort.Slice(view.Folders, func(i, j int) bool {
+		return view.Folders[i].Name < view.Folders[j].Name
+	})
+	return view
+}
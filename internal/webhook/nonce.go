@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"sync"
+	"time"
+)
+
+// maxNonces bounds the memory used by the replay cache
+const maxNonces = 10000
+
+// nonceCache remembers recently seen notification nonces for a fixed window
+type nonceCache struct {
+	mu     sync.Mutex
+	window time.Duration
+	now    func() time.Time
+	seen   map[string]time.Time
+}
+
+// newNonceCache creates a cache that remembers nonces for window
+func newNonceCache(window time.Duration) *nonceCache {
+	return &nonceCache{
+		window: window,
+		now:    time.Now,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// checkAndStore records the nonce and reports whether it was already seen
+// within the window, returning how long until that window ends
+func (c *nonceCache) checkAndStore(nonce string) (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.pruneLocked(now)
+
+	if seenAt, ok := c.seen[nonce]; ok && now.Sub(seenAt) < c.window {
+		return c.window - now.Sub(seenAt), true
+	}
+
+	if len(c.seen) >= maxNonces {
+		c.evictOldestLocked()
+	}
+	c.seen[nonce] = now
+	return 0, false
+}
+
+// pruneLocked drops nonces older than the window; c.mu must be held
+func (c *nonceCache) pruneLocked(now time.Time) {
+	for nonce, seenAt := range c.seen {
+		if now.Sub(seenAt) >= c.window {
+			delete(c.seen, nonce)
+		}
+	}
+}
+
+// evictOldestLocked drops the oldest nonce; c.mu must be held
+func (c *nonceCache) evictOldestLocked() {
+	var oldest string
+	var oldestAt time.Time
+	for nonce, seenAt := range c.seen {
+		if oldest == "" || seenAt.Before(oldestAt) {
+			oldest, oldestAt = nonce, seenAt
+		}
+	}
+	delete(c.seen, oldest)
+}
@@ -0,0 +1,249 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/logging"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/safego"
+)
+
+const (
+	// SignatureHeader is the header Dropbox uses to sign webhook requests
+	SignatureHeader = "X-Dropbox-Signature"
+
+	// maxBodySize limits the size of notification bodies we are willing to read
+	maxBodySize = 1 << 20
+
+	// defaultTriggerTimeout bounds the time spent processing a single notification
+	defaultTriggerTimeout = 5 * time.Minute
+
+	// DefaultReplayWindow is how long a notification signature is remembered
+	DefaultReplayWindow = time.Minute
+)
+
+// Trigger starts change processing after Dropbox reports new changes
+type Trigger interface {
+	TriggerCheck(ctx context.Context) error
+}
+
+// Notification is the body Dropbox posts when files change
+type Notification struct {
+	ListFolder struct {
+		Accounts []string `json:"accounts"`
+	} `json:"list_folder"`
+	Delta struct {
+		Users []int64 `json:"users"`
+	} `json:"delta"`
+}
+
+// Handler receives Dropbox webhook requests
+type Handler struct {
+	secrets [][]byte
+	trigger Trigger
+	timeout time.Duration
+	nonces  *nonceCache
+	logger  *slog.Logger
+	// running is set while a check triggered by a notification runs, and
+	// pending when another notification arrived during it; deferred is set
+	// while a check for replayed notifications waits for its window to end
+	mu       sync.Mutex
+	running  bool
+	pending  bool
+	deferred bool
+}
+
+// NewHandler creates a webhook handler that verifies requests against any of
+// the app secrets, so secrets can be rotated without downtime, and feeds
+// notifications to the trigger. Notifications whose signature was already
+// seen within replayWindow are treated as possible replays and checked at
+// most once more when the window ends; a zero window uses
+// DefaultReplayWindow.
+func NewHandler(secrets []string, replayWindow time.Duration, trigger Trigger) (*Handler, error) {
+	if trigger == nil {
+		return nil, fmt.Errorf("trigger cannot be nil")
+	}
+	if replayWindow < 0 {
+		return nil, fmt.Errorf("replay window cannot be negative")
+	}
+	if replayWindow == 0 {
+		replayWindow = DefaultReplayWindow
+	}
+
+	keys := make([][]byte, 0, len(secrets))
+	for _, secret := range secrets {
+		if secret != "" {
+			keys = append(keys, []byte(secret))
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one app secret is required")
+	}
+
+	return &Handler{
+		secrets: keys,
+		trigger: trigger,
+		timeout: defaultTriggerTimeout,
+		nonces:  newNonceCache(replayWindow),
+		logger:  logging.Component(nil, "webhook"),
+	}, nil
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.handleChallenge(w, r)
+	case http.MethodPost:
+		h.handleNotification(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleChallenge echoes the verification challenge Dropbox sends when the
+// webhook URI is registered
+func (h *Handler) handleChallenge(w http.ResponseWriter, r *http.Request) {
+	challenge := r.URL.Query().Get("challenge")
+	if challenge == "" {
+		http.Error(w, "missing challenge", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write([]byte(challenge))
+}
+
+// handleNotification verifies and dispatches a change notification. Dropbox
+// expects a response within ten seconds, so processing happens asynchronously.
+func (h *Handler) handleNotification(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	signature := strings.ToLower(r.Header.Get(SignatureHeader))
+	if !h.verify(body, signature) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+
+	var notification Notification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		http.Error(w, "invalid notification body", http.StatusBadRequest)
+		return
+	}
+
+	// Dropbox notifications carry no timestamp, so the signature doubles as a
+	// nonce. Dropbox also sends the same body for every change to an account,
+	// so a repeated signature may be a genuine change as well as a replay: it
+	// is acknowledged, and all repeats within the window are folded into one
+	// check when it ends. Replays cost at most one extra check per window and
+	// no change is missed.
+	if wait, replay := h.nonces.checkAndStore(signature); replay {
+		h.deferCheck(notification, wait)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	h.schedule(notification)
+	w.WriteHeader(http.StatusOK)
+}
+
+// deferCheck schedules a check for a repeated notification after wait,
+// unless one is already waiting
+func (h *Handler) deferCheck(notification Notification, wait time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.deferred {
+		return
+	}
+	h.deferred = true
+	time.AfterFunc(wait, func() {
+		h.mu.Lock()
+		h.deferred = false
+		h.mu.Unlock()
+		h.schedule(notification)
+	})
+}
+
+// schedule runs a check for the notification; notifications arriving while
+// a check runs are coalesced into one more check once it finishes, so no
+// change is missed.
+func (h *Handler) schedule(notification Notification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.running {
+		h.pending = true
+		return
+	}
+	h.running = true
+	safego.Go(nil, "webhook dispatch", func() {
+		h.dispatch(notification)
+		for h.next() {
+			h.dispatch(notification)
+		}
+	})
+}
+
+// next reports whether a notification arrived during the last check,
+// clearing it; otherwise the checks are done
+func (h *Handler) next() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.pending {
+		h.pending = false
+		return true
+	}
+	h.running = false
+	return false
+}
+
+// verify reports whether the signature matches the body under any configured secret
+func (h *Handler) verify(body []byte, signature string) bool {
+	for _, secret := range h.secrets {
+		if VerifySignature(body, signature, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatch runs the trigger for a verified notification; a panicking check
+// is reported as an error so later notifications are still processed
+func (h *Handler) dispatch(notification Notification) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	h.logger.Info("Received Dropbox webhook", "accounts", len(notification.ListFolder.Accounts))
+	err := safego.Call(nil, "webhook dispatch", func() error { return h.trigger.TriggerCheck(ctx) })
+	if err != nil {
+		h.logger.Error("Error processing webhook notification", "error", err)
+	}
+}
+
+// VerifySignature reports whether signature is the hex-encoded HMAC-SHA256 of
+// body keyed with secret
+func VerifySignature(body []byte, signature string, secret []byte) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
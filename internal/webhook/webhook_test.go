@@ -0,0 +1,256 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "app-secret"
+
+// mockTrigger records trigger calls on a channel
+type mockTrigger struct {
+	calls chan struct{}
+}
+
+func newMockTrigger() *mockTrigger {
+	return &mockTrigger{calls: make(chan struct{}, 1)}
+}
+
+func (m *mockTrigger) TriggerCheck(ctx context.Context) error {
+	m.calls <- struct{}{}
+	return nil
+}
+
+func sign(body string) string {
+	return signWith(testSecret, body)
+}
+
+func signWith(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestNewHandler(t *testing.T) {
+	_, err := NewHandler([]string{""}, 0, newMockTrigger())
+	assert.Error(t, err)
+
+	_, err = NewHandler([]string{testSecret}, -time.Second, newMockTrigger())
+	assert.Error(t, err)
+
+	_, err = NewHandler([]string{testSecret}, 0, nil)
+	assert.Error(t, err)
+
+	handler, err := NewHandler([]string{testSecret}, 0, newMockTrigger())
+	require.NoError(t, err)
+	assert.NotNil(t, handler)
+}
+
+func TestHandler_Challenge(t *testing.T) {
+	handler, err := NewHandler([]string{testSecret}, 0, newMockTrigger())
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/webhook?challenge=abc123", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "abc123", rec.Body.String())
+	assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+
+	req = httptest.NewRequest(http.MethodGet, "/webhook", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestHandler_Notification(t *testing.T) {
+	body := `{"list_folder": {"accounts": ["dbid:AAH4f99T0taONIb-OurWxbNQ6ywGRopQngc"]}, "delta": {"users": [12345678]}}`
+
+	tests := []struct {
+		name        string
+		body        string
+		signature   string
+		wantCode    int
+		wantTrigger bool
+	}{
+		{name: "valid signature", body: body, signature: sign(body), wantCode: http.StatusOK, wantTrigger: true},
+		{name: "missing signature", body: body, signature: "", wantCode: http.StatusForbidden},
+		{name: "wrong signature", body: body, signature: sign("other"), wantCode: http.StatusForbidden},
+		{name: "malformed body", body: "not json", signature: sign("not json"), wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trigger := newMockTrigger()
+			handler, err := NewHandler([]string{testSecret}, 0, trigger)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(tt.body))
+			req.Header.Set(SignatureHeader, tt.signature)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			select {
+			case <-trigger.calls:
+				assert.True(t, tt.wantTrigger, "trigger should not have been called")
+			case <-time.After(100 * time.Millisecond):
+				assert.False(t, tt.wantTrigger, "trigger was not called")
+			}
+		})
+	}
+}
+
+func TestHandler_MethodNotAllowed(t *testing.T) {
+	handler, err := NewHandler([]string{testSecret}, 0, newMockTrigger())
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPut, "/webhook", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func postNotification(handler http.Handler, body, signature string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	req.Header.Set(SignatureHeader, signature)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_SecretRotation(t *testing.T) {
+	trigger := newMockTrigger()
+	handler, err := NewHandler([]string{"new-secret", "old-secret"}, 0, trigger)
+	require.NoError(t, err)
+
+	for _, secret := range []string{"new-secret", "old-secret"} {
+		body := `{"list_folder": {"accounts": ["` + secret + `"]}}`
+		rec := postNotification(handler, body, signWith(secret, body))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		select {
+		case <-trigger.calls:
+		case <-time.After(time.Second):
+			t.Fatalf("notification signed with %s was not processed", secret)
+		}
+	}
+
+	body := `{"list_folder": {"accounts": ["retired"]}}`
+	rec := postNotification(handler, body, signWith("retired-secret", body))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestHandler_ReplayProtection(t *testing.T) {
+	trigger := &mockTrigger{calls: make(chan struct{}, 10)}
+	handler, err := NewHandler([]string{testSecret}, 0, trigger)
+	require.NoError(t, err)
+
+	now := time.Now()
+	handler.nonces.now = func() time.Time { return now }
+
+	body := `{"list_folder": {"accounts": ["dbid:1"]}}`
+	assert.Equal(t, http.StatusOK, postNotification(handler, body, sign(body)).Code)
+	select {
+	case <-trigger.calls:
+	case <-time.After(time.Second):
+		t.Fatal("first notification was not processed")
+	}
+
+	// Repeats within the window are acknowledged but not checked straight away
+	now = now.Add(DefaultReplayWindow - 200*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, postNotification(handler, body, strings.ToUpper(sign(body))).Code)
+	}
+	select {
+	case <-trigger.calls:
+		t.Fatal("repeated notification was checked before its window ended")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Dropbox repeats the same body for genuine changes too, so the repeats
+	// are checked once when the window ends
+	select {
+	case <-trigger.calls:
+	case <-time.After(time.Second):
+		t.Fatal("repeated notifications were not checked after the window")
+	}
+	select {
+	case <-trigger.calls:
+		t.Fatal("repeated notifications were checked more than once")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// Once the window has passed the signature is forgotten
+	now = now.Add(time.Second)
+	assert.Equal(t, http.StatusOK, postNotification(handler, body, sign(body)).Code)
+	select {
+	case <-trigger.calls:
+	case <-time.After(time.Second):
+		t.Fatal("notification after the replay window was not processed")
+	}
+}
+
+// blockingTrigger counts checks, each waiting until released
+type blockingTrigger struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingTrigger) TriggerCheck(ctx context.Context) error {
+	b.started <- struct{}{}
+	<-b.release
+	return nil
+}
+
+func TestHandler_CoalescesNotificationsDuringCheck(t *testing.T) {
+	trigger := &blockingTrigger{started: make(chan struct{}, 10), release: make(chan struct{})}
+	handler, err := NewHandler([]string{testSecret}, 0, trigger)
+	require.NoError(t, err)
+
+	// Distinct accounts, so none of the notifications counts as a repeat
+	notify := func(account int) int {
+		body := fmt.Sprintf(`{"list_folder": {"accounts": ["dbid:%d"]}}`, account)
+		return postNotification(handler, body, sign(body)).Code
+	}
+	notify(1)
+	<-trigger.started
+
+	// Notifications during the check are combined into one further check
+	for i := 2; i < 5; i++ {
+		assert.Equal(t, http.StatusOK, notify(i))
+	}
+	trigger.release <- struct{}{}
+	select {
+	case <-trigger.started:
+	case <-time.After(time.Second):
+		t.Fatal("notifications during the check were not processed")
+	}
+	trigger.release <- struct{}{}
+
+	select {
+	case <-trigger.started:
+		t.Fatal("coalesced notifications were checked more than once")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Once idle, the next notification is checked again
+	notify(5)
+	select {
+	case <-trigger.started:
+	case <-time.After(time.Second):
+		t.Fatal("notification after the checks finished was not processed")
+	}
+	trigger.release <- struct{}{}
+}
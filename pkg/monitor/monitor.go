@@ -0,0 +1,109 @@
+// Package monitor embeds the Dropbox change monitoring pipeline in other Go
+// programs. A Monitor polls the configured folders, stores and reports
+// changes exactly as the cli and web commands do, and passes every set of
+// detected changes to its subscribers.
+package monitor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/config"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/container"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/core"
+	"github.com/christiaanpauw/swarmgo_dropbox_monitor/internal/models"
+)
+
+// Config is the monitor configuration, as read from config.yaml
+type Config = config.Config
+
+// Change is a single file change
+type Change = models.FileChange
+
+// Report is a generated change report
+type Report = models.Report
+
+// ReportType selects the format of a report
+type ReportType = models.ReportType
+
+// Report types
+const (
+	FileListReport  = models.FileListReport
+	NarrativeReport = models.NarrativeReport
+	HTMLReport      = models.HTMLReport
+)
+
+// ChangeHandler receives the changes found by each check
+type ChangeHandler = core.ChangeHandler
+
+// LoadConfig reads and validates a YAML configuration file
+func LoadConfig(path string) (*Config, error) {
+	return config.LoadConfig(path)
+}
+
+// Monitor runs the monitoring pipeline
+type Monitor struct {
+	container *container.Container
+}
+
+// New validates cfg and creates a monitor; call Start to begin monitoring
+func New(cfg *Config) (*Monitor, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("config cannot be nil")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	c, err := container.NewContainer(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create monitor: %w", err)
+	}
+	return &Monitor{container: c}, nil
+}
+
+// Start begins polling and scheduled reporting
+func (m *Monitor) Start(ctx context.Context) error {
+	return m.container.Start(ctx)
+}
+
+// Stop stops polling and scheduled reporting
+func (m *Monitor) Stop(ctx context.Context) error {
+	return m.container.Stop(ctx)
+}
+
+// Health reports whether all components are running
+func (m *Monitor) Health(ctx context.Context) error {
+	return m.container.Health(ctx)
+}
+
+// Reload applies a new configuration to the running monitor. The poll
+// interval and notification channels change immediately; settings such as
+// the Dropbox token and database path need a restart.
+func (m *Monitor) Reload(ctx context.Context, cfg *Config) error {
+	return m.container.Reload(ctx, cfg)
+}
+
+// Subscribe registers a handler for every set of changes the monitor
+// detects or ingests, and returns a function that removes it. Handlers run
+// synchronously on the checking goroutine, so they should return quickly;
+// their errors are logged.
+func (m *Monitor) Subscribe(handler ChangeHandler) (unsubscribe func()) {
+	return m.container.Subscribe(handler)
+}
+
+// Check looks for changes immediately instead of waiting for the next poll
+func (m *Monitor) Check(ctx context.Context) error {
+	return m.container.TriggerCheck(ctx)
+}
+
+// Ingest stores and reports changes from another source as if they had been
+// detected by the monitor
+func (m *Monitor) Ingest(ctx context.Context, changes []Change) error {
+	return m.container.IngestChanges(ctx, changes)
+}
+
+// GenerateReport renders a report of the pending changes without sending it
+func (m *Monitor) GenerateReport(ctx context.Context, reportType ReportType) (*Report, error) {
+	return m.container.PreviewReport(ctx, reportType)
+}
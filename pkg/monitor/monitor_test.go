@@ -0,0 +1,62 @@
+package monitor
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig(t *testing.T) *Config {
+	dir := t.TempDir()
+	cfg := &Config{
+		DropboxToken: "test-token",
+		PollInterval: 5 * time.Minute,
+	}
+	cfg.Database.Path = filepath.Join(dir, "monitor.db")
+	cfg.State.Path = filepath.Join(dir, "state.json")
+	cfg.Retry.MaxAttempts = 1
+	cfg.Retry.Delay = time.Second
+	cfg.HealthCheck.Interval = time.Minute
+	return cfg
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(nil)
+	assert.Error(t, err)
+
+	_, err = New(&Config{})
+	assert.ErrorContains(t, err, "invalid config")
+
+	m, err := New(testConfig(t))
+	require.NoError(t, err)
+	assert.NotNil(t, m)
+}
+
+func TestMonitor_Subscribe(t *testing.T) {
+	m, err := New(testConfig(t))
+	require.NoError(t, err)
+
+	var received [][]Change
+	unsubscribe := m.Subscribe(func(ctx context.Context, changes []Change) error {
+		received = append(received, changes)
+		return nil
+	})
+
+	ctx := context.Background()
+	require.NoError(t, m.Start(ctx))
+	defer m.Stop(ctx)
+
+	require.NoError(t, m.Ingest(ctx, []Change{{Path: "/docs/a.txt", Modified: time.Now()}}))
+	require.Len(t, received, 1)
+	assert.Equal(t, "/docs/a.txt", received[0][0].Path)
+	assert.Equal(t, ".txt", received[0][0].Extension)
+
+	unsubscribe()
+	unsubscribe()
+	require.NoError(t, m.Ingest(ctx, []Change{{Path: "/docs/b.txt", Modified: time.Now()}}))
+	assert.Len(t, received, 1)
+}